@@ -0,0 +1,197 @@
+// project-bundler/advise.go
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// adviceKind identifies which command-line flag a suggestion maps to.
+type adviceKind int
+
+const (
+	adviceDir adviceKind = iota
+	adviceExt
+)
+
+// suggestion is a single candidate exclusion with its projected savings.
+type suggestion struct {
+	kind  adviceKind
+	name  string // Directory name or file extension.
+	bytes int64
+	files int
+}
+
+// runAdvise implements the `advise` subcommand. It analyzes what a bundle
+// would contain and proposes exclusions, expressed as the existing
+// -ignore-dirs / -ignore-exts flags, ordered by how much they would save.
+func runAdvise(args []string) {
+	fs := flag.NewFlagSet("advise", flag.ExitOnError)
+	srcDir := fs.String("src", ".", "Source project directory.")
+	projectType := fs.String("type", "auto", "Project type. Options: "+strings.Join(availableProjectTypes(), ", "))
+	ignoreDirsStr := fs.String("ignore-dirs", "", "Comma-separated list of directories to ignore. Overrides the project type's default.")
+	ignoreExtsStr := fs.String("ignore-exts", "", "Comma-separated list of file extensions to ignore. Overrides the project type's default.")
+	noDefaultIgnores := fs.Bool("no-default-ignores", false, "Do not ignore the common junk directories shared by all presets.")
+	top := fs.Int("top", 5, "Maximum number of suggestions to print.")
+	minSavings := fs.Float64("min-savings", 5, "Only suggest exclusions saving at least this percentage of the bundle.")
+	apply := fs.Bool("apply", false, "Interactively choose suggestions and write the reduced bundle.")
+	outputFile := fs.String("output", "bundle.md", "Output markdown file used with -apply.")
+	fs.Parse(args)
+
+	opts, err := resolveOptions(*srcDir, *projectType, *ignoreDirsStr, *ignoreExtsStr, *noDefaultIgnores)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	files, _, err := collectFiles(opts)
+	if err != nil {
+		log.Fatalf("Error during directory walk: %v", err)
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	fmt.Printf("\nBundle composition for '%s' (type: %s): %d files, %s\n", opts.SrcDir, opts.ProjectType, len(files), formatSize(total))
+	if total == 0 {
+		fmt.Println("Nothing to advise: the bundle is empty.")
+		return
+	}
+
+	suggestions := buildSuggestions(files, total, *minSavings)
+	if len(suggestions) > *top {
+		suggestions = suggestions[:*top]
+	}
+	if len(suggestions) == 0 {
+		fmt.Printf("No single directory or extension accounts for %.0f%% or more of the bundle.\n", *minSavings)
+		return
+	}
+
+	fmt.Println("\nSuggestions:")
+	for i, s := range suggestions {
+		fmt.Printf("  %d. %s saves %.0f%% (%s, %d files)\n", i+1, s.describe(), percent(s.bytes, total), formatSize(s.bytes), s.files)
+		fmt.Printf("     %s\n", s.flag(opts))
+	}
+
+	if !*apply {
+		return
+	}
+
+	// Let the user pick suggestions one by one, then bundle with the combined result.
+	reader := bufio.NewReader(os.Stdin)
+	for _, s := range suggestions {
+		fmt.Printf("\nApply: %s? [y/N] ", s.describe())
+		answer, _ := reader.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			continue
+		}
+		switch s.kind {
+		case adviceDir:
+			opts.IgnoreDirs.Add([]string{s.name}, "advise -apply")
+		case adviceExt:
+			opts.IgnoreExts.Add([]string{s.name}, "advise -apply")
+		}
+	}
+
+	fmt.Printf("\nEquivalent command:\n  project-bundler -src=%q -type=%s -ignore-dirs=%q -ignore-exts=%q -output=%q\n\n",
+		opts.SrcDir, opts.ProjectType, strings.Join(opts.IgnoreDirs.Sorted(), ","), strings.Join(opts.IgnoreExts.Sorted(), ","), *outputFile)
+	if _, err := writeBundle(opts, *outputFile, false); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+// buildSuggestions aggregates file sizes by directory name and extension and
+// returns the candidates saving at least minSavings percent, largest first.
+// Directory names are aggregated across all depths because -ignore-dirs
+// matches by name wherever it appears in the tree.
+func buildSuggestions(files []fileEntry, total int64, minSavings float64) []suggestion {
+	dirs := make(map[string]*suggestion)
+	exts := make(map[string]*suggestion)
+
+	for _, f := range files {
+		// Count each directory name at most once per file.
+		seen := make(stringSet)
+		for _, part := range strings.Split(filepath.Dir(f.RelPath), string(filepath.Separator)) {
+			if part == "." || part == "" || seen.Contains(part) {
+				continue
+			}
+			seen[part] = struct{}{}
+			s, ok := dirs[part]
+			if !ok {
+				s = &suggestion{kind: adviceDir, name: part}
+				dirs[part] = s
+			}
+			s.bytes += f.Size
+			s.files++
+		}
+
+		if ext := filepath.Ext(f.RelPath); ext != "" {
+			s, ok := exts[ext]
+			if !ok {
+				s = &suggestion{kind: adviceExt, name: ext}
+				exts[ext] = s
+			}
+			s.bytes += f.Size
+			s.files++
+		}
+	}
+
+	var result []suggestion
+	for _, group := range []map[string]*suggestion{dirs, exts} {
+		for _, s := range group {
+			// Excluding everything is never useful advice.
+			if s.bytes == total || percent(s.bytes, total) < minSavings {
+				continue
+			}
+			result = append(result, *s)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].bytes != result[j].bytes {
+			return result[i].bytes > result[j].bytes
+		}
+		return result[i].name < result[j].name
+	})
+	return result
+}
+
+func (s suggestion) describe() string {
+	if s.kind == adviceDir {
+		return fmt.Sprintf("excluding directory '%s'", s.name)
+	}
+	return fmt.Sprintf("excluding extension '%s'", s.name)
+}
+
+// flag renders the command-line flag that applies this suggestion. Because the
+// ignore flags replace the preset defaults, the current list is carried over.
+func (s suggestion) flag(opts bundleOptions) string {
+	if s.kind == adviceDir {
+		return fmt.Sprintf("-ignore-dirs=%q", strings.Join(append(opts.IgnoreDirs.Sorted(), s.name), ","))
+	}
+	return fmt.Sprintf("-ignore-exts=%q", strings.Join(append(opts.IgnoreExts.Sorted(), s.name), ","))
+}
+
+func percent(part, total int64) float64 {
+	return float64(part) * 100 / float64(total)
+}
+
+// formatSize renders a byte count using binary units (KB, MB, GB).
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,185 @@
+// project-bundler/annotate.go
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// symbolPatterns describes how to find imports and exported symbols for a
+// language using regular expressions. Each pattern's first capture group is
+// the import path or symbol name.
+type symbolPatterns struct {
+	imports []*regexp.Regexp
+	exports []*regexp.Regexp
+	// private reports whether a matched export name is actually private by
+	// naming convention (e.g. a leading underscore in Dart and Python).
+	private func(name string) bool
+}
+
+func hasUnderscorePrefix(name string) bool { return strings.HasPrefix(name, "_") }
+
+// languagePatterns holds regex-based extractors keyed by language identifier.
+// Go is handled separately with the real parser.
+var languagePatterns = map[string]symbolPatterns{
+	"rust": {
+		imports: []*regexp.Regexp{regexp.MustCompile(`(?m)^\s*(?:pub\s+)?use\s+([\w:]+)`)},
+		exports: []*regexp.Regexp{regexp.MustCompile(`(?m)^pub\s+(?:async\s+)?(?:fn|struct|enum|trait|type|const|static|mod)\s+(\w+)`)},
+	},
+	"dart": {
+		imports: []*regexp.Regexp{regexp.MustCompile(`(?m)^import\s+'([^']+)'`)},
+		exports: []*regexp.Regexp{regexp.MustCompile(`(?m)^(?:abstract\s+|sealed\s+|final\s+)*(?:class|mixin|enum|extension|typedef)\s+(\w+)`)},
+		private: hasUnderscorePrefix,
+	},
+	"java": {
+		imports: []*regexp.Regexp{regexp.MustCompile(`(?m)^import\s+(?:static\s+)?([\w.*]+);`)},
+		exports: []*regexp.Regexp{regexp.MustCompile(`(?m)^public\s+(?:(?:abstract|final|sealed)\s+)*(?:class|interface|enum|record|@interface)\s+(\w+)`)},
+	},
+	"kotlin": {
+		imports: []*regexp.Regexp{regexp.MustCompile(`(?m)^import\s+([\w.*]+)`)},
+		exports: []*regexp.Regexp{regexp.MustCompile(`(?m)^(?:(?:public|data|sealed|abstract|open|enum|inline|value)\s+)*(?:class|interface|object|fun|typealias)\s+(\w+)`)},
+	},
+	"swift": {
+		imports: []*regexp.Regexp{regexp.MustCompile(`(?m)^import\s+(\w+)`)},
+		exports: []*regexp.Regexp{regexp.MustCompile(`(?m)^(?:public|open)\s+(?:final\s+)?(?:class|struct|enum|protocol|func|actor|typealias)\s+(\w+)`)},
+	},
+	"python": {
+		imports: []*regexp.Regexp{
+			regexp.MustCompile(`(?m)^import\s+([\w.]+)`),
+			regexp.MustCompile(`(?m)^from\s+([\w.]+)\s+import`),
+		},
+		exports: []*regexp.Regexp{regexp.MustCompile(`(?m)^(?:async\s+)?(?:def|class)\s+(\w+)`)},
+		private: hasUnderscorePrefix,
+	},
+	"javascript": {
+		imports: []*regexp.Regexp{
+			regexp.MustCompile(`(?m)^import\s+(?:[^'"]*\s+from\s+)?['"]([^'"]+)['"]`),
+			regexp.MustCompile(`require\(\s*['"]([^'"]+)['"]\s*\)`),
+		},
+		exports: []*regexp.Regexp{regexp.MustCompile(`(?m)^export\s+(?:default\s+)?(?:async\s+)?(?:function\*?|class|const|let|var|interface|type|enum)\s+(\w+)`)},
+	},
+}
+
+func init() {
+	languagePatterns["typescript"] = languagePatterns["javascript"]
+}
+
+// summarizeSymbols returns the imports and exported symbols of a source file.
+// ok is false when the language is not supported or the file cannot be parsed.
+func summarizeSymbols(lang string, content []byte) (imports, exports []string, ok bool) {
+	if lang == "go" {
+		return summarizeGoSymbols(content)
+	}
+
+	patterns, found := languagePatterns[lang]
+	if !found {
+		return nil, nil, false
+	}
+	for _, re := range patterns.imports {
+		for _, m := range re.FindAllSubmatch(content, -1) {
+			imports = appendUnique(imports, string(m[1]))
+		}
+	}
+	for _, re := range patterns.exports {
+		for _, m := range re.FindAllSubmatch(content, -1) {
+			name := string(m[1])
+			if patterns.private != nil && patterns.private(name) {
+				continue
+			}
+			exports = appendUnique(exports, name)
+		}
+	}
+	return imports, exports, true
+}
+
+// summarizeGoSymbols uses go/parser so that grouped declarations, methods and
+// build-constrained files are handled exactly.
+func summarizeGoSymbols(content []byte) (imports, exports []string, ok bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	for _, imp := range file.Imports {
+		imports = append(imports, strings.Trim(imp.Path.Value, `"`))
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := receiverTypeName(d.Recv.List[0].Type)
+				if !ast.IsExported(recv) {
+					continue
+				}
+				exports = append(exports, recv+"."+d.Name.Name)
+				continue
+			}
+			exports = append(exports, d.Name.Name)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.IsExported() {
+						exports = append(exports, s.Name.Name)
+					}
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						if name.IsExported() {
+							exports = append(exports, name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return imports, exports, true
+}
+
+// receiverTypeName unwraps pointer and generic receivers to the base type name.
+func receiverTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexListExpr:
+		return receiverTypeName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// symbolSummaryLine renders the one-line annotation written above a file's
+// code block, or "" when there is nothing worth reporting.
+func symbolSummaryLine(lang string, content []byte) string {
+	imports, exports, ok := summarizeSymbols(lang, content)
+	if !ok || (len(imports) == 0 && len(exports) == 0) {
+		return ""
+	}
+	return "Imports: " + joinOrNone(imports) + " | Exports: " + joinOrNone(exports) + "\n"
+}
+
+func joinOrNone(items []string) string {
+	if len(items) == 0 {
+		return "(none)"
+	}
+	return strings.Join(items, ", ")
+}
+
+func appendUnique(items []string, item string) []string {
+	for _, existing := range items {
+		if existing == item {
+			return items
+		}
+	}
+	return append(items, item)
+}
@@ -0,0 +1,219 @@
+// project-bundler/apidiff.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// apiSurface maps a package directory to its exported declarations, keyed by
+// name ("Type.Method" for methods) with the declaration as printed source.
+type apiSurface map[string]map[string]string
+
+// apiChange is one difference between two API surfaces.
+type apiChange struct {
+	pkg, name string
+	kind      string // "removed", "changed" or "added".
+	old, new  string
+}
+
+// apiDiff is the -api-diff result written as a section of the bundle.
+type apiDiff struct {
+	from, to string // The refs as given, e.g. "v1.2.0" and "HEAD".
+	changes  []apiChange
+}
+
+// computeAPIDiff compares the exported Go API of srcDir between the two refs
+// of a "FROM..TO" range; an empty TO means HEAD.
+func computeAPIDiff(srcDir, refRange string) (*apiDiff, error) {
+	from, to, ok := strings.Cut(refRange, "..")
+	if !ok || from == "" {
+		return nil, fmt.Errorf("invalid range '%s': use FROM..TO, e.g. v1.2.0..HEAD", refRange)
+	}
+	if to == "" {
+		to = "HEAD"
+	}
+	surfaces := make([]apiSurface, 2)
+	for i, rev := range []string{from, to} {
+		dir, _, cleanup, err := checkoutRevision(srcDir, rev)
+		if err != nil {
+			return nil, fmt.Errorf("could not check out '%s': %w", rev, err)
+		}
+		surfaces[i], err = goAPISurface(dir)
+		cleanup()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &apiDiff{from: from, to: to, changes: diffAPISurfaces(surfaces[0], surfaces[1])}, nil
+}
+
+// goAPISurface collects the exported declarations of every importable
+// package under dir. Tests, testdata, vendored code and internal packages are
+// not part of the public API.
+func goAPISurface(dir string) (apiSurface, error) {
+	surface := make(apiSurface)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if p != dir && (name == "vendor" || name == "testdata" || name == "internal" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, filepath.Dir(p))
+		pkg := filepath.ToSlash(rel)
+		decls, ok := goExportedDecls(content)
+		if !ok {
+			return nil // Files that do not parse (e.g. templates) have no API.
+		}
+		if surface[pkg] == nil {
+			surface[pkg] = make(map[string]string)
+		}
+		for k, v := range decls {
+			surface[pkg][k] = v
+		}
+		return nil
+	})
+	return surface, err
+}
+
+// goExportedDecls prints each exported declaration of a file without doc
+// comments and function bodies, so only signature changes show up.
+func goExportedDecls(content []byte) (map[string]string, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+	if err != nil || file.Name.Name == "main" {
+		return nil, false
+	}
+	ast.FileExports(file)
+	decls := make(map[string]string)
+	render := func(node any) string {
+		var buf bytes.Buffer
+		printer.Fprint(&buf, fset, node)
+		return buf.String()
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := receiverTypeName(d.Recv.List[0].Type)
+				if !ast.IsExported(recv) {
+					continue
+				}
+				name = recv + "." + name
+			}
+			d.Doc, d.Body = nil, nil
+			decls[name] = render(d)
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, spec := range d.Specs {
+				single := &ast.GenDecl{Tok: d.Tok, Specs: []ast.Spec{spec}}
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					s.Doc, s.Comment = nil, nil
+					decls[s.Name.Name] = render(single)
+				case *ast.ValueSpec:
+					s.Doc, s.Comment = nil, nil
+					for _, name := range s.Names {
+						decls[name.Name] = render(single)
+					}
+				}
+			}
+		}
+	}
+	return decls, true
+}
+
+// diffAPISurfaces lists removed, changed and added declarations, in that
+// order of severity and then by package and name.
+func diffAPISurfaces(old, new apiSurface) []apiChange {
+	var changes []apiChange
+	for pkg, decls := range old {
+		for name, sig := range decls {
+			switch newSig, ok := new[pkg][name]; {
+			case !ok:
+				changes = append(changes, apiChange{pkg: pkg, name: name, kind: "removed", old: sig})
+			case newSig != sig:
+				changes = append(changes, apiChange{pkg: pkg, name: name, kind: "changed", old: sig, new: newSig})
+			}
+		}
+	}
+	for pkg, decls := range new {
+		for name, sig := range decls {
+			if _, ok := old[pkg][name]; !ok {
+				changes = append(changes, apiChange{pkg: pkg, name: name, kind: "added", new: sig})
+			}
+		}
+	}
+	severity := map[string]int{"removed": 0, "changed": 1, "added": 2}
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.kind != b.kind {
+			return severity[a.kind] < severity[b.kind]
+		}
+		if a.pkg != b.pkg {
+			return a.pkg < b.pkg
+		}
+		return a.name < b.name
+	})
+	return changes
+}
+
+// writeAPIDiffSection renders the API changes as a section. Removed and
+// changed declarations are marked as potentially breaking.
+func writeAPIDiffSection(w io.Writer, d *apiDiff) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Exported API changes %s..%s (%d):\n\n", d.from, d.to, len(d.changes))
+	if len(d.changes) == 0 {
+		b.WriteString("No changes to the exported Go API.\n\n")
+	}
+	for _, c := range d.changes {
+		label := c.kind
+		if c.kind != "added" {
+			label += ", potentially breaking"
+		}
+		fmt.Fprintf(&b, "- `%s`: `%s` (%s)\n", path.Clean(c.pkg), c.name, label)
+		diff := indentLines(c.old, "  - ") + indentLines(c.new, "  + ")
+		fmt.Fprintf(&b, "\n  ```diff\n%s  ```\n\n", diff)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// indentLines prefixes every line of s with indent and ends each with a
+// newline; an empty s yields "".
+func indentLines(s, indent string) string {
+	if s == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(s, "\n") {
+		b.WriteString(indent + line + "\n")
+	}
+	return b.String()
+}
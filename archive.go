@@ -0,0 +1,201 @@
+// project-bundler/archive.go
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// archiveSeparator joins an archive's path and the path of an entry inside
+// it, as in testdata/fixture.zip!/users.json.
+const archiveSeparator = "!/"
+
+// archiveFormat returns the format of an archive by its file name, or "".
+func archiveFormat(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tgz"
+	}
+	return ""
+}
+
+// archiveEntry is one regular file read from an archive.
+type archiveEntry struct {
+	name    string // Slash-separated path inside the archive.
+	mode    os.FileMode
+	modTime time.Time
+	content []byte
+}
+
+// readArchive returns the regular files of an archive, stopping with an
+// error once their total size would exceed limit, which keeps compression
+// bombs from exhausting memory.
+func readArchive(data []byte, format string, limit int64) ([]archiveEntry, error) {
+	var entries []archiveEntry
+	var total int64
+	add := func(name string, mode os.FileMode, modTime time.Time, r io.Reader, size int64) error {
+		if total+size > limit {
+			return fmt.Errorf("expands to more than %s", formatSize(limit))
+		}
+		content, err := io.ReadAll(io.LimitReader(r, limit-total+1))
+		if err != nil {
+			return err
+		}
+		if total += int64(len(content)); total > limit {
+			return fmt.Errorf("expands to more than %s", formatSize(limit))
+		}
+		entries = append(entries, archiveEntry{name: name, mode: mode.Perm(), modTime: modTime, content: content})
+		return nil
+	}
+
+	if format == "zip" {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			err = add(f.Name, f.Mode(), f.Modified, rc, int64(f.UncompressedSize64))
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return entries, nil
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+	if format == "tgz" {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := add(hdr.Name, hdr.FileInfo().Mode(), hdr.ModTime, tr, hdr.Size); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// expandArchives opens the archives the walk skipped as binary content and
+// returns their text files as virtual entries under archive!/path, with their
+// contents keyed by fileEntry.Path. Archives larger than limit, or expanding
+// to more than limit, stay skipped. Expanded archives are removed from the
+// skipped report; entries are filtered by the ignore rules like files on disk.
+func expandArchives(opts bundleOptions, skipped map[string][]string, limit int64) ([]fileEntry, map[string][]byte) {
+	var files []fileEntry
+	contents := make(map[string][]byte)
+	var stillSkipped []string
+	for _, archivePath := range skipped[bundler.ReasonBinary] {
+		format := archiveFormat(archivePath)
+		info, err := os.Stat(archivePath)
+		if format == "" || err != nil || info.Size() > limit {
+			stillSkipped = append(stillSkipped, archivePath)
+			continue
+		}
+		data, err := os.ReadFile(archivePath)
+		var entries []archiveEntry
+		if err == nil {
+			entries, err = readArchive(data, format, limit)
+		}
+		if err != nil {
+			log.Printf("Not expanding archive %s: %v", archivePath, err)
+			stillSkipped = append(stillSkipped, archivePath)
+			continue
+		}
+		rel, err := filepath.Rel(opts.SrcDir, archivePath)
+		if err != nil {
+			rel = archivePath
+		}
+		for _, e := range entries {
+			name := path.Clean(strings.TrimPrefix(e.name, "/"))
+			virtual := archivePath + archiveSeparator + name
+			if rule := bundler.SecretRule(name, func() []byte { return e.content }); rule != "" {
+				if !opts.AllowSecrets {
+					skipped[bundler.ReasonSecret] = append(skipped[bundler.ReasonSecret], virtual)
+					continue
+				}
+				log.Printf("Warning: bundling secret file %s (%s) because of -unsafe-include-secrets", virtual, rule)
+			}
+			if reason := archiveEntrySkip(opts, name, e.content); reason != "" {
+				skipped[reason] = append(skipped[reason], virtual)
+				continue
+			}
+			files = append(files, fileEntry{
+				Path:    virtual,
+				RelPath: rel + archiveSeparator + filepath.FromSlash(name),
+				Lang:    bundler.DetectLanguageContent(path.Base(name), opts.LangMap, e.content[:min(len(e.content), 1024)]),
+				Size:    int64(len(e.content)),
+				Mode:    e.mode,
+				ModTime: e.modTime,
+			})
+			contents[virtual] = e.content
+		}
+	}
+	if len(stillSkipped) > 0 {
+		skipped[bundler.ReasonBinary] = stillSkipped
+	} else {
+		delete(skipped, bundler.ReasonBinary)
+	}
+	return files, contents
+}
+
+// archiveEntrySkip applies the directory and extension ignore rules and the
+// binary check to an archive entry, returning the skip reason or "".
+func archiveEntrySkip(opts bundleOptions, name string, content []byte) string {
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		if opts.IgnoreDirs.Contains(dir) {
+			return bundler.ReasonIgnoredDir
+		}
+	}
+	base := path.Base(name)
+	if opts.IgnoreExts.Contains(path.Ext(base)) || opts.IgnoreExts.Contains(base) {
+		return bundler.ReasonIgnoredExt
+	}
+	for _, suffix := range opts.IgnoreSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			return bundler.ReasonIgnoredExt
+		}
+	}
+	if bytes.IndexByte(content[:min(len(content), 1024)], 0) >= 0 {
+		return bundler.ReasonBinary
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+// project-bundler/binary.go
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// binaryModes are the values of -binary-mode.
+var binaryModes = []string{"skip", "list", "base64"}
+
+// binaryBlock is the block content of a binary file for -binary-mode: a line
+// with its size and media type, followed with base64 by the file's bytes
+// when they fit in -binary-max-size.
+func binaryBlock(opts bundleOptions, f fileEntry) ([]byte, error) {
+	embed := opts.binaryMode == "base64" && f.Size <= opts.binaryMaxSize
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var data []byte
+	if embed {
+		data, err = io.ReadAll(file)
+	} else {
+		data = make([]byte, 512) // All http.DetectContentType looks at.
+		var n int
+		n, err = io.ReadFull(file, data)
+		data = data[:n]
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kind := binaryType(f.RelPath, data)
+	switch {
+	case embed:
+		var b strings.Builder
+		fmt.Fprintf(&b, "(binary, %s, %s, base64)\n", formatSize(f.Size), kind)
+		encoded := base64.StdEncoding.EncodeToString(data)
+		for len(encoded) > 76 {
+			b.WriteString(encoded[:76] + "\n")
+			encoded = encoded[76:]
+		}
+		b.WriteString(encoded)
+		return []byte(b.String()), nil
+	case opts.binaryMode == "base64":
+		return []byte(fmt.Sprintf("(binary, %s, %s; larger than -binary-max-size %s, so not embedded)", formatSize(f.Size), kind, formatSize(opts.binaryMaxSize))), nil
+	}
+	return []byte(fmt.Sprintf("(binary, %s, %s; re-run with -binary-mode=base64 to embed it)", formatSize(f.Size), kind)), nil
+}
+
+// binaryType returns the media type of a binary file, sniffed from its first
+// bytes or, when they are not recognized, guessed from its extension.
+func binaryType(name string, head []byte) string {
+	kind := http.DetectContentType(head)
+	if kind == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(filepath.Ext(name)); byExt != "" {
+			kind = byExt
+		}
+	}
+	kind, _, _ = strings.Cut(kind, ";")
+	return kind
+}
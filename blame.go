@@ -0,0 +1,110 @@
+// project-bundler/blame.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// blameAuthorWidth caps the author column so long names do not push the
+// code far to the right.
+const blameAuthorWidth = 14
+
+// blameLine is one line of `git blame --line-porcelain` output.
+type blameLine struct {
+	commit string
+	author string
+	when   time.Time
+	text   string
+}
+
+// blameSelected reports whether relPath is one of the -blame paths, or lies
+// inside one of them when the path names a directory.
+func blameSelected(paths []string, relPath string) bool {
+	rel := filepath.ToSlash(relPath)
+	for _, p := range paths {
+		p = strings.TrimSuffix(filepath.ToSlash(p), "/")
+		if rel == p || strings.HasPrefix(rel, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// blameAnnotate returns the file's lines prefixed with the abbreviated
+// commit, author and age of the change that last touched each line.
+func blameAnnotate(srcDir, relPath string, now time.Time) ([]byte, error) {
+	out, err := gitOutput(srcDir, "blame", "--line-porcelain", "--", filepath.ToSlash(relPath))
+	if err != nil {
+		return nil, err
+	}
+	lines := parseBlame(out)
+
+	width := 0
+	for _, l := range lines {
+		width = max(width, len([]rune(l.author)))
+	}
+	width = min(width, blameAuthorWidth)
+
+	var buf bytes.Buffer
+	for _, l := range lines {
+		author := []rune(l.author)
+		if len(author) > width {
+			author = append(author[:width-1], '…')
+		}
+		fmt.Fprintf(&buf, "%.7s %-*s %4s | %s\n", l.commit, width, string(author), blameAge(now.Sub(l.when)), l.text)
+	}
+	return buf.Bytes(), nil
+}
+
+// parseBlame reads --line-porcelain output, where every line repeats the full
+// commit header followed by the line content prefixed with a tab.
+func parseBlame(out string) []blameLine {
+	var lines []blameLine
+	var cur blameLine
+	header := true
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			cur.text = line[1:]
+			lines = append(lines, cur)
+			cur, header = blameLine{}, true
+		case header:
+			cur.commit, _, _ = strings.Cut(line, " ")
+			header = false
+		case strings.HasPrefix(line, "author "):
+			cur.author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-time "):
+			if sec, err := strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64); err == nil {
+				cur.when = time.Unix(sec, 0)
+			}
+		}
+	}
+	return lines
+}
+
+// blameAge renders a duration in the coarsest sensible unit, e.g. "3d" or "2y".
+func blameAge(d time.Duration) string {
+	day := 24 * time.Hour
+	switch {
+	case d < time.Hour:
+		return "now"
+	case d < day:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d < 14*day:
+		return fmt.Sprintf("%dd", d/day)
+	case d < 60*day:
+		return fmt.Sprintf("%dw", d/(7*day))
+	case d < 365*day:
+		return fmt.Sprintf("%dmo", d/(30*day))
+	}
+	return fmt.Sprintf("%dy", d/(365*day))
+}
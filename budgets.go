@@ -0,0 +1,105 @@
+// project-bundler/budgets.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// dirBudget caps the tokens spent on the files matching a glob. Budgets come
+// from the local config, e.g. budgets: {"internal/legacy/**": 5k}.
+type dirBudget struct {
+	pattern string
+	rule    ruleSet // Holds just pattern, for MatchingGlob.
+	tokens  int
+	spent   int
+}
+
+// parseBudgets reads the local config's budgets, in pattern order.
+func parseBudgets(budgets map[string]string, file string) ([]*dirBudget, error) {
+	patterns := make([]string, 0, len(budgets))
+	for pattern := range budgets {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	var parsed []*dirBudget
+	for _, pattern := range patterns {
+		tokens, err := parseTokenCount(budgets[pattern])
+		if err != nil || tokens <= 0 {
+			return nil, fmt.Errorf("%s: invalid budget '%s' for '%s': use a token count such as 5k", file, budgets[pattern], pattern)
+		}
+		rule := make(ruleSet)
+		rule.Add([]string{pattern}, file)
+		parsed = append(parsed, &dirBudget{pattern: pattern, rule: rule, tokens: tokens})
+	}
+	return parsed, nil
+}
+
+// matchingBudgets returns the budgets a file counts against: all whose glob
+// matches it, so a nested directory's budget applies within its parent's.
+func matchingBudgets(budgets []*dirBudget, relPath string) []*dirBudget {
+	var matching []*dirBudget
+	for _, b := range budgets {
+		if _, ok := b.rule.MatchingGlob(relPath); ok {
+			matching = append(matching, b)
+		}
+	}
+	return matching
+}
+
+// fitBudgets cuts content down to what the budgets covering it have left and
+// charges them for the rest. Go that does not fit is first outlined to its
+// exported API; what still does not fit keeps only its leading lines. It
+// returns the annotation describing the cut, or "" if the file fit as is.
+func fitBudgets(budgets []*dirBudget, tok tokenizer, lang string, content []byte) ([]byte, string) {
+	if len(budgets) == 0 {
+		return content, ""
+	}
+	tightest := budgets[0]
+	for _, b := range budgets[1:] {
+		if b.tokens-b.spent < tightest.tokens-tightest.spent {
+			tightest = b
+		}
+	}
+	left := max(0, tightest.tokens-tightest.spent)
+	n := tok.count(content)
+	var note string
+	if n > left {
+		if api, ok := goExportedAPI(lang, content); ok {
+			content, n = api, tok.count(api)
+			note = fmt.Sprintf("Exported API only: over the %d token budget of %s.\n", tightest.tokens, tightest.pattern)
+		}
+	}
+	if n > left {
+		var kept, total int
+		content, n, kept, total = truncateToTokens(content, tok, left)
+		note = fmt.Sprintf("Truncated: over the %d token budget of %s; the first %d of %d lines are shown.\n", tightest.tokens, tightest.pattern, kept, total)
+	}
+	for _, b := range budgets {
+		b.spent += n
+	}
+	return content, note
+}
+
+// truncateToTokens keeps the leading lines of content that fit in limit
+// tokens, followed by a marker line. It returns the tokens of the lines kept
+// and how many of how many lines that is.
+func truncateToTokens(content []byte, tok tokenizer, limit int) (cut []byte, tokens, kept, total int) {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	var b bytes.Buffer
+	for _, line := range lines {
+		n := tok.count(line)
+		if tokens+n > limit {
+			break
+		}
+		b.Write(line)
+		tokens += n
+		kept++
+	}
+	fmt.Fprintf(&b, "... truncated to fit the token budget (%d lines omitted) ...\n", len(lines)-kept)
+	return b.Bytes(), tokens, kept, len(lines)
+}
@@ -0,0 +1,39 @@
+// project-bundler/buildcontext.go
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"strings"
+)
+
+// parseBuildContext parses a -build-context value such as "linux/amd64" or
+// "linux/amd64,netgo,cgo" into a go/build context. The "cgo" tag enables cgo;
+// without it, files that require cgo are excluded.
+func parseBuildContext(s string) (*build.Context, error) {
+	parts := strings.Split(s, ",")
+	goos, goarch, ok := strings.Cut(strings.TrimSpace(parts[0]), "/")
+	if !ok || goos == "" || goarch == "" {
+		return nil, fmt.Errorf("invalid build context '%s': start with GOOS/GOARCH, e.g. linux/amd64,netgo", s)
+	}
+	ctx := build.Default
+	ctx.GOOS, ctx.GOARCH = goos, goarch
+	ctx.CgoEnabled = false
+	ctx.BuildTags = nil
+	for _, tag := range parts[1:] {
+		tag = strings.TrimSpace(tag)
+		if tag == "cgo" {
+			ctx.CgoEnabled = true
+			continue
+		}
+		if tag != "" {
+			ctx.BuildTags = append(ctx.BuildTags, tag)
+		}
+	}
+	return &ctx, nil
+}
+
+// buildContextLabel names a context in bundle annotations, e.g. "linux/amd64".
+func buildContextLabel(ctx *build.Context) string {
+	return ctx.GOOS + "/" + ctx.GOARCH
+}
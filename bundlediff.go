@@ -0,0 +1,333 @@
+// project-bundler/bundlediff.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// runDiff implements the `diff` subcommand, which compares a bundle with the
+// source tree (or with another bundle) and lists the files added, removed
+// and changed since, for example to review what a model changed once its
+// reply was unbundled. Like diff(1) it exits with status 1 when there are
+// differences.
+//
+//	project-bundler diff -u bundle.md .
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	unified := fs.Bool("u", false, "Print a unified diff of each added, removed and changed file instead of only listing them.")
+	contextLines := fs.Int("U", 3, "Lines of context around each change in unified diffs.")
+	pathGlob := fs.String("path", "", "Compare only files whose path matches this glob, with the syntax of -ignore-paths, e.g. 'pkg/**/*.go'.")
+	projectType := fs.String("type", "auto", "Project type whose rules select the files of a directory, as for bundling. Options: auto, "+strings.Join(availableProjectTypes(), ", "))
+	styleName := fs.String("style", "github", "Output style the bundles were written with. Options: "+strings.Join(bundler.StyleNames(), ", "))
+	lenient := fs.Bool("lenient", false, "Recover what can be read from malformed bundles, such as ones edited by hand, and warn about each problem with its line, instead of stopping at the first.")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		log.Fatalf("Usage: project-bundler diff [-u] [-U n] [-path glob] [-lenient] <bundle> [<directory or bundle>]")
+	}
+	if *contextLines < 0 {
+		log.Fatalf("-U must not be negative.")
+	}
+	style, ok := bundler.Styles[*styleName]
+	if !ok {
+		log.Fatalf("Invalid style '%s'. Available styles are: %s", *styleName, strings.Join(bundler.StyleNames(), ", "))
+	}
+
+	old, err := readBundleFiles(fs.Arg(0), style, *lenient)
+	if err != nil {
+		log.Fatalf("Could not read bundle '%s': %v", fs.Arg(0), err)
+	}
+	target := "."
+	if fs.NArg() == 2 {
+		target = fs.Arg(1)
+	}
+	var cur map[string][]byte
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		cur, err = readTreeFiles(target, *projectType, fs.Arg(0))
+		if err != nil {
+			log.Fatalf("Could not read '%s': %v", target, err)
+		}
+	} else if cur, err = readBundleFiles(target, style, *lenient); err != nil {
+		log.Fatalf("Could not read bundle '%s': %v", target, err)
+	}
+
+	paths := bundler.RuleSet{*pathGlob: "-path"}
+	var all []string
+	for p := range old {
+		all = append(all, p)
+	}
+	for p := range cur {
+		if _, ok := old[p]; !ok {
+			all = append(all, p)
+		}
+	}
+	slices.Sort(all)
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	var added, removed, changed, condensed int
+	for _, p := range all {
+		if *pathGlob != "" {
+			if _, ok := paths.MatchingGlob(filepath.FromSlash(p)); !ok {
+				continue
+			}
+		}
+		a, inOld := old[p]
+		b, inCur := cur[p]
+		var status string
+		switch {
+		case inOld && a == nil, inCur && b == nil:
+			condensed++
+			continue
+		case !inCur:
+			status, removed = "D", removed+1
+		case !inOld:
+			status, added = "A", added+1
+		case !bytes.Equal(a, b):
+			status, changed = "M", changed+1
+		default:
+			continue
+		}
+		if !*unified {
+			fmt.Fprintf(w, "%s  %s\n", status, p)
+			continue
+		}
+		from, to := "a/"+p, "b/"+p
+		if !inOld {
+			from = "/dev/null"
+		}
+		if !inCur {
+			to = "/dev/null"
+		}
+		fmt.Fprintf(w, "diff %s %s\n", "a/"+p, "b/"+p)
+		writeUnifiedDiff(w, from, to, splitLines(a), splitLines(b), *contextLines)
+	}
+	w.Flush()
+	if condensed > 0 {
+		fmt.Fprintf(os.Stderr, "%d files bundled in condensed form (stubs, API-only, truncated, ...) were not compared.\n", condensed)
+	}
+	if added+removed+changed > 0 {
+		fmt.Fprintf(os.Stderr, "%d added, %d removed, %d changed.\n", added, removed, changed)
+		os.Exit(1)
+	}
+}
+
+// readBundleFiles returns the content of each file in a bundle (or journal)
+// by its slash-separated path. Files whose block does not hold their real
+// content, such as duplicate stubs and API-only or truncated blocks, map to
+// nil. lenient recovers what it can from a malformed bundle.
+func readBundleFiles(path string, style bundler.Style, lenient bool) (map[string][]byte, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	state, err := replayJournal(path, style, &bundler.ParseOptions{Lenient: lenient})
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+	for _, b := range state.live() {
+		p := strings.TrimPrefix(b.Path, "/")
+		content := b.Content
+		stub := bytes.TrimSpace(content)
+		switch {
+		case condensedNote(b.Annotation) != "", elidedRE.Match(content), duplicateStubRE.Match(stub),
+			stubKind(content) != "", binaryEmbedRE.Match(content):
+			content = nil
+		default:
+			content = withFinalNewline(content)
+		}
+		files[p] = content
+	}
+	return files, nil
+}
+
+// readTreeFiles returns the content of each file of dir that a bundle of it
+// would hold, decoded as it would be bundled, by its slash-separated path.
+// The bundle being compared and its sidecars are left out.
+func readTreeFiles(dir, projectType, bundlePath string) (map[string][]byte, error) {
+	opts, err := resolveOptions(dir, projectType, "", "", false)
+	if err != nil {
+		return nil, err
+	}
+	entries, _, err := collectFiles(opts)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte, len(entries))
+	for _, f := range entries {
+		if isOwnOutput(opts, bundlePath, f.Path) {
+			continue
+		}
+		p := filepath.ToSlash(f.RelPath)
+		if f.Placeholder || f.Minified || f.Binary || f.DuplicateOf != "" {
+			files[p] = nil
+			continue
+		}
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		content, _ = bundler.Transcode(content, f.Charset, f.EOL)
+		files[p] = withFinalNewline(content)
+	}
+	return files, nil
+}
+
+// withFinalNewline adds the final newline models often drop, so its absence
+// does not count as a change.
+func withFinalNewline(content []byte) []byte {
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		return append(content[:len(content):len(content)], '\n')
+	}
+	return content
+}
+
+// splitLines splits content into lines without their newlines.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+// diffOp is one line of an edit script: kept (' '), removed ('-') or added
+// ('+'), with its index in the old and the new lines.
+type diffOp struct {
+	kind byte
+	a, b int
+}
+
+// diffLines computes the shortest edit script turning a into b with Myers'
+// O(ND) algorithm.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	limit := n + m
+	off := limit + 1
+	v := make([]int, 2*limit+3)
+	// trace[d] holds the furthest x on each diagonal k in [-d-1, d+1] before
+	// round d, at index k+d+1.
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, slices.Clone(v[off-d-1:off+d+2]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[off+k-1] < v[off+k+1] {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(trace, n, m)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrackDiff walks the rounds of diffLines back from the end of both
+// inputs to recover the edit script.
+func backtrackDiff(trace [][]int, x, y int) []diffOp {
+	var ops []diffOp
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && at(k-1) < at(k+1) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			ops = append(ops, diffOp{' ', x, y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{'+', x, y})
+		} else {
+			x--
+			ops = append(ops, diffOp{'-', x, y})
+		}
+	}
+	slices.Reverse(ops)
+	return ops
+}
+
+// writeUnifiedDiff writes the differences between the lines of a and b in
+// the unified format of diff -u, with context lines around each change.
+func writeUnifiedDiff(w io.Writer, from, to string, a, b []string, context int) {
+	ops := diffLines(a, b)
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", from, to)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// A hunk runs from context lines before this change to context lines
+		// after the last change that is not separated from it by more than
+		// twice the context.
+		start := max(0, i-context)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		end = min(len(ops), end+context)
+		hunk := ops[start:end]
+		var aLen, bLen int
+		for _, op := range hunk {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(hunk[0].a, aLen), hunkRange(hunk[0].b, bLen))
+		for _, op := range hunk {
+			var line string
+			if op.kind == '+' {
+				line = b[op.b]
+			} else {
+				line = a[op.a]
+			}
+			fmt.Fprintf(w, "%c%s\n", op.kind, line)
+		}
+		i = end
+	}
+}
+
+// hunkRange formats the start and length of one side of a hunk. An empty
+// side starts at the line before it, as in diff -u.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
@@ -0,0 +1,218 @@
+// project-bundler/check.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// checkBaselineFile is where `check -update-baseline` records token counts.
+const checkBaselineFile = ".project-bundler-baseline.json"
+
+// isCheckBaseline reports whether path is a baseline file `check` wrote into
+// the source tree, which neither bundles nor token counts include.
+func isCheckBaseline(path string) bool {
+	return filepath.Base(path) == checkBaselineFile
+}
+
+// checkTopGrowth is how many grown directories the report lists.
+const checkTopGrowth = 10
+
+// tokenBaseline is the recorded state a later `check` run is compared with.
+type tokenBaseline struct {
+	Generated time.Time      `json:"generated"`
+	Tokenizer string         `json:"tokenizer"`
+	Total     int            `json:"total"`
+	Dirs      map[string]int `json:"dirs"`
+}
+
+// runCheck implements the `check` subcommand: a CI gate that fails when the
+// canonical bundle exceeds a token budget and shows which directories grew
+// since the recorded baseline.
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	srcDir := fs.String("src", ".", "Source project directory.")
+	projectType := fs.String("type", "auto", "Project type. Options: "+strings.Join(availableProjectTypes(), ", "))
+	ignoreDirsStr := fs.String("ignore-dirs", "", "Comma-separated list of directories to ignore. Overrides the project type's default.")
+	ignoreExtsStr := fs.String("ignore-exts", "", "Comma-separated list of file extensions to ignore. Overrides the project type's default.")
+	noDefaultIgnores := fs.Bool("no-default-ignores", false, "Do not ignore the common junk directories shared by all presets.")
+	budgetStr := fs.String("budget", "", "Maximum tokens the bundle may use, e.g. 200k or 1.5m. Required.")
+	model := fs.String("model", "gpt-4o", "Target model or tokenizer used to count tokens.")
+	depth := fs.Int("depth", 1, "Directory depth at which growth is reported.")
+	baselinePath := fs.String("baseline", "", "Baseline file (default: "+checkBaselineFile+" in -src).")
+	updateBaseline := fs.Bool("update-baseline", false, "Record the current counts as the new baseline.")
+	fs.BoolVar(&plainOutput, "plain", plainOutput, "Use plain text status markers instead of symbols.")
+	fs.Parse(args)
+
+	budget, err := parseTokenCount(*budgetStr)
+	if err != nil || budget <= 0 {
+		log.Fatalf("Invalid or missing -budget %q: use a token count such as 200k", *budgetStr)
+	}
+	tok, err := tokenizerForModel(*model)
+	if err != nil {
+		log.Fatalf("Invalid -model: %v", err)
+	}
+	if *baselinePath == "" {
+		*baselinePath = filepath.Join(*srcDir, checkBaselineFile)
+	}
+
+	opts, err := resolveOptions(*srcDir, *projectType, *ignoreDirsStr, *ignoreExtsStr, *noDefaultIgnores)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	current, err := measureTokens(opts, tok, *depth)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	fmt.Printf("Bundle: %d tokens of a %d token budget (%.0f%%, %s tokenizer)\n", current.Total, budget, 100*float64(current.Total)/float64(budget), tok.name())
+
+	previous, err := loadBaseline(*baselinePath)
+	if err != nil {
+		log.Fatalf("Could not read baseline: %v", err)
+	}
+	if previous != nil {
+		printGrowth(previous, current)
+	}
+
+	if *updateBaseline {
+		if err := saveBaseline(*baselinePath, current); err != nil {
+			log.Fatalf("Could not write baseline: %v", err)
+		}
+		fmt.Printf("Recorded baseline in '%s'\n", *baselinePath)
+	}
+
+	if current.Total > budget {
+		fmt.Print(plainText(fmt.Sprintf("✖ Over budget by %d tokens.\n", current.Total-budget)))
+		os.Exit(1)
+	}
+	fmt.Println(plainText("✔ Within budget."))
+}
+
+// measureTokens renders every file the way the default bundle would and
+// counts its tokens, grouped by directory prefix of the given depth.
+func measureTokens(opts bundleOptions, tok tokenizer, depth int) (*tokenBaseline, error) {
+	files, _, err := collectFiles(opts)
+	if err != nil {
+		return nil, fmt.Errorf("error during directory walk: %w", err)
+	}
+	b := &tokenBaseline{Generated: time.Now().UTC(), Tokenizer: tok.name(), Dirs: make(map[string]int)}
+	for _, f := range files {
+		if isCheckBaseline(f.Path) {
+			continue
+		}
+		content, err := os.ReadFile(f.Path)
+		if f.DuplicateOf != "" {
+			content, err = opts.Style.DuplicateStub(f.DuplicateOf), nil
+		}
+		if err != nil {
+			continue // The bundle would skip it too.
+		}
+		w := &tokenCountingWriter{tok: tok}
+		if err := opts.Style.WriteFile(w, f.RelPath, f.Lang, "", content); err != nil {
+			return nil, err
+		}
+		n := w.total()
+		b.Total += n
+		b.Dirs[dirAtDepth(f.RelPath, depth)] += n
+	}
+	return b, nil
+}
+
+// dirAtDepth truncates a file's directory to at most depth components;
+// files at the root are grouped under ".".
+func dirAtDepth(relPath string, depth int) string {
+	parts := strings.Split(filepath.ToSlash(filepath.Dir(relPath)), "/")
+	if parts[0] == "." {
+		return "."
+	}
+	if len(parts) > depth {
+		parts = parts[:depth]
+	}
+	return strings.Join(parts, "/")
+}
+
+// printGrowth lists the directories that grew most since the baseline.
+func printGrowth(previous, current *tokenBaseline) {
+	if previous.Tokenizer != current.Tokenizer {
+		fmt.Printf("Baseline was counted with the %s tokenizer; growth figures are approximate.\n", previous.Tokenizer)
+	}
+	type growth struct {
+		dir        string
+		before, by int
+	}
+	var grown []growth
+	for dir, n := range current.Dirs {
+		if by := n - previous.Dirs[dir]; by > 0 {
+			grown = append(grown, growth{dir, previous.Dirs[dir], by})
+		}
+	}
+	sort.Slice(grown, func(i, j int) bool {
+		if grown[i].by != grown[j].by {
+			return grown[i].by > grown[j].by
+		}
+		return grown[i].dir < grown[j].dir
+	})
+
+	fmt.Printf("Since baseline of %s: %+d tokens (%d -> %d)\n", previous.Generated.Format("2006-01-02"), current.Total-previous.Total, previous.Total, current.Total)
+	if len(grown) > checkTopGrowth {
+		grown = grown[:checkTopGrowth]
+	}
+	for _, g := range grown {
+		label := "/" + g.dir
+		if g.dir == "." {
+			label = "/ (root files)"
+		}
+		fmt.Printf("  %-30s %+8d tokens (was %d)\n", label, g.by, g.before)
+	}
+}
+
+func loadBaseline(path string) (*tokenBaseline, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var b tokenBaseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &b, nil
+}
+
+func saveBaseline(path string, b *tokenBaseline) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// parseTokenCount parses counts such as "200000", "200k" or "1.5m".
+func parseTokenCount(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	factor := 1.0
+	switch {
+	case strings.HasSuffix(s, "k"):
+		s, factor = strings.TrimSuffix(s, "k"), 1e3
+	case strings.HasSuffix(s, "m"):
+		s, factor = strings.TrimSuffix(s, "m"), 1e6
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid token count %q", s)
+	}
+	return int(n * factor), nil
+}
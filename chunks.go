@@ -0,0 +1,126 @@
+// project-bundler/chunks.go
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// chunkIDLength is the number of hex digits kept from the chunk hash; 48 bits
+// keep collisions out of reach for any realistic project while staying short
+// enough for a model to copy reliably.
+const chunkIDLength = 12
+
+// chunkRef locates a chunk in the project, by original (untransformed) lines.
+type chunkRef struct {
+	Path  string `json:"path"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// chunkID derives a stable ID from a file's path and original content, so an
+// unchanged file keeps its ID across regenerated bundles.
+func chunkID(relPath string, content []byte) string {
+	h := sha256.New()
+	h.Write([]byte(filepath.ToSlash(relPath)))
+	h.Write([]byte{0})
+	h.Write(content)
+	return "c" + hex.EncodeToString(h.Sum(nil))[:chunkIDLength]
+}
+
+// lineCount counts the lines of content; a final line without a newline
+// still counts.
+func lineCount(content []byte) int {
+	n := bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// addChunk records a file as one chunk in the manifest and returns the line
+// emitted above its block.
+func (m *bundleManifest) addChunk(relPath string, content []byte) string {
+	if m.Chunks == nil {
+		m.Chunks = make(map[string]chunkRef)
+	}
+	id := chunkID(relPath, content)
+	ref := chunkRef{Path: filepath.ToSlash(relPath), Start: 1, End: max(lineCount(content), 1)}
+	m.Chunks[id] = ref
+	return fmt.Sprintf("Chunk %s (lines %d-%d)\n", id, ref.Start, ref.End)
+}
+
+// runResolve implements the `resolve` subcommand, which translates chunk
+// citations and bundle line numbers back to file locations.
+//
+//	project-bundler resolve -manifest bundle.manifest.json c1f3a9e07b2d4:12
+//	project-bundler resolve -source-map bundle.sourcemap.json 10542
+func runResolve(args []string) {
+	fs := flag.NewFlagSet("resolve", flag.ExitOnError)
+	manifestPath := fs.String("manifest", "bundle.manifest.json", "Manifest written next to the bundle by -chunk-ids, for chunk citations.")
+	sourceMapPath := fs.String("source-map", "bundle.sourcemap.json", "Source map written next to the bundle by -source-map, for bundle line numbers.")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		log.Fatalf("Usage: project-bundler resolve [-manifest file] [-source-map file] <chunk-id>[:line]|<bundle-line>...")
+	}
+
+	var manifest *bundleManifest
+	var lines *sourceMap
+	failed := false
+	for _, citation := range fs.Args() {
+		if line, err := strconv.ParseInt(strings.ReplaceAll(citation, ",", ""), 10, 64); err == nil {
+			if lines == nil {
+				if lines, err = loadSourceMap(*sourceMapPath); err != nil {
+					log.Fatalf("%v; bundle with -source-map first.", err)
+				}
+			}
+			location, err := lines.lookup(line)
+			if err != nil {
+				fmt.Printf("%s: %v\n", citation, err)
+				failed = true
+				continue
+			}
+			fmt.Println(location)
+			continue
+		}
+
+		if manifest == nil {
+			m, err := loadManifest(*manifestPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if m == nil || len(m.Chunks) == 0 {
+				log.Fatalf("No chunk IDs in '%s'; bundle with -chunk-ids first.", *manifestPath)
+			}
+			manifest = m
+		}
+		id, lineStr, hasLine := strings.Cut(citation, ":")
+		ref, ok := manifest.Chunks[id]
+		if !ok {
+			fmt.Printf("%s: unknown chunk (the file changed or was removed since the bundle)\n", citation)
+			failed = true
+			continue
+		}
+		if !hasLine {
+			fmt.Printf("%s:%d-%d\n", ref.Path, ref.Start, ref.End)
+			continue
+		}
+		line, err := strconv.Atoi(lineStr)
+		if err != nil || line < 1 || ref.Start+line-1 > ref.End {
+			fmt.Printf("%s: line out of range 1-%d\n", citation, ref.End-ref.Start+1)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s:%d\n", ref.Path, ref.Start+line-1)
+	}
+	if failed {
+		log.Fatal("Some citations could not be resolved.")
+	}
+}
@@ -0,0 +1,85 @@
+// project-bundler/classify.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// classLevel is a file's data classification, from least to most sensitive.
+type classLevel int
+
+const (
+	classPublic classLevel = iota
+	classInternal
+	classSensitive
+)
+
+var classNames = [...]string{"public", "internal", "sensitive"}
+
+func (l classLevel) String() string { return classNames[l] }
+
+// parseClassLevel reads a classification name.
+func parseClassLevel(s string) (classLevel, error) {
+	for i, name := range classNames {
+		if strings.EqualFold(s, name) {
+			return classLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown classification '%s'; use %s", s, strings.Join(classNames[:], ", "))
+}
+
+// classRule classifies the files matching a glob, from the local config's
+// classification map, e.g. classification: {"docs/**": public}.
+type classRule struct {
+	rule  ruleSet // Holds just the glob, for MatchingGlob.
+	level classLevel
+}
+
+// parseClassRules reads the local config's classification map, in glob
+// order.
+func parseClassRules(rules map[string]string, file string) ([]classRule, error) {
+	globs := make([]string, 0, len(rules))
+	for glob := range rules {
+		globs = append(globs, glob)
+	}
+	sort.Strings(globs)
+	var parsed []classRule
+	for _, glob := range globs {
+		level, err := parseClassLevel(rules[glob])
+		if err != nil {
+			return nil, fmt.Errorf("%s: classification of '%s': %w", file, glob, err)
+		}
+		rule := make(ruleSet)
+		rule.Add([]string{glob}, file)
+		parsed = append(parsed, classRule{rule: rule, level: level})
+	}
+	return parsed, nil
+}
+
+// classifyFile labels a file: files on the secret list (bundled with
+// -unsafe-include-secrets) and files the secret scan finds credentials in
+// are sensitive; other files take the highest level of the classification
+// rules matching their path, and are internal when none does. Findings that
+// -redact-secrets removes from the bundle do not count.
+func classifyFile(opts bundleOptions, f fileEntry, content []byte) classLevel {
+	if bundler.SecretRule(f.RelPath, func() []byte { return content }) != "" {
+		return classSensitive
+	}
+	if !opts.redactSecrets && len(bundler.ScanSecrets(f.RelPath, content)) > 0 {
+		return classSensitive
+	}
+	level, matched := classPublic, false
+	for _, r := range opts.classRules {
+		if _, ok := r.rule.MatchingGlob(f.RelPath); ok {
+			level, matched = max(level, r.level), true
+		}
+	}
+	if !matched {
+		return classInternal
+	}
+	return level
+}
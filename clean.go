@@ -0,0 +1,185 @@
+// project-bundler/clean.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// digitRunRE matches the parts of a bundle name that change from run to
+// run in a pipeline: dates, times, build numbers and versions.
+var digitRunRE = regexp.MustCompile(`[0-9]+`)
+
+// releaseTagRE matches a release tag in a bundle name, such as v1.4.0.
+var releaseTagRE = regexp.MustCompile(`(?:^|[^A-Za-z0-9])v[0-9]+(?:\.[0-9]+)+`)
+
+// cleanBundle is a bundle found by `clean`, with the files written along
+// with it.
+type cleanBundle struct {
+	path     string
+	modTime  time.Time
+	size     int64
+	sidecars []string
+}
+
+// runClean implements the `clean` subcommand, which applies a retention
+// policy to a directory that pipelines fill with bundles under changing
+// names, e.g. -output "{module}-$BUILD_ID.md". Bundles whose names differ
+// only in their digits form a series; each series keeps its newest -keep
+// bundles. Sidecars, -format artifacts and -split parts go with their
+// bundle.
+//
+//	project-bundler clean -dir bundles -keep 10 -dry-run
+func runClean(args []string) {
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	dir := fs.String("dir", "", "Directory holding the bundles, searched recursively. Required.")
+	pattern := fs.String("pattern", "*.md", "Glob matching the bundle file names.")
+	keep := fs.Int("keep", 5, "Number of the newest bundles to keep in each series.")
+	olderThan := fs.String("older-than", "", "Only remove bundles last modified longer ago than this, e.g. 30d; empty removes any past -keep.")
+	keepTagged := fs.Bool("keep-tagged", true, "Keep every bundle whose name contains a release tag such as v1.4.0, in addition to -keep per series.")
+	dryRun := fs.Bool("dry-run", false, "List what would be removed without removing it.")
+	fs.Parse(args)
+	if *dir == "" {
+		log.Fatalf("Usage: project-bundler clean -dir <directory> [flags]")
+	}
+	if *keep < 0 {
+		log.Fatalf("-keep must not be negative.")
+	}
+	if _, err := filepath.Match(*pattern, ""); err != nil {
+		log.Fatalf("Invalid -pattern '%s': %v", *pattern, err)
+	}
+	minAge, err := parseAge(*olderThan)
+	if err != nil {
+		log.Fatalf("Invalid -older-than: %v", err)
+	}
+
+	series, err := findBundleSeries(*dir, *pattern)
+	if err != nil {
+		log.Fatalf("Could not read '%s': %v", *dir, err)
+	}
+	now := time.Now()
+	removed, kept := 0, 0
+	var freed int64
+	for _, key := range slices.Sorted(maps.Keys(series)) {
+		bundles := series[key]
+		for i, b := range bundles {
+			switch {
+			case i < *keep,
+				*keepTagged && releaseTagRE.MatchString(filepath.Base(b.path)),
+				minAge > 0 && now.Sub(b.modTime) <= minAge:
+				kept++
+				continue
+			}
+			for _, p := range append([]string{b.path}, b.sidecars...) {
+				if *dryRun {
+					fmt.Printf("  - would remove %s\n", p)
+					continue
+				}
+				if err := os.Remove(p); err != nil {
+					log.Printf("Could not remove %s: %v", p, err)
+					continue
+				}
+				fmt.Printf("  - removed %s\n", p)
+			}
+			removed++
+			freed += b.size
+		}
+	}
+	verb := "Removed"
+	if *dryRun {
+		verb = "Would remove"
+	}
+	fmt.Printf("%s %d bundles (%s) from %d series in '%s'; kept %d.\n", verb, removed, formatSize(freed), len(series), *dir, kept)
+}
+
+// findBundleSeries finds the bundles under dir and groups them by
+// directory and series, newest first. A file matching pattern that was
+// written along with another bundle, such as bundle.changes.md, belongs to
+// that bundle instead.
+func findBundleSeries(dir, pattern string) (map[string][]*cleanBundle, error) {
+	var candidates []string
+	var others []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if ok, _ := filepath.Match(pattern, d.Name()); ok {
+			candidates = append(candidates, p)
+		} else {
+			others = append(others, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A file can only have been written along with a bundle whose path
+	// without extension it starts with, up to one of its dots.
+	formats := availableFormats()
+	byStem := make(map[string][]string)
+	for _, p := range candidates {
+		stem := strings.TrimSuffix(p, filepath.Ext(p))
+		byStem[stem] = append(byStem[stem], p)
+	}
+	owners := func(p string) []string {
+		var found []string
+		for i := len(p) - 1; i >= len(p)-len(filepath.Base(p)); i-- {
+			if p[i] != '.' {
+				continue
+			}
+			for _, q := range byStem[p[:i]] {
+				if q != p && isSidecar(q, p, formats) {
+					found = append(found, q)
+				}
+			}
+		}
+		return found
+	}
+
+	var bundles []*cleanBundle
+	byPath := make(map[string]*cleanBundle)
+	for _, p := range candidates {
+		if len(owners(p)) > 0 {
+			others = append(others, p)
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		b := &cleanBundle{path: p, modTime: info.ModTime(), size: info.Size()}
+		bundles = append(bundles, b)
+		byPath[p] = b
+	}
+	for _, p := range others {
+		for _, q := range owners(p) {
+			if b, ok := byPath[q]; ok {
+				b.sidecars = append(b.sidecars, p)
+				if info, err := os.Stat(p); err == nil {
+					b.size += info.Size()
+				}
+			}
+		}
+	}
+
+	series := make(map[string][]*cleanBundle)
+	for _, b := range bundles {
+		key := filepath.Join(filepath.Dir(b.path), digitRunRE.ReplaceAllString(filepath.Base(b.path), "#"))
+		series[key] = append(series[key], b)
+	}
+	for _, s := range series {
+		sort.SliceStable(s, func(i, j int) bool { return s[i].modTime.After(s[j].modTime) })
+	}
+	return series, nil
+}
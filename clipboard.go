@@ -0,0 +1,53 @@
+// project-bundler/clipboard.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommand returns the command that copies its stdin to the system
+// clipboard: pbcopy on macOS, PowerShell's Set-Clipboard on Windows (clip.exe
+// mangles UTF-8), and wl-copy, xclip or xsel elsewhere, whichever is present.
+func clipboardCommand() (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("pbcopy"), nil
+	case "windows":
+		return exec.Command("powershell", "-NoProfile", "-Command",
+			"[Console]::InputEncoding = [Text.Encoding]::UTF8; Set-Clipboard -Value ([Console]::In.ReadToEnd())"), nil
+	}
+	candidates := [][]string{{"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		candidates = append([][]string{{"wl-copy"}}, candidates...)
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return exec.Command(c[0], c[1:]...), nil
+		}
+	}
+	return nil, errors.New("no clipboard tool found; install wl-clipboard, xclip or xsel")
+}
+
+// copyToClipboard puts data on the system clipboard.
+func copyToClipboard(data []byte) error {
+	cmd, err := clipboardCommand()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %s", cmd.Args[0], msg)
+		}
+		return fmt.Errorf("%s: %w", cmd.Args[0], err)
+	}
+	return nil
+}
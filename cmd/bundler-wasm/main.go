@@ -0,0 +1,105 @@
+//go:build js && wasm
+
+// project-bundler/cmd/bundler-wasm/main.go
+
+// Command bundler-wasm exposes pkg/bundler to JavaScript so browser tools can
+// bundle a dropped folder without a server. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o bundler.wasm ./cmd/bundler-wasm
+//
+// and load it with the wasm_exec.js shipped in $(go env GOROOT)/lib/wasm.
+// It defines
+//
+//	projectBundler.bundle(files, {type: "auto", style: "github"})
+//
+// where files maps slash-separated relative paths to strings or Uint8Arrays.
+// The call returns {bundle, files, skipped}, or {error} when the files cannot
+// be bundled.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall/js"
+	"testing/fstest"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+func main() {
+	js.Global().Set("projectBundler", js.ValueOf(map[string]any{
+		"bundle": js.FuncOf(bundle),
+	}))
+	select {} // Keep the exported function alive.
+}
+
+// bundle is the JavaScript entry point; see the package comment.
+func bundle(this js.Value, args []js.Value) any {
+	if len(args) == 0 || args[0].Type() != js.TypeObject {
+		return failure("bundle expects an object mapping paths to file contents")
+	}
+	fsys := make(fstest.MapFS)
+	keys := js.Global().Get("Object").Call("keys", args[0])
+	for i := 0; i < keys.Length(); i++ {
+		key := keys.Index(i).String()
+		value := args[0].Get(key)
+		var data []byte
+		if value.Type() == js.TypeString {
+			data = []byte(value.String())
+		} else {
+			data = make([]byte, value.Get("length").Int())
+			js.CopyBytesToGo(data, value)
+		}
+		fsys[strings.TrimPrefix(key, "/")] = &fstest.MapFile{Data: data, Mode: 0o644}
+	}
+
+	projectType, style := "auto", "github"
+	if len(args) > 1 && args[1].Type() == js.TypeObject {
+		if v := args[1].Get("type"); v.Type() == js.TypeString {
+			projectType = v.String()
+		}
+		if v := args[1].Get("style"); v.Type() == js.TypeString {
+			style = v.String()
+		}
+	}
+	if projectType == "auto" {
+		projectType, _ = bundler.DetectProjectType(fsys)
+	}
+	opts, err := bundler.NewOptions(projectType)
+	if err != nil {
+		return failure(err.Error())
+	}
+	var ok bool
+	if opts.Style, ok = bundler.Styles[style]; !ok {
+		return failure(fmt.Sprintf("invalid style '%s'. Available styles are: %s", style, strings.Join(bundler.StyleNames(), ", ")))
+	}
+	opts.FS = fsys
+
+	var out bytes.Buffer
+	report, err := bundler.New(opts).Bundle(".", &out)
+	var partial *bundler.PartialBundleError
+	if err != nil && !errors.As(err, &partial) {
+		return failure(err.Error()) // Unread files are listed as skipped.
+	}
+	files := make([]any, len(report.Files))
+	for i, f := range report.Files {
+		files[i] = f
+	}
+	skipped := make(map[string]any)
+	for reason, paths := range report.Skipped {
+		list := make([]any, len(paths))
+		for i, p := range paths {
+			list[i] = p
+		}
+		skipped[reason] = list
+	}
+	return map[string]any{"bundle": out.String(), "files": files, "skipped": skipped}
+}
+
+// failure is the result of a call that could not bundle the files. A Go
+// panic would stop the module, so errors are returned rather than thrown.
+func failure(message string) any {
+	return map[string]any{"error": message}
+}
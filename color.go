@@ -0,0 +1,81 @@
+// project-bundler/color.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// ANSI colors used for console output.
+const (
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorRed    = "\x1b[31m"
+	colorReset  = "\x1b[0m"
+)
+
+// colorOutput enables ANSI colors on stdout; see setColorMode.
+var colorOutput bool
+
+// messageColors colors the catalog messages that summarize a decision.
+var messageColors = map[string]string{
+	"bundling-file":     colorGreen,
+	"success":           colorGreen,
+	"clipboard-copied":  colorGreen,
+	"partial":           colorYellow,
+	"over-token-budget": colorYellow,
+	"secrets-redacted":  colorYellow,
+	"secrets-found":     colorYellow,
+	"skip-reason":       colorYellow,
+}
+
+// setColorMode applies -color. "auto" colors only when writing to a terminal
+// and neither NO_COLOR (https://no-color.org) nor plain output is set.
+// Log messages on stderr are colored red under the same rules.
+func setColorMode(mode string) error {
+	switch mode {
+	case "always":
+		colorOutput = true
+	case "never":
+		colorOutput = false
+	case "auto":
+		colorOutput = os.Getenv("NO_COLOR") == "" && !plainOutput && isTerminal(os.Stdout)
+	default:
+		return fmt.Errorf("invalid -color value '%s'. Use auto, always, or never", mode)
+	}
+	if colorOutput && (mode == "always" || isTerminal(os.Stderr)) {
+		log.SetOutput(colorWriter{w: os.Stderr, color: colorRed})
+	}
+	return nil
+}
+
+// colorize wraps s in the given color when color output is enabled. Leading
+// and trailing newlines stay outside the escape codes.
+func colorize(color, s string) string {
+	if !colorOutput || color == "" {
+		return s
+	}
+	text := strings.Trim(s, "\n")
+	if text == "" {
+		return s
+	}
+	start := strings.Index(s, text)
+	return s[:start] + color + text + colorReset + s[start+len(text):]
+}
+
+// colorWriter colors everything written through it; the log package writes
+// one complete message per call.
+type colorWriter struct {
+	w     io.Writer
+	color string
+}
+
+func (c colorWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(c.w, c.color+string(p)+colorReset); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
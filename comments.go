@@ -0,0 +1,146 @@
+// project-bundler/comments.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// reviewMarker starts an inline review comment. Reviewers (or a model asked to
+// review the bundle) add such lines inside a file block, directly below the
+// line they refer to:
+//
+//	if err != nil {
+//	>>review: this error is swallowed; return it
+//
+// Consecutive comment lines on the same line are joined into one comment.
+const reviewMarker = ">>review:"
+
+// reviewComment is one exported comment, shaped like the draft review
+// comments of the GitHub pull request reviews API.
+type reviewComment struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Side string `json:"side"`
+	Body string `json:"body"`
+}
+
+// runComments implements the `comments` subcommand.
+//
+//	project-bundler comments export -repo owner/name -pr 42 bundle.md
+func runComments(args []string) {
+	if len(args) == 0 || args[0] != "export" {
+		log.Fatalf("Usage: project-bundler comments export -repo owner/name -pr N [flags] <bundle>")
+	}
+	fs := flag.NewFlagSet("comments export", flag.ExitOnError)
+	repo := fs.String("repo", "", "GitHub repository as owner/name.")
+	pr := fs.Int("pr", 0, "Pull request number to post the review on.")
+	commit := fs.String("commit", "", "Commit SHA the comments refer to (default: the pull request's head).")
+	styleName := fs.String("style", "github", "Output style the bundle was written with. Options: "+strings.Join(bundler.StyleNames(), ", "))
+	dryRun := fs.Bool("dry-run", false, "Print the review comments as JSON instead of posting them.")
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		log.Fatalf("Expected exactly one bundle file, got %d", fs.NArg())
+	}
+	style, ok := bundler.Styles[*styleName]
+	if !ok {
+		log.Fatalf("Invalid style '%s'. Available styles are: %s", *styleName, strings.Join(bundler.StyleNames(), ", "))
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Could not read bundle: %v", err)
+	}
+	comments, err := parseReviewComments(f, style)
+	f.Close()
+	if err != nil {
+		log.Fatalf("Could not parse bundle: %v", err)
+	}
+	if len(comments) == 0 {
+		fmt.Println("No review comments found.")
+		return
+	}
+
+	if *dryRun {
+		data, _ := json.MarshalIndent(comments, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	if *repo == "" || *pr <= 0 {
+		log.Fatalf("-repo and -pr are required unless -dry-run is set")
+	}
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		log.Fatalf("Set GITHUB_TOKEN to a token that may comment on %s", *repo)
+	}
+	if err := postReview(*repo, *pr, *commit, token, comments); err != nil {
+		log.Fatalf("Could not post review: %v", err)
+	}
+	fmt.Print(plainText(fmt.Sprintf("✅ Posted %d review comments to %s#%d\n", len(comments), *repo, *pr)))
+}
+
+// parseReviewComments reads a bundle written with the given style and
+// returns its inline review comments with the file path and the line number
+// within the original file.
+func parseReviewComments(r io.Reader, style bundler.Style) ([]reviewComment, error) {
+	blocks, err := style.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	var comments []reviewComment
+	for _, block := range blocks {
+		lineNo := 0
+		for _, line := range strings.Split(string(block.Content), "\n") {
+			body, ok := strings.CutPrefix(strings.TrimSpace(line), reviewMarker)
+			if !ok {
+				lineNo++
+				continue
+			}
+			body = strings.TrimSpace(body)
+			at := max(lineNo, 1)
+			if n := len(comments); n > 0 && comments[n-1].Path == block.Path && comments[n-1].Line == at {
+				comments[n-1].Body += "\n" + body
+			} else {
+				comments = append(comments, reviewComment{Path: block.Path, Line: at, Side: "RIGHT", Body: body})
+			}
+		}
+	}
+	return comments, nil
+}
+
+// postReview submits the comments as a single pull request review.
+func postReview(repo string, pr int, commit, token string, comments []reviewComment) error {
+	payload := map[string]any{"event": "COMMENT", "comments": comments}
+	if commit != "" {
+		payload["commit_id"] = commit
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://api.github.com/repos/%s/pulls/%d/reviews", repo, pr), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("GitHub pull request reviews API returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// project-bundler/configkeys.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kinds of configuration inputs reported by the key inventory.
+const (
+	keyEnv    = "Environment variable"
+	keyConfig = "Config key"
+	keyFlag   = "Feature flag"
+)
+
+// keyInventoryMaxLocations limits how many usage sites are listed per key.
+const keyInventoryMaxLocations = 3
+
+// keyPattern finds one kind of configuration lookup. The first non-empty
+// capture group is the key.
+type keyPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// flagCallPattern covers the evaluation calls of the common feature flag SDKs
+// (LaunchDarkly, Unleash, GrowthBook, Flagsmith, OpenFeature).
+var flagCallPattern = regexp.MustCompile(`\b(?:[iI]sEnabled|[iI]sFeatureEnabled|[bB]oolVariation|[sS]tringVariation|[vV]ariation|[gG]etFeatureValue|[gG]etBooleanValue|[hH]asFeature)\(\s*["'` + "`" + `]([\w.:-]+)["'` + "`" + `]`)
+
+// keyPatterns are keyed by language identifier.
+var keyPatterns = map[string][]keyPattern{
+	"go": {
+		{keyEnv, regexp.MustCompile(`os\.(?:Getenv|LookupEnv)\(\s*"([^"]+)"`)},
+		{keyConfig, regexp.MustCompile(`viper\.(?:Get\w*|IsSet|SetDefault|BindEnv)\(\s*"([^"]+)"`)},
+		{keyFlag, flagCallPattern},
+	},
+	"javascript": {
+		{keyEnv, regexp.MustCompile(`process\.env\.([A-Za-z_]\w*)|process\.env\[\s*['"]([^'"]+)['"]`)},
+		{keyConfig, regexp.MustCompile(`\bconfig\.(?:get|has)\(\s*['"]([^'"]+)['"]`)},
+		{keyFlag, flagCallPattern},
+	},
+	"python": {
+		{keyEnv, regexp.MustCompile(`os\.(?:environ\.get|getenv)\(\s*['"]([^'"]+)['"]|os\.environ\[\s*['"]([^'"]+)['"]`)},
+		{keyConfig, regexp.MustCompile(`\b(?:config|settings)\.get\(\s*['"]([^'"]+)['"]`)},
+		{keyFlag, flagCallPattern},
+	},
+	"java": {
+		{keyEnv, regexp.MustCompile(`System\.getenv\(\s*"([^"]+)"`)},
+		{keyConfig, regexp.MustCompile(`@Value\(\s*"\$\{([^}:]+)|\.getProperty\(\s*"([^"]+)"`)},
+		{keyFlag, flagCallPattern},
+	},
+	"rust": {
+		{keyEnv, regexp.MustCompile(`env::var(?:_os)?\(\s*"([^"]+)"|env!\(\s*"([^"]+)"`)},
+	},
+	"dart": {
+		{keyEnv, regexp.MustCompile(`Platform\.environment\[\s*'([^']+)'|String\.fromEnvironment\(\s*'([^']+)'`)},
+	},
+	"swift": {
+		{keyEnv, regexp.MustCompile(`environment\[\s*"([^"]+)"\]`)},
+	},
+}
+
+func init() {
+	keyPatterns["typescript"] = keyPatterns["javascript"]
+	keyPatterns["kotlin"] = keyPatterns["java"]
+}
+
+// keyUsage is one configuration key with every place it is read.
+type keyUsage struct {
+	kind      string
+	key       string
+	locations []string
+}
+
+// findConfigKeys scans the bundled files for configuration lookups and
+// returns them grouped by kind and key.
+func findConfigKeys(files []fileEntry) []*keyUsage {
+	byKey := make(map[string]*keyUsage)
+	for _, f := range files {
+		patterns := keyPatterns[f.Lang]
+		if len(patterns) == 0 || f.Placeholder {
+			continue
+		}
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			continue // Reported when the file itself is bundled.
+		}
+		for _, p := range patterns {
+			for _, m := range p.re.FindAllSubmatchIndex(content, -1) {
+				key := firstGroup(content, m)
+				if key == "" {
+					continue
+				}
+				id := p.kind + "\x00" + key
+				u, ok := byKey[id]
+				if !ok {
+					u = &keyUsage{kind: p.kind, key: key}
+					byKey[id] = u
+				}
+				u.locations = append(u.locations, fmt.Sprintf("%s:%d", filepath.ToSlash(f.RelPath), lineAt(content, m[0])))
+			}
+		}
+	}
+
+	usages := make([]*keyUsage, 0, len(byKey))
+	for _, u := range byKey {
+		usages = append(usages, u)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].kind != usages[j].kind {
+			return usages[i].kind < usages[j].kind
+		}
+		return usages[i].key < usages[j].key
+	})
+	return usages
+}
+
+// firstGroup returns the first non-empty capture group of a match.
+func firstGroup(content []byte, m []int) string {
+	for g := 1; 2*g+1 < len(m); g++ {
+		if m[2*g] >= 0 && m[2*g+1] > m[2*g] {
+			return string(content[m[2*g]:m[2*g+1]])
+		}
+	}
+	return ""
+}
+
+// formatLocations lists the first few usage sites and counts the rest.
+func formatLocations(locations []string) string {
+	shown := locations
+	if len(shown) > keyInventoryMaxLocations {
+		shown = shown[:keyInventoryMaxLocations]
+	}
+	s := "/" + strings.Join(shown, ", /")
+	if extra := len(locations) - len(shown); extra > 0 {
+		s += fmt.Sprintf(" (+%d more)", extra)
+	}
+	return s
+}
+
+// writeConfigKeyAppendix emits the inventory of environment variables, config
+// keys and feature flags as an appendix after the files.
+func writeConfigKeyAppendix(w io.Writer, files []fileEntry) error {
+	usages := findConfigKeys(files)
+	if len(usages) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Appendix: configuration inputs (%d keys):\n\n| Kind | Key | Read at |\n|---|---|---|\n", len(usages))
+	for _, u := range usages {
+		fmt.Fprintf(&b, "| %s | `%s` | %s |\n", u.kind, u.key, formatLocations(u.locations))
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,40 @@
+// project-bundler/confirm.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// confirmLargeBundle estimates the bundle's size with a walk that reads no
+// file contents and, when it exceeds threshold, asks on a terminal whether
+// to go on. Without a terminal it refuses unless yes (-yes) is set, so an
+// unattended run cannot write a runaway bundle.
+func confirmLargeBundle(opts bundleOptions, threshold int64, yes bool) error {
+	// Walk quietly: -verbose decisions and warnings come from the real walk.
+	files, _, err := bundler.Collect(opts.Options)
+	if err != nil {
+		return nil // The real walk reports the error.
+	}
+	var estimate int64
+	for _, f := range files {
+		estimate += f.Size + int64(len(f.RelPath)) + 32 // As the watchdog estimates a block.
+	}
+	if estimate <= threshold || yes {
+		return nil
+	}
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return fmt.Errorf("the bundle would be about %s from %d files, over the -confirm-size threshold of %s; pass -yes to write it anyway", formatSize(estimate), len(files), formatSize(threshold))
+	}
+	fmt.Printf("The bundle will be about %s from %d files (over %s). Write it? [y/N] ", formatSize(estimate), len(files), formatSize(threshold))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return fmt.Errorf("not writing the bundle; narrow it with -include/-exclude or `project-bundler advise`")
+	}
+	return nil
+}
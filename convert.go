@@ -0,0 +1,77 @@
+// project-bundler/convert.go
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// fileConverter bundles a text rendering of files in place of their bytes,
+// for formats such as documents and spreadsheets.
+type fileConverter struct {
+	handles func(name string) bool
+	// convert returns the text to bundle and the annotation shown above it.
+	// Nil text without an error keeps the file as it is; an error skips it
+	// as binary content.
+	convert func(name string) (text []byte, note string, err error)
+}
+
+// convertFiles applies c to the files it handles, including those the walk
+// skipped as binary content, which are moved back into the bundle when they
+// convert. The text is added to contents and the annotation to notes, both
+// keyed by fileEntry.Path.
+func convertFiles(opts bundleOptions, files []fileEntry, skipped map[string][]string, c fileConverter, contents map[string][]byte, notes map[string]string) []fileEntry {
+	apply := func(f *fileEntry) bool {
+		text, note, err := c.convert(f.Path)
+		if err != nil {
+			log.Printf("Skipping %s: %v", f.Path, err)
+			return false
+		}
+		if text != nil {
+			text = append(bytes.TrimRight(text, "\r\n\t "), '\n')
+			contents[f.Path] = text
+			notes[f.Path] = note
+			f.Lang = "text"
+			f.Size = int64(len(text))
+		}
+		return true
+	}
+
+	var kept []fileEntry
+	for _, f := range files {
+		if _, done := contents[f.Path]; !done && c.handles(f.Path) && !apply(&f) {
+			skipped[bundler.ReasonBinary] = append(skipped[bundler.ReasonBinary], f.Path)
+			continue
+		}
+		kept = append(kept, f)
+	}
+
+	var stillSkipped []string
+	for _, p := range skipped[bundler.ReasonBinary] {
+		info, err := os.Stat(p)
+		if _, done := contents[p]; done || err != nil || !c.handles(p) {
+			stillSkipped = append(stillSkipped, p)
+			continue
+		}
+		rel, err := filepath.Rel(opts.SrcDir, p)
+		if err != nil {
+			rel = p
+		}
+		f := fileEntry{Path: p, RelPath: rel, Mode: info.Mode().Perm(), ModTime: info.ModTime()}
+		if !apply(&f) || contents[p] == nil {
+			stillSkipped = append(stillSkipped, p)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	if len(stillSkipped) > 0 {
+		skipped[bundler.ReasonBinary] = stillSkipped
+	} else {
+		delete(skipped, bundler.ReasonBinary)
+	}
+	return kept
+}
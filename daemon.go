@@ -0,0 +1,469 @@
+// project-bundler/daemon.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// The daemon speaks JSON-RPC 2.0 over a unix socket, one JSON value per
+// message in each direction. Editor plugins keep a connection open and get
+// answers from the warm file list and content cache instead of paying for a
+// process start and a full walk per request.
+
+// rpcRequest is a JSON-RPC 2.0 request; requests without an id are
+// notifications and get no response.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcMessage is a response or a server notification.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// Standard JSON-RPC error codes.
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+// fileStamp identifies a version of a file for change detection.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// cachedBlock is a file rendered as a bundle block.
+type cachedBlock struct {
+	stamp  fileStamp
+	block  []byte
+	tokens int // Estimated with the daemon's -model.
+}
+
+// rpcConn is one client connection; writes are serialized because watch
+// notifications are sent from the polling goroutine.
+type rpcConn struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (c *rpcConn) send(m rpcMessage) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m.JSONRPC = "2.0"
+	return c.enc.Encode(m)
+}
+
+// bundleDaemon holds the warm state of one source tree.
+type bundleDaemon struct {
+	opts bundleOptions
+	tok  tokenizer
+
+	mu       sync.Mutex
+	files    map[string]fileEntry // Keyed by slash-separated relative path.
+	stamps   map[string]fileStamp
+	skipped  map[string]string // Slash-separated relative path -> skip reason code.
+	blocks   map[string]cachedBlock
+	watchers map[*rpcConn]bool
+}
+
+// runDaemon implements the `daemon` subcommand.
+func runDaemon(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	srcDir := fs.String("src", ".", "Source project directory.")
+	projectType := fs.String("type", "auto", "Project type. Options: "+strings.Join(availableProjectTypes(), ", "))
+	ignoreDirsStr := fs.String("ignore-dirs", "", "Comma-separated list of directories to ignore. Overrides the project type's default.")
+	ignoreExtsStr := fs.String("ignore-exts", "", "Comma-separated list of file extensions to ignore. Overrides the project type's default.")
+	noDefaultIgnores := fs.Bool("no-default-ignores", false, "Do not ignore the common junk directories shared by all presets.")
+	socket := fs.String("socket", defaultDaemonSocket(), "Unix socket to listen on. Defaults to project-bundler.sock in $XDG_RUNTIME_DIR, or else daemon.sock in the project-bundler directory of the user cache directory.")
+	poll := fs.Duration("poll", 2*time.Second, "How often to rescan the tree for changes.")
+	model := fs.String("model", "cl100k", "Target model or tokenizer ("+strings.Join(availableTokenizers(), ", ")+") used for the token counts of cached blocks.")
+	fs.Parse(args)
+
+	opts, err := resolveOptions(*srcDir, *projectType, *ignoreDirsStr, *ignoreExtsStr, *noDefaultIgnores)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	opts.Cache = bundler.NewWalkCache() // Each rescan reuses what the previous one read.
+	tok, err := tokenizerForModel(*model)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	d := &bundleDaemon{opts: opts, tok: tok, blocks: make(map[string]cachedBlock), watchers: make(map[*rpcConn]bool)}
+	if _, err := d.rescan(); err != nil {
+		log.Fatalf("Error during directory walk: %v", err)
+	}
+
+	listener, err := listenUnix(*socket)
+	if err != nil {
+		log.Fatalf("Could not listen on '%s': %v", *socket, err)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		listener.Close() // Also removes the socket file.
+	}()
+	go d.pollChanges(*poll)
+
+	fmt.Printf("Serving '%s' (%d files) on %s\n", opts.SrcDir, len(d.files), *socket)
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			log.Printf("Accept failed: %v", err)
+			continue
+		}
+		go d.serve(conn)
+	}
+}
+
+// defaultDaemonSocket is where the daemon listens and warm connects unless
+// -socket says otherwise: in the user's runtime directory, or else in the
+// user's cache directory, so users of a shared machine do not share one.
+func defaultDaemonSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "project-bundler.sock")
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "project-bundler", "daemon.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("project-bundler-%d.sock", os.Getuid()))
+}
+
+// rescan walks the tree again and returns the paths that were added,
+// removed or modified since the previous scan. Cached blocks of changed
+// files are dropped.
+func (d *bundleDaemon) rescan() (map[string][]string, error) {
+	files, skipped, err := collectFiles(d.opts)
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]fileEntry, len(files))
+	stamps := make(map[string]fileStamp, len(files))
+	for _, f := range files {
+		rel := filepath.ToSlash(f.RelPath)
+		byPath[rel] = f
+		stamps[rel] = fileStamp{size: f.Size, modTime: f.ModTime}
+	}
+	reasons := make(map[string]string)
+	for reason, paths := range skipped {
+		for _, p := range paths {
+			if rel, err := filepath.Rel(d.opts.SrcDir, p); err == nil {
+				reasons[filepath.ToSlash(rel)] = reason
+			}
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	changes := make(map[string][]string)
+	for rel, stamp := range stamps {
+		switch old, ok := d.stamps[rel]; {
+		case !ok:
+			changes["added"] = append(changes["added"], rel)
+		case old != stamp:
+			changes["modified"] = append(changes["modified"], rel)
+			delete(d.blocks, rel)
+		}
+	}
+	for rel := range d.stamps {
+		if _, ok := stamps[rel]; !ok {
+			changes["removed"] = append(changes["removed"], rel)
+			delete(d.blocks, rel)
+		}
+	}
+	for _, paths := range changes {
+		sort.Strings(paths)
+	}
+	d.files, d.stamps, d.skipped = byPath, stamps, reasons
+	return changes, nil
+}
+
+// pollChanges rescans the tree periodically and notifies watchers of
+// changes with a "changed" notification.
+func (d *bundleDaemon) pollChanges(interval time.Duration) {
+	for range time.Tick(interval) {
+		changes, err := d.rescan()
+		if err != nil {
+			log.Printf("Rescan failed: %v", err)
+			continue
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		d.mu.Lock()
+		watchers := make([]*rpcConn, 0, len(d.watchers))
+		for c := range d.watchers {
+			watchers = append(watchers, c)
+		}
+		d.mu.Unlock()
+		for _, c := range watchers {
+			c.send(rpcMessage{Method: "changed", Params: changes})
+		}
+	}
+}
+
+// serve answers the requests of one connection until it closes.
+func (d *bundleDaemon) serve(conn net.Conn) {
+	defer conn.Close()
+	c := &rpcConn{enc: json.NewEncoder(conn)}
+	defer func() {
+		d.mu.Lock()
+		delete(d.watchers, c)
+		d.mu.Unlock()
+	}()
+	dec := json.NewDecoder(conn)
+	for {
+		var req rpcRequest
+		if err := dec.Decode(&req); err == io.EOF {
+			return
+		} else if err != nil {
+			c.send(rpcMessage{ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}})
+			return
+		}
+		result, rpcErr := d.call(c, req)
+		if req.ID == nil {
+			continue
+		}
+		resp := rpcMessage{ID: req.ID, Result: result, Error: rpcErr}
+		if rpcErr == nil && result == nil {
+			resp.Result = struct{}{}
+		}
+		if err := c.send(resp); err != nil {
+			return
+		}
+	}
+}
+
+// call dispatches a request to its method.
+func (d *bundleDaemon) call(c *rpcConn, req rpcRequest) (any, *rpcError) {
+	var params struct {
+		Path  string   `json:"path"`
+		Paths []string `json:"paths"`
+	}
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+	}
+	switch req.Method {
+	case "bundleSelection":
+		return d.bundleSelection(params.Paths)
+	case "explainPath":
+		if params.Path == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "path is required"}
+		}
+		return d.explainPath(params.Path), nil
+	case "stats":
+		return d.stats(), nil
+	case "warm":
+		return d.warm(c, req.ID != nil), nil
+	case "watch":
+		d.mu.Lock()
+		d.watchers[c] = true
+		d.mu.Unlock()
+		return map[string]bool{"watching": true}, nil
+	case "unwatch":
+		d.mu.Lock()
+		delete(d.watchers, c)
+		d.mu.Unlock()
+		return map[string]bool{"watching": false}, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method '%s'", req.Method)}
+}
+
+// bundleSelection renders the bundled files at or below the given paths
+// (relative to -src) as a bundle, from cache where the file is unchanged.
+func (d *bundleDaemon) bundleSelection(paths []string) (any, *rpcError) {
+	if len(paths) == 0 {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "paths is required"}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var selected []string
+	missing := []string{}
+	for _, p := range paths {
+		p = strings.Trim(filepath.ToSlash(filepath.Clean(p)), "/")
+		found := false
+		for rel := range d.files {
+			if p == "." || rel == p || strings.HasPrefix(rel, p+"/") {
+				selected = appendUnique(selected, rel)
+				found = true
+			}
+		}
+		if !found {
+			missing = append(missing, p)
+		}
+	}
+	sort.Strings(selected)
+
+	var out bytes.Buffer
+	written := 0
+	for _, rel := range selected {
+		cached, _, err := d.block(rel)
+		if err != nil {
+			missing = append(missing, rel)
+			continue
+		}
+		out.Write(cached.block)
+		written++
+	}
+	return map[string]any{"bundle": out.String(), "files": written, "missing": missing}, nil
+}
+
+// block returns the rendered block of a bundled file, rendering it if it is
+// not cached or the file changed since. rendered reports whether it was.
+// The caller must hold d.mu.
+func (d *bundleDaemon) block(rel string) (cached cachedBlock, rendered bool, err error) {
+	cached, ok := d.blocks[rel]
+	if ok && cached.stamp == d.stamps[rel] {
+		return cached, false, nil
+	}
+	f := d.files[rel]
+	content, err := os.ReadFile(f.Path)
+	if err != nil {
+		return cachedBlock{}, false, err
+	}
+	var block bytes.Buffer
+	d.opts.Style.WriteFile(&block, f.RelPath, f.Lang, "", content)
+	cached = cachedBlock{stamp: d.stamps[rel], block: block.Bytes(), tokens: d.tok.count(block.Bytes())}
+	d.blocks[rel] = cached
+	return cached, true, nil
+}
+
+// warmProgressEvery is how many files the warm method renders between
+// progress notifications.
+const warmProgressEvery = 100
+
+// warm renders the blocks of every bundled file that is not cached yet, so
+// the first bundleSelection after a start is as fast as later ones. Blocks
+// already cached are kept, so a warm that was cut short resumes where it
+// stopped. The caller gets "warmProgress" notifications with the files done
+// so far; if it goes away, the warm stops.
+func (d *bundleDaemon) warm(c *rpcConn, notify bool) map[string]any {
+	d.mu.Lock()
+	paths := make([]string, 0, len(d.files))
+	for rel := range d.files {
+		paths = append(paths, rel)
+	}
+	d.mu.Unlock()
+	sort.Strings(paths)
+
+	warmed, cached, failed, tokens := 0, 0, 0, 0
+	for i, rel := range paths {
+		// Lock per file so requests are answered during a long warm.
+		d.mu.Lock()
+		if _, ok := d.files[rel]; ok {
+			switch b, rendered, err := d.block(rel); {
+			case err != nil:
+				failed++
+			case rendered:
+				warmed++
+				tokens += b.tokens
+			default:
+				cached++
+				tokens += b.tokens
+			}
+		}
+		d.mu.Unlock()
+		if notify && (i+1)%warmProgressEvery == 0 {
+			if err := c.send(rpcMessage{Method: "warmProgress", Params: map[string]int{"done": i + 1, "total": len(paths)}}); err != nil {
+				break
+			}
+		}
+	}
+	return map[string]any{"warmed": warmed, "cached": cached, "failed": failed, "tokens": tokens, "tokenizer": d.tok.name()}
+}
+
+// explainPath tells why a path is or is not in the bundle. Paths below a
+// skipped directory report the directory's reason.
+func (d *bundleDaemon) explainPath(p string) map[string]any {
+	p = strings.Trim(filepath.ToSlash(filepath.Clean(p)), "/")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if f, ok := d.files[p]; ok {
+		return map[string]any{"path": p, "included": true, "language": f.Lang, "size": f.Size}
+	}
+	for dir := p; dir != "." && dir != ""; dir = filepath.ToSlash(filepath.Dir(dir)) {
+		if reason, ok := d.skipped[dir]; ok {
+			explanation := map[string]any{"path": p, "included": false, "reason": reason, "description": reasonDescription(reason)}
+			if dir != p {
+				explanation["skippedDirectory"] = dir
+			}
+			return explanation
+		}
+	}
+	if _, err := os.Stat(filepath.Join(d.opts.SrcDir, filepath.FromSlash(p))); err != nil {
+		return map[string]any{"path": p, "included": false, "reason": reasonNotFound, "description": reasonDescription(reasonNotFound)}
+	}
+	return map[string]any{"path": p, "included": false, "reason": reasonNotAFile, "description": reasonDescription(reasonNotAFile)}
+}
+
+// stats summarizes the current bundle contents.
+func (d *bundleDaemon) stats() map[string]any {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var total int64
+	tokens := 0
+	for _, b := range d.blocks {
+		tokens += b.tokens
+	}
+	bytesByLang := make(map[string]int64)
+	for _, f := range d.files {
+		total += f.Size
+		bytesByLang[f.Lang] += f.Size
+	}
+	skipped := make(map[string]int)
+	for _, reason := range d.skipped {
+		skipped[reason]++
+	}
+	dirHits, dirs, checkHits, checks := d.opts.Cache.Stats()
+	return map[string]any{
+		"projectType":  d.opts.ProjectType,
+		"files":        len(d.files),
+		"bytes":        total,
+		"languages":    languageShares(bytesByLang),
+		"skipped":      skipped,
+		"cachedFiles":  len(d.blocks),
+		"cachedTokens": tokens,
+		"walkCache": map[string]int{
+			"dirsReused":   dirHits,
+			"dirs":         dirs,
+			"checksReused": checkHits,
+			"checks":       checks,
+		},
+	}
+}
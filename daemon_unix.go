@@ -0,0 +1,42 @@
+//go:build unix
+
+// project-bundler/daemon_unix.go
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// listenUnix listens on a unix socket readable only by the current user,
+// replacing a stale socket file left by a daemon of the same user that did
+// not shut down. Anything else at path, including another user's socket, is
+// left alone.
+func listenUnix(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another daemon is already listening")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if info, err := os.Lstat(path); err == nil {
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if info.Mode().Type() != fs.ModeSocket || !ok || int(st.Uid) != os.Getuid() {
+			return nil, fmt.Errorf("'%s' exists and is not a stale socket of yours; remove it or pass another -socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	// The socket is created without group and other permissions, rather
+	// than restricted after Listen, so nobody can connect in between.
+	umask := syscall.Umask(0o077)
+	listener, err := net.Listen("unix", path)
+	syscall.Umask(umask)
+	return listener, err
+}
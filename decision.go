@@ -0,0 +1,79 @@
+// project-bundler/decision.go
+package main
+
+import (
+	"fmt"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// ruleSet is the library's rule-to-provenance map.
+type ruleSet = bundler.RuleSet
+
+// Skip reason codes of the CLI's own filters, in addition to the library's
+// bundler.Reason* codes.
+const (
+	reasonTooLarge     = "TOO_LARGE"
+	reasonTooOld       = "TOO_OLD"
+	reasonTooNew       = "TOO_NEW"
+	reasonBundleOutput = "BUNDLE_OUTPUT"
+	reasonOutsideTests = "OUTSIDE_TEST_CONTEXT"
+	reasonOutsideFocus = "OUTSIDE_FOCUS"
+	reasonOutsideFlaky = "OUTSIDE_FLAKY_CONTEXT"
+	reasonUnchanged    = "UNCHANGED"
+	reasonClassified   = "OVER_CLASSIFICATION"
+	reasonOverTokens   = "OVER_TOKEN_BUDGET"
+	reasonNotFound     = "NOT_FOUND"  // Only reported by the daemon's explain method.
+	reasonNotAFile     = "NOT_A_FILE" // Likewise.
+)
+
+// cliReasonText describes the CLI's reason codes in English.
+var cliReasonText = map[string]string{
+	reasonTooLarge:     "Over Size Limit",
+	reasonTooOld:       "Older Than Limit",
+	reasonTooNew:       "Newer Than Limit",
+	reasonBundleOutput: "Bundle Output",
+	reasonOutsideTests: "Outside Test Context",
+	reasonOutsideFocus: "Not Imported by Focus",
+	reasonOutsideFlaky: "Outside Flaky Test Context",
+	reasonUnchanged:    "Unchanged Since Ref",
+	reasonClassified:   "Above Max Classification",
+	reasonOverTokens:   "Did Not Fit Token Budget",
+	reasonNotFound:     "Not Found",
+	reasonNotAFile:     "Not a File",
+}
+
+// reasonDescription returns the English description of a skip reason code.
+func reasonDescription(code string) string {
+	if s, ok := bundler.ReasonText[code]; ok {
+		return s
+	}
+	if s, ok := cliReasonText[code]; ok {
+		return s
+	}
+	return code
+}
+
+// decisionLog collects skipped paths grouped by reason code and, in verbose mode,
+// prints every include/skip decision together with the rule that made it.
+type decisionLog struct {
+	verbose bool
+	skipped map[string][]string
+}
+
+func newDecisionLog(verbose bool) *decisionLog {
+	return &decisionLog{verbose: verbose, skipped: make(map[string][]string)}
+}
+
+func (l *decisionLog) skip(path, reason, rule string) {
+	l.skipped[reason] = append(l.skipped[reason], path)
+	if l.verbose {
+		fmt.Println(colorize(colorYellow, fmt.Sprintf("  [skip]    %s: %s [%s] (%s)", path, trReason(reason), reason, rule)))
+	}
+}
+
+func (l *decisionLog) include(path, rule string) {
+	if l.verbose {
+		fmt.Println(colorize(colorGreen, fmt.Sprintf("  [include] %s (%s)", path, rule)))
+	}
+}
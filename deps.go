@@ -0,0 +1,62 @@
+// project-bundler/deps.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// moduleDep is a third-party Go module bundled alongside the project with
+// -with-dep.
+type moduleDep struct {
+	Path    string `json:"Path"`
+	Version string `json:"Version"`
+	Dir     string `json:"Dir"` // Extracted source in the module cache or vendor/.
+	Error   string `json:"Error"`
+}
+
+// resolveModuleDep makes the source of a module available locally with
+// `go mod download`, which uses the module cache first and GOPROXY (and
+// GOPRIVATE, GOFLAGS, ...) otherwise. Without "@version", the version
+// required by the project's go.mod is used.
+func resolveModuleDep(srcDir, spec string) (moduleDep, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", "mod", "download", "-json", spec)
+	cmd.Dir = srcDir
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	runErr := cmd.Run()
+
+	var dep moduleDep
+	if err := json.Unmarshal(stdout.Bytes(), &dep); err != nil {
+		if runErr != nil {
+			return dep, fmt.Errorf("go mod download %s: %v: %s", spec, runErr, strings.TrimSpace(stderr.String()))
+		}
+		return dep, fmt.Errorf("go mod download %s: %w", spec, err)
+	}
+	if dep.Error != "" {
+		return dep, fmt.Errorf("go mod download %s: %s", spec, dep.Error)
+	}
+	return dep, nil
+}
+
+// prefix is the directory dependency files appear under in the bundle.
+func (d moduleDep) prefix() string {
+	return path.Join("deps", d.Path+"@"+d.Version)
+}
+
+// collectDepFiles walks a dependency's source with the project's filtering
+// rules and places its files under the dependency's prefix.
+func collectDepFiles(opts bundleOptions, dep moduleDep) ([]fileEntry, map[string][]string, error) {
+	opts.SrcDir = dep.Dir
+	opts.Only = nil // Allowlists are written for the project's paths.
+	files, skipped, err := collectFiles(opts)
+	for i := range files {
+		files[i].RelPath = filepath.Join(filepath.FromSlash(dep.prefix()), files[i].RelPath)
+	}
+	return files, skipped, err
+}
@@ -0,0 +1,191 @@
+// project-bundler/diagram.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// diagramMaxEdges caps the diagram so huge monorepos still produce something
+// a model (and a Mermaid renderer) can take in.
+const diagramMaxEdges = 150
+
+// availableDiagramLevels lists the accepted -diagram values.
+func availableDiagramLevels() []string {
+	return []string{"none", "packages", "modules"}
+}
+
+// dependencyGraph maps a source node to the set of nodes it imports.
+type dependencyGraph map[string]stringSet
+
+// buildDependencyGraph derives the internal import graph from the bundled
+// files. Nodes are directories ("packages") or top-level directories
+// ("modules"); imports that cannot be resolved to a directory inside the
+// project are treated as external and left out.
+func buildDependencyGraph(opts bundleOptions, files []fileEntry, level string) dependencyGraph {
+	goModule := readModuleLine(filepath.Join(opts.SrcDir, "go.mod"), "module ")
+	dartPackage := readModuleLine(filepath.Join(opts.SrcDir, "pubspec.yaml"), "name:")
+
+	dirs := make(stringSet)
+	for _, f := range files {
+		dirs[path.Dir(filepath.ToSlash(f.RelPath))] = struct{}{}
+	}
+
+	graph := make(dependencyGraph)
+	for _, f := range files {
+		if f.Placeholder {
+			continue
+		}
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			continue // Reported when the file itself is bundled.
+		}
+		imports, _, ok := summarizeSymbols(f.Lang, content)
+		if !ok {
+			continue
+		}
+		fromDir := path.Dir(filepath.ToSlash(f.RelPath))
+		for _, imp := range imports {
+			toDir, ok := resolveImportDir(imp, fromDir, goModule, dartPackage, dirs)
+			if !ok {
+				continue
+			}
+			from, to := diagramNode(fromDir, level), diagramNode(toDir, level)
+			if from == to {
+				continue
+			}
+			if graph[from] == nil {
+				graph[from] = make(stringSet)
+			}
+			graph[from][to] = struct{}{}
+		}
+	}
+	return graph
+}
+
+// readModuleLine returns the trimmed value after prefix on the first matching
+// line of a manifest such as go.mod or pubspec.yaml, or "" if absent.
+func readModuleLine(manifest, prefix string) string {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), prefix); ok {
+			return strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return ""
+}
+
+// resolveImportDir maps an import to the project directory it refers to.
+func resolveImportDir(imp, fromDir, goModule, dartPackage string, dirs stringSet) (string, bool) {
+	var candidate string
+	switch {
+	case goModule != "" && (imp == goModule || strings.HasPrefix(imp, goModule+"/")):
+		candidate = strings.TrimPrefix(strings.TrimPrefix(imp, goModule), "/")
+		if candidate == "" {
+			candidate = "."
+		}
+		return candidate, dirs.Contains(candidate)
+	case dartPackage != "" && strings.HasPrefix(imp, "package:"+dartPackage+"/"):
+		candidate = path.Dir(path.Join("lib", strings.TrimPrefix(imp, "package:"+dartPackage+"/")))
+	case strings.HasPrefix(imp, "./") || strings.HasPrefix(imp, "../"):
+		// JavaScript/TypeScript relative imports name a file or a directory index.
+		target := path.Join(fromDir, imp)
+		if dirs.Contains(target) {
+			return target, true
+		}
+		candidate = path.Dir(target)
+	case strings.HasSuffix(imp, ".dart") && !strings.Contains(imp, ":"):
+		candidate = path.Dir(path.Join(fromDir, imp))
+	case strings.HasPrefix(imp, "crate::"):
+		parts := strings.Split(strings.TrimPrefix(imp, "crate::"), "::")
+		candidate = path.Join("src", parts[0])
+		if !dirs.Contains(candidate) {
+			candidate = "src"
+		}
+	case strings.Contains(imp, ".") && !strings.ContainsAny(imp, "/:"):
+		// Python and JVM dotted names: try the longest prefix that is a directory.
+		parts := strings.Split(strings.TrimSuffix(imp, ".*"), ".")
+		for i := len(parts); i > 0; i-- {
+			if c := path.Join(parts[:i]...); dirs.Contains(c) {
+				return c, true
+			}
+		}
+		return "", false
+	default:
+		return "", false
+	}
+	return candidate, dirs.Contains(candidate)
+}
+
+// diagramNode collapses a directory to the requested level of detail.
+func diagramNode(dir, level string) string {
+	if level == "modules" {
+		if top, _, found := strings.Cut(dir, "/"); found {
+			return top
+		}
+	}
+	return dir
+}
+
+// writeDiagram renders the graph as a fenced Mermaid flowchart.
+func writeDiagram(w io.Writer, graph dependencyGraph, level string) error {
+	var edges [][2]string
+	for from, targets := range graph {
+		for to := range targets {
+			edges = append(edges, [2]string{from, to})
+		}
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i][0] != edges[j][0] {
+			return edges[i][0] < edges[j][0]
+		}
+		return edges[i][1] < edges[j][1]
+	})
+
+	omitted := 0
+	if len(edges) > diagramMaxEdges {
+		omitted = len(edges) - diagramMaxEdges
+		edges = edges[:diagramMaxEdges]
+	}
+
+	ids := make(map[string]string)
+	nodeID := func(name string) string {
+		if id, ok := ids[name]; ok {
+			return id
+		}
+		id := fmt.Sprintf("n%d", len(ids))
+		ids[name] = id
+		label := "/" + name
+		if name == "." {
+			label = "/"
+		}
+		return fmt.Sprintf("%s[%q]", id, label)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Dependency diagram (%s):\n```mermaid\ngraph LR\n", level)
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  %s --> %s\n", nodeID(e[0]), nodeID(e[1]))
+	}
+	b.WriteString("```\n")
+	if omitted > 0 {
+		fmt.Fprintf(&b, "(%d more edges omitted)\n", omitted)
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,191 @@
+// project-bundler/doccoverage.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// docCoverage is the documentation coverage of one package (directory).
+type docCoverage struct {
+	Package    string   `json:"package"`
+	Documented int      `json:"documented"`
+	Total      int      `json:"total"`
+	Missing    []string `json:"missing,omitempty"` // Exported symbols without a doc comment.
+}
+
+// findDocCoverage counts the exported symbols of the bundled files that do
+// and do not carry a doc comment, grouped by directory. Test files are left
+// out, since their exported test functions are not meant to be documented.
+func findDocCoverage(files []fileEntry) []docCoverage {
+	byPackage := make(map[string]*docCoverage)
+	for _, f := range files {
+		if f.Placeholder || isTestFile(filepath.ToSlash(f.RelPath)) {
+			continue
+		}
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			continue // Reported when the file itself is bundled.
+		}
+		symbols, ok := documentedSymbols(f.Lang, content)
+		if !ok || len(symbols) == 0 {
+			continue
+		}
+		pkg := path.Dir(filepath.ToSlash(f.RelPath))
+		c := byPackage[pkg]
+		if c == nil {
+			c = &docCoverage{Package: pkg}
+			byPackage[pkg] = c
+		}
+		for _, s := range symbols {
+			c.Total++
+			if s.documented {
+				c.Documented++
+			} else {
+				c.Missing = append(c.Missing, s.name)
+			}
+		}
+	}
+
+	coverage := make([]docCoverage, 0, len(byPackage))
+	for _, c := range byPackage {
+		coverage = append(coverage, *c)
+	}
+	sort.Slice(coverage, func(i, j int) bool { return coverage[i].Package < coverage[j].Package })
+	return coverage
+}
+
+// docSymbol is an exported symbol and whether it has a doc comment.
+type docSymbol struct {
+	name       string
+	documented bool
+}
+
+// documentedSymbols lists a file's exported symbols with their doc status.
+// Go uses the parser's doc comments; other languages use the export patterns
+// of languagePatterns and look for a comment (or, in Python, a docstring)
+// next to the declaration.
+func documentedSymbols(lang string, content []byte) ([]docSymbol, bool) {
+	if lang == "go" {
+		return goDocumentedSymbols(content)
+	}
+	patterns, found := languagePatterns[lang]
+	if !found {
+		return nil, false
+	}
+	var symbols []docSymbol
+	for _, re := range patterns.exports {
+		for _, m := range re.FindAllSubmatchIndex(content, -1) {
+			name := string(content[m[2]:m[3]])
+			if patterns.private != nil && patterns.private(name) {
+				continue
+			}
+			symbols = append(symbols, docSymbol{name, hasDocComment(lang, content, m[0], m[1])})
+		}
+	}
+	return symbols, true
+}
+
+// hasDocComment reports whether the declaration spanning content[start:end]
+// is documented: by a comment line above it (skipping annotations and
+// decorators) or, for Python, by a docstring below it.
+func hasDocComment(lang string, content []byte, start, end int) bool {
+	if lang == "python" {
+		rest := content[end:]
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			next := bytes.TrimSpace(rest[i+1:])
+			return bytes.HasPrefix(next, []byte(`"""`)) || bytes.HasPrefix(next, []byte(`'''`))
+		}
+		return false
+	}
+	lines := bytes.Split(content[:start], []byte("\n"))
+	// The last element is the declaration line itself up to start.
+	for i := len(lines) - 2; i >= 0; i-- {
+		line := bytes.TrimSpace(lines[i])
+		switch {
+		case len(line) == 0:
+			return false
+		case line[0] == '@':
+			continue
+		case bytes.HasPrefix(line, []byte("//")), bytes.HasPrefix(line, []byte("*")),
+			bytes.HasPrefix(line, []byte("/*")), bytes.HasPrefix(line, []byte("#")):
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// goDocumentedSymbols reports the exported Go declarations. A doc comment on
+// a grouped declaration covers the values in the group, as godoc shows it.
+func goDocumentedSymbols(content []byte) ([]docSymbol, bool) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, false
+	}
+	var symbols []docSymbol
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				recv := receiverTypeName(d.Recv.List[0].Type)
+				if !ast.IsExported(recv) {
+					continue
+				}
+				name = recv + "." + name
+			}
+			symbols = append(symbols, docSymbol{name, d.Doc != nil})
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Name.IsExported() {
+						symbols = append(symbols, docSymbol{s.Name.Name, s.Doc != nil || (d.Doc != nil && !d.Lparen.IsValid())})
+					}
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						if name.IsExported() {
+							symbols = append(symbols, docSymbol{name.Name, s.Doc != nil || d.Doc != nil})
+						}
+					}
+				}
+			}
+		}
+	}
+	return symbols, true
+}
+
+// writeDocCoverageAppendix appends the per-package documentation coverage,
+// listing the undocumented symbols so documentation prompts can target them.
+func writeDocCoverageAppendix(w io.Writer, coverage []docCoverage) error {
+	if len(coverage) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Appendix: doc comment coverage (%d packages):\n\n| Package | Documented | Missing doc comments |\n|---|---|---|\n", len(coverage))
+	for _, c := range coverage {
+		missing := "—"
+		if len(c.Missing) > 0 {
+			missing = "`" + strings.Join(c.Missing, "`, `") + "`"
+		}
+		fmt.Fprintf(&b, "| `%s` | %d/%d (%.0f%%) | %s |\n", c.Package, c.Documented, c.Total, 100*float64(c.Documented)/float64(c.Total), missing)
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
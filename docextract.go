@@ -0,0 +1,499 @@
+// project-bundler/docextract.go
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// documentFormat returns the name of a document format whose text
+// -extract-docs can recover, or "".
+func documentFormat(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".pdf":
+		return "PDF"
+	case ".docx":
+		return "DOCX"
+	case ".pptx":
+		return "PPTX"
+	}
+	return ""
+}
+
+// documentConverter replaces PDF, DOCX and PPTX files with their plain text.
+// Documents without any recoverable text are skipped as binary content.
+var documentConverter = fileConverter{
+	handles: func(name string) bool { return documentFormat(name) != "" },
+	convert: func(name string) ([]byte, string, error) {
+		format := documentFormat(name)
+		text, err := extractDocumentText(name, format)
+		if err != nil {
+			return nil, "", fmt.Errorf("could not extract text: %w", err)
+		}
+		if len(bytes.TrimSpace(text)) == 0 {
+			return nil, "", fmt.Errorf("no extractable text (scanned or image-only?)")
+		}
+		return text, fmt.Sprintf("Plain text extracted from %s; layout, tables and images are not preserved.\n", format), nil
+	},
+}
+
+// extractDocumentText reads a document and returns its text.
+func extractDocumentText(name, format string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "PDF":
+		return extractPDFText(data)
+	case "DOCX":
+		return extractOfficeText(data, []string{"word/document.xml"}, "")
+	case "PPTX":
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		slideRE := regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+		var slides []string
+		for _, f := range zr.File {
+			if slideRE.MatchString(f.Name) {
+				slides = append(slides, f.Name)
+			}
+		}
+		sort.Slice(slides, func(i, j int) bool {
+			a, _ := strconv.Atoi(slideRE.FindStringSubmatch(slides[i])[1])
+			b, _ := strconv.Atoi(slideRE.FindStringSubmatch(slides[j])[1])
+			return a < b
+		})
+		return extractOfficeText(data, slides, "Slide")
+	}
+	return nil, fmt.Errorf("unsupported document format %s", format)
+}
+
+// extractOfficeText concatenates the text runs of the given XML parts of an
+// Office Open XML package (a zip), one paragraph per line. With a heading,
+// each part is introduced by "<heading> N".
+func extractOfficeText(data []byte, parts []string, heading string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		byName[f.Name] = f
+	}
+	var out bytes.Buffer
+	for i, name := range parts {
+		f, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("missing %s", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		if heading != "" {
+			if i > 0 {
+				out.WriteString("\n")
+			}
+			fmt.Fprintf(&out, "%s %d\n", heading, i+1)
+		}
+		err = officeXMLText(rc, &out)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// officeXMLText writes the text of WordprocessingML or DrawingML markup: "t"
+// elements hold text, "p" elements are paragraphs, and "tab" and "br" are
+// what they say.
+func officeXMLText(r io.Reader, w *bytes.Buffer) error {
+	dec := xml.NewDecoder(r)
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				w.WriteByte('\t')
+			case "br":
+				w.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				w.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				w.Write(t)
+			}
+		}
+	}
+}
+
+var (
+	pdfStreamRE = regexp.MustCompile(`>>\s*stream\r?\n`)
+	pdfBfRE     = regexp.MustCompile(`<([0-9A-Fa-f]+)>\s*<([0-9A-Fa-f]+)>(?:\s*<([0-9A-Fa-f]+)>)?`)
+)
+
+// extractPDFText recovers the text of a PDF from the show-text operators of
+// its content streams. It is an approximation: streams must be uncompressed
+// or FlateDecode, fonts with a ToUnicode CMap are decoded through the merged
+// mappings of all CMaps in the file, and text appears in content order
+// rather than reading order. Encrypted PDFs are not supported.
+func extractPDFText(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\r\n\t "), []byte("%PDF")) {
+		return nil, fmt.Errorf("not a PDF file")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return nil, fmt.Errorf("encrypted PDF")
+	}
+	var streams [][]byte
+	for _, m := range pdfStreamRE.FindAllSubmatchIndex(data, -1) {
+		// The stream's dictionary, from the start of its object.
+		dict := data[max(0, m[0]-2048):m[0]]
+		if i := bytes.LastIndex(dict, []byte(" obj")); i >= 0 {
+			dict = dict[i:]
+		}
+		body := data[m[1]:]
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			continue
+		}
+		body = body[:end]
+		if bytes.Contains(dict, []byte("/Filter")) {
+			if !bytes.Contains(dict, []byte("/FlateDecode")) || bytes.Contains(dict, []byte("/DecodeParms")) {
+				continue // Images and other encodings carry no text we can read.
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(body))
+			if err != nil {
+				continue
+			}
+			body, err = io.ReadAll(zr)
+			if err != nil && len(body) == 0 {
+				continue
+			}
+		}
+		streams = append(streams, body)
+	}
+
+	cmap := make(map[string]string)
+	for _, s := range streams {
+		if bytes.Contains(s, []byte("begincmap")) {
+			parsePDFCMap(s, cmap)
+		}
+	}
+	var out bytes.Buffer
+	for _, s := range streams {
+		if bytes.Contains(s, []byte("begincmap")) || !bytes.Contains(s, []byte("BT")) {
+			continue
+		}
+		pdfContentText(s, cmap, &out)
+	}
+	text := out.Bytes()
+	printable := 0
+	for _, r := range string(text) {
+		if unicode.IsPrint(r) || unicode.IsSpace(r) {
+			printable++
+		}
+	}
+	if n := utf8.RuneCount(text); n > 0 && printable*10 < n*9 {
+		return nil, fmt.Errorf("text uses font encodings that cannot be decoded")
+	}
+	return text, nil
+}
+
+// parsePDFCMap adds the bfchar and bfrange mappings of a ToUnicode CMap,
+// keyed by the upper-case hex of the character code.
+func parsePDFCMap(s []byte, cmap map[string]string) {
+	for _, section := range []string{"bfchar", "bfrange"} {
+		rest := s
+		for {
+			start := bytes.Index(rest, []byte("begin"+section))
+			if start < 0 {
+				break
+			}
+			rest = rest[start+len("begin"+section):]
+			end := bytes.Index(rest, []byte("end"+section))
+			if end < 0 {
+				break
+			}
+			for _, m := range pdfBfRE.FindAllSubmatch(rest[:end], -1) {
+				if section == "bfchar" {
+					cmap[strings.ToUpper(string(m[1]))] = utf16Hex(string(m[2]))
+					continue
+				}
+				if m[3] == nil {
+					continue // Ranges mapping to arrays are not supported.
+				}
+				lo, err1 := strconv.ParseUint(string(m[1]), 16, 32)
+				hi, err2 := strconv.ParseUint(string(m[2]), 16, 32)
+				dst, err3 := strconv.ParseUint(string(m[3]), 16, 32)
+				if err1 != nil || err2 != nil || err3 != nil || hi < lo || hi-lo > 0xFFFF {
+					continue
+				}
+				width := len(m[1])
+				for c := lo; c <= hi; c++ {
+					cmap[fmt.Sprintf("%0*X", width, c)] = string(rune(dst + c - lo))
+				}
+			}
+			rest = rest[end:]
+		}
+	}
+}
+
+// utf16Hex decodes the UTF-16BE hex of a CMap destination.
+func utf16Hex(h string) string {
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		return ""
+	}
+	var units []uint16
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	var sb strings.Builder
+	for i := 0; i < len(units); i++ {
+		u := rune(units[i])
+		if u >= 0xD800 && u < 0xDC00 && i+1 < len(units) {
+			u = (u-0xD800)<<10 + (rune(units[i+1]) - 0xDC00) + 0x10000
+			i++
+		}
+		sb.WriteRune(u)
+	}
+	return sb.String()
+}
+
+// pdfContentText writes the strings shown by a content stream's Tj, TJ, '
+// and " operators, breaking lines at text positioning that moves down and
+// at the end of each text object.
+func pdfContentText(s []byte, cmap map[string]string, out *bytes.Buffer) {
+	var operands [][]byte // String operands since the last operator.
+	var numbers []string
+	inArray := false
+	show := func() {
+		for _, str := range operands {
+			out.WriteString(decodePDFString(str, cmap))
+		}
+	}
+	newline := func() {
+		if out.Len() > 0 && out.Bytes()[out.Len()-1] != '\n' {
+			out.WriteByte('\n')
+		}
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '(':
+			str, n := pdfLiteralString(s[i:])
+			operands = append(operands, str)
+			i += n
+		case c == '<' && i+1 < len(s) && s[i+1] != '<':
+			end := bytes.IndexByte(s[i:], '>')
+			if end < 0 {
+				return
+			}
+			operands = append(operands, pdfHexString(s[i+1:i+end]))
+			i += end + 1
+		case c == '%':
+			for i < len(s) && s[i] != '\n' && s[i] != '\r' {
+				i++
+			}
+		case c == '[' || c == ']':
+			inArray = c == '['
+			i++
+		case c == '<' || c == '>' || c == '{' || c == '}' || c == '/' || isPDFSpace(c):
+			i++
+		default:
+			j := i
+			for j < len(s) && !isPDFSpace(s[j]) && !strings.ContainsRune("()<>[]{}/%", rune(s[j])) {
+				j++
+			}
+			if j == i { // A stray delimiter such as ')'.
+				i++
+				continue
+			}
+			word := string(s[i:j])
+			i = j
+			if v, err := strconv.ParseFloat(word, 64); err == nil {
+				if inArray && v <= -200 && len(operands) > 0 {
+					// A large TJ adjustment is how many producers space words.
+					operands = append(operands, []byte(" "))
+				}
+				numbers = append(numbers, word)
+				if len(numbers) > 8 {
+					numbers = numbers[1:]
+				}
+				continue
+			}
+			switch word {
+			case "Tj", "TJ":
+				show()
+			case "'", "\"":
+				newline()
+				show()
+			case "T*", "ET":
+				newline()
+			case "Td", "TD":
+				if len(numbers) >= 2 {
+					if ty, _ := strconv.ParseFloat(numbers[len(numbers)-1], 64); ty < 0 {
+						newline()
+					} else if tx, _ := strconv.ParseFloat(numbers[len(numbers)-2], 64); tx > 0 && out.Len() > 0 && out.Bytes()[out.Len()-1] != '\n' {
+						out.WriteByte(' ')
+					}
+				}
+			case "Tm":
+				newline()
+			}
+			operands = operands[:0]
+			numbers = numbers[:0]
+		}
+	}
+	newline()
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+// pdfLiteralString parses a parenthesized string with its escapes and nested
+// parentheses, returning the bytes and the length consumed.
+func pdfLiteralString(s []byte) ([]byte, int) {
+	var b []byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '(':
+			if depth > 0 {
+				b = append(b, c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return b, i + 1
+			}
+			b = append(b, c)
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch e := s[i]; e {
+			case 'n':
+				b = append(b, '\n')
+			case 'r':
+				b = append(b, '\r')
+			case 't':
+				b = append(b, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				if e == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+					i++
+				}
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0
+					for k := 0; k < 3 && i < len(s) && s[i] >= '0' && s[i] <= '7'; k++ {
+						v = v*8 + int(s[i]-'0')
+						i++
+					}
+					i--
+					b = append(b, byte(v))
+				} else {
+					b = append(b, e)
+				}
+			}
+		default:
+			b = append(b, c)
+		}
+	}
+	return b, len(s)
+}
+
+// pdfHexString decodes the digits of a <...> string; whitespace is ignored
+// and a missing final digit is 0.
+func pdfHexString(digits []byte) []byte {
+	h := string(bytes.Map(func(r rune) rune {
+		if isPDFSpace(byte(r)) {
+			return -1
+		}
+		return r
+	}, digits))
+	if len(h)%2 == 1 {
+		h += "0"
+	}
+	b, _ := hex.DecodeString(h)
+	return b
+}
+
+// decodePDFString maps a string operand to text: through the CMap when one
+// covers its codes, otherwise as Latin-1 (close enough to WinAnsi and
+// PDFDocEncoding for the ASCII range).
+func decodePDFString(raw []byte, cmap map[string]string) string {
+	if len(cmap) > 0 {
+		for _, width := range []int{2, 1} {
+			if len(raw)%width != 0 {
+				continue
+			}
+			var sb strings.Builder
+			ok := true
+			for i := 0; i < len(raw); i += width {
+				r, found := cmap[fmt.Sprintf("%0*X", width*2, raw[i:i+width])]
+				if !found {
+					ok = false
+					break
+				}
+				sb.WriteString(r)
+			}
+			if ok {
+				return sb.String()
+			}
+		}
+	}
+	var sb strings.Builder
+	for _, b := range raw {
+		switch {
+		case pdfFallbackChars[b] != "":
+			sb.WriteString(pdfFallbackChars[b])
+		case b < 0x20 && b != '\t' && b != '\n':
+			// Other control codes are glyph slots of custom font encodings.
+		default:
+			sb.WriteRune(rune(b))
+		}
+	}
+	return sb.String()
+}
+
+// pdfFallbackChars maps the codes of TeX's ligature slots and WinAnsi's
+// typographic punctuation, which Latin-1 leaves as control characters.
+var pdfFallbackChars = [256]string{
+	0x0B: "ff", 0x0C: "fi", 0x0D: "fl", 0x0E: "ffi", 0x0F: "ffl",
+	0x85: "…", 0x91: "‘", 0x92: "’", 0x93: "“", 0x94: "”", 0x95: "•", 0x96: "–", 0x97: "—",
+}
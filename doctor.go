@@ -0,0 +1,221 @@
+// project-bundler/doctor.go
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// checkStatus is the outcome of a single doctor check.
+type checkStatus int
+
+const (
+	checkOK checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+func (s checkStatus) symbol() string {
+	if plainOutput {
+		return [...]string{"OK", "WARN", "FAIL"}[s]
+	}
+	switch s {
+	case checkWarn:
+		return "!"
+	case checkFail:
+		return "✖"
+	}
+	return "✔"
+}
+
+// doctorCheck is one diagnostic line, with an actionable fix when not OK.
+type doctorCheck struct {
+	status checkStatus
+	name   string
+	detail string
+	fix    string
+}
+
+const (
+	// doctorLargeDirShare flags a directory holding this percentage of the bundle.
+	doctorLargeDirShare = 25.0
+	// doctorLargeDirFiles flags a directory contributing this many files.
+	doctorLargeDirFiles = 2000
+)
+
+// runDoctor implements the `doctor` subcommand. It checks the environment and
+// the source tree for the problems most support requests boil down to.
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	srcDir := fs.String("src", ".", "Source project directory.")
+	projectType := fs.String("type", "auto", "Project type. Options: "+strings.Join(availableProjectTypes(), ", "))
+	outputFile := fs.String("output", "bundle.md", "Output file whose location should be writable.")
+	fs.BoolVar(&plainOutput, "plain", plainOutput, "Use plain text status markers instead of symbols.")
+	fs.Parse(args)
+
+	var checks []doctorCheck
+	checks = append(checks, checkGit(*srcDir))
+
+	srcCheck := checkSource(*srcDir)
+	checks = append(checks, srcCheck)
+	if srcCheck.status != checkFail {
+		checks = append(checks, checkFilesystem(*srcDir))
+		checks = append(checks, checkPermissions(*srcDir))
+
+		opts, err := resolveOptions(*srcDir, *projectType, "", "", false)
+		if err != nil {
+			checks = append(checks, doctorCheck{status: checkFail, name: "Project type", detail: err.Error(),
+				fix: "Pass one of the listed types with -type, or use -type=auto."})
+		} else {
+			checks = append(checks, doctorCheck{status: checkOK, name: "Project type", detail: opts.ProjectType})
+			checks = append(checks, checkLargeDirectories(opts)...)
+		}
+	}
+	checks = append(checks, checkOutputWritable(*outputFile))
+
+	fmt.Println("\n--- project-bundler doctor ---")
+	failed := false
+	for _, c := range checks {
+		fmt.Printf("%s %s: %s\n", c.status.symbol(), c.name, c.detail)
+		if c.fix != "" && c.status != checkOK {
+			fmt.Printf("    fix: %s\n", c.fix)
+		}
+		failed = failed || c.status == checkFail
+	}
+	fmt.Println("------------------------------")
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func checkGit(srcDir string) doctorCheck {
+	path, err := exec.LookPath("git")
+	if err != nil {
+		return doctorCheck{status: checkWarn, name: "git", detail: "not found on PATH",
+			fix: "Install git; git-based features need it to inspect history."}
+	}
+	if err := exec.Command(path, "-C", srcDir, "rev-parse", "--is-inside-work-tree").Run(); err != nil {
+		return doctorCheck{status: checkWarn, name: "git", detail: fmt.Sprintf("%s available, but '%s' is not inside a git work tree", path, srcDir),
+			fix: "Run from a git checkout if you rely on git-based features."}
+	}
+	return doctorCheck{status: checkOK, name: "git", detail: path}
+}
+
+func checkSource(srcDir string) doctorCheck {
+	info, err := os.Stat(srcDir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return doctorCheck{status: checkFail, name: "Source directory", detail: fmt.Sprintf("'%s' does not exist", srcDir),
+			fix: "Check the -src path."}
+	case err != nil:
+		return doctorCheck{status: checkFail, name: "Source directory", detail: err.Error(),
+			fix: "Make sure the directory is readable by the current user."}
+	case !info.IsDir():
+		return doctorCheck{status: checkFail, name: "Source directory", detail: fmt.Sprintf("'%s' is not a directory", srcDir),
+			fix: "Point -src at the project root directory, not a file."}
+	}
+	return doctorCheck{status: checkOK, name: "Source directory", detail: srcDir}
+}
+
+func checkFilesystem(srcDir string) doctorCheck {
+	if fsType := unsupportedFilesystem(srcDir); fsType != "" {
+		return doctorCheck{status: checkWarn, name: "Filesystem", detail: fmt.Sprintf("source is on a %s filesystem", fsType),
+			fix: "Network and FUSE filesystems make walks slow and may hide files; bundle from a local clone if possible."}
+	}
+	return doctorCheck{status: checkOK, name: "Filesystem", detail: "local"}
+}
+
+// checkPermissions walks the whole tree (ignoring presets) and reports entries
+// the current user cannot read, since those abort a bundling run.
+func checkPermissions(srcDir string) doctorCheck {
+	var denied []string
+	filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrPermission) {
+				denied = append(denied, path)
+			}
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if f, err := os.Open(path); err != nil {
+				if errors.Is(err, fs.ErrPermission) {
+					denied = append(denied, path)
+				}
+			} else {
+				f.Close()
+			}
+		}
+		return nil
+	})
+
+	if len(denied) == 0 {
+		return doctorCheck{status: checkOK, name: "Permissions", detail: "all entries readable"}
+	}
+	shown := denied
+	if len(shown) > 5 {
+		shown = shown[:5]
+	}
+	return doctorCheck{status: checkFail, name: "Permissions", detail: fmt.Sprintf("%d unreadable entries, e.g. %s", len(denied), strings.Join(shown, ", ")),
+		fix: "Fix ownership/permissions, or exclude these paths with -ignore-dirs / -ignore-exts."}
+}
+
+// checkLargeDirectories flags directories that dominate the bundle, which
+// usually means a dependency or build output directory is not ignored.
+func checkLargeDirectories(opts bundleOptions) []doctorCheck {
+	files, _, err := collectFiles(opts)
+	if err != nil {
+		return []doctorCheck{{status: checkFail, name: "Directory walk", detail: err.Error(),
+			fix: "Resolve the error above; bundling will fail the same way."}}
+	}
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	if total == 0 {
+		return []doctorCheck{{status: checkWarn, name: "Bundle contents", detail: "no files would be bundled",
+			fix: "Check -type and the ignore lists; everything is currently filtered out."}}
+	}
+
+	var checks []doctorCheck
+	for _, s := range buildSuggestions(files, total, doctorLargeDirShare) {
+		if s.kind != adviceDir {
+			continue
+		}
+		checks = append(checks, doctorCheck{status: checkWarn, name: "Large directory",
+			detail: fmt.Sprintf("'%s' is %.0f%% of the bundle (%s, %d files)", s.name, percent(s.bytes, total), formatSize(s.bytes), s.files),
+			fix:    fmt.Sprintf("If it is generated or vendored, exclude it: %s", s.flag(opts))})
+	}
+	for _, s := range buildSuggestions(files, total, 0) {
+		if s.kind == adviceDir && s.files >= doctorLargeDirFiles {
+			checks = append(checks, doctorCheck{status: checkWarn, name: "Large directory",
+				detail: fmt.Sprintf("'%s' contributes %d files", s.name, s.files),
+				fix:    fmt.Sprintf("Directories with thousands of files are usually dependencies; consider %s", s.flag(opts))})
+		}
+	}
+	if len(checks) == 0 {
+		checks = append(checks, doctorCheck{status: checkOK, name: "Bundle contents",
+			detail: fmt.Sprintf("%d files, %s, no dominating directories", len(files), formatSize(total))})
+	}
+	return checks
+}
+
+func checkOutputWritable(outputFile string) doctorCheck {
+	dir := filepath.Dir(outputFile)
+	f, err := os.CreateTemp(dir, ".project-bundler-doctor-*")
+	if err != nil {
+		return doctorCheck{status: checkFail, name: "Output location", detail: fmt.Sprintf("cannot write to '%s': %v", dir, err),
+			fix: "Choose a writable location with -output."}
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return doctorCheck{status: checkOK, name: "Output location", detail: dir}
+}
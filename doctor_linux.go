@@ -0,0 +1,25 @@
+// project-bundler/doctor_linux.go
+package main
+
+import "syscall"
+
+// Magic numbers from statfs(2) for filesystems that are slow or unreliable to walk.
+var remoteFilesystems = map[int64]string{
+	0x6969:     "NFS",
+	0x517B:     "SMB",
+	0xFF534D42: "CIFS",
+	0xFE534D42: "SMB2",
+	0x65735546: "FUSE",
+	0x01021997: "9P",
+	0x564C:     "NCP",
+}
+
+// unsupportedFilesystem returns the name of the filesystem type if the path
+// lives on a network or FUSE mount, or "" otherwise.
+func unsupportedFilesystem(path string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return ""
+	}
+	return remoteFilesystems[int64(st.Type)]
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+// project-bundler/doctor_other.go
+package main
+
+// unsupportedFilesystem is only implemented on Linux, where statfs(2)
+// reports the filesystem type.
+func unsupportedFilesystem(path string) string {
+	return ""
+}
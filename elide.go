@@ -0,0 +1,117 @@
+// project-bundler/elide.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	elideMaxPeriod  = 8 // Longest multi-line entry (in lines) considered for repetition.
+	elideMinEntries = 6 // A run must repeat at least this many times to be elided.
+	elideKeep       = 2 // Number of leading entries kept verbatim as an example.
+)
+
+var (
+	shapeString = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|` + "`[^`]*`")
+	shapeNumber = regexp.MustCompile(`\b(?:0[xX][0-9a-fA-F_]+|\d[\d_]*(?:\.\d+)?)\b`)
+	shapeIdent  = regexp.MustCompile(`[A-Za-z_]\w*`)
+	shapeSpace  = regexp.MustCompile(`\s+`)
+)
+
+// lineShape reduces a line to its structure: literals and identifiers are
+// replaced by placeholders so that e.g. `{name: "a", want: 1},` and
+// `{name: "b", want: 2},` compare equal. Indentation is preserved.
+func lineShape(line string) string {
+	trimmed := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(trimmed)]
+	s := shapeString.ReplaceAllString(trimmed, `"s"`)
+	s = shapeNumber.ReplaceAllString(s, "0")
+	s = shapeIdent.ReplaceAllString(s, "a")
+	s = shapeSpace.ReplaceAllString(s, " ")
+	return indent + strings.TrimSpace(s)
+}
+
+// elideBoilerplate keeps declarations and structure but collapses long runs of
+// repetitive entries (generated getters/setters, table-driven test cases, const
+// blocks) into a single "… (N similar entries elided)" line.
+func elideBoilerplate(content []byte) []byte {
+	lines := strings.Split(string(content), "\n")
+	shapes := make([]string, len(lines))
+	for i, line := range lines {
+		shapes[i] = lineShape(line)
+	}
+
+	var out bytes.Buffer
+	i := 0
+	for i < len(lines) {
+		period, end := findRepetition(shapes, i)
+		if period == 0 {
+			out.WriteString(lines[i])
+			if i < len(lines)-1 {
+				out.WriteByte('\n')
+			}
+			i++
+			continue
+		}
+
+		kept := i + elideKeep*period
+		for _, line := range lines[i:kept] {
+			out.WriteString(line)
+			out.WriteByte('\n')
+		}
+		first := lines[kept]
+		indent := first[:len(first)-len(strings.TrimLeft(first, " \t"))]
+		fmt.Fprintf(&out, "%s… (%d similar entries elided)", indent, (end-kept)/period)
+		if end < len(lines) {
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+	return out.Bytes()
+}
+
+// findRepetition looks for the longest run starting at index start in which a
+// block of `period` line shapes repeats back to back. It returns the period and
+// the exclusive end index of the run, or a zero period if none qualifies.
+func findRepetition(shapes []string, start int) (period, end int) {
+	bestLen := 0
+	for p := 1; p <= elideMaxPeriod && start+p <= len(shapes); p++ {
+		block := shapes[start : start+p]
+		if !hasIdentifier(block) {
+			continue
+		}
+		j := start + p
+		for j+p <= len(shapes) && equalShapes(shapes[j:j+p], block) {
+			j += p
+		}
+		entries := (j - start) / p
+		if entries >= elideMinEntries && j-start > bestLen {
+			bestLen = j - start
+			period, end = p, j
+		}
+	}
+	return period, end
+}
+
+// hasIdentifier rejects blocks consisting only of blank lines or punctuation,
+// which would otherwise match as "repetitive".
+func hasIdentifier(block []string) bool {
+	for _, s := range block {
+		if strings.Contains(s, "a") {
+			return true
+		}
+	}
+	return false
+}
+
+func equalShapes(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
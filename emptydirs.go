@@ -0,0 +1,74 @@
+// project-bundler/emptydirs.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// findEmptyDirs returns the directories under the source directory that have
+// no entries at all, relative to it and in walk order. Ignored directories
+// and paths are not descended into, as in collectFiles.
+func findEmptyDirs(opts bundleOptions) ([]string, error) {
+	var dirs []string
+	err := filepath.WalkDir(opts.SrcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(opts.SrcDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if opts.IgnoreDirs.Contains(d.Name()) {
+			return filepath.SkipDir
+		}
+		if _, ok := opts.IgnorePaths.MatchingGlob(rel); ok {
+			return filepath.SkipDir
+		}
+		entries, err := readDirNames(path, 1)
+		if err != nil {
+			return nil // Unreadable directories are reported by the main walk.
+		}
+		if len(entries) == 0 {
+			dirs = append(dirs, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// readDirNames reads at most n entry names without listing the whole directory.
+func readDirNames(dir string, n int) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(n)
+	if err == io.EOF {
+		err = nil
+	}
+	return names, err
+}
+
+// writeEmptyDirSection lists the empty directories so the directory layout,
+// which some build systems depend on, survives in the bundle.
+func writeEmptyDirSection(w io.Writer, style bundler.Style, dirs []string) error {
+	if len(dirs) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Empty directories (%d):\n\n", len(dirs))
+	for _, dir := range dirs {
+		fmt.Fprintf(&b, "- `%s/`\n", bundler.EscapeHeaderPath(style.DisplayPath(dir)))
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,124 @@
+// project-bundler/endpoints.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// routePattern finds HTTP route registrations. The method group may be
+// empty (net/http without a method) and the path group may carry a Go 1.22
+// "METHOD /path" pattern, which endpoint splits apart.
+type routePattern struct {
+	re          *regexp.Regexp
+	methodGroup int // 0 when the pattern carries no method.
+	pathGroup   int
+}
+
+// routePatterns are keyed by language identifier.
+var routePatterns = map[string][]routePattern{
+	"go": {
+		// net/http and gorilla/mux: http.HandleFunc("/x", ...), mux.Handle("GET /x", ...).
+		{re: regexp.MustCompile(`\.Handle(?:Func)?\(\s*"([^"]+)"`), pathGroup: 1},
+		// gin and echo: r.GET("/x", ...); chi: r.Get("/x", ...).
+		{re: regexp.MustCompile(`\.(GET|POST|PUT|DELETE|PATCH|HEAD|OPTIONS|Any|Get|Post|Put|Delete|Patch|Head|Options)\(\s*"(/[^"]*)"`), methodGroup: 1, pathGroup: 2},
+	},
+	"javascript": {
+		// express, fastify, koa-router and friends.
+		{re: regexp.MustCompile("\\b(?:app|router|server|fastify|api|routes)\\.(get|post|put|delete|patch|head|options|all)\\(\\s*['\"`](/[^'\"`]*)['\"`]"), methodGroup: 1, pathGroup: 2},
+	},
+	"java": {
+		{re: regexp.MustCompile(`@(Get|Post|Put|Delete|Patch|Request)Mapping\(\s*(?:(?:value|path)\s*=\s*)?\{?\s*"([^"]*)"`), methodGroup: 1, pathGroup: 2},
+	},
+	"python": {
+		// Flask/FastAPI style decorators: @app.get("/x"), @router.post("/x"), @app.route("/x").
+		{re: regexp.MustCompile(`@\w+\.(get|post|put|delete|patch|head|options|route)\(\s*['"](/[^'"]*)['"]`), methodGroup: 1, pathGroup: 2},
+	},
+}
+
+func init() {
+	routePatterns["typescript"] = routePatterns["javascript"]
+	routePatterns["kotlin"] = routePatterns["java"]
+}
+
+// endpoint is one discovered route registration.
+type endpoint struct {
+	method   string
+	path     string
+	location string // relPath:line of the registration.
+}
+
+// findEndpoints scans the bundled files for route registrations.
+func findEndpoints(files []fileEntry) []endpoint {
+	var found []endpoint
+	for _, f := range files {
+		patterns := routePatterns[f.Lang]
+		if len(patterns) == 0 || f.Placeholder {
+			continue
+		}
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			continue // Reported when the file itself is bundled.
+		}
+		for _, p := range patterns {
+			for _, m := range p.re.FindAllSubmatchIndex(content, -1) {
+				ep := endpoint{
+					path:     string(content[m[2*p.pathGroup]:m[2*p.pathGroup+1]]),
+					location: fmt.Sprintf("%s:%d", filepath.ToSlash(f.RelPath), lineAt(content, m[0])),
+				}
+				if p.methodGroup > 0 {
+					ep.method = string(content[m[2*p.methodGroup]:m[2*p.methodGroup+1]])
+				}
+				found = append(found, normalizeEndpoint(ep))
+			}
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].path != found[j].path {
+			return found[i].path < found[j].path
+		}
+		return found[i].method < found[j].method
+	})
+	return found
+}
+
+// normalizeEndpoint upper-cases methods and splits Go 1.22 "GET /x" patterns.
+func normalizeEndpoint(ep endpoint) endpoint {
+	if method, p, ok := strings.Cut(ep.path, " "); ok && ep.method == "" {
+		ep.method, ep.path = method, strings.TrimSpace(p)
+	}
+	switch strings.ToUpper(ep.method) {
+	case "", "ANY", "ALL", "ROUTE", "REQUEST":
+		ep.method = "*"
+	default:
+		ep.method = strings.ToUpper(ep.method)
+	}
+	return ep
+}
+
+// lineAt returns the 1-based line number of a byte offset.
+func lineAt(content []byte, offset int) int {
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
+
+// writeEndpointSection emits a Markdown table of the discovered routes.
+func writeEndpointSection(w io.Writer, files []fileEntry) error {
+	endpoints := findEndpoints(files)
+	if len(endpoints) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "HTTP endpoints (%d found):\n\n| Method | Path | Registered at |\n|---|---|---|\n", len(endpoints))
+	for _, ep := range endpoints {
+		fmt.Fprintf(&b, "| %s | `%s` | /%s |\n", ep.method, ep.path, ep.location)
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
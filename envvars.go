@@ -0,0 +1,117 @@
+// project-bundler/envvars.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// envDefaultPatterns recognize environment lookups whose fallback value is
+// visible in the source. Group 1 is the variable, group 2 the default.
+var envDefaultPatterns = map[string][]*regexp.Regexp{
+	"go": {
+		regexp.MustCompile(`cmp\.Or\(\s*os\.Getenv\(\s*"([^"]+)"\s*\)\s*,\s*("[^"]*"|-?\d[\w.]*|true|false)`),
+		// Project helpers such as getEnv("PORT", "8080") or envOr("DEBUG", false).
+		regexp.MustCompile(`\b\w*[eE]nv\w*\(\s*"([A-Z][A-Z0-9_]*)"\s*,\s*("[^"]*"|-?\d[\w.]*|true|false)`),
+	},
+	"javascript": {
+		regexp.MustCompile("process\\.env\\.([A-Za-z_]\\w*)\\s*(?:\\|\\||\\?\\?)\\s*(['\"`][^'\"`]*['\"`]|-?\\d[\\w.]*|true|false)"),
+		regexp.MustCompile("process\\.env\\[\\s*['\"]([^'\"]+)['\"]\\s*\\]\\s*(?:\\|\\||\\?\\?)\\s*(['\"`][^'\"`]*['\"`]|-?\\d[\\w.]*|true|false)"),
+	},
+	"python": {
+		regexp.MustCompile(`os\.(?:environ\.get|getenv)\(\s*['"]([^'"]+)['"]\s*,\s*(['"][^'"]*['"]|-?\d[\w.]*|True|False|None)`),
+	},
+	"rust": {
+		regexp.MustCompile(`env::var\(\s*"([^"]+)"\s*\)[^;]{0,40}?\.unwrap_or(?:_else)?\(\s*(?:\|_\|\s*)?("[^"]*")`),
+	},
+	"dart": {
+		regexp.MustCompile(`fromEnvironment\(\s*'([^']+)'\s*,\s*defaultValue:\s*('[^']*'|-?\d[\w.]*|true|false)`),
+	},
+}
+
+func init() {
+	envDefaultPatterns["typescript"] = envDefaultPatterns["javascript"]
+}
+
+// envVar is one environment variable with its statically known default.
+type envVar struct {
+	name      string
+	def       string // Empty when no default could be determined.
+	locations []string
+}
+
+// findEnvVars merges the plain environment lookups found by the key
+// inventory with lookups that carry a visible default.
+func findEnvVars(files []fileEntry) []*envVar {
+	vars := make(map[string]*envVar)
+	get := func(name string) *envVar {
+		v, ok := vars[name]
+		if !ok {
+			v = &envVar{name: name}
+			vars[name] = v
+		}
+		return v
+	}
+
+	for _, u := range findConfigKeys(files) {
+		if u.kind == keyEnv {
+			get(u.key).locations = u.locations
+		}
+	}
+
+	for _, f := range files {
+		patterns := envDefaultPatterns[f.Lang]
+		if len(patterns) == 0 || f.Placeholder {
+			continue
+		}
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			continue // Reported when the file itself is bundled.
+		}
+		for _, re := range patterns {
+			for _, m := range re.FindAllSubmatchIndex(content, -1) {
+				v := get(string(content[m[2]:m[3]]))
+				if v.def == "" {
+					v.def = string(content[m[4]:m[5]])
+				}
+				loc := fmt.Sprintf("%s:%d", filepath.ToSlash(f.RelPath), lineAt(content, m[0]))
+				if !slices.Contains(v.locations, loc) {
+					v.locations = append(v.locations, loc)
+				}
+			}
+		}
+	}
+
+	out := make([]*envVar, 0, len(vars))
+	for _, v := range vars {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+// writeEnvVarSection emits an "Environment variables" table before the files.
+func writeEnvVarSection(w io.Writer, files []fileEntry) error {
+	vars := findEnvVars(files)
+	if len(vars) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Environment variables (%d):\n\n| Variable | Default | Read at |\n|---|---|---|\n", len(vars))
+	for _, v := range vars {
+		def := "—"
+		if v.def != "" {
+			def = "`" + v.def + "`"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s |\n", v.name, def, formatLocations(v.locations))
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
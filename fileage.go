@@ -0,0 +1,115 @@
+// project-bundler/fileage.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseAge parses an -ignore-older-than or -ignore-newer-than value: a
+// number with a unit of y (365 days), mo (30 days), w or d, or anything
+// time.ParseDuration accepts, such as 36h.
+func parseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"y", 365 * 24 * time.Hour}, {"mo", 30 * 24 * time.Hour}, {"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour},
+	}
+	for _, u := range units {
+		if num, ok := strings.CutSuffix(s, u.suffix); ok {
+			n, err := strconv.ParseFloat(num, 64)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(n * float64(u.unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q; use e.g. 2y, 6mo, 3w, 10d or 36h", s)
+	}
+	return d, nil
+}
+
+// filterByAge skips the files last changed longer ago than -ignore-older-than
+// or more recently than -ignore-newer-than. A file's last change is its last
+// commit, so a fresh clone does not make every file new; files git does not
+// know (untracked, or no repository at all) fall back to their mtime.
+func filterByAge(opts bundleOptions, files []fileEntry, skipped map[string][]string) []fileEntry {
+	if opts.ignoreOlder == 0 && opts.ignoreNewer == 0 {
+		return files
+	}
+	want := make(stringSet)
+	for _, f := range files {
+		want[filepath.ToSlash(f.RelPath)] = struct{}{}
+	}
+	committed := lastCommitTimes(opts.SrcDir, want)
+	now := time.Now()
+	kept := files[:0]
+	for _, f := range files {
+		changed, ok := committed[filepath.ToSlash(f.RelPath)]
+		if !ok {
+			changed = f.ModTime
+		}
+		switch {
+		case f.DuplicateOf != "":
+		case opts.ignoreOlder > 0 && now.Sub(changed) > opts.ignoreOlder:
+			skipped[reasonTooOld] = append(skipped[reasonTooOld], f.Path)
+			continue
+		case opts.ignoreNewer > 0 && now.Sub(changed) < opts.ignoreNewer:
+			skipped[reasonTooNew] = append(skipped[reasonTooNew], f.Path)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// lastCommitTimes returns the time of the last commit touching each of the
+// wanted paths (slash-separated, relative to srcDir). It reads the history
+// newest first and stops as soon as every path has been seen, so recently
+// active trees do not pay for the whole history. Outside a repository it
+// returns an empty map.
+func lastCommitTimes(srcDir string, want stringSet) map[string]time.Time {
+	times := make(map[string]time.Time)
+	// Each commit is "\x01<unix time>\x00" followed by "\n"-prefixed,
+	// NUL-terminated file names.
+	cmd := exec.Command("git", "-C", srcDir, "log", "--format=%x01%ct", "--name-only", "-z", "--relative", "--no-renames", "--", ".")
+	out, err := cmd.StdoutPipe()
+	if err != nil || cmd.Start() != nil {
+		return times
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	r := bufio.NewReader(out)
+	var commit time.Time
+	for len(times) < len(want) {
+		token, err := r.ReadString(0)
+		if err != nil && (err != io.EOF || token == "") {
+			break
+		}
+		token = strings.TrimPrefix(strings.TrimSuffix(token, "\x00"), "\n")
+		if ts, ok := strings.CutPrefix(token, "\x01"); ok {
+			if sec, err := strconv.ParseInt(ts, 10, 64); err == nil {
+				commit = time.Unix(sec, 0)
+			}
+			continue
+		}
+		if _, seen := times[token]; token != "" && !seen && want.Contains(token) {
+			times[token] = commit
+		}
+	}
+	return times
+}
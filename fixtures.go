@@ -0,0 +1,167 @@
+// project-bundler/fixtures.go
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// fixtureSampleRows is how many synthetic records replace a fixture's data.
+const fixtureSampleRows = 2
+
+// defaultFixtureDirs are directory names whose data files -fake-fixtures
+// replaces with synthetic samples.
+var defaultFixtureDirs = []string{"fixtures", "testdata", "__fixtures__", "test_data"}
+
+var (
+	dateLike  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+	emailLike = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	uuidLike  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// inFixtureDir reports whether any directory component of relPath is one of
+// the fixture directory names.
+func inFixtureDir(dirs stringSet, relPath string) bool {
+	for _, part := range strings.Split(filepath.Dir(relPath), string(filepath.Separator)) {
+		if dirs.Contains(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// synthesizeFixture replaces the records of a CSV, TSV, JSON or JSON Lines
+// file with a few schema-preserving fake values, so the bundle shows the shape
+// of the data without shipping real records. Other files and files that do not
+// parse are returned unchanged; ok reports whether a replacement was made.
+func synthesizeFixture(name string, content []byte) (out []byte, ok bool) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return synthesizeDelimited(content, ',')
+	case ".tsv":
+		return synthesizeDelimited(content, '\t')
+	case ".json":
+		return synthesizeJSON(content)
+	case ".jsonl", ".ndjson":
+		return synthesizeJSONLines(content)
+	}
+	return content, false
+}
+
+func synthesizeDelimited(content []byte, comma rune) ([]byte, bool) {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil || len(records) < 2 {
+		return content, false
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = comma
+	w.Write(records[0]) // Header row.
+	for i := 1; i <= fixtureSampleRows; i++ {
+		template := records[1+(i-1)%(len(records)-1)]
+		row := make([]string, len(template))
+		for j, v := range template {
+			row[j] = fakeScalar(v, i)
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return buf.Bytes(), true
+}
+
+func synthesizeJSON(content []byte) ([]byte, bool) {
+	var v any
+	if err := json.Unmarshal(content, &v); err != nil {
+		return content, false
+	}
+	out, err := json.MarshalIndent(fakeJSONValue(v, 1), "", "  ")
+	if err != nil {
+		return content, false
+	}
+	return append(out, '\n'), true
+}
+
+func synthesizeJSONLines(content []byte) ([]byte, bool) {
+	var buf bytes.Buffer
+	n := 0
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if n == fixtureSampleRows {
+			break
+		}
+		var v any
+		if err := json.Unmarshal(line, &v); err != nil {
+			return content, false
+		}
+		n++
+		out, err := json.Marshal(fakeJSONValue(v, n))
+		if err != nil {
+			return content, false
+		}
+		buf.Write(out)
+		buf.WriteByte('\n')
+	}
+	if n == 0 {
+		return content, false
+	}
+	return buf.Bytes(), true
+}
+
+// fakeJSONValue keeps objects' keys and arrays' element shapes, trimming
+// arrays to fixtureSampleRows elements and faking every scalar.
+func fakeJSONValue(v any, seq int) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, e := range t {
+			out[k] = fakeJSONValue(e, seq)
+		}
+		return out
+	case []any:
+		out := make([]any, 0, fixtureSampleRows)
+		for i := 0; i < len(t) && i < fixtureSampleRows; i++ {
+			out = append(out, fakeJSONValue(t[i], i+1))
+		}
+		return out
+	case string:
+		return fakeScalar(t, seq)
+	case float64:
+		return float64(seq)
+	}
+	return v // bool and null carry no data worth hiding.
+}
+
+// fakeScalar returns a placeholder of the same apparent type as v.
+func fakeScalar(v string, seq int) string {
+	s := strings.TrimSpace(v)
+	switch {
+	case s == "":
+		return ""
+	case s == "true" || s == "false":
+		return s
+	case uuidLike.MatchString(s):
+		return "00000000-0000-0000-0000-" + strings.Repeat("0", 11) + strconv.Itoa(seq%10)
+	case dateLike.MatchString(s):
+		return "2000-01-0" + strconv.Itoa(seq%9+1) + s[10:]
+	case emailLike.MatchString(s):
+		return "user" + strconv.Itoa(seq) + "@example.com"
+	}
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return strconv.Itoa(seq)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Itoa(seq) + ".5"
+	}
+	return "text" + strconv.Itoa(seq)
+}
@@ -0,0 +1,167 @@
+// project-bundler/flaky.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// flakyRecentCommits is how many of the latest commits to the context's
+// files -flaky lists.
+const flakyRecentCommits = 20
+
+// flakyPatterns are the constructs that make Go tests flaky: parallelism,
+// goroutines and their synchronization, timing, and randomness.
+var flakyPatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"parallel test", regexp.MustCompile(`\bt\.Parallel\(\)`)},
+	{"goroutine", regexp.MustCompile(`\bgo\s+(?:func\b|[\w.]+\()`)},
+	{"synchronization", regexp.MustCompile(`\bsync\.(?:WaitGroup|Mutex|RWMutex|Once|Cond)\b|\batomic\.\w+|\bselect\s*\{|\bchan\b`)},
+	{"sleep", regexp.MustCompile(`\btime\.Sleep\(`)},
+	{"timer", regexp.MustCompile(`\btime\.(?:After|AfterFunc|NewTimer|NewTicker|Tick)\(|\bcontext\.With(?:Timeout|Deadline)\(`)},
+	{"wall clock", regexp.MustCompile(`\btime\.(?:Now|Since|Until)\(`)},
+	{"randomness", regexp.MustCompile(`\brand\.\w+\(`)},
+}
+
+// flakyContext is the file selection for `-flaky TestName`, with what the
+// section ahead of the files reports.
+type flakyContext struct {
+	test     string // The test function.
+	location string // Where it is defined, as path:line.
+	selected []fileEntry
+	dropped  []string // Paths left out of the context.
+	usages   []flakyUsage
+	commits  string // Recent commits to the selected files, one tab-separated line each; "" when there are none.
+	gitErr   error  // Why the commits could not be listed.
+}
+
+// flakyUsage is a line of the context that uses one of flakyPatterns.
+type flakyUsage struct {
+	relPath string
+	line    int
+	kind    string
+	code    string
+}
+
+// selectFlaky narrows the walked files to the context of a flaky Go test:
+// the test's package with its other tests and TestMain, the project
+// packages it imports, and the project's test helpers and fixtures. A
+// subtest name (TestFoo/case) selects its top-level test.
+func selectFlaky(opts bundleOptions, files []fileEntry, name string) (flakyContext, error) {
+	name, _, _ = strings.Cut(name, "/")
+	ctx := flakyContext{test: name}
+	decl := regexp.MustCompile(`(?m)^func ` + regexp.QuoteMeta(name) + `\(`)
+	var testDir string
+	for _, f := range files {
+		rel := filepath.ToSlash(f.RelPath)
+		if f.Lang != "go" || !strings.HasSuffix(rel, "_test.go") || f.Placeholder {
+			continue
+		}
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			continue
+		}
+		if loc := decl.FindIndex(content); loc != nil {
+			ctx.location = fmt.Sprintf("%s:%d", rel, 1+bytes.Count(content[:loc[0]], []byte("\n")))
+			testDir = path.Dir(rel)
+			break
+		}
+	}
+	if testDir == "" {
+		return ctx, fmt.Errorf("no Go test function %s in the bundled files", name)
+	}
+
+	deps := buildDependencyGraph(opts, files, "packages")[testDir]
+	for _, f := range files {
+		rel := filepath.ToSlash(f.RelPath)
+		dir := path.Dir(rel)
+		if dir == testDir || deps.Contains(dir) && !isTestFile(rel) || isTestHelper(rel) {
+			ctx.selected = append(ctx.selected, f)
+		} else {
+			ctx.dropped = append(ctx.dropped, f.Path)
+		}
+	}
+
+	var paths []string
+	for _, f := range ctx.selected {
+		paths = append(paths, f.RelPath)
+		if f.Lang == "go" && !f.Placeholder && !f.Binary {
+			ctx.usages = append(ctx.usages, findFlakyUsages(f)...)
+		}
+	}
+	ctx.commits, ctx.gitErr = gitOutput(opts.SrcDir, append([]string{"log", fmt.Sprintf("-n%d", flakyRecentCommits),
+		"--format=%h%x09%ct%x09%aN%x09%s", "--"}, paths...)...)
+	return ctx, nil
+}
+
+// findFlakyUsages lists the lines of a Go file that use flakyPatterns,
+// ignoring line comments.
+func findFlakyUsages(f fileEntry) []flakyUsage {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return nil // Reported when the file itself is bundled.
+	}
+	defer file.Close()
+	var usages []flakyUsage
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		code := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(code, "//"); i >= 0 {
+			code = strings.TrimSpace(code[:i])
+		}
+		for _, p := range flakyPatterns {
+			if p.re.MatchString(code) {
+				usages = append(usages, flakyUsage{filepath.ToSlash(f.RelPath), n, p.kind, code})
+				break
+			}
+		}
+	}
+	return usages
+}
+
+// writeFlakySection emits where the flaky test is, the concurrency, timing
+// and randomness in its context, and the recent changes to its files.
+func writeFlakySection(w io.Writer, ctx flakyContext) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Flaky test `%s`, defined at `%s` (%d files in its context).\n\n", ctx.test, ctx.location, len(ctx.selected))
+	if len(ctx.usages) == 0 {
+		b.WriteString("No parallelism, goroutines, timing or randomness found in its context.\n\n")
+	} else {
+		fmt.Fprintf(&b, "Parallelism, goroutines, timing and randomness in its context (%d):\n\n| Location | Kind | Code |\n|---|---|---|\n", len(ctx.usages))
+		for _, u := range ctx.usages {
+			code := "`" + u.code + "`"
+			if strings.Contains(u.code, "`") {
+				code = "`` " + u.code + " ``"
+			}
+			fmt.Fprintf(&b, "| `%s:%d` | %s | %s |\n", u.relPath, u.line, u.kind, strings.ReplaceAll(code, "|", "\\|"))
+		}
+		b.WriteString("\n")
+	}
+	switch {
+	case ctx.gitErr != nil:
+		fmt.Fprintf(&b, "Recent changes are not available: %v\n\n", ctx.gitErr)
+	case ctx.commits == "":
+		b.WriteString("No commits touched these files.\n\n")
+	default:
+		fmt.Fprintf(&b, "Latest commits to these files (up to %d):\n\n| Commit | Time | Author | Subject |\n|--------|------|--------|---------|\n", flakyRecentCommits)
+		for _, line := range strings.Split(ctx.commits, "\n") {
+			fields := strings.SplitN(line, "\t", 4)
+			if len(fields) < 4 {
+				continue
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", fields[0], formatUnix(fields[1]), fields[2], strings.ReplaceAll(fields[3], "|", "\\|"))
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,106 @@
+// project-bundler/focus.go
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// focusPackageDir reports whether a -focus entry names a Go package of the
+// project (a directory holding .go files, in a tree with a go.mod) rather
+// than a path glob, and returns the directory relative to srcDir.
+func focusPackageDir(srcDir, entry string) (string, bool) {
+	if strings.ContainsAny(entry, "*?[") {
+		return "", false
+	}
+	if _, err := os.Stat(filepath.Join(srcDir, "go.mod")); err != nil {
+		return "", false
+	}
+	dir := path.Clean(filepath.ToSlash(entry))
+	entries, err := os.ReadDir(filepath.Join(srcDir, filepath.FromSlash(dir)))
+	if err != nil {
+		return "", false
+	}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".go") {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// selectFocusPackages narrows the walked files to the -focus packages and
+// the project packages they import, directly or not, ordered dependencies
+// first so that every package follows the ones it builds on. The focus
+// packages keep their tests; their dependencies are bundled without them,
+// and so are imports only tests make. go.mod is kept for the import paths.
+// It returns the files to bundle and the paths left out.
+func selectFocusPackages(opts bundleOptions, files []fileEntry, targets []string) ([]fileEntry, []string) {
+	var code []fileEntry
+	for _, f := range files {
+		if !isTestFile(filepath.ToSlash(f.RelPath)) {
+			code = append(code, f)
+		}
+	}
+	graph := buildDependencyGraph(opts, code, "packages")
+
+	// A depth-first walk that appends each package after its imports.
+	var order []string
+	visited := make(stringSet)
+	var visit func(dir string)
+	visit = func(dir string) {
+		if visited.Contains(dir) {
+			return
+		}
+		visited[dir] = struct{}{}
+		deps := make([]string, 0, len(graph[dir]))
+		for to := range graph[dir] {
+			deps = append(deps, to)
+		}
+		slices.Sort(deps)
+		for _, to := range deps {
+			visit(to)
+		}
+		order = append(order, dir)
+	}
+	for _, target := range targets {
+		visit(target)
+	}
+	rank := make(map[string]int, len(order))
+	for i, dir := range order {
+		rank[dir] = i + 1 // 0 is go.mod's.
+	}
+
+	var selected []fileEntry
+	var dropped []string
+	for _, f := range files {
+		rel := filepath.ToSlash(f.RelPath)
+		dir := path.Dir(rel)
+		_, ok := rank[dir]
+		switch {
+		case rel == "go.mod":
+		case ok && (slices.Contains(targets, dir) || !isTestFile(rel)):
+		default:
+			dropped = append(dropped, f.Path)
+			continue
+		}
+		selected = append(selected, f)
+	}
+	slices.SortStableFunc(selected, func(a, b fileEntry) int {
+		return focusRank(rank, a) - focusRank(rank, b)
+	})
+	return selected, dropped
+}
+
+// focusRank is the position of a selected file's package in the
+// dependencies-first order.
+func focusRank(rank map[string]int, f fileEntry) int {
+	rel := filepath.ToSlash(f.RelPath)
+	if rel == "go.mod" {
+		return 0
+	}
+	return rank[path.Dir(rel)]
+}
@@ -0,0 +1,273 @@
+// project-bundler/formats.go
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// availableFormats lists the artifacts -format can produce. "md" is the
+// bundle itself; the others are written next to it with their own extension.
+func availableFormats() []string {
+	return []string{"md", "json", "jsonl", "zip", "xml", "html", "sqlite"}
+}
+
+// parseFormats validates a comma-separated -format value.
+func parseFormats(s string) ([]string, error) {
+	var formats []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if !slices.Contains(availableFormats(), f) {
+			return nil, fmt.Errorf("unknown format '%s'. Available formats are: %s", f, strings.Join(availableFormats(), ", "))
+		}
+		if !slices.Contains(formats, f) {
+			formats = append(formats, f)
+		}
+	}
+	return formats, nil
+}
+
+// wantsMarkdown reports whether the Markdown bundle is among the requested
+// formats. No formats means the default, Markdown only.
+func wantsMarkdown(formats []string) bool {
+	return len(formats) == 0 || slices.Contains(formats, "md")
+}
+
+// artifact receives every bundled file during the single walk that produces
+// the Markdown bundle, so all formats share the same filtering and contents.
+type artifact interface {
+	// add records a file. raw is the file as read from disk (nil for cloud
+	// placeholders); rendered is what the Markdown bundle shows.
+	add(f fileEntry, raw, rendered []byte, annotation string) error
+	// finish completes the artifact; truncated is the watchdog's reason, if any.
+	finish(truncated string) error
+	path() string
+}
+
+// openArtifacts creates the non-Markdown artifacts named in formats. theme
+// names the highlightTheme of html output, or is "" for plain code.
+func openArtifacts(formats []string, outputFile string, base64Invalid bool, theme string) ([]artifact, error) {
+	var artifacts []artifact
+	for _, format := range formats {
+		var a artifact
+		var err error
+		switch format {
+		case "json":
+			a, err = newJSONArtifact(sidecarPath(outputFile, ".json"))
+		case "jsonl":
+			a, err = newJSONLArtifact(sidecarPath(outputFile, ".jsonl"))
+		case "zip":
+			a, err = newZipArtifact(sidecarPath(outputFile, ".zip"))
+		case "xml":
+			a, err = newXMLArtifact(sidecarPath(outputFile, ".xml"), base64Invalid)
+		case "html":
+			a, err = newHTMLArtifact(sidecarPath(outputFile, ".html"), base64Invalid, theme)
+		case "sqlite":
+			a, err = newSQLiteArtifact(sidecarPath(outputFile, ".db"))
+		default:
+			continue
+		}
+		if err != nil {
+			for _, opened := range artifacts {
+				opened.finish("")
+			}
+			return nil, fmt.Errorf("failed to create %s output: %w", format, err)
+		}
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, nil
+}
+
+// jsonArtifact streams {"generated": ..., "files": [...]} so large trees are
+// never held in memory.
+type jsonArtifact struct {
+	file  *os.File
+	w     *bufio.Writer
+	count int
+
+	docCoverage []docCoverage   // Written as "docCoverage" when set (-doc-coverage).
+	owners      []dirOwners     // Written as "owners" when set (-owners).
+	languages   []languageShare // Written as "languages" when any file has a known language.
+	skipped     map[string]int  // Written as "skipped", counts by reason code, when files were skipped.
+}
+
+// jsonFile is one element of the JSON artifact's "files" array.
+type jsonFile struct {
+	Path        string `json:"path"`
+	Language    string `json:"language"`
+	Size        int64  `json:"size"`
+	Annotation  string `json:"annotation,omitempty"`
+	Content     string `json:"content"`
+	Placeholder bool   `json:"placeholder,omitempty"`
+}
+
+func newJSONFile(f fileEntry, rendered []byte, annotation string) jsonFile {
+	return jsonFile{
+		Path:        filepath.ToSlash(f.RelPath),
+		Language:    f.Lang,
+		Size:        f.Size,
+		Annotation:  strings.TrimSuffix(annotation, "\n"),
+		Content:     string(rendered),
+		Placeholder: f.Placeholder,
+	}
+}
+
+func newJSONArtifact(path string) (*jsonArtifact, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &jsonArtifact{file: f, w: bufio.NewWriter(f)}
+	fmt.Fprintf(a.w, "{\"generated\":%q,\"files\":[", time.Now().UTC().Format(time.RFC3339))
+	return a, nil
+}
+
+func (a *jsonArtifact) add(f fileEntry, raw, rendered []byte, annotation string) error {
+	data, err := json.Marshal(newJSONFile(f, rendered, annotation))
+	if err != nil {
+		return err
+	}
+	if a.count > 0 {
+		a.w.WriteByte(',')
+	}
+	a.count++
+	_, err = a.w.Write(data)
+	return err
+}
+
+func (a *jsonArtifact) finish(truncated string) error {
+	a.w.WriteString("]")
+	if truncated != "" {
+		fmt.Fprintf(a.w, ",\"truncated\":%q", truncated)
+	}
+	if len(a.languages) > 0 {
+		data, err := json.Marshal(a.languages)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.w, ",\"languages\":%s", data)
+	}
+	if len(a.skipped) > 0 {
+		data, err := json.Marshal(a.skipped)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.w, ",\"skipped\":%s", data)
+	}
+	if a.docCoverage != nil {
+		data, err := json.Marshal(a.docCoverage)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.w, ",\"docCoverage\":%s", data)
+	}
+	if a.owners != nil {
+		data, err := json.Marshal(a.owners)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.w, ",\"owners\":%s", data)
+	}
+	a.w.WriteString("}\n")
+	err := a.w.Flush()
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (a *jsonArtifact) path() string { return a.file.Name() }
+
+// jsonlArtifact writes one JSON object per file and line, in the shape of the
+// JSON artifact's "files" elements, so consumers can stream it. A truncated
+// run ends with a {"truncated": reason} line.
+type jsonlArtifact struct {
+	file *os.File
+	w    *bufio.Writer
+}
+
+func newJSONLArtifact(path string) (*jsonlArtifact, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlArtifact{file: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (a *jsonlArtifact) add(f fileEntry, raw, rendered []byte, annotation string) error {
+	data, err := json.Marshal(newJSONFile(f, rendered, annotation))
+	if err != nil {
+		return err
+	}
+	a.w.Write(data)
+	return a.w.WriteByte('\n')
+}
+
+func (a *jsonlArtifact) finish(truncated string) error {
+	if truncated != "" {
+		fmt.Fprintf(a.w, "{\"truncated\":%q}\n", truncated)
+	}
+	err := a.w.Flush()
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (a *jsonlArtifact) path() string { return a.file.Name() }
+
+// zipArtifact stores the bundled files unmodified under their relative
+// paths, giving a snapshot that matches the bundle exactly.
+type zipArtifact struct {
+	file *os.File
+	zw   *zip.Writer
+}
+
+func newZipArtifact(path string) (*zipArtifact, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &zipArtifact{file: f, zw: zip.NewWriter(f)}, nil
+}
+
+func (a *zipArtifact) add(f fileEntry, raw, rendered []byte, annotation string) error {
+	if raw == nil {
+		return nil // Placeholders have no local content to archive.
+	}
+	return addZipFile(a.zw, f.RelPath, raw)
+}
+
+// addZipFile stores content in zw under the slash form of relPath.
+func addZipFile(zw *zip.Writer, relPath string, content []byte) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     filepath.ToSlash(relPath),
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+func (a *zipArtifact) finish(truncated string) error {
+	if truncated != "" {
+		a.zw.SetComment("project-bundler: truncated: " + truncated)
+	}
+	err := a.zw.Close()
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (a *zipArtifact) path() string { return a.file.Name() }
@@ -0,0 +1,138 @@
+// project-bundler/fortests.go
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// testHelperDirs are directory names that hold shared test helpers and
+// fixtures; -for-tests includes them so the model follows the project's
+// existing conventions.
+var testHelperDirs = append([]string{"testutil", "testutils", "testhelpers", "testing", "__mocks__", "mocks"}, defaultFixtureDirs...)
+
+// testContextFiles are file names that configure the project or support its
+// whole test suite, such as module manifests that give the import paths.
+var testContextFiles = map[string]bool{
+	"go.mod":                   true,
+	"package.json":             true,
+	"pyproject.toml":           true,
+	"Cargo.toml":               true,
+	"pubspec.yaml":             true,
+	"conftest.py":              true,
+	"helpers_test.go":          true,
+	"main_test.go":             true,
+	"setup_test.go":            true,
+	"jest.config.js":           true,
+	"jest.config.ts":           true,
+	"vitest.config.ts":         true,
+	"pytest.ini":               true,
+	"flutter_test_config.dart": true,
+}
+
+// forTestsContext is the file selection for `-for-tests <dir>`: the target
+// package with its existing tests, the exported API of the project packages
+// it imports, and the project's test helpers and fixtures.
+type forTestsContext struct {
+	selected []fileEntry
+	apiOnly  stringSet // Relative paths of dependency files reduced to their API.
+	dropped  []string  // Paths left out of the context.
+}
+
+// selectForTests narrows the walked files to the test-writing context for
+// the target directory (relative to -src).
+func selectForTests(opts bundleOptions, files []fileEntry, target string) forTestsContext {
+	target = path.Clean(filepath.ToSlash(target))
+	inTarget := func(dir string) bool {
+		return target == "." || dir == target || strings.HasPrefix(dir, target+"/")
+	}
+
+	// Packages the target imports, one level deep.
+	deps := make(stringSet)
+	for from, tos := range buildDependencyGraph(opts, files, "packages") {
+		if !inTarget(from) {
+			continue
+		}
+		for to := range tos {
+			if !inTarget(to) {
+				deps[to] = struct{}{}
+			}
+		}
+	}
+
+	ctx := forTestsContext{apiOnly: make(stringSet)}
+	for _, f := range files {
+		rel := filepath.ToSlash(f.RelPath)
+		dir := path.Dir(rel)
+		switch {
+		case inTarget(dir), isTestHelper(rel):
+			ctx.selected = append(ctx.selected, f)
+		case deps.Contains(dir) && !isTestFile(rel):
+			ctx.selected = append(ctx.selected, f)
+			ctx.apiOnly[f.RelPath] = struct{}{}
+		default:
+			ctx.dropped = append(ctx.dropped, f.Path)
+		}
+	}
+	return ctx
+}
+
+// isTestHelper reports whether a file is a shared test helper or fixture.
+func isTestHelper(rel string) bool {
+	if testContextFiles[path.Base(rel)] {
+		return true
+	}
+	for _, part := range strings.Split(path.Dir(rel), "/") {
+		for _, dir := range testHelperDirs {
+			if part == dir {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isTestFile reports whether a file holds tests by the common naming
+// conventions of the supported languages.
+func isTestFile(rel string) bool {
+	name := path.Base(rel)
+	stem := strings.TrimSuffix(name, path.Ext(name))
+	return strings.HasSuffix(stem, "_test") || strings.HasPrefix(stem, "test_") ||
+		strings.HasSuffix(stem, ".test") || strings.HasSuffix(stem, ".spec") ||
+		strings.HasSuffix(stem, "Test") || strings.HasSuffix(stem, "Tests")
+}
+
+// goExportedAPI reduces Go source to its exported declarations without
+// function bodies, which is all a test author needs from a dependency.
+// Other languages, and Go that does not parse, are returned unchanged.
+func goExportedAPI(lang string, content []byte) ([]byte, bool) {
+	if lang != "go" {
+		return content, false
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return content, false
+	}
+	comments := ast.NewCommentMap(fset, file, file.Comments)
+	ast.FileExports(file)
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			fn.Body = nil
+		}
+	}
+	// Drop the comments of removed declarations and bodies.
+	file.Comments = comments.Filter(file).Comments()
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, file); err != nil {
+		return content, false
+	}
+	return buf.Bytes(), true
+}
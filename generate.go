@@ -0,0 +1,141 @@
+// project-bundler/generate.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// generatorConfigs are configuration files that drive code generators
+// without a //go:generate directive.
+var generatorConfigs = map[string]string{
+	"sqlc.yaml":         "sqlc generate",
+	"sqlc.yml":          "sqlc generate",
+	"sqlc.json":         "sqlc generate",
+	"buf.gen.yaml":      "buf generate",
+	"gqlgen.yml":        "go run github.com/99designs/gqlgen generate",
+	".mockery.yaml":     "mockery",
+	"oapi-codegen.yaml": "oapi-codegen -config oapi-codegen.yaml",
+}
+
+// generateStep is one way code gets regenerated: a //go:generate directive
+// or a generator configuration file.
+type generateStep struct {
+	dir       string // Directory the command runs in, relative to -src.
+	generator string
+	command   string
+	inputs    []string // Files the command reads, relative to dir.
+	source    string   // Where the step was found.
+}
+
+// findGenerateSteps collects the //go:generate directives of the bundled Go
+// files and the generator configuration files.
+func findGenerateSteps(files []fileEntry) []generateStep {
+	var steps []generateStep
+	for _, f := range files {
+		if f.Placeholder {
+			continue
+		}
+		rel := filepath.ToSlash(f.RelPath)
+		dir := path.Dir(rel)
+		if command, ok := generatorConfigs[path.Base(rel)]; ok {
+			steps = append(steps, generateStep{dir: dir, generator: strings.Fields(command)[0], command: command, inputs: []string{path.Base(rel)}, source: rel})
+			continue
+		}
+		if f.Lang != "go" {
+			continue
+		}
+		file, err := os.Open(f.Path)
+		if err != nil {
+			continue // Reported when the file itself is bundled.
+		}
+		scanner := bufio.NewScanner(file)
+		for line := 1; scanner.Scan(); line++ {
+			command, ok := strings.CutPrefix(scanner.Text(), "//go:generate ")
+			if !ok {
+				continue
+			}
+			command = strings.TrimSpace(command)
+			steps = append(steps, generateStep{
+				dir:       dir,
+				generator: generatorName(command),
+				command:   command,
+				inputs:    generateInputs(filepath.Dir(f.Path), command, path.Base(rel)),
+				source:    fmt.Sprintf("%s:%d", rel, line),
+			})
+		}
+		file.Close()
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].dir < steps[j].dir })
+	return steps
+}
+
+// generatorName extracts the tool from a generate command, looking through
+// "go run" and "go tool" wrappers: "go run go.uber.org/mock/mockgen@v0.4.0
+// -source=x.go" is mockgen.
+func generatorName(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) >= 3 && fields[0] == "go" && (fields[1] == "run" || fields[1] == "tool") {
+		for _, f := range fields[2:] {
+			if !strings.HasPrefix(f, "-") {
+				fields = []string{f}
+				break
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	name, _, _ := strings.Cut(path.Base(fields[0]), "@")
+	return name
+}
+
+// generateInputs lists the arguments of a generate command that name files
+// in the directive's directory, including "-flag=file" forms and globs such
+// as *.proto. Commands without file arguments (stringer) read the package,
+// represented by the file holding the directive.
+func generateInputs(dir, command, directiveFile string) []string {
+	var inputs []string
+	for _, arg := range strings.Fields(command) {
+		if _, value, ok := strings.Cut(arg, "="); ok && strings.HasPrefix(arg, "-") {
+			arg = value
+		}
+		arg = strings.Trim(arg, `"'`)
+		if arg == "" || strings.HasPrefix(arg, "-") {
+			continue
+		}
+		matches, _ := filepath.Glob(filepath.Join(dir, filepath.FromSlash(arg)))
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && !info.IsDir() {
+				rel, _ := filepath.Rel(dir, m)
+				inputs = appendUnique(inputs, filepath.ToSlash(rel))
+			}
+		}
+	}
+	if len(inputs) == 0 {
+		inputs = []string{directiveFile}
+	}
+	return inputs
+}
+
+// writeGenerateSection lists how to regenerate the generated code that was
+// left out of the bundle.
+func writeGenerateSection(w io.Writer, steps []generateStep, skipped int) error {
+	if len(steps) == 0 && skipped == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Generated code: %d generated files left out; regenerate with (%d steps):\n\n| Directory | Generator | Command | Inputs | Defined in |\n|---|---|---|---|---|\n", skipped, len(steps))
+	for _, s := range steps {
+		fmt.Fprintf(&b, "| `%s` | %s | `%s` | %s | `%s` |\n", s.dir, s.generator, strings.ReplaceAll(s.command, "|", `\|`), "`"+strings.Join(s.inputs, "`, `")+"`", s.source)
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
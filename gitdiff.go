@@ -0,0 +1,74 @@
+// project-bundler/gitdiff.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// gitChanges is the -git-diff result: the files that differ from a ref and,
+// with -git-diff-patch, the unified diff written as a section of the bundle.
+type gitChanges struct {
+	ref       string    // The ref as given, e.g. "main" or "HEAD~3".
+	paths     stringSet // Added and modified files, slash-separated and relative to -src.
+	withPatch bool      // Emit the diff as a section (-git-diff-patch).
+	patch     string    // The unified diff; "" when nothing tracked changed.
+}
+
+// computeGitChanges lists the files under srcDir that were added or modified
+// since ref, counting uncommitted changes and untracked files that are not
+// ignored. Deleted files have nothing to bundle and only show in the patch.
+func computeGitChanges(srcDir, ref string, withPatch bool) (*gitChanges, error) {
+	changes := &gitChanges{ref: ref, paths: make(stringSet), withPatch: withPatch}
+	diff, err := gitOutput(srcDir, "diff", "-z", "--name-only", "--relative", "--no-renames", "--diff-filter=d", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := gitOutput(srcDir, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range strings.Split(diff+"\x00"+untracked, "\x00") {
+		if p != "" {
+			changes.paths[p] = struct{}{}
+		}
+	}
+	if withPatch {
+		if changes.patch, err = gitOutput(srcDir, "diff", "--relative", ref, "--"); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// filterChanged keeps the files changed since the ref and returns the
+// others separately, for the skipped-files report.
+func (c *gitChanges) filterChanged(files []fileEntry) (changed []fileEntry, unchanged []string) {
+	for _, f := range files {
+		if c.paths.Contains(filepath.ToSlash(f.RelPath)) {
+			changed = append(changed, f)
+		} else {
+			unchanged = append(unchanged, f.Path)
+		}
+	}
+	return changed, unchanged
+}
+
+// writeGitDiffSection emits the unified diff against the ref ahead of the
+// changed files. Untracked files are not part of it; they are bundled whole.
+func writeGitDiffSection(w io.Writer, c *gitChanges) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Changes since %s (%d files added or modified):\n\n", c.ref, len(c.paths))
+	if c.patch == "" {
+		b.WriteString("No changes to tracked files.\n\n")
+	} else {
+		fence := strings.Repeat("`", bundler.FenceLength([]byte(c.patch), '`'))
+		fmt.Fprintf(&b, "%sdiff\n%s\n%s\n\n", fence, c.patch, fence)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
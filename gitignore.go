@@ -0,0 +1,31 @@
+// project-bundler/gitignore.go
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// globalGitExcludes reads the user's global gitignore patterns: git's
+// core.excludesFile, or $XDG_CONFIG_HOME/git/ignore (~/.config/git/ignore)
+// when that is unset, as git itself does. A missing file yields nothing.
+func globalGitExcludes(srcDir string) []string {
+	path, err := gitOutput(srcDir, "config", "--path", "core.excludesFile")
+	if err != nil || path == "" {
+		config := os.Getenv("XDG_CONFIG_HOME")
+		if config == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil
+			}
+			config = filepath.Join(home, ".config")
+		}
+		path = filepath.Join(config, "git", "ignore")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
@@ -0,0 +1,171 @@
+// project-bundler/grep.go
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// grepOptions controls how runGrep matches and reports lines.
+type grepOptions struct {
+	before, after int
+	filesOnly     bool
+	count         bool
+	invert        bool
+	prefix        string // Bundle name and ":" when searching several bundles.
+	printed       bool   // A group of lines was printed; the next starts with "--".
+}
+
+// runGrep implements the `grep` subcommand, which searches the files in a
+// bundle and reports matches by file path and line within the file, so the
+// tree does not have to be unbundled first. Like grep(1) it exits with
+// status 1 when nothing matched.
+//
+//	project-bundler grep -i -C 2 "func main" bundle.md
+func runGrep(args []string) {
+	fs := flag.NewFlagSet("grep", flag.ExitOnError)
+	fixed := fs.Bool("F", false, "Treat the pattern as a literal string instead of a regular expression.")
+	ignoreCase := fs.Bool("i", false, "Match case-insensitively.")
+	word := fs.Bool("w", false, "Match only whole words.")
+	invert := fs.Bool("v", false, "Report the lines that do not match.")
+	context := fs.Int("C", 0, "Lines of context to show around each match.")
+	before := fs.Int("B", 0, "Lines of context to show before each match (overrides -C).")
+	after := fs.Int("A", 0, "Lines of context to show after each match (overrides -C).")
+	filesOnly := fs.Bool("l", false, "Print only the paths of files with a match.")
+	count := fs.Bool("c", false, "Print only the number of matching lines per file.")
+	pathGlob := fs.String("path", "", "Search only files whose path matches this glob, with the syntax of -ignore-paths, e.g. 'pkg/**/*.go'.")
+	styleName := fs.String("style", "github", "Output style the bundle was written with. Options: "+strings.Join(bundler.StyleNames(), ", "))
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		log.Fatalf("Usage: project-bundler grep [flags] <pattern> <bundle>...")
+	}
+	style, ok := bundler.Styles[*styleName]
+	if !ok {
+		log.Fatalf("Invalid style '%s'. Available styles are: %s", *styleName, strings.Join(bundler.StyleNames(), ", "))
+	}
+	if *context < 0 || *before < 0 || *after < 0 {
+		log.Fatalf("-A, -B and -C must not be negative.")
+	}
+	if *pathGlob != "" {
+		if _, err := path.Match(*pathGlob, ""); err != nil {
+			log.Fatalf("Invalid -path glob '%s': %v", *pathGlob, err)
+		}
+	}
+
+	expr := fs.Arg(0)
+	if *fixed {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if *word {
+		expr = `\b(?:` + expr + `)\b`
+	}
+	if *ignoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		log.Fatalf("Invalid pattern: %v", err)
+	}
+
+	opts := grepOptions{before: *context, after: *context, filesOnly: *filesOnly, count: *count, invert: *invert}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "B":
+			opts.before = *before
+		case "A":
+			opts.after = *after
+		}
+	})
+	paths := bundler.RuleSet{*pathGlob: "-path"}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	matched := false
+	for _, bundlePath := range fs.Args()[1:] {
+		if _, err := os.Stat(bundlePath); err != nil {
+			log.Fatalf("Could not read bundle: %v", err)
+		}
+		// Replaying also covers journals: only each file's latest content counts.
+		state, err := replayJournal(bundlePath, style, nil)
+		if err != nil {
+			log.Fatalf("Could not parse bundle '%s': %v", bundlePath, err)
+		}
+		if fs.NArg() > 2 {
+			opts.prefix = bundlePath + ":"
+		}
+		for _, b := range state.live() {
+			if *pathGlob != "" {
+				if _, ok := paths.MatchingGlob(filepath.FromSlash(b.Path)); !ok {
+					continue
+				}
+			}
+			if grepBlock(w, re, b, &opts) {
+				matched = true
+			}
+		}
+	}
+	w.Flush()
+	if !matched {
+		os.Exit(1)
+	}
+}
+
+// grepBlock reports the matching lines of one file block, grep style:
+// "path:line:text" for matches and "path-line-text" for context, with "--"
+// between groups that are not adjacent, also across files. It reports whether any line matched.
+func grepBlock(w *bufio.Writer, re *regexp.Regexp, b bundler.Block, opts *grepOptions) bool {
+	lines := strings.Split(strings.TrimSuffix(string(b.Content), "\n"), "\n")
+	var hits []int
+	for i, line := range lines {
+		if re.MatchString(line) != opts.invert {
+			hits = append(hits, i)
+		}
+	}
+	if len(hits) == 0 {
+		if opts.count {
+			fmt.Fprintf(w, "%s%s:0\n", opts.prefix, b.Path)
+		}
+		return false
+	}
+	switch {
+	case opts.filesOnly:
+		fmt.Fprintf(w, "%s%s\n", opts.prefix, b.Path)
+		return true
+	case opts.count:
+		fmt.Fprintf(w, "%s%s:%d\n", opts.prefix, b.Path, len(hits))
+		return true
+	}
+
+	next := 0 // First line not yet printed.
+	for h, i := range hits {
+		from := max(i-opts.before, next)
+		if opts.printed && (h == 0 || from > next) && (opts.before > 0 || opts.after > 0) {
+			fmt.Fprintln(w, "--")
+		}
+		opts.printed = true
+		for j := from; j < i; j++ {
+			fmt.Fprintf(w, "%s%s-%d-%s\n", opts.prefix, b.Path, j+1, lines[j])
+		}
+		fmt.Fprintf(w, "%s%s:%d:%s\n", opts.prefix, b.Path, i+1, lines[i])
+		next = i + 1
+		// Trailing context stops at the next match, which prints itself.
+		to := min(i+opts.after, len(lines)-1)
+		if h+1 < len(hits) {
+			to = min(to, hits[h+1]-1)
+		}
+		for j := next; j <= to; j++ {
+			fmt.Fprintf(w, "%s%s-%d-%s\n", opts.prefix, b.Path, j+1, lines[j])
+		}
+		next = max(next, to+1)
+	}
+	return true
+}
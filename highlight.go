@@ -0,0 +1,158 @@
+// project-bundler/highlight.go
+package main
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// highlightTheme colors highlighted code in html output. Token classes use
+// Chroma's short names: c for comments, s for strings, m for numbers and k
+// for keywords.
+type highlightTheme struct {
+	background, text string
+	classes          map[string]string // CSS declarations by token class.
+}
+
+var highlightThemes = map[string]highlightTheme{
+	"github": {background: "#f6f8fa", text: "#1f2328", classes: map[string]string{
+		"c": "color: #6e7781; font-style: italic", "s": "color: #0a3069", "m": "color: #0550ae", "k": "color: #cf222e",
+	}},
+	"monokai": {background: "#272822", text: "#f8f8f2", classes: map[string]string{
+		"c": "color: #75715e", "s": "color: #e6db74", "m": "color: #ae81ff", "k": "color: #f92672",
+	}},
+}
+
+// highlightThemeNames returns the names of the themes in lexical order.
+func highlightThemeNames() []string {
+	var names []string
+	for name := range highlightThemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// css returns the style sheet rules of the theme.
+func (t highlightTheme) css() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pre { background: %s; color: %s; }\n", t.background, t.text)
+	for _, class := range []string{"c", "s", "m", "k"} {
+		fmt.Fprintf(&b, "pre .%s { %s; }\n", class, t.classes[class])
+	}
+	return b.String()
+}
+
+// highlightSyntax is what the highlighter knows about a language: how it
+// writes comments and strings (as for -strip-comments) and its keywords.
+type highlightSyntax struct {
+	commentSyntax
+	keywords stringSet
+}
+
+func newHighlightSyntax(comments commentSyntax, keywords string) highlightSyntax {
+	s := highlightSyntax{commentSyntax: comments, keywords: make(stringSet)}
+	for _, k := range strings.Fields(keywords) {
+		s.keywords[k] = struct{}{}
+	}
+	return s
+}
+
+const (
+	cKeywords    = "auto break case char const continue default do double else enum extern float for goto if inline int long register return short signed sizeof static struct switch typedef union unsigned void volatile while"
+	javaKeywords = "abstract assert boolean break byte case catch char class const continue default do double else enum extends final finally float for goto if implements import instanceof int interface long native new null package private protected public return short static super switch synchronized this throw throws transient true false try void volatile while var record"
+	jsKeywords   = "async await break case catch class const continue debugger default delete do else export extends false finally for from function if import in instanceof let new null of return static super switch this throw true try typeof undefined var void while yield as interface type enum implements private protected public readonly"
+)
+
+// highlightSyntaxes are the languages html output highlights. Others are
+// shown as plain text.
+var highlightSyntaxes = map[string]highlightSyntax{
+	"go":         newHighlightSyntax(goComment, "break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var true false nil iota"),
+	"java":       newHighlightSyntax(cLikeComment, javaKeywords),
+	"groovy":     newHighlightSyntax(cLikeComment, javaKeywords+" def in as trait"),
+	"csharp":     newHighlightSyntax(cLikeComment, "abstract as async await base bool break byte case catch char class const continue decimal default delegate do double else enum event false finally float for foreach get if in int interface internal is lock long namespace new null object out override params private protected public readonly record ref return sealed set short static string struct switch this throw true try typeof uint ulong using var virtual void while yield"),
+	"dart":       newHighlightSyntax(cLikeComment, "abstract as async await break case catch class const continue default do else enum extends false final finally for if import in is late library new null required return static super switch this throw true try var void while with yield"),
+	"kotlin":     newHighlightSyntax(kotlinComment, "as break class companion continue data do else false for fun if import in interface is null object override package private protected public return sealed super this throw true try typealias val var when while"),
+	"swift":      newHighlightSyntax(swiftComment, "as break case catch class continue default defer do else enum extension false for func guard if import in init let nil private protocol public return self static struct super switch throw throws true try var where while"),
+	"rust":       newHighlightSyntax(rustComment, "as async await break const continue crate dyn else enum extern false fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use where while"),
+	"c":          newHighlightSyntax(cLikeComment, cKeywords),
+	"cpp":        newHighlightSyntax(cLikeComment, cKeywords+" bool catch class constexpr delete false namespace new nullptr operator private protected public template this throw true try typename using virtual"),
+	"objectivec": newHighlightSyntax(cLikeComment, cKeywords+" @interface @implementation @end @property @protocol id nil self super YES NO"),
+	"javascript": newHighlightSyntax(jsComment, jsKeywords),
+	"typescript": newHighlightSyntax(jsComment, jsKeywords),
+	"python":     newHighlightSyntax(pythonComment, "and as assert async await break class continue def del elif else except False finally for from global if import in is lambda None nonlocal not or pass raise return True try while with yield"),
+	"shell":      newHighlightSyntax(shellComment, "case do done elif else esac export fi for function if in local return then until while"),
+	"css":        newHighlightSyntax(cssComment, ""),
+	"scss":       newHighlightSyntax(cLikeComment, ""),
+	"less":       newHighlightSyntax(cLikeComment, ""),
+}
+
+// highlightHTML renders code as HTML, wrapping comments, strings, numbers
+// and keywords in spans of their token class. Code in a language without a
+// highlightSyntax is only escaped.
+func highlightHTML(lang, code string) string {
+	s, ok := highlightSyntaxes[lang]
+	if !ok {
+		return html.EscapeString(code)
+	}
+	var b strings.Builder
+	span := func(class, text string) {
+		b.WriteString(`<span class="` + class + `">` + html.EscapeString(text) + "</span>")
+	}
+	lineStart, plain := 0, 0 // Start of the current line, and of the text not written yet.
+	flush := func(i int) {
+		b.WriteString(html.EscapeString(code[plain:i]))
+	}
+	for i := 0; i < len(code); {
+		c := code[i]
+		end := i
+		class := ""
+		switch {
+		case c == '\n':
+			lineStart = i + 1
+		case s.lineCommentAt(code, i, []byte(code[lineStart:i])):
+			end = strings.IndexByte(code[i:], '\n')
+			if end < 0 {
+				end = len(code) - i
+			}
+			end, class = i+end, "c"
+		default:
+			if q, ok := s.quoteAt(code, i); ok {
+				end, class = closeQuote(code, i+len(q.open), q), "s"
+			} else if open, close, ok := s.blockCommentAt(code, i); ok {
+				end, class = s.closeBlock(code, i+len(open), open, close), "c"
+			} else if isWordByte(c) && (i == 0 || !isWordByte(code[i-1])) {
+				for end = i + 1; end < len(code) && isWordByte(code[end]); end++ {
+				}
+				switch {
+				case c >= '0' && c <= '9':
+					class = "m"
+				case s.keywords.Contains(code[i:end]) || i > 0 && code[i-1] == '@' && s.keywords.Contains(code[i-1:end]):
+					class = "k"
+				}
+			}
+		}
+		if class == "" {
+			i = max(end, i+1)
+			continue
+		}
+		if class == "k" && i > 0 && code[i-1] == '@' {
+			i-- // Objective-C's @interface and the like.
+		}
+		flush(i)
+		span(class, code[i:end])
+		if nl := strings.LastIndexByte(code[i:end], '\n'); nl >= 0 {
+			lineStart = i + nl + 1
+		}
+		i, plain = end, end
+	}
+	flush(len(code))
+	return b.String()
+}
+
+// isWordByte reports whether c can be part of an identifier or number.
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
@@ -0,0 +1,269 @@
+// project-bundler/i18n.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// catalogs holds the translated CLI messages keyed by language and message
+// key. English is complete and is the fallback for missing translations.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"autodetected":       "Auto-detected project type: %s\n",
+		"autodetect-failed":  "Could not auto-detect project type, using 'generic' defaults.\n",
+		"custom-ignore-dirs": "Using custom ignore-dirs list from command-line flag.\n",
+		"custom-ignore-exts": "Using custom ignore-exts list from command-line flag.\n",
+		"starting":           "Starting to bundle project from '%s' into '%s' (type: %s)...\n",
+		"also-bundling":      "Also bundling '%s' under '%s/' (type: %s).\n",
+		"bundling-file":      "  + Bundling file: %s\n",
+		"changes-written":    "Wrote changes since last bundle to '%s'\n",
+		"chunks-written":     "Wrote chunk IDs to '%s'\n",
+		"sourcemap-written":  "Wrote source map to '%s'\n",
+		"prompts-written":    "Wrote %d question prompts, '%s' to '%s'\n",
+		"incremental-reused": "Reused %d of %d files from the incremental cache '%s'\n",
+		"watching":           "\n👀 Watching '%s' for changes every %s; press Ctrl+C to stop.\n",
+		"watch-rebundling":   "\n🔄 Detected %d file changes; writing the bundle again.\n",
+		"partial":            "\n⚠️  Wrote partial project bundle at '%s': %s\n",
+		"success":            "\n✅ Successfully created project bundle at '%s'\n",
+		"stdout-label":       "standard output",
+		"clipboard-label":    "clipboard",
+		"clipboard-copied":   "📋 Copied the bundle (%s) to the clipboard.\n",
+		"skipped-header":     "\n--- Skipped Files Report ---\n",
+		"no-skipped":         "No files were skipped.\n",
+		"skip-reason":        "\nReason: %s [%s]\n",
+		"token-estimate":     "Estimated size: %d tokens (%s tokenizer)\n",
+		"cost-estimate":      "Estimated input cost: %s (at $%.2f per million tokens)\n",
+		"cost-estimate-part": "  %s: %d tokens, %s\n",
+		"languages":          "Languages: %s\n",
+		"over-token-budget":  "⚠️  The bundle is an estimated %d tokens, over the -max-tokens budget of %d.\n",
+		"journal-appended":   "Appended journal record %d to '%s': %d changed, %d removed\n",
+		"journal-unchanged":  "No changes since the last journal record in '%s'; nothing appended\n",
+		"secrets-redacted":   "\n⚠️  Redacted %d likely secrets:\n",
+		"secrets-found":      "\n⚠️  Found %d likely secrets:\n",
+		"truncated-header":   "\n--- Truncated Files (over -max-file-size) ---\n",
+		"over-budget-header": "\n--- Files Cut to Fit Their Budgets ---\n",
+		"classification":     "Classification: %s (%d public, %d internal, %d sensitive files)\n",
+		"packed":             "Packed %d of %d files into the -max-tokens budget of %d; the %d files left out are listed at the end of the bundle.\n",
+	},
+	"de": {
+		"autodetected":       "Projekttyp automatisch erkannt: %s\n",
+		"autodetect-failed":  "Projekttyp konnte nicht erkannt werden, verwende die Standardwerte von 'generic'.\n",
+		"custom-ignore-dirs": "Verwende die ignore-dirs-Liste aus der Befehlszeile.\n",
+		"custom-ignore-exts": "Verwende die ignore-exts-Liste aus der Befehlszeile.\n",
+		"starting":           "Bündle das Projekt aus '%s' nach '%s' (Typ: %s)...\n",
+		"also-bundling":      "Bündle außerdem '%s' unter '%s/' (Typ: %s).\n",
+		"bundling-file":      "  + Bündle Datei: %s\n",
+		"changes-written":    "Änderungen seit dem letzten Bundle nach '%s' geschrieben\n",
+		"chunks-written":     "Chunk-IDs nach '%s' geschrieben\n",
+		"sourcemap-written":  "Source-Map nach '%s' geschrieben\n",
+		"prompts-written":    "%d Frage-Prompts geschrieben, '%s' bis '%s'\n",
+		"incremental-reused": "%d von %d Dateien aus dem inkrementellen Cache '%s' wiederverwendet\n",
+		"watching":           "\n👀 Überwache '%s' alle %s auf Änderungen; Strg+C beendet.\n",
+		"watch-rebundling":   "\n🔄 %d Dateiänderungen erkannt; schreibe das Bundle neu.\n",
+		"partial":            "\n⚠️  Unvollständiges Projekt-Bundle nach '%s' geschrieben: %s\n",
+		"success":            "\n✅ Projekt-Bundle erfolgreich unter '%s' erstellt\n",
+		"stdout-label":       "Standardausgabe",
+		"clipboard-label":    "Zwischenablage",
+		"clipboard-copied":   "📋 Bundle (%s) in die Zwischenablage kopiert.\n",
+		"skipped-header":     "\n--- Bericht übersprungener Dateien ---\n",
+		"no-skipped":         "Es wurden keine Dateien übersprungen.\n",
+		"skip-reason":        "\nGrund: %s [%s]\n",
+		"token-estimate":     "Geschätzte Größe: %d Tokens (Tokenizer %s)\n",
+		"cost-estimate":      "Geschätzte Eingabekosten: %s (bei $%.2f pro Million Tokens)\n",
+		"cost-estimate-part": "  %s: %d Tokens, %s\n",
+		"languages":          "Sprachen: %s\n",
+		"over-token-budget":  "⚠️  Das Bündel umfasst geschätzt %d Tokens und überschreitet das -max-tokens-Budget von %d.\n",
+		"journal-appended":   "Journal-Eintrag %d an '%s' angehängt: %d geändert, %d entfernt\n",
+		"journal-unchanged":  "Keine Änderungen seit dem letzten Journal-Eintrag in '%s'; nichts angehängt\n",
+		"secrets-redacted":   "\n⚠️  %d mutmaßliche Geheimnisse geschwärzt:\n",
+		"secrets-found":      "\n⚠️  %d mutmaßliche Geheimnisse gefunden:\n",
+		"truncated-header":   "\n--- Gekürzte Dateien (über -max-file-size) ---\n",
+		"over-budget-header": "\n--- Auf ihr Budget gekürzte Dateien ---\n",
+		"classification":     "Einstufung: %s (%d öffentliche, %d interne, %d sensible Dateien)\n",
+		"packed":             "%d von %d Dateien in das -max-tokens-Budget von %d gepackt; die %d ausgelassenen Dateien stehen am Ende des Bundles.\n",
+	},
+	"ja": {
+		"autodetected":       "プロジェクトの種類を自動検出しました: %s\n",
+		"autodetect-failed":  "プロジェクトの種類を検出できなかったため、'generic' の既定値を使用します。\n",
+		"custom-ignore-dirs": "コマンドラインで指定された ignore-dirs リストを使用します。\n",
+		"custom-ignore-exts": "コマンドラインで指定された ignore-exts リストを使用します。\n",
+		"starting":           "'%s' のプロジェクトを '%s' にバンドルしています (種類: %s)...\n",
+		"also-bundling":      "'%s' も '%s/' の下にバンドルします (種類: %s)。\n",
+		"bundling-file":      "  + ファイルをバンドル中: %s\n",
+		"changes-written":    "前回のバンドル以降の変更を '%s' に書き込みました\n",
+		"chunks-written":     "チャンク ID を '%s' に書き込みました\n",
+		"sourcemap-written":  "ソースマップを '%s' に書き込みました\n",
+		"prompts-written":    "%d 件の質問プロンプトを書き込みました（'%s' から '%s'）\n",
+		"incremental-reused": "インクリメンタルキャッシュ '%[3]s' から %[1]d / %[2]d ファイルを再利用しました\n",
+		"watching":           "\n👀 '%s' の変更を %s ごとに監視しています。Ctrl+C で終了します。\n",
+		"watch-rebundling":   "\n🔄 %d 件のファイル変更を検出しました。バンドルを書き直します。\n",
+		"partial":            "\n⚠️  部分的なプロジェクトバンドルを '%s' に書き込みました: %s\n",
+		"success":            "\n✅ プロジェクトバンドルを '%s' に作成しました\n",
+		"stdout-label":       "標準出力",
+		"clipboard-label":    "クリップボード",
+		"clipboard-copied":   "📋 バンドル (%s) をクリップボードにコピーしました。\n",
+		"skipped-header":     "\n--- スキップされたファイルのレポート ---\n",
+		"no-skipped":         "スキップされたファイルはありません。\n",
+		"skip-reason":        "\n理由: %s [%s]\n",
+		"token-estimate":     "推定サイズ: %d トークン (%s トークナイザー)\n",
+		"cost-estimate":      "推定入力コスト: %s (100万トークンあたり $%.2f)\n",
+		"cost-estimate-part": "  %s: %d トークン, %s\n",
+		"languages":          "言語: %s\n",
+		"over-token-budget":  "⚠️  バンドルは推定 %d トークンで、-max-tokens の上限 %d を超えています。\n",
+		"journal-appended":   "ジャーナルレコード %d を '%s' に追加しました: 変更 %d 件、削除 %d 件\n",
+		"journal-unchanged":  "'%s' の最後のジャーナルレコード以降に変更はありません。何も追加していません\n",
+		"secrets-redacted":   "\n⚠️  機密情報と思われる %d 件を伏せ字にしました:\n",
+		"secrets-found":      "\n⚠️  機密情報と思われるものが %d 件見つかりました:\n",
+		"truncated-header":   "\n--- 切り詰めたファイル (-max-file-size 超過) ---\n",
+		"over-budget-header": "\n--- 予算に合わせて削ったファイル ---\n",
+		"classification":     "分類: %s (public %d 件、internal %d 件、sensitive %d 件)\n",
+		"packed":             "%[2]d 件中 %[1]d 件のファイルを -max-tokens の予算 %[3]d に収めました。除外した %[4]d 件はバンドルの末尾に一覧があります。\n",
+	},
+}
+
+// reasonCatalogs translates the descriptions of skip reason codes; the
+// English ones are bundler.ReasonText and cliReasonText.
+var reasonCatalogs = map[string]map[string]string{
+	"de": {
+		"IGNORED_DIR":           "Ignoriertes Verzeichnis",
+		"IGNORED_EXT":           "Ignorierte Endung/Datei",
+		"IGNORED_SUFFIX":        "Ignoriertes Suffix",
+		"IGNORED_PATH":          "Ignorierter Pfad",
+		"POLICY":                "Nicht in der Positivliste",
+		"SPECIAL_FILE":          "Spezialdatei",
+		"ERROR_READ":            "Lesefehler",
+		"CLOUD_PLACEHOLDER":     "Cloud-Platzhalter",
+		"BINARY":                "Binärinhalt erkannt",
+		"DUPLICATE_DIR":         "Doppeltes Verzeichnis",
+		"OTHER_FILESYSTEM":      "Anderes Dateisystem",
+		"OUTSIDE_TEST_CONTEXT":  "Außerhalb des Testkontexts",
+		"OUTSIDE_FOCUS":         "Nicht vom Fokus importiert",
+		"OUTSIDE_FLAKY_CONTEXT": "Außerhalb des Kontexts des instabilen Tests",
+		"TOO_LARGE":             "Über der Größengrenze",
+		"TOO_OLD":               "Älter als die Altersgrenze",
+		"TOO_NEW":               "Neuer als die Altersgrenze",
+		"BUNDLE_OUTPUT":         "Ausgabe des Bundles",
+		"UNCHANGED":             "Seit Referenz unverändert",
+		"OVER_CLASSIFICATION":   "Über der höchsten Einstufung",
+		"OVER_TOKEN_BUDGET":     "Passte nicht ins Token-Budget",
+		"BUILD_CONSTRAINTS":     "Build-Constraints",
+		"GENERATED":             "Generierter Code",
+		"MINIFIED":              "Minifizierter Code",
+		"IGNORE_MARKER":         "Markierung bundler:ignore",
+		"GITIGNORED":            "Von Git ignoriert",
+		"BUNDLERIGNORED":        "Von .bundlerignore ignoriert",
+		"SECRET":                "Geheimnis-Datei",
+	},
+	"ja": {
+		"IGNORED_DIR":           "無視されたディレクトリ",
+		"IGNORED_EXT":           "無視された拡張子/ファイル",
+		"IGNORED_SUFFIX":        "無視されたサフィックス",
+		"IGNORED_PATH":          "無視されたパス",
+		"POLICY":                "許可リスト外",
+		"SPECIAL_FILE":          "特殊ファイル",
+		"ERROR_READ":            "ファイル読み取りエラー",
+		"CLOUD_PLACEHOLDER":     "クラウドのプレースホルダー",
+		"BINARY":                "バイナリ内容を検出",
+		"DUPLICATE_DIR":         "重複したディレクトリ",
+		"OTHER_FILESYSTEM":      "別のファイルシステム",
+		"OUTSIDE_TEST_CONTEXT":  "テストコンテキスト外",
+		"OUTSIDE_FOCUS":         "フォーカス対象からインポートされていない",
+		"OUTSIDE_FLAKY_CONTEXT": "不安定なテストのコンテキスト外",
+		"TOO_LARGE":             "サイズ上限超過",
+		"TOO_OLD":               "期間上限より古い",
+		"TOO_NEW":               "期間下限より新しい",
+		"BUNDLE_OUTPUT":         "バンドルの出力",
+		"UNCHANGED":             "参照以降変更なし",
+		"OVER_CLASSIFICATION":   "分類の上限超過",
+		"OVER_TOKEN_BUDGET":     "トークン予算に収まらない",
+		"BUILD_CONSTRAINTS":     "ビルド制約",
+		"GENERATED":             "生成されたコード",
+		"MINIFIED":              "ミニファイされたコード",
+		"IGNORE_MARKER":         "bundler:ignore マーカー",
+		"GITIGNORED":            "Git で無視",
+		"BUNDLERIGNORED":        ".bundlerignore で無視",
+		"SECRET":                "機密ファイル",
+	},
+}
+
+// locale is the active catalog language.
+var locale = "en"
+
+// availableLocales returns the languages that have a message catalog.
+func availableLocales() []string {
+	var langs []string
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// localeFromEnv derives the language from the POSIX locale variables, in
+// their order of precedence, e.g. "de_DE.UTF-8" -> "de".
+func localeFromEnv() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// setLocale selects the catalog for a language tag such as "ja",
+// "de-DE" or "ja_JP.UTF-8". Unknown languages fall back to English.
+func setLocale(tag string) {
+	lang := strings.ToLower(tag)
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+	if _, ok := catalogs[lang]; ok {
+		locale = lang
+	} else {
+		locale = "en"
+	}
+}
+
+// tr returns the active translation of a message key, falling back to
+// English and then to the key itself.
+func tr(key string) string {
+	if s, ok := catalogs[locale][key]; ok {
+		return s
+	}
+	if s, ok := catalogs["en"][key]; ok {
+		return s
+	}
+	return key
+}
+
+// trReason returns the active translation of a skip reason code's
+// description.
+func trReason(code string) string {
+	if s, ok := reasonCatalogs[locale][code]; ok {
+		return s
+	}
+	return reasonDescription(code)
+}
+
+// plainOutput disables emoji and other decorative symbols (-plain) so the
+// output reads well in screen readers and log files. Dumb terminals get
+// plain output by default.
+var plainOutput = os.Getenv("TERM") == "dumb"
+
+var plainReplacer = strings.NewReplacer("✅ ", "", "📋 ", "", "👀 ", "", "🔄 ", "", "⚠️  ", "WARNING: ", "✔", "OK", "✖", "FAIL")
+
+// plainText strips decorations from s when plain output is enabled.
+func plainText(s string) string {
+	if plainOutput {
+		return plainReplacer.Replace(s)
+	}
+	return s
+}
+
+// printMsg prints a translated message; its format verbs match the English.
+func printMsg(key string, args ...any) {
+	fmt.Print(colorize(messageColors[key], plainText(fmt.Sprintf(tr(key), args...))))
+}
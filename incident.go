@@ -0,0 +1,364 @@
+// project-bundler/incident.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// incidentTimeLayouts are the absolute times -since and -until accept, read
+// in the local time zone unless they carry one.
+var incidentTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+// configExts and configNames pick out the configuration files of a service:
+// what it is deployed with and reads at startup.
+var (
+	configExts  = []string{".yaml", ".yml", ".toml", ".json", ".ini", ".conf", ".cfg", ".properties", ".env", ".tf", ".tfvars", ".hcl"}
+	configNames = []string{"Dockerfile", "Containerfile", "Procfile", "docker-compose*", "compose.y*ml", "*.env.example", ".env.*"}
+)
+
+// incidentBundle holds what the sections of an incident bundle are built
+// from.
+type incidentBundle struct {
+	opts         bundleOptions
+	files        []fileEntry
+	since, until time.Time
+	goodRef      string // "" when no commit predates the window.
+	goodLabel    string // How goodRef was chosen, for the header.
+	changes      *gitChanges
+	written      stringSet // RelPaths already written in full.
+	redactions   int
+}
+
+// runIncident implements the `incident` subcommand, which bundles what an
+// on-call engineer needs to reason about a failing service: the changes
+// deployed in a time window, the diff since the last known-good tag, the
+// service's configuration and the supplied log or trace excerpts. Secrets
+// are always redacted, since logs and configs are where they end up.
+//
+//	project-bundler incident -since 6h -log api.log services/api
+func runIncident(args []string) {
+	fs := flag.NewFlagSet("incident", flag.ExitOnError)
+	outputFile := fs.String("output", "incident.md", "Output markdown file.")
+	projectType := fs.String("type", "auto", "Project type. Options: "+strings.Join(availableProjectTypes(), ", "))
+	sinceStr := fs.String("since", "24h", "Start of the incident window: an age such as 6h or 2d, or a time such as '2006-01-02 15:04'.")
+	untilStr := fs.String("until", "", "End of the incident window, in the same forms as -since. Defaults to now.")
+	goodRef := fs.String("good-ref", "", "Last known-good revision to diff against. Defaults to the latest tag on the last commit before the window, or that commit if it has no tag.")
+	var logs stringsFlag
+	fs.Var(&logs, "log", "Log or trace excerpt to include; '-' reads standard input. May be repeated.")
+	logLines := fs.Int("log-lines", 500, "Keep only the last N lines of each log excerpt; 0 keeps them all.")
+	styleName := fs.String("style", "github", "Output style. Options: "+strings.Join(bundler.StyleNames(), ", "))
+	fs.Parse(args)
+	resultPath = *outputFile
+	if fs.NArg() > 1 {
+		fatalf("Usage: project-bundler incident [flags] [service-path]")
+	}
+	serviceDir := "."
+	if fs.NArg() == 1 {
+		serviceDir = fs.Arg(0)
+	}
+	if *logLines < 0 {
+		fatalf("-log-lines must not be negative.")
+	}
+
+	now := time.Now()
+	since, err := parseIncidentTime(*sinceStr, now)
+	if err != nil {
+		fatalf("Invalid -since: %v", err)
+	}
+	until := now
+	if *untilStr != "" {
+		if until, err = parseIncidentTime(*untilStr, now); err != nil {
+			fatalf("Invalid -until: %v", err)
+		}
+	}
+	if !since.Before(until) {
+		fatalf("-since must be before -until.")
+	}
+
+	opts, err := resolveOptions(serviceDir, *projectType, "", "", false)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	style, ok := bundler.Styles[*styleName]
+	if !ok {
+		fatalf("Invalid style '%s'. Available styles are: %s", *styleName, strings.Join(bundler.StyleNames(), ", "))
+	}
+	opts.Style = style
+	if _, err := gitOutput(serviceDir, "rev-parse", "--git-dir"); err != nil {
+		fatalf("'%s' is not in a git repository; an incident bundle is built from its history: %v", serviceDir, err)
+	}
+
+	inc := &incidentBundle{opts: opts, since: since, until: until, goodRef: *goodRef, written: make(stringSet)}
+	if inc.goodRef != "" {
+		if _, err := resolveRevision(serviceDir, inc.goodRef); err != nil {
+			fatalf("Invalid -good-ref: %v", err)
+		}
+		inc.goodLabel = "given with -good-ref"
+	} else {
+		inc.goodRef, inc.goodLabel = lastKnownGood(serviceDir, since)
+	}
+	if inc.goodRef != "" {
+		if inc.changes, err = computeGitChanges(serviceDir, inc.goodRef, true); err != nil {
+			fatalf("Could not diff against '%s': %v", inc.goodRef, err)
+		}
+	}
+	var skipped map[string][]string
+	if inc.files, skipped, err = collectFiles(opts); err != nil {
+		fatalf("Error during directory walk: %v", err)
+	}
+
+	var b bytes.Buffer
+	if err := inc.write(&b, serviceDir, logs, *logLines); err != nil {
+		fatalf("Could not write incident bundle: %v", err)
+	}
+	if err := os.WriteFile(*outputFile, b.Bytes(), 0o644); err != nil {
+		fatalf("Could not write incident bundle: %v", err)
+	}
+	fmt.Printf("Wrote incident bundle for '%s' to '%s' (%s, secrets redacted: %d).\n", serviceDir, *outputFile, formatSize(int64(b.Len())), inc.redactions)
+	writeResultLine(resultPath, bundleResult{filesBundled: len(inc.written), filesSkipped: countSkipped(skipped), bytesWritten: int64(b.Len())}, nil)
+}
+
+// parseIncidentTime parses -since or -until: an age before now, or an
+// absolute time in one of incidentTimeLayouts.
+func parseIncidentTime(s string, now time.Time) (time.Time, error) {
+	for _, layout := range incidentTimeLayouts {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(s), time.Local); err == nil {
+			return t, nil
+		}
+	}
+	age, err := parseAge(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%v, or a time such as '2006-01-02 15:04'", err)
+	}
+	return now.Add(-age), nil
+}
+
+// lastKnownGood picks the revision to diff against when -good-ref is not
+// given: what was running before the window opened, named by its release
+// tag where there is one.
+func lastKnownGood(dir string, since time.Time) (ref, label string) {
+	commit, err := gitOutput(dir, "rev-list", "-1", "--before="+since.Format(time.RFC3339), "HEAD")
+	if err != nil || commit == "" {
+		return "", ""
+	}
+	if tag, err := gitOutput(dir, "describe", "--tags", "--abbrev=0", commit); err == nil && tag != "" {
+		return tag, "the latest tag before the window"
+	}
+	return commit[:min(len(commit), 12)], "the last commit before the window"
+}
+
+func (inc *incidentBundle) write(w io.Writer, serviceDir string, logs []string, logLines int) error {
+	name := filepath.Base(serviceDir)
+	if abs, err := filepath.Abs(serviceDir); err == nil {
+		name = filepath.Base(abs)
+	}
+	fmt.Fprintf(w, "# Incident context: %s\n\n", name)
+	fmt.Fprintf(w, "Window: %s to %s (%s).\n", inc.since.Format("2006-01-02 15:04 MST"), inc.until.Format("2006-01-02 15:04 MST"), formatAge(inc.until.Sub(inc.since)))
+	if inc.goodRef != "" {
+		fmt.Fprintf(w, "Last known good: `%s` (%s).\n", inc.goodRef, inc.goodLabel)
+	} else {
+		fmt.Fprintf(w, "Last known good: none; no commit predates the window.\n")
+	}
+	fmt.Fprintf(w, "Secrets in the files, the diff and the logs below are redacted.\n\n")
+
+	for _, section := range []func(io.Writer) error{inc.writeDeploys, inc.writeDiff, inc.writeChanged, inc.writeConfig} {
+		if err := section(w); err != nil {
+			return err
+		}
+	}
+	return inc.writeLogs(w, logs, logLines)
+}
+
+// writeDeploys lists the commits to the service and the tags created in the
+// window, newest first.
+func (inc *incidentBundle) writeDeploys(w io.Writer) error {
+	fmt.Fprintf(w, "## Recent Changes\n\n")
+	dir := inc.opts.SrcDir
+	out, err := gitOutput(dir, "log", "--since="+inc.since.Format(time.RFC3339), "--until="+inc.until.Format(time.RFC3339),
+		"--format=%h%x09%ct%x09%aN%x09%s", "--", ".")
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		fmt.Fprintf(w, "No commits touched this service in the window.\n\n")
+	} else {
+		fmt.Fprintf(w, "| Commit | Time | Author | Subject |\n|--------|------|--------|---------|\n")
+		for _, line := range strings.Split(out, "\n") {
+			fields := strings.SplitN(line, "\t", 4)
+			if len(fields) < 4 {
+				continue
+			}
+			fmt.Fprintf(w, "| `%s` | %s | %s | %s |\n", fields[0], formatUnix(fields[1]), fields[2], strings.ReplaceAll(fields[3], "|", "\\|"))
+		}
+		fmt.Fprintln(w)
+	}
+
+	tags, err := gitOutput(dir, "for-each-ref", "--sort=-creatordate", "--format=%(refname:short)%09%(creatordate:unix)", "refs/tags")
+	if err != nil {
+		return err
+	}
+	var released []string
+	for _, line := range strings.Split(tags, "\n") {
+		tag, ts, _ := strings.Cut(line, "\t")
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			continue
+		}
+		if t := time.Unix(sec, 0); !t.Before(inc.since) && !t.After(inc.until) {
+			released = append(released, fmt.Sprintf("`%s` (%s)", tag, formatUnix(ts)))
+		}
+	}
+	if len(released) > 0 {
+		fmt.Fprintf(w, "Tags created in the window: %s.\n\n", strings.Join(released, ", "))
+	}
+	return nil
+}
+
+// writeDiff emits the diff since the last known-good revision, redacted.
+func (inc *incidentBundle) writeDiff(w io.Writer) error {
+	fmt.Fprintf(w, "## Diff Since Last Known Good\n\n")
+	if inc.changes == nil {
+		fmt.Fprintf(w, "No known-good revision to diff against; pass -good-ref.\n\n")
+		return nil
+	}
+	inc.changes.patch = string(inc.redact("changes.diff", []byte(inc.changes.patch)))
+	return writeGitDiffSection(w, inc.changes)
+}
+
+// writeChanged writes the current content of the files changed since the
+// last known-good revision.
+func (inc *incidentBundle) writeChanged(w io.Writer) error {
+	if inc.changes == nil || len(inc.changes.paths) == 0 {
+		return nil
+	}
+	changed, _ := inc.changes.filterChanged(inc.files)
+	if len(changed) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "## Changed Files\n\n")
+	for _, f := range changed {
+		if err := inc.writeFile(w, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeConfig writes the environment variables the service reads and its
+// configuration files, leaving out those already written as changed.
+func (inc *incidentBundle) writeConfig(w io.Writer) error {
+	fmt.Fprintf(w, "## Configuration\n\n")
+	if err := writeEnvVarSection(w, inc.files); err != nil {
+		return err
+	}
+	found := false
+	for _, f := range inc.files {
+		if !isConfigFile(f.RelPath) {
+			continue
+		}
+		found = true
+		if err := inc.writeFile(w, f); err != nil {
+			return err
+		}
+	}
+	if !found {
+		fmt.Fprintf(w, "No configuration files found.\n\n")
+	}
+	return nil
+}
+
+// writeLogs writes each log excerpt as it was given, cut to its last lines.
+func (inc *incidentBundle) writeLogs(w io.Writer, logs []string, keep int) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "## Logs\n\n")
+	for _, name := range logs {
+		var content []byte
+		var err error
+		if name == "-" {
+			content, err = io.ReadAll(bufio.NewReader(os.Stdin))
+			name = "stdin"
+		} else {
+			content, err = os.ReadFile(name)
+		}
+		if err != nil {
+			return fmt.Errorf("reading log: %w", err)
+		}
+		lines := strings.SplitAfter(strings.TrimSuffix(string(content), "\n"), "\n")
+		annotation := ""
+		if keep > 0 && len(lines) > keep {
+			annotation = fmt.Sprintf("Last %d of %d lines.\n", keep, len(lines))
+			lines = lines[len(lines)-keep:]
+		}
+		content = inc.redact(name, []byte(strings.Join(lines, "")))
+		if err := inc.opts.Style.WriteFile(w, name, "text", annotation, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile writes a file of the service once, redacted.
+func (inc *incidentBundle) writeFile(w io.Writer, f fileEntry) error {
+	if inc.written.Contains(f.RelPath) || f.Placeholder || f.DuplicateOf != "" {
+		return nil
+	}
+	inc.written[f.RelPath] = struct{}{}
+	content, err := os.ReadFile(f.Path)
+	if err != nil {
+		return err
+	}
+	return inc.opts.Style.WriteFile(w, f.RelPath, f.Lang, "", inc.redact(f.RelPath, content))
+}
+
+func (inc *incidentBundle) redact(name string, content []byte) []byte {
+	findings := bundler.ScanSecrets(name, content)
+	if len(findings) == 0 {
+		return content
+	}
+	inc.redactions += len(findings)
+	return bundler.RedactSecrets(content, findings)
+}
+
+// isConfigFile reports whether a file configures a service rather than
+// implementing it. Lock files only pin dependencies and are left out.
+func isConfigFile(relPath string) bool {
+	if bundler.IsLockFile(relPath) {
+		return false
+	}
+	base := path.Base(filepath.ToSlash(relPath))
+	for _, p := range configNames {
+		if ok, _ := path.Match(p, base); ok {
+			return true
+		}
+	}
+	ext := strings.ToLower(path.Ext(base))
+	for _, e := range configExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// formatUnix formats a Unix time from git for the incident tables.
+func formatUnix(ts string) string {
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ts
+	}
+	return time.Unix(sec, 0).Format("2006-01-02 15:04")
+}
@@ -0,0 +1,109 @@
+// project-bundler/incremental.go
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// renderCache is the -incremental cache manifest: the rendered block of
+// every file of the previous run, with what it was rendered from. A file
+// whose size, modification time and variant are unchanged is not read
+// again; its block is copied from the cache.
+type renderCache struct {
+	// Key identifies the settings the blocks were rendered with: the
+	// version, the command line and the project config. Any change to them
+	// invalidates every block.
+	Key   string                  `json:"key"`
+	Files map[string]renderedFile `json:"files"` // By slash-separated relative path.
+
+	next map[string]renderedFile // This run's blocks, saved for the next one.
+}
+
+// renderedFile is one cached block.
+type renderedFile struct {
+	Size    int64                   `json:"size"`
+	ModTime time.Time               `json:"mtime"`
+	Variant string                  `json:"variant"` // See renderVariant.
+	SHA256  string                  `json:"sha256"`  // Of the original content, for the manifest.
+	Block   string                  `json:"block"`
+	Tokens  int                     `json:"tokens,omitempty"` // Of the block, when counted.
+	Secrets []bundler.SecretFinding `json:"secrets,omitempty"`
+	// Fingerprint is the file's minHash, when the manifest is fingerprinted.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// renderCacheKey fingerprints what, beyond a file's own content, decides
+// how it is rendered.
+func renderCacheKey(srcDir string) string {
+	h := sha256.New()
+	h.Write([]byte(version + "\x00" + strings.Join(os.Args[1:], "\x00") + "\x00"))
+	for _, name := range []string{localConfigFile, localConfigAltFile} {
+		if data, err := os.ReadFile(filepath.Join(srcDir, name)); err == nil {
+			h.Write(data)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// renderVariant describes the per-file inputs of rendering that depend on
+// the other files or on the walk rather than on the content, such as
+// whether -for-tests reduced the file to its API.
+func renderVariant(f fileEntry, apiOnly, truncated bool, pairNote string) string {
+	v, _ := json.Marshal([]any{f.Lang, f.Charset, f.CharsetDetected, f.EOL, f.NotBuilt, apiOnly, truncated, pairNote})
+	return string(v)
+}
+
+// loadRenderCache reads the cache of the previous run. A missing cache, or
+// one rendered with other settings, yields an empty one: the run renders
+// every file and saves a fresh cache.
+func loadRenderCache(path, key string) (*renderCache, error) {
+	cache := &renderCache{Key: key, Files: make(map[string]renderedFile), next: make(map[string]renderedFile)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var prev renderCache
+	if err := json.Unmarshal(data, &prev); err != nil || prev.Key != key {
+		return cache, nil
+	}
+	cache.Files = prev.Files
+	return cache, nil
+}
+
+// lookup returns the cached block of f if f is unchanged since it was
+// rendered.
+func (c *renderCache) lookup(f fileEntry, variant string) (renderedFile, bool) {
+	r, ok := c.Files[filepath.ToSlash(f.RelPath)]
+	if !ok || r.Size != f.Size || !r.ModTime.Equal(f.ModTime) || r.Variant != variant {
+		return renderedFile{}, false
+	}
+	return r, true
+}
+
+// keep records a block for the next run.
+func (c *renderCache) keep(f fileEntry, r renderedFile) {
+	r.Size, r.ModTime = f.Size, f.ModTime
+	c.next[filepath.ToSlash(f.RelPath)] = r
+}
+
+// save writes this run's blocks, dropping those of files no longer bundled.
+func (c *renderCache) save(path string) error {
+	data, err := json.Marshal(renderCache{Key: c.Key, Files: c.next})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,166 @@
+// project-bundler/init.go
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// binaryDirMinFiles is how many binary files a directory needs before init
+// proposes to exclude it for them.
+const binaryDirMinFiles = 10
+
+// binarySuggestion is a directory that mostly holds binary files. They are
+// never bundled, but the walk opens each one to find that out.
+type binarySuggestion struct {
+	name            string
+	binaries, files int
+}
+
+// runInit implements the `init` subcommand. It inspects the project,
+// proposes a localConfigFile with the detected project types and the
+// exclusions advise would suggest, and writes it once confirmed.
+//
+//	project-bundler init -src ./my-project
+func runInit(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	srcDir := fs.String("src", ".", "Source project directory.")
+	minSavings := fs.Float64("min-savings", 5, "Only propose excluding directories and extensions holding at least this percentage of the bundle.")
+	yes := fs.Bool("yes", false, "Write the proposed config without asking.")
+	dryRun := fs.Bool("dry-run", false, "Only print the proposed config.")
+	force := fs.Bool("force", false, "Replace an existing "+localConfigFile+".")
+	fs.Parse(args)
+
+	if _, found, _ := readLocalConfig(*srcDir); found && !*force && !*dryRun {
+		log.Fatalf("'%s' already has a project config; pass -force to replace it", *srcDir)
+	}
+
+	var config localConfig
+	var types []string
+	for _, d := range bundler.DetectProjectTypes(os.DirFS(*srcDir)) {
+		types = append(types, d.Type)
+	}
+	if len(types) > 0 {
+		config.Extends = strings.Join(types, ",")
+		fmt.Printf("Detected project type: %s\n", config.Extends)
+	} else {
+		fmt.Println("No project type detected; the generic preset applies.")
+	}
+	// The proposal starts from the presets alone, whatever the file to be
+	// replaced says.
+	projectType := config.Extends
+	if projectType == "" {
+		projectType = "generic"
+	}
+	opts, err := resolveConfigOptions(localConfig{file: localConfigFile}, *srcDir, projectType, "", "", false)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	files, skipped, err := collectFiles(opts)
+	if err != nil {
+		log.Fatalf("Error during directory walk: %v", err)
+	}
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	fmt.Printf("The bundle would hold %d files, %s.\n", len(files), formatSize(total))
+
+	suggestions := buildSuggestions(files, total, *minSavings)
+	if len(suggestions) > wizardSuggestions {
+		suggestions = suggestions[:wizardSuggestions]
+	}
+	for _, s := range suggestions {
+		fmt.Printf("  Proposing to exclude %s: %.0f%% of the bundle (%s, %d files)\n", strings.TrimPrefix(s.describe(), "excluding "), percent(s.bytes, total), formatSize(s.bytes), s.files)
+		switch s.kind {
+		case adviceDir:
+			config.IgnoreDirs = append(config.IgnoreDirs, s.name)
+		case adviceExt:
+			config.IgnoreExts = append(config.IgnoreExts, s.name)
+		}
+	}
+	for _, s := range binaryDirs(opts.SrcDir, files, skipped[bundler.ReasonBinary]) {
+		if !slices.Contains(config.IgnoreDirs, s.name) {
+			fmt.Printf("  Proposing to exclude directory '%s': %d of its %d files are binary\n", s.name, s.binaries, s.files)
+			config.IgnoreDirs = append(config.IgnoreDirs, s.name)
+		}
+	}
+
+	proposal := formatLocalConfig(config)
+	file := filepath.Join(*srcDir, localConfigFile)
+	fmt.Printf("\nProposed %s:\n\n%s\n", localConfigFile, proposal)
+	if *dryRun {
+		return
+	}
+	if !*yes {
+		if !isTerminal(os.Stdin) {
+			log.Fatalf("Not writing '%s' without a terminal to confirm on; pass -yes to write it", file)
+		}
+		fmt.Printf("Write it to '%s'? [y/N] ", file)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("Nothing written.")
+			return
+		}
+	}
+	if err := saveLocalConfig(*srcDir, config); err != nil {
+		log.Fatalf("Could not write '%s': %v", file, err)
+	}
+	fmt.Printf("Wrote '%s'; edit it to change the settings, and commit it to share them.\n", file)
+}
+
+// binaryDirs returns the directory names, at any depth as -ignore-dirs
+// matches them, of which at least binaryDirMinFiles and half of the files
+// are binary, most binaries first.
+func binaryDirs(srcDir string, files []fileEntry, binaries []string) []binarySuggestion {
+	counts := make(map[string]*binarySuggestion)
+	count := func(relPath string, binary bool) {
+		seen := make(stringSet)
+		for _, part := range strings.Split(filepath.Dir(relPath), string(filepath.Separator)) {
+			if part == "." || part == "" || seen.Contains(part) {
+				continue
+			}
+			seen[part] = struct{}{}
+			s, ok := counts[part]
+			if !ok {
+				s = &binarySuggestion{name: part}
+				counts[part] = s
+			}
+			s.files++
+			if binary {
+				s.binaries++
+			}
+		}
+	}
+	for _, f := range files {
+		count(f.RelPath, false)
+	}
+	for _, p := range binaries {
+		if rel, err := filepath.Rel(srcDir, p); err == nil {
+			count(rel, true)
+		}
+	}
+	var dirs []binarySuggestion
+	for _, s := range counts {
+		if s.binaries >= binaryDirMinFiles && 2*s.binaries >= s.files {
+			dirs = append(dirs, *s)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].binaries != dirs[j].binaries {
+			return dirs[i].binaries > dirs[j].binaries
+		}
+		return dirs[i].name < dirs[j].name
+	})
+	return dirs
+}
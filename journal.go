@@ -0,0 +1,243 @@
+// project-bundler/journal.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// journalRecordRE matches the line that opens each journal record.
+var journalRecordRE = regexp.MustCompile(`(?m)^Journal record (\d+), `)
+
+// journalState is the project as a journal describes it: every file's latest
+// block, with removed files dropped.
+type journalState struct {
+	order   []string // Paths in the order they first appeared; may hold removed ones.
+	blocks  map[string]bundler.Block
+	records int // Number of the last record; 0 for a new journal.
+}
+
+// replayJournal reads the journal at path, applying its records in order. A
+// missing journal is an empty one. With checks, the journal or bundle is
+// read with parseBundle; paths may repeat only in a journal.
+func replayJournal(path string, style bundler.Style, checks *bundler.ParseOptions) (*journalState, error) {
+	state := &journalState{blocks: make(map[string]bundler.Block)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range journalRecordRE.FindAllSubmatch(data, -1) {
+		if n, err := strconv.Atoi(string(m[1])); err == nil && n > state.records {
+			state.records = n
+		}
+	}
+	var blocks []bundler.Block
+	if checks != nil {
+		opts := *checks
+		opts.AllowDuplicates = state.records > 0
+		blocks, err = parseBundle(path, bytes.NewReader(data), style, opts)
+	} else {
+		blocks, err = style.Parse(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		if b.Removed {
+			delete(state.blocks, b.Path)
+			continue
+		}
+		if _, ok := state.blocks[b.Path]; !ok {
+			state.order = append(state.order, b.Path)
+		}
+		state.blocks[b.Path] = b
+	}
+	return state, nil
+}
+
+// live returns the blocks of the files that still exist, in order.
+func (s *journalState) live() []bundler.Block {
+	var blocks []bundler.Block
+	seen := make(stringSet)
+	for _, p := range s.order {
+		b, ok := s.blocks[p]
+		if !ok || seen.Contains(p) {
+			continue
+		}
+		seen[p] = struct{}{}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+// removed returns the paths of the files a record removed and no later one
+// brought back, in order.
+func (s *journalState) removed() []string {
+	var paths []string
+	seen := make(stringSet)
+	for _, p := range s.order {
+		if _, ok := s.blocks[p]; ok || seen.Contains(p) {
+			continue
+		}
+		seen[p] = struct{}{}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// journalStyle strips the -root-label and -path-prefix rewrites from style:
+// parsed paths already carry them, and writing them back must not apply them
+// a second time.
+func journalStyle(style bundler.Style) bundler.Style {
+	return bundler.Style{PathHeader: style.PathHeader, Fenced: style.Fenced, FenceChar: style.FenceChar, FileTag: style.FileTag, PathFooter: style.PathFooter}
+}
+
+// sameBlock reports whether two blocks would be written identically.
+func sameBlock(a, b bundler.Block) bool {
+	return a.Lang == b.Lang && a.Annotation == b.Annotation && bytes.Equal(a.Content, b.Content)
+}
+
+// appendJournal compares the full bundle at snapshotPath with the state the
+// journal at journalPath describes and appends a record with the files that
+// were added or changed and a tombstone for each removed one. Nothing is
+// appended when nothing changed. It returns the record's number (0 when
+// none was written) and its counts.
+func appendJournal(journalPath, snapshotPath string, style bundler.Style) (record, changed, removed int, err error) {
+	state, err := replayJournal(journalPath, style, nil)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("could not read journal: %w", err)
+	}
+	f, err := os.Open(snapshotPath)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	current, err := style.Parse(f)
+	f.Close()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	var delta []bundler.Block
+	present := make(stringSet)
+	for _, b := range current {
+		present[b.Path] = struct{}{}
+		if prev, ok := state.blocks[b.Path]; !ok || !sameBlock(prev, b) {
+			delta = append(delta, b)
+		}
+	}
+	var gone []string
+	for p := range state.blocks {
+		if !present.Contains(p) {
+			gone = append(gone, p)
+		}
+	}
+	sort.Strings(gone)
+	if len(delta) == 0 && len(gone) == 0 {
+		return 0, 0, 0, nil
+	}
+
+	out, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+	record = state.records + 1
+	fmt.Fprintf(w, "Journal record %d, %s: %d changed, %d removed.\n\n", record, time.Now().UTC().Format(time.RFC3339), len(delta), len(gone))
+	if err := writeJournalBlocks(w, journalStyle(style), delta, gone); err != nil {
+		return 0, 0, 0, err
+	}
+	if err := w.Flush(); err != nil {
+		return 0, 0, 0, err
+	}
+	return record, len(delta), len(gone), out.Close()
+}
+
+// writeJournalBlocks writes the blocks of one record: the files' latest
+// contents and then a tombstone for each removed path.
+func writeJournalBlocks(w *bufio.Writer, style bundler.Style, blocks []bundler.Block, removed []string) error {
+	for _, b := range blocks {
+		if err := style.WriteFile(w, b.Path, b.Lang, b.Annotation, b.Content); err != nil {
+			return err
+		}
+	}
+	for _, p := range removed {
+		if err := style.WriteRemoved(w, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runCompact implements the `compact` subcommand, which squashes a journal
+// written with -journal into a single record holding every file's latest
+// content.
+//
+//	project-bundler compact bundle.md
+func runCompact(args []string) {
+	fs := flag.NewFlagSet("compact", flag.ExitOnError)
+	styleName := fs.String("style", "github", "Output style the journal was written with. Options: "+strings.Join(bundler.StyleNames(), ", "))
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fatalf("Usage: project-bundler compact [-style name] <journal>")
+	}
+	style, ok := bundler.Styles[*styleName]
+	if !ok {
+		fatalf("Invalid style '%s'. Available styles are: %s", *styleName, strings.Join(bundler.StyleNames(), ", "))
+	}
+	journalPath := fs.Arg(0)
+	resultPath = journalPath
+	state, err := replayJournal(journalPath, style, nil)
+	if err != nil {
+		fatalf("Could not read journal: %v", err)
+	}
+	if state.records == 0 {
+		fatalf("'%s' is not a journal written with -journal.", journalPath)
+	}
+	blocks := state.live()
+
+	// Write next to the journal and rename, so a failure leaves it intact.
+	tmp, err := os.CreateTemp(filepath.Dir(journalPath), "."+filepath.Base(journalPath)+".*")
+	if err != nil {
+		fatalf("Could not write compacted journal: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if fi, err := os.Stat(journalPath); err == nil {
+		tmp.Chmod(fi.Mode().Perm())
+	}
+	w := bufio.NewWriter(tmp)
+	fmt.Fprintf(w, "Journal record 1, %s: %d files, compacted from %d records.\n\n", time.Now().UTC().Format(time.RFC3339), len(blocks), state.records)
+	err = writeJournalBlocks(w, journalStyle(style), blocks, nil)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), journalPath)
+	}
+	if err != nil {
+		fatalf("Could not write compacted journal: %v", err)
+	}
+	var size int64
+	if fi, err := os.Stat(journalPath); err == nil {
+		size = fi.Size()
+	}
+	fmt.Printf("Compacted %d records into one with %d files.\n", state.records, len(blocks))
+	writeResultLine(resultPath, bundleResult{filesBundled: len(blocks), bytesWritten: size}, nil)
+}
@@ -0,0 +1,62 @@
+// project-bundler/languages.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// languageShare is one language's part of the bundled content, measured in
+// bytes the way GitHub's linguist does.
+type languageShare struct {
+	Language string  `json:"language"`
+	Bytes    int64   `json:"bytes"`
+	Percent  float64 `json:"percent"`
+}
+
+// languageShares turns the bytes bundled per language into shares, largest
+// first. Unrecognized files ("text") carry no language and are left out, so
+// the percentages describe the code rather than the READMEs and data.
+func languageShares(bytesByLang map[string]int64) []languageShare {
+	var total int64
+	for lang, n := range bytesByLang {
+		if lang != "text" {
+			total += n
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	var shares []languageShare
+	for lang, n := range bytesByLang {
+		if lang != "text" && n > 0 {
+			shares = append(shares, languageShare{Language: lang, Bytes: n, Percent: percent(n, total)})
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Bytes != shares[j].Bytes {
+			return shares[i].Bytes > shares[j].Bytes
+		}
+		return shares[i].Language < shares[j].Language
+	})
+	return shares
+}
+
+// formatLanguageShares renders shares as "go 81.2%, yaml 10.3%, other 8.5%",
+// folding everything after the first limit languages into "other".
+func formatLanguageShares(shares []languageShare, limit int) string {
+	var parts []string
+	var other float64
+	for i, s := range shares {
+		if i >= limit {
+			other += s.Percent
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %.1f%%", s.Language, s.Percent))
+	}
+	if other > 0 {
+		parts = append(parts, fmt.Sprintf("other %.1f%%", other))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,137 @@
+// project-bundler/linenumbers.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// lineRange is a 1-based, inclusive range of lines.
+type lineRange struct{ from, to int }
+
+// lineSlice keeps only a range of the lines of the files matching a glob,
+// from an -include pattern such as "main.go:100-250".
+type lineSlice struct {
+	rule ruleSet // Holds just the glob, for MatchingGlob.
+	lineRange
+}
+
+// lineRangeRE matches the ":100-250" (or ":100") suffix of an -include
+// pattern.
+var lineRangeRE = regexp.MustCompile(`^(.+):([0-9]+)(?:-([0-9]+))?$`)
+
+// parseLineSlice splits an -include pattern with a line range into its glob
+// and range. ok is false for patterns without one.
+func parseLineSlice(pattern string) (glob string, r lineRange, ok bool, err error) {
+	m := lineRangeRE.FindStringSubmatch(pattern)
+	if m == nil {
+		return pattern, r, false, nil
+	}
+	r.from, _ = strconv.Atoi(m[2])
+	r.to = r.from
+	if m[3] != "" {
+		r.to, _ = strconv.Atoi(m[3])
+	}
+	if r.from < 1 || r.to < r.from {
+		return "", r, false, fmt.Errorf("invalid line range in '%s': use FROM-TO with 1 <= FROM <= TO", pattern)
+	}
+	return m[1], r, true, nil
+}
+
+// selectLines keeps the lines of content in the ranges of the slices whose
+// glob matches relPath, with a marker line for each run of lines left out.
+// It returns the lines kept, each line's number in the file (0 for the
+// markers) and the annotation describing the cut; ok is false when no slice
+// matches.
+func selectLines(cuts []lineSlice, relPath string, content []byte) (kept []byte, numbers []int, note string, ok bool) {
+	var ranges []lineRange
+	for _, s := range cuts {
+		if _, match := s.rule.MatchingGlob(relPath); match {
+			ranges = append(ranges, s.lineRange)
+		}
+	}
+	if len(ranges) == 0 {
+		return content, nil, "", false
+	}
+	slices.SortFunc(ranges, func(a, b lineRange) int { return a.from - b.from })
+	merged := ranges[:1]
+	for _, r := range ranges[1:] {
+		if last := &merged[len(merged)-1]; r.from <= last.to+1 {
+			last.to = max(last.to, r.to)
+		} else {
+			merged = append(merged, r)
+		}
+	}
+
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	var b bytes.Buffer
+	var shown []string
+	next := 1 // First line not yet shown or marked as left out.
+	for _, r := range merged {
+		if r.from > len(lines) {
+			break
+		}
+		r.to = min(r.to, len(lines))
+		if r.from > next {
+			fmt.Fprintf(&b, "... lines %d-%d omitted ...\n", next, r.from-1)
+			numbers = append(numbers, 0)
+		}
+		for n := r.from; n <= r.to; n++ {
+			b.Write(lines[n-1])
+			if !bytes.HasSuffix(lines[n-1], []byte("\n")) {
+				b.WriteByte('\n') // The last line, without a final newline.
+			}
+			numbers = append(numbers, n)
+		}
+		shown = append(shown, fmt.Sprintf("%d-%d", r.from, r.to))
+		next = r.to + 1
+	}
+	if next <= len(lines) {
+		fmt.Fprintf(&b, "... lines %d-%d omitted ...\n", next, len(lines))
+		numbers = append(numbers, 0)
+	}
+	if len(shown) == 0 {
+		return b.Bytes(), numbers, fmt.Sprintf("Line range: the file has only %d lines, fewer than requested.\n", len(lines)), true
+	}
+	return b.Bytes(), numbers, fmt.Sprintf("Lines %s of %d.\n", strings.Join(shown, ", "), len(lines)), true
+}
+
+// numberLines prefixes each line of content with its number in the file:
+// numbers[i] for the i-th line, or i+1 when numbers is nil. Lines numbered
+// 0, the markers of lines left out, get a blank prefix of the same width.
+func numberLines(content []byte, numbers []int) []byte {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	last := len(lines)
+	if numbers != nil {
+		last = 0
+		for _, n := range numbers {
+			last = max(last, n)
+		}
+	}
+	width := len(strconv.Itoa(last))
+	var b bytes.Buffer
+	b.Grow(len(content) + len(lines)*(width+2))
+	for i, line := range lines {
+		n := i + 1
+		if numbers != nil {
+			n = numbers[i]
+		}
+		if n == 0 {
+			fmt.Fprintf(&b, "%*s  ", width, "")
+		} else {
+			fmt.Fprintf(&b, "%*d  ", width, n)
+		}
+		b.Write(line)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,220 @@
+// project-bundler/lint.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// lintProblem is a structural problem `lint` found, at a line of a bundle
+// file or, with line 0, in the file as a whole.
+type lintProblem struct {
+	file    string
+	line    int
+	message string
+}
+
+// runLint implements the `lint` subcommand: a gate before a bundle is shared
+// or unbundled that checks its fences and blocks are balanced, its paths are
+// unique and safe to write, its manifest parses, its parts fit their budget
+// and no secrets slipped into it. It exits with status 1 when it finds any
+// problem.
+func runLint(args []string) {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	styleName := fs.String("style", "github", "Output style the bundle was written with. Options: "+strings.Join(bundler.StyleNames(), ", "))
+	finalNewline := fs.String("final-newline", "pad", "-final-newline value the bundle was written with: pad or mark.")
+	manifestPath := fs.String("manifest", "", "Manifest of the bundle. Defaults to the bundle's name with .manifest.json, if it exists.")
+	maxTokensStr := fs.String("max-tokens", "", "Maximum tokens of the bundle or of each of its parts, e.g. 200k.")
+	maxBytesStr := fs.String("max-bytes", "", "Maximum size of the bundle or of each of its parts, e.g. 500KB.")
+	model := fs.String("model", "gpt-4o", "Target model or tokenizer used to count tokens for -max-tokens.")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatalf("Usage: project-bundler lint [-style name] [-max-tokens n] [-max-bytes size] <bundle>")
+	}
+	style, ok := bundler.Styles[*styleName]
+	if !ok {
+		log.Fatalf("Invalid style '%s'. Available styles are: %s", *styleName, strings.Join(bundler.StyleNames(), ", "))
+	}
+	var err error
+	if style.MarkNoNewline, err = parseFinalNewline(*finalNewline); err != nil {
+		log.Fatalf("%v", err)
+	}
+	maxTokens := 0
+	if *maxTokensStr != "" {
+		if maxTokens, err = parseTokenCount(*maxTokensStr); err != nil {
+			log.Fatalf("Invalid -max-tokens: %v", err)
+		}
+	}
+	maxBytes, err := parseByteSize(*maxBytesStr)
+	if err != nil {
+		log.Fatalf("Invalid -max-bytes: %v", err)
+	}
+	var tok tokenizer
+	if maxTokens > 0 {
+		if tok, err = tokenizerForModel(*model); err != nil {
+			log.Fatalf("Invalid -model: %v", err)
+		}
+	}
+
+	bundle := fs.Arg(0)
+	files := bundleFiles(bundle)
+	if len(files) == 0 {
+		log.Fatalf("Could not read bundle: neither %s nor its parts exist", bundle)
+	}
+	if *manifestPath == "" {
+		*manifestPath = sidecarPath(bundle, ".manifest.json")
+	}
+
+	var problems []lintProblem
+	if _, err := loadManifest(*manifestPath); err != nil {
+		if errors.Unwrap(err) != nil {
+			err = errors.Unwrap(err) // Without the path, which the report shows.
+		}
+		problems = append(problems, lintProblem{*manifestPath, 0, "manifest does not parse: " + err.Error()})
+	}
+	seen := make(map[string]lintProblem) // Where each path was first seen.
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatalf("Could not read bundle: %v", err)
+		}
+		if maxBytes > 0 && int64(len(data)) > maxBytes {
+			problems = append(problems, lintProblem{file, 0, fmt.Sprintf("%s, over the -max-bytes limit of %s", formatSize(int64(len(data))), formatSize(maxBytes))})
+		}
+		if tok != nil {
+			if n := tok.count(data); n > maxTokens {
+				problems = append(problems, lintProblem{file, 0, fmt.Sprintf("%d tokens, over the -max-tokens limit of %d", n, maxTokens)})
+			}
+		}
+		found, err := lintBundleFile(file, data, style, seen)
+		if err != nil {
+			log.Fatalf("Could not parse bundle: %v", err)
+		}
+		problems = append(problems, found...)
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].file != problems[j].file {
+			return problems[i].file < problems[j].file
+		}
+		return problems[i].line < problems[j].line
+	})
+	for _, p := range problems {
+		if p.line > 0 {
+			fmt.Printf("%s:%d: %s\n", p.file, p.line, p.message)
+		} else {
+			fmt.Printf("%s: %s\n", p.file, p.message)
+		}
+	}
+	if len(problems) > 0 {
+		fmt.Printf("%d problem(s) in %s.\n", len(problems), bundle)
+		os.Exit(1)
+	}
+	fmt.Printf("No problems in %s (%d files, %d bundle files).\n", bundle, len(seen), len(files))
+}
+
+// bundleFiles returns the files a bundle consists of: the bundle itself or,
+// when -split-tokens or -split-bytes replaced it, its parts.
+func bundleFiles(bundle string) []string {
+	if _, err := os.Stat(bundle); err == nil {
+		return []string{bundle}
+	}
+	var parts []string
+	for n := 1; ; n++ {
+		part := partPath(bundle, n)
+		if _, err := os.Stat(part); err != nil {
+			return parts
+		}
+		parts = append(parts, part)
+	}
+}
+
+// lintBundleFile checks the blocks of one bundle file. seen records where
+// each path was first seen, so that duplicates across parts are found too.
+// In a journal, whose later records supersede earlier ones, paths may repeat.
+func lintBundleFile(file string, data []byte, style bundler.Style, seen map[string]lintProblem) ([]lintProblem, error) {
+	blocks, err := style.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	journal := journalRecordRE.Match(data)
+	var problems []lintProblem
+	add := func(line int, format string, args ...any) {
+		problems = append(problems, lintProblem{file, line, fmt.Sprintf(format, args...)})
+	}
+	if style.Fenced {
+		if line := unclosedFence(data); line > 0 {
+			add(line, "code fence is never closed")
+		}
+	}
+	for _, b := range blocks {
+		switch {
+		case b.Path == "":
+			add(b.Line, "file block without a path")
+		case !filepath.IsLocal(filepath.FromSlash(b.Path)):
+			add(b.Line, "path %s leaves the directory it would be unbundled to", b.Path)
+		}
+		if first, ok := seen[b.Path]; ok && !journal {
+			at := fmt.Sprintf("line %d", first.line)
+			if first.file != file {
+				at = fmt.Sprintf("%s:%d", first.file, first.line)
+			}
+			add(b.Line, "duplicate path %s, first at %s", b.Path, at)
+		} else if !ok {
+			seen[b.Path] = lintProblem{file: file, line: b.Line}
+		}
+		if b.Unclosed {
+			switch {
+			case style.Fenced && b.Lang == "" && len(b.Content) == 0:
+				add(b.Line, "%s has no code fence", b.Path)
+			case style.FileTag:
+				add(b.Line, "%s has no closing </file> tag", b.Path)
+			case style.PathFooter != "":
+				add(b.Line, "%s has no closing footer", b.Path)
+			}
+		}
+		start := b.Line + style.ContentLine(style.BlockAnnotation(b.Annotation, b.Content)) - 1
+		for _, s := range bundler.ScanSecrets(b.Path, b.Content) {
+			add(start+s.Line-1, "likely secret in %s: %s", b.Path, s.Rule)
+		}
+	}
+	return problems, nil
+}
+
+// unclosedFence returns the line of a Markdown code fence that is never
+// closed, or 0 when all fences are balanced. As in CommonMark, a fence is
+// closed by a run of its character at least as long, with nothing else on
+// the line.
+func unclosedFence(data []byte) int {
+	open, openLine := "", 0
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if len(line)-len(trimmed) > 3 || trimmed == "" || trimmed[0] != '`' && trimmed[0] != '~' {
+			continue
+		}
+		run := len(trimmed) - len(strings.TrimLeft(trimmed, trimmed[:1]))
+		switch {
+		case run < 3:
+		case open == "":
+			open, openLine = trimmed[:run], n
+		case trimmed[0] == open[0] && run >= len(open) && strings.TrimSpace(trimmed[run:]) == "":
+			open = ""
+		}
+	}
+	if open != "" {
+		return openLine
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+// project-bundler/lock.go
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errLocked is returned by tryLock when another process holds the lock.
+var errLocked = errors.New("lock is held by another process")
+
+// lockPollInterval is how often a waiting run retries the lock.
+const lockPollInterval = 200 * time.Millisecond
+
+// acquireOutputLock takes an exclusive lock on the output's lock file so
+// that two runs writing the same bundle (and its manifest) cannot interleave.
+// The lock is released by the operating system if the process dies, so stale
+// locks cannot block later runs. The lock file itself is left in place:
+// deleting it would let a waiting run and a new run lock different files.
+// It lives in the user's cache directory, not next to the bundle.
+func acquireOutputLock(outputFile string, wait time.Duration) (release func(), err error) {
+	path, err := outputLockPath(outputFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not lock '%s': %w", outputFile, err)
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		f, err := tryLock(path)
+		if err == nil {
+			return func() { f.Close() }, nil
+		}
+		if !errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("could not lock '%s': %w", path, err)
+		}
+		if !time.Now().Before(deadline) {
+			if wait > 0 {
+				return nil, fmt.Errorf("another project-bundler run is still writing '%s' after waiting %s", outputFile, wait)
+			}
+			return nil, fmt.Errorf("another project-bundler run is writing '%s'; wait for it to finish or pass -lock-wait", outputFile)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// outputLockPath returns the lock file of outputFile, named after a hash of
+// its absolute path in the user's cache directory.
+func outputLockPath(outputFile string) (string, error) {
+	abs, err := filepath.Abs(outputFile)
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, "project-bundler", "locks", hex.EncodeToString(sum[:16])+".lock"), nil
+}
+
+// openLockFile opens (creating it and its directory if needed) the lock
+// file for tryLock.
+func openLockFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+}
@@ -0,0 +1,12 @@
+//go:build !unix && !windows
+
+// project-bundler/lock_other.go
+package main
+
+import "os"
+
+// tryLock cannot take an exclusive lock on this platform; it only makes sure
+// the lock file can be created.
+func tryLock(path string) (*os.File, error) {
+	return openLockFile(path)
+}
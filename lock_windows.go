@@ -0,0 +1,29 @@
+// project-bundler/lock_windows.go
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// errorSharingViolation is ERROR_SHARING_VIOLATION from winerror.h.
+const errorSharingViolation syscall.Errno = 32
+
+// tryLock opens path without any sharing, which Windows enforces as an
+// exclusive lock until the handle is closed or the process exits.
+func tryLock(path string) (*os.File, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil,
+		syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		if errors.Is(err, errorSharingViolation) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
@@ -4,123 +4,42 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"io/fs"
 	"log"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
-)
-
-// --- Configuration Section ---
-
-// ProjectConfig defines the bundling rules for a specific project type.
-type ProjectConfig struct {
-	IgnoreDirs     []string
-	IgnoreExts     []string
-	IgnoreSuffixes []string
-	LangMap        map[string]string
-}
-
-// baseLangMap contains common language mappings for extensions.
-var baseLangMap = map[string]string{
-	".md":        "markdown",
-	".sh":        "shell",
-	".json":      "json",
-	".yml":       "yaml",
-	".yaml":      "yaml",
-	".toml":      "toml",
-	".txt":       "text",
-	".gitignore": "text",
-	".proto":     "protobuf",
-}
-
-// filenameLangMap contains mappings for well-known filenames that lack extensions.
-var filenameLangMap = map[string]string{
-	"Dockerfile": "dockerfile",
-	"Makefile":   "makefile",
-	"go.mod":     "go-mod",
-	"go.sum":     "text",
-	"LICENSE":    "text",
-	"README":     "markdown",
-}
+	"time"
 
-// projectConfigs holds the presets for different project types.
-var projectConfigs = map[string]ProjectConfig{
-	"generic": {
-		IgnoreDirs: []string{".git"},
-		IgnoreExts: []string{".DS_Store", ".log", ".lock"},
-	},
-	"android": {
-		IgnoreDirs: []string{".git", ".idea", "build", ".gradle", "gradle"},
-		IgnoreExts: []string{".DS_Store", ".iml", ".jar", ".keystore", ".jks", ".apk", ".aab", ".so", ".png", ".jpg", ".jpeg", ".gif", ".webp"},
-		LangMap: map[string]string{
-			".java":   "java",
-			".kt":     "kotlin",
-			".kts":    "kotlin",
-			".xml":    "xml",
-			".gradle": "groovy",
-			".pro":    "text",
-		},
-	},
-	"flutter": {
-		IgnoreDirs:     []string{".git", ".idea", ".dart_tool", ".metadata", "build", "android", "ios", "linux", "windows", "macos", "web"},
-		IgnoreExts:     []string{".DS_Store", ".flutter-plugins-dependencies", ".iml", ".metadata", ".lock", ".png", ".jpg", ".jpeg", ".gif", ".webp", ".ttf", ".otf", ".ico", ".apk", ".aab"},
-		IgnoreSuffixes: []string{".g.dart", ".freezed.dart", ".gr.dart"}, // Ignores generated code
-		LangMap: map[string]string{
-			".dart": "dart",
-			".yaml": "yaml",
-			".arb":  "json",
-		},
-	},
-	"go": {
-		IgnoreDirs: []string{".git", "vendor", "build"},
-		IgnoreExts: []string{".DS_Store", ".exe", ".so", ".a"},
-		LangMap: map[string]string{
-			".go": "go",
-		},
-	},
-	"rust": {
-		IgnoreDirs: []string{".git", "target"},
-		IgnoreExts: []string{".DS_Store", ".rlib", ".so", ".a", ".exe"},
-		LangMap: map[string]string{
-			".rs": "rust",
-		},
-	},
-	"ios": {
-		IgnoreDirs: []string{".git", ".idea", "Pods", "build", "DerivedData", ".swiftpm", "Carthage"},
-		IgnoreExts: []string{".DS_Store", ".mobileprovision", ".app", ".ipa", ".car", ".xcassets", ".storyboardc", ".nib", ".png", ".jpg", ".jpeg"},
-		LangMap: map[string]string{
-			".swift":      "swift",
-			".m":          "objectivec",
-			".h":          "objectivec",
-			".storyboard": "xml",
-			".xib":        "xml",
-			".plist":      "xml",
-		},
-	},
-}
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
 
 // --- Helper Functions ---
 
 // stringSet is a helper type for efficient lookups (O(1) average).
 type stringSet map[string]struct{}
 
-func newStringSet(items []string) stringSet {
-	s := make(stringSet, len(items))
-	for _, item := range items {
-		s[item] = struct{}{}
-	}
-	return s
-}
-
 func (s stringSet) Contains(item string) bool {
 	_, ok := s[item]
 	return ok
 }
 
+// stringsFlag collects the values of a flag that may be repeated.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
 // mergeMaps combines multiple maps. Keys in later maps overwrite earlier ones.
 func mergeMaps(maps ...map[string]string) map[string]string {
 	result := make(map[string]string)
@@ -133,218 +52,2046 @@ func mergeMaps(maps ...map[string]string) map[string]string {
 }
 
 // detectProjectType checks for landmark files to determine the project type.
+// A monorepo yields several, joined with commas as -type takes them.
 func detectProjectType(srcDir string) string {
-	landmarkFiles := map[string]string{
-		"go.mod":        "go",
-		"Cargo.toml":    "rust",
-		"build.gradle":  "android",
-		"Package.swift": "ios",
-		"Podfile":       "ios",
-		"pubspec.yaml":  "flutter",
+	found := bundler.DetectProjectTypes(os.DirFS(srcDir))
+	if len(found) == 0 {
+		printMsg("autodetect-failed")
+		return "generic"
+	}
+	var types, described []string
+	for _, d := range found {
+		types = append(types, d.Type)
+		if d.Dir == "." {
+			described = append(described, d.Type)
+		} else {
+			described = append(described, fmt.Sprintf("%s (%s/)", d.Type, d.Dir))
+		}
 	}
+	printMsg("autodetected", strings.Join(described, ", "))
+	return strings.Join(types, ",")
+}
+
+// --- Bundling ---
+
+// bundleOptions holds the resolved filtering rules and output settings for a single run.
+type bundleOptions struct {
+	bundler.Options
+	verbose bool // Print every walk decision with the rule that made it.
 
-	for landmark, projectType := range landmarkFiles {
-		if _, err := os.Stat(filepath.Join(srcDir, landmark)); err == nil {
-			fmt.Printf("Auto-detected project type: %s\n", projectType)
-			return projectType
+	annotate         bool // Emit an imports/exports summary line above each code block.
+	elideBoilerplate bool // Collapse long runs of repetitive entries.
+	trackChanges     bool // Keep a manifest and write a "changed since last bundle" file.
+	manifestMtimes   bool // Also record modification times in the manifest.
+	chunkIDs         bool // Emit a stable chunk ID above each file and record it in the manifest.
+	sourceMap        bool // Write a sidecar mapping bundle lines to file lines.
+	limits           watchdog
+	blamePaths       []string          // Files or directories to annotate with git blame margins.
+	fixtureDirs      stringSet         // Directories whose data files are replaced by synthetic samples; nil disables.
+	diagram          string            // Dependency diagram level: none, packages, or modules.
+	schema           bool              // Emit a consolidated SQL schema section before the files.
+	endpoints        bool              // Emit a table of HTTP route registrations before the files.
+	configKeys       bool              // Append an inventory of env vars, config keys and feature flags.
+	docCoverage      bool              // Append per-package doc comment coverage (also in the JSON artifact).
+	owners           bool              // Append the owners of each top-level directory (also in the JSON artifact).
+	stdlibIndex      bool              // Append the Go standard library imports per package.
+	generateHints    bool              // Skip generated files and list the commands that regenerate them instead.
+	formatBase64     bool              // Embed non-UTF-8 files as base64 in xml and html output.
+	apiDiff          *apiDiff          // Exported API changes between two refs, emitted before the files; nil disables.
+	deps             []moduleDep       // Third-party modules bundled under deps/ after the project's files.
+	envVars          bool              // Emit an environment variable table with defaults before the files.
+	emptyDirs        bool              // List empty directories before the files and record them in the manifest.
+	forTests         string            // Directory to gather a test-writing context for; "" bundles everything.
+	flaky            string            // Go test to gather a flaky-test investigation context for; "" bundles everything.
+	langPairs        []langPair        // Source/target languages whose files are bundled side by side when porting.
+	lockWait         time.Duration     // How long to wait for another run writing the same output.
+	formats          []string          // Artifacts to produce from the walk (md, json, zip); empty means md.
+	tokenizer        tokenizer         // Counts bundle tokens for the target model; nil disables counting.
+	model            string            // Target model name, used to look up pricing.
+	pricePerMTok     float64           // USD per million input tokens overriding the pricing table; 0 uses the table.
+	tokenReport      bool              // Print the estimated tokens of each file after bundling.
+	tokenHeader      bool              // Put the token totals and largest files at the top of the bundle.
+	maxTokens        int               // Token budget for the bundle; 0 disables the check.
+	maxTokensWarn    bool              // Only warn when the bundle exceeds maxTokens instead of failing.
+	split            splitLimit        // Per-part budget for splitting the bundle; zero writes a single file.
+	archiveMaxSize   int64             // Expand zip/tar archives up to this size (and expanded size) into virtual files; 0 disables.
+	extractDocs      bool              // Bundle the plain text of PDF, DOCX and PPTX files instead of skipping them.
+	summarizeSheets  bool              // Bundle a summary of XLSX, CSV and TSV files instead of their content.
+	gitDiff          *gitChanges       // Files changed since a git ref; only these are bundled. nil bundles everything.
+	journal          bool              // Append a record of the changes since the last run to outputFile instead of rewriting it.
+	tree             bool              // Start the bundle with a directory tree of the bundled files.
+	redactSecrets    bool              // Replace likely credentials in file contents with a marker.
+	failOnSecrets    bool              // Fail instead of writing a bundle that contains likely credentials.
+	maxFileSize      int64             // Skip, or with truncateLines cut down, files larger than this; 0 disables.
+	truncateLines    int               // Lines kept at the start and end of files over maxFileSize; 0 skips them.
+	ignoreOlder      time.Duration     // Skip files last changed longer ago than this; 0 disables.
+	ignoreNewer      time.Duration     // Skip files last changed more recently than this; 0 disables.
+	outputLabel      string            // How messages name the Markdown output when it is not a file; "" uses its path.
+	incremental      bool              // Reuse the rendered blocks of unchanged files from the previous run's cache.
+	stripComments    bool              // Remove comments from the languages in commentStrippers.
+	compact          bool              // Drop trailing whitespace and repeated blank lines, and minify JSON.
+	recoverSources   bool              // Bundle the sources in the source maps of minified files instead.
+	rootPrefix       string            // Directory the first -src root's files appear under; "" for one root.
+	roots            []sourceRoot      // Further -src roots, bundled after the first.
+	budgets          []*dirBudget      // Token budgets of path globs, from the local config.
+	removed          removedRules      // Rules the local config's remove: lists dropped.
+	srcLabel         string            // How -metadata and -report-json name the source; "" uses SrcDir.
+	metadata         bool              // Put the run's metadata at the top of the bundle as YAML front matter.
+	reportJSON       string            // File to write the machine-readable run report to.
+	classify         bool              // Label each bundled file public, internal or sensitive.
+	classRules       []classRule       // Classifications of path globs, from the local config.
+	maxClass         classLevel        // With classify, skip the files classified above this.
+	pack             bool              // Bundle the highest-priority files that fit in maxTokens instead of failing.
+	focus            ruleSet           // Path globs of the files packed first.
+	focusPackages    []string          // Go package directories (relative to -src) to bundle with their dependencies.
+	lineNumbers      bool              // Prefix each line of the bundled files with its number.
+	lineSlices       []lineSlice       // Line ranges of -include patterns such as "main.go:100-250".
+	theme            string            // highlightTheme of html output; "" for plain code.
+	binaryMode       string            // One of binaryModes.
+	binaryMaxSize    int64             // Largest binary file -binary-mode=base64 embeds.
+	transformers     []*plugin         // -transform plugins, in order.
+	detectors        []*plugin         // -detect plugins.
+	sinks            []*plugin         // -sink plugins.
+	profile          profileHeat       // Runtime heat of the source files from -profile; nil without one.
+	coverageOrder    bool              // -order coverage: hottest files first.
+	maxLineLength    int               // Characters a line may have before it is cut or wrapped; 0 disables.
+	wrapLongLines    bool              // Wrap lines over maxLineLength instead of cutting them.
+	templateScrub    *strings.Replacer // Replaces project-specific names in the Markdown with placeholders; nil disables.
+	question         string            // Written at the start and end of the bundle and recorded in the manifest.
+	questions        []string          // A batch of questions about the bundle, each written to a prompt file of its own.
+	transcript       *transcript       // Earlier conversation appended to the bundle; nil without one.
+}
+
+// fileEntry describes a file that passed all filters and will be bundled.
+type fileEntry = bundler.File
+
+// availableProjectTypes returns the names of all built-in presets.
+func availableProjectTypes() []string {
+	return bundler.ProjectTypes()
+}
+
+// resolveOptions determines the project type and combines its preset with
+// the project's local config file and any command-line overrides. Empty
+// override strings keep the preset defaults. The common ignore directories
+// are always added unless noDefaultIgnores is set.
+func resolveOptions(srcDir, projectType, ignoreDirsStr, ignoreExtsStr string, noDefaultIgnores bool) (bundleOptions, error) {
+	local, _, err := loadLocalConfig(srcDir)
+	if err != nil {
+		return bundleOptions{}, err
+	}
+	return resolveConfigOptions(local, srcDir, projectType, ignoreDirsStr, ignoreExtsStr, noDefaultIgnores)
+}
+
+// resolveConfigOptions is resolveOptions with the local config already
+// loaded.
+func resolveConfigOptions(local localConfig, srcDir, projectType, ignoreDirsStr, ignoreExtsStr string, noDefaultIgnores bool) (bundleOptions, error) {
+	if projectType == "auto" && local.Extends != "" {
+		for _, t := range strings.Split(local.Extends, ",") {
+			if _, ok := bundler.Presets[t]; !ok {
+				return bundleOptions{}, fmt.Errorf("%s: unknown preset '%s' in 'extends'. Available types are: %s", local.file, t, strings.Join(availableProjectTypes(), ", "))
+			}
 		}
+		projectType = local.Extends
+	}
+	if projectType == "auto" {
+		projectType = detectProjectType(srcDir)
 	}
 
-	if matches, _ := filepath.Glob(filepath.Join(srcDir, "*.xcodeproj")); len(matches) > 0 {
-		fmt.Println("Auto-detected project type: ios")
-		return "ios"
+	// Several comma-separated types compose their presets: every preset's
+	// rules apply, and on a language conflict the type named first wins.
+	types := strings.Split(projectType, ",")
+	configs := make([]bundler.ProjectConfig, len(types))
+	for i, t := range types {
+		config, ok := bundler.Presets[t]
+		if !ok {
+			return bundleOptions{}, fmt.Errorf("invalid project type '%s'. Available types are: %s", t, strings.Join(availableProjectTypes(), ", "))
+		}
+		if local.Override {
+			config = bundler.ProjectConfig{LangMap: config.LangMap}
+		}
+		configs[i] = config
+	}
+	var ignoreSuffixes []string
+	langMaps := []map[string]string{bundler.BaseLangMap}
+	for i := range configs {
+		ignoreSuffixes = append(ignoreSuffixes, configs[i].IgnoreSuffixes...)
+		langMaps = append(langMaps, configs[len(configs)-1-i].LangMap)
 	}
+	langMaps = append(langMaps, local.LangMap)
 
-	fmt.Println("Could not auto-detect project type, using 'generic' defaults.")
-	return "generic"
+	// Each rule remembers its layer so -verbose can explain every decision.
+	// The local config is more specific than the preset, so it goes first.
+
+	ignoreDirs := make(ruleSet)
+	if ignoreDirsStr != "" {
+		printMsg("custom-ignore-dirs")
+		ignoreDirs.Add(strings.Split(ignoreDirsStr, ","), "-ignore-dirs flag")
+	}
+	for _, layer := range local.layers() {
+		ignoreDirs.Add(layer.IgnoreDirs, layer.file)
+	}
+	if ignoreDirsStr == "" {
+		for i, config := range configs {
+			ignoreDirs.Add(config.IgnoreDirs, "preset "+types[i])
+		}
+		if len(types) > 1 {
+			keepProjectDirs(ignoreDirs, srcDir, types)
+		}
+	}
+	if !noDefaultIgnores {
+		ignoreDirs.Add(bundler.CommonIgnoreDirs, "common ignore list")
+	}
+	removed := removedRules{"ignore-dirs": removeRules(ignoreDirs, local.RemoveDirs, "-ignore-dirs flag")}
+
+	ignoreExts := make(ruleSet)
+	if ignoreExtsStr != "" {
+		printMsg("custom-ignore-exts")
+		ignoreExts.Add(strings.Split(ignoreExtsStr, ","), "-ignore-exts flag")
+	}
+	for _, layer := range local.layers() {
+		ignoreExts.Add(layer.IgnoreExts, layer.file)
+	}
+	if ignoreExtsStr == "" {
+		for i, config := range configs {
+			ignoreExts.Add(config.IgnoreExts, "preset "+types[i])
+		}
+	}
+	removed["ignore-exts"] = removeRules(ignoreExts, local.RemoveExts, "-ignore-exts flag")
+
+	ignorePaths := make(ruleSet)
+	for _, layer := range local.layers() {
+		ignorePaths.Add(layer.IgnorePaths, layer.file)
+	}
+	for i, config := range configs {
+		ignorePaths.Add(config.IgnorePaths, "preset "+types[i])
+	}
+	removed["ignore-paths"] = removeRules(ignorePaths, local.RemovePaths, "")
+
+	return bundleOptions{Options: bundler.Options{
+		SrcDir:         srcDir,
+		ProjectType:    projectType,
+		IgnoreDirs:     ignoreDirs,
+		IgnoreExts:     ignoreExts,
+		IgnoreSuffixes: ignoreSuffixes,
+		IgnorePaths:    ignorePaths,
+		LangMap:        mergeMaps(langMaps...),
+		Style:          bundler.Styles["github"],
+		Placeholders:   "skip",
+		Minified:       "skip",
+		GitIgnore:      true,
+		GitExcludes:    globalGitExcludes(srcDir),
+		BundlerIgnore:  true,
+		IgnoreMarker:   true,
+	}, removed: removed}, nil
 }
 
-// isBinaryFile checks the first 1KB for null bytes to detect binary content.
-func isBinaryFile(path string) (bool, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return false, err
+// removedRules holds the rules removed from each ignore list, by its key in
+// the local config.
+type removedRules map[string]ruleSet
+
+// removeRules drops rules from set as a remove: list of the local config
+// asks and returns them with the layer they came from. Rules given by the
+// flag named flagSource stay, as flags win over the file.
+func removeRules(set ruleSet, rules []string, flagSource string) ruleSet {
+	removed := make(ruleSet)
+	for _, rule := range rules {
+		if source, ok := set[rule]; ok && source != flagSource {
+			removed[rule] = source
+			delete(set, rule)
+		}
 	}
-	defer file.Close()
+	return removed
+}
 
-	// Read up to 1024 bytes from the file.
-	buffer := make([]byte, 1024)
-	n, err := file.Read(buffer)
-	if err != nil && err != io.EOF {
-		return false, err
+// keepProjectDirs drops the preset rules that would ignore the directory of
+// one of the composed project types, such as the flutter preset's "android"
+// rule when an Android app lives in android/.
+func keepProjectDirs(ignoreDirs ruleSet, srcDir string, types []string) {
+	composed := make(stringSet)
+	for _, t := range types {
+		composed[t] = struct{}{}
+	}
+	for _, d := range bundler.DetectProjectTypes(os.DirFS(srcDir)) {
+		if !composed.Contains(d.Type) || d.Dir == "." {
+			continue
+		}
+		for _, name := range strings.Split(d.Dir, "/") {
+			if strings.HasPrefix(ignoreDirs[name], "preset ") {
+				delete(ignoreDirs, name)
+			}
+		}
+	}
+	// Detection does not look inside the directories the root's preset
+	// ignores, so check those at the top level directly.
+	for name, source := range ignoreDirs {
+		if !strings.HasPrefix(source, "preset ") {
+			continue
+		}
+		if t, ok := bundler.DetectProjectType(os.DirFS(filepath.Join(srcDir, name))); ok && composed.Contains(t) {
+			delete(ignoreDirs, name)
+		}
 	}
+}
 
-	// The actual slice of bytes read might be smaller than the buffer.
-	readBytes := buffer[:n]
+// collectFiles walks the source tree with bundler.Collect, applying the
+// -max-runtime deadline and printing every decision in verbose mode.
+func collectFiles(opts bundleOptions) ([]fileEntry, map[string][]string, error) {
+	walk, decisions := walkOptions(opts)
+	files, _, err := bundler.Collect(walk)
+	return files, decisions.skipped, err
+}
 
-	// A null byte is a strong indicator of a binary file.
-	return bytes.Contains(readBytes, []byte{0}), nil
+// walkOptions returns the library options of a walk for opts, whose
+// decisions go to the returned log.
+func walkOptions(opts bundleOptions) (bundler.Options, *decisionLog) {
+	decisions := newDecisionLog(opts.verbose)
+	walk := opts.Options
+	walk.Deadline = opts.limits.deadline
+	walk.SkipGenerated = opts.generateHints
+	walk.OnDecision = func(path, reason, rule string) {
+		if reason == "" {
+			decisions.include(path, rule)
+			return
+		}
+		decisions.skip(path, reason, rule)
+		if reason == bundler.ReasonReadError {
+			log.Printf("Could not read file %s: %s", path, rule)
+		}
+	}
+	walk.OnSecret = func(path, rule string) {
+		log.Printf("Warning: bundling secret file %s (%s) because of -unsafe-include-secrets", path, rule)
+	}
+	return walk, decisions
 }
 
 // --- Main Execution ---
 
 func main() {
-	var availableTypes []string
-	for k := range projectConfigs {
-		availableTypes = append(availableTypes, k)
+	setLocale(localeFromEnv())
+
+	if len(os.Args) < 2 || os.Args[1] != "version" && os.Args[1] != "self-update" {
+		warnIfOutdated()
+	}
+
+	// Subcommands are dispatched before flag parsing so they can define their own flags.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "advise":
+			runAdvise(os.Args[2:])
+			return
+		case "stats":
+			runStats(os.Args[2:])
+			return
+		case "version":
+			runVersion(os.Args[2:])
+			return
+		case "self-update":
+			runSelfUpdate(os.Args[2:])
+			return
+		case "doctor":
+			runDoctor(os.Args[2:])
+			return
+		case "check":
+			runCheck(os.Args[2:])
+			return
+		case "annotate":
+			runAnnotate(os.Args[2:])
+			return
+		case "comments":
+			runComments(os.Args[2:])
+			return
+		case "daemon":
+			runDaemon(os.Args[2:])
+			return
+		case "resolve":
+			runResolve(os.Args[2:])
+			return
+		case "mount":
+			runMount(os.Args[2:])
+			return
+		case "compact":
+			runCompact(os.Args[2:])
+			return
+		case "unbundle":
+			runUnbundle(os.Args[2:])
+			return
+		case "grep":
+			runGrep(os.Args[2:])
+			return
+		case "onboard":
+			runOnboard(os.Args[2:])
+			return
+		case "incident":
+			runIncident(os.Args[2:])
+			return
+		case "warm":
+			runWarm(os.Args[2:])
+			return
+		case "clean":
+			runClean(os.Args[2:])
+			return
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "test-rules":
+			runTestRules(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "lint":
+			runLint(os.Args[2:])
+			return
+		case "similar":
+			runSimilar(os.Args[2:])
+			return
+		case "plugins":
+			runPlugins(os.Args[2:])
+			return
+		case "init":
+			runInit(os.Args[2:])
+			return
+		}
 	}
 
-	// 1. Define and parse command-line flags.
-	srcDir := flag.String("src", ".", "Source project directory.")
-	outputFile := flag.String("output", "bundle.md", "Output markdown file.")
-	projectType := flag.String("type", "auto", "Project type. Options: "+strings.Join(availableTypes, ", "))
+	// 1. Define and parse command-line flags. Invalid ones end with the
+	// RESULT line too.
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	var srcDirs stringsFlag
+	flag.Var(&srcDirs, "src", "Source project directory, or a git URL to clone, as URL[@ref][:/subdir]. Defaults to the current directory. May be repeated, or given as arguments, to bundle several roots; see -src-prefix.")
+	var srcPrefixes stringsFlag
+	flag.Var(&srcPrefixes, "src-prefix", "Directory the files of the corresponding -src appear under, in -src order. May be repeated. Defaults to each root's base name when there are several roots.")
+	outputFile := flag.String("output", "bundle.md", "Output markdown file, or - to write the bundle to stdout (messages then go to stderr).")
+	clipboard := flag.Bool("clipboard", false, "Copy the bundle to the system clipboard. Without -output it is copied instead of written to a file.")
+	outputDir := flag.String("output-dir", "", "Directory to write the output to (created if missing) when -output is a relative name. Defaults to the project config's 'output-dir'.")
+	projectType := flag.String("type", "auto", "Project type. Options: "+strings.Join(availableProjectTypes(), ", "))
 	reportSkipped := flag.Bool("report-skipped", false, "Report all skipped files and reasons.")
 	ignoreDirsStr := flag.String("ignore-dirs", "", "Comma-separated list of directories to ignore. Overrides the project type's default.")
 	ignoreExtsStr := flag.String("ignore-exts", "", "Comma-separated list of file extensions to ignore. Overrides the project type's default.")
-	flag.Parse()
+	annotate := flag.Bool("annotate", false, "Add a one-line summary of imports and exported symbols above each code file.")
+	elide := flag.Bool("elide-boilerplate", false, "Collapse long runs of repetitive code (getters/setters, test tables, const blocks) into a single elision line.")
+	styleName := flag.String("style", "github", "Output style controlling headers and fences. Options: "+strings.Join(bundler.StyleNames(), ", "))
+	finalNewline := flag.String("final-newline", "pad", "How fenced blocks end: pad puts a newline before every closing fence; mark closes the fence right after a file's final newline and marks files without one with a \"\\ No newline at end of file\" line. Unbundle with the same value.")
+	templateFile := flag.String("template", "", "Render each file, and optionally the start and end of the bundle, with this Go text/template file instead of -style's framing. It defines \"file\" (fields .Path, .RelPath, .Lang, .Annotation, .Content, .Size, .Index, .Fence) and optionally \"header\" and \"footer\".")
+	maxRuntime := flag.Duration("max-runtime", 0, "Abort cleanly with a partial bundle after this much wall-clock time (e.g. 5m). 0 disables the limit.")
+	maxOutputStr := flag.String("max-output-size", "", "Abort cleanly with a partial bundle before the output exceeds this size (e.g. 50MB). Empty disables the limit.")
+	noDefaultIgnores := flag.Bool("no-default-ignores", false, "Do not ignore the common junk directories (node_modules, .venv, dist, ...) shared by all presets.")
+	placeholders := flag.String("placeholders", "skip", "How to handle cloud placeholder files (OneDrive, Dropbox, iCloud) that are not downloaded: skip, stub, or hydrate.")
+	trackChanges := flag.Bool("track-changes", false, "Keep a manifest next to the output and write a companion file listing paths changed since the last bundle.")
+	manifestMtimes := flag.Bool("manifest-mtimes", false, "With -track-changes, also record each file's modification time in the manifest.")
+	at := flag.String("at", "", "Bundle the tree as it was at this git commit, tag, branch or date (e.g. v2.3, 2024-01-31) without touching the working directory.")
+	blame := flag.String("blame", "", "Comma-separated files or directories (relative to -src) to annotate with git blame margins: commit, author and age per line.")
+	fakeFixtures := flag.Bool("fake-fixtures", false, "Replace CSV/TSV/JSON/JSONL files in fixture directories with small schema-preserving synthetic samples.")
+	fixtureDirsStr := flag.String("fixture-dirs", strings.Join(defaultFixtureDirs, ","), "Comma-separated directory names treated as fixtures by -fake-fixtures.")
+	diagram := flag.String("diagram", "none", "Embed a Mermaid dependency diagram at the top of the bundle. Options: "+strings.Join(availableDiagramLevels(), ", "))
+	schema := flag.Bool("schema", false, "Emit a consolidated \"current schema\" section from schema.sql/structure.sql or, failing that, the project's SQL migrations.")
+	endpoints := flag.Bool("endpoints", false, "Emit a table of HTTP routes (net/http, gin, echo, chi, express, fastify, Spring, Flask/FastAPI) with their registration sites.")
+	configKeys := flag.Bool("config-keys", false, "Append an inventory of environment variables, config keys (viper, config.get, @Value) and feature flags with their usage sites.")
+	withDeps := flag.String("with-dep", "", "Comma-separated Go modules to bundle under /deps/ alongside the project, e.g. github.com/some/lib@v1.4.2. Without @version, the version in go.mod is used. Fetched with 'go mod download'.")
+	depsSource := flag.String("deps-source", "auto", "Where -with-dep takes module source from in projects with a vendor/ directory: auto (vendor/ when it has the requested version), vendor, or proxy.")
+	apiDiffRange := flag.String("api-diff", "", "Emit the exported Go API changes between two git refs, e.g. v1.2.0..HEAD, for prompts about breaking changes and changelogs.")
+	generateHints := flag.Bool("generate-hints", false, "Leave out generated code (files marked \"Code generated ... DO NOT EDIT.\") and list the //go:generate directives and generator configs (sqlc, buf, gqlgen, ...) that recreate it.")
+	stdlibIndex := flag.Bool("stdlib-index", false, "Append an index of the Go standard library packages each package imports, calling out unsafe, reflect, cgo and other notable imports.")
+	owners := flag.Bool("owners", false, "Append the owners of each top-level directory from CODEOWNERS and OWNERS files, with their handles, teams and emails; also added to the JSON artifact as \"owners\".")
+	docCoverage := flag.Bool("doc-coverage", false, "Append a per-package report of exported symbols without doc comments; also added to the JSON artifact as \"docCoverage\".")
+	envVars := flag.Bool("env-vars", false, "Emit an \"Environment variables\" section listing every variable read, with its default where statically determinable.")
+	flaky := flag.String("flaky", "", "Bundle a flaky-test investigation context for this Go test (e.g. TestServerShutdown): its package and tests, the project packages it imports, the project's test helpers and fixtures, the parallelism, goroutines, timing and randomness in them, and the latest commits to those files.")
+	forTests := flag.String("for-tests", "", "Bundle a \"write tests for X\" context for this directory (relative to -src): its code and existing tests, the exported API of the project packages it imports, and the project's test helpers and fixtures.")
+	pairLangs := flag.String("pair-langs", "", "For porting projects: bundle each file of the first language next to its ported counterpart in the second, e.g. java=kotlin or objectivec=swift. Comma-separate several pairs.")
+	emptyDirs := flag.Bool("empty-dirs", false, "List empty directories in the bundle and the -track-changes manifest so the exact directory layout can be recreated.")
+	lockWait := flag.Duration("lock-wait", 0, "How long to wait when another run is writing the same output (e.g. 30s). 0 fails immediately.")
+	ignorePathsStr := flag.String("ignore-paths", "", "Comma-separated path globs relative to -src to ignore in addition to the preset's (e.g. \"docs/generated/**,**/*.pb.go\").")
+	var includes, excludes stringsFlag
+	flag.Var(&includes, "include", "Include only files whose path relative to -src matches this glob (\"**\" spans directories, e.g. \"src/**/*.go\"). A :FROM-TO suffix (e.g. \"main.go:100-250\") bundles only those lines of the files. Repeatable; composes with -only and the preset's ignore rules.")
+	flag.Var(&excludes, "exclude", "Skip files and directories whose path relative to -src matches this glob (e.g. \"**/*_test.go\"). Repeatable; adds to the preset's ignore rules.")
+	only := flag.String("only", "", "Deny-by-default mode: include only files matching these comma-separated path globs. The entry \"preset\" allows every file in a language the preset knows. Ignore rules still apply.")
+	formatStr := flag.String("format", "md", "Comma-separated artifacts to produce from a single walk: "+strings.Join(availableFormats(), ", ")+". json, zip, xml and html are written next to -output.")
+	formatBase64 := flag.Bool("format-base64", false, "In xml and html output, embed files that are not valid UTF-8 as base64 of their bytes instead of replacing the invalid sequences with U+FFFD.")
+	theme := flag.String("theme", "github", "Color theme of the syntax highlighting in html output: "+strings.Join(highlightThemeNames(), ", ")+", or none for plain code.")
+	lang := flag.String("lang", "", "Language for CLI messages: "+strings.Join(availableLocales(), ", ")+". Defaults to LC_ALL, LC_MESSAGES or LANG.")
+	flag.BoolVar(&plainOutput, "plain", plainOutput, "Screen-reader and log friendly output: no emoji or decorative symbols. Enabled automatically when TERM=dumb.")
+	colorMode := flag.String("color", "auto", "Colorize console output: auto (only on a terminal, honoring NO_COLOR and -plain), always, or never.")
+	model := flag.String("model", "", "Target model (e.g. gpt-4o, claude-sonnet-4, llama-3) or tokenizer ("+strings.Join(availableTokenizers(), ", ")+") used to estimate the bundle's token count.")
+	tokenReport := flag.Bool("token-report", false, "Print a table of the estimated tokens per file (largest first) and in total after bundling.")
+	tokenHeaderFlag := flag.Bool("token-header", false, "Put the estimated total tokens and the largest files by tokens at the top of the bundle.")
+	maxTokens := flag.Int("max-tokens", 0, "Fail, removing the Markdown bundle, when it is estimated at more than this many tokens. 0 disables the check.")
+	splitTokens := flag.Int("split-tokens", 0, "Write the bundle as <output>.part1.md, .part2.md, ... of at most this many estimated tokens each, never dividing a file's block, plus <output>.index.md listing the files of each part.")
+	splitBytes := flag.String("split-bytes", "", "Like -split-tokens, with a size limit per part (e.g. 500KB).")
+	splitOverlap := flag.Int("split-overlap", 0, "With -split-tokens or -split-bytes, start each part after the first with the -tree section and the last this many lines of the previous part's final file, for parts read on their own.")
+	maxTokensWarn := flag.Bool("max-tokens-warn", false, "With -max-tokens, keep the bundle and only warn when it is over budget.")
+	price := flag.Float64("price", 0, "USD per million input tokens for cost estimates, overriding the built-in pricing table for -model.")
+	rootLabel := flag.String("root-label", "/", "What the source root is shown as in file headers, e.g. \"myrepo\" or \"/srv/app\" to match a container image layout.")
+	pathPrefix := flag.String("path-prefix", "", "Rewrite paths in file headers: a prefix to prepend (e.g. \"services/api\"), or OLD=NEW to replace a leading directory (\"src=\" strips src/).")
+	buildContextStr := flag.String("build-context", "", "Only bundle Go files that build for this target, e.g. linux/amd64 or linux/amd64,netgo,cgo (GOOS/GOARCH, then build tags; cgo enables cgo).")
+	markBuildExcluded := flag.Bool("mark-build-excluded", false, "With -build-context, bundle the excluded Go files with a note instead of skipping them.")
+	oneFileSystem := flag.Bool("one-file-system", false, "Like tar and rsync: do not descend into mounted volumes, network mounts or overlay mounts below -src.")
+	noWizard := flag.Bool("no-wizard", false, "Do not ask which large directories and extensions to exclude when an interactive run finds no "+localConfigFile+".")
+	interactive := flag.Bool("interactive", false, "Before bundling, list the files that pass the filters as a checkbox tree and let you toggle files and directories. The choice can be saved for -selection.")
+	selection := flag.String("selection", "", "Leave out the paths in a selection file saved by -interactive, one -ignore-paths glob per line.")
+	editorConfig := flag.Bool("editorconfig", false, "Decode files in the charset (latin1, utf-16be, utf-16le, utf-8-bom) and line endings (crlf, cr) their .editorconfig declares, instead of bundling the bytes as they are.")
+	chunkIDs := flag.Bool("chunk-ids", false, "Write a stable ID (from path and content) above each file and record the IDs in a manifest next to the output; `project-bundler resolve` maps cited IDs back to file:line.")
+	noGitignore := flag.Bool("no-gitignore", false, "Do not skip files matched by .gitignore files (root and nested), .git/info/exclude and the global git excludes file.")
+	noBundlerignore := flag.Bool("no-bundlerignore", false, "Do not skip files matched by .bundlerignore files.")
+	sourceMapFlag := flag.Bool("source-map", false, "Write <output>.sourcemap.json mapping the bundle's line numbers to file and line; `project-bundler resolve -source-map` translates them.")
+	expandArchivesFlag := flag.Bool("expand-archives", false, "Bundle the text files inside zip, tar and tar.gz archives in the project (e.g. test fixtures) under virtual paths like testdata/fixture.zip!/users.json.")
+	archiveMaxSizeStr := flag.String("archive-max-size", "1MB", "With -expand-archives, leave archives larger than this, or expanding to more than this, skipped as binary.")
+	extractDocs := flag.Bool("extract-docs", false, "Bundle the plain text of PDF, DOCX and PPTX files (specs, RFCs and other docs) instead of skipping them as binary.")
+	summarizeSheets := flag.Bool("summarize-sheets", false, "Bundle XLSX workbooks and long CSV/TSV files as a summary (sheet names, row and column counts, header and first rows) instead of skipping or bundling them whole.")
+	includeSecrets := flag.Bool("unsafe-include-secrets", false, "Bundle files on the built-in secret list (.env, *.pem, *.key, id_rsa*, credentials.json, .npmrc with tokens, ...), which every preset otherwise skips. Each one is logged.")
+	confirmSizeStr := flag.String("confirm-size", "500MB", "Ask for confirmation before writing a bundle estimated above this size; without a terminal, fail unless -yes is given. 0 disables the check.")
+	yes := flag.Bool("yes", false, "Write bundles over -confirm-size without asking.")
+	gitDiffRef := flag.String("git-diff", "", "Bundle only the files added or modified since this git ref (e.g. main or HEAD~3), including uncommitted changes and untracked files.")
+	gitDiffPatch := flag.Bool("git-diff-patch", false, "With -git-diff, also emit the unified diff against the ref in a section before the files.")
+	maxFileSizeStr := flag.String("max-file-size", "", "Skip files larger than this (e.g. 500KB), such as lock files, minified code and large fixtures. Empty disables the limit.")
+	ignoreOlder := flag.String("ignore-older-than", "", "Skip files whose last commit (or mtime, for files git does not track) is older than this, e.g. 2y, 6mo, 3w or 10d.")
+	ignoreNewer := flag.String("ignore-newer-than", "", "Skip files whose last commit (or mtime, for files git does not track) is more recent than this, e.g. 1d.")
+	truncateLines := flag.Int("truncate", 0, "With -max-file-size, keep files over the limit but show only their first and last N lines, with a marker for the lines left out.")
+	redactSecrets := flag.Bool("redact-secrets", false, "Scan file contents for likely credentials (AWS keys, private key blocks, API tokens, high-entropy strings) and replace them with a [REDACTED ...] marker, listing each by file and line.")
+	failOnSecrets := flag.Bool("fail-on-secrets", false, "Scan file contents like -redact-secrets, but fail without leaving a bundle behind if anything is found.")
+	tree := flag.Bool("tree", false, "Start the bundle with an ASCII directory tree (like tree(1)) of the files it contains, for structural context before their contents.")
+	journal := flag.Bool("journal", false, "Treat -output as an append-only journal: each run appends a record with only the files added, changed or removed since the previous one. Squash it with 'project-bundler compact'.")
+	jobs := flag.Int("jobs", 0, "Number of top-level directories walked, and files checked and read, at once; 0 uses one per CPU and 1 works through them one at a time. Output does not depend on it.")
+	stripComments := flag.Bool("strip-comments", false, "Remove comments from Go, Java, Kotlin, Swift, Rust, JavaScript/TypeScript, C#, Dart, Python, shell, YAML and CSS files to fit more code into a token budget. Directives such as //go:build and Python type comments stay.")
+	compact := flag.Bool("compact", false, "Remove trailing whitespace and collapse runs of blank lines into one; minify JSON files.")
+	normalizeEOL := flag.Bool("normalize-eol", false, "Convert CRLF and CR line endings to LF in every bundled file.")
+	recoverSrc := flag.Bool("recover-sources", false, "Bundle the original sources embedded in the source maps of minified files instead of skipping or stubbing them.")
+	ref := flag.String("ref", "", "Branch, tag or commit to clone when -src is a git URL; overrides an @ref in the URL.")
+	minified := flag.String("minified", "skip", "How to handle minified JavaScript/CSS and webpack or rollup output: skip, stub, or include.")
+	binaryMode := flag.String("binary-mode", "skip", "How to handle binary files such as images and fonts: skip, list (a stub with the size and media type), or base64 (embed files up to -binary-max-size, list larger ones).")
+	binaryMaxSize := flag.String("binary-max-size", "64KB", "Largest binary file -binary-mode=base64 embeds.")
+	printConfigFlag := flag.Bool("print-config", false, "Print the effective configuration (project type, ignore rules with the layer each came from, the rules "+localConfigFile+" removed, budgets, classifications) and exit without bundling.")
+	metadata := flag.Bool("metadata", false, "Start the bundle with YAML front matter describing it: tool version, generation time, source, preset, git commit, file count, bytes and (with a tokenizer) tokens.")
+	reportJSON := flag.String("report-json", "", "Write the complete run report (metadata, included files with sizes and tokens, skipped files with reason codes) to this JSON file, for CI pipelines.")
+	pack := flag.Bool("pack", false, "With -max-tokens, when the files do not all fit, bundle the most important ones that do instead of failing: files matching -focus, then entry points, then the rest, recently changed and smaller files first. The files left out are listed in an index at the end of the bundle.")
+	highlighter := flag.String("highlighter", "", "Name the languages of code fences the way this highlighter expects: "+strings.Join(bundler.HighlighterNames(), ", ")+", or none for the detected IDs as they are. Defaults to the renderer of -style: prism for obsidian, highlightjs for chatgpt, github otherwise.")
+	lineNumbers := flag.Bool("line-numbers", false, "Prefix each line of the bundled files with its line number, so answers can cite exact locations.")
+	focus := flag.String("focus", "", "Comma-separated path globs relative to -src whose files -pack bundles first (e.g. \"internal/auth/**,cmd/server/*.go\"); implies -pack. In a Go module, a package directory (e.g. \"./cmd/server\") bundles only that package and the project packages it imports, dependencies first.")
+	maxClass := flag.String("max-classification", "", "Skip the files classified above this level (public, internal or sensitive), e.g. internal to keep credentials and paths the local config marks sensitive out of the bundle. Files are classified whenever this is set or the local config has classification rules.")
+	transform := flag.String("transform", "", "Comma-separated transformer plugins (project-bundler-plugin-<name> on PATH) to pass file contents through, in order.")
+	detect := flag.String("detect", "", "Comma-separated detector plugins whose findings join the scan of -redact-secrets or -fail-on-secrets.")
+	sink := flag.String("sink", "", "Comma-separated sink plugins to hand the finished bundle to, e.g. to upload it.")
+	maxLineLength := flag.Int("max-line-length", 0, "Cut lines longer than N characters, such as minified code or embedded base64, with a marker for the rest. 0 disables.")
+	longLines := flag.String("long-lines", "truncate", "What -max-line-length does with longer lines: truncate, or wrap them onto lines of at most N characters.")
+	templateScrub := flag.String("template-scrub", "", "Share the bundle as a reusable template: replace project-specific names with placeholders throughout the Markdown, given as NAME=PLACEHOLDER pairs, comma-separated. auto stands for the module path and app name.")
+	question := flag.String("question", "", "The question the bundle is the context for, e.g. \"Why does login fail after token refresh?\". It is written at the start and the end of the bundle and recorded in the manifest.")
+	questionsFile := flag.String("questions", "", "File of questions to ask about the same bundle, one per line. Each gets a prompt file next to the bundle (bundle.question1.md, ...) that names the shared bundle, so one upload serves the batch.")
+	attachTranscript := flag.String("attach-transcript", "", "Append an earlier conversation, as a JSON list of chat messages or a Markdown transcript, so a follow-up bundle carries the discussion along with the refreshed code.")
+	transcriptBudgetStr := flag.String("transcript-budget", "20k", "Tokens -attach-transcript may use; the earliest messages are left out to fit.")
+	watch := flag.Bool("watch", false, "Keep running and write the bundle again whenever a file it would contain is added, changed or removed.")
+	watchInterval := flag.Duration("watch-interval", time.Second, "With -watch, how often to rescan the tree; the bundle is written once a scan finds no further changes.")
+	incremental := flag.Bool("incremental", false, "Keep a cache of the rendered files next to -output (bundle.cache.json) and reuse it for the files unchanged since the previous run, which are then not read again.")
+	order := flag.String("order", "depth-first", "Order of the files in the bundle. Options: "+strings.Join(bundler.Orders, ", ")+", coverage. depth-first follows the directory tree; ties in the others are broken by path. coverage puts the files hottest in the -profile first.")
+	profile := flag.String("profile", "", "Go coverage profile (go test -coverprofile) or pprof profile (e.g. cpu.pprof) whose hottest files -order coverage puts first and -pack keeps first.")
+	verbose := flag.Bool("verbose", false, "Print every include/skip decision with the rule and configuration layer that made it.")
+	statsFile := flag.String("stats-file", os.Getenv("PROJECT_BUNDLER_STATS_FILE"), "Opt-in local file to append usage stats to (defaults to $PROJECT_BUNDLER_STATS_FILE). Nothing is recorded when empty.")
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		writeResultLine(resultPath, bundleResult{}, err)
+		os.Exit(2)
+	}
+	resultPath = *outputFile
+	if *lang != "" {
+		setLocale(*lang)
+	}
+	// With -output -, stdout carries the bundle and nothing else.
+	bundleStdout := os.Stdout
+	if *outputFile == "-" {
+		os.Stdout = os.Stderr
+	}
+	if err := setColorMode(*colorMode); err != nil {
+		fatalf("%v", err)
+	}
 
-	// 2. Determine and load project configuration.
-	finalProjectType := *projectType
-	if finalProjectType == "auto" {
-		finalProjectType = detectProjectType(*srcDir)
+	// 2. Optionally clone remote repositories, or swap in a historical
+	// snapshot of the source tree.
+	srcDirs = append(srcDirs, flag.Args()...)
+	if len(srcDirs) == 0 {
+		srcDirs = stringsFlag{"."}
+	}
+	srcDir := &srcDirs[0]
+	srcLabel := *srcDir // Before a remote -src is replaced by its clone.
+	firstRemote := false
+	for i := range srcDirs {
+		refFlag := *ref
+		if i > 0 {
+			refFlag = "" // -ref is for the first -src; the others give URL@ref.
+		}
+		if name, pluginRef, ok := parsePluginSource(srcDirs[i]); ok {
+			if *at != "" || *gitDiffRef != "" || *watch {
+				fatalf("A plugin -src has no history or working tree; do not combine it with -at, -git-diff or -watch.")
+			}
+			dir, n, remove, err := fetchPluginSource(name, pluginRef)
+			if err != nil {
+				fatalf("Could not fetch '%s': %v", srcDirs[i], err)
+			}
+			fmt.Printf("Fetched %d files from plugin '%s'.\n", n, name)
+			srcDirs[i], exitCleanups = dir, append(exitCleanups, remove)
+			continue
+		}
+		remote, ok := parseRemoteSource(srcDirs[i], refFlag)
+		if !ok {
+			continue
+		}
+		if *at != "" || *gitDiffRef != "" || *watch {
+			fatalf("A remote -src is cloned without history; use URL@ref or -ref instead of -at, and do not combine it with -git-diff or -watch.")
+		}
+		dir, commit, remove, err := cloneRemote(remote)
+		if err != nil {
+			fatalf("Could not clone '%s': %v", remote.url, err)
+		}
+		label := remote.ref
+		if label == "" {
+			label = "the default branch"
+		}
+		fmt.Printf("Cloned '%s' at %s (commit %.12s).\n", remote.url, label, commit)
+		srcDirs[i], exitCleanups = dir, append(exitCleanups, remove)
+		firstRemote = firstRemote || i == 0
+	}
+	if *ref != "" && !firstRemote {
+		fatalf("-ref selects the revision of a remote -src; use -at for a local repository.")
+	}
+	if len(srcDirs) > 1 && (*at != "" || *gitDiffRef != "" || *watch) {
+		fatalf("Several -src roots cannot be combined with -at, -git-diff or -watch.")
+	}
+	bundleSrc := *srcDir
+	if *at != "" {
+		dir, commit, remove, err := checkoutRevision(*srcDir, *at)
+		if err != nil {
+			fatalf("Could not check out '%s': %v", *at, err)
+		}
+		fmt.Printf("Bundling '%s' as of %s (commit %.12s).\n", *srcDir, *at, commit)
+		bundleSrc, exitCleanups = dir, append(exitCleanups, remove)
 	}
 
-	config, ok := projectConfigs[finalProjectType]
+	// 3. Determine and load project configuration.
+	opts, err := resolveOptions(bundleSrc, *projectType, *ignoreDirsStr, *ignoreExtsStr, *noDefaultIgnores)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	localConfig, hasLocalConfig, err := loadLocalConfig(bundleSrc)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	// The local config's output settings apply unless their flags are given.
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	if localConfig.Style != "" && !setFlags["style"] {
+		*styleName = localConfig.Style
+	}
+	// Without -output, the name comes from the local config or the preset and
+	// may use placeholders; a relative name goes in -output-dir.
+	if !setFlags["output"] {
+		switch {
+		case localConfig.Output != "":
+			*outputFile = expandOutputName(localConfig.Output, opts)
+		case bundler.Presets[opts.ProjectType].Output != "":
+			*outputFile = expandOutputName(bundler.Presets[opts.ProjectType].Output, opts)
+		}
+	}
+	if localConfig.OutputDir != "" && !setFlags["output-dir"] {
+		*outputDir = localConfig.OutputDir
+	}
+	if *outputDir != "" && !filepath.IsAbs(*outputFile) && *outputFile != "-" {
+		*outputFile = filepath.Join(expandOutputName(*outputDir, opts), *outputFile)
+	}
+	if dir := filepath.Dir(*outputFile); dir != "." && (*outputDir != "" || !setFlags["output"]) {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			fatalf("Could not create output directory: %v", err)
+		}
+	}
+	resultPath = *outputFile
+	if opts.budgets, err = parseBudgets(localConfig.Budgets, localConfig.file); err != nil {
+		fatalf("%v", err)
+	}
+	if opts.classRules, err = parseClassRules(localConfig.Classes, localConfig.file); err != nil {
+		fatalf("%v", err)
+	}
+	opts.maxClass = classSensitive
+	if *maxClass != "" {
+		if opts.maxClass, err = parseClassLevel(*maxClass); err != nil {
+			fatalf("Invalid -max-classification: %v", err)
+		}
+	}
+	opts.classify = len(opts.classRules) > 0 || *maxClass != ""
+	opts.annotate = *annotate
+	opts.verbose = *verbose
+	opts.OneFileSystem = *oneFileSystem
+	opts.EditorConfig = *editorConfig
+	opts.NormalizeEOL = *normalizeEOL
+	if *buildContextStr != "" {
+		if opts.BuildContext, err = parseBuildContext(*buildContextStr); err != nil {
+			fatalf("Invalid -build-context: %v", err)
+		}
+		opts.MarkBuildExcluded = *markBuildExcluded
+	}
+	if *only != "" || len(includes) > 0 {
+		opts.Only = make(ruleSet)
+		if *only != "" {
+			opts.Only.Add(strings.Split(*only, ","), "-only flag")
+		}
+		for _, pattern := range includes {
+			glob, r, ok, err := parseLineSlice(pattern)
+			if err != nil {
+				fatalf("Invalid -include: %v", err)
+			}
+			if ok {
+				rule := make(ruleSet)
+				rule.Add([]string{glob}, "-include flag")
+				opts.lineSlices = append(opts.lineSlices, lineSlice{rule: rule, lineRange: r})
+			}
+			opts.Only.Add([]string{glob}, "-include flag")
+		}
+	}
+	opts.IgnorePaths.Add(excludes, "-exclude flag")
+	if *ignorePathsStr != "" {
+		opts.IgnorePaths.Add(strings.Split(*ignorePathsStr, ","), "-ignore-paths flag")
+	}
+	if *selection != "" {
+		globs, err := readSelection(*selection)
+		if err != nil {
+			fatalf("Could not read -selection: %v", err)
+		}
+		opts.IgnorePaths.Add(globs, "-selection "+*selection)
+	}
+	if *printConfigFlag {
+		printConfig(os.Stdout, opts)
+		runExitCleanups()
+		return
+	}
+	if *fakeFixtures {
+		opts.fixtureDirs = make(stringSet)
+		for _, dir := range strings.Split(*fixtureDirsStr, ",") {
+			opts.fixtureDirs[dir] = struct{}{}
+		}
+	}
+	if *blame != "" {
+		opts.blamePaths = strings.Split(*blame, ",")
+	}
+	opts.elideBoilerplate = *elide
+	opts.stripComments = *stripComments
+	opts.compact = *compact
+	opts.lineNumbers = *lineNumbers
+	if opts.lineNumbers && (opts.stripComments || opts.compact || opts.elideBoilerplate) {
+		fatalf("-line-numbers numbers the lines as they are in the files; it cannot be combined with -strip-comments, -compact or -elide-boilerplate, which remove lines.")
+	}
+	opts.recoverSources = *recoverSrc
+	if _, remote := parseRemoteSource(srcLabel, ""); !remote {
+		srcLabel, _ = filepath.Abs(srcLabel)
+	}
+	opts.srcLabel = srcLabel
+	opts.metadata = *metadata
+	opts.reportJSON = *reportJSON
+	if opts.metadata && *journal {
+		fatalf("-metadata changes with every run; it cannot be combined with -journal.")
+	}
+	roots, err := resolveSourceRoots(srcDirs, srcPrefixes, *projectType, *ignoreDirsStr, *ignoreExtsStr, *noDefaultIgnores)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	opts.rootPrefix, opts.roots = roots[0].prefix, roots[1:]
+	for _, root := range opts.roots {
+		printMsg("also-bundling", root.dir, root.prefix, root.preset.ProjectType)
+	}
+	style, ok := bundler.Styles[*styleName]
 	if !ok {
-		log.Fatalf("Invalid project type '%s'. Available types are: %s", finalProjectType, strings.Join(availableTypes, ", "))
+		fatalf("Invalid style '%s'. Available styles are: %s", *styleName, strings.Join(bundler.StyleNames(), ", "))
+	}
+	opts.Style, err = style.WithPaths(*rootLabel, *pathPrefix)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if *highlighter != "" {
+		if opts.Style, err = opts.Style.WithHighlighter(*highlighter); err != nil {
+			fatalf("Invalid -highlighter: %v", err)
+		}
+	}
+	if opts.Style.MarkNoNewline, err = parseFinalNewline(*finalNewline); err != nil {
+		fatalf("%v", err)
+	}
+	opts.trackChanges = *trackChanges
+	opts.manifestMtimes = *manifestMtimes
+	opts.chunkIDs = *chunkIDs
+	opts.sourceMap = *sourceMapFlag
+	if *templateFile != "" {
+		if opts.sourceMap {
+			fatalf("-template cannot be combined with -source-map: the line a file starts on depends on the template.")
+		}
+		if opts.Style.Template, err = bundler.ParseTemplate(*templateFile); err != nil {
+			fatalf("Invalid -template: %v", err)
+		}
+	}
+	opts.GitIgnore = !*noGitignore
+	opts.BundlerIgnore = !*noBundlerignore
+	opts.AllowSecrets = *includeSecrets
+	maxOutput, err := parseByteSize(*maxOutputStr)
+	if err != nil {
+		fatalf("Invalid -max-output-size: %v", err)
+	}
+	opts.limits = watchdog{maxRuntime: *maxRuntime, maxOutput: maxOutput}
+	if opts.maxFileSize, err = parseByteSize(*maxFileSizeStr); err != nil {
+		fatalf("Invalid -max-file-size: %v", err)
+	}
+	if opts.ignoreOlder, err = parseAge(*ignoreOlder); err != nil {
+		fatalf("Invalid -ignore-older-than: %v", err)
+	}
+	if opts.ignoreNewer, err = parseAge(*ignoreNewer); err != nil {
+		fatalf("Invalid -ignore-newer-than: %v", err)
+	}
+	if opts.ignoreOlder > 0 && opts.ignoreNewer >= opts.ignoreOlder {
+		fatalf("-ignore-newer-than must be shorter than -ignore-older-than, or no file would be left.")
+	}
+	if *truncateLines < 0 || *truncateLines > 0 && opts.maxFileSize == 0 {
+		fatalf("-truncate takes a positive number of lines and requires -max-file-size.")
+	}
+	opts.truncateLines = *truncateLines
+	if *maxLineLength < 0 {
+		fatalf("-max-line-length takes a positive number of characters.")
+	}
+	opts.maxLineLength = *maxLineLength
+	switch *longLines {
+	case "truncate":
+	case "wrap":
+		opts.wrapLongLines = true
+	default:
+		fatalf("Invalid -long-lines value '%s'. Use truncate or wrap.", *longLines)
+	}
+	if *expandArchivesFlag {
+		if opts.archiveMaxSize, err = parseByteSize(*archiveMaxSizeStr); err != nil || opts.archiveMaxSize <= 0 {
+			fatalf("Invalid -archive-max-size '%s'", *archiveMaxSizeStr)
+		}
+	}
+	opts.extractDocs = *extractDocs
+	opts.summarizeSheets = *summarizeSheets
+	opts.tree = *tree
+	if *jobs < 0 {
+		fatalf("-jobs must not be negative.")
+	}
+	opts.Jobs = *jobs
+	if *order == "coverage" {
+		if *profile == "" {
+			fatalf("-order coverage needs a -profile to rank the files by.")
+		}
+		opts.coverageOrder, *order = true, "depth-first" // For the files the profile does not cover.
+	}
+	if !slices.Contains(bundler.Orders, *order) {
+		fatalf("Invalid order '%s'. Available orders are: %s, coverage", *order, strings.Join(bundler.Orders, ", "))
+	}
+	opts.Order = *order
+	if *profile != "" {
+		if opts.profile, err = readProfile(*profile); err != nil {
+			fatalf("Invalid -profile: %v", err)
+		}
+	}
+	if *redactSecrets && *failOnSecrets {
+		fatalf("Use either -redact-secrets or -fail-on-secrets, not both.")
+	}
+	opts.redactSecrets, opts.failOnSecrets = *redactSecrets, *failOnSecrets
+	if !slices.Contains(availableDiagramLevels(), *diagram) {
+		fatalf("Invalid -diagram value '%s'. Use %s.", *diagram, strings.Join(availableDiagramLevels(), ", "))
+	}
+	opts.diagram = *diagram
+	opts.schema = *schema
+	opts.endpoints = *endpoints
+	opts.emptyDirs = *emptyDirs
+	if *pairLangs != "" {
+		if opts.langPairs, err = parseLangPairs(*pairLangs); err != nil {
+			fatalf("Invalid -pair-langs: %v", err)
+		}
+	}
+	if *forTests != "" {
+		if info, err := os.Stat(filepath.Join(bundleSrc, *forTests)); err != nil || !info.IsDir() {
+			fatalf("Invalid -for-tests '%s': not a directory under '%s'", *forTests, *srcDir)
+		}
+		opts.forTests = *forTests
+	}
+	if *flaky != "" && *forTests != "" {
+		fatalf("Use either -flaky or -for-tests, not both.")
+	}
+	opts.flaky = *flaky
+	opts.configKeys = *configKeys
+	opts.docCoverage = *docCoverage
+	opts.owners = *owners
+	opts.stdlibIndex = *stdlibIndex
+	opts.generateHints = *generateHints
+	if !slices.Contains(availableDepSources(), *depsSource) {
+		fatalf("Invalid -deps-source value '%s'. Use %s.", *depsSource, strings.Join(availableDepSources(), ", "))
+	}
+	if *withDeps != "" {
+		vendored := readVendorModules(bundleSrc)
+		for _, problem := range vendorMismatches(vendored, readGoSum(bundleSrc)) {
+			log.Printf("Warning: %s", problem)
+		}
+		for _, spec := range strings.Split(*withDeps, ",") {
+			dep, warnings, err := resolveDep(bundleSrc, strings.TrimSpace(spec), *depsSource, vendored)
+			if err != nil {
+				fatalf("Invalid -with-dep: %v", err)
+			}
+			for _, w := range warnings {
+				log.Printf("Warning: %s", w)
+			}
+			// Never bundle a dependency twice: once from vendor/ and once under deps/.
+			if _, ok := vendored[dep.Path]; ok {
+				opts.IgnorePaths.Add([]string{"vendor/" + dep.Path + "/**"}, "-with-dep "+dep.Path)
+			}
+			opts.deps = append(opts.deps, dep)
+		}
+	}
+	if *gitDiffPatch && *gitDiffRef == "" {
+		fatalf("-git-diff-patch requires -git-diff")
+	}
+	if *gitDiffRef != "" {
+		if opts.gitDiff, err = computeGitChanges(*srcDir, *gitDiffRef, *gitDiffPatch); err != nil {
+			fatalf("Invalid -git-diff: %v", err)
+		}
+	}
+	if *apiDiffRange != "" {
+		if opts.apiDiff, err = computeAPIDiff(*srcDir, *apiDiffRange); err != nil {
+			fatalf("Invalid -api-diff: %v", err)
+		}
+	}
+	opts.envVars = *envVars
+	opts.question = strings.TrimSpace(*question)
+	if *questionsFile != "" {
+		if opts.question != "" || *journal {
+			fatalf("-questions keeps the questions out of the shared bundle; it cannot be combined with -question or -journal.")
+		}
+		if opts.questions, err = readQuestions(*questionsFile); err != nil {
+			fatalf("Invalid -questions file %s: %v", *questionsFile, err)
+		}
+	}
+	opts.lockWait = *lockWait
+	opts.formatBase64 = *formatBase64
+	if _, ok := highlightThemes[*theme]; ok {
+		opts.theme = *theme
+	} else if *theme != "none" {
+		fatalf("Invalid -theme '%s': use %s or none", *theme, strings.Join(highlightThemeNames(), ", "))
+	}
+	if opts.formats, err = parseFormats(*formatStr); err != nil {
+		fatalf("Invalid -format: %v", err)
+	}
+	if *templateScrub != "" {
+		if len(opts.formats) > 0 && !slices.Equal(opts.formats, []string{"md"}) {
+			fatalf("-template-scrub only scrubs the Markdown bundle and cannot be combined with other -format values.")
+		}
+		if opts.templateScrub, err = parseTemplateScrub(*templateScrub, opts.SrcDir); err != nil {
+			fatalf("Invalid -template-scrub: %v", err)
+		}
+	}
+	if *model != "" {
+		if opts.tokenizer, err = tokenizerForModel(*model); err != nil {
+			fatalf("Invalid -model: %v", err)
+		}
+	} else if *price > 0 {
+		fatalf("-price needs -model to count tokens")
+	} else if *tokenReport || *tokenHeaderFlag || *maxTokens > 0 || *splitTokens > 0 || len(opts.budgets) > 0 || *attachTranscript != "" {
+		opts.tokenizer = tokenizers["o200k"] // As for `check`, whose default model is gpt-4o.
+	}
+	if *maxTokens < 0 {
+		fatalf("Invalid -max-tokens %d", *maxTokens)
+	}
+	if *attachTranscript != "" {
+		budget, err := parseTokenCount(*transcriptBudgetStr)
+		if err != nil || budget <= 0 {
+			fatalf("Invalid -transcript-budget '%s'", *transcriptBudgetStr)
+		}
+		if opts.transcript, err = readTranscript(*attachTranscript, opts.tokenizer, budget); err != nil {
+			fatalf("Could not attach the transcript: %v", err)
+		}
+	}
+	opts.tokenReport = *tokenReport
+	opts.tokenHeader = *tokenHeaderFlag
+	opts.maxTokens = *maxTokens
+	opts.incremental = *incremental
+	if opts.incremental && (!wantsMarkdown(opts.formats) || len(opts.formats) > 1 || opts.sourceMap || opts.chunkIDs) {
+		fatalf("-incremental caches only the Markdown bundle; it cannot be combined with other -format values, -source-map or -chunk-ids.")
+	}
+	opts.maxTokensWarn = *maxTokensWarn
+	opts.focus = make(ruleSet)
+	var focusGlobs []string
+	for _, entry := range strings.Split(*focus, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		if dir, ok := focusPackageDir(bundleSrc, entry); ok {
+			opts.focusPackages = append(opts.focusPackages, dir)
+			opts.focus.Add([]string{path.Join(dir, "*")}, "-focus flag")
+			continue
+		}
+		focusGlobs = append(focusGlobs, entry)
+	}
+	if (*pack || len(focusGlobs) > 0) && *maxTokens == 0 {
+		fatalf("-pack and -focus globs need a -max-tokens budget to pack the files into.")
+	}
+	opts.pack = *pack || *focus != "" && *maxTokens > 0
+	opts.focus.Add(focusGlobs, "-focus flag")
+	if *splitTokens > 0 && *splitBytes != "" {
+		fatalf("Use either -split-tokens or -split-bytes, not both.")
+	}
+	opts.split.tokens = *splitTokens
+	if *splitBytes != "" {
+		if opts.split.bytes, err = parseByteSize(*splitBytes); err != nil || opts.split.bytes <= 0 {
+			fatalf("Invalid -split-bytes '%s'", *splitBytes)
+		}
+	}
+	if opts.split.overlap = *splitOverlap; opts.split.overlap < 0 || opts.split.overlap > 0 && opts.split.tokens == 0 && opts.split.bytes == 0 {
+		fatalf("-split-overlap takes a number of lines and needs -split-tokens or -split-bytes.")
+	}
+	if (opts.split.tokens > 0 || opts.split.bytes > 0) && opts.sourceMap {
+		fatalf("-source-map cannot be combined with -split-tokens or -split-bytes: its line numbers are for a single bundle file.")
+	}
+	if opts.journal = *journal; opts.journal && (opts.sourceMap || opts.split.tokens > 0 || opts.split.bytes > 0) {
+		fatalf("-journal cannot be combined with -source-map, -split-tokens or -split-bytes: records hold only each run's changes.")
+	}
+	opts.model = *model
+	opts.pricePerMTok = *price
+	switch *placeholders {
+	case "skip", "stub", "hydrate":
+		opts.Placeholders = *placeholders
+	default:
+		fatalf("Invalid -placeholders value '%s'. Use skip, stub, or hydrate.", *placeholders)
+	}
+	switch *minified {
+	case "skip", "stub":
+		opts.Minified = *minified
+	case "include":
+		if *recoverSrc {
+			fatalf("-recover-sources replaces the minified files -minified skips or stubs; it cannot be combined with -minified=include.")
+		}
+		opts.Minified = ""
+	default:
+		fatalf("Invalid -minified value '%s'. Use skip, stub, or include.", *minified)
+	}
+	if !slices.Contains(binaryModes, *binaryMode) {
+		fatalf("Invalid -binary-mode value '%s'. Use %s.", *binaryMode, strings.Join(binaryModes, ", "))
+	}
+	if opts.binaryMode = *binaryMode; opts.binaryMode != "skip" {
+		opts.Binary = "stub"
+	}
+	if opts.binaryMaxSize, err = parseByteSize(*binaryMaxSize); err != nil {
+		fatalf("Invalid -binary-max-size: %v", err)
 	}
+	if *detect != "" && !opts.redactSecrets && !opts.failOnSecrets {
+		fatalf("-detect adds to the secret scan; use it with -redact-secrets or -fail-on-secrets.")
+	}
+	if *sink != "" && *watch {
+		fatalf("-sink hands over a finished bundle; it cannot be combined with -watch.")
+	}
+	opts.transformers = startPlugins("-transform", *transform, pluginTransformer)
+	opts.detectors = startPlugins("-detect", *detect, pluginDetector)
+	opts.sinks = startPlugins("-sink", *sink, pluginSink)
+	defer closePlugins(opts)
 
-	var finalIgnoreDirs []string
-	if *ignoreDirsStr != "" {
-		fmt.Println("Using custom ignore-dirs list from command-line flag.")
-		finalIgnoreDirs = strings.Split(*ignoreDirsStr, ",")
-	} else {
-		finalIgnoreDirs = config.IgnoreDirs
+	// Interactive first runs choose their exclusions before bundling.
+	if !hasLocalConfig && !*noWizard && *at == "" && isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		if err := runFirstRunWizard(&opts); err != nil {
+			fatalf("First-run wizard failed: %v", err)
+		}
+	}
+	if *interactive {
+		if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+			fatalf("-interactive needs a terminal; save a selection with it once and pass it as -selection elsewhere.")
+		}
+		if err := runPicker(&opts); err != nil {
+			fatalf("-interactive: %v", err)
+		}
 	}
 
-	var finalIgnoreExts []string
-	if *ignoreExtsStr != "" {
-		fmt.Println("Using custom ignore-exts list from command-line flag.")
-		finalIgnoreExts = strings.Split(*ignoreExtsStr, ",")
-	} else {
-		finalIgnoreExts = config.IgnoreExts
+	if confirmSize, err := parseByteSize(*confirmSizeStr); err != nil {
+		fatalf("Invalid -confirm-size: %v", err)
+	} else if confirmSize > 0 {
+		if err := confirmLargeBundle(opts, confirmSize, *yes); err != nil {
+			fatalf("%v", err)
+		}
+	}
+
+	// Stdout and clipboard bundles are rendered to a temporary file first, so
+	// the checks that discard a bundle (-fail-on-secrets, -max-tokens) leave
+	// nothing half-written behind there either.
+	toStdout, toClipboardOnly := *outputFile == "-", *clipboard && !setFlags["output"]
+	if (toStdout || *clipboard) && !wantsMarkdown(opts.formats) {
+		fatalf("-output - and -clipboard need the md format.")
+	}
+	if *clipboard {
+		if _, err := clipboardCommand(); err != nil {
+			fatalf("Cannot use -clipboard: %v", err)
+		}
+	}
+	if toStdout || toClipboardOnly {
+		if len(opts.formats) > 1 || opts.journal || opts.split.tokens > 0 || opts.split.bytes > 0 || opts.trackChanges || opts.sourceMap || opts.chunkIDs || opts.incremental || len(opts.questions) > 0 {
+			fatalf("-output - and -clipboard without -output write only the Markdown bundle; they cannot be combined with other -format values, -journal, -split-tokens, -split-bytes, -track-changes, -source-map, -chunk-ids, -incremental or -questions.")
+		}
+		dir, err := os.MkdirTemp("", "project-bundler-")
+		if err != nil {
+			fatalf("Could not create a temporary file: %v", err)
+		}
+		exitCleanups = append(exitCleanups, func() { os.RemoveAll(dir) })
+		*outputFile = filepath.Join(dir, "bundle.md")
+		opts.outputLabel, resultPath = tr("clipboard-label"), "clipboard"
+		if toStdout {
+			opts.outputLabel, resultPath = tr("stdout-label"), "-"
+		}
+	}
+
+	if *watch {
+		if toStdout || *clipboard || *at != "" {
+			fatalf("-watch rewrites an -output file as the tree changes; it cannot be combined with -output -, -clipboard or -at.")
+		}
+		if *watchInterval <= 0 {
+			fatalf("-watch-interval must be positive.")
+		}
+	}
+
+	// 4. Walk, filter, and write the bundle.
+	start := time.Now()
+	result, err := writeBundle(opts, *outputFile, *reportSkipped)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if toStdout || *clipboard {
+		data, err := os.ReadFile(*outputFile)
+		if err != nil {
+			fatalf("Could not read back the bundle: %v", err)
+		}
+		if toStdout {
+			if _, err := bundleStdout.Write(data); err != nil {
+				fatalf("Could not write the bundle to stdout: %v", err)
+			}
+		}
+		if *clipboard {
+			if err := copyToClipboard(data); err != nil {
+				fatalf("Could not copy the bundle to the clipboard: %v", err)
+			}
+			printMsg("clipboard-copied", formatSize(int64(len(data))))
+		}
+	}
+	runExitCleanups()
+	if err := sendToSinks(opts, result); err != nil {
+		fatalf("%v", err)
 	}
-	// Extract Suffixes
-	finalIgnoreSuffixes := config.IgnoreSuffixes
 
-	ignoreDirs := newStringSet(finalIgnoreDirs)
-	ignoreExts := newStringSet(finalIgnoreExts)
+	// 5. Record local usage statistics if the user opted in.
+	if *statsFile != "" {
+		if err := recordStats(*statsFile, opts, result, time.Since(start)); err != nil {
+			log.Printf("Could not record usage stats: %v", err)
+		}
+	}
+
+	if opts.outputLabel == "" && len(result.outputs) > 0 {
+		resultPath = result.outputs[0]
+	}
+	writeResultLine(resultPath, result, nil)
+
+	if *watch {
+		watchAndRebundle(opts, *outputFile, *reportSkipped, *watchInterval)
+	}
+
+	// A partial bundle is still valid, but CI should notice it.
+	if result.truncated != "" {
+		os.Exit(2)
+	}
+}
+
+// bundleResult summarizes a completed bundling run.
+type bundleResult struct {
+	filesBundled int
+	filesSkipped int
+	skipped      map[string]int // Skipped files by reason code.
+	bytesWritten int64
+	truncated    string       // Why the watchdog stopped the run early, if it did.
+	tokens       int          // Estimated tokens in the Markdown bundle, when a tokenizer is set.
+	fileTokens   []fileTokens // Estimated tokens of each file's content, for the token report.
+	shortened    []string     // Files over -max-file-size bundled with -truncate.
+	overBudget   []string     // Files outlined or truncated to fit the local config's budgets.
+	languages    []languageShare
+	generated    time.Time             // When the run started.
+	bundled      []fileEntry           // The files in the bundle, for -report-json.
+	skippedPaths map[string][]string   // Skipped files by reason code, for -report-json.
+	outputs      []string              // The files written.
+	classes      map[string]classLevel // Each classified file's level, by path.
+	parts        []*bundlePart         // The parts of a split bundle.
+}
+
+// countingWriter counts the bytes passed through to the underlying writer.
+type countingWriter struct {
+	w     io.Writer
+	n     int64
+	lines int64 // Newlines written, for -source-map.
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.lines += int64(bytes.Count(p[:n], []byte("\n")))
+	return n, err
+}
+
+// fileObserver passes the files written by bundler.Bundle to a function.
+type fileObserver struct {
+	bundler.NopObserver
+	included func(r bundler.RenderedFile)
+}
+
+func (o *fileObserver) OnFileIncluded(r bundler.RenderedFile) { o.included(r) }
 
-	langMap := mergeMaps(baseLangMap, config.LangMap)
-	skippedFiles := make(map[string][]string)
+// writeBundle walks the source tree with the given options and writes every
+// bundled file to outputFile as a fenced Markdown block.
+func writeBundle(opts bundleOptions, outputFile string, reportSkipped bool) (bundleResult, error) {
+	result := bundleResult{generated: time.Now().UTC()}
+	for _, b := range opts.budgets {
+		b.spent = 0 // -watch bundles again with the same options.
+	}
 
-	// 3. Setup output file and buffered writer.
-	file, err := os.Create(*outputFile)
+	release, err := acquireOutputLock(outputFile, opts.lockWait)
 	if err != nil {
-		log.Fatalf("Failed to create output file: %v", err)
+		return result, err
 	}
-	defer file.Close()
+	defer release()
 
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
+	// Setup output file and buffered writer. When only other formats were
+	// requested the Markdown is still rendered (for the watchdog) but discarded.
+	var out io.Writer = io.Discard
+	var file *os.File
+	markdownPath := outputFile
+	if opts.journal {
+		// The full bundle goes to a snapshot, from which only the delta is appended.
+		markdownPath = outputFile + ".snapshot"
+		defer os.Remove(markdownPath)
+	}
+	if wantsMarkdown(opts.formats) {
+		if file, err = os.Create(markdownPath); err != nil {
+			return result, fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	artifacts, err := openArtifacts(opts.formats, outputFile, opts.formatBase64, opts.theme)
+	if err != nil {
+		return result, err
+	}
+	finished := false
+	defer func() {
+		if !finished {
+			for _, a := range artifacts {
+				a.finish(result.truncated)
+			}
+		}
+	}()
 
-	fmt.Printf("Starting to bundle project from '%s' into '%s' (type: %s)...\n", *srcDir, *outputFile, finalProjectType)
+	var tokens *tokenCountingWriter
+	if opts.tokenizer != nil {
+		tokens = &tokenCountingWriter{tok: opts.tokenizer}
+		out = io.MultiWriter(out, tokens)
+	}
+	// Per-file counts are the counter's progress over each file's block.
+	countFileTokens := tokens != nil && (opts.tokenReport || opts.tokenHeader || opts.maxTokens > 0 || opts.reportJSON != "")
+	counter := &countingWriter{w: out}
+	writer := bufio.NewWriter(counter)
+	var scrub *scrubWriter
+	if opts.templateScrub != nil {
+		scrub = &scrubWriter{w: counter, replacer: opts.templateScrub}
+		writer = bufio.NewWriter(scrub)
+	}
 
-	// 4. Walk the directory tree.
-	walkErr := filepath.WalkDir(*srcDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err // Propagate errors like permission denied.
+	// The bundle is written by the library's Bundle, with the command's file
+	// selection, sections and per-file conversions as its steps. Their
+	// results are kept here for the appendices and reports that follow.
+	var (
+		files        []fileEntry
+		skippedFiles map[string][]string
+		apiOnly      stringSet
+		flakyCtx     flakyContext
+		truncated    stringSet // Files shortened by -max-file-size with -truncate-lines.
+		pairNotes    map[string]string
+		omitted      []omittedFile
+		reads        *readAhead
+		// Contents that do not come from reading f.Path, keyed by f.Path.
+		inMemory        = make(map[string][]byte)
+		conversionNotes = make(map[string]string)
+	)
+	defer func() {
+		if reads != nil {
+			reads.close()
+		}
+	}()
+	manifest := newBundleManifest()
+	manifest.Question = opts.question
+	if opts.trackChanges || opts.chunkIDs {
+		manifest.fingerprint = newMinHash()
+	}
+	bytesByLang := make(map[string]int64)
+	var starts []blockStart // For -split-tokens and -split-bytes.
+	var secrets []secretHit // For -redact-secrets and -fail-on-secrets.
+	var lines *sourceMap
+	if opts.sourceMap && wantsMarkdown(opts.formats) {
+		lines = &sourceMap{Bundle: filepath.Base(outputFile)}
+	}
+	// With -incremental, unchanged files are copied from the cache unread.
+	var renders *renderCache
+	cachePath := sidecarPath(outputFile, ".cache.json")
+	if opts.incremental {
+		if renders, err = loadRenderCache(cachePath, renderCacheKey(opts.SrcDir)); err != nil {
+			return result, fmt.Errorf("failed to read the incremental cache: %w", err)
 		}
+	}
+	reused := 0
+	variant := func(f fileEntry) string {
+		return renderVariant(f, apiOnly.Contains(f.RelPath), truncated.Contains(f.Path), pairNotes[f.RelPath])
+	}
+	cacheable := func(f fileEntry) bool {
+		// Blame annotations change with the history, not the file, and budget
+		// cuts with the files before it. Cached blocks would also miss the
+		// manifest's record of converted encodings, classifying a file
+		// needs its content, a template can show the block's index, and
+		// detector plugins are not part of the cache key.
+		_, ok := inMemory[f.Path]
+		return renders != nil && !ok && !blameSelected(opts.blamePaths, f.RelPath) && len(matchingBudgets(opts.budgets, f.RelPath)) == 0 && f.Charset == "" && f.EOL == "" && !opts.classify && opts.Style.Template == nil && len(opts.detectors) == 0
+	}
 
-		// Skip directories that are in the ignore list.
-		if d.IsDir() {
-			if ignoreDirs.Contains(d.Name()) {
-				skippedFiles["Ignored Directory"] = append(skippedFiles["Ignored Directory"], path)
-				return filepath.SkipDir // Efficiently prune this entire directory.
+	walk, decisions := walkOptions(opts)
+	b := &bundler.Bundler{Options: walk}
+	// Walk the directory tree and collect the files that pass all filters,
+	// then apply the command's own selections.
+	b.Collect = func(walk bundler.Options) ([]fileEntry, map[string][]string, error) {
+		opts.limits.start()
+		var walkErr error
+		files, _, walkErr = bundler.Collect(walk)
+		skippedFiles = decisions.skipped
+		if errors.Is(walkErr, bundler.ErrDeadlineExceeded) {
+			// Nothing can be written in the time left; emit just the truncation notice.
+			result.truncated = opts.limits.check(0, 0)
+			files = nil
+		} else if walkErr != nil {
+			return nil, skippedFiles, fmt.Errorf("error during directory walk: %w", walkErr)
+		}
+		files = slices.DeleteFunc(files, func(f fileEntry) bool {
+			if isOwnOutput(opts, outputFile, f.Path) {
+				skippedFiles[reasonBundleOutput] = append(skippedFiles[reasonBundleOutput], f.Path)
+				return true
+			}
+			return false
+		})
+		if opts.forTests != "" {
+			ctx := selectForTests(opts, files, opts.forTests)
+			files, apiOnly = ctx.selected, ctx.apiOnly
+			if len(ctx.dropped) > 0 {
+				skippedFiles[reasonOutsideTests] = ctx.dropped
+			}
+		}
+		if opts.flaky != "" {
+			var err error
+			if flakyCtx, err = selectFlaky(opts, files, opts.flaky); err != nil {
+				return nil, skippedFiles, fmt.Errorf("-flaky: %w", err)
+			}
+			files = flakyCtx.selected
+			if len(flakyCtx.dropped) > 0 {
+				skippedFiles[reasonOutsideFlaky] = flakyCtx.dropped
+			}
+		}
+		if opts.gitDiff != nil {
+			var unchanged []string
+			if files, unchanged = opts.gitDiff.filterChanged(files); len(unchanged) > 0 {
+				skippedFiles[reasonUnchanged] = unchanged
+			}
+		}
+		files = filterByAge(opts, files, skippedFiles)
+		files, truncated = overSizeLimit(opts, files, skippedFiles)
+		if opts.extractDocs {
+			files = convertFiles(opts, files, skippedFiles, documentConverter, inMemory, conversionNotes)
+		}
+		if opts.summarizeSheets {
+			files = convertFiles(opts, files, skippedFiles, spreadsheetConverter, inMemory, conversionNotes)
+		}
+		if len(opts.transformers) > 0 {
+			transformFiles(opts, files, inMemory, conversionNotes)
+		}
+		if opts.archiveMaxSize > 0 {
+			archiveFiles, contents := expandArchives(opts, skippedFiles, opts.archiveMaxSize)
+			files = append(files, archiveFiles...)
+			maps.Copy(inMemory, contents)
+			bundler.SortFiles(files, opts.Order)
+		}
+		if opts.recoverSources {
+			var contents map[string][]byte
+			files, contents = recoverSources(opts, files, skippedFiles, conversionNotes)
+			maps.Copy(inMemory, contents)
+			bundler.SortFiles(files, opts.Order)
+		}
+		if len(opts.focusPackages) > 0 {
+			var dropped []string
+			if files, dropped = selectFocusPackages(opts, files, opts.focusPackages); len(dropped) > 0 {
+				skippedFiles[reasonOutsideFocus] = dropped
+			}
+		}
+		if opts.coverageOrder {
+			sortByHeat(files, opts.profile.forFiles(files))
+		}
+		if len(opts.langPairs) > 0 {
+			files, pairNotes = pairFiles(files, opts.langPairs)
+		}
+		prefixFiles(files, opts.rootPrefix)
+		for _, root := range opts.roots {
+			rootFiles, rootSkipped, err := collectRootFiles(opts, root)
+			if err != nil {
+				return nil, skippedFiles, fmt.Errorf("error while walking %s: %w", root.dir, err)
+			}
+			files = append(files, rootFiles...)
+			for reason, paths := range rootSkipped {
+				skippedFiles[reason] = append(skippedFiles[reason], paths...)
+			}
+		}
+		for _, dep := range opts.deps {
+			depFiles, depSkipped, err := collectDepFiles(opts, dep)
+			if err != nil {
+				return nil, skippedFiles, fmt.Errorf("error while walking %s@%s: %w", dep.Path, dep.Version, err)
+			}
+			files = append(files, depFiles...)
+			for reason, paths := range depSkipped {
+				skippedFiles[reason] = append(skippedFiles[reason], paths...)
+			}
+		}
+		if opts.pack {
+			total := len(files)
+			if files, omitted = packFiles(opts, files, inMemory, skippedFiles); len(omitted) > 0 {
+				printMsg("packed", len(files), total, opts.maxTokens, len(omitted))
 			}
-			return nil
 		}
+		// Start reading ahead while the sections before the files are written.
+		reads = startReadAhead(files, func(f fileEntry) bool {
+			if _, ok := inMemory[f.Path]; ok || f.Placeholder || f.Minified || f.Binary || f.DuplicateOf != "" {
+				return false
+			}
+			if cacheable(f) {
+				_, hit := renders.lookup(f, variant(f))
+				return !hit
+			}
+			return true
+		}, opts.Jobs)
+		return files, skippedFiles, nil
+	}
 
-		// Skip files based on extension or full filename.
-		ext := filepath.Ext(d.Name())
-		if ignoreExts.Contains(ext) || ignoreExts.Contains(d.Name()) {
-			skippedFiles["Ignored Extension/File"] = append(skippedFiles["Ignored Extension/File"], path)
-			return nil
+	var treeSection bytes.Buffer // Repeated in each part by -split-overlap.
+	b.Preamble = func(writer io.Writer, files []fileEntry) error {
+		if err := writeDocumentTemplate(writer, opts, "header", len(files), ""); err != nil {
+			return err
+		}
+		if err := writeQuestion(writer, opts.question, false); err != nil {
+			return err
+		}
+		if opts.tree {
+			if err := writeTreeSection(&treeSection, opts.Style, files); err != nil {
+				return err
+			}
+			if _, err := writer.Write(treeSection.Bytes()); err != nil {
+				return err
+			}
+		}
+		if opts.diagram != "" && opts.diagram != "none" {
+			if err := writeDiagram(writer, buildDependencyGraph(opts, files, opts.diagram), opts.diagram); err != nil {
+				return err
+			}
 		}
+		if opts.schema {
+			if err := writeSchemaSection(writer, files); err != nil {
+				return err
+			}
+		}
+		if opts.generateHints {
+			if err := writeGenerateSection(writer, findGenerateSteps(files), len(skippedFiles[bundler.ReasonGenerated])); err != nil {
+				return err
+			}
+		}
+		if opts.gitDiff != nil && opts.gitDiff.withPatch {
+			if err := writeGitDiffSection(writer, opts.gitDiff); err != nil {
+				return err
+			}
+		}
+		if opts.apiDiff != nil {
+			if err := writeAPIDiffSection(writer, opts.apiDiff); err != nil {
+				return err
+			}
+		}
+		if opts.flaky != "" {
+			if err := writeFlakySection(writer, flakyCtx); err != nil {
+				return err
+			}
+		}
+		if opts.envVars {
+			if err := writeEnvVarSection(writer, files); err != nil {
+				return err
+			}
+		}
+		if opts.endpoints {
+			if err := writeEndpointSection(writer, files); err != nil {
+				return err
+			}
+		}
+		if opts.emptyDirs {
+			dirs, err := findEmptyDirs(opts)
+			if err != nil {
+				return fmt.Errorf("error while looking for empty directories: %w", err)
+			}
+			if err := writeEmptyDirSection(writer, opts.Style, dirs); err != nil {
+				return err
+			}
+			manifest.EmptyDirs = dirs
+		}
+		return nil
+	}
 
-		// Check Suffixes
-		for _, suffix := range finalIgnoreSuffixes {
-			if strings.HasSuffix(d.Name(), suffix) {
-				skippedFiles["Ignored Suffix"] = append(skippedFiles["Ignored Suffix"], path)
-				return nil
+	// Each file's block is prepared by render and written by the library;
+	// observer then records it. pending carries what render started over.
+	var pending struct {
+		block      blockStart
+		countBlock bool          // Count the block's tokens once written.
+		cache      *renderedFile // Keep the block for -incremental, with its token count.
+	}
+	var observeErr error // The first error of observer, which cannot return it.
+	observer := &fileObserver{included: func(r bundler.RenderedFile) {
+		if observeErr != nil {
+			return
+		}
+		f := r.File
+		if r.Block == nil || pending.cache != nil {
+			for _, a := range artifacts {
+				if err := a.add(f, r.Raw, r.Content, r.Annotation); err != nil {
+					observeErr = err
+					return
+				}
 			}
 		}
+		n := 0
+		if pending.countBlock {
+			if err := writer.Flush(); err != nil {
+				observeErr = err
+				return
+			}
+			n = tokens.sofar() - pending.block.tokens
+			result.fileTokens = append(result.fileTokens, fileTokens{Path: f.RelPath, Tokens: n})
+		}
+		if pending.cache != nil {
+			pending.cache.Tokens = n
+			renders.keep(f, *pending.cache)
+		}
+		starts = append(starts, pending.block)
+		result.filesBundled++
+		result.bundled = append(result.bundled, f)
+	}}
+	b.Observer = observer
 
-		// IMPORTANT: Perform binary file detection to prevent corruption.
-		isBinary, err := isBinaryFile(path)
-		if err != nil {
-			skippedFiles["File Read Error"] = append(skippedFiles["File Read Error"], path)
-			log.Printf("Could not check file type for %s: %v", path, err)
-			return nil
+	b.Render = func(i int, f fileEntry) (bundler.RenderedFile, error) {
+		pending.countBlock, pending.cache = false, nil
+		if observeErr != nil {
+			return bundler.RenderedFile{}, observeErr
 		}
-		if isBinary {
-			skippedFiles["Detected Binary Content"] = append(skippedFiles["Detected Binary Content"], path)
-			return nil // Safely skip this binary file.
+		// Rough size of the block: content plus header and fences.
+		next := f.Size + int64(len(f.RelPath)) + 32
+		if reason := opts.limits.check(counter.n+int64(writer.Buffered()), next); reason != "" {
+			result.truncated = reason
+			return bundler.RenderedFile{}, bundler.SkipRest
 		}
 
-		// At this point, the file is considered valid for bundling.
-		fmt.Printf("  + Bundling file: %s\n", path)
-		content, err := os.ReadFile(path)
+		printMsg("bundling-file", f.Path)
+		countBlock := tokens != nil && (countFileTokens || opts.split.tokens > 0 || opts.split.bytes > 0)
+		if countBlock || scrub != nil {
+			// Flush so the counters have seen everything before the block,
+			// as scrubbed names change its length.
+			if err := writer.Flush(); err != nil {
+				return bundler.RenderedFile{}, err
+			}
+		}
+		pending.block = blockStart{offset: counter.n + int64(writer.Buffered()), relPath: f.RelPath}
+		if countBlock {
+			pending.block.tokens = tokens.sofar()
+		}
+		switch {
+		case f.Placeholder:
+			stub := []byte(fmt.Sprintf("(cloud placeholder, %s not downloaded locally; re-run with -placeholders=hydrate to include it)", formatSize(f.Size)))
+			return bundler.RenderedFile{Lang: "text", Content: stub}, nil
+		case f.Minified:
+			stub := []byte(fmt.Sprintf("(minified %s, %s not bundled; re-run with -minified=include to include it)", f.Lang, formatSize(f.Size)))
+			return bundler.RenderedFile{Lang: "text", Content: stub}, nil
+		case f.Binary:
+			stub, err := binaryBlock(opts, f)
+			if err != nil {
+				log.Printf("Could not read file %s: %v", f.Path, err)
+				return bundler.RenderedFile{}, &bundler.SkipFileError{Reason: bundler.ReasonReadError, Err: err}
+			}
+			return bundler.RenderedFile{Lang: "text", Content: stub}, nil
+		case f.DuplicateOf != "":
+			manifest.alias(f.RelPath, f.DuplicateOf)
+			manifest.addMetadata(f, opts.manifestMtimes)
+			return bundler.RenderedFile{Lang: "text", Content: opts.Style.DuplicateStub(f.DuplicateOf)}, nil
+		}
+		if cacheable(f) {
+			if r, ok := renders.lookup(f, variant(f)); ok {
+				manifest.Files[filepath.ToSlash(f.RelPath)] = r.SHA256
+				manifest.addMetadata(f, opts.manifestMtimes)
+				manifest.reuseFingerprint(r.Fingerprint)
+				for _, finding := range r.Secrets {
+					secrets = append(secrets, secretHit{f.RelPath, finding})
+				}
+				if truncated.Contains(f.Path) {
+					result.shortened = append(result.shortened, f.Path)
+				}
+				bytesByLang[f.Lang] += f.Size
+				if countFileTokens {
+					result.fileTokens = append(result.fileTokens, fileTokens{Path: f.RelPath, Tokens: r.Tokens})
+				}
+				renders.keep(f, r)
+				reused++
+				return bundler.RenderedFile{Lang: f.Lang, Block: []byte(r.Block)}, nil
+			}
+		}
+		content, ok := inMemory[f.Path]
+		var err error
+		if !ok {
+			content, err = reads.read(i)
+		}
 		if err != nil {
-			skippedFiles["File Read Error"] = append(skippedFiles["File Read Error"], path)
-			log.Printf("Could not read file %s: %v", path, err)
-			return nil
+			log.Printf("Could not read file %s: %v", f.Path, err)
+			return bundler.RenderedFile{}, &bundler.SkipFileError{Reason: bundler.ReasonReadError, Err: err}
+		}
+		if opts.classify {
+			level := classifyFile(opts, f, content)
+			if level > opts.maxClass {
+				return bundler.RenderedFile{}, &bundler.SkipFileError{Reason: reasonClassified}
+			}
+			if result.classes == nil {
+				result.classes = make(map[string]classLevel)
+			}
+			result.classes[f.Path] = level
+		}
+		manifest.add(f.RelPath, content)
+		fingerprint := manifest.addFingerprint(f.RelPath, content)
+		manifest.addMetadata(f, opts.manifestMtimes)
+		manifest.addEncoding(f, content, opts.NormalizeEOL)
+		// altered notes the transforms that leave content unlike the file's,
+		// so that unbundle does not write it back over the file.
+		var altered string
+		content, redacted := bundler.RedactSections(content)
+		if redacted {
+			altered += "Redacted: regions between bundler:redact markers removed.\n"
+		}
+		firstSecret := len(secrets)
+		if scanned := scanForSecrets(opts, f, content, &secrets); !bytes.Equal(scanned, content) {
+			content = scanned
+			altered += "Redacted: secrets replaced by -redact-secrets.\n"
+		}
+		raw := content
+		var annotation, chunk string
+		if opts.chunkIDs {
+			chunk = manifest.addChunk(f.RelPath, raw)
+		}
+		content, transcoded := bundler.Transcode(content, f.Charset, f.EOL)
+		switch {
+		case transcoded && f.CharsetDetected:
+			annotation = fmt.Sprintf("Converted from %s to UTF-8; the encoding was detected from the content.\n", f.Charset)
+		case transcoded:
+			annotation = fmt.Sprintf("Converted from %s to UTF-8 as declared by .editorconfig.\n", f.Charset)
+		}
+		if opts.NormalizeEOL {
+			content = bundler.NormalizeEOL(content)
+		}
+		// Line numbers are added last, and only to content whose lines are
+		// still the file's.
+		numbered := opts.lineNumbers && !redacted
+		var lineNos []int
+		if cut, numbers, note, ok := selectLines(opts.lineSlices, f.RelPath, content); ok {
+			content, lineNos, annotation = cut, numbers, note
+		}
+		if note, ok := conversionNotes[f.Path]; ok {
+			annotation, numbered = note, false
+		}
+		if truncated.Contains(f.Path) {
+			content, annotation = truncateContent(content, opts.truncateLines, opts.maxFileSize)
+			result.shortened = append(result.shortened, f.Path)
+			numbered = false
+		}
+		if opts.fixtureDirs != nil && inFixtureDir(opts.fixtureDirs, f.RelPath) {
+			if fake, ok := synthesizeFixture(f.RelPath, content); ok {
+				content = fake
+				annotation = "Synthetic sample: real records replaced, structure preserved.\n"
+				numbered = false
+			}
+		}
+		if f.NotBuilt {
+			annotation = fmt.Sprintf("Not compiled for %s: excluded by build constraints.\n", buildContextLabel(opts.BuildContext))
+		}
+		if note, ok := pairNotes[f.RelPath]; ok && annotation == "" {
+			annotation = note
+		}
+		if apiOnly.Contains(f.RelPath) {
+			if api, ok := goExportedAPI(f.Lang, content); ok {
+				content = api
+				annotation = "Exported API only: function bodies and unexported declarations removed.\n"
+				numbered = false
+			}
+		}
+		blamed := blameSelected(opts.blamePaths, f.RelPath)
+		if strip, ok := commentStrippers[f.Lang]; ok && opts.stripComments && !blamed {
+			if stripped := strip(content); !bytes.Equal(stripped, content) {
+				content = stripped
+				altered += "Altered: comments removed by -strip-comments.\n"
+			}
+		}
+		if opts.compact && !blamed {
+			if compacted := compactWhitespace(f.Lang, content); !bytes.Equal(compacted, content) {
+				content = compacted
+				altered += "Altered: whitespace compacted by -compact.\n"
+			}
+		}
+		if opts.elideBoilerplate && !blamed {
+			content = elideBoilerplate(content)
+		}
+		if budgets := matchingBudgets(opts.budgets, f.RelPath); len(budgets) > 0 {
+			var note string
+			if content, note = fitBudgets(budgets, opts.tokenizer, f.Lang, content); note != "" {
+				annotation = note
+				result.overBudget = append(result.overBudget, f.Path)
+				numbered = false
+			}
 		}
 
-		// Determine language for syntax highlighting.
-		var lang string
-		lang, ok = langMap[ext] // 1. Try by extension.
-		if !ok {
-			lang, ok = filenameLangMap[d.Name()] // 2. Try by full filename.
-			if !ok {
-				lang = "text" // 3. Default to plain text.
+		if opts.annotate && annotation == "" {
+			annotation = symbolSummaryLine(f.Lang, content)
+		}
+		if blamed {
+			if annotated, err := blameAnnotate(opts.SrcDir, f.RelPath, time.Now()); err != nil {
+				log.Printf("Could not blame %s, bundling it without annotations: %v", f.RelPath, err)
+			} else {
+				content, numbered = annotated, false
+			}
+		}
+		if opts.maxLineLength > 0 {
+			if limited, note := limitLineLength(content, opts.maxLineLength, opts.wrapLongLines); note != "" {
+				content, annotation = limited, annotation+note
+				numbered = numbered && !opts.wrapLongLines
 			}
 		}
+		if numbered {
+			content = numberLines(content, lineNos)
+		}
+		annotation = chunk + annotation + altered
+		if lines != nil {
+			// Flush so the counter knows the line the block starts on.
+			if err := writer.Flush(); err != nil {
+				return bundler.RenderedFile{}, err
+			}
+			lines.add(counter.lines+1, opts.Style, annotation, f.RelPath, raw, content)
+		}
+		bytesByLang[f.Lang] += int64(len(raw))
+		r := bundler.RenderedFile{Lang: f.Lang, Annotation: annotation, Content: content, Raw: raw}
+		pending.countBlock = countFileTokens
+		if cacheable(f) {
+			var rendered bytes.Buffer
+			if err := opts.Style.WriteIndexedFile(&rendered, result.filesBundled+1, f.RelPath, f.Lang, annotation, content); err != nil {
+				return bundler.RenderedFile{}, err
+			}
+			r.Block = rendered.Bytes()
+			pending.cache = &renderedFile{Variant: variant(f), SHA256: manifest.Files[filepath.ToSlash(f.RelPath)], Block: rendered.String(), Fingerprint: fingerprint}
+			for _, hit := range secrets[firstSecret:] {
+				pending.cache.Secrets = append(pending.cache.Secrets, hit.SecretFinding)
+			}
+		}
+		return r, nil
+	}
 
-		relativePath, err := filepath.Rel(*srcDir, path)
+	printMsg("starting", opts.SrcDir, outputFile, opts.ProjectType)
+	// Files that could not be read were logged and are reported as skipped.
+	var partial *bundler.PartialBundleError
+	if _, err := b.Bundle(opts.SrcDir, writer); err != nil && !errors.As(err, &partial) {
+		return result, err
+	}
+	if observeErr != nil {
+		return result, observeErr
+	}
+	result.languages = languageShares(bytesByLang)
+	if opts.configKeys && result.truncated == "" {
+		if err := writeConfigKeyAppendix(writer, files); err != nil {
+			return result, err
+		}
+	}
+	if opts.stdlibIndex && result.truncated == "" {
+		if err := writeStdlibAppendix(writer, findStdlibUsage(opts, files)); err != nil {
+			return result, err
+		}
+	}
+	if opts.docCoverage && result.truncated == "" {
+		coverage := findDocCoverage(files)
+		if err := writeDocCoverageAppendix(writer, coverage); err != nil {
+			return result, err
+		}
+		for _, a := range artifacts {
+			if j, ok := a.(*jsonArtifact); ok {
+				j.docCoverage = coverage
+			}
+		}
+	}
+	if opts.owners && result.truncated == "" {
+		owners := findOwners(opts, files)
+		if err := writeOwnersAppendix(writer, owners); err != nil {
+			return result, err
+		}
+		for _, a := range artifacts {
+			if j, ok := a.(*jsonArtifact); ok {
+				j.owners = owners
+			}
+		}
+	}
+	if err := writeOmittedIndex(writer, omitted, opts.maxTokens); err != nil {
+		return result, err
+	}
+	if err := writeTranscriptAppendix(writer, opts.transcript); err != nil {
+		return result, err
+	}
+	if err := writeQuestion(writer, opts.question, true); err != nil {
+		return result, err
+	}
+	if err := writeDocumentTemplate(writer, opts, "footer", result.filesBundled, result.truncated); err != nil {
+		return result, err
+	}
+	if result.truncated != "" {
+		notice := fmt.Sprintf("[project-bundler] Bundle truncated: %s after %d of %d files.\n", result.truncated, result.filesBundled, len(files))
+		if _, err := writer.WriteString(notice); err != nil {
+			return result, err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return result, err
+	}
+	if scrub != nil {
+		if err := scrub.Flush(); err != nil {
+			return result, err
+		}
+	}
+	// Closed here, as the token header and splitting rewrite the file.
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return result, err
+		}
+	}
+	result.bytesWritten = counter.n
+	if renders != nil {
+		if err := renders.save(cachePath); err != nil {
+			return result, fmt.Errorf("failed to write the incremental cache: %w", err)
+		}
+		printMsg("incremental-reused", reused, result.filesBundled, cachePath)
+	}
+	if opts.failOnSecrets && len(secrets) > 0 {
+		printSecretReport("secrets-found", secrets)
+		finished = true
+		for _, a := range artifacts {
+			a.finish(result.truncated)
+			os.Remove(a.path())
+		}
+		if wantsMarkdown(opts.formats) {
+			os.Remove(markdownPath)
+		}
+		return result, fmt.Errorf("found %d likely secrets; remove them, exclude their files or use -redact-secrets", len(secrets))
+	}
+	if tokens != nil {
+		result.tokens = tokens.total()
+	}
+	splitTokens := result.tokens // The bundle's total, with the headers prepended below.
+	if opts.maxTokens > 0 && result.tokens > opts.maxTokens && !opts.maxTokensWarn {
+		printTokenReport(result.fileTokens, result.tokens, opts.tokenizer.name())
+		if wantsMarkdown(opts.formats) {
+			os.Remove(markdownPath)
+		}
+		return result, fmt.Errorf("bundle is an estimated %d tokens, over the -max-tokens budget of %d; narrow it with -include/-exclude, bundle what fits with -pack or raise the budget", result.tokens, opts.maxTokens)
+	}
+	if opts.tokenHeader && wantsMarkdown(opts.formats) {
+		header := tokenHeader(result.fileTokens, result.tokens, opts.tokenizer.name())
+		if err := prependToFile(markdownPath, header); err != nil {
+			return result, fmt.Errorf("failed to write token header: %w", err)
+		}
+		if lines != nil {
+			lines.shift(int64(strings.Count(header, "\n")))
+		}
+		for i := range starts {
+			starts[i].offset += int64(len(header))
+		}
+		if tokens != nil && (opts.split.tokens > 0 || opts.split.bytes > 0) {
+			headerTokens := opts.tokenizer.count([]byte(header))
+			for i := range starts {
+				starts[i].tokens += headerTokens
+			}
+			splitTokens += headerTokens
+		}
+	}
+	if opts.metadata && wantsMarkdown(opts.formats) {
+		header := collectMetadata(opts, result).header()
+		if err := prependToFile(markdownPath, header); err != nil {
+			return result, fmt.Errorf("failed to write metadata header: %w", err)
+		}
+		if lines != nil {
+			lines.shift(int64(strings.Count(header, "\n")))
+		}
+		for i := range starts {
+			starts[i].offset += int64(len(header))
+		}
+		if tokens != nil && (opts.split.tokens > 0 || opts.split.bytes > 0) {
+			headerTokens := opts.tokenizer.count([]byte(header))
+			for i := range starts {
+				starts[i].tokens += headerTokens
+			}
+			splitTokens += headerTokens
+		}
+	}
+	if opts.journal && wantsMarkdown(opts.formats) {
+		record, changed, removed, err := appendJournal(outputFile, markdownPath, opts.Style)
 		if err != nil {
-			relativePath = path // Fallback to full path on error.
+			return result, fmt.Errorf("failed to append to journal: %w", err)
 		}
-
-		// Write the formatted block to the output buffer.
-		header := fmt.Sprintf("File: /%s\n```%s\n", relativePath, lang)
-		if _, err := writer.WriteString(header); err != nil {
-			return err
+		if record == 0 {
+			printMsg("journal-unchanged", outputFile)
+		} else {
+			printMsg("journal-appended", record, outputFile, changed, removed)
 		}
-		if _, err := writer.Write(content); err != nil {
-			return err
+	}
+	finished = true
+	var written []string
+	context := outputFile // What -questions prompts name as their context.
+	if (opts.split.tokens > 0 || opts.split.bytes > 0) && wantsMarkdown(opts.formats) {
+		price, _ := modelInputPrice(opts.model, opts.pricePerMTok)
+		indexPath, parts, err := splitBundle(outputFile, starts, opts.split, splitTokens, splitContext{treeSection.String(), opts.Style, opts.tokenizer, price})
+		if err != nil {
+			return result, fmt.Errorf("failed to split the bundle: %w", err)
 		}
-		if _, err := writer.WriteString("\n```\n\n"); err != nil {
-			return err
+		written = append(written, indexPath)
+		context = indexPath
+		result.parts = parts
+	} else if opts.outputLabel != "" {
+		written = append(written, opts.outputLabel)
+	} else if wantsMarkdown(opts.formats) {
+		written = append(written, outputFile)
+	}
+	result.outputs, result.skippedPaths = written, skippedFiles
+	result.skipped = make(map[string]int, len(skippedFiles))
+	for reason, paths := range skippedFiles {
+		result.filesSkipped += len(paths)
+		result.skipped[reason] = len(paths)
+	}
+	for _, a := range artifacts {
+		switch a := a.(type) {
+		case *jsonArtifact:
+			a.languages, a.skipped = result.languages, result.skipped
+		case *sqliteArtifact:
+			a.languages = result.languages
+		}
+		if err := a.finish(result.truncated); err != nil {
+			return result, fmt.Errorf("failed to write '%s': %w", a.path(), err)
 		}
+		written = append(written, a.path())
+	}
+	result.outputs = written
 
-		return nil
-	})
+	// Print the optional skipped files report.
+	if reportSkipped {
+		printSkippedReport(skippedFiles)
+		if len(result.shortened) > 0 {
+			printMsg("truncated-header")
+			for _, path := range result.shortened {
+				fmt.Printf("  - %s\n", path)
+			}
+		}
+		if len(result.overBudget) > 0 {
+			printMsg("over-budget-header")
+			for _, path := range result.overBudget {
+				fmt.Printf("  - %s\n", path)
+			}
+		}
+	}
 
-	if walkErr != nil {
-		log.Fatalf("Error during directory walk: %v", walkErr)
+	if opts.trackChanges {
+		changesPath, err := writeChangesFile(outputFile, manifest)
+		if err != nil {
+			return result, fmt.Errorf("failed to track changes: %w", err)
+		}
+		printMsg("changes-written", changesPath)
+	}
+	if lines != nil {
+		sourceMapPath := sidecarPath(outputFile, ".sourcemap.json")
+		if err := lines.save(sourceMapPath); err != nil {
+			return result, fmt.Errorf("failed to write source map: %w", err)
+		}
+		printMsg("sourcemap-written", sourceMapPath)
+	}
+	if len(opts.questions) > 0 && wantsMarkdown(opts.formats) {
+		prompts, err := writeQuestionPrompts(outputFile, context, result.filesBundled, opts.questions)
+		if err != nil {
+			return result, fmt.Errorf("failed to write question prompts: %w", err)
+		}
+		printMsg("prompts-written", len(prompts), prompts[0], prompts[len(prompts)-1])
+	}
+	if opts.chunkIDs && !opts.trackChanges {
+		manifestPath := sidecarPath(outputFile, ".manifest.json")
+		if err := manifest.save(manifestPath); err != nil {
+			return result, fmt.Errorf("failed to write chunk manifest: %w", err)
+		}
+		printMsg("chunks-written", manifestPath)
 	}
 
-	// 5. Print the optional skipped files report.
-	if *reportSkipped {
-		fmt.Println("\n--- Skipped Files Report ---")
-		if len(skippedFiles) == 0 {
-			fmt.Println("No files were skipped.")
-		} else {
-			for reason, paths := range skippedFiles {
-				fmt.Printf("\nReason: %s\n", reason)
-				for _, path := range paths {
-					fmt.Printf("  - %s\n", path)
-				}
+	if opts.redactSecrets && len(secrets) > 0 {
+		printSecretReport("secrets-redacted", secrets)
+	}
+	if opts.tokenReport {
+		printTokenReport(result.fileTokens, result.tokens, opts.tokenizer.name())
+	}
+	if opts.maxTokens > 0 && result.tokens > opts.maxTokens {
+		printMsg("over-token-budget", result.tokens, opts.maxTokens)
+	}
+	if tokens != nil {
+		printMsg("token-estimate", result.tokens, opts.tokenizer.name())
+		if price, ok := modelInputPrice(opts.model, opts.pricePerMTok); ok {
+			printMsg("cost-estimate", formatCost(float64(result.tokens)/1e6*price), price)
+			for _, p := range result.parts {
+				printMsg("cost-estimate-part", filepath.Base(p.path), p.tokens, formatCost(float64(p.tokens)/1e6*price))
 			}
 		}
-		fmt.Println("--------------------------")
+	}
+	if len(result.languages) > 0 {
+		printMsg("languages", formatLanguageShares(result.languages, 5))
+	}
+	if opts.classify {
+		var counts [len(classNames)]int
+		bundleLevel := classPublic
+		for _, level := range result.classes {
+			counts[level]++
+			bundleLevel = max(bundleLevel, level)
+		}
+		printMsg("classification", bundleLevel, counts[classPublic], counts[classInternal], counts[classSensitive])
+	}
+
+	if opts.reportJSON != "" {
+		if err := writeRunReport(opts.reportJSON, opts, result); err != nil {
+			return result, fmt.Errorf("failed to write '%s': %w", opts.reportJSON, err)
+		}
 	}
 
-	fmt.Printf("\n✅ Successfully created project bundle at '%s'\n", *outputFile)
+	if result.truncated != "" {
+		printMsg("partial", strings.Join(written, "', '"), result.truncated)
+		return result, nil
+	}
+	printMsg("success", strings.Join(written, "', '"))
+	return result, nil
+}
+
+// printSkippedReport prints every skipped path grouped by reason, with the
+// reason's code.
+func printSkippedReport(skippedFiles map[string][]string) {
+	printMsg("skipped-header")
+	if len(skippedFiles) == 0 {
+		printMsg("no-skipped")
+	} else {
+		for _, reason := range slices.Sorted(maps.Keys(skippedFiles)) {
+			printMsg("skip-reason", trReason(reason), reason)
+			for _, path := range slices.Sorted(slices.Values(skippedFiles[reason])) {
+				fmt.Printf("  - %s\n", path)
+			}
+		}
+	}
+	fmt.Println("--------------------------")
 }
@@ -0,0 +1,292 @@
+// project-bundler/manifest.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// bundleManifest records what went into a bundle so later runs can tell which
+// files changed since then.
+type bundleManifest struct {
+	Generated time.Time         `json:"generated"`
+	Files     map[string]string `json:"files"` // Relative path -> SHA-256 of the original content.
+	// Modes and Mtimes keep what a checkout needs beyond the content, such as
+	// the executable bit of scripts. Mtimes is only recorded on request.
+	Modes  map[string]string    `json:"modes,omitempty"` // Relative path -> permission bits in octal, e.g. "0755".
+	Mtimes map[string]time.Time `json:"mtimes,omitempty"`
+	// EmptyDirs lists directories without any entries, which have no file
+	// to carry them (-empty-dirs).
+	EmptyDirs []string `json:"emptyDirs,omitempty"`
+	// Chunks maps the chunk IDs emitted by -chunk-ids to file locations.
+	Chunks map[string]chunkRef `json:"chunks,omitempty"`
+	// Encodings records the files that were converted to UTF-8 with LF line
+	// endings for the bundle, so unbundle can write them back byte for byte.
+	Encodings map[string]fileEncoding `json:"encodings,omitempty"`
+	// Question is the -question the bundle was written for.
+	Question string `json:"question,omitempty"`
+	// Fingerprint is a MinHash signature of the bundled paths and content
+	// that `similar` compares; see minHash.
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	fingerprint minHash // Merged from the files' signatures; nil when the manifest is not saved.
+}
+
+// fileEncoding is how a file was stored on disk, in Transcode's terms.
+type fileEncoding struct {
+	Charset string `json:"charset,omitempty"` // e.g. "latin1" or "utf-16le".
+	BOM     bool   `json:"bom,omitempty"`     // The file started with a byte order mark.
+	EOL     string `json:"eol,omitempty"`     // "crlf" or "cr".
+}
+
+func newBundleManifest() *bundleManifest {
+	return &bundleManifest{Files: make(map[string]string), Modes: make(map[string]string)}
+}
+
+// add records the hash of a file's original (untransformed) content.
+func (m *bundleManifest) add(relPath string, content []byte) {
+	sum := sha256.Sum256(content)
+	m.Files[filepath.ToSlash(relPath)] = hex.EncodeToString(sum[:])
+}
+
+// addMetadata records a file's permission bits and, if requested, its
+// modification time.
+func (m *bundleManifest) addMetadata(f fileEntry, mtime bool) {
+	path := filepath.ToSlash(f.RelPath)
+	m.Modes[path] = fmt.Sprintf("%04o", f.Mode)
+	if mtime {
+		if m.Mtimes == nil {
+			m.Mtimes = make(map[string]time.Time)
+		}
+		m.Mtimes[path] = f.ModTime.UTC()
+	}
+}
+
+// addEncoding records the charset and line endings content was converted
+// from, if any. The line endings are not recorded when -normalize-eol made
+// LF the intended result.
+func (m *bundleManifest) addEncoding(f fileEntry, raw []byte, normalized bool) {
+	enc := fileEncoding{Charset: f.Charset, EOL: f.EOL}
+	if normalized {
+		enc.EOL = ""
+	}
+	switch enc.Charset {
+	case "utf-8-bom":
+		enc.BOM = bytes.HasPrefix(raw, []byte("\xef\xbb\xbf"))
+	case "utf-16le":
+		enc.BOM = bytes.HasPrefix(raw, []byte{0xff, 0xfe})
+	case "utf-16be":
+		enc.BOM = bytes.HasPrefix(raw, []byte{0xfe, 0xff})
+	}
+	if enc == (fileEncoding{}) {
+		return
+	}
+	if m.Encodings == nil {
+		m.Encodings = make(map[string]fileEncoding)
+	}
+	m.Encodings[filepath.ToSlash(f.RelPath)] = enc
+}
+
+// encode converts the UTF-8 content of the file at the slash-separated path
+// back to the encoding recorded for it, if any.
+func (m *bundleManifest) encode(path string, content []byte) []byte {
+	enc, ok := m.Encodings[path]
+	if !ok {
+		return content
+	}
+	return bundler.Encode(content, enc.Charset, enc.BOM, enc.EOL)
+}
+
+// original reports whether content is exactly what the file at the
+// slash-separated path held when the bundle was written.
+func (m *bundleManifest) original(path string, content []byte) bool {
+	sum := sha256.Sum256(content)
+	return m.Files[path] == hex.EncodeToString(sum[:])
+}
+
+// alias records relPath with the hash already recorded for another path, for
+// hard links whose content is not read twice.
+func (m *bundleManifest) alias(relPath, of string) {
+	if sum, ok := m.Files[filepath.ToSlash(of)]; ok {
+		m.Files[filepath.ToSlash(relPath)] = sum
+	}
+}
+
+// sidecarPath derives a companion file name from the output file, e.g.
+// bundle.md -> bundle.manifest.json.
+func sidecarPath(outputFile, suffix string) string {
+	return strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + suffix
+}
+
+// sidecarSuffixes are the companion files a run may leave next to -output,
+// including the lock file earlier releases kept there. Format artifacts,
+// split parts and -questions prompts are matched separately.
+var sidecarSuffixes = []string{".cache.json", ".manifest.json", ".sourcemap.json", ".changes.md", ".lock"}
+
+// isOwnOutput reports whether path is the bundle at outputFile, a file
+// written with it, or the baseline of `check`. When the output goes inside
+// the source tree these are not bundled, or every run would contain the
+// previous one.
+func isOwnOutput(opts bundleOptions, outputFile, path string) bool {
+	if isCheckBaseline(path) {
+		return true
+	}
+	out, err := filepath.Abs(outputFile)
+	if err != nil {
+		return false
+	}
+	p, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	return p == out || isSidecar(out, p, opts.formats)
+}
+
+// isSidecar reports whether path was written along with the bundle at
+// bundlePath: a sidecar, a -format artifact of one of formats, a -split part
+// or a -journal snapshot.
+func isSidecar(bundlePath, path string, formats []string) bool {
+	if path == bundlePath+".snapshot" {
+		return true
+	}
+	ext := filepath.Ext(bundlePath)
+	rest, ok := strings.CutPrefix(path, strings.TrimSuffix(bundlePath, ext))
+	switch {
+	case !ok:
+		return false
+	case slices.Contains(sidecarSuffixes, rest), rest == ".index"+ext:
+		return true
+	}
+	for _, numbered := range []string{".part", ".question"} {
+		if n, ok := strings.CutPrefix(strings.TrimSuffix(rest, ext), numbered); ok && n != "" && strings.Trim(n, "0123456789") == "" {
+			return true
+		}
+	}
+	for _, f := range formats {
+		if f == "sqlite" {
+			f = "db"
+		}
+		if f != "md" && rest == "."+f {
+			return true
+		}
+	}
+	return false
+}
+
+// loadManifest reads a previously saved manifest. A missing file is not an
+// error; it returns nil so the caller can treat the run as the first one.
+func loadManifest(path string) (*bundleManifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m bundleManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+func (m *bundleManifest) save(path string) error {
+	m.Generated = time.Now().UTC()
+	if m.fingerprint != nil {
+		m.Fingerprint = m.fingerprint.String()
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// manifestDiff lists the paths that differ between two manifests.
+type manifestDiff struct {
+	added, modified, removed []string
+}
+
+func diffManifests(prev, cur *bundleManifest) manifestDiff {
+	var d manifestDiff
+	for path, hash := range cur.Files {
+		old, ok := prev.Files[path]
+		switch {
+		case !ok:
+			d.added = append(d.added, path)
+		case old != hash:
+			d.modified = append(d.modified, path)
+		}
+	}
+	for path := range prev.Files {
+		if _, ok := cur.Files[path]; !ok {
+			d.removed = append(d.removed, path)
+		}
+	}
+	sort.Strings(d.added)
+	sort.Strings(d.modified)
+	sort.Strings(d.removed)
+	return d
+}
+
+// writeChangesFile compares the new manifest against the previous one saved
+// next to the output, writes the compact "changed since last bundle" companion
+// file, and then replaces the saved manifest with the new one.
+func writeChangesFile(outputFile string, cur *bundleManifest) (string, error) {
+	manifestPath := sidecarPath(outputFile, ".manifest.json")
+	changesPath := sidecarPath(outputFile, ".changes.md")
+
+	prev, err := loadManifest(manifestPath)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Create(changesPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+
+	if prev == nil {
+		fmt.Fprintf(w, "# Changes since last bundle\n\nNo previous bundle manifest found; all %d files are new.\n", len(cur.Files))
+	} else {
+		d := diffManifests(prev, cur)
+		fmt.Fprintf(w, "# Changes since last bundle (%s)\n", prev.Generated.Format(time.RFC3339))
+		if len(d.added)+len(d.modified)+len(d.removed) == 0 {
+			fmt.Fprintln(w, "\nNo changes.")
+		}
+		writePathList(w, "Modified", d.modified)
+		writePathList(w, "Added", d.added)
+		writePathList(w, "Removed", d.removed)
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	return changesPath, cur.save(manifestPath)
+}
+
+func writePathList(w *bufio.Writer, title string, paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, p := range paths {
+		fmt.Fprintf(w, "- /%s\n", p)
+	}
+}
@@ -0,0 +1,160 @@
+// project-bundler/mapsources.go
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// sourceMappingURLRE matches the comment build tools append to generated
+// JavaScript (//# sourceMappingURL=app.js.map) and CSS (/*# ... */).
+var sourceMappingURLRE = regexp.MustCompile(`[#@] sourceMappingURL=([^\s*]+)`)
+
+// sourceSchemeRE matches the scheme bundlers prefix to the names of the
+// sources in a source map, as in webpack://app/./src/App.tsx.
+var sourceSchemeRE = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://[^/]*/`)
+
+// assetMap is the part of an asset's version 3 source map that holds the
+// sources.
+type assetMap struct {
+	SourceRoot     string    `json:"sourceRoot"`
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent"`
+}
+
+// recoverSources implements -recover-sources: each minified file that
+// -minified skipped or stubbed is replaced by the original sources embedded
+// in its source map (sourcesContent), bundled under the asset's path like
+// archive entries, e.g. static/js/main.js!/src/App.tsx. Sources are
+// filtered by the ignore rules, so those from node_modules stay out.
+// Assets without a source map, or whose map has no embedded sources, are
+// left as they were.
+func recoverSources(opts bundleOptions, files []fileEntry, skipped map[string][]string, notes map[string]string) ([]fileEntry, map[string][]byte) {
+	contents := make(map[string][]byte)
+	var recovered []fileEntry
+	recoverAsset := func(assetPath, rel string) bool {
+		m, mapPath, err := loadAssetMap(assetPath)
+		if err != nil {
+			log.Printf("Not recovering the sources of %s: %v", assetPath, err)
+			return false
+		}
+		if m == nil {
+			return false
+		}
+		from := filepath.Base(mapPath)
+		if mapPath == assetPath {
+			from = "the source map inlined in " + from
+		}
+		info, _ := os.Stat(assetPath)
+		n := 0
+		for i, source := range m.Sources {
+			if i >= len(m.SourcesContent) || m.SourcesContent[i] == nil {
+				continue
+			}
+			name := cleanSourceName(m.SourceRoot, source)
+			content := []byte(*m.SourcesContent[i])
+			virtual := assetPath + archiveSeparator + name
+			if reason := archiveEntrySkip(opts, name, content); reason != "" {
+				skipped[reason] = append(skipped[reason], virtual)
+				continue
+			}
+			f := fileEntry{
+				Path:    virtual,
+				RelPath: rel + archiveSeparator + filepath.FromSlash(name),
+				Lang:    bundler.DetectLanguageContent(path.Base(name), opts.LangMap, content[:min(len(content), 1024)]),
+				Size:    int64(len(content)),
+				Mode:    0o644,
+			}
+			if info != nil {
+				f.ModTime = info.ModTime()
+			}
+			recovered = append(recovered, f)
+			contents[virtual] = content
+			notes[virtual] = fmt.Sprintf("Original source recovered from %s.\n", from)
+			n++
+		}
+		return n > 0
+	}
+
+	kept := files[:0]
+	for _, f := range files {
+		if f.Minified && recoverAsset(f.Path, f.RelPath) {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	skipped[bundler.ReasonMinified] = slices.DeleteFunc(skipped[bundler.ReasonMinified], func(p string) bool {
+		rel, err := filepath.Rel(opts.SrcDir, p)
+		if err != nil {
+			rel = p
+		}
+		return recoverAsset(p, rel)
+	})
+	if len(skipped[bundler.ReasonMinified]) == 0 {
+		delete(skipped, bundler.ReasonMinified)
+	}
+	return append(kept, recovered...), contents
+}
+
+// loadAssetMap reads the source map of a generated file: the one its
+// sourceMappingURL comment names, which may be inline as a data: URL, or
+// else the file's name plus .map. It returns nil if there is none.
+func loadAssetMap(assetPath string) (*assetMap, string, error) {
+	data, err := os.ReadFile(assetPath)
+	if err != nil {
+		return nil, "", err
+	}
+	// The comment is the last one in the file.
+	tail := data[max(0, len(data)-4096):]
+	mapPath := assetPath + ".map"
+	var raw []byte
+	if m := sourceMappingURLRE.FindAllSubmatch(tail, -1); m != nil {
+		url := string(m[len(m)-1][1])
+		if rest, ok := strings.CutPrefix(url, "data:"); ok {
+			meta, payload, _ := strings.Cut(rest, ",")
+			if !strings.HasSuffix(meta, ";base64") {
+				return nil, "", fmt.Errorf("unsupported inline source map encoding")
+			}
+			if raw, err = base64.StdEncoding.DecodeString(payload); err != nil {
+				return nil, "", fmt.Errorf("inline source map: %w", err)
+			}
+			mapPath = assetPath
+		} else if !strings.Contains(url, "://") {
+			mapPath = filepath.Join(filepath.Dir(assetPath), filepath.FromSlash(url))
+		}
+	}
+	if raw == nil {
+		if raw, err = os.ReadFile(mapPath); os.IsNotExist(err) {
+			return nil, "", nil
+		} else if err != nil {
+			return nil, "", err
+		}
+	}
+	var m assetMap
+	if err := json.Unmarshal(bytes.TrimPrefix(raw, []byte(")]}'")), &m); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", filepath.Base(mapPath), err)
+	}
+	return &m, mapPath, nil
+}
+
+// cleanSourceName turns the name of a source in a map into a relative,
+// slash-separated path: webpack://app/./src/App.tsx becomes src/App.tsx.
+func cleanSourceName(root, source string) string {
+	name := sourceSchemeRE.ReplaceAllString(source, "")
+	if root != "" && !strings.Contains(source, "://") {
+		name = sourceSchemeRE.ReplaceAllString(root, "") + "/" + name
+	}
+	name = path.Clean("/" + name) // Drops ../ segments that would climb out.
+	return strings.TrimPrefix(name, "/")
+}
@@ -0,0 +1,224 @@
+// project-bundler/markup.go
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// markupText makes file content safe to embed in XML and HTML: invalid UTF-8
+// sequences and characters XML 1.0 forbids (control characters other than
+// tab, newline and carriage return, and the non-characters U+FFFE/U+FFFF)
+// become U+FFFD. replaced counts the substitutions.
+func markupText(content []byte) (text string, replaced int) {
+	var b strings.Builder
+	b.Grow(len(content))
+	for len(content) > 0 {
+		r, size := utf8.DecodeRune(content)
+		if r == utf8.RuneError && size == 1 || !isXMLChar(r) {
+			r = utf8.RuneError
+			replaced++
+		}
+		b.WriteRune(r)
+		content = content[size:]
+	}
+	return b.String(), replaced
+}
+
+// isXMLChar reports whether r is in the XML 1.0 Char production.
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
+
+// markupContent decides how a file appears in a markup document. Text that
+// needed replacements is emitted as base64 of the original bytes instead when
+// base64Invalid is set (-format-base64), and reported either way.
+func markupContent(f fileEntry, raw, rendered []byte, base64Invalid bool, format string) (text string, encoded bool) {
+	text, replaced := markupText(rendered)
+	if replaced == 0 {
+		return text, false
+	}
+	if base64Invalid {
+		if raw == nil {
+			raw = rendered
+		}
+		log.Printf("%s: %s is not valid UTF-8 text; embedded as base64", format, f.RelPath)
+		return base64.StdEncoding.EncodeToString(raw), true
+	}
+	log.Printf("%s: replaced %d invalid byte sequences or control characters in %s with U+FFFD (use -format-base64 to keep the original bytes)", format, replaced, f.RelPath)
+	return text, false
+}
+
+// xmlArtifact writes the bundle as one <file> element per file:
+//
+//	<bundle generated="..."><file path="a.go" language="go" size="12">...</file></bundle>
+//
+// Files embedded as base64 carry encoding="base64".
+type xmlArtifact struct {
+	file          *os.File
+	w             *bufio.Writer
+	base64Invalid bool
+}
+
+func newXMLArtifact(path string, base64Invalid bool) (*xmlArtifact, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &xmlArtifact{file: f, w: bufio.NewWriter(f), base64Invalid: base64Invalid}
+	fmt.Fprintf(a.w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<bundle generated=\"%s\">\n", time.Now().UTC().Format(time.RFC3339))
+	return a, nil
+}
+
+// xmlAttr renders a quoted attribute value; paths can contain anything, and
+// EscapeText also escapes quotes and newlines.
+func xmlAttr(s string) string {
+	text, _ := markupText([]byte(s))
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(text))
+	return `"` + b.String() + `"`
+}
+
+func (a *xmlArtifact) add(f fileEntry, raw, rendered []byte, annotation string) error {
+	text, encoded := markupContent(f, raw, rendered, a.base64Invalid, "xml")
+	fmt.Fprintf(a.w, "<file path=%s language=%s size=\"%d\"", xmlAttr(filepath.ToSlash(f.RelPath)), xmlAttr(f.Lang), f.Size)
+	if annotation = strings.TrimSuffix(annotation, "\n"); annotation != "" {
+		fmt.Fprintf(a.w, " annotation=%s", xmlAttr(annotation))
+	}
+	if f.Placeholder {
+		a.w.WriteString(` placeholder="true"`)
+	}
+	if encoded {
+		a.w.WriteString(` encoding="base64"`)
+	}
+	// The content keeps its line breaks, which EscapeText would encode.
+	_, err := a.w.WriteString(">" + html.EscapeString(text) + "</file>\n")
+	return err
+}
+
+func (a *xmlArtifact) finish(truncated string) error {
+	if truncated != "" {
+		fmt.Fprintf(a.w, "<truncated>%s</truncated>\n", html.EscapeString(truncated))
+	}
+	a.w.WriteString("</bundle>\n")
+	err := a.w.Flush()
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (a *xmlArtifact) path() string { return a.file.Name() }
+
+// htmlArtifact writes a self-contained page with a table of contents and one
+// <pre> block per file. Files embedded as base64 become download links.
+// With a theme, code is highlighted in the page itself, without scripts, so
+// it reads the same offline.
+type htmlArtifact struct {
+	file          *os.File
+	body          *os.File // Temporary file holding the sections until the TOC is complete.
+	w             *bufio.Writer
+	toc           strings.Builder
+	count         int
+	base64Invalid bool
+	theme         string
+}
+
+func newHTMLArtifact(path string, base64Invalid bool, theme string) (*htmlArtifact, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	body, err := os.CreateTemp(filepath.Dir(path), ".project-bundler-html-*")
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &htmlArtifact{file: f, body: body, w: bufio.NewWriter(body), base64Invalid: base64Invalid, theme: theme}, nil
+}
+
+func (a *htmlArtifact) add(f fileEntry, raw, rendered []byte, annotation string) error {
+	text, encoded := markupContent(f, raw, rendered, a.base64Invalid, "html")
+	name, _ := markupText([]byte(filepath.ToSlash(f.RelPath)))
+	a.count++
+	id := fmt.Sprintf("f%d", a.count)
+	fmt.Fprintf(&a.toc, "<li><a href=\"#%s\">%s</a></li>\n", id, html.EscapeString(name))
+
+	fmt.Fprintf(a.w, "<section id=\"%s\">\n<h2>%s</h2>\n", id, html.EscapeString(name))
+	if annotation = strings.TrimSuffix(annotation, "\n"); annotation != "" {
+		note, _ := markupText([]byte(annotation))
+		fmt.Fprintf(a.w, "<p class=\"note\">%s</p>\n", html.EscapeString(note))
+	}
+	if encoded {
+		fmt.Fprintf(a.w, "<p class=\"note\">Not valid UTF-8 text: <a download=\"%s\" href=\"data:application/octet-stream;base64,%s\">download the original bytes</a> (%s).</p>\n", html.EscapeString(filepath.Base(name)), text, formatSize(f.Size))
+	} else {
+		code := html.EscapeString(text)
+		if a.theme != "" {
+			code = highlightHTML(f.Lang, text)
+		}
+		fmt.Fprintf(a.w, "<pre><code class=\"language-%s\">%s</code></pre>\n", html.EscapeString(f.Lang), code)
+	}
+	_, err := a.w.WriteString("</section>\n")
+	return err
+}
+
+func (a *htmlArtifact) finish(truncated string) error {
+	defer os.Remove(a.body.Name())
+	err := a.w.Flush()
+	if err == nil {
+		_, err = a.body.Seek(0, 0)
+	}
+	if err == nil {
+		out := bufio.NewWriter(a.file)
+		fmt.Fprintf(out, `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Project bundle</title>
+<style>
+body { font: 14px system-ui, sans-serif; max-width: 1100px; margin: 2em auto; padding: 0 1em; }
+pre { background: #f6f8fa; padding: 1em; overflow-x: auto; }
+.note { color: #57606a; font-style: italic; }
+%s</style>
+</head>
+<body>
+<h1>Project bundle</h1>
+<p>Generated %s, %d files.</p>
+`, a.themeCSS(), time.Now().UTC().Format(time.RFC3339), a.count)
+		if truncated != "" {
+			fmt.Fprintf(out, "<p class=\"note\">Truncated: %s</p>\n", html.EscapeString(truncated))
+		}
+		fmt.Fprintf(out, "<ul>\n%s</ul>\n", a.toc.String())
+		if _, err = out.ReadFrom(a.body); err == nil {
+			out.WriteString("</body>\n</html>\n")
+			err = out.Flush()
+		}
+	}
+	a.body.Close()
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// themeCSS returns the style sheet rules of the artifact's theme, if any.
+func (a *htmlArtifact) themeCSS() string {
+	if a.theme == "" {
+		return ""
+	}
+	return highlightThemes[a.theme].css()
+}
+
+func (a *htmlArtifact) path() string { return a.file.Name() }
@@ -0,0 +1,141 @@
+// project-bundler/mount.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// bundleNode is a file or directory of a mounted bundle. Inode numbers are
+// the node's index in bundleTree.nodes plus one, so the root is inode 1 as
+// FUSE expects.
+type bundleNode struct {
+	ino      uint64
+	name     string
+	parent   *bundleNode
+	content  []byte                 // Files only.
+	children map[string]*bundleNode // Directories only; nil for files.
+	sorted   []string               // Child names in lexical order, for listings.
+}
+
+// bundleTree is the read-only directory tree of a parsed bundle.
+type bundleTree struct {
+	nodes   []*bundleNode
+	modTime time.Time // Shown as every node's times: the bundle's own.
+}
+
+func (t *bundleTree) newNode(name string, parent *bundleNode, dir bool) *bundleNode {
+	n := &bundleNode{ino: uint64(len(t.nodes) + 1), name: name, parent: parent}
+	if dir {
+		n.children = make(map[string]*bundleNode)
+	}
+	t.nodes = append(t.nodes, n)
+	return n
+}
+
+// node returns the node with inode number ino, or nil.
+func (t *bundleTree) node(ino uint64) *bundleNode {
+	if ino == 0 || ino > uint64(len(t.nodes)) {
+		return nil
+	}
+	return t.nodes[ino-1]
+}
+
+// buildBundleTree lays the blocks out as directories and files. Duplicate
+// stubs get the content of the file they point to; blocks whose path leaves
+// the bundle root or collides with a directory are left out with a warning.
+func buildBundleTree(blocks []bundler.Block, modTime time.Time) *bundleTree {
+	t := &bundleTree{modTime: modTime}
+	root := t.newNode("", nil, true)
+	contents := make(map[string][]byte)
+	for _, b := range blocks {
+		contents[b.Path] = b.Content
+	}
+	for _, b := range blocks {
+		if !filepath.IsLocal(filepath.FromSlash(b.Path)) {
+			log.Printf("Leaving out %s (line %d): path leaves the bundle root", b.Path, b.Line)
+			continue
+		}
+		content := b.Content
+		if m := duplicateStubRE.FindSubmatch(bytes.TrimSpace(content)); m != nil {
+			if first, ok := contents[strings.TrimPrefix(string(m[1]), "/")]; ok {
+				content = first
+			}
+		}
+		parts := strings.Split(b.Path, "/")
+		dir := root
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := dir.children[part]
+			if !ok {
+				child = t.newNode(part, dir, true)
+				dir.children[part] = child
+			}
+			dir = child
+			if dir.children == nil {
+				break
+			}
+		}
+		name := parts[len(parts)-1]
+		if existing, ok := dir.children[name]; dir.children == nil || ok && existing.children != nil {
+			log.Printf("Leaving out %s (line %d): a file and a directory share the path", b.Path, b.Line)
+			continue
+		}
+		f := t.newNode(name, dir, false)
+		f.content = content
+		dir.children[name] = f
+	}
+	for _, n := range t.nodes {
+		for name := range n.children {
+			n.sorted = append(n.sorted, name)
+		}
+		sort.Strings(n.sorted)
+	}
+	return t
+}
+
+// runMount implements the `mount` subcommand, which serves a bundle as a
+// read-only filesystem until it is interrupted or unmounted.
+//
+//	project-bundler mount bundle.md /mnt/ctx
+func runMount(args []string) {
+	fs := flag.NewFlagSet("mount", flag.ExitOnError)
+	styleName := fs.String("style", "github", "Output style the bundle was written with. Options: "+strings.Join(bundler.StyleNames(), ", "))
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		log.Fatalf("Usage: project-bundler mount [-style name] <bundle> <mountpoint>")
+	}
+	style, ok := bundler.Styles[*styleName]
+	if !ok {
+		log.Fatalf("Invalid style '%s'. Available styles are: %s", *styleName, strings.Join(bundler.StyleNames(), ", "))
+	}
+	bundlePath, mountpoint := fs.Arg(0), fs.Arg(1)
+	info, err := os.Stat(bundlePath)
+	if err != nil {
+		log.Fatalf("Could not read bundle: %v", err)
+	}
+	// Replaying also covers journals: later blocks replace earlier ones.
+	state, err := replayJournal(bundlePath, style, nil)
+	if err != nil {
+		log.Fatalf("Could not parse bundle: %v", err)
+	}
+	blocks := state.live()
+	if len(blocks) == 0 {
+		log.Fatalf("No file blocks found in '%s'; pass -style if it was not written in the github style.", bundlePath)
+	}
+	tree := buildBundleTree(blocks, info.ModTime())
+	mounted := func() {
+		fmt.Printf("Mounted %d files from '%s' at '%s' (read-only). Press Ctrl+C or unmount it to stop.\n", len(blocks), bundlePath, mountpoint)
+	}
+	if err := serveBundle(tree, mountpoint, mounted); err != nil {
+		log.Fatalf("Could not mount bundle: %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+// project-bundler/mount_linux.go
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// FUSE opcodes and constants from linux/fuse.h. Only what a read-only
+// filesystem needs is handled; everything else is answered with ENOSYS.
+const (
+	fuseLookup      = 1
+	fuseForget      = 2
+	fuseGetattr     = 3
+	fuseOpen        = 14
+	fuseRead        = 15
+	fuseStatfs      = 17
+	fuseRelease     = 18
+	fuseFlush       = 25
+	fuseInit        = 26
+	fuseOpendir     = 27
+	fuseReaddir     = 28
+	fuseReleasedir  = 29
+	fuseAccess      = 34
+	fuseInterrupt   = 36
+	fuseDestroy     = 38
+	fuseBatchForget = 42
+
+	fuseKernelMinor   = 31
+	fuseMaxWrite      = 128 * 1024
+	fuseInHeaderSize  = 40
+	fuseOutHeaderSize = 16
+	fuseOpenKeepCache = 1 << 1
+	fuseAttrValid     = 3600 // Seconds the kernel may cache entries; a bundle never changes.
+)
+
+// serveBundle mounts tree read-only at mountpoint through /dev/fuse and
+// answers the kernel's requests until the filesystem is unmounted or the
+// process is interrupted. Mounting directly needs CAP_SYS_ADMIN; otherwise
+// the setuid fusermount3 (or fusermount) helper mounts it.
+func serveBundle(tree *bundleTree, mountpoint string, mounted func()) error {
+	dev, unmount, err := fuseMount(mountpoint)
+	if err != nil {
+		return err
+	}
+	defer dev.Close()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		unmount()
+	}()
+	mounted()
+
+	buf := make([]byte, fuseMaxWrite+64*1024)
+	for {
+		n, err := dev.Read(buf)
+		switch {
+		case errors.Is(err, syscall.ENODEV):
+			return nil // Unmounted.
+		case errors.Is(err, syscall.EINTR), errors.Is(err, syscall.ENOENT):
+			continue // Interrupted or a request the kernel has since dropped.
+		case err != nil:
+			unmount()
+			return err
+		}
+		if n < fuseInHeaderSize {
+			continue
+		}
+		reply, errno, ok := tree.handle(buf[:n])
+		if !ok {
+			continue // Requests like FORGET take no reply.
+		}
+		out := make([]byte, fuseOutHeaderSize, fuseOutHeaderSize+len(reply))
+		binary.NativeEndian.PutUint32(out[0:], uint32(fuseOutHeaderSize+len(reply)))
+		binary.NativeEndian.PutUint32(out[4:], uint32(-int32(errno)))
+		copy(out[8:16], buf[8:16]) // The request's unique ID.
+		out = append(out, reply...)
+		if _, err := dev.Write(out); err != nil && !errors.Is(err, syscall.ENOENT) {
+			unmount()
+			return err
+		}
+	}
+}
+
+// handle answers one request. It returns the reply body or an errno, and
+// false for requests that must not be answered.
+func (t *bundleTree) handle(req []byte) ([]byte, syscall.Errno, bool) {
+	opcode := binary.NativeEndian.Uint32(req[4:])
+	nodeID := binary.NativeEndian.Uint64(req[16:])
+	in := req[fuseInHeaderSize:]
+	switch opcode {
+	case fuseForget, fuseBatchForget, fuseInterrupt:
+		return nil, 0, false
+	case fuseInit:
+		out := make([]byte, 64)
+		binary.NativeEndian.PutUint32(out[0:], 7)
+		binary.NativeEndian.PutUint32(out[4:], fuseKernelMinor)
+		copy(out[8:12], in[8:12]) // max_readahead, as the kernel proposed.
+		binary.NativeEndian.PutUint32(out[20:], fuseMaxWrite)
+		binary.NativeEndian.PutUint32(out[24:], 1) // time_gran: nanoseconds.
+		return out, 0, true
+	case fuseDestroy, fuseRelease, fuseReleasedir, fuseFlush:
+		return nil, 0, true
+	case fuseStatfs:
+		out := make([]byte, 80)
+		binary.NativeEndian.PutUint64(out[24:], uint64(len(t.nodes))) // files
+		binary.NativeEndian.PutUint32(out[40:], 4096)                 // bsize
+		binary.NativeEndian.PutUint32(out[44:], 255)                  // namelen
+		binary.NativeEndian.PutUint32(out[48:], 4096)                 // frsize
+		return out, 0, true
+	}
+
+	n := t.node(nodeID)
+	if n == nil {
+		return nil, syscall.ENOENT, true
+	}
+	switch opcode {
+	case fuseLookup:
+		name, _, _ := bytes.Cut(in, []byte{0})
+		child, ok := n.children[string(name)]
+		if !ok {
+			return nil, syscall.ENOENT, true
+		}
+		out := make([]byte, 40, 128)
+		binary.NativeEndian.PutUint64(out[0:], child.ino)
+		binary.NativeEndian.PutUint64(out[16:], fuseAttrValid) // entry_valid
+		binary.NativeEndian.PutUint64(out[24:], fuseAttrValid) // attr_valid
+		return append(out, t.attr(child)...), 0, true
+	case fuseGetattr:
+		out := make([]byte, 16, 104)
+		binary.NativeEndian.PutUint64(out[0:], fuseAttrValid)
+		return append(out, t.attr(n)...), 0, true
+	case fuseAccess:
+		if mask := binary.NativeEndian.Uint32(in); mask&2 != 0 { // W_OK
+			return nil, syscall.EROFS, true
+		}
+		return nil, 0, true
+	case fuseOpen, fuseOpendir:
+		if flags := binary.NativeEndian.Uint32(in); flags&syscall.O_ACCMODE != syscall.O_RDONLY || flags&syscall.O_TRUNC != 0 {
+			return nil, syscall.EROFS, true
+		}
+		if (opcode == fuseOpendir) != (n.children != nil) {
+			if n.children != nil {
+				return nil, syscall.EISDIR, true
+			}
+			return nil, syscall.ENOTDIR, true
+		}
+		out := make([]byte, 16)
+		binary.NativeEndian.PutUint32(out[8:], fuseOpenKeepCache)
+		return out, 0, true
+	case fuseRead:
+		offset := binary.NativeEndian.Uint64(in[8:])
+		size := uint64(binary.NativeEndian.Uint32(in[16:]))
+		if offset >= uint64(len(n.content)) {
+			return nil, 0, true
+		}
+		return n.content[offset:min(offset+size, uint64(len(n.content)))], 0, true
+	case fuseReaddir:
+		offset := binary.NativeEndian.Uint64(in[8:])
+		size := int(binary.NativeEndian.Uint32(in[16:]))
+		return t.readdir(n, offset, size), 0, true
+	}
+	return nil, syscall.ENOSYS, true
+}
+
+// attr encodes a node's fuse_attr. Everything is owned by the user serving
+// the mount and read-only.
+func (t *bundleTree) attr(n *bundleNode) []byte {
+	out := make([]byte, 88)
+	mode, nlink := uint32(syscall.S_IFREG|0o444), uint32(1)
+	if n.children != nil {
+		mode, nlink = syscall.S_IFDIR|0o555, 2
+	}
+	sec, nsec := uint64(t.modTime.Unix()), uint32(t.modTime.Nanosecond())
+	binary.NativeEndian.PutUint64(out[0:], n.ino)
+	binary.NativeEndian.PutUint64(out[8:], uint64(len(n.content)))
+	binary.NativeEndian.PutUint64(out[16:], (uint64(len(n.content))+511)/512)
+	for i := 0; i < 3; i++ { // atime, mtime, ctime
+		binary.NativeEndian.PutUint64(out[24+8*i:], sec)
+		binary.NativeEndian.PutUint32(out[48+4*i:], nsec)
+	}
+	binary.NativeEndian.PutUint32(out[60:], mode)
+	binary.NativeEndian.PutUint32(out[64:], nlink)
+	binary.NativeEndian.PutUint32(out[68:], uint32(os.Getuid()))
+	binary.NativeEndian.PutUint32(out[72:], uint32(os.Getgid()))
+	binary.NativeEndian.PutUint32(out[80:], 4096) // blksize
+	return out
+}
+
+// readdir encodes the fuse_dirent entries of a directory from the entry at
+// offset on, as many as fit in size bytes. "." and ".." come first.
+func (t *bundleTree) readdir(n *bundleNode, offset uint64, size int) []byte {
+	parent := n
+	if n.parent != nil {
+		parent = n.parent
+	}
+	var out []byte
+	for i := offset; i < uint64(len(n.sorted))+2; i++ {
+		name, ino, typ := ".", n.ino, uint32(syscall.DT_DIR)
+		switch {
+		case i == 1:
+			name, ino = "..", parent.ino
+		case i > 1:
+			child := n.children[n.sorted[i-2]]
+			name, ino = child.name, child.ino
+			if child.children == nil {
+				typ = syscall.DT_REG
+			}
+		}
+		entry := make([]byte, 24, (24+len(name)+7)&^7)
+		binary.NativeEndian.PutUint64(entry[0:], ino)
+		binary.NativeEndian.PutUint64(entry[8:], i+1) // Offset of the next entry.
+		binary.NativeEndian.PutUint32(entry[16:], uint32(len(name)))
+		binary.NativeEndian.PutUint32(entry[20:], typ)
+		entry = append(entry, name...)
+		entry = entry[:cap(entry)]
+		if len(out)+len(entry) > size {
+			break
+		}
+		out = append(out, entry...)
+	}
+	return out
+}
+
+// fuseMount opens /dev/fuse and mounts it at mountpoint, falling back to
+// fusermount when the mount(2) call is not permitted. It returns the device
+// and a function that unmounts it.
+func fuseMount(mountpoint string) (*os.File, func(), error) {
+	dev, err := os.OpenFile("/dev/fuse", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open /dev/fuse (is FUSE installed?): %w", err)
+	}
+	opts := fmt.Sprintf("fd=%d,rootmode=40000,user_id=%d,group_id=%d", dev.Fd(), os.Getuid(), os.Getgid())
+	err = syscall.Mount("project-bundler", mountpoint, "fuse.project-bundler", syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, opts)
+	if err == nil {
+		return dev, func() { syscall.Unmount(mountpoint, syscall.MNT_DETACH) }, nil
+	}
+	dev.Close()
+	if !errors.Is(err, syscall.EPERM) {
+		return nil, nil, err
+	}
+
+	helper, lookErr := exec.LookPath("fusermount3")
+	if lookErr != nil {
+		if helper, lookErr = exec.LookPath("fusermount"); lookErr != nil {
+			return nil, nil, fmt.Errorf("mounting needs root or fusermount: %w", err)
+		}
+	}
+	dev, err = fusermountDevice(helper, mountpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dev, func() { exec.Command(helper, "-u", "-z", "--", mountpoint).Run() }, nil
+}
+
+// fusermountDevice runs the fusermount helper, which mounts the filesystem
+// and passes the opened /dev/fuse back over a socket.
+func fusermountDevice(helper, mountpoint string) (*os.File, error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, err
+	}
+	ours, theirs := os.NewFile(uintptr(fds[0]), "fusermount-socket"), os.NewFile(uintptr(fds[1]), "fusermount-socket")
+	defer ours.Close()
+	cmd := exec.Command(helper, "-o", "ro,nosuid,nodev,fsname=project-bundler,subtype=project-bundler", "--", mountpoint)
+	cmd.Env = append(os.Environ(), "_FUSE_COMMFD=3")
+	cmd.ExtraFiles = []*os.File{theirs}
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	theirs.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", helper, err)
+	}
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := syscall.Recvmsg(fds[0], make([]byte, 1), oob, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no device from %s: %w", helper, err)
+	}
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) == 0 {
+		return nil, fmt.Errorf("no device from %s", helper)
+	}
+	devFds, err := syscall.ParseUnixRights(&msgs[0])
+	if err != nil || len(devFds) == 0 {
+		return nil, fmt.Errorf("no device from %s", helper)
+	}
+	return os.NewFile(uintptr(devFds[0]), "/dev/fuse"), nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+// project-bundler/mount_other.go
+package main
+
+import "errors"
+
+// serveBundle is only implemented on Linux, which has FUSE in the kernel;
+// elsewhere it would need a third-party FUSE library.
+func serveBundle(tree *bundleTree, mountpoint string, mounted func()) error {
+	return errors.New("mount is only supported on Linux")
+}
@@ -0,0 +1,546 @@
+// project-bundler/onboard.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// onboardSections are the sections of an onboarding packet in their default
+// order. The `onboard-sections` list in .bundler.yaml or -sections picks and
+// orders them.
+var onboardSections = []string{"docs", "diagram", "entry-points", "churn", "owners", "glossary"}
+
+// onboardMaxRead is the largest file read for entry points and glossary
+// terms; larger files are data rather than code someone new would read.
+const onboardMaxRead = 1 << 20
+
+// onboardPacket holds what the sections of an onboarding packet are built
+// from: the files a bundle would contain and their contents.
+type onboardPacket struct {
+	opts     bundleOptions
+	files    []fileEntry
+	contents map[string][]byte // By RelPath, for files up to onboardMaxRead.
+	bundled  stringSet         // RelPaths already written in full.
+	since    time.Duration
+	top      int
+
+	history *gitActivity // Read on first use; see activity.
+}
+
+// runOnboard implements the `onboard` subcommand, which writes a curated
+// packet for someone new to a project instead of a full bundle: the docs,
+// a dependency diagram, the entry points, the most changed files, who
+// works where and a glossary of the project's own terms.
+//
+//	project-bundler onboard -src . -output onboarding.md
+func runOnboard(args []string) {
+	fs := flag.NewFlagSet("onboard", flag.ExitOnError)
+	srcDir := fs.String("src", ".", "Source project directory.")
+	outputFile := fs.String("output", "onboarding.md", "Output markdown file.")
+	projectType := fs.String("type", "auto", "Project type. Options: "+strings.Join(availableProjectTypes(), ", "))
+	sectionsStr := fs.String("sections", "", "Comma-separated sections in the order to write them. Options: "+strings.Join(onboardSections, ", ")+". Defaults to the project config's 'onboard-sections', or all of them.")
+	sinceStr := fs.String("since", "1y", "How far back the churn and ownership sections look in the git history, e.g. 6mo or 2y.")
+	top := fs.Int("top", 10, "Number of files in the churn section, and of terms (times three) in the glossary.")
+	styleName := fs.String("style", "github", "Output style. Options: "+strings.Join(bundler.StyleNames(), ", "))
+	fs.Parse(args)
+	resultPath = *outputFile
+
+	opts, err := resolveOptions(*srcDir, *projectType, "", "", false)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	style, ok := bundler.Styles[*styleName]
+	if !ok {
+		fatalf("Invalid style '%s'. Available styles are: %s", *styleName, strings.Join(bundler.StyleNames(), ", "))
+	}
+	opts.Style = style
+	since, err := parseAge(*sinceStr)
+	if err != nil {
+		fatalf("Invalid -since: %v", err)
+	}
+	if *top <= 0 {
+		fatalf("-top must be positive.")
+	}
+	local, _, err := loadLocalConfig(*srcDir)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	sections := onboardSections
+	if *sectionsStr != "" {
+		sections = strings.Split(*sectionsStr, ",")
+	} else if len(local.OnboardSections) > 0 {
+		sections = local.OnboardSections
+	}
+	for _, s := range sections {
+		if !slices.Contains(onboardSections, s) {
+			fatalf("Unknown onboarding section '%s'. Options: %s", s, strings.Join(onboardSections, ", "))
+		}
+	}
+
+	files, skipped, err := collectFiles(opts)
+	if err != nil {
+		fatalf("Error during directory walk: %v", err)
+	}
+	p := &onboardPacket{opts: opts, files: files, contents: make(map[string][]byte), bundled: make(stringSet), since: since, top: *top}
+	for _, f := range files {
+		if f.Placeholder || f.DuplicateOf != "" || f.Size > onboardMaxRead {
+			continue
+		}
+		if content, err := os.ReadFile(f.Path); err == nil {
+			p.contents[f.RelPath] = content
+		}
+	}
+
+	var b bytes.Buffer
+	name := filepath.Base(opts.SrcDir)
+	if abs, err := filepath.Abs(opts.SrcDir); err == nil {
+		name = filepath.Base(abs)
+	}
+	fmt.Fprintf(&b, "# Onboarding: %s\n\n", name)
+	fmt.Fprintf(&b, "A guided tour of this %s project for someone new to it, generated by project-bundler.\n\n", strings.ReplaceAll(opts.ProjectType, ",", ", "))
+	writers := map[string]func(io.Writer) error{
+		"docs":         p.writeDocs,
+		"diagram":      p.writeArchitecture,
+		"entry-points": p.writeEntryPoints,
+		"churn":        p.writeChurn,
+		"owners":       p.writeOwners,
+		"glossary":     p.writeGlossary,
+	}
+	for _, s := range sections {
+		if err := writers[s](&b); err != nil {
+			fatalf("Could not write the %s section: %v", s, err)
+		}
+	}
+	if err := os.WriteFile(*outputFile, b.Bytes(), 0o644); err != nil {
+		fatalf("Could not write onboarding packet: %v", err)
+	}
+	fmt.Printf("Wrote onboarding packet for '%s' to '%s' (%s; sections: %s).\n", opts.SrcDir, *outputFile, formatSize(int64(b.Len())), strings.Join(sections, ", "))
+	writeResultLine(resultPath, bundleResult{filesBundled: len(p.bundled), filesSkipped: countSkipped(skipped), bytesWritten: int64(b.Len())}, nil)
+}
+
+// writeFull writes a file as a block of the packet, once.
+func (p *onboardPacket) writeFull(w io.Writer, f fileEntry) error {
+	content, ok := p.contents[f.RelPath]
+	if !ok || p.bundled.Contains(f.RelPath) {
+		return nil
+	}
+	p.bundled[f.RelPath] = struct{}{}
+	return p.opts.Style.WriteFile(w, f.RelPath, f.Lang, "", content)
+}
+
+// docRank orders documentation: the README first, then the other top-level
+// guides, then everything under docs/ or doc/. Other files are not docs.
+func docRank(relPath string) (int, bool) {
+	rel := filepath.ToSlash(relPath)
+	base := strings.ToUpper(strings.TrimSuffix(path.Base(rel), path.Ext(rel)))
+	ext := strings.ToLower(path.Ext(rel))
+	isText := ext == ".md" || ext == ".rst" || ext == ".adoc" || ext == ".txt" || ext == ""
+	switch {
+	case !isText:
+		return 0, false
+	case !strings.Contains(rel, "/") && base == "README":
+		return 0, true
+	case !strings.Contains(rel, "/") && slices.Contains([]string{"ARCHITECTURE", "DESIGN", "CONTRIBUTING", "HACKING", "DEVELOPMENT", "GLOSSARY"}, base):
+		return 1, true
+	case ext != "" && ext != ".txt" && (strings.HasPrefix(rel, "docs/") || strings.HasPrefix(rel, "doc/")):
+		return 2, true
+	}
+	return 0, false
+}
+
+func (p *onboardPacket) writeDocs(w io.Writer) error {
+	var docs []fileEntry
+	for _, f := range p.files {
+		if _, ok := docRank(f.RelPath); ok {
+			docs = append(docs, f)
+		}
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		ri, _ := docRank(docs[i].RelPath)
+		rj, _ := docRank(docs[j].RelPath)
+		if ri != rj {
+			return ri < rj
+		}
+		return docs[i].RelPath < docs[j].RelPath
+	})
+	fmt.Fprintf(w, "## Documentation\n\n")
+	if len(docs) == 0 {
+		fmt.Fprintf(w, "The project has no README or docs/ directory.\n\n")
+		return nil
+	}
+	for _, f := range docs {
+		if err := p.writeFull(w, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *onboardPacket) writeArchitecture(w io.Writer) error {
+	fmt.Fprintf(w, "## Architecture\n\n")
+	level := "packages"
+	graph := buildDependencyGraph(p.opts, p.files, level)
+	if len(graph) > 30 {
+		level = "modules"
+		graph = buildDependencyGraph(p.opts, p.files, level)
+	}
+	if len(graph) == 0 {
+		fmt.Fprintf(w, "No dependencies between the project's own directories were found.\n\n")
+		return nil
+	}
+	return writeDiagram(w, graph, level)
+}
+
+// entryPointRE finds the program entry points of the languages the presets
+// cover: main functions, Python's __main__ guard and Swift's @main.
+var entryPointRE = regexp.MustCompile(`(?m)^func main\(\)|^\s*(?:Future<void>|void)\s+main\(|^fn main\(\)|^\s*(?:public\s+)?static\s+(?:async\s+)?(?:void|int|Task)\s+[Mm]ain\(|^fun main\(|^if __name__ == ['"]__main__['"]|^@main\b`)
+
+// packageEntryPoints returns the files package.json names as "main" and
+// "bin", relative to its directory.
+func packageEntryPoints(content []byte) []string {
+	var pkg struct {
+		Main string          `json:"main"`
+		Bin  json.RawMessage `json:"bin"`
+	}
+	if json.Unmarshal(content, &pkg) != nil {
+		return nil
+	}
+	var entries []string
+	if pkg.Main != "" {
+		entries = append(entries, pkg.Main)
+	}
+	var bin string
+	var bins map[string]string
+	if json.Unmarshal(pkg.Bin, &bin) == nil && bin != "" {
+		entries = append(entries, bin)
+	} else if json.Unmarshal(pkg.Bin, &bins) == nil {
+		for _, b := range bins {
+			entries = append(entries, b)
+		}
+	}
+	return entries
+}
+
+func (p *onboardPacket) writeEntryPoints(w io.Writer) error {
+	entries := make(stringSet)
+	for _, f := range p.files {
+		content := p.contents[f.RelPath]
+		rel := filepath.ToSlash(f.RelPath)
+		if path.Base(rel) == "package.json" {
+			for _, e := range packageEntryPoints(content) {
+				entries[path.Join(path.Dir(rel), e)] = struct{}{}
+			}
+			continue
+		}
+		if f.Lang != "text" && f.Lang != "markdown" && (entryPointRE.Match(content) || path.Base(rel) == "__main__.py") {
+			entries[rel] = struct{}{}
+		}
+	}
+	fmt.Fprintf(w, "## Entry Points\n\n")
+	if len(entries) == 0 {
+		fmt.Fprintf(w, "No program entry points were found; this may be a library.\n\n")
+		return nil
+	}
+	for _, f := range p.files {
+		if entries.Contains(filepath.ToSlash(f.RelPath)) {
+			if err := p.writeFull(w, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// gitActivity is the recent history of the project: commits per file and
+// per author within each top-level directory.
+type gitActivity struct {
+	commits map[string]int            // Slash-separated path -> commits.
+	authors map[string]map[string]int // Top-level directory ("." for root files) -> author -> commits.
+}
+
+// recentGitActivity reads the commits of the last since from srcDir's
+// history. ok is false outside a git repository.
+func recentGitActivity(srcDir string, since time.Duration) (gitActivity, bool) {
+	activity := gitActivity{commits: make(map[string]int), authors: make(map[string]map[string]int)}
+	after := time.Now().Add(-since).Format(time.RFC3339)
+	out, err := exec.Command("git", "-C", srcDir, "log", "--since="+after, "--format=%x01%aN", "--name-only", "-z", "--relative", "--no-renames", "--", ".").Output()
+	if err != nil {
+		return activity, false
+	}
+	var author string
+	touched := make(stringSet) // Top-level directories of the current commit.
+	flush := func() {
+		for dir := range touched {
+			if activity.authors[dir] == nil {
+				activity.authors[dir] = make(map[string]int)
+			}
+			activity.authors[dir][author]++
+		}
+		clear(touched)
+	}
+	for _, token := range strings.Split(string(out), "\x00") {
+		token = strings.TrimPrefix(token, "\n")
+		if name, ok := strings.CutPrefix(token, "\x01"); ok {
+			flush()
+			author = name
+			continue
+		}
+		if token == "" {
+			continue
+		}
+		activity.commits[token]++
+		dir, _, found := strings.Cut(token, "/")
+		if !found {
+			dir = "."
+		}
+		touched[dir] = struct{}{}
+	}
+	flush()
+	return activity, true
+}
+
+// activity returns the recent git history, shared by the churn and
+// ownership sections.
+func (p *onboardPacket) activity() (gitActivity, bool) {
+	if p.history == nil {
+		activity, ok := recentGitActivity(p.opts.SrcDir, p.since)
+		if !ok {
+			return activity, false
+		}
+		p.history = &activity
+	}
+	return *p.history, true
+}
+
+func (p *onboardPacket) writeChurn(w io.Writer) error {
+	fmt.Fprintf(w, "## Most Changed Files\n\n")
+	activity, ok := p.activity()
+	if !ok {
+		fmt.Fprintf(w, "Not a git repository; churn needs its history.\n\n")
+		return nil
+	}
+	var churned []fileEntry
+	for _, f := range p.files {
+		if activity.commits[filepath.ToSlash(f.RelPath)] > 0 {
+			churned = append(churned, f)
+		}
+	}
+	if len(churned) == 0 {
+		fmt.Fprintf(w, "No bundled file was changed in the last %s.\n\n", formatAge(p.since))
+		return nil
+	}
+	sort.SliceStable(churned, func(i, j int) bool {
+		return activity.commits[filepath.ToSlash(churned[i].RelPath)] > activity.commits[filepath.ToSlash(churned[j].RelPath)]
+	})
+	churned = churned[:min(len(churned), p.top)]
+	fmt.Fprintf(w, "The files changed most often in the last %s, where most of the current work happens:\n\n", formatAge(p.since))
+	fmt.Fprintf(w, "| File | Commits |\n|------|---------|\n")
+	for _, f := range churned {
+		fmt.Fprintf(w, "| `%s` | %d |\n", p.opts.Style.DisplayPath(f.RelPath), activity.commits[filepath.ToSlash(f.RelPath)])
+	}
+	fmt.Fprintln(w)
+	for _, f := range churned {
+		if err := p.writeFull(w, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// codeOwnersFiles are where GitHub and GitLab look for a CODEOWNERS file.
+var codeOwnersFiles = []string{"CODEOWNERS", ".github/CODEOWNERS", ".gitlab/CODEOWNERS", "docs/CODEOWNERS"}
+
+func (p *onboardPacket) writeOwners(w io.Writer) error {
+	fmt.Fprintf(w, "## Ownership\n\n")
+	for _, name := range codeOwnersFiles {
+		f, err := os.Open(filepath.Join(p.opts.SrcDir, filepath.FromSlash(name)))
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		fmt.Fprintf(w, "From `%s`; later rules win:\n\n| Path | Owners |\n|------|--------|\n", name)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[") {
+				continue
+			}
+			fmt.Fprintf(w, "| `%s` | %s |\n", fields[0], strings.Join(fields[1:], " "))
+		}
+		fmt.Fprintln(w)
+		return scanner.Err()
+	}
+
+	activity, ok := p.activity()
+	if !ok || len(activity.authors) == 0 {
+		fmt.Fprintf(w, "No CODEOWNERS file, and no git history of the last %s to infer owners from.\n\n", formatAge(p.since))
+		return nil
+	}
+	dirs := make([]string, 0, len(activity.authors))
+	for dir := range activity.authors {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	fmt.Fprintf(w, "No CODEOWNERS file; the most active authors of the last %s per top-level directory:\n\n| Directory | Authors (commits) |\n|-----------|-------------------|\n", formatAge(p.since))
+	for _, dir := range dirs {
+		authors := activity.authors[dir]
+		names := make([]string, 0, len(authors))
+		for name := range authors {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if authors[names[i]] != authors[names[j]] {
+				return authors[names[i]] > authors[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		var cells []string
+		for _, name := range names[:min(len(names), 3)] {
+			cells = append(cells, fmt.Sprintf("%s (%d)", name, authors[name]))
+		}
+		label := "/" + dir + "/"
+		if dir == "." {
+			label = "/ (top-level files)"
+		}
+		fmt.Fprintf(w, "| `%s` | %s |\n", label, strings.Join(cells, ", "))
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+// glossaryTermRE finds type-like declarations (types, classes, structs,
+// interfaces, enums, traits, records, protocols) across the presets'
+// languages. Their names are the nouns a project's code is written in.
+var glossaryTermRE = regexp.MustCompile(`(?m)^[ \t]*(?:(?:export|default|pub(?:\([\w:]+\))?|public|internal|abstract|final|sealed|data|open|partial|static)\s+)*(?:type|class|struct|interface|enum|trait|record|protocol)\s+([A-Za-z][A-Za-z0-9]{3,})\b`)
+
+// glossaryTerm is a project-specific term with where it is defined.
+type glossaryTerm struct {
+	name    string
+	defined string // RelPath of the declaring file.
+	about   string // First sentence of the comment above the declaration.
+	usedIn  int    // Files that mention the term.
+}
+
+// leadingComment returns the first sentence of the comment lines directly
+// above the line starting at offset in content.
+func leadingComment(content []byte, offset int) string {
+	lines := strings.Split(string(content[:offset]), "\n")
+	lines = lines[:len(lines)-1] // The empty rest of the line before offset.
+	var comment []string
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		text, ok := "", false
+		for _, marker := range []string{"///", "//", "#", "/**", "*/", "*"} {
+			if rest, found := strings.CutPrefix(line, marker); found {
+				text, ok = strings.TrimSpace(strings.TrimSuffix(rest, "*/")), true
+				break
+			}
+		}
+		if !ok || strings.HasPrefix(text, "[") || strings.HasPrefix(text, "@") || strings.HasPrefix(text, "!") {
+			break
+		}
+		if text != "" {
+			comment = append([]string{text}, comment...)
+		}
+	}
+	about := strings.Join(comment, " ")
+	if i := strings.Index(about, ". "); i >= 0 {
+		about = about[:i+1]
+	}
+	return about
+}
+
+func (p *onboardPacket) writeGlossary(w io.Writer) error {
+	terms := make(map[string]*glossaryTerm)
+	for _, f := range p.files {
+		content := p.contents[f.RelPath]
+		if f.Lang == "text" || f.Lang == "markdown" {
+			continue
+		}
+		for _, m := range glossaryTermRE.FindAllSubmatchIndex(content, -1) {
+			name := string(content[m[2]:m[3]])
+			if _, ok := terms[name]; !ok {
+				terms[name] = &glossaryTerm{name: name, defined: f.RelPath, about: leadingComment(content, m[0])}
+			}
+		}
+	}
+	// A term's weight is how many files use it: the vocabulary everyone
+	// needs, rather than one file's helper types.
+	words := regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+	for _, f := range p.files {
+		seen := make(stringSet)
+		for _, word := range words.FindAll(p.contents[f.RelPath], -1) {
+			if t, ok := terms[string(word)]; ok && !seen.Contains(t.name) {
+				seen[t.name] = struct{}{}
+				t.usedIn++
+			}
+		}
+	}
+	var ranked []*glossaryTerm
+	for _, t := range terms {
+		if t.usedIn >= 2 {
+			ranked = append(ranked, t)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].usedIn != ranked[j].usedIn {
+			return ranked[i].usedIn > ranked[j].usedIn
+		}
+		return ranked[i].name < ranked[j].name
+	})
+	ranked = ranked[:min(len(ranked), 3*p.top)]
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i].name < ranked[j].name })
+
+	fmt.Fprintf(w, "## Glossary\n\n")
+	if len(ranked) == 0 {
+		fmt.Fprintf(w, "No types shared between files were found to build a glossary from.\n\n")
+		return nil
+	}
+	fmt.Fprintf(w, "The project's own types that the most files use, with the comment on their declaration:\n\n| Term | Meaning | Defined in | Used in |\n|------|---------|------------|---------|\n")
+	for _, t := range ranked {
+		about := strings.ReplaceAll(t.about, "|", `\|`)
+		if about == "" {
+			about = "(undocumented)"
+		}
+		fmt.Fprintf(w, "| **%s** | %s | `%s` | %d files |\n", t.name, about, p.opts.Style.DisplayPath(t.defined), t.usedIn)
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+// formatAge renders a -since duration the way it is usually given.
+func formatAge(d time.Duration) string {
+	day := 24 * time.Hour
+	switch {
+	case d%(365*day) == 0:
+		return pluralize(int(d/(365*day)), "year")
+	case d%(30*day) == 0:
+		return pluralize(int(d/(30*day)), "month")
+	case d%day == 0:
+		return pluralize(int(d/day), "day")
+	}
+	return d.String()
+}
+
+func pluralize(n int, unit string) string {
+	if n == 1 {
+		return unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
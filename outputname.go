@@ -0,0 +1,68 @@
+// project-bundler/outputname.go
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// outputPlaceholderRE matches the placeholders of an -output name set by a
+// preset or .bundler.yaml, such as "{module}-bundle.md".
+var outputPlaceholderRE = regexp.MustCompile(`\{(module|name|type)\}`)
+
+// majorVersionRE matches the major version suffix of a Go module path.
+var majorVersionRE = regexp.MustCompile(`^v[0-9]+$`)
+
+// expandOutputName fills in the placeholders of an output name: {name} is
+// the base name of the source directory, {type} the project type and
+// {module} the name the project's manifest declares (falling back to
+// {name}).
+func expandOutputName(name string, opts bundleOptions) string {
+	return outputPlaceholderRE.ReplaceAllStringFunc(name, func(m string) string {
+		switch m {
+		case "{module}":
+			if module := projectModuleName(opts.SrcDir); module != "" {
+				return module
+			}
+			fallthrough
+		case "{name}":
+			if abs, err := filepath.Abs(opts.SrcDir); err == nil {
+				return filepath.Base(abs)
+			}
+			return filepath.Base(opts.SrcDir)
+		default:
+			return opts.ProjectType
+		}
+	})
+}
+
+// projectModuleName returns the module or package name declared by the
+// project's go.mod, Cargo.toml, pubspec.yaml or package.json, reduced to a
+// single path element ("github.com/acme/tool/v2" becomes "tool", "@acme/ui"
+// becomes "ui"), or "" when there is none.
+func projectModuleName(srcDir string) string {
+	if module := readModuleLine(filepath.Join(srcDir, "go.mod"), "module "); module != "" {
+		parts := strings.Split(module, "/")
+		if len(parts) > 1 && majorVersionRE.MatchString(parts[len(parts)-1]) {
+			parts = parts[:len(parts)-1]
+		}
+		return parts[len(parts)-1]
+	}
+	if name := readModuleLine(filepath.Join(srcDir, "Cargo.toml"), "name = "); name != "" {
+		return name
+	}
+	if name := readModuleLine(filepath.Join(srcDir, "pubspec.yaml"), "name:"); name != "" {
+		return name
+	}
+	var pkg struct {
+		Name string `json:"name"`
+	}
+	if data, err := os.ReadFile(filepath.Join(srcDir, "package.json")); err == nil && json.Unmarshal(data, &pkg) == nil && pkg.Name != "" {
+		return path.Base(pkg.Name)
+	}
+	return ""
+}
@@ -0,0 +1,212 @@
+// project-bundler/owners.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// codeOwnersRule is a line of a CODEOWNERS file.
+type codeOwnersRule struct {
+	match  func(relPath string) bool
+	owners []string
+}
+
+// dirOwners is an entry of the -owners appendix: who owns the bundled files
+// of a top-level directory.
+type dirOwners struct {
+	Dir     string       `json:"directory"` // "." for the top-level files.
+	Owners  []ownerFiles `json:"owners"`    // Most files first.
+	Sources []string     `json:"sources"`   // The CODEOWNERS and OWNERS files the owners come from.
+	Files   int          `json:"files"`     // Bundled files in the directory.
+}
+
+// ownerFiles is an owner's handle, team or email with the number of bundled
+// files in the directory that it owns.
+type ownerFiles struct {
+	Handle string `json:"handle"`
+	Files  int    `json:"files"`
+}
+
+// findOwners maps the top-level directories of the bundled files to their
+// owners. The last matching rule of the CODEOWNERS file decides a file's
+// owners, as on GitHub and GitLab; files it does not cover are owned by the
+// nearest OWNERS file in their directory or above.
+func findOwners(opts bundleOptions, files []fileEntry) []dirOwners {
+	rules, codeOwners := readCodeOwners(opts.SrcDir)
+	ownersFiles := make(map[string][]string) // By directory; nil when it has none.
+	nearest := func(dir string) (string, []string) {
+		for {
+			owners, ok := ownersFiles[dir]
+			if !ok {
+				owners = readOwnersFile(filepath.Join(opts.SrcDir, filepath.FromSlash(dir), "OWNERS"))
+				ownersFiles[dir] = owners
+			}
+			if owners != nil {
+				return path.Join(dir, "OWNERS"), owners
+			}
+			if dir == "." {
+				return "", nil
+			}
+			dir = path.Dir(dir)
+		}
+	}
+
+	byDir := make(map[string]*dirOwners)
+	counts := make(map[string]map[string]int)
+	for _, f := range files {
+		rel := filepath.ToSlash(f.RelPath)
+		top, _, found := strings.Cut(rel, "/")
+		if !found {
+			top = "."
+		}
+		d := byDir[top]
+		if d == nil {
+			d = &dirOwners{Dir: top, Owners: []ownerFiles{}, Sources: []string{}}
+			byDir[top], counts[top] = d, make(map[string]int)
+		}
+		d.Files++
+		var owners []string
+		source := ""
+		for i := len(rules) - 1; i >= 0; i-- {
+			if rules[i].match(rel) {
+				owners, source = rules[i].owners, codeOwners
+				break
+			}
+		}
+		if source == "" {
+			source, owners = nearest(path.Dir(rel))
+		}
+		for _, o := range owners {
+			counts[top][o]++
+		}
+		if source != "" && !slices.Contains(d.Sources, source) {
+			d.Sources = append(d.Sources, source)
+		}
+	}
+
+	var list []dirOwners
+	for top, d := range byDir {
+		for handle, n := range counts[top] {
+			d.Owners = append(d.Owners, ownerFiles{handle, n})
+		}
+		sort.Slice(d.Owners, func(i, j int) bool {
+			if d.Owners[i].Files != d.Owners[j].Files {
+				return d.Owners[i].Files > d.Owners[j].Files
+			}
+			return d.Owners[i].Handle < d.Owners[j].Handle
+		})
+		sort.Strings(d.Sources)
+		list = append(list, *d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Dir < list[j].Dir })
+	return list
+}
+
+// readCodeOwners reads the first CODEOWNERS file of codeOwnersFiles. Rules
+// without owners unassign their paths; GitLab section headers are skipped.
+func readCodeOwners(srcDir string) ([]codeOwnersRule, string) {
+	for _, name := range codeOwnersFiles {
+		f, err := os.Open(filepath.Join(srcDir, filepath.FromSlash(name)))
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		var rules []codeOwnersRule
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line, _, _ := strings.Cut(scanner.Text(), " #")
+			fields := strings.Fields(line)
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[") || strings.HasPrefix(fields[0], "^[") {
+				continue
+			}
+			if match := bundler.GitIgnoreMatcher(fields[0]); match != nil {
+				rules = append(rules, codeOwnersRule{match, fields[1:]})
+			}
+		}
+		return rules, name
+	}
+	return nil, ""
+}
+
+// readOwnersFile reads the owners of a Kubernetes-style OWNERS file (YAML
+// with approvers and reviewers lists) or a Chromium-style one (an owner per
+// line, with set, per-file, include and file: directives, which are
+// skipped). It returns nil when there is no such file.
+func readOwnersFile(name string) []string {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	owners := []string{}
+	inList := false // In a YAML approvers or reviewers list.
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		switch {
+		case line == "":
+		case strings.HasSuffix(line, ":"):
+			inList = line == "approvers:" || line == "reviewers:"
+		case strings.HasPrefix(line, "- "):
+			if inList {
+				owners = appendOwner(owners, strings.Trim(strings.TrimSpace(line[2:]), `"'`))
+			}
+		case strings.Contains(line, ":"), strings.HasPrefix(line, "set "), strings.HasPrefix(line, "per-file "), strings.HasPrefix(line, "include "):
+		case !strings.ContainsAny(line, " \t"):
+			owners = appendOwner(owners, line)
+		}
+	}
+	return owners
+}
+
+func appendOwner(owners []string, owner string) []string {
+	if owner == "" || slices.Contains(owners, owner) {
+		return owners
+	}
+	return append(owners, owner)
+}
+
+// writeOwnersAppendix appends who owns each top-level directory, with the
+// handles, teams and emails to contact.
+func writeOwnersAppendix(w io.Writer, owners []dirOwners) error {
+	if len(owners) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Appendix: owners and contacts (%d directories):\n\n| Directory | Owners (files owned) | From |\n|---|---|---|\n", len(owners))
+	for _, d := range owners {
+		label := "`/" + d.Dir + "/`"
+		if d.Dir == "." {
+			label = "`/` (top-level files)"
+		}
+		var cells []string
+		for _, o := range d.Owners {
+			cells = append(cells, fmt.Sprintf("%s (%d of %d)", o.Handle, o.Files, d.Files))
+		}
+		who := strings.Join(cells, ", ")
+		if who == "" {
+			who = "none"
+		}
+		var sources []string
+		for _, s := range d.Sources {
+			sources = append(sources, "`"+s+"`")
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", label, who, strings.Join(sources, ", "))
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,187 @@
+// project-bundler/pack.go
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// packRecentWindow is how long before the tree's newest change a file counts
+// as recently changed when packing.
+const packRecentWindow = 30 * 24 * time.Hour
+
+// packCandidate is a file -pack may leave out, with what ranks it.
+type packCandidate struct {
+	f      fileEntry
+	tier   int   // 0 for -focus matches, 1 for entry points, 2 for the rest.
+	heat   int64 // In the -profile, if any.
+	recent bool  // Changed within packRecentWindow of the tree's newest change.
+	tokens int   // Of the file's block.
+}
+
+// omittedFile is a file -pack left out, for the index at the end of the
+// bundle.
+type omittedFile struct {
+	path   string
+	tokens int
+}
+
+// packFiles chooses the files to bundle when all of them would not fit in
+// -max-tokens: files matching -focus first, then entry points, then the
+// rest, each group with the files hottest in the -profile first, then its
+// recently changed files and smaller files before larger ones. A file is bundled if it fits in what the files ranked
+// above it left, counting an index line for every file left out. Stubs
+// (cloud placeholders, minified and binary files, and duplicates) are always kept. It
+// returns the files to bundle, in their original order, and the files left
+// out, from most to least important.
+func packFiles(opts bundleOptions, files []fileEntry, inMemory map[string][]byte, skipped map[string][]string) ([]fileEntry, []omittedFile) {
+	tok := opts.tokenizer
+	contents := make(map[string][]byte, len(files))
+	entries := make(stringSet)
+	want := make(stringSet)
+	for _, f := range files {
+		if f.Placeholder || f.Minified || f.Binary || f.DuplicateOf != "" {
+			continue
+		}
+		content, ok := inMemory[f.Path]
+		if !ok {
+			var err error
+			if content, err = os.ReadFile(f.Path); err != nil {
+				continue // Kept, so the render loop reports it.
+			}
+			content, _ = bundler.Transcode(content, f.Charset, f.EOL)
+		}
+		contents[f.Path] = content
+		rel := filepath.ToSlash(f.RelPath)
+		want[rel] = struct{}{}
+		if path.Base(rel) == "package.json" {
+			for _, e := range packageEntryPoints(content) {
+				entries[path.Join(path.Dir(rel), e)] = struct{}{}
+			}
+		}
+	}
+	committed := lastCommitTimes(opts.SrcDir, want)
+	heat := opts.profile.forFiles(files)
+
+	var candidates []packCandidate
+	var newest time.Time
+	changed := make(map[string]time.Time, len(contents))
+	fixed, total := 0, 0 // Tokens of the files always kept, and of all files.
+	for _, f := range files {
+		content, ok := contents[f.Path]
+		if !ok {
+			n := tok.count([]byte(f.RelPath)) + 16 // A stub, or a file that could not be read.
+			fixed += n
+			total += n
+			continue
+		}
+		var block bytes.Buffer
+		opts.Style.WriteFile(&block, f.RelPath, f.Lang, "", content)
+		c := packCandidate{f: f, tier: 2, heat: heat[f.Path], tokens: tok.count(block.Bytes())}
+		rel := filepath.ToSlash(f.RelPath)
+		_, focused := opts.focus.MatchingGlob(f.RelPath)
+		switch {
+		case focused:
+			c.tier = 0
+		case entries.Contains(rel) || f.Lang != "text" && f.Lang != "markdown" && (entryPointRE.Match(content) || path.Base(rel) == "__main__.py"):
+			c.tier = 1
+		}
+		t, ok := committed[rel]
+		if !ok {
+			t = f.ModTime
+		}
+		changed[f.Path] = t
+		if t.After(newest) {
+			newest = t
+		}
+		candidates = append(candidates, c)
+		total += c.tokens
+	}
+	if total <= opts.maxTokens {
+		return files, nil
+	}
+	for i := range candidates {
+		candidates[i].recent = newest.Sub(changed[candidates[i].f.Path]) <= packRecentWindow
+	}
+	slices.SortStableFunc(candidates, func(a, b packCandidate) int {
+		switch {
+		case a.tier != b.tier:
+			return a.tier - b.tier
+		case a.heat != b.heat:
+			return cmp.Compare(b.heat, a.heat)
+		case a.recent != b.recent:
+			if a.recent {
+				return -1
+			}
+			return 1
+		case a.tokens != b.tokens:
+			return a.tokens - b.tokens
+		}
+		return strings.Compare(a.f.RelPath, b.f.RelPath)
+	})
+
+	// Start from every candidate left out, then bundle each in turn if
+	// swapping its index line for its block stays within the budget.
+	lines := make([]int, len(candidates))
+	cost := fixed + tok.count([]byte(omittedIndexHeader(len(candidates), total, opts.maxTokens)))
+	for i, c := range candidates {
+		lines[i] = tok.count([]byte(omittedIndexRow(c.f.RelPath, c.tokens)))
+		cost += lines[i]
+	}
+	keep := make(stringSet)
+	var omitted []omittedFile
+	for i, c := range candidates {
+		if cost-lines[i]+c.tokens <= opts.maxTokens {
+			cost += c.tokens - lines[i]
+			keep[c.f.Path] = struct{}{}
+			continue
+		}
+		omitted = append(omitted, omittedFile{path: c.f.RelPath, tokens: c.tokens})
+		skipped[reasonOverTokens] = append(skipped[reasonOverTokens], c.f.Path)
+	}
+	var kept []fileEntry
+	for _, f := range files {
+		if _, ok := contents[f.Path]; !ok || keep.Contains(f.Path) {
+			kept = append(kept, f)
+		}
+	}
+	return kept, omitted
+}
+
+func omittedIndexHeader(files, tokens, budget int) string {
+	return fmt.Sprintf("Omitted files: %d files (%d tokens) did not fit the budget of %d tokens; from most to least important:\n\n| File | Tokens |\n|---|---|\n", files, tokens, budget)
+}
+
+func omittedIndexRow(path string, tokens int) string {
+	return fmt.Sprintf("| `%s` | %d |\n", filepath.ToSlash(path), tokens)
+}
+
+// writeOmittedIndex lists the files -pack left out at the end of the bundle,
+// so the reader knows what is missing.
+func writeOmittedIndex(w io.Writer, omitted []omittedFile, budget int) error {
+	if len(omitted) == 0 {
+		return nil
+	}
+	tokens := 0
+	for _, o := range omitted {
+		tokens += o.tokens
+	}
+	var b strings.Builder
+	b.WriteString(omittedIndexHeader(len(omitted), tokens, budget))
+	for _, o := range omitted {
+		b.WriteString(omittedIndexRow(o.path, o.tokens))
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,102 @@
+// project-bundler/pairing.go
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// langPair is one -pair-langs mapping, e.g. java=kotlin.
+type langPair struct {
+	from, to string
+}
+
+// parseLangPairs parses a comma-separated -pair-langs value such as
+// "java=kotlin,objectivec=swift". Languages are the identifiers used for code
+// fences.
+func parseLangPairs(s string) ([]langPair, error) {
+	var pairs []langPair
+	for _, item := range strings.Split(s, ",") {
+		from, to, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || from == "" || to == "" || from == to {
+			return nil, fmt.Errorf("invalid language pair '%s': use FROM=TO, e.g. java=kotlin", item)
+		}
+		pairs = append(pairs, langPair{from, to})
+	}
+	return pairs, nil
+}
+
+// pairFiles reorders files so every original in a pair's source language is
+// directly followed by its ported counterpart, and returns a note for each
+// paired or still unported file. A counterpart is the file with the same base
+// name in the target language, preferably in the mirrored directory (a "java"
+// path component becomes "kotlin"); otherwise the only one with that name.
+func pairFiles(files []fileEntry, pairs []langPair) ([]fileEntry, map[string]string) {
+	notes := make(map[string]string)
+	placed := make(map[string]bool) // relPaths already moved next to their original.
+	counterpart := make(map[string]fileEntry)
+
+	for _, p := range pairs {
+		byDirStem := make(map[string]fileEntry)
+		byStem := make(map[string][]fileEntry)
+		for _, f := range files {
+			if f.Lang == p.to {
+				rel := filepath.ToSlash(f.RelPath)
+				byDirStem[path.Join(path.Dir(rel), pairStem(rel))] = f
+				byStem[pairStem(rel)] = append(byStem[pairStem(rel)], f)
+			}
+		}
+		for _, f := range files {
+			if f.Lang != p.from {
+				continue
+			}
+			rel := filepath.ToSlash(f.RelPath)
+			mirrored := mirrorLangDir(path.Dir(rel), p.from, p.to)
+			match, ok := byDirStem[path.Join(mirrored, pairStem(rel))]
+			if !ok && len(byStem[pairStem(rel)]) == 1 {
+				match, ok = byStem[pairStem(rel)][0], true
+			}
+			if !ok || placed[match.RelPath] {
+				notes[f.RelPath] = fmt.Sprintf("Not yet ported to %s.\n", p.to)
+				continue
+			}
+			counterpart[f.RelPath] = match
+			placed[match.RelPath] = true
+			notes[f.RelPath] = fmt.Sprintf("Original; its %s port follows: /%s\n", p.to, filepath.ToSlash(match.RelPath))
+			notes[match.RelPath] = fmt.Sprintf("Ported from %s: /%s\n", p.from, rel)
+		}
+	}
+
+	ordered := make([]fileEntry, 0, len(files))
+	for _, f := range files {
+		if placed[f.RelPath] {
+			continue // Emitted right after its original.
+		}
+		ordered = append(ordered, f)
+		if c, ok := counterpart[f.RelPath]; ok {
+			ordered = append(ordered, c)
+		}
+	}
+	return ordered, notes
+}
+
+// pairStem is the lowercased file name without extension, so Foo.java and
+// Foo.kt match.
+func pairStem(rel string) string {
+	name := path.Base(rel)
+	return strings.ToLower(strings.TrimSuffix(name, path.Ext(name)))
+}
+
+// mirrorLangDir swaps path components named after the source language for
+// the target language, e.g. src/main/java/app -> src/main/kotlin/app.
+func mirrorLangDir(dir, from, to string) string {
+	parts := strings.Split(dir, "/")
+	for i, part := range parts {
+		if part == from {
+			parts[i] = to
+		}
+	}
+	return strings.Join(parts, "/")
+}
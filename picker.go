@@ -0,0 +1,265 @@
+// project-bundler/picker.go
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pickerNode is a directory or file of the -interactive picker.
+type pickerNode struct {
+	name     string
+	path     string                 // Slash-separated, relative to -src.
+	size     int64                  // Of the file, or of the files below the directory.
+	children map[string]*pickerNode // nil for files.
+	open     bool                   // The directory's children are listed.
+	selected bool                   // The file is bundled.
+}
+
+// count returns how many files at or below n are selected, out of how many.
+func (n *pickerNode) count() (selected, total int) {
+	if n.children == nil {
+		if n.selected {
+			return 1, 1
+		}
+		return 0, 1
+	}
+	for _, c := range n.children {
+		s, t := c.count()
+		selected, total = selected+s, total+t
+	}
+	return selected, total
+}
+
+func (n *pickerNode) setSelected(selected bool) {
+	n.selected = selected
+	for _, c := range n.children {
+		c.setSelected(selected)
+	}
+}
+
+// sortedChildren returns the children of a directory, directories first,
+// each group in lexical order.
+func (n *pickerNode) sortedChildren() []*pickerNode {
+	children := make([]*pickerNode, 0, len(n.children))
+	for _, c := range n.children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if (children[i].children == nil) != (children[j].children == nil) {
+			return children[i].children != nil
+		}
+		return children[i].name < children[j].name
+	})
+	return children
+}
+
+// pickerRow is a numbered line of the picker.
+type pickerRow struct {
+	node  *pickerNode
+	depth int
+}
+
+// rows lists the children of n and, below open directories, theirs.
+func (n *pickerNode) rows(depth int) []pickerRow {
+	var rows []pickerRow
+	for _, c := range n.sortedChildren() {
+		rows = append(rows, pickerRow{c, depth})
+		if c.open {
+			rows = append(rows, c.rows(depth+1)...)
+		}
+	}
+	return rows
+}
+
+// exclusions returns -ignore-paths globs that leave out what is not
+// selected: a directory with nothing selected as a whole, other files one
+// by one.
+func (n *pickerNode) exclusions() []string {
+	var globs []string
+	for _, c := range n.sortedChildren() {
+		selected, _ := c.count()
+		switch {
+		case c.children != nil && selected == 0:
+			globs = append(globs, escapeGlob(c.path)+"/**")
+		case c.children != nil:
+			globs = append(globs, c.exclusions()...)
+		case !c.selected:
+			globs = append(globs, escapeGlob(c.path))
+		}
+	}
+	return globs
+}
+
+// escapeGlob escapes the characters of a path that glob patterns give a
+// meaning to.
+func escapeGlob(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// runPicker lists the files that pass the filters as a checkbox tree on the
+// terminal and lets the user toggle files and directories before the bundle
+// is written. What is left out is added to opts' ignore paths, and can be
+// saved as a -selection file to reuse the choice.
+func runPicker(opts *bundleOptions) error {
+	files, _, err := collectFiles(*opts)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("no files pass the filters")
+	}
+	root := &pickerNode{children: make(map[string]*pickerNode)}
+	for _, f := range files {
+		parts := strings.Split(filepath.ToSlash(f.RelPath), "/")
+		n := root
+		for i, part := range parts {
+			n.size += f.Size
+			child, ok := n.children[part]
+			if !ok {
+				child = &pickerNode{name: part, path: strings.Join(parts[:i+1], "/"), selected: true}
+				if i < len(parts)-1 {
+					child.children = make(map[string]*pickerNode)
+				}
+				n.children[part] = child
+			}
+			n = child
+		}
+		n.size = f.Size
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		rows := root.rows(0)
+		fmt.Println()
+		for i, r := range rows {
+			mark := "[x]"
+			selected, total := r.node.count()
+			switch {
+			case selected == 0:
+				mark = "[ ]"
+			case selected < total:
+				mark = "[~]"
+			}
+			name := r.node.name
+			detail := formatSize(r.node.size)
+			if r.node.children != nil {
+				name += "/"
+				if r.node.open {
+					name = "▾ " + name
+				} else {
+					name = "▸ " + name
+				}
+				detail = fmt.Sprintf("%d of %d files, %s", selected, total, detail)
+			}
+			fmt.Printf("%4d %s %s%s (%s)\n", i+1, mark, strings.Repeat("  ", r.depth), name, detail)
+		}
+		selected, total := root.count()
+		fmt.Printf("\n%d of %d files selected. Toggle with numbers or ranges (3, 5-9), o N opens or closes a directory,\na selects all, n none, s FILE saves the selection; Enter bundles, q quits: ", selected, total)
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return errors.New("no answer from the terminal")
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			if selected == 0 {
+				fmt.Println("Nothing is selected.")
+				continue
+			}
+			opts.IgnorePaths.Add(root.exclusions(), "-interactive")
+			return nil
+		case fields[0] == "q":
+			return errors.New("quit without writing the bundle")
+		case fields[0] == "a" || fields[0] == "n":
+			root.setSelected(fields[0] == "a")
+		case fields[0] == "s" && len(fields) == 2:
+			if err := saveSelection(fields[1], root.exclusions()); err != nil {
+				fmt.Printf("Could not save the selection: %v\n", err)
+			} else {
+				fmt.Printf("Saved the selection to '%s'; bundle with it again using -selection %s.\n", fields[1], fields[1])
+			}
+		case fields[0] == "o" && len(fields) == 2:
+			if i, err := strconv.Atoi(fields[1]); err == nil && i >= 1 && i <= len(rows) && rows[i-1].node.children != nil {
+				rows[i-1].node.open = !rows[i-1].node.open
+			} else {
+				fmt.Printf("No directory numbered %s.\n", fields[1])
+			}
+		default:
+			picked, err := parseRowNumbers(strings.Join(fields, ","), len(rows))
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			for _, i := range picked {
+				n := rows[i-1].node
+				selected, total := n.count()
+				n.setSelected(selected < total)
+			}
+		}
+	}
+}
+
+// parseRowNumbers parses comma-separated row numbers and ranges such as
+// "3,5-9", each between 1 and rows.
+func parseRowNumbers(s string, rows int) ([]int, error) {
+	var numbers []int
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(item, "-")
+		first, err1 := strconv.Atoi(from)
+		last, err2 := first, error(nil)
+		if isRange {
+			last, err2 = strconv.Atoi(to)
+		}
+		if err1 != nil || err2 != nil || first < 1 || last > rows || first > last {
+			return nil, fmt.Errorf("'%s' is not a row number or range between 1 and %d.", item, rows)
+		}
+		for i := first; i <= last; i++ {
+			numbers = append(numbers, i)
+		}
+	}
+	return numbers, nil
+}
+
+// saveSelection writes the globs that leave out what was not selected,
+// one per line, for -selection.
+func saveSelection(file string, globs []string) error {
+	var b strings.Builder
+	b.WriteString("# project-bundler selection: paths left out, as -ignore-paths globs. Use with -selection.\n")
+	for _, g := range globs {
+		b.WriteString(g + "\n")
+	}
+	return os.WriteFile(file, []byte(b.String()), 0o644)
+}
+
+// readSelection reads a file saved by saveSelection, skipping blank lines
+// and comments.
+func readSelection(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var globs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			globs = append(globs, line)
+		}
+	}
+	return globs, nil
+}
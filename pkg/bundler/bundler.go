@@ -0,0 +1,225 @@
+// project-bundler/pkg/bundler/bundler.go
+
+// Package bundler walks a source tree, filters it with per-language presets
+// and ignore rules, and renders the remaining files into a single document
+// for use as LLM context. It is the core of the project-bundler command; the
+// command adds the optional sections (schemas, endpoints, diagrams, ...) and
+// the other output formats on top of it.
+//
+//	opts, err := bundler.NewOptions("go")
+//	if err != nil {
+//		return err
+//	}
+//	report, err := bundler.New(opts).Bundle("./myproject", &buf)
+package bundler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+)
+
+// Bundler renders the files selected by its Options.
+//
+// Programs that add to the bundle, like the project-bundler command, can
+// replace its steps with their own: Collect, Preamble and Render. Each
+// defaults to the package's own behavior when nil.
+type Bundler struct {
+	Options  Options
+	Observer Observer // Told of every file included and skipped; nil for none.
+
+	// Collect selects the files to bundle in place of the package's Collect,
+	// e.g. to filter or add to them. opts is Options with SrcDir and FS set
+	// for the call, and an OnDecision that also tells the Observer.
+	Collect func(opts Options) ([]File, map[string][]string, error)
+	// Preamble writes what comes before the first file, once the files are
+	// selected.
+	Preamble func(w io.Writer, files []File) error
+	// Render prepares the block of the selected file files[i] in place of
+	// reading it. It returns a *SkipFileError to leave the file out, or
+	// SkipRest to end the bundle before it.
+	Render func(i int, f File) (RenderedFile, error)
+}
+
+// SkipRest is returned by a Render hook to write no more files. Bundle
+// returns the files written so far without an error.
+var SkipRest = errors.New("skip the remaining files")
+
+// SkipFileError is returned by a Render hook to leave a file out of the
+// bundle. Bundle records it in Report.Skipped under Reason; files skipped
+// with ReasonReadError make Bundle return a *PartialBundleError.
+type SkipFileError struct {
+	Reason string
+	Err    error // Why the file could not be read, for ReasonReadError.
+}
+
+func (e *SkipFileError) Error() string {
+	if e.Err != nil {
+		return "file skipped: " + e.Err.Error()
+	}
+	return "file skipped: " + e.Reason
+}
+
+func (e *SkipFileError) Unwrap() error { return e.Err }
+
+// New returns a Bundler for opts.
+func New(opts Options) *Bundler {
+	return &Bundler{Options: opts}
+}
+
+// Report summarises a Bundle call.
+type Report struct {
+	Files   []string            // Relative paths written, in bundle order.
+	Skipped map[string][]string // Skipped paths grouped by reason code (see ReasonText).
+	Bytes   int64               // Content bytes read from the bundled files.
+}
+
+// Bundle walks src and writes every selected file to w in Options.Style.
+// src is a directory, or a slash-separated directory of Options.FS when that
+// is set ("." for all of it). Files that cannot be read are reported under
+// ReasonReadError, and the bundle is written without them; Bundle then
+// returns a *PartialBundleError with the report. b.Observer, when set, is
+// told of each file as it is included or skipped.
+//
+// The errors to branch on are ErrSourceNotFound, ErrDeadlineExceeded,
+// ErrSecretDetected (a *SecretError) with Options.FailOnSecrets and
+// ErrBudgetExceeded with Options.MaxBytes.
+func (b *Bundler) Bundle(src string, w io.Writer) (*Report, error) {
+	opts := b.Options
+	opts.SrcDir = src
+	if opts.FS != nil && src != "." {
+		sub, err := fs.Sub(opts.FS, src)
+		if err != nil {
+			return nil, err
+		}
+		opts.FS = sub
+	}
+	fsys := opts.fileSystem()
+	if _, err := fs.Stat(fsys, "."); errors.Is(err, fs.ErrNotExist) {
+		return &Report{Skipped: make(map[string][]string)}, fmt.Errorf("%w: %s", ErrSourceNotFound, src)
+	}
+	observer := b.Observer
+	if observer == nil {
+		observer = NopObserver{}
+	}
+	var unread []FileError // For the PartialBundleError.
+	decide := opts.OnDecision
+	opts.OnDecision = func(path, reason, rule string) {
+		if decide != nil {
+			decide(path, reason, rule)
+		}
+		if reason == ReasonReadError {
+			unread = append(unread, FileError{Path: path, Reason: reason, Err: errors.New(rule)})
+		}
+		if reason != "" {
+			observer.OnFileSkipped(path, reason)
+		}
+	}
+	collect, render := b.Collect, b.Render
+	if collect == nil {
+		collect = Collect
+	}
+	if render == nil {
+		render = func(i int, f File) (RenderedFile, error) { return renderFile(opts, fsys, f) }
+	}
+
+	files, skipped, err := collect(opts)
+	if skipped == nil {
+		skipped = make(map[string][]string)
+	}
+	report := &Report{Skipped: skipped}
+	if err != nil {
+		return report, err
+	}
+	if b.Preamble != nil {
+		if err := b.Preamble(w, files); err != nil {
+			return report, err
+		}
+	}
+	var written int64 // Content bytes written, for MaxBytes.
+	for i, f := range files {
+		r, err := render(i, f)
+		var skip *SkipFileError
+		switch {
+		case errors.Is(err, SkipRest):
+			return report, finishBundle(report, unread, observer)
+		case errors.As(err, &skip):
+			report.Skipped[skip.Reason] = append(report.Skipped[skip.Reason], f.Path)
+			if skip.Reason == ReasonReadError {
+				unread = append(unread, FileError{Path: f.Path, Reason: skip.Reason, Err: skip.Err})
+			}
+			observer.OnFileSkipped(f.Path, skip.Reason)
+			observer.OnProgress(i+1, len(files))
+			continue
+		case err != nil:
+			return report, err
+		}
+		block := r.Content
+		if r.Block != nil {
+			block = r.Block
+		}
+		if opts.FailOnSecrets && r.Raw != nil {
+			if findings := ScanSecrets(f.RelPath, block); len(findings) > 0 {
+				return report, &SecretError{Path: f.RelPath, Findings: findings}
+			}
+		}
+		if written += int64(len(block)); opts.MaxBytes > 0 && written > opts.MaxBytes {
+			return report, fmt.Errorf("%w: %s would take the bundle past %d bytes after %d files", ErrBudgetExceeded, f.RelPath, opts.MaxBytes, len(report.Files))
+		}
+		r.File, r.Index = f, len(report.Files)+1
+		if r.Block != nil {
+			_, err = w.Write(r.Block)
+		} else {
+			err = opts.Style.WriteIndexedFile(w, r.Index, f.RelPath, r.Lang, r.Annotation, r.Content)
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Files = append(report.Files, f.RelPath)
+		report.Bytes += int64(len(r.Raw))
+		observer.OnFileIncluded(r)
+		observer.OnProgress(i+1, len(files))
+	}
+	return report, finishBundle(report, unread, observer)
+}
+
+// finishBundle completes a bundle that was written: it tells the observer, and
+// returns the *PartialBundleError of the files that could not be read.
+func finishBundle(report *Report, unread []FileError, observer Observer) error {
+	observer.OnComplete(report)
+	if len(unread) > 0 {
+		return &PartialBundleError{Files: unread}
+	}
+	return nil
+}
+
+// renderFile reads f and prepares its block: stubs for the files that are
+// not read, and otherwise the content converted to UTF-8 with its redacted
+// sections removed.
+func renderFile(opts Options, fsys fs.FS, f File) (RenderedFile, error) {
+	r := RenderedFile{Lang: "text"}
+	switch {
+	case f.Placeholder:
+		r.Content = []byte(fmt.Sprintf("(cloud placeholder, %d bytes not downloaded locally)", f.Size))
+	case f.DuplicateOf != "":
+		r.Content = opts.Style.DuplicateStub(f.DuplicateOf)
+	case f.Minified:
+		r.Content = []byte(fmt.Sprintf("(minified %s, %d bytes not bundled)", f.Lang, f.Size))
+	case f.Binary:
+		r.Content = []byte(fmt.Sprintf("(binary, %d bytes not bundled)", f.Size))
+	default:
+		raw, err := fs.ReadFile(fsys, filepath.ToSlash(f.RelPath))
+		if err != nil {
+			return r, &SkipFileError{Reason: ReasonReadError, Err: err}
+		}
+		r.Lang, r.Raw = f.Lang, raw
+		r.Content, _ = Transcode(raw, f.Charset, f.EOL)
+		r.Content, _ = RedactSections(r.Content)
+		if opts.NormalizeEOL {
+			r.Content = NormalizeEOL(r.Content)
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,162 @@
+// project-bundler/pkg/bundler/bundler_test.go
+package bundler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// recorder is an Observer that records the events it is told of.
+type recorder struct {
+	included []string
+	skipped  map[string]string
+	progress [][2]int
+	report   *Report
+}
+
+func (r *recorder) OnFileIncluded(f RenderedFile) {
+	r.included = append(r.included, f.RelPath)
+}
+
+func (r *recorder) OnFileSkipped(path, reason string) {
+	r.skipped[path] = reason
+}
+
+func (r *recorder) OnProgress(done, total int) {
+	r.progress = append(r.progress, [2]int{done, total})
+}
+
+func (r *recorder) OnComplete(report *Report) {
+	r.report = report
+}
+
+func TestBundleObserver(t *testing.T) {
+	opts, err := NewOptions("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.FS = fstest.MapFS{
+		"go.mod":            {Data: []byte("module x\n")},
+		"main.go":           {Data: []byte("package main\n")},
+		"vendor/dep/dep.go": {Data: []byte("package dep\n")},
+		"logo.png":          {Data: []byte("\x89PNG\x00\x00")},
+	}
+	rec := &recorder{skipped: make(map[string]string)}
+	var out bytes.Buffer
+	report, err := (&Bundler{Options: opts, Observer: rec}).Bundle(".", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(rec.included, report.Files) || len(rec.included) != 2 {
+		t.Errorf("included %v, report has %v", rec.included, report.Files)
+	}
+	if len(rec.skipped) != 2 || rec.skipped["vendor"] != ReasonIgnoredDir || rec.skipped["logo.png"] == "" {
+		t.Errorf("skipped %v", rec.skipped)
+	}
+	if want := [][2]int{{1, 2}, {2, 2}}; !slices.Equal(rec.progress, want) {
+		t.Errorf("progress %v, want %v", rec.progress, want)
+	}
+	if rec.report != report {
+		t.Error("OnComplete was not called with the report")
+	}
+}
+
+func TestBundleHooks(t *testing.T) {
+	opts, err := NewOptions("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.FS = fstest.MapFS{
+		"a.go": {Data: []byte("package a\n")},
+		"b.go": {Data: []byte("package b\n")},
+		"c.go": {Data: []byte("package c\n")},
+		"d.go": {Data: []byte("package d\n")},
+	}
+	b := &Bundler{Options: opts}
+	b.Collect = func(opts Options) ([]File, map[string][]string, error) {
+		files, skipped, err := Collect(opts)
+		return files[1:], skipped, err // Leaves out a.go.
+	}
+	b.Preamble = func(w io.Writer, files []File) error {
+		_, err := fmt.Fprintf(w, "%d files\n\n", len(files))
+		return err
+	}
+	b.Render = func(i int, f File) (RenderedFile, error) {
+		switch f.RelPath {
+		case "c.go":
+			return RenderedFile{}, &SkipFileError{Reason: ReasonPolicy}
+		case "d.go":
+			return RenderedFile{}, SkipRest
+		}
+		return RenderedFile{Lang: "go", Content: []byte(fmt.Sprintf("// file %d\n", i))}, nil
+	}
+	var out bytes.Buffer
+	report, err := b.Bundle(".", &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "3 files\n\nFile: /b.go\n```go\n// file 0\n\n```\n\n"; out.String() != want {
+		t.Errorf("bundle is %q, want %q", out.String(), want)
+	}
+	if !slices.Equal(report.Files, []string{"b.go"}) || !slices.Equal(report.Skipped[ReasonPolicy], []string{"c.go"}) {
+		t.Errorf("report %+v", report)
+	}
+}
+
+func TestBundleErrors(t *testing.T) {
+	files := fstest.MapFS{
+		"a.go":    {Data: []byte("package a\n")},
+		"key.go":  {Data: []byte("package key\n\nconst id = \"AKIA" + strings.Repeat("Q", 16) + "\"\n")},
+		"long.go": {Data: []byte("package long\n\n// " + strings.Repeat("x", 100) + "\n")},
+	}
+	bundle := func(src string, set func(b *Bundler)) (*Report, error) {
+		opts, err := NewOptions("go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.FS = files
+		b := New(opts)
+		if set != nil {
+			set(b)
+		}
+		return b.Bundle(src, io.Discard)
+	}
+
+	if _, err := bundle("missing", nil); !errors.Is(err, ErrSourceNotFound) {
+		t.Errorf("missing src: %v, want ErrSourceNotFound", err)
+	}
+
+	_, err := bundle(".", func(b *Bundler) { b.Options.FailOnSecrets = true })
+	var secret *SecretError
+	if !errors.Is(err, ErrSecretDetected) || !errors.As(err, &secret) || secret.Path != "key.go" || secret.Findings[0].Rule != "AWS access key ID" {
+		t.Errorf("FailOnSecrets: %v, want a SecretError for key.go", err)
+	}
+
+	report, err := bundle(".", func(b *Bundler) { b.Options.MaxBytes = 60 })
+	if !errors.Is(err, ErrBudgetExceeded) || !slices.Equal(report.Files, []string{"a.go", "key.go"}) {
+		t.Errorf("MaxBytes: %v after %v, want ErrBudgetExceeded after a.go and key.go", err, report.Files)
+	}
+
+	report, err = bundle(".", func(b *Bundler) {
+		b.Render = func(i int, f File) (RenderedFile, error) {
+			if f.RelPath == "key.go" {
+				return RenderedFile{}, &SkipFileError{Reason: ReasonReadError, Err: fs.ErrPermission}
+			}
+			return RenderedFile{Lang: f.Lang, Content: []byte("x")}, nil
+		}
+	})
+	var partial *PartialBundleError
+	if !errors.As(err, &partial) || len(partial.Files) != 1 || partial.Files[0].Path != "key.go" || !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("unreadable file: %v, want a PartialBundleError for key.go", err)
+	}
+	if !slices.Equal(report.Files, []string{"a.go", "long.go"}) || !slices.Equal(report.Skipped[ReasonReadError], []string{"key.go"}) {
+		t.Errorf("unreadable file: report %+v", report)
+	}
+}
@@ -0,0 +1,169 @@
+// project-bundler/pkg/bundler/bundlertest/bundlertest.go
+
+// Package bundlertest checks in tests that a directory bundles to a golden
+// snapshot, so that teams can lock down how their bundler configuration
+// treats their tree in their own CI:
+//
+//	func TestBundle(t *testing.T) {
+//		opts, err := bundler.NewOptions("go")
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		bundlertest.Snapshot(t, bundler.New(opts), "testdata/project", "testdata/project.golden",
+//			bundlertest.Replace(`\d{4}-\d\d-\d\dT[\d:]+Z`, "<time>"))
+//	}
+//
+// The snapshot is the bundle followed by the skipped paths grouped by reason
+// code. Run the tests with BUNDLERTEST_UPDATE=1 to write the snapshots
+// instead of comparing them.
+package bundlertest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// UpdateEnv names the environment variable that makes Snapshot write the
+// golden files when set to 1.
+const UpdateEnv = "BUNDLERTEST_UPDATE"
+
+// Option normalizes a snapshot before it is compared or written, so that
+// what changes between runs or machines does not fail the test.
+type Option func(snapshot []byte) []byte
+
+// Replace replaces the matches of the regular expression pattern, with $1
+// and the like expanded as by regexp.ReplaceAll.
+func Replace(pattern, replacement string) Option {
+	re := regexp.MustCompile(pattern)
+	return func(snapshot []byte) []byte {
+		return re.ReplaceAll(snapshot, []byte(replacement))
+	}
+}
+
+// DropLines removes the lines matching the regular expression pattern.
+func DropLines(pattern string) Option {
+	re := regexp.MustCompile(pattern)
+	return func(snapshot []byte) []byte {
+		var out [][]byte
+		for _, line := range bytes.SplitAfter(snapshot, []byte("\n")) {
+			if !re.Match(bytes.TrimSuffix(line, []byte("\n"))) {
+				out = append(out, line)
+			}
+		}
+		return bytes.Join(out, nil)
+	}
+}
+
+// NormalizeEOL converts CRLF and CR line endings to LF, for trees checked
+// out with either.
+func NormalizeEOL() Option {
+	return bundler.NormalizeEOL
+}
+
+// WithoutSkipped leaves the skipped paths out of the snapshot.
+func WithoutSkipped() Option {
+	return func(snapshot []byte) []byte {
+		if i := bytes.LastIndex(snapshot, []byte(skippedHeader)); i >= 0 {
+			return snapshot[:i]
+		}
+		return snapshot
+	}
+}
+
+// skippedHeader starts the skipped paths in a snapshot.
+const skippedHeader = "\n--- skipped ---\n"
+
+// Render bundles src with b and returns its snapshot: the bundle, then the
+// skipped paths, relative to src, grouped by reason code in order.
+func Render(b *bundler.Bundler, src string, opts ...Option) ([]byte, error) {
+	var out bytes.Buffer
+	report, err := b.Bundle(src, &out)
+	if err != nil {
+		return nil, err
+	}
+	out.WriteString(skippedHeader)
+	reasons := make([]string, 0, len(report.Skipped))
+	for reason := range report.Skipped {
+		reasons = append(reasons, reason)
+	}
+	slices.Sort(reasons)
+	for _, reason := range reasons {
+		paths := make([]string, 0, len(report.Skipped[reason]))
+		for _, p := range report.Skipped[reason] {
+			if rel, err := filepath.Rel(src, p); err == nil && b.Options.FS == nil {
+				p = rel
+			}
+			paths = append(paths, filepath.ToSlash(p))
+		}
+		slices.Sort(paths)
+		fmt.Fprintf(&out, "%s: %s\n", reason, strings.Join(paths, ", "))
+	}
+	snapshot := out.Bytes()
+	for _, opt := range opts {
+		snapshot = opt(snapshot)
+	}
+	return snapshot, nil
+}
+
+// Snapshot fails t unless bundling src with b gives the snapshot stored in
+// the golden file, after opts. With BUNDLERTEST_UPDATE=1 it writes the
+// golden file instead.
+func Snapshot(t testing.TB, b *bundler.Bundler, src, golden string, opts ...Option) {
+	t.Helper()
+	got, err := Render(b, src, opts...)
+	if err != nil {
+		t.Fatalf("bundling %s: %v", src, err)
+	}
+	if os.Getenv(UpdateEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("reading the snapshot: %v; run with %s=1 to write it", err, UpdateEnv)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s does not bundle to the snapshot %s; run with %s=1 to update it if the change is intended\n%s", src, golden, UpdateEnv, firstDifference(want, got))
+	}
+}
+
+// firstDifference describes the first line where two snapshots differ,
+// with the lines before it for context.
+func firstDifference(want, got []byte) string {
+	const context = 3
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	i := 0
+	for i < len(wantLines) && i < len(gotLines) && wantLines[i] == gotLines[i] {
+		i++
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "first difference at line %d:\n", i+1)
+	for j := max(0, i-context); j < i; j++ {
+		fmt.Fprintf(&b, "    %s\n", wantLines[j])
+	}
+	if i < len(wantLines) {
+		fmt.Fprintf(&b, "  - %s\n", wantLines[i])
+	} else {
+		b.WriteString("  - (end of snapshot)\n")
+	}
+	if i < len(gotLines) {
+		fmt.Fprintf(&b, "  + %s\n", gotLines[i])
+	} else {
+		b.WriteString("  + (end of bundle)\n")
+	}
+	return b.String()
+}
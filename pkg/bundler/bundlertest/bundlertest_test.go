@@ -0,0 +1,65 @@
+// project-bundler/pkg/bundler/bundlertest/bundlertest_test.go
+package bundlertest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// recordingTB records the failures of Snapshot instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+}
+
+func TestSnapshot(t *testing.T) {
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "go.mod"), []byte("module x\r\n"), 0o644)
+	os.WriteFile(filepath.Join(src, "main.go"), []byte("package main\r\n\r\n// Built 2024-05-01.\r\n"), 0o644)
+	os.MkdirAll(filepath.Join(src, "vendor"), 0o755)
+	opts, err := bundler.NewOptions("go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bundler.New(opts)
+	golden := filepath.Join(t.TempDir(), "testdata", "project.golden")
+	normalize := []Option{NormalizeEOL(), Replace(`\d{4}-\d\d-\d\d`, "<date>")}
+
+	t.Setenv(UpdateEnv, "1")
+	Snapshot(t, b, src, golden, normalize...)
+	want := "File: /go.mod\n```go-mod\nmodule x\n\n```\n\nFile: /main.go\n```go\npackage main\n\n// Built <date>.\n\n```\n\n\n--- skipped ---\nIGNORED_DIR: vendor\n"
+	if data, _ := os.ReadFile(golden); string(data) != want {
+		t.Fatalf("snapshot is %q, want %q", data, want)
+	}
+
+	t.Setenv(UpdateEnv, "")
+	Snapshot(t, b, src, golden, normalize...)
+
+	os.WriteFile(filepath.Join(src, "main.go"), []byte("package main\r\n\r\n// Built 2024-05-02, changed.\r\n"), 0o644)
+	rec := &recordingTB{TB: t}
+	Snapshot(rec, b, src, golden, normalize...)
+	if len(rec.failures) != 1 || !strings.Contains(rec.failures[0], "line 11") || !strings.Contains(rec.failures[0], "+ // Built <date>, changed.") {
+		t.Errorf("failures %q, want one at line 11", rec.failures)
+	}
+
+	rec = &recordingTB{TB: t}
+	Snapshot(rec, b, src, golden, append(normalize, DropLines(`^// Built`), WithoutSkipped())...)
+	if len(rec.failures) != 1 || !strings.Contains(rec.failures[0], "- // Built <date>.") {
+		t.Errorf("failures %q, want one about the dropped line", rec.failures)
+	}
+}
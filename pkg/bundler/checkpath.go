@@ -0,0 +1,80 @@
+// project-bundler/pkg/bundler/checkpath.go
+package bundler
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// CheckPath applies the rules of a walk that only look at names to a file
+// path relative to the source directory, which need not exist: the ignored
+// paths, .gitignore and .bundlerignore files, ignored directories, the secret list, ignored
+// extensions and suffixes, and the allowlist. It returns the reason code and
+// rule that would skip the file, or "" and "" when the file would get past
+// them. The checks that read a file (binary content, generated code, build
+// constraints, minified code) are not applied, except for the secret configs
+// decided by their content, which are read when the file exists.
+func (opts Options) CheckPath(relPath string) (reason, rule string) {
+	name := strings.Trim(path.Clean(filepath.ToSlash(relPath)), "/")
+	fsys := opts.fileSystem()
+	var gitIgnore *gitIgnores
+	if opts.GitIgnore {
+		gitIgnore = newGitIgnores(fsys, opts.GitExcludes)
+	}
+	var bundlerIgnore *gitIgnores
+	if opts.BundlerIgnore {
+		bundlerIgnore = newBundlerIgnores(fsys)
+	}
+	// The walk prunes a skipped directory, so a directory's rules come first.
+	segs := strings.Split(name, "/")
+	for i := range segs {
+		sub, isDir := strings.Join(segs[:i+1], "/"), i < len(segs)-1
+		rel := filepath.FromSlash(sub)
+		if pattern, ok := opts.IgnorePaths.MatchingGlob(rel); ok {
+			return ReasonIgnoredPath, opts.IgnorePaths.Describe("pattern", pattern)
+		}
+		if gitIgnore != nil {
+			if rule, source, ok := gitIgnore.match(sub, isDir); ok {
+				return ReasonGitignored, fmt.Sprintf("pattern %q from %s", rule, source)
+			}
+		}
+		if bundlerIgnore != nil {
+			if rule, source, ok := bundlerIgnore.match(sub, isDir); ok {
+				return ReasonBundlerIgnored, fmt.Sprintf("pattern %q from %s", rule, source)
+			}
+		}
+		if isDir && opts.IgnoreDirs.Contains(segs[i]) {
+			return ReasonIgnoredDir, opts.IgnoreDirs.Describe("directory", segs[i])
+		}
+	}
+
+	base := segs[len(segs)-1]
+	if rule := SecretRule(name, func() []byte { data, _ := fs.ReadFile(fsys, name); return data }); rule != "" && !opts.AllowSecrets {
+		return ReasonSecret, rule
+	}
+	ext := filepath.Ext(base)
+	if opts.IgnoreExts.Contains(ext) {
+		return ReasonIgnoredExt, opts.IgnoreExts.Describe("extension", ext)
+	}
+	if opts.IgnoreExts.Contains(base) {
+		return ReasonIgnoredExt, opts.IgnoreExts.Describe("file name", base)
+	}
+	for _, suffix := range opts.IgnoreSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			return ReasonIgnoredSuffix, fmt.Sprintf("suffix %q from preset %s", suffix, opts.ProjectType)
+		}
+	}
+	if opts.Only != nil {
+		_, known := opts.LangMap[ext]
+		if _, ok := FilenameLangMap[base]; ok {
+			known = true
+		}
+		if _, ok := opts.Only.MatchingGlob(filepath.FromSlash(name)); !ok && !(known && opts.Only.Contains("preset")) {
+			return ReasonPolicy, "no -only or -include pattern matches"
+		}
+	}
+	return "", ""
+}
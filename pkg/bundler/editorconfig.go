@@ -0,0 +1,267 @@
+// project-bundler/pkg/bundler/editorconfig.go
+package bundler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// editorConfigSection is one [glob] section of an .editorconfig file.
+type editorConfigSection struct {
+	pattern *regexp.Regexp // Matches paths relative to the file's directory.
+	props   map[string]string
+}
+
+// editorConfigFile is a parsed .editorconfig file.
+type editorConfigFile struct {
+	root     bool
+	sections []editorConfigSection
+}
+
+// editorConfigs resolves EditorConfig properties for the files of a tree,
+// parsing each directory's .editorconfig at most once.
+type editorConfigs struct {
+	fsys  fs.FS
+	files map[string]*editorConfigFile // Keyed by slash-separated directory; nil when absent.
+	mu    sync.Mutex                   // Guards files; subtrees are walked concurrently.
+}
+
+func newEditorConfigs(fsys fs.FS) *editorConfigs {
+	return &editorConfigs{fsys: fsys, files: make(map[string]*editorConfigFile)}
+}
+
+// properties returns the EditorConfig properties that apply to a file, given
+// relative to the source directory. Files closer to the file and later
+// sections win, as in the specification; the search stops at a file marked
+// root = true or at the source directory.
+func (e *editorConfigs) properties(relPath string) map[string]string {
+	name := filepath.ToSlash(relPath)
+	var chain []string // Directories from the file's up to the source root.
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		chain = append(chain, dir)
+		if dir == "." {
+			break
+		}
+	}
+	props := make(map[string]string)
+	for i := len(chain) - 1; i >= 0; i-- {
+		dir := chain[i]
+		cfg := e.load(dir)
+		if cfg == nil {
+			continue
+		}
+		if cfg.root {
+			clear(props)
+		}
+		rel := name
+		if dir != "." {
+			rel = strings.TrimPrefix(name, dir+"/")
+		}
+		for _, s := range cfg.sections {
+			if s.pattern.MatchString(rel) {
+				for k, v := range s.props {
+					props[k] = v
+				}
+			}
+		}
+	}
+	return props
+}
+
+// load parses the .editorconfig of a directory, caching the result.
+func (e *editorConfigs) load(dir string) *editorConfigFile {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if cfg, ok := e.files[dir]; ok {
+		return cfg
+	}
+	cfg, _ := parseEditorConfig(e.fsys, path.Join(dir, ".editorconfig"))
+	e.files[dir] = cfg
+	return cfg
+}
+
+// parseEditorConfig reads an .editorconfig file. Property names and the
+// values this tool uses are case-insensitive and lowercased.
+func parseEditorConfig(fsys fs.FS, name string) (*editorConfigFile, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cfg := &editorConfigFile{}
+	var current *editorConfigSection
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[' && strings.HasSuffix(line, "]"):
+			re, err := regexp.Compile(editorConfigGlob(line[1 : len(line)-1]))
+			if err != nil {
+				current = nil // Sections with unusable globs match nothing.
+				continue
+			}
+			cfg.sections = append(cfg.sections, editorConfigSection{pattern: re, props: make(map[string]string)})
+			current = &cfg.sections[len(cfg.sections)-1]
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			key, value = strings.ToLower(strings.TrimSpace(key)), strings.ToLower(strings.TrimSpace(value))
+			if current != nil {
+				current.props[key] = value
+			} else if key == "root" {
+				cfg.root = value == "true"
+			}
+		}
+	}
+	return cfg, scanner.Err()
+}
+
+// editorConfigGlob translates an EditorConfig glob into an anchored regular
+// expression: "*" stays within a path segment, "**" crosses them, and
+// {a,b} and {1..3} alternate. A glob without a slash matches the file name
+// at any depth.
+func editorConfigGlob(glob string) string {
+	if strings.HasPrefix(glob, "/") {
+		glob = glob[1:]
+	} else if !strings.Contains(glob, "/") {
+		glob = "**/" + glob
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	depth := 0 // Open {} groups.
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				b.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			if end := strings.IndexByte(glob[i:], ']'); end > 1 {
+				class := glob[i+1 : i+end]
+				if class[0] == '!' {
+					class = "^" + class[1:]
+				}
+				b.WriteString("[" + class + "]")
+				i += end
+			} else {
+				b.WriteString(`\[`)
+			}
+		case '{':
+			end := strings.IndexByte(glob[i:], '}')
+			if end < 0 {
+				b.WriteString(`\{`)
+				break
+			}
+			if lo, hi, ok := strings.Cut(glob[i+1:i+end], ".."); ok {
+				if from, err1 := strconv.Atoi(lo); err1 == nil {
+					if to, err2 := strconv.Atoi(hi); err2 == nil && from <= to && to-from <= 1000 {
+						var alts []string
+						for n := from; n <= to; n++ {
+							alts = append(alts, strconv.Itoa(n))
+						}
+						b.WriteString("(?:" + strings.Join(alts, "|") + ")")
+						i += end
+						break
+					}
+				}
+			}
+			if !strings.Contains(glob[i:i+end], ",") {
+				b.WriteString(`\{`) // A single choice is literal.
+				break
+			}
+			b.WriteString("(?:")
+			depth++
+		case '}':
+			if depth > 0 {
+				b.WriteString(")")
+				depth--
+			} else {
+				b.WriteString(`\}`)
+			}
+		case ',':
+			if depth > 0 {
+				b.WriteString("|")
+			} else {
+				b.WriteString(",")
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// declaresUTF16 reports whether an EditorConfig charset is UTF-16, whose
+// text legitimately contains null bytes.
+func declaresUTF16(charset string) bool {
+	return charset == "utf-16be" || charset == "utf-16le"
+}
+
+// Transcode converts content in a declared EditorConfig charset to UTF-8 and
+// the declared line endings to "\n". Undeclared properties leave the content
+// as it is; changed reports whether the charset was converted.
+func Transcode(content []byte, charset, eol string) (out []byte, changed bool) {
+	switch charset {
+	case "latin1":
+		buf := make([]byte, 0, len(content))
+		for _, c := range content {
+			buf = utf8.AppendRune(buf, rune(c))
+		}
+		content, changed = buf, true
+	case "utf-8-bom":
+		content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	case "utf-16be", "utf-16le":
+		var order binary.ByteOrder = binary.BigEndian
+		bom := []byte{0xfe, 0xff}
+		if charset == "utf-16le" {
+			order, bom = binary.LittleEndian, []byte{0xff, 0xfe}
+		}
+		content = bytes.TrimPrefix(content, bom)
+		units := make([]uint16, len(content)/2)
+		for i := range units {
+			units[i] = order.Uint16(content[2*i:])
+		}
+		buf := make([]byte, 0, len(content))
+		for _, r := range utf16.Decode(units) {
+			buf = utf8.AppendRune(buf, r)
+		}
+		if len(content)%2 == 1 {
+			buf = utf8.AppendRune(buf, utf8.RuneError)
+		}
+		content, changed = buf, true
+	}
+	switch eol {
+	case "crlf":
+		content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	case "cr":
+		content = bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+	}
+	return content, changed
+}
@@ -0,0 +1,123 @@
+// project-bundler/pkg/bundler/encoding.go
+package bundler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// DetectCharset guesses the charset of a file from its first bytes, in the
+// names Transcode takes: "utf-8-bom", "utf-16le" or "utf-16be" from a byte
+// order mark, "utf-16le" or "utf-16be" for BOM-less UTF-16 that is mostly
+// ASCII, as some Windows tools write it, and "latin1" for text that is not
+// valid UTF-8. Plain UTF-8 and content that looks binary yield "".
+func DetectCharset(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("\xef\xbb\xbf")):
+		return "utf-8-bom"
+	case bytes.HasPrefix(head, []byte{0xff, 0xfe, 0, 0}), bytes.HasPrefix(head, []byte{0, 0, 0xfe, 0xff}):
+		return "" // UTF-32, which Transcode does not decode.
+	case bytes.HasPrefix(head, []byte{0xff, 0xfe}):
+		return "utf-16le"
+	case bytes.HasPrefix(head, []byte{0xfe, 0xff}):
+		return "utf-16be"
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return detectUTF16(head)
+	}
+	// The head may end in the middle of a character.
+	for n := 1; n < utf8.UTFMax && len(head) > 0; n++ {
+		if r, size := utf8.DecodeLastRune(head); r != utf8.RuneError || size != 1 {
+			break
+		}
+		head = head[:len(head)-1]
+	}
+	if !utf8.Valid(head) {
+		return "latin1"
+	}
+	return ""
+}
+
+// detectUTF16 recognizes BOM-less UTF-16 by the null high byte of ASCII
+// characters. Nearly every code unit must be printable ASCII, so that
+// binaries with runs of nulls are not mistaken for text.
+func detectUTF16(head []byte) string {
+	if len(head) < 4 {
+		return ""
+	}
+	var le, be int
+	units := len(head) / 2
+	for i := 0; i+1 < len(head); i += 2 {
+		switch {
+		case head[i+1] == 0 && isASCIIText(head[i]):
+			le++
+		case head[i] == 0 && isASCIIText(head[i+1]):
+			be++
+		}
+	}
+	switch {
+	case le*10 >= units*9:
+		return "utf-16le"
+	case be*10 >= units*9:
+		return "utf-16be"
+	}
+	return ""
+}
+
+func isASCIIText(c byte) bool {
+	return c >= 0x20 && c < 0x7f || c == '\t' || c == '\n' || c == '\r'
+}
+
+// NormalizeEOL converts CRLF and lone CR line endings to LF.
+func NormalizeEOL(content []byte) []byte {
+	if bytes.IndexByte(content, '\r') < 0 {
+		return content
+	}
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+}
+
+// Encode reverses Transcode: it converts UTF-8 content with LF line endings
+// back to charset ("latin1", "utf-8-bom", "utf-16le" or "utf-16be") and eol
+// ("crlf" or "cr"). bom prepends the charset's byte order mark, which a file
+// declared as utf-8-bom or detected as UTF-16 may or may not have had.
+// Characters latin1 cannot represent become '?'.
+func Encode(content []byte, charset string, bom bool, eol string) []byte {
+	switch eol {
+	case "crlf":
+		content = bytes.ReplaceAll(content, []byte("\n"), []byte("\r\n"))
+	case "cr":
+		content = bytes.ReplaceAll(content, []byte("\n"), []byte("\r"))
+	}
+	switch charset {
+	case "latin1":
+		buf := make([]byte, 0, len(content))
+		for _, r := range string(content) {
+			if r > 0xff {
+				r = '?'
+			}
+			buf = append(buf, byte(r))
+		}
+		return buf
+	case "utf-8-bom":
+		if bom {
+			return append([]byte("\xef\xbb\xbf"), content...)
+		}
+	case "utf-16le", "utf-16be":
+		var order binary.AppendByteOrder = binary.BigEndian
+		if charset == "utf-16le" {
+			order = binary.LittleEndian
+		}
+		buf := make([]byte, 0, 2*len(content)+2)
+		if bom {
+			buf = order.AppendUint16(buf, 0xfeff)
+		}
+		for _, u := range utf16.Encode([]rune(string(content))) {
+			buf = order.AppendUint16(buf, u)
+		}
+		return buf
+	}
+	return content
+}
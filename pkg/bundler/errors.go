@@ -0,0 +1,76 @@
+// project-bundler/pkg/bundler/errors.go
+package bundler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors of Bundle that callers can branch on with errors.Is, besides
+// ErrDeadlineExceeded.
+var (
+	// ErrSourceNotFound is returned when the src directory does not exist.
+	ErrSourceNotFound = errors.New("source directory not found")
+	// ErrSecretDetected is matched by the *SecretError of FailOnSecrets.
+	ErrSecretDetected = errors.New("secret detected")
+	// ErrBudgetExceeded is returned when the files would pass MaxBytes.
+	ErrBudgetExceeded = errors.New("bundle budget exceeded")
+)
+
+// SecretError stops a bundle with Options.FailOnSecrets at the first file
+// holding likely credentials, before its block is written.
+type SecretError struct {
+	Path     string // RelPath of the file.
+	Findings []SecretFinding
+}
+
+func (e *SecretError) Error() string {
+	rules := make([]string, len(e.Findings))
+	for i, f := range e.Findings {
+		rules[i] = fmt.Sprintf("%s on line %d", f.Rule, f.Line)
+	}
+	return fmt.Sprintf("%s: %s in %s", ErrSecretDetected, strings.Join(rules, ", "), e.Path)
+}
+
+func (e *SecretError) Is(target error) bool { return target == ErrSecretDetected }
+
+// FileError is the failure of one file of a bundle.
+type FileError struct {
+	Path   string // File.Path of the file.
+	Reason string // Reason code it is reported under in Report.Skipped.
+	Err    error  // Nil when a Render hook skipped the file without one.
+}
+
+func (e *FileError) Error() string {
+	if e.Err == nil {
+		return e.Path + ": " + ReasonText[e.Reason]
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error { return e.Err }
+
+// PartialBundleError is returned by Bundle when files selected for the
+// bundle could not be read. The bundle is complete without them: callers
+// that accept that can take the report as for a nil error.
+type PartialBundleError struct {
+	Files []FileError
+}
+
+func (e *PartialBundleError) Error() string {
+	if len(e.Files) == 1 {
+		return "bundle is missing a file: " + e.Files[0].Error()
+	}
+	return fmt.Sprintf("bundle is missing %d files, the first %s", len(e.Files), e.Files[0].Error())
+}
+
+// Unwrap returns the files' errors, so that errors.Is finds, for example,
+// fs.ErrPermission among them.
+func (e *PartialBundleError) Unwrap() []error {
+	errs := make([]error, len(e.Files))
+	for i := range e.Files {
+		errs[i] = &e.Files[i]
+	}
+	return errs
+}
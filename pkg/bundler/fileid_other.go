@@ -0,0 +1,12 @@
+//go:build !unix
+
+// project-bundler/pkg/bundler/fileid_other.go
+package bundler
+
+import "io/fs"
+
+// fileIdentity is not available on this platform, so hard links and bind
+// mounts are bundled like separate files.
+func fileIdentity(info fs.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+// project-bundler/pkg/bundler/fileid_unix.go
+package bundler
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileIdentity returns the device and inode of a file, which are shared by
+// hard links and by the same tree seen through a bind mount.
+func fileIdentity(info fs.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
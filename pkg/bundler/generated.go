@@ -0,0 +1,31 @@
+// project-bundler/pkg/bundler/generated.go
+package bundler
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"regexp"
+)
+
+// generatedHeader is the marker Go tools put in generated files
+// (https://go.dev/s/generatedcode); protoc plugins, sqlc, mockgen and
+// stringer all follow it.
+var generatedHeader = regexp.MustCompile(`(?m)^// Code generated .* DO NOT EDIT\.$`)
+
+// IsGeneratedFile reports whether the named file of fsys carries the
+// generated-code marker before its package clause.
+func IsGeneratedFile(fsys fs.FS, name string) bool {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, 4096)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	if i := bytes.Index(head, []byte("\npackage ")); i >= 0 {
+		head = head[:i]
+	}
+	return generatedHeader.Match(head)
+}
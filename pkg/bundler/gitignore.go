@@ -0,0 +1,210 @@
+// project-bundler/pkg/bundler/gitignore.go
+package bundler
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// gitIgnoreRule is one pattern line of a .gitignore file.
+type gitIgnoreRule struct {
+	pattern *regexp.Regexp // Matches paths relative to the file's directory.
+	text    string         // The line as written, for -verbose.
+	negate  bool           // "!pattern" re-includes what earlier rules excluded.
+	dirOnly bool           // "pattern/" only matches directories.
+}
+
+// gitIgnoreFile is a parsed .gitignore (or exclude) file.
+type gitIgnoreFile struct {
+	source string // Shown as the provenance of its rules.
+	rules  []gitIgnoreRule
+}
+
+// gitIgnores resolves the .gitignore rules for the paths of a tree, parsing
+// each directory's .gitignore at most once. As in git, the global excludes
+// come first, then .git/info/exclude, then .gitignore files from the root
+// down, and the last matching rule wins. The same engine reads .bundlerignore
+// files, which have no base files.
+type gitIgnores struct {
+	fsys  fs.FS
+	name  string                    // The file read in each directory: .gitignore or .bundlerignore.
+	base  []*gitIgnoreFile          // Global excludes and .git/info/exclude, relative to the root.
+	files map[string]*gitIgnoreFile // Keyed by slash-separated directory; nil when absent.
+	mu    sync.Mutex                // Guards files; subtrees are walked concurrently.
+}
+
+func newGitIgnores(fsys fs.FS, globalExcludes []string) *gitIgnores {
+	g := &gitIgnores{fsys: fsys, name: ".gitignore", files: make(map[string]*gitIgnoreFile)}
+	if len(globalExcludes) > 0 {
+		g.base = append(g.base, parseGitIgnore(strings.NewReader(strings.Join(globalExcludes, "\n")), "global git excludes"))
+	}
+	if f, err := fsys.Open(".git/info/exclude"); err == nil {
+		g.base = append(g.base, parseGitIgnore(f, ".git/info/exclude"))
+		f.Close()
+	}
+	return g
+}
+
+// newBundlerIgnores resolves the rules of .bundlerignore files, which use
+// gitignore syntax and apply to the subtree of their directory whether or
+// not the tree is a git repository.
+func newBundlerIgnores(fsys fs.FS) *gitIgnores {
+	return &gitIgnores{fsys: fsys, name: ".bundlerignore", files: make(map[string]*gitIgnoreFile)}
+}
+
+// match reports whether a slash-separated path relative to the root is
+// ignored, and the rule that decided it.
+func (g *gitIgnores) match(name string, isDir bool) (rule, source string, ignored bool) {
+	apply := func(file *gitIgnoreFile, rel string) {
+		for _, r := range file.rules {
+			if (!r.dirOnly || isDir) && r.pattern.MatchString(rel) {
+				rule, source, ignored = r.text, file.source, !r.negate
+			}
+		}
+	}
+	for _, file := range g.base {
+		apply(file, name)
+	}
+	var chain []string // Directories from the path's up to the root.
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		chain = append(chain, dir)
+		if dir == "." {
+			break
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		dir := chain[i]
+		file := g.load(dir)
+		if file == nil {
+			continue
+		}
+		rel := name
+		if dir != "." {
+			rel = strings.TrimPrefix(name, dir+"/")
+		}
+		apply(file, rel)
+	}
+	return rule, source, ignored
+}
+
+// load parses the ignore file of a directory, caching the result.
+func (g *gitIgnores) load(dir string) *gitIgnoreFile {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if file, ok := g.files[dir]; ok {
+		return file
+	}
+	var file *gitIgnoreFile
+	if f, err := g.fsys.Open(path.Join(dir, g.name)); err == nil {
+		file = parseGitIgnore(f, path.Join(dir, g.name))
+		f.Close()
+	}
+	g.files[dir] = file
+	return file
+}
+
+// parseGitIgnore reads gitignore patterns. Lines that do not translate into
+// a valid expression are ignored, as git ignores malformed patterns.
+func parseGitIgnore(r io.Reader, source string) *gitIgnoreFile {
+	file := &gitIgnoreFile{source: source}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		// Trailing spaces are dropped unless escaped with a backslash.
+		if trimmed := strings.TrimRight(line, " "); strings.HasSuffix(trimmed, "\\") && len(trimmed) < len(line) {
+			line = trimmed + " "
+		} else {
+			line = trimmed
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		rule := gitIgnoreRule{text: line}
+		if line[0] == '!' {
+			rule.negate, line = true, line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		re, err := regexp.Compile(gitIgnoreRegexp(line))
+		if err != nil || line == "" {
+			continue
+		}
+		rule.pattern = re
+		file.rules = append(file.rules, rule)
+	}
+	return file
+}
+
+// gitIgnoreRegexp translates a gitignore pattern (without "!" and trailing
+// "/") into an anchored regular expression. A pattern containing a slash is
+// relative to the .gitignore's directory; one without matches a name at any
+// depth. "*", "?" and classes stay within a path segment, while a "**"
+// segment spans any number of them.
+func gitIgnoreRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	if !strings.Contains(pattern, "/") {
+		b.WriteString("(?:.*/)?")
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/") && (i == 0 || pattern[i-1] == '/'):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case pattern[i:] == "**" && (i == 0 || pattern[i-1] == '/'):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// GitIgnoreMatcher compiles a gitignore-style pattern, such as a CODEOWNERS
+// rule, into a function that reports whether it matches a slash-separated
+// path relative to the root or one of the directories the path is in. It
+// returns nil for a malformed pattern.
+func GitIgnoreMatcher(pattern string) func(relPath string) bool {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	re, err := regexp.Compile(gitIgnoreRegexp(strings.TrimRight(pattern, "/")))
+	if err != nil {
+		return nil
+	}
+	return func(relPath string) bool {
+		for name, isDir := relPath, false; name != "." && name != "/" && name != ""; name, isDir = path.Dir(name), true {
+			if (isDir || !dirOnly) && re.MatchString(name) {
+				return true
+			}
+		}
+		return false
+	}
+}
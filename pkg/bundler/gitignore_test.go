@@ -0,0 +1,99 @@
+// project-bundler/pkg/bundler/gitignore_test.go
+package bundler
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestGitIgnoresMatch(t *testing.T) {
+	fsys := fstest.MapFS{
+		".gitignore": {Data: []byte(`# comment
+*.log
+!keep.log
+/build
+out/
+docs/**/*.tmp
+a/**/b
+**/cache
+file?.txt
+[abc].md
+[!x]y.cfg
+\#hash
+\!bang
+` + "trail\\ \n")},
+		".git/info/exclude": {Data: []byte("secret.txt\n")},
+		"sub/.gitignore":    {Data: []byte("/local.go\n!*.log\nnested/\n")},
+	}
+	g := newGitIgnores(fsys, []string{"*.swp"})
+	tests := []struct {
+		name    string
+		isDir   bool
+		ignored bool
+		rule    string
+	}{
+		{"app.log", false, true, "*.log"},
+		{"deep/dir/app.log", false, true, "*.log"},
+		{"keep.log", false, false, "!keep.log"},
+		{"build", true, true, "/build"},
+		{"build", false, true, "/build"},
+		{"src/build", true, false, ""},
+		{"out", true, true, "out/"},
+		{"out", false, false, ""},
+		{"src/out", true, true, "out/"},
+		{"docs/x.tmp", false, true, "docs/**/*.tmp"},
+		{"docs/a/b/x.tmp", false, true, "docs/**/*.tmp"},
+		{"src/docs/x.tmp", false, false, ""},
+		{"a/b", true, true, "a/**/b"},
+		{"a/x/y/b", true, true, "a/**/b"},
+		{"cache", true, true, "**/cache"},
+		{"x/y/cache", true, true, "**/cache"},
+		{"file1.txt", false, true, "file?.txt"},
+		{"file10.txt", false, false, ""},
+		{"dir/file1.txt", false, true, "file?.txt"},
+		{"b.md", false, true, "[abc].md"},
+		{"d.md", false, false, ""},
+		{"zy.cfg", false, true, "[!x]y.cfg"},
+		{"xy.cfg", false, false, ""},
+		{"#hash", false, true, `\#hash`},
+		{"!bang", false, true, `\!bang`},
+		{"trail ", false, true, `trail\ `},
+		{"trail", false, false, ""},
+		{"secret.txt", false, true, "secret.txt"},
+		{"x.swp", false, true, "*.swp"},
+		{"sub/local.go", false, true, "/local.go"},
+		{"local.go", false, false, ""},
+		{"sub/deeper/local.go", false, false, ""},
+		{"sub/app.log", false, false, "!*.log"},
+		{"sub/deeper/app.log", false, false, "!*.log"},
+		{"sub/nested", true, true, "nested/"},
+		{"nested", true, false, ""},
+	}
+	for _, tt := range tests {
+		rule, _, ignored := g.match(tt.name, tt.isDir)
+		if ignored != tt.ignored || rule != tt.rule {
+			t.Errorf("match(%q, dir=%v) = %q, %v; want %q, %v", tt.name, tt.isDir, rule, ignored, tt.rule, tt.ignored)
+		}
+	}
+}
+
+func TestGitIgnoreMatcher(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "pkg/x/main.go", true},
+		{"/docs", "docs/readme.md", true},
+		{"/docs", "src/docs/readme.md", false},
+		{"docs/", "docs/readme.md", true},
+		{"docs/", "docs", false},
+		{"apps/**/test", "apps/a/b/test/x.go", true},
+		{"apps/*/x.go", "apps/a/b/x.go", false},
+	}
+	for _, tt := range tests {
+		if got := GitIgnoreMatcher(tt.pattern)(tt.path); got != tt.want {
+			t.Errorf("GitIgnoreMatcher(%q)(%q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// project-bundler/pkg/bundler/highlighters.go
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Highlighters maps the names of common syntax highlighters to the
+// identifiers they expect for the language IDs DetectLanguage returns, where
+// the two differ: "objectivec" is "objective-c" to Chroma, and highlight.js
+// knows shell scripts as "bash". IDs that are not listed are used as they
+// are. github (Linguist, which also backs GitLab and most Markdown viewers
+// that follow GitHub) accepts every ID, so its map is empty.
+var Highlighters = map[string]map[string]string{
+	"github": {},
+	"highlightjs": {
+		"text": "plaintext", "shell": "bash", "sed": "bash", "go-mod": "go",
+		"gitignore": "plaintext", "gitattributes": "plaintext", "caddyfile": "plaintext", "meson": "plaintext", "rst": "plaintext",
+		"vue": "xml", "svelte": "xml", "razor": "cshtml", "elisp": "lisp", "racket": "scheme",
+		"starlark": "python", "cython": "python", "just": "makefile", "sass": "scss",
+	},
+	"prism": {
+		"text": "plaintext", "shell": "bash", "sed": "bash", "go-mod": "go-module",
+		"gitattributes": "plaintext", "caddyfile": "plaintext", "meson": "plaintext", "rst": "rest",
+		"vue": "markup", "svelte": "markup", "starlark": "python", "cython": "python", "just": "makefile",
+	},
+	"chroma": {
+		"text": "plaintext", "shell": "bash", "sed": "bash", "go-mod": "go", "objectivec": "objective-c",
+		"gitignore": "plaintext", "gitattributes": "plaintext", "elisp": "emacslisp", "vbnet": "vb.net",
+		"starlark": "python", "cython": "python", "just": "makefile",
+	},
+}
+
+// HighlighterNames returns the names of all highlighters in lexical order.
+func HighlighterNames() []string {
+	var names []string
+	for name := range Highlighters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithHighlighter returns a copy of the style whose fences name languages
+// the way the named highlighter expects; "none" uses the language IDs as
+// they are.
+func (s Style) WithHighlighter(name string) (Style, error) {
+	if name == "none" {
+		s.Highlighter = ""
+		return s, nil
+	}
+	if _, ok := Highlighters[name]; !ok {
+		return s, fmt.Errorf("unknown highlighter '%s'; use %s or none", name, strings.Join(HighlighterNames(), ", "))
+	}
+	s.Highlighter = name
+	return s, nil
+}
+
+// FenceLang returns the identifier the style's fences use for a language ID.
+func (s Style) FenceLang(lang string) string {
+	if id, ok := Highlighters[s.Highlighter][lang]; ok {
+		return id
+	}
+	return lang
+}
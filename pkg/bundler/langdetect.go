@@ -0,0 +1,139 @@
+// project-bundler/pkg/bundler/langdetect.go
+package bundler
+
+import (
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// interpreterLangs maps the interpreter of a shebang line, without version
+// digits, to a language.
+var interpreterLangs = map[string]string{
+	"sh": "shell", "bash": "shell", "dash": "shell", "ash": "shell", "ksh": "shell", "zsh": "shell",
+	"fish":   "fish",
+	"python": "python", "pypy": "python",
+	"node": "javascript", "nodejs": "javascript", "deno": "typescript", "bun": "javascript",
+	"ts-node": "typescript", "tsx": "typescript",
+	"ruby": "ruby", "perl": "perl", "php": "php", "lua": "lua", "luajit": "lua",
+	"Rscript": "r", "julia": "julia", "tclsh": "tcl", "wish": "tcl",
+	"pwsh": "powershell", "awk": "awk", "gawk": "awk", "sed": "sed",
+	"make": "makefile", "osascript": "applescript", "elixir": "elixir",
+	"runhaskell": "haskell", "stack": "haskell", "swift": "swift", "groovy": "groovy",
+	"kotlin": "kotlin", "scala": "scala", "dotnet": "csharp", "escript": "erlang",
+	"guile": "scheme", "racket": "racket", "sbcl": "lisp", "crystal": "crystal", "nim": "nim",
+}
+
+// versionSuffixRE matches the version of an interpreter name such as
+// python3.11 or lua5.4.
+var versionSuffixRE = regexp.MustCompile(`[0-9.]+$`)
+
+// Editor modelines name the language of a file explicitly, e.g.
+// "-*- mode: python -*-" (Emacs) or "vim: set ft=python:" (Vim).
+var (
+	emacsModeRE = regexp.MustCompile(`-\*-.*?\bmode:\s*([A-Za-z0-9+-]+)`)
+	vimModeRE   = regexp.MustCompile(`\bvim?:.*?\b(?:ft|filetype)=([A-Za-z0-9+-]+)`)
+)
+
+// modelineLangs maps editor mode names that differ from the fence tags.
+var modelineLangs = map[string]string{
+	"sh": "shell", "bash": "shell", "zsh": "shell", "js": "javascript", "js2": "javascript",
+	"ts": "typescript", "py": "python", "rb": "ruby", "make": "makefile", "conf": "ini",
+	"dosini": "ini", "c++": "cpp", "cperl": "perl", "emacs-lisp": "elisp", "yml": "yaml",
+}
+
+// DetectLanguageContent is DetectLanguage for a file whose first bytes are
+// known. A file that neither langMap nor FilenameLangMap names, such as a
+// script without an extension, is recognized by its shebang line, an editor
+// modeline or the markers of a few formats.
+func DetectLanguageContent(name string, langMap map[string]string, head []byte) string {
+	if lang, ok := lookupLanguage(name, langMap); ok {
+		return lang
+	}
+	if lang := contentLanguage(head); lang != "" {
+		return lang
+	}
+	return "text"
+}
+
+// lookupLanguage finds the language of a file name in langMap and
+// FilenameLangMap.
+func lookupLanguage(name string, langMap map[string]string) (string, bool) {
+	if lang, ok := langMap[name]; ok { // 1. Try a file name in langMap.
+		return lang, true
+	}
+	if lang, ok := FilenameLangMap[name]; ok { // 2. Try by full filename.
+		return lang, true
+	}
+	if lang, ok := langMap[filepath.Ext(name)]; ok { // 3. Try by extension.
+		return lang, true
+	}
+	return "", false
+}
+
+// contentLanguage guesses a language from the first bytes of a file, or
+// returns "".
+func contentLanguage(head []byte) string {
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	first, _, _ := bytes.Cut(head, []byte("\n"))
+	if lang := shebangLanguage(string(bytes.TrimSpace(first))); lang != "" {
+		return lang
+	}
+	// Modelines are on the first lines; the Emacs one may follow a shebang.
+	lines := bytes.SplitN(head, []byte("\n"), 4)
+	for _, line := range lines[:min(len(lines), 3)] {
+		for _, re := range []*regexp.Regexp{emacsModeRE, vimModeRE} {
+			if m := re.FindSubmatch(line); m != nil {
+				mode := strings.ToLower(string(m[1]))
+				if lang, ok := modelineLangs[mode]; ok {
+					return lang
+				}
+				return mode
+			}
+		}
+	}
+
+	trimmed := bytes.TrimSpace(head)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<?php")):
+		return "php"
+	case bytes.HasPrefix(trimmed, []byte("<?xml")):
+		return "xml"
+	case hasPrefixFold(trimmed, "<!doctype html"), hasPrefixFold(trimmed, "<html"):
+		return "html"
+	case bytes.HasPrefix(trimmed, []byte("diff --git ")),
+		bytes.HasPrefix(trimmed, []byte("--- ")) && bytes.Contains(head, []byte("\n+++ ")):
+		return "diff"
+	}
+	return ""
+}
+
+// shebangLanguage returns the language of the interpreter a shebang line
+// runs, through env or directly, or "".
+func shebangLanguage(line string) string {
+	if !strings.HasPrefix(line, "#!") {
+		return ""
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := fields[0]
+	if filepath.Base(interpreter) == "env" {
+		// Skip env's options, e.g. "#!/usr/bin/env -S deno run".
+		interpreter = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interpreter = f
+				break
+			}
+		}
+	}
+	name := versionSuffixRE.ReplaceAllString(filepath.Base(interpreter), "")
+	return interpreterLangs[name]
+}
+
+func hasPrefixFold(b []byte, prefix string) bool {
+	return len(b) >= len(prefix) && strings.EqualFold(string(b[:len(prefix)]), prefix)
+}
@@ -0,0 +1,88 @@
+// project-bundler/pkg/bundler/marker.go
+package bundler
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// ignoreMarkerLines is how many lines at the top of a file HasIgnoreMarker
+// looks at.
+const ignoreMarkerLines = 5
+
+// markerComment matches the start of a comment line holding a marker, in
+// the comment syntax of most languages: "// bundler:ignore", "# ...",
+// "<!-- ... -->", "-- ..." and the like.
+const markerComment = `^(\s*(?://+|#+|/\*+|\*|<!--|--|;+|%+|\{-|\(\*|')\s*)bundler:`
+
+var (
+	ignoreMarkerRE = regexp.MustCompile(markerComment + `ignore\b`)
+	redactStartRE  = regexp.MustCompile(markerComment + `redact-start\b(.*)$`)
+	redactEndRE    = regexp.MustCompile(markerComment + `redact-end\b`)
+)
+
+// HasIgnoreMarker reports whether one of the first lines of a file's content
+// is a "bundler:ignore" comment, which its owner puts there to keep the file
+// out of every bundle.
+func HasIgnoreMarker(head []byte) bool {
+	for i, line := range bytes.SplitN(head, []byte("\n"), ignoreMarkerLines+1) {
+		if i == ignoreMarkerLines {
+			break
+		}
+		if ignoreMarkerRE.Match(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactSections replaces each region of content between a
+// "bundler:redact-start" and a "bundler:redact-end" comment line, markers
+// included, with one comment line saying how many lines were left out, in
+// the same comment syntax: "// bundler:redacted (12 lines)". A start
+// without an end redacts the rest of the file. redacted is false when the
+// content has no regions.
+func RedactSections(content []byte) (out []byte, redacted bool) {
+	if !bytes.Contains(content, []byte("bundler:redact-start")) {
+		return content, false
+	}
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	var b bytes.Buffer
+	b.Grow(len(content))
+	for i := 0; i < len(lines); i++ {
+		m := redactStartRE.FindSubmatch(bytes.TrimRight(lines[i], "\r\n"))
+		if m == nil {
+			b.Write(lines[i])
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !redactEndRE.Match(lines[end]) {
+			end++
+		}
+		n := min(end, len(lines)-1) - i + 1
+		if end == len(lines) && len(lines[end-1]) == 0 {
+			n-- // The empty string after a final newline.
+		}
+		fmt.Fprintf(&b, "%sbundler:redacted (%d lines)%s\n", m[1], n, closingComment(m[2]))
+		i, redacted = end, true
+	}
+	if !redacted {
+		return content, false
+	}
+	if !bytes.HasSuffix(content, []byte("\n")) && bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+		b.Truncate(b.Len() - 1)
+	}
+	return b.Bytes(), true
+}
+
+// closingComment returns what closes a block comment in the rest of a
+// marker line, such as " -->" or " */", so the placeholder stays a comment.
+func closingComment(rest []byte) string {
+	for _, closer := range []string{"-->", "*/", "-}", "*)"} {
+		if bytes.HasSuffix(bytes.TrimSpace(rest), []byte(closer)) {
+			return " " + closer
+		}
+	}
+	return ""
+}
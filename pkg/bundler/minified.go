@@ -0,0 +1,72 @@
+// project-bundler/pkg/bundler/minified.go
+package bundler
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// minifiedSample is how much of a file IsMinifiedFile reads.
+const minifiedSample = 32 << 10
+
+// bundlerMarkers are written by webpack and similar bundlers into their
+// output, however it is minified.
+var bundlerMarkers = [][]byte{
+	[]byte("__webpack_require__"),
+	[]byte("webpackChunk"),
+	[]byte("/******/"),
+	[]byte("System.register("),
+	[]byte("__vite__"),
+	[]byte("parcelRequire"),
+}
+
+// IsMinified reports whether JavaScript or CSS content, given by its name
+// and first bytes, is minified or the output of a bundler: code nobody edits
+// that costs many tokens. The signals are a .min. name, bundler markers,
+// and long lines with little whitespace; a sourceMappingURL comment, which
+// build tools append to their output, lowers the bar.
+func IsMinified(name string, head []byte) bool {
+	if strings.Contains(path.Base(name), ".min.") {
+		return true
+	}
+	for _, marker := range bundlerMarkers {
+		if bytes.Contains(head, marker) {
+			return true
+		}
+	}
+	if len(head) < 1024 {
+		return false
+	}
+	longest, lines := 0, 0
+	for rest := head; len(rest) > 0; lines++ {
+		line, after, _ := bytes.Cut(rest, []byte("\n"))
+		longest = max(longest, len(line))
+		rest = after
+	}
+	spaces := 0
+	for _, c := range head {
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			spaces++
+		}
+	}
+	minLine := 500
+	if bytes.Contains(head, []byte("sourceMappingURL=")) {
+		minLine = 200
+	}
+	return longest >= minLine && (len(head)/lines >= 200 || spaces*20 < len(head))
+}
+
+// isMinifiedFile reads the start of the named file of fsys for IsMinified.
+func isMinifiedFile(fsys fs.FS, name string) bool {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, minifiedSample)
+	n, _ := io.ReadFull(f, head)
+	return IsMinified(name, head[:n])
+}
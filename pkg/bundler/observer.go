@@ -0,0 +1,39 @@
+// project-bundler/pkg/bundler/observer.go
+package bundler
+
+// Observer receives the progress of a Bundle call as it happens, so that
+// GUIs and bots can show it live. Its methods are called from the goroutine
+// that called Bundle, in bundle order. Embed NopObserver to implement only
+// some of them.
+type Observer interface {
+	// OnFileIncluded is called after each file's block is written.
+	OnFileIncluded(f RenderedFile)
+	// OnFileSkipped is called for each path left out, with its reason code:
+	// during the walk, and for files that could not be read.
+	OnFileSkipped(path, reason string)
+	// OnProgress is called after each selected file is written or skipped,
+	// with the number done so far out of the files selected by the walk.
+	OnProgress(done, total int)
+	// OnComplete is called with the report once the bundle is complete.
+	OnComplete(report *Report)
+}
+
+// RenderedFile is a file as Bundle wrote it.
+type RenderedFile struct {
+	File
+	Index      int    // Position in the bundle, from 1.
+	Lang       string // Language of the block; "text" for stubs.
+	Annotation string // Lines written between the path header and the content.
+	Content    []byte // Content of the block.
+	Raw        []byte // The file as read; nil for stubs, whose content is not read.
+	Block      []byte // When set, written as is in place of rendering Content, e.g. from a cache.
+}
+
+// NopObserver ignores every event. Embed it in an Observer that only needs
+// some of them.
+type NopObserver struct{}
+
+func (NopObserver) OnFileIncluded(RenderedFile)  {}
+func (NopObserver) OnFileSkipped(string, string) {}
+func (NopObserver) OnProgress(int, int)          {}
+func (NopObserver) OnComplete(*Report)           {}
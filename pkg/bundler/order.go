@@ -0,0 +1,64 @@
+// project-bundler/pkg/bundler/order.go
+package bundler
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Orders are the file orders SortFiles accepts. depth-first is the order of
+// the walk: the entries of each directory by name, each subdirectory in
+// full where its name falls.
+var Orders = []string{"depth-first", "path", "size", "ext", "mtime"}
+
+// SortFiles puts files in the named order, "" meaning depth-first:
+//
+//   - path: by the full slash-separated path, byte by byte.
+//   - size: largest first.
+//   - ext: by extension, so files of a kind are together.
+//   - mtime: most recently modified first.
+//
+// Ties are broken by path, so the order depends only on the files and not
+// on the order they came in.
+func SortFiles(files []File, order string) error {
+	var by func(a, b File) int
+	switch order {
+	case "", "depth-first":
+		by = func(a, b File) int { return compareDepthFirst(a.RelPath, b.RelPath) }
+	case "path":
+		by = func(a, b File) int { return 0 }
+	case "size":
+		by = func(a, b File) int { return cmp.Compare(b.Size, a.Size) }
+	case "ext":
+		by = func(a, b File) int {
+			return strings.Compare(strings.ToLower(filepath.Ext(a.RelPath)), strings.ToLower(filepath.Ext(b.RelPath)))
+		}
+	case "mtime":
+		by = func(a, b File) int { return b.ModTime.Compare(a.ModTime) }
+	default:
+		return fmt.Errorf("invalid order '%s'. Available orders are: %s", order, strings.Join(Orders, ", "))
+	}
+	slices.SortStableFunc(files, func(a, b File) int {
+		if c := by(a, b); c != 0 {
+			return c
+		}
+		return strings.Compare(filepath.ToSlash(a.RelPath), filepath.ToSlash(b.RelPath))
+	})
+	return nil
+}
+
+// compareDepthFirst orders two relative paths as fs.WalkDir visits them:
+// element by element, so "a/b" comes before "a.go" though '.' < '/'.
+func compareDepthFirst(a, b string) int {
+	as := strings.Split(filepath.ToSlash(a), "/")
+	bs := strings.Split(filepath.ToSlash(b), "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := strings.Compare(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
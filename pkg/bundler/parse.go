@@ -0,0 +1,343 @@
+// project-bundler/pkg/bundler/parse.go
+package bundler
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// escapedFileTagRE matches a closing file tag the claude style escaped.
+var escapedFileTagRE = regexp.MustCompile(`<\\(\\*)/file>`)
+
+// Block is one file block read back from a bundle.
+type Block struct {
+	Path       string // The header's path, unescaped and without a leading "/".
+	Annotation string // Lines between the header and the opening fence; fenced styles only.
+	Lang       string // The opening fence's info string; fenced styles only.
+	Content    []byte
+	Line       int // Line of the path header in the bundle, from 1.
+	// Unclosed is set when the block lacks its closing fence, tag or footer:
+	// the bundle ended inside it or, for a fenced style, the next header came
+	// before its opening fence.
+	Unclosed bool
+	// Removed is set for a tombstone, the block a journal writes with
+	// WriteRemoved for a file removed since its previous record.
+	Removed bool
+}
+
+// Parse reads the file blocks of a bundle written in style s, undoing what
+// WriteFile did: a bundle written by this package yields every file's exact
+// content. It is lenient with bundles edited by hand or returned by a model:
+// a closing fence may be any run of the fence character at least as long as
+// the opening one, and a final block may lack its closing fence, which sets
+// Block.Unclosed. Text outside file blocks, such as the sections before the
+// files, is ignored. ParseChecked also reports what is wrong with a bundle.
+func (s Style) Parse(r io.Reader) ([]Block, error) {
+	blocks, _, err := s.parse(r, nil)
+	return blocks, err
+}
+
+// ParseOptions controls ParseChecked.
+type ParseOptions struct {
+	// Lenient recovers from every problem it can and returns the problems
+	// as warnings, instead of failing at the first.
+	Lenient bool
+	// AllowDuplicates accepts paths that appear in more than one block, as
+	// in journals, whose later records supersede earlier ones.
+	AllowDuplicates bool
+}
+
+// ParseError is a problem found by ParseChecked, located at the line of the
+// bundle it concerns.
+type ParseError struct {
+	Line int    // Line of the bundle, from 1.
+	Path string // Path of the block concerned; "" when there is none.
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Limits on the markup of a block, beyond which ParseChecked no longer takes
+// a line for a path header or waits for the opening fence.
+const (
+	maxHeaderLength     = 16 << 10
+	maxAnnotationLength = 64 << 10
+)
+
+// maxLineLength is the longest line Parse reads.
+const maxLineLength = 64 << 20
+
+// ParseChecked is Parse for bundles that may be malformed, such as ones
+// edited by hand. It finds blocks without a path or code fence, blocks that
+// are never closed or closed by a fence that does not match the opening one,
+// paths that appear twice, and path headers or annotations too long to be
+// real. Without opts.Lenient it fails with a *ParseError for the first of
+// them. With it, it returns every problem as a warning and recovers what it
+// can: a block whose content runs into the next file's header ends before
+// that header, and the header starts the next block; of blocks with the
+// same path, the last is kept; blocks without a path and oversized headers
+// are dropped.
+func (s Style) ParseChecked(r io.Reader, opts ParseOptions) ([]Block, []*ParseError, error) {
+	blocks, problems, err := s.parse(r, &opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	slices.SortStableFunc(problems, func(a, b *ParseError) int { return a.Line - b.Line })
+	if !opts.Lenient && len(problems) > 0 {
+		return nil, nil, problems[0]
+	}
+	return blocks, problems, nil
+}
+
+// parse implements Parse, and ParseChecked when check is not nil.
+func (s Style) parse(r io.Reader, check *ParseOptions) ([]Block, []*ParseError, error) {
+	headerPrefix, headerSuffix, _ := strings.Cut(s.PathHeader, "%s")
+	plain := !s.Fenced && !s.FileTag && s.PathFooter == ""
+	footer := "" // The current block's closing line, for styles with a PathFooter.
+	var blocks []Block
+	var current *Block
+	var lines []string
+	var annotation strings.Builder
+	fence := ""
+	inHeader := false // Between a fenced style's header and its opening fence.
+	contentLine := 0  // Line of lines[0] in the bundle.
+	var headers []int // Indexes of the lines of content that look like path headers.
+	var problems []*ParseError
+	seen := make(map[string]int) // Index in blocks by path.
+	problem := func(line int, path, format string, args ...any) {
+		problems = append(problems, &ParseError{Line: line, Path: path, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	finish := func(closed bool) {
+		if current == nil {
+			return
+		}
+		current.Unclosed = !closed
+		content := strings.Join(lines, "\n")
+		if plain {
+			// Blocks are separated by a blank line after the content's own newline.
+			content = strings.TrimSuffix(content, "\n")
+		}
+		if s.FileTag {
+			content = escapedFileTagRE.ReplaceAllString(content, "<$1/file>")
+		}
+		if s.Fenced && s.MarkNoNewline && fence != "" {
+			if before, found := strings.CutSuffix(current.Annotation, NoNewlineNote); found {
+				current.Annotation = before
+			} else {
+				content += "\n"
+			}
+		}
+		current.Content = []byte(content)
+		current.Removed = strings.TrimRight(content, "\r\n") == RemovedMarker
+		switch i, dup := seen[current.Path]; {
+		case check == nil:
+			blocks = append(blocks, *current)
+		case current.Path == "":
+			problem(current.Line, "", "path header without a path")
+		case dup && !check.AllowDuplicates:
+			problem(current.Line, current.Path, "duplicate path %s, first at line %d", current.Path, blocks[i].Line)
+			blocks = slices.Delete(blocks, i, i+1)
+			for p, j := range seen {
+				if j > i {
+					seen[p] = j - 1
+				}
+			}
+			fallthrough
+		default:
+			seen[current.Path] = len(blocks)
+			blocks = append(blocks, *current)
+		}
+		current, lines, fence, inHeader, headers = nil, nil, "", false, nil
+		annotation.Reset()
+	}
+
+	// Lines to read again after a recovery, from line pendingLine.
+	var pending []string
+	pendingLine := 0
+	// split ends the current block before lines[k], the header of another
+	// file, and queues that header and the lines after it to be read again.
+	split := func(k int, after ...string) {
+		end := k
+		for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+			end--
+		}
+		restLine := contentLine + k
+		pending = append(append(slices.Clip(lines[k:]), after...), pending...)
+		pendingLine = restLine
+		if closer := strings.TrimSpace(lines[max(end-1, 0)]); s.Fenced && end > 0 && isBareFence(closer) {
+			problem(contentLine+end-1, current.Path, "fence %s does not close %s, opened with %s at line %d", closer, current.Path, fence, contentLine-1)
+			lines = lines[:end-1]
+			finish(true)
+		} else {
+			problem(current.Line, current.Path, "%s is never closed and runs into the path header at line %d", current.Path, restLine)
+			lines = lines[:end]
+			finish(false)
+		}
+	}
+	// swallowed returns the index of a path header in the content of a
+	// fenced block about to be closed that most likely opened a block of its
+	// own, whose closing fence closes this one, or -1. In a block WriteFile
+	// wrote, no content line starts with a fence as long as the block's.
+	swallowed := func() int {
+		if !s.Fenced {
+			return -1
+		}
+		opensBlock := func(l string) bool { return fenceRun(l, s.FenceChar) >= len(fence) }
+		for i, k := range headers {
+			end := len(lines)
+			if i+1 < len(headers) {
+				end = headers[i+1]
+			}
+			if slices.ContainsFunc(lines[k+1:end], opensBlock) {
+				return k
+			}
+		}
+		return -1
+	}
+	closeBlock := func(raw string) {
+		if k := swallowed(); check != nil && k >= 0 {
+			split(k, raw)
+			return
+		}
+		finish(true)
+	}
+
+	feed := func(raw string, lineNo int) {
+		line := strings.TrimSuffix(raw, "\r") // For markup only; content keeps its CRLFs.
+		inContent := current != nil && !inHeader
+		if name, ok := strings.CutPrefix(line, headerPrefix); ok && strings.HasSuffix(name, headerSuffix) {
+			switch {
+			case check != nil && len(line) > maxHeaderLength:
+				if !inContent || plain {
+					problem(lineNo, "", "path header of %d bytes, longer than the limit of %d", len(line), maxHeaderLength)
+				}
+			case !inContent || plain:
+				if check != nil && inHeader {
+					problem(current.Line, current.Path, "%s has no code fence before the next path header at line %d", current.Path, lineNo)
+				}
+				finish(plain)
+				name = strings.TrimSuffix(name, headerSuffix)
+				if unescaped, err := url.PathUnescape(name); err == nil {
+					name = unescaped
+				}
+				current = &Block{Path: strings.TrimPrefix(name, "/"), Line: lineNo}
+				if s.PathFooter != "" {
+					footer = fmt.Sprintf(s.PathFooter, strings.TrimSuffix(strings.TrimPrefix(line, headerPrefix), headerSuffix))
+				}
+				inHeader = s.Fenced
+				contentLine = lineNo + 1
+				return
+			case check != nil:
+				headers = append(headers, len(lines))
+			}
+		}
+		switch {
+		case current == nil:
+		case inHeader:
+			if n := fenceRun(line, s.FenceChar); n >= 3 {
+				fence = strings.Repeat(s.FenceChar, n)
+				current.Annotation = annotation.String()
+				current.Lang = strings.TrimSpace(strings.TrimLeft(line, " "+s.FenceChar))
+				inHeader = false
+				contentLine = lineNo + 1
+			} else if strings.TrimSpace(line) != "" || annotation.Len() > 0 {
+				annotation.WriteString(line + "\n")
+				if check != nil && annotation.Len() > maxAnnotationLength {
+					problem(current.Line, current.Path, "%s has no code fence within %d bytes of its path header", current.Path, maxAnnotationLength)
+					finish(false)
+				}
+			}
+		case s.Fenced && isClosingFence(line, fence):
+			closeBlock(raw)
+		case s.FileTag && line == "</file>":
+			finish(true)
+		case footer != "" && line == footer:
+			finish(true)
+		default:
+			lines = append(lines, raw)
+		}
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	sc.Split(scanRawLines)
+	lineNo := 0
+	for {
+		switch {
+		case len(pending) > 0:
+			raw := pending[0]
+			pending = pending[1:]
+			pendingLine++
+			feed(raw, pendingLine-1)
+			continue
+		case sc.Scan():
+			lineNo++
+			feed(sc.Text(), lineNo)
+			continue
+		}
+		if err := sc.Err(); err == bufio.ErrTooLong {
+			return nil, nil, &ParseError{Line: lineNo + 1, Msg: fmt.Sprintf("line longer than %d MiB", maxLineLength>>20)}
+		} else if err != nil {
+			return nil, nil, err
+		}
+		// A block that is never closed but holds the header of another
+		// file most likely lost its closing line.
+		if current == nil || inHeader || len(headers) == 0 {
+			break
+		}
+		split(headers[0])
+	}
+	if check != nil && current != nil && !plain {
+		if inHeader {
+			problem(current.Line, current.Path, "%s has no code fence before the end of the bundle", current.Path)
+		} else {
+			problem(current.Line, current.Path, "%s is never closed before the end of the bundle", current.Path)
+		}
+	}
+	finish(plain)
+	return blocks, problems, nil
+}
+
+// scanRawLines is bufio.ScanLines without dropping a carriage return before
+// the newline, which belongs to the content of a CRLF file.
+func scanRawLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// fenceRun returns how many fence characters a line starts with, after up
+// to three spaces of indentation as CommonMark allows.
+func fenceRun(line, char string) int {
+	trimmed := strings.TrimLeft(line, " ")
+	if char == "" || len(line)-len(trimmed) > 3 {
+		return 0
+	}
+	return len(trimmed) - len(strings.TrimLeft(trimmed, char))
+}
+
+// isClosingFence reports whether a line closes a block opened with fence: it
+// holds only fence characters, at least as many as the opening fence.
+func isClosingFence(line, fence string) bool {
+	n := fenceRun(line, fence[:1])
+	return n >= len(fence) && strings.TrimSpace(line) == strings.Repeat(fence[:1], n)
+}
+
+// isBareFence reports whether a line is only a run of three or more
+// backticks or tildes, such as a closing fence of either style.
+func isBareFence(line string) bool {
+	return len(line) >= 3 && (strings.Trim(line, "`") == "" || strings.Trim(line, "~") == "")
+}
@@ -0,0 +1,238 @@
+// project-bundler/pkg/bundler/parse_test.go
+package bundler
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseChecked(t *testing.T) {
+	github := Styles["github"]
+	tests := []struct {
+		name     string
+		style    Style
+		bundle   string
+		opts     ParseOptions
+		problems []string // Of the lenient parse, as "line: message".
+		paths    []string // Blocks of the lenient parse, as "path=content".
+	}{
+		{
+			name:   "well formed",
+			style:  github,
+			bundle: "File: /a.go\n```go\na\n```\n\nFile: /b.go\n```go\nb\n```\n",
+			paths:  []string{"a.go=a", "b.go=b"},
+		},
+		{
+			name:     "short closing fence",
+			style:    github,
+			bundle:   "File: /a.md\n````md\n```sh\nls\n```\n```\n\nFile: /b.go\n```go\nb\n```\n",
+			problems: []string{"6: fence ``` does not close a.md, opened with ```` at line 2"},
+			paths:    []string{"a.md=```sh\nls\n```", "b.go=b"},
+		},
+		{
+			name:     "tilde closing fence",
+			style:    github,
+			bundle:   "File: /a.go\n```go\na\n~~~\n\nFile: /b.go\n```go\nb\n```\n",
+			problems: []string{"4: fence ~~~ does not close a.go, opened with ``` at line 2"},
+			paths:    []string{"a.go=a", "b.go=b"},
+		},
+		{
+			name:     "missing closing fence",
+			style:    github,
+			bundle:   "File: /a.go\n```go\na\n\nFile: /b.go\n```go\nb\n```\n",
+			problems: []string{"1: a.go is never closed and runs into the path header at line 5"},
+			paths:    []string{"a.go=a", "b.go=b"},
+		},
+		{
+			name:     "unclosed at the end",
+			style:    github,
+			bundle:   "File: /a.go\n```go\na\n",
+			problems: []string{"1: a.go is never closed before the end of the bundle"},
+			paths:    []string{"a.go=a"},
+		},
+		{
+			name:     "missing opening fence",
+			style:    github,
+			bundle:   "File: /a.go\na\n\nFile: /b.go\n```go\nb\n```\n\nFile: /c.go\n",
+			problems: []string{"1: a.go has no code fence before the next path header at line 4", "9: c.go has no code fence before the end of the bundle"},
+			paths:    []string{"a.go=", "b.go=b", "c.go="},
+		},
+		{
+			name:     "duplicate path",
+			style:    github,
+			bundle:   "File: /a.go\n```go\nold\n```\n\nFile: /b.go\n```go\nb\n```\n\nFile: /a.go\n```go\nnew\n```\n",
+			problems: []string{"11: duplicate path a.go, first at line 1"},
+			paths:    []string{"b.go=b", "a.go=new"},
+		},
+		{
+			name:   "duplicate path allowed",
+			style:  github,
+			bundle: "File: /a.go\n```go\nold\n```\n\nFile: /a.go\n```go\nnew\n```\n",
+			opts:   ParseOptions{AllowDuplicates: true},
+			paths:  []string{"a.go=old", "a.go=new"},
+		},
+		{
+			name:     "no path",
+			style:    github,
+			bundle:   "File: /\n```\nx\n```\n",
+			problems: []string{"1: path header without a path"},
+		},
+		{
+			name:     "enormous header",
+			style:    github,
+			bundle:   "File: /" + strings.Repeat("a", maxHeaderLength) + "\n```\nx\n```\n\nFile: /b.go\n```go\nb\n```\n",
+			problems: []string{"1: path header of 16391 bytes, longer than the limit of 16384"},
+			paths:    []string{"b.go=b"},
+		},
+		{
+			name:     "enormous annotation",
+			style:    github,
+			bundle:   "File: /a.go\n" + strings.Repeat("note\n", maxAnnotationLength/5+1) + "```go\na\n```\n",
+			problems: []string{"1: a.go has no code fence within 65536 bytes of its path header"},
+			paths:    []string{"a.go="},
+		},
+		{
+			name:     "missing footer",
+			style:    Styles["begin-end"],
+			bundle:   "===== BEGIN FILE /a.go =====\na\n\n===== BEGIN FILE /b.go =====\nb\n===== END FILE /b.go =====\n",
+			problems: []string{"1: a.go is never closed and runs into the path header at line 4"},
+			paths:    []string{"a.go=a", "b.go=b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.Lenient = true
+			blocks, warnings, err := tt.style.ParseChecked(strings.NewReader(tt.bundle), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var problems, paths []string
+			for _, w := range warnings {
+				problems = append(problems, strings.TrimPrefix(w.Error(), "line "))
+			}
+			for _, b := range blocks {
+				paths = append(paths, b.Path+"="+string(b.Content))
+			}
+			if !reflect.DeepEqual(problems, tt.problems) {
+				t.Errorf("warnings = %q, want %q", problems, tt.problems)
+			}
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("blocks = %q, want %q", paths, tt.paths)
+			}
+
+			opts.Lenient = false
+			_, _, err = tt.style.ParseChecked(strings.NewReader(tt.bundle), opts)
+			var perr *ParseError
+			switch {
+			case len(tt.problems) == 0 && err != nil:
+				t.Errorf("strict parse failed: %v", err)
+			case len(tt.problems) > 0 && !errors.As(err, &perr):
+				t.Errorf("strict parse returned %v, want a *ParseError", err)
+			case len(tt.problems) > 0 && strings.TrimPrefix(perr.Error(), "line ") != tt.problems[0]:
+				t.Errorf("strict parse failed with %q, want %q", perr, tt.problems[0])
+			}
+		})
+	}
+}
+
+func TestParseRemoved(t *testing.T) {
+	for _, name := range []string{"github", "plain", "claude"} {
+		style := Styles[name]
+		var b bytes.Buffer
+		style.WriteFile(&b, "a.go", "go", "", []byte("package a\n"))
+		style.WriteRemoved(&b, "b.go")
+		blocks, err := style.Parse(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(blocks) != 2 || blocks[0].Removed || !blocks[1].Removed || blocks[1].Path != "b.go" {
+			t.Errorf("%s: blocks = %+v, want a.go and a tombstone for b.go", name, blocks)
+		}
+	}
+}
+
+func TestParseLongLine(t *testing.T) {
+	bundle := "File: /a.go\n```go\n" + strings.Repeat("x", maxLineLength+1) + "\n```\n"
+	_, err := Styles["github"].Parse(strings.NewReader(bundle))
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Line != 3 {
+		t.Errorf("Parse = %v, want a *ParseError at line 3", err)
+	}
+}
+
+// fuzzStyles are the styles FuzzParse checks, with whether WriteFile output
+// in the style parses back to the exact content of any file.
+var fuzzStyles = []struct {
+	style     Style
+	roundTrip bool
+}{
+	{Styles["github"], true},
+	{Style{PathHeader: "File: %s", Fenced: true, FenceChar: "`", MarkNoNewline: true}, true},
+	{Styles["obsidian"], true},
+	{Styles["chatgpt"], true},
+	{Styles["claude"], true},
+	// A content line equal to the header or footer ends the block.
+	{Styles["plain"], false},
+	{Styles["begin-end"], false},
+}
+
+func FuzzParse(f *testing.F) {
+	for _, s := range fuzzStyles {
+		var b bytes.Buffer
+		s.style.WriteFile(&b, "a.go", "go", "", []byte("package a\n"))
+		s.style.WriteFile(&b, "docs/b.md", "markdown", "(annotated)\n", []byte("```sh\nls\n```"))
+		f.Add(b.Bytes())
+		f.Add(b.Bytes()[:b.Len()/2])
+	}
+	f.Add([]byte("File: /a.go\n````go\n```\n```\n\nFile: /a.go\n~~~\n"))
+	f.Add([]byte("<file path=\"/a\">\n<\\/file>\r\n<file path=\"%zz\">\n</file>"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		lines := bytes.Count(data, []byte("\n")) + 1
+		for _, s := range fuzzStyles {
+			parsed, err := s.style.Parse(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			blocks, warnings, err := s.style.ParseChecked(bytes.NewReader(data), ParseOptions{Lenient: true})
+			if err != nil {
+				t.Fatalf("ParseChecked: %v", err)
+			}
+			for _, b := range blocks {
+				if b.Line < 1 || b.Line > lines {
+					t.Errorf("%s: block %q at line %d of %d", s.style.PathHeader, b.Path, b.Line, lines)
+				}
+			}
+			for _, w := range warnings {
+				if w.Line < 1 || w.Line > lines {
+					t.Errorf("%s: warning %q at line %d of %d", s.style.PathHeader, w, w.Line, lines)
+				}
+			}
+			if len(warnings) == 0 && !reflect.DeepEqual(blocks, parsed) {
+				t.Errorf("%s: ParseChecked found no problems but read other blocks than Parse", s.style.PathHeader)
+			}
+			_, _, err = s.style.ParseChecked(bytes.NewReader(data), ParseOptions{})
+			if (err != nil) != (len(warnings) > 0) {
+				t.Errorf("%s: strict parse returned %v with %d lenient warnings", s.style.PathHeader, err, len(warnings))
+			}
+
+			if !s.roundTrip {
+				continue
+			}
+			var b bytes.Buffer
+			s.style.WriteFile(&b, "dir/file.txt", "text", "", data)
+			blocks, warnings, err = s.style.ParseChecked(&b, ParseOptions{})
+			switch {
+			case err != nil:
+				t.Errorf("%s: parsing a written file: %v", s.style.PathHeader, err)
+			case len(blocks) != 1 || len(warnings) != 0:
+				t.Errorf("%s: a written file parses into %d blocks", s.style.PathHeader, len(blocks))
+			case blocks[0].Path != "dir/file.txt" || !bytes.Equal(blocks[0].Content, data):
+				t.Errorf("%s: a written file parses back as %q: %q", s.style.PathHeader, blocks[0].Path, blocks[0].Content)
+			}
+		}
+	})
+}
@@ -0,0 +1,20 @@
+// project-bundler/pkg/bundler/placeholder_darwin.go
+package bundler
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// sfDataless is set by the File Provider framework (iCloud Drive, Dropbox,
+// OneDrive) on files whose content has been evicted to the cloud.
+const sfDataless = 0x40000000
+
+// isCloudPlaceholder reports whether the file's content lives only in the cloud.
+func isCloudPlaceholder(info fs.FileInfo) bool {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+	return st.Flags&sfDataless != 0
+}
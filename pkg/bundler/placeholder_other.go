@@ -0,0 +1,12 @@
+//go:build !windows && !darwin
+
+// project-bundler/pkg/bundler/placeholder_other.go
+package bundler
+
+import "io/fs"
+
+// isCloudPlaceholder always reports false: there is no standard placeholder
+// marker for cloud-synced files on this platform.
+func isCloudPlaceholder(info fs.FileInfo) bool {
+	return false
+}
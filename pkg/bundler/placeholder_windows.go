@@ -0,0 +1,24 @@
+// project-bundler/pkg/bundler/placeholder_windows.go
+package bundler
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// File attributes set by cloud sync providers (OneDrive Files On-Demand,
+// Dropbox online-only files) on placeholders whose content is not local.
+const (
+	fileAttributeOffline            = 0x00001000
+	fileAttributeRecallOnOpen       = 0x00040000
+	fileAttributeRecallOnDataAccess = 0x00400000
+)
+
+// isCloudPlaceholder reports whether the file's content lives only in the cloud.
+func isCloudPlaceholder(info fs.FileInfo) bool {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return false
+	}
+	return data.FileAttributes&(fileAttributeOffline|fileAttributeRecallOnOpen|fileAttributeRecallOnDataAccess) != 0
+}
@@ -0,0 +1,377 @@
+// project-bundler/pkg/bundler/presets.go
+package bundler
+
+import (
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ProjectConfig defines the bundling rules for a specific project type.
+type ProjectConfig struct {
+	IgnoreDirs     []string
+	IgnoreExts     []string
+	IgnoreSuffixes []string
+	IgnorePaths    []string // Globs relative to the source directory; "**" spans directories.
+	LangMap        map[string]string
+	Output         string // Default for -output; may use {module}, {name} and {type}. Empty means "bundle.md".
+}
+
+// BaseLangMap contains common language mappings for extensions.
+var BaseLangMap = map[string]string{
+	".md":        "markdown",
+	".sh":        "shell",
+	".json":      "json",
+	".yml":       "yaml",
+	".yaml":      "yaml",
+	".toml":      "toml",
+	".txt":       "text",
+	".gitignore": "text",
+	".proto":     "protobuf",
+}
+
+// FilenameLangMap contains mappings for well-known filenames that lack
+// extensions, or whose extension does not tell their language, such as
+// CMakeLists.txt. They take precedence over the extension.
+var FilenameLangMap = map[string]string{
+	"Dockerfile":     "dockerfile",
+	"Containerfile":  "dockerfile",
+	"Makefile":       "makefile",
+	"makefile":       "makefile",
+	"GNUmakefile":    "makefile",
+	"Kbuild":         "makefile",
+	"CMakeLists.txt": "cmake",
+	"meson.build":    "meson",
+	"Justfile":       "just",
+	"justfile":       "just",
+	"Jenkinsfile":    "groovy",
+	"Vagrantfile":    "ruby",
+	"Gemfile":        "ruby",
+	"Rakefile":       "ruby",
+	"Podfile":        "ruby",
+	"Fastfile":       "ruby",
+	"Appfile":        "ruby",
+	"Brewfile":       "ruby",
+	"Dangerfile":     "ruby",
+	"Guardfile":      "ruby",
+	"Berksfile":      "ruby",
+	"Capfile":        "ruby",
+	"Pipfile":        "toml",
+	"Pipfile.lock":   "json",
+	"Cargo.lock":     "toml",
+	"poetry.lock":    "toml",
+	"BUILD":          "starlark",
+	"BUILD.bazel":    "starlark",
+	"WORKSPACE":      "starlark",
+	"MODULE.bazel":   "starlark",
+	"Tiltfile":       "starlark",
+	"SConstruct":     "python",
+	"SConscript":     "python",
+	"Snakefile":      "python",
+	"Caddyfile":      "caddyfile",
+	"nginx.conf":     "nginx",
+	"Procfile":       "yaml",
+	"go.mod":         "go-mod",
+	"go.work":        "go-mod",
+	"go.sum":         "text",
+	"LICENSE":        "text",
+	"COPYING":        "text",
+	"NOTICE":         "text",
+	"AUTHORS":        "text",
+	"CODEOWNERS":     "text",
+	"README":         "markdown",
+	"CHANGELOG":      "markdown",
+	".bashrc":        "shell",
+	".bash_profile":  "shell",
+	".bash_aliases":  "shell",
+	".profile":       "shell",
+	".zshrc":         "shell",
+	".zshenv":        "shell",
+	".zprofile":      "shell",
+	".envrc":         "shell",
+	".env":           "shell",
+	".vimrc":         "vim",
+	".gitconfig":     "ini",
+	".gitmodules":    "ini",
+	".editorconfig":  "ini",
+	".npmrc":         "ini",
+	".pylintrc":      "ini",
+	".flake8":        "ini",
+	".babelrc":       "json",
+	".eslintrc":      "json",
+	".prettierrc":    "json",
+	".jshintrc":      "json",
+	".swcrc":         "json",
+	".nvmrc":         "text",
+	".gitignore":     "gitignore",
+	".dockerignore":  "gitignore",
+	".npmignore":     "gitignore",
+	".gitattributes": "gitattributes",
+}
+
+// CommonIgnoreDirs are junk directories ignored under every preset (and in
+// addition to -ignore-dirs), unless -no-default-ignores is given.
+var CommonIgnoreDirs = []string{
+	".git", "node_modules", ".venv", "__pycache__", "dist", "coverage",
+	".terraform", ".idea", ".vscode", ".cache",
+}
+
+// Presets holds the built-in rules for different project types.
+var Presets = map[string]ProjectConfig{
+	"generic": {
+		IgnoreDirs: []string{".git"},
+		IgnoreExts: []string{".DS_Store", ".log", ".lock"},
+	},
+	"android": {
+		IgnoreDirs: []string{".git", ".idea", "build", ".gradle", "gradle"},
+		IgnoreExts: []string{".DS_Store", ".iml", ".jar", ".keystore", ".jks", ".apk", ".aab", ".so", ".png", ".jpg", ".jpeg", ".gif", ".webp"},
+		LangMap: map[string]string{
+			".java":   "java",
+			".kt":     "kotlin",
+			".kts":    "kotlin",
+			".xml":    "xml",
+			".gradle": "groovy",
+			".pro":    "text",
+		},
+	},
+	"flutter": {
+		IgnoreDirs:     []string{".git", ".idea", ".dart_tool", ".metadata", "build", "android", "ios", "linux", "windows", "macos", "web"},
+		IgnoreExts:     []string{".DS_Store", ".flutter-plugins-dependencies", ".iml", ".metadata", ".lock", ".png", ".jpg", ".jpeg", ".gif", ".webp", ".ttf", ".otf", ".ico", ".apk", ".aab"},
+		IgnoreSuffixes: []string{".g.dart", ".freezed.dart", ".gr.dart"}, // Ignores generated code
+		// flutter_intl and gen-l10n output.
+		IgnorePaths: []string{"lib/generated/**", "lib/l10n/generated/**"},
+		LangMap: map[string]string{
+			".dart": "dart",
+			".yaml": "yaml",
+			".arb":  "json",
+		},
+	},
+	"go": {
+		IgnoreDirs: []string{".git", "vendor", "build"},
+		IgnoreExts: []string{".DS_Store", ".exe", ".so", ".a"},
+		LangMap: map[string]string{
+			".go": "go",
+		},
+	},
+	"rust": {
+		IgnoreDirs: []string{".git", "target"},
+		IgnoreExts: []string{".DS_Store", ".rlib", ".so", ".a", ".exe"},
+		LangMap: map[string]string{
+			".rs": "rust",
+		},
+	},
+	"ios": {
+		IgnoreDirs: []string{".git", ".idea", "Pods", "build", "DerivedData", ".swiftpm", "Carthage"},
+		IgnoreExts: []string{".DS_Store", ".mobileprovision", ".app", ".ipa", ".car", ".xcassets", ".storyboardc", ".nib", ".png", ".jpg", ".jpeg"},
+		LangMap: map[string]string{
+			".swift":      "swift",
+			".m":          "objectivec",
+			".h":          "objectivec",
+			".storyboard": "xml",
+			".xib":        "xml",
+			".plist":      "xml",
+		},
+	},
+	"node": {
+		IgnoreDirs:     []string{".git", "node_modules", "dist", "build", "out", ".next", ".nuxt", ".svelte-kit", ".turbo", ".parcel-cache", "coverage"},
+		IgnoreExts:     []string{".DS_Store", ".log", ".lock", ".map", ".tsbuildinfo"},
+		IgnoreSuffixes: []string{".min.js", ".min.css"},
+		IgnorePaths:    []string{"package-lock.json", "pnpm-lock.yaml"},
+		LangMap: map[string]string{
+			".js":     "javascript",
+			".mjs":    "javascript",
+			".cjs":    "javascript",
+			".jsx":    "javascript",
+			".ts":     "typescript",
+			".mts":    "typescript",
+			".cts":    "typescript",
+			".tsx":    "typescript",
+			".vue":    "vue",
+			".svelte": "svelte",
+			".html":   "html",
+			".css":    "css",
+			".scss":   "scss",
+		},
+	},
+	"python": {
+		IgnoreDirs: []string{".git", "venv", ".venv", "env", "__pycache__", ".pytest_cache", ".mypy_cache", ".ruff_cache", ".tox", ".nox", "build", "dist", "htmlcov"},
+		IgnoreExts: []string{".DS_Store", ".pyc", ".pyo", ".pyd", ".so", ".egg", ".whl", ".log"},
+		// setuptools metadata, regenerated by every build.
+		IgnorePaths: []string{"**/*.egg-info/**"},
+		LangMap: map[string]string{
+			".py":  "python",
+			".pyi": "python",
+			".pyx": "cython",
+			".cfg": "ini",
+			".ini": "ini",
+			".rst": "rst",
+		},
+	},
+	"java": {
+		IgnoreDirs: []string{".git", ".idea", "target", "build", "out", ".gradle"},
+		IgnoreExts: []string{".DS_Store", ".iml", ".class", ".jar", ".war", ".ear", ".log"},
+		LangMap: map[string]string{
+			".java":       "java",
+			".kt":         "kotlin",
+			".kts":        "kotlin",
+			".groovy":     "groovy",
+			".gradle":     "groovy",
+			".xml":        "xml",
+			".properties": "properties",
+		},
+	},
+	"dotnet": {
+		IgnoreDirs: []string{".git", ".vs", ".idea", "bin", "obj", "packages", "TestResults"},
+		IgnoreExts: []string{".DS_Store", ".dll", ".exe", ".pdb", ".nupkg", ".snupkg", ".suo", ".user", ".cache"},
+		LangMap: map[string]string{
+			".cs":      "csharp",
+			".csx":     "csharp",
+			".fs":      "fsharp",
+			".fsx":     "fsharp",
+			".vb":      "vbnet",
+			".razor":   "razor",
+			".cshtml":  "razor",
+			".xaml":    "xml",
+			".csproj":  "xml",
+			".fsproj":  "xml",
+			".vbproj":  "xml",
+			".props":   "xml",
+			".targets": "xml",
+			".config":  "xml",
+			".resx":    "xml",
+		},
+	},
+	"web": {
+		IgnoreDirs:     []string{".git", "node_modules", "dist", "build", ".cache"},
+		IgnoreExts:     []string{".DS_Store", ".map", ".png", ".jpg", ".jpeg", ".gif", ".webp", ".avif", ".ico", ".woff", ".woff2", ".ttf", ".otf", ".eot", ".mp4", ".webm"},
+		IgnoreSuffixes: []string{".min.js", ".min.css"},
+		LangMap: map[string]string{
+			".html": "html",
+			".htm":  "html",
+			".css":  "css",
+			".scss": "scss",
+			".sass": "sass",
+			".less": "less",
+			".js":   "javascript",
+			".mjs":  "javascript",
+			".svg":  "xml",
+		},
+	},
+}
+
+// ProjectTypes returns the names of all built-in presets in lexical order.
+func ProjectTypes() []string {
+	var types []string
+	for k := range Presets {
+		types = append(types, k)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// landmarks are the root files (path.Match patterns) that identify each
+// project type. Many projects carry several, such as a Flutter app with a
+// package.json for tooling, so the first match wins: the more specific
+// ecosystems come first and a bare index.html last.
+var landmarks = []struct{ pattern, projectType string }{
+	{"go.mod", "go"},
+	{"Cargo.toml", "rust"},
+	{"pubspec.yaml", "flutter"},
+	{"Package.swift", "ios"},
+	{"Podfile", "ios"},
+	{"*.xcodeproj", "ios"},
+	{"build.gradle", "android"},
+	{"pom.xml", "java"},
+	{"*.sln", "dotnet"},
+	{"*.csproj", "dotnet"},
+	{"*.fsproj", "dotnet"},
+	{"pyproject.toml", "python"},
+	{"setup.py", "python"},
+	{"setup.cfg", "python"},
+	{"requirements.txt", "python"},
+	{"Pipfile", "python"},
+	{"package.json", "node"},
+	{"tsconfig.json", "node"},
+	{"index.html", "web"},
+}
+
+// DetectProjectType checks the root of fsys for landmark files to determine
+// the project type. It returns "generic" and false when no landmark is found.
+func DetectProjectType(fsys fs.FS) (string, bool) {
+	for _, l := range landmarks {
+		if matches, _ := fs.Glob(fsys, l.pattern); len(matches) > 0 {
+			return l.projectType, true
+		}
+	}
+	return "generic", false
+}
+
+// Detection is a project type found by DetectProjectTypes and the directory
+// (slash-separated, "." for the root) holding its landmark.
+type Detection struct {
+	Type string
+	Dir  string
+}
+
+// DetectProjectTypes finds every project type in a monorepo: the root's, as
+// DetectProjectType reports it, then those of the directories up to two
+// levels below, such as backend/go.mod and apps/web/package.json. Hidden
+// directories, the common ignore list and the directories the root's preset
+// ignores (a Flutter app's android/ and ios/ platform code) are not
+// searched. Each type is reported once, at the first directory found.
+func DetectProjectTypes(fsys fs.FS) []Detection {
+	var found []Detection
+	seen := make(map[string]bool)
+	add := func(projectType, dir string) {
+		if !seen[projectType] {
+			seen[projectType] = true
+			found = append(found, Detection{Type: projectType, Dir: dir})
+		}
+	}
+	skip := make(map[string]bool)
+	for _, dir := range CommonIgnoreDirs {
+		skip[dir] = true
+	}
+	if rootType, ok := DetectProjectType(fsys); ok {
+		add(rootType, ".")
+		for _, dir := range Presets[rootType].IgnoreDirs {
+			skip[dir] = true
+		}
+	}
+
+	var search func(dir string, depth int)
+	search = func(dir string, depth int) {
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return
+		}
+		for _, e := range entries {
+			if !e.IsDir() || strings.HasPrefix(e.Name(), ".") || skip[e.Name()] {
+				continue
+			}
+			sub := path.Join(dir, e.Name())
+			if subFS, err := fs.Sub(fsys, sub); err == nil {
+				if projectType, ok := DetectProjectType(subFS); ok {
+					add(projectType, sub)
+					continue // A project's own subdirectories belong to it.
+				}
+			}
+			if depth < 2 {
+				search(sub, depth+1)
+			}
+		}
+	}
+	search(".", 1)
+	return found
+}
+
+// DetectLanguage picks the Markdown language identifier for a file name.
+// langMap is keyed by extension, and may also name whole files. See
+// DetectLanguageContent for files whose name does not tell.
+func DetectLanguage(name string, langMap map[string]string) string {
+	if lang, ok := lookupLanguage(name, langMap); ok {
+		return lang
+	}
+	return "text" // Default to plain text.
+}
@@ -0,0 +1,48 @@
+// project-bundler/pkg/bundler/reasons.go
+package bundler
+
+// Skip reason codes key Report.Skipped and the skipped map of Collect, and
+// are passed to Options.OnDecision. Unlike their descriptions they never
+// change between releases, so automation can branch on them.
+const (
+	ReasonIgnoredDir       = "IGNORED_DIR"
+	ReasonIgnoredExt       = "IGNORED_EXT"
+	ReasonIgnoredSuffix    = "IGNORED_SUFFIX"
+	ReasonIgnoredPath      = "IGNORED_PATH"
+	ReasonGitignored       = "GITIGNORED"
+	ReasonBundlerIgnored   = "BUNDLERIGNORED"
+	ReasonPolicy           = "POLICY"
+	ReasonSecret           = "SECRET"
+	ReasonSpecialFile      = "SPECIAL_FILE"
+	ReasonReadError        = "ERROR_READ"
+	ReasonPlaceholder      = "CLOUD_PLACEHOLDER"
+	ReasonBinary           = "BINARY"
+	ReasonDuplicateDir     = "DUPLICATE_DIR"
+	ReasonOtherFilesystem  = "OTHER_FILESYSTEM"
+	ReasonBuildConstraints = "BUILD_CONSTRAINTS"
+	ReasonGenerated        = "GENERATED"
+	ReasonMinified         = "MINIFIED"
+	ReasonIgnoreMarker     = "IGNORE_MARKER"
+)
+
+// ReasonText describes each reason code in English, for reports.
+var ReasonText = map[string]string{
+	ReasonIgnoredDir:       "Ignored Directory",
+	ReasonIgnoredExt:       "Ignored Extension/File",
+	ReasonIgnoredSuffix:    "Ignored Suffix",
+	ReasonIgnoredPath:      "Ignored Path",
+	ReasonGitignored:       "Gitignored",
+	ReasonBundlerIgnored:   "Ignored by .bundlerignore",
+	ReasonPolicy:           "Not Allowlisted",
+	ReasonSecret:           "Secret File",
+	ReasonSpecialFile:      "Special File",
+	ReasonReadError:        "File Read Error",
+	ReasonPlaceholder:      "Cloud Placeholder",
+	ReasonBinary:           "Detected Binary Content",
+	ReasonDuplicateDir:     "Duplicate Directory",
+	ReasonOtherFilesystem:  "Other Filesystem",
+	ReasonBuildConstraints: "Build Constraints",
+	ReasonGenerated:        "Generated Code",
+	ReasonMinified:         "Minified Code",
+	ReasonIgnoreMarker:     "bundler:ignore Marker",
+}
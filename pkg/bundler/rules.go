@@ -0,0 +1,85 @@
+// project-bundler/pkg/bundler/rules.go
+package bundler
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RuleSet maps each ignore rule to the configuration layer that contributed
+// it (a preset, the common ignore list, a command-line flag), so every skip
+// can be traced back to where the rule came from.
+type RuleSet map[string]string
+
+// Add records rules from a layer. Rules already present keep their original
+// provenance, so layers should be added from most to least specific.
+func (r RuleSet) Add(rules []string, source string) {
+	for _, rule := range rules {
+		if _, ok := r[rule]; !ok {
+			r[rule] = source
+		}
+	}
+}
+
+func (r RuleSet) Contains(rule string) bool {
+	_, ok := r[rule]
+	return ok
+}
+
+// Sorted returns the rules in lexical order.
+func (r RuleSet) Sorted() []string {
+	rules := make([]string, 0, len(r))
+	for rule := range r {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	return rules
+}
+
+// Describe renders a matched rule with its provenance, e.g.
+// `directory "node_modules" from common ignore list`.
+func (r RuleSet) Describe(kind, rule string) string {
+	return fmt.Sprintf("%s %q from %s", kind, rule, r[rule])
+}
+
+// MatchingGlob returns the first pattern in rules that matches the path,
+// which is given relative to the source directory in OS form.
+func (r RuleSet) MatchingGlob(relPath string) (string, bool) {
+	name := filepath.ToSlash(relPath)
+	for _, pattern := range r.Sorted() {
+		if matchGlob(pattern, name) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// globCache holds the expressions matchGlob compiled, keyed by pattern.
+var globCache sync.Map
+
+// matchGlob reports whether a slash-separated path relative to the source
+// directory matches pattern. Patterns are anchored at the source directory
+// and share the gitignore pattern engine: "*", "?" and classes match within
+// a segment and "**" spans any number of segments (including none), so
+// "docs/generated/**" matches the directory itself and everything below it,
+// and "**/*.pb.go" matches at any depth.
+func matchGlob(pattern, name string) bool {
+	re, ok := globCache.Load(pattern)
+	if !ok {
+		expr := gitIgnoreRegexp("/" + pattern)
+		if strings.HasSuffix(pattern, "/**") {
+			expr = strings.TrimSuffix(expr, "/.*$") + "(?:/.*)?$"
+		}
+		compiled, err := regexp.Compile(expr)
+		if err != nil {
+			compiled = nil // A malformed pattern matches nothing.
+		}
+		re, _ = globCache.LoadOrStore(pattern, compiled)
+	}
+	compiled := re.(*regexp.Regexp)
+	return compiled != nil && compiled.MatchString(name)
+}
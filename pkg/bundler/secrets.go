@@ -0,0 +1,188 @@
+// project-bundler/pkg/bundler/secrets.go
+package bundler
+
+import (
+	"bytes"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// secretPatterns are file names (path.Match patterns) that hold credentials
+// and are never bundled, whatever the preset, ignore lists or allowlist say.
+var secretPatterns = []string{
+	".env", ".env.*",
+	"*.pem", "*.key", "*.p12", "*.pfx", "*.jks", "*.keystore",
+	"id_rsa*", "id_dsa*", "id_ecdsa*", "id_ed25519*",
+	"credentials.json", "client_secret*.json", "service-account*.json",
+	".netrc", ".git-credentials", ".pypirc", ".htpasswd",
+}
+
+// secretExemptions are matches of secretPatterns that are safe to share:
+// env templates and public keys.
+var secretExemptions = []string{".env.example", ".env.sample", ".env.template", ".env.dist", "*.pub"}
+
+// tokenConfigs are package manager configs that are only secret when they
+// contain an auth token, which is checked by content.
+var tokenConfigs = map[string][]string{
+	".npmrc":      {"_authToken", "_auth=", "_password"},
+	".yarnrc.yml": {"npmAuthToken"},
+}
+
+// SecretRule reports why a file is on the built-in hard-block list, or ""
+// when it is not. read is only called for configs whose content decides.
+func SecretRule(name string, read func() []byte) string {
+	base := path.Base(strings.ReplaceAll(name, "\\", "/"))
+	for _, p := range secretExemptions {
+		if ok, _ := path.Match(p, base); ok {
+			return ""
+		}
+	}
+	for _, p := range secretPatterns {
+		if ok, _ := path.Match(p, base); ok {
+			return "secret file pattern " + p
+		}
+	}
+	if markers, ok := tokenConfigs[base]; ok {
+		content := read()
+		for _, m := range markers {
+			if bytes.Contains(content, []byte(m)) {
+				return base + " containing " + strings.TrimSuffix(m, "=")
+			}
+		}
+	}
+	return ""
+}
+
+// SecretFinding is a likely credential in a file's content.
+type SecretFinding struct {
+	Rule       string // What was found, e.g. "AWS access key ID".
+	Line       int    // Line of the start of the secret, from 1.
+	Start, End int    // Byte offsets of the secret in the content.
+}
+
+// contentSecretRules match credentials by their shape. Where a rule has a
+// capture group, only the group is the secret; the rest is context.
+var contentSecretRules = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"private key block", regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----.*?-----END [A-Z0-9 ]*PRIVATE KEY(?: BLOCK)?-----`)},
+	{"AWS access key ID", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"AWS secret access key", regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"GitLab token", regexp.MustCompile(`\bglpat-[A-Za-z0-9_-]{20,}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[A-Za-z0-9]{20,}\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"API key", regexp.MustCompile(`\bsk-(?:proj-|ant-)?[A-Za-z0-9_-]{32,}\b`)},
+	{"token assignment", regexp.MustCompile(`(?i)(?:api_?key|secret|token|passw(?:or)?d|credentials?)[a-z_]*["']?\s*[:=]\s*["']([A-Za-z0-9+/=_-]{12,})["']`)},
+	{"high-entropy string", regexp.MustCompile(`["'\x60]([A-Za-z0-9+/=_-]{32,})["'\x60]`)},
+}
+
+// SecretRuleNames returns the names of the content rules, as findings and
+// the markers of RedactSecrets give them.
+func SecretRuleNames() []string {
+	names := make([]string, len(contentSecretRules))
+	for i, rule := range contentSecretRules {
+		names[i] = rule.name
+	}
+	return names
+}
+
+// lockFiles hold checksums that look like random strings, so the high-entropy
+// rule does not apply to them.
+var lockFiles = []string{"go.sum", "*.lock", "*-lock.json", "*-lock.yaml", "*.lockb", "npm-shrinkwrap.json"}
+
+// IsLockFile reports whether name is a dependency lock file such as go.sum
+// or package-lock.json.
+func IsLockFile(name string) bool {
+	base := path.Base(strings.ReplaceAll(name, "\\", "/"))
+	for _, p := range lockFiles {
+		if ok, _ := path.Match(p, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ScanSecrets finds likely credentials in the content of the file name:
+// private key blocks, well-known token formats, values assigned to
+// secret-sounding names and long random-looking strings. Overlapping
+// matches are reported once, in content order.
+func ScanSecrets(name string, content []byte) []SecretFinding {
+	lockFile := IsLockFile(name)
+	var findings []SecretFinding
+	for _, rule := range contentSecretRules {
+		entropic := rule.name == "token assignment" || rule.name == "high-entropy string"
+		hexAllowed := rule.name == "token assignment" // Unprompted hex strings are mostly digests.
+		if lockFile && rule.name == "high-entropy string" {
+			continue
+		}
+		for _, m := range rule.re.FindAllSubmatchIndex(content, -1) {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 && rule.re.NumSubexp() == 1 {
+				start, end = m[2], m[3]
+			}
+			if entropic && !randomLooking(content[start:end], hexAllowed) {
+				continue
+			}
+			findings = append(findings, SecretFinding{Rule: rule.name, Start: start, End: end})
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Start < findings[j].Start })
+	var kept []SecretFinding
+	for _, f := range findings {
+		if len(kept) > 0 && f.Start < kept[len(kept)-1].End {
+			continue
+		}
+		f.Line = 1 + bytes.Count(content[:f.Start], []byte("\n"))
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// RedactSecrets replaces each finding, which must come from ScanSecrets on
+// the same content, with a marker naming its rule.
+func RedactSecrets(content []byte, findings []SecretFinding) []byte {
+	var out []byte
+	last := 0
+	for _, f := range findings {
+		out = append(out, content[last:f.Start]...)
+		out = append(out, "[REDACTED "+f.Rule+"]"...)
+		last = f.End
+	}
+	return append(out, content[last:]...)
+}
+
+// randomLooking reports whether s reads like a generated secret rather than
+// a word or identifier: it mixes letters and digits, and its Shannon entropy
+// is close to the most its length allows. Hex-only strings, which are
+// usually digests, count only when hexAllowed.
+func randomLooking(s []byte, hexAllowed bool) bool {
+	var letter, digit, nonHex bool
+	counts := make(map[byte]int)
+	for _, c := range s {
+		counts[c]++
+		switch {
+		case c >= '0' && c <= '9':
+			digit = true
+		case c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F':
+			letter = true
+		default:
+			letter = letter || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+			nonHex = true
+		}
+	}
+	if !digit || !letter || !nonHex && !hexAllowed || bytes.HasPrefix(s, []byte("sha")) {
+		return false
+	}
+	entropy := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(len(s))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy > 0.75*math.Log2(float64(min(len(s), 64)))
+}
@@ -0,0 +1,234 @@
+// project-bundler/pkg/bundler/style.go
+package bundler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Style controls how each bundled file is framed in the output. Different
+// renderers and models handle markup differently, so the framing is selectable.
+type Style struct {
+	PathHeader  string // Printf pattern for the line naming the file; receives the path.
+	Fenced      bool   // Wrap content in a Markdown code fence.
+	FenceChar   string // "`" or "~"; only used when Fenced.
+	FileTag     bool   // Wrap content in <file> tags instead of a fence.
+	PathFooter  string // Printf pattern for a line closing the block, like PathHeader; "" for none.
+	Highlighter string // Highlighter (see Highlighters) whose language identifiers fences use; "" uses the IDs as they are.
+
+	// MarkNoNewline makes fenced blocks close right after the content's
+	// final newline and mark files without one with NoNewlineNote, instead
+	// of putting a newline before every closing fence. Parse must be called
+	// with the same setting.
+	MarkNoNewline bool
+
+	// Template, when set, renders each block instead of the fields above:
+	// its "file" template is executed with a FileBlock (see ParseTemplate).
+	Template *template.Template
+
+	rootLabel   string // What the source root is shown as (-root-label); "" means "/".
+	rewriteFrom string // Leading path components replaced by rewriteTo (-path-prefix).
+	rewriteTo   string
+}
+
+// Styles holds the built-in styles by name.
+var Styles = map[string]Style{
+	// github is the original format: a "File:" line followed by a backtick fence.
+	"github": {PathHeader: "File: %s", Fenced: true, FenceChar: "`", Highlighter: "github"},
+	// obsidian uses a heading per file so files show up in the outline pane;
+	// Obsidian highlights code with Prism.
+	"obsidian": {PathHeader: "## %s", Fenced: true, FenceChar: "`", Highlighter: "prism"},
+	// chatgpt uses a smaller heading with the path as inline code and tilde
+	// fences, which survive content full of backticks better in the chat UI,
+	// which highlights code with highlight.js.
+	"chatgpt": {PathHeader: "#### `%s`", Fenced: true, FenceChar: "~", Highlighter: "highlightjs"},
+	// claude wraps every file in XML-style tags, which Claude models parse reliably.
+	"claude": {PathHeader: "<file path=\"%s\">", FileTag: true},
+	// plain has no markup at all, similar to the output of head(1) on many files.
+	"plain": {PathHeader: "==> %s <=="},
+	// begin-end brackets each file with marker lines naming it and no
+	// Markdown, for parsers and older models that trip over fences.
+	"begin-end": {PathHeader: "===== BEGIN FILE %s =====", PathFooter: "===== END FILE %s ====="},
+}
+
+// StyleNames returns the names of all built-in styles in lexical order.
+func StyleNames() []string {
+	var names []string
+	for name := range Styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NoNewlineNote is the annotation line that marks a file without a final
+// newline in styles with MarkNoNewline, as in a unified diff.
+const NoNewlineNote = "\\ No newline at end of file\n"
+
+// fileTagRE matches a closing file tag in content, escaped with any number
+// of backslashes, which the claude style escapes with one more.
+var fileTagRE = regexp.MustCompile(`<(\\*)/file>`)
+
+// WriteFile writes one file block. annotation, if non-empty, is a complete
+// line placed between the path header and the content.
+func (s Style) WriteFile(w io.Writer, path, lang, annotation string, content []byte) error {
+	return s.WriteIndexedFile(w, 0, path, lang, annotation, content)
+}
+
+// WriteIndexedFile is WriteFile for the index-th block of a bundle, counted
+// from 1, which a Template can show.
+func (s Style) WriteIndexedFile(w io.Writer, index int, path, lang, annotation string, content []byte) error {
+	if s.Template != nil {
+		return s.writeTemplate(w, index, path, lang, annotation, content)
+	}
+	var open, close string
+	switch {
+	case s.Fenced:
+		fence := strings.Repeat(s.FenceChar, FenceLength(content, s.FenceChar[0]))
+		open, close = fence+s.FenceLang(lang)+"\n", "\n"+fence+"\n\n"
+		if s.MarkNoNewline && bytes.HasSuffix(content, []byte("\n")) {
+			close = fence + "\n\n"
+		}
+		annotation = s.BlockAnnotation(annotation, content)
+	case s.FileTag:
+		// A literal closing tag inside the content would end the block early.
+		// Escaped ones get one more backslash, so Parse can tell them apart.
+		content = fileTagRE.ReplaceAll(content, []byte(`<\$1/file>`))
+		close = "\n</file>\n\n"
+	case s.PathFooter != "":
+		close = "\n" + fmt.Sprintf(s.PathFooter, EscapeHeaderPath(s.DisplayPath(path))) + "\n\n"
+	default:
+		close = "\n\n"
+	}
+
+	header := fmt.Sprintf(s.PathHeader, EscapeHeaderPath(s.DisplayPath(path))) + "\n" + annotation + open
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, close)
+	return err
+}
+
+// BlockAnnotation returns the annotation WriteFile writes for a block of
+// content: with NoNewlineNote added when the style marks files without a
+// final newline and content is one.
+func (s Style) BlockAnnotation(annotation string, content []byte) string {
+	if s.Fenced && s.MarkNoNewline && !bytes.HasSuffix(content, []byte("\n")) {
+		return annotation + NoNewlineNote
+	}
+	return annotation
+}
+
+// ContentLine returns the line of a block written by WriteFile, counted from
+// 1 at the path header, on which the file content starts.
+func (s Style) ContentLine(annotation string) int {
+	line := 2 + strings.Count(annotation, "\n")
+	if s.Fenced {
+		line++
+	}
+	return line
+}
+
+// WithPaths returns a copy of the style that shows paths under root and
+// rewrites them with a -path-prefix value: "OLD=NEW" replaces a leading OLD
+// directory with NEW (an empty NEW strips it), and a value without "=" is
+// prepended to every path.
+func (s Style) WithPaths(root, prefix string) (Style, error) {
+	s.rootLabel = root
+	from, to, found := strings.Cut(prefix, "=")
+	if !found {
+		from, to = "", prefix
+	}
+	s.rewriteFrom, s.rewriteTo = strings.Trim(from, "/"), strings.Trim(to, "/")
+	if found && s.rewriteFrom == "" && s.rewriteTo == "" {
+		return s, fmt.Errorf("invalid -path-prefix '%s': use OLD=NEW, OLD= or a prefix", prefix)
+	}
+	return s, nil
+}
+
+// DisplayPath maps a path relative to the source directory to the path shown
+// in headers.
+func (s Style) DisplayPath(relPath string) string {
+	p := filepath.ToSlash(relPath)
+	switch {
+	case s.rewriteFrom == "":
+		p = path.Join(s.rewriteTo, p)
+	case p == s.rewriteFrom:
+		p = s.rewriteTo
+	case strings.HasPrefix(p, s.rewriteFrom+"/"):
+		p = path.Join(s.rewriteTo, strings.TrimPrefix(p, s.rewriteFrom+"/"))
+	}
+	root := s.rootLabel
+	if root == "" {
+		root = "/"
+	}
+	if strings.HasSuffix(root, "/") {
+		return root + p
+	}
+	return root + "/" + p
+}
+
+// EscapeHeaderPath percent-encodes the characters that would break a path
+// header: control characters such as newlines end the header line early,
+// backticks and quotes close the inline code or attribute around the path,
+// '<' and '>' open tags, and a trailing '#' is dropped from headings. '%' is
+// encoded too, so url.PathUnescape recovers the original name exactly.
+func EscapeHeaderPath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c < 0x20 || c == 0x7f || strings.IndexByte("%`\"<>#", c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// FenceLength returns a fence length guaranteed to be longer than any run of
+// fence characters at the start of a content line, so the content can never
+// close the block early. CommonMark requires at least three. A carriage
+// return ends a line as a newline does, as Markdown renderers treat it.
+func FenceLength(content []byte, char byte) int {
+	longest := 0
+	for _, line := range bytes.FieldsFunc(content, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		line = bytes.TrimLeft(line, " ")
+		n := 0
+		for n < len(line) && line[n] == char {
+			n++
+		}
+		if n > longest {
+			longest = n
+		}
+	}
+	if longest < 3 {
+		return 3
+	}
+	return longest + 1
+}
+
+// RemovedMarker is the content of a tombstone, the block that records that a
+// file was removed. Parse sets Block.Removed for such blocks.
+const RemovedMarker = "(removed from the project since the previous record)"
+
+// WriteRemoved writes a tombstone for path.
+func (s Style) WriteRemoved(w io.Writer, path string) error {
+	return s.WriteFile(w, path, "text", "", []byte(RemovedMarker))
+}
+
+// DuplicateStub is the block content written in place of a file whose content
+// already appears in the bundle under another path.
+func (s Style) DuplicateStub(first string) []byte {
+	return []byte(fmt.Sprintf("(same file as %s; its content is bundled there)", s.DisplayPath(first)))
+}
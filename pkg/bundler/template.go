@@ -0,0 +1,61 @@
+// project-bundler/pkg/bundler/template.go
+package bundler
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// FileBlock is what a style's Template renders for each file.
+type FileBlock struct {
+	Path       string // As shown in headers (Style.DisplayPath), not escaped.
+	RelPath    string // Slash-separated, relative to the source directory.
+	Lang       string // The fence identifier (Style.FenceLang) of the language.
+	Annotation string // A complete line, or "".
+	Content    string
+	Size       int    // Of Content, in bytes.
+	Index      int    // 1-based position in the bundle; 0 for a block written on its own.
+	Fence      string // A backtick fence longer than any in Content (FenceLength).
+}
+
+// ParseTemplate reads a text/template file for Style.Template. It must
+// define a "file" template, which is executed with a FileBlock for each
+// file; "header" and "footer" templates are optional and up to the caller.
+// Besides the standard functions, templates can use the ones of the strings
+// package: trimSpace, trimSuffix, replaceAll, toUpper, toLower and repeat,
+// and escapePath (EscapeHeaderPath).
+func ParseTemplate(file string) (*template.Template, error) {
+	t, err := template.New(filepath.Base(file)).Funcs(template.FuncMap{
+		"trimSpace":  strings.TrimSpace,
+		"trimSuffix": strings.TrimSuffix,
+		"replaceAll": strings.ReplaceAll,
+		"toUpper":    strings.ToUpper,
+		"toLower":    strings.ToLower,
+		"repeat":     strings.Repeat,
+		"escapePath": EscapeHeaderPath,
+	}).ParseFiles(file)
+	if err != nil {
+		return nil, err
+	}
+	if t.Lookup("file") == nil {
+		return nil, fmt.Errorf("%s does not define a \"file\" template", file)
+	}
+	return t, nil
+}
+
+// writeTemplate renders one file block with the style's Template.
+func (s Style) writeTemplate(w io.Writer, index int, path, lang, annotation string, content []byte) error {
+	return s.Template.ExecuteTemplate(w, "file", FileBlock{
+		Path:       s.DisplayPath(path),
+		RelPath:    filepath.ToSlash(path),
+		Lang:       s.FenceLang(lang),
+		Annotation: annotation,
+		Content:    string(content),
+		Size:       len(content),
+		Index:      index,
+		Fence:      strings.Repeat("`", FenceLength(content, '`')),
+	})
+}
@@ -0,0 +1,655 @@
+// project-bundler/pkg/bundler/walk.go
+package bundler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/build"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrDeadlineExceeded aborts the directory walk when Options.Deadline passes.
+var ErrDeadlineExceeded = errors.New("maximum runtime exceeded")
+
+// Options holds the resolved filtering rules for a walk.
+type Options struct {
+	SrcDir         string // Root of the walk; Bundle sets it from its src argument.
+	FS             fs.FS  // Tree to walk instead of the SrcDir directory, e.g. files dropped into a browser; nil uses os.DirFS(SrcDir).
+	ProjectType    string
+	IgnoreDirs     RuleSet
+	IgnoreExts     RuleSet
+	IgnoreSuffixes []string
+	IgnorePaths    RuleSet
+	Only           RuleSet // Allowlist globs (plus "preset") for deny-by-default runs; nil disables.
+	LangMap        map[string]string
+	Style          Style
+	Placeholders   string // How to treat cloud placeholder files: skip, stub, or hydrate.
+	Minified       string // How to treat minified JavaScript and CSS (IsMinified): skip, stub, or "" to bundle them.
+	Binary         string // How to treat binary files: skip, or stub to list them without their content.
+
+	OneFileSystem     bool           // Do not descend into directories on other filesystems (mount points).
+	BuildContext      *build.Context // Go files that do not build in this context are skipped or marked; nil disables.
+	MarkBuildExcluded bool           // Bundle files excluded by BuildContext with a note instead of skipping them.
+	EditorConfig      bool           // Record the charset and end_of_line of each file's .editorconfig.
+	GitIgnore         bool           // Skip paths matched by .gitignore files, .git/info/exclude and GitExcludes.
+	GitExcludes       []string       // Global gitignore patterns (git's core.excludesFile), applied from the root.
+	BundlerIgnore     bool           // Skip paths matched by .bundlerignore files (gitignore syntax, for their directory's subtree).
+	SkipGenerated     bool           // Skip files carrying a "Code generated ... DO NOT EDIT." header.
+	IgnoreMarker      bool           // Skip files with a "bundler:ignore" comment in their first lines (HasIgnoreMarker).
+	Deadline          time.Time      // Abort the walk with ErrDeadlineExceeded after this time; zero disables.
+	AllowSecrets      bool           // Bundle files on the secret hard-block list (see SecretRule) instead of skipping them.
+	Jobs              int            // Top-level directories walked, and files checked, at once; 0 means one per CPU.
+	Order             string         // Order of the returned files, one of Orders; "" is the walk's own depth-first order.
+	NormalizeEOL      bool           // Bundle converts CRLF and CR line endings to LF in every file.
+	Cache             *WalkCache     // Reuse directory listings and file checks of earlier walks of the tree; nil disables.
+	FailOnSecrets     bool           // Bundle stops with a *SecretError at the first file whose content ScanSecrets flags.
+	MaxBytes          int64          // Bundle stops with ErrBudgetExceeded before the file content written would pass this; 0 disables.
+
+	// OnDecision, when set, is called for every path the walk decides on,
+	// with the rule that decided it. reason is a reason code, or "" for
+	// included files.
+	OnDecision func(path, reason, rule string)
+	// OnSecret, when set, is called for each hard-blocked secret file that
+	// AllowSecrets lets through.
+	OnSecret func(path, rule string)
+}
+
+// NewOptions returns the rules of the named preset plus the common ignore
+// directories, rendered in the github style.
+func NewOptions(projectType string) (Options, error) {
+	config, ok := Presets[projectType]
+	if !ok {
+		return Options{}, fmt.Errorf("invalid project type '%s'. Available types are: %s", projectType, strings.Join(ProjectTypes(), ", "))
+	}
+	source := "preset " + projectType
+	opts := Options{
+		ProjectType:    projectType,
+		IgnoreDirs:     make(RuleSet),
+		IgnoreExts:     make(RuleSet),
+		IgnoreSuffixes: config.IgnoreSuffixes,
+		IgnorePaths:    make(RuleSet),
+		LangMap:        make(map[string]string),
+		Style:          Styles["github"],
+		Placeholders:   "skip",
+		Minified:       "skip",
+		Binary:         "skip",
+		GitIgnore:      true,
+		IgnoreMarker:   true,
+		BundlerIgnore:  true,
+	}
+	opts.IgnoreDirs.Add(config.IgnoreDirs, source)
+	opts.IgnoreDirs.Add(CommonIgnoreDirs, "common ignore list")
+	opts.IgnoreExts.Add(config.IgnoreExts, source)
+	opts.IgnorePaths.Add(config.IgnorePaths, source)
+	for _, m := range []map[string]string{BaseLangMap, config.LangMap} {
+		for k, v := range m {
+			opts.LangMap[k] = v
+		}
+	}
+	return opts, nil
+}
+
+// File describes a file that passed all filters and will be bundled.
+type File struct {
+	Path    string // Path as seen during the walk.
+	RelPath string // Path relative to the source directory.
+	Lang    string
+	Size    int64
+	Mode    fs.FileMode // Permission bits.
+	ModTime time.Time
+
+	Placeholder bool   // Cloud placeholder bundled as a stub without reading it.
+	Minified    bool   // Minified JavaScript or CSS bundled as a stub (Options.Minified "stub").
+	Binary      bool   // Binary file bundled as a stub (Options.Binary "stub").
+	NotBuilt    bool   // Excluded by the build constraints of Options.BuildContext (MarkBuildExcluded).
+	DuplicateOf string // Earlier RelPath with the same device and inode; bundled as a cross-reference.
+	Charset     string // Charset declared by .editorconfig (EditorConfig) or detected from the content.
+	EOL         string // Line endings declared by .editorconfig (EditorConfig).
+
+	CharsetDetected bool // Charset was detected from the content (DetectCharset), not declared.
+}
+
+// fileID identifies a file or directory independently of the path it was
+// reached by; see fileIdentity.
+type fileID struct {
+	dev, ino uint64
+}
+
+// IsBinaryFile checks the first 1KB of the named file of fsys for null bytes
+// to detect binary content.
+func IsBinaryFile(fsys fs.FS, name string) (bool, error) {
+	head, err := readHead(fsys, name)
+	if err != nil {
+		return false, err
+	}
+	// A null byte is a strong indicator of a binary file.
+	return bytes.Contains(head, []byte{0}), nil
+}
+
+// readHead reads up to the first 1KB of the named file of fsys.
+func readHead(fsys fs.FS, name string) ([]byte, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	buffer := make([]byte, 1024)
+	n, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return buffer[:n], nil
+}
+
+// fileSystem returns the tree the options describe. This is the library's
+// only access to the operating system's files.
+func (opts Options) fileSystem() fs.FS {
+	if opts.FS != nil {
+		return opts.FS
+	}
+	return os.DirFS(opts.SrcDir)
+}
+
+// Collect walks the source tree and applies the ignore rules, returning the
+// files to bundle (in opts.Order) and the skipped paths grouped by reason
+// code. File contents are not retained; only the binary check reads from
+// disk.
+// File.Path is RelPath joined to SrcDir; everything is read through the
+// tree's fs.FS using the slash form of RelPath.
+//
+// The walk, which only looks at names and metadata, runs on opts.Jobs
+// workers, one top-level directory each; the checks that open files run on
+// as many workers afterwards. Both record their results and apply them in
+// walk order, so the outcome does not depend on the number of workers.
+func Collect(opts Options) (files []File, skipped map[string][]string, walkErr error) {
+	fsys := opts.fileSystem()
+	var buildContext *build.Context
+	if opts.BuildContext != nil {
+		// Read build constraints through fsys as well.
+		ctx := *opts.BuildContext
+		ctx.JoinPath = path.Join
+		ctx.OpenFile = func(name string) (io.ReadCloser, error) { return fsys.Open(name) }
+		buildContext = &ctx
+	}
+	if opts.Cache != nil {
+		opts.Cache.begin(walkCacheKey(opts, buildContext))
+		defer func() { opts.Cache.finish(walkErr == nil) }()
+		fsys = cachedDirFS{fsys, opts.Cache}
+	}
+	var pending []pendingFile // Files that passed the name and metadata rules, in walk order.
+	skipped = make(map[string][]string)
+	skip := func(path, reason, rule string) {
+		skipped[reason] = append(skipped[reason], path)
+		if opts.OnDecision != nil {
+			opts.OnDecision(path, reason, rule)
+		}
+	}
+	include := func(path, rule string) {
+		if opts.OnDecision != nil {
+			opts.OnDecision(path, "", rule)
+		}
+	}
+	seen := make(map[fileID]string) // First relative path reached for each file or directory.
+	var rootDev uint64              // Set when the root is visited, before any subtree.
+	var editorConfig *editorConfigs
+	if opts.EditorConfig {
+		editorConfig = newEditorConfigs(fsys)
+	}
+	var gitIgnore *gitIgnores
+	if opts.GitIgnore {
+		gitIgnore = newGitIgnores(fsys, opts.GitExcludes)
+	}
+	var bundlerIgnore *gitIgnores
+	if opts.BundlerIgnore {
+		bundlerIgnore = newBundlerIgnores(fsys)
+	}
+
+	// visitor returns the walk function for a subtree, which records what it
+	// decides in events. seen holds the directories entered in the subtree, to
+	// prune bind mounts that would loop; duplicates across subtrees are found
+	// when the events are replayed.
+	visitor := func(events *[]walkEvent, seen map[fileID]string) fs.WalkDirFunc {
+		return func(name string, d fs.DirEntry, err error) error {
+			path := filepath.Join(opts.SrcDir, filepath.FromSlash(name))
+			if err != nil {
+				var pathErr *fs.PathError
+				if errors.As(err, &pathErr) && pathErr.Path == name {
+					pathErr.Path = path // Name the file as the caller knows it.
+				}
+				return err // Propagate errors like permission denied.
+			}
+			rel := filepath.FromSlash(name)
+			record := func(reason, rule string) {
+				*events = append(*events, walkEvent{name: name, path: path, reason: reason, rule: rule})
+			}
+			if !opts.Deadline.IsZero() && time.Now().After(opts.Deadline) {
+				return ErrDeadlineExceeded
+			}
+
+			// Skip anything matching a full-path pattern, pruning whole directories.
+			if len(opts.IgnorePaths) > 0 && rel != "." {
+				if pattern, ok := opts.IgnorePaths.MatchingGlob(rel); ok {
+					record(ReasonIgnoredPath, opts.IgnorePaths.Describe("pattern", pattern))
+					if d.IsDir() {
+						return fs.SkipDir
+					}
+					return nil
+				}
+			}
+
+			// Skip what git ignores; an ignored directory is pruned, so nothing
+			// below it can be re-included, as in git.
+			if gitIgnore != nil && rel != "." {
+				if rule, source, ok := gitIgnore.match(name, d.IsDir()); ok {
+					record(ReasonGitignored, fmt.Sprintf("pattern %q from %s", rule, source))
+					if d.IsDir() {
+						return fs.SkipDir
+					}
+					return nil
+				}
+			}
+			if bundlerIgnore != nil && rel != "." {
+				if rule, source, ok := bundlerIgnore.match(name, d.IsDir()); ok {
+					record(ReasonBundlerIgnored, fmt.Sprintf("pattern %q from %s", rule, source))
+					if d.IsDir() {
+						return fs.SkipDir
+					}
+					return nil
+				}
+			}
+
+			// Skip directories that are in the ignore list.
+			if d.IsDir() {
+				if opts.IgnoreDirs.Contains(d.Name()) {
+					record(ReasonIgnoredDir, opts.IgnoreDirs.Describe("directory", d.Name()))
+					return fs.SkipDir // Efficiently prune this entire directory.
+				}
+				// A directory reached twice is a bind mount or a duplicated mount
+				// point; walking it again would double the bundle.
+				if info, err := d.Info(); err == nil {
+					if id, ok := fileIdentity(info); ok {
+						if rel == "." {
+							rootDev = id.dev
+						} else if opts.OneFileSystem && id.dev != rootDev {
+							record(ReasonOtherFilesystem, "mount point skipped by -one-file-system")
+							return fs.SkipDir
+						}
+						if first, dup := seen[id]; dup {
+							record(ReasonDuplicateDir, "same directory as /"+filepath.ToSlash(first))
+							return fs.SkipDir
+						}
+						seen[id] = rel
+						*events = append(*events, walkEvent{name: name, path: path, dir: true, id: id})
+					}
+				}
+				return nil
+			}
+
+			// Secrets are skipped under every preset and rule set, unless the
+			// caller explicitly allows them.
+			if rule := SecretRule(name, func() []byte { data, _ := fs.ReadFile(fsys, name); return data }); rule != "" {
+				if !opts.AllowSecrets {
+					record(ReasonSecret, rule)
+					return nil
+				}
+				*events = append(*events, walkEvent{name: name, path: path, secretRule: rule})
+			}
+
+			// Skip files based on extension or full filename.
+			ext := filepath.Ext(d.Name())
+			if opts.IgnoreExts.Contains(ext) {
+				record(ReasonIgnoredExt, opts.IgnoreExts.Describe("extension", ext))
+				return nil
+			}
+			if opts.IgnoreExts.Contains(d.Name()) {
+				record(ReasonIgnoredExt, opts.IgnoreExts.Describe("file name", d.Name()))
+				return nil
+			}
+
+			// Check Suffixes
+			for _, suffix := range opts.IgnoreSuffixes {
+				if strings.HasSuffix(d.Name(), suffix) {
+					record(ReasonIgnoredSuffix, fmt.Sprintf("suffix %q from preset %s", suffix, opts.ProjectType))
+					return nil
+				}
+			}
+
+			// In allowlist mode a file must match an include pattern, or be a known
+			// language of the preset when "preset" is listed, to get any further.
+			if opts.Only != nil {
+				_, known := opts.LangMap[ext]
+				if _, ok := FilenameLangMap[d.Name()]; ok {
+					known = true
+				}
+				if _, ok := opts.Only.MatchingGlob(rel); !ok && !(known && opts.Only.Contains("preset")) {
+					record(ReasonPolicy, "no -only or -include pattern matches")
+					return nil
+				}
+			}
+
+			// Named pipes, devices, sockets and Windows junctions can block or fail
+			// on read; symlinks are still followed when the file is opened.
+			if !d.Type().IsRegular() && d.Type()&fs.ModeSymlink == 0 {
+				record(ReasonSpecialFile, "file mode "+d.Type().String())
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				record(ReasonReadError, err.Error())
+				return nil
+			}
+
+			entry := File{
+				Path:    path,
+				RelPath: rel,
+				Lang:    DetectLanguage(d.Name(), opts.LangMap),
+				Size:    info.Size(),
+				Mode:    info.Mode().Perm(),
+				ModTime: info.ModTime(),
+			}
+
+			// Cloud placeholders must be handled before anything opens the file,
+			// because opening one triggers a (possibly slow or hanging) download.
+			if isCloudPlaceholder(info) {
+				switch opts.Placeholders {
+				case "stub":
+					entry.Placeholder = true
+					*events = append(*events, walkEvent{name: name, path: path, file: &pendingFile{entry: entry}})
+					return nil
+				case "skip":
+					record(ReasonPlaceholder, "-placeholders=skip")
+					return nil
+				}
+				// "hydrate" falls through: reading the file downloads it.
+			}
+
+			if editorConfig != nil {
+				props := editorConfig.properties(rel)
+				entry.Charset, entry.EOL = props["charset"], props["end_of_line"]
+			}
+			*events = append(*events, walkEvent{name: name, path: path, file: &pendingFile{entry: entry, name: name, info: info, symlink: d.Type()&fs.ModeSymlink != 0}})
+			return nil
+		}
+	}
+
+	// The root is walked first, listing its entries without descending; then
+	// each entry's subtree is walked on a worker.
+	type subtree struct {
+		name   string
+		d      fs.DirEntry
+		events []walkEvent
+		err    error
+	}
+	var root subtree
+	var top []*subtree
+	rootSeen := make(map[fileID]string)
+	visitRoot := visitor(&root.events, rootSeen)
+	root.err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if name == "." || err != nil {
+			return visitRoot(name, d, err)
+		}
+		top = append(top, &subtree{name: name, d: d})
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if root.err != nil {
+		top = nil
+	}
+	parallel(len(top), opts.Jobs, func(i int) {
+		t := top[i]
+		visit := visitor(&t.events, maps.Clone(rootSeen))
+		t.err = visit(t.name, t.d, nil)
+		if t.err == nil && t.d.IsDir() {
+			t.err = fs.WalkDir(fsys, t.name, func(name string, d fs.DirEntry, err error) error {
+				if name == t.name && err == nil {
+					return nil // Visited above, with its entry from the listing.
+				}
+				return visit(name, d, err)
+			})
+		}
+		if t.err == fs.SkipDir {
+			t.err = nil
+		}
+	})
+
+	// Replay the events in walk order, stopping where a sequential walk would
+	// have stopped. A directory already entered in an earlier subtree is
+	// skipped with everything recorded below it.
+	walkErr = root.err
+	for _, t := range append([]*subtree{&root}, top...) {
+		dropped := "" // A duplicate directory whose events are dropped.
+		for _, ev := range t.events {
+			if dropped != "" && strings.HasPrefix(ev.name, dropped+"/") {
+				continue
+			}
+			switch {
+			case ev.dir:
+				if first, dup := seen[ev.id]; dup {
+					skip(ev.path, ReasonDuplicateDir, "same directory as /"+filepath.ToSlash(first))
+					dropped = ev.name
+				} else {
+					seen[ev.id] = filepath.FromSlash(ev.name)
+				}
+			case ev.file != nil:
+				pending = append(pending, *ev.file)
+			case ev.secretRule != "":
+				if opts.OnSecret != nil {
+					opts.OnSecret(ev.path, ev.secretRule)
+				}
+			default:
+				skip(ev.path, ev.reason, ev.rule)
+			}
+		}
+		if t != &root && t.err != nil {
+			walkErr = t.err
+			break
+		}
+	}
+
+	// Open each file on a worker; the results are applied below in order.
+	parallel(len(pending), opts.Jobs, func(i int) {
+		p := &pending[i]
+		if p.entry.Placeholder || !opts.Deadline.IsZero() && time.Now().After(opts.Deadline) {
+			return
+		}
+		p.checked = true
+		if p.symlink {
+			p.info, p.statErr = fs.Stat(fsys, p.name)
+		} else if opts.Cache != nil {
+			var cached bool
+			if p.fileChecks, cached = opts.Cache.check(p.name, p.info); cached {
+				return
+			}
+			defer func() {
+				if p.err == nil {
+					opts.Cache.keepCheck(p.name, p.info, p.fileChecks)
+				}
+			}()
+		}
+		// IMPORTANT: Perform binary file detection to prevent corruption.
+		head, err := readHead(fsys, p.name)
+		if p.err = err; err != nil {
+			return
+		}
+		p.binary = bytes.Contains(head, []byte{0})
+		p.optedOut = opts.IgnoreMarker && HasIgnoreMarker(head)
+		p.charset = DetectCharset(head)
+		p.lang = DetectLanguageContent(path.Base(p.name), opts.LangMap, head)
+		if buildContext != nil && path.Ext(p.name) == ".go" {
+			if match, err := buildContext.MatchFile(path.Dir(p.name), path.Base(p.name)); err == nil && !match {
+				p.notBuilt = true
+			}
+		}
+		p.generated = opts.SkipGenerated && IsGeneratedFile(fsys, p.name)
+		p.minified = opts.Minified != "" && (p.lang == "javascript" || p.lang == "css") && isMinifiedFile(fsys, p.name)
+	})
+
+	for _, p := range pending {
+		entry := p.entry
+		path := entry.Path
+		if entry.Placeholder {
+			include(path, "cloud placeholder stub from -placeholders=stub")
+			files = append(files, entry)
+			continue
+		}
+		if !p.checked {
+			continue // Not checked: the deadline passed.
+		}
+		if p.err != nil {
+			skip(path, ReasonReadError, p.err.Error())
+			continue
+		}
+		if p.optedOut {
+			skip(path, ReasonIgnoreMarker, "bundler:ignore comment in the file")
+			continue
+		}
+		entry.Lang = p.lang
+		// A declared charset wins over the detected one.
+		if entry.Charset == "" && p.charset != "" {
+			entry.Charset, entry.CharsetDetected = p.charset, true
+		}
+		// UTF-16 text is full of null bytes, so a UTF-16 charset wins.
+		if p.binary && !declaresUTF16(entry.Charset) {
+			if opts.Binary != "stub" {
+				skip(path, ReasonBinary, "null byte in the first 1KB")
+				continue // Safely skip this binary file.
+			}
+			entry.Binary = true
+			include(path, "binary file stub")
+			files = append(files, entry)
+			continue
+		}
+
+		// Go files whose build constraints (file name suffixes and //go:build
+		// lines) exclude them from the target platform.
+		if p.notBuilt {
+			if !opts.MarkBuildExcluded {
+				skip(path, ReasonBuildConstraints, "build constraints exclude "+buildContext.GOOS+"/"+buildContext.GOARCH)
+				continue
+			}
+			entry.NotBuilt = true
+		}
+
+		// Hard links share one inode; bundle the content only under the first
+		// path and cross-reference it from the others.
+		if p.symlink && p.statErr == nil {
+			entry.Mode, entry.ModTime = p.info.Mode().Perm(), p.info.ModTime()
+		}
+		if p.statErr == nil {
+			if id, ok := fileIdentity(p.info); ok {
+				if first, dup := seen[id]; dup {
+					entry.DuplicateOf = first
+					include(path, "same file as /"+filepath.ToSlash(first))
+					files = append(files, entry)
+					continue
+				}
+				seen[id] = entry.RelPath
+			}
+		}
+
+		if p.generated {
+			skip(path, ReasonGenerated, "\"Code generated ... DO NOT EDIT.\" header")
+			continue
+		}
+		if p.minified {
+			if opts.Minified != "stub" {
+				skip(path, ReasonMinified, "long lines, little whitespace or bundler markers")
+				continue
+			}
+			entry.Minified = true
+		}
+
+		// At this point, the file is considered valid for bundling.
+		include(path, "passed all filters")
+		files = append(files, entry)
+	}
+	if walkErr == nil && !opts.Deadline.IsZero() && time.Now().After(opts.Deadline) {
+		walkErr = ErrDeadlineExceeded
+	}
+	if err := SortFiles(files, opts.Order); err != nil && walkErr == nil {
+		walkErr = err
+	}
+
+	return files, skipped, walkErr
+}
+
+// walkEvent is a decision the walk of a subtree recorded, to be applied in
+// walk order: a skip with its reason, a directory entered, a secret file let
+// through, or a file for the checks that open it.
+type walkEvent struct {
+	name         string // Slash-separated name in the tree's fs.FS.
+	path         string
+	reason, rule string
+	dir          bool
+	id           fileID // The entered directory's identity.
+	secretRule   string
+	file         *pendingFile
+}
+
+// pendingFile is a file that passed the walk's name and metadata rules,
+// with the results of the checks that open it.
+type pendingFile struct {
+	entry   File
+	name    string // Slash-separated name in the tree's fs.FS.
+	info    fs.FileInfo
+	symlink bool
+
+	checked bool  // False when the deadline passed first.
+	err     error // From opening the file for the binary check.
+	statErr error // From following a symlink.
+	fileChecks
+}
+
+// fileChecks are the results of the checks that open a file, which a
+// WalkCache keeps for files that have not changed.
+type fileChecks struct {
+	binary    bool
+	charset   string // Detected from the first 1KB.
+	lang      string // Likewise, for files whose name does not tell.
+	notBuilt  bool
+	generated bool
+	optedOut  bool
+	minified  bool
+}
+
+// parallel calls fn for every index below n on up to jobs goroutines (one
+// per CPU when jobs is 0) and returns when all calls have.
+func parallel(n, jobs int, fn func(i int)) {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	jobs = min(jobs, n)
+	if jobs <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < n; i = int(next.Add(1) - 1) {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
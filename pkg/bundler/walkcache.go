@@ -0,0 +1,203 @@
+// project-bundler/pkg/bundler/walkcache.go
+package bundler
+
+import (
+	"fmt"
+	"go/build"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+)
+
+// walkCacheRacyWindow is how recently a directory or file may have changed
+// and still be cached. A change within the filesystem's timestamp
+// granularity of the moment it was read would keep the same modification
+// time, so such entries are read again by the next walk.
+const walkCacheRacyWindow = 2 * time.Second
+
+// WalkCache carries what Collect learned about a tree over to the next walk
+// of the same tree, for callers that walk it over and over, like a watcher,
+// a daemon or an editor integration. It keeps directory listings while the
+// directory's modification time stays the same, and the results of the
+// checks that open files (binary, charset, language, markers, generated and
+// minified detection, build constraints) while the file's size, mode and
+// modification time stay the same. Files are still statted on every walk,
+// since editing a file does not change its directory's modification time.
+//
+// A WalkCache is safe for concurrent use; walks that share one run one at a
+// time. Entries made with other options or for another tree are dropped.
+type WalkCache struct {
+	walking sync.Mutex // Held by the walk using the cache.
+
+	mu                   sync.Mutex
+	key                  string // The tree and options of the entries.
+	start                time.Time
+	dirs, nextDirs       map[string]cachedDir   // By slash-separated name.
+	checks, nextChecks   map[string]cachedCheck // Likewise.
+	dirHits, checkHits   int
+	dirTotal, checkTotal int
+}
+
+// cachedDir is a directory listing with the directory's modification time.
+type cachedDir struct {
+	modTime time.Time
+	entries []fs.DirEntry
+}
+
+// cachedCheck is the result of the checks that open a file, with the
+// metadata the file had.
+type cachedCheck struct {
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	checks  fileChecks
+}
+
+// NewWalkCache returns an empty cache to set as Options.Cache.
+func NewWalkCache() *WalkCache {
+	return &WalkCache{}
+}
+
+// Stats returns how many directory listings and file checks the last walk
+// took from the cache, out of how many it needed.
+func (c *WalkCache) Stats() (dirHits, dirs, checkHits, checks int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dirHits, c.dirTotal, c.checkHits, c.checkTotal
+}
+
+// begin starts a walk with the given cache key. What the walk reads or
+// finds goes into the next generation, which replaces the entries when the
+// walk completes, so those of removed paths do not pile up.
+func (c *WalkCache) begin(key string) {
+	c.walking.Lock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key != c.key {
+		c.key, c.dirs, c.checks = key, nil, nil
+	}
+	c.start = time.Now()
+	c.nextDirs, c.nextChecks = make(map[string]cachedDir), make(map[string]cachedCheck)
+	c.dirHits, c.dirTotal, c.checkHits, c.checkTotal = 0, 0, 0, 0
+}
+
+// finish ends the walk begin started, keeping its entries if it completed.
+func (c *WalkCache) finish(completed bool) {
+	c.mu.Lock()
+	if completed {
+		c.dirs, c.checks = c.nextDirs, c.nextChecks
+	}
+	c.nextDirs, c.nextChecks = nil, nil
+	c.mu.Unlock()
+	c.walking.Unlock()
+}
+
+// racy reports whether a modification time is too recent to cache by.
+func (c *WalkCache) racy(modTime time.Time) bool {
+	return !modTime.Before(c.start.Add(-walkCacheRacyWindow))
+}
+
+// dir returns the cached listing of a directory, if it has not changed.
+func (c *WalkCache) dir(name string, modTime time.Time) ([]fs.DirEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dirTotal++
+	d, ok := c.dirs[name]
+	if !ok || !d.modTime.Equal(modTime) {
+		return nil, false
+	}
+	c.dirHits++
+	c.nextDirs[name] = d
+	return d.entries, true
+}
+
+func (c *WalkCache) keepDir(name string, modTime time.Time, entries []fs.DirEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.racy(modTime) {
+		c.nextDirs[name] = cachedDir{modTime, entries}
+	}
+}
+
+// check returns the cached check results of a file, if it has not changed.
+func (c *WalkCache) check(name string, info fs.FileInfo) (fileChecks, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checkTotal++
+	f, ok := c.checks[name]
+	if !ok || f.size != info.Size() || f.mode != info.Mode() || !f.modTime.Equal(info.ModTime()) {
+		return fileChecks{}, false
+	}
+	c.checkHits++
+	c.nextChecks[name] = f
+	return f.checks, true
+}
+
+func (c *WalkCache) keepCheck(name string, info fs.FileInfo, checks fileChecks) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.racy(info.ModTime()) {
+		c.nextChecks[name] = cachedCheck{info.Size(), info.Mode(), info.ModTime(), checks}
+	}
+}
+
+// walkCacheKey identifies the tree and the options the checks depend on.
+func walkCacheKey(opts Options, buildContext *build.Context) string {
+	key := fmt.Sprint(opts.SrcDir, opts.FS != nil, opts.IgnoreMarker, opts.SkipGenerated, opts.Minified != "", opts.LangMap)
+	if buildContext != nil {
+		key += fmt.Sprint(buildContext.GOOS, buildContext.GOARCH, buildContext.CgoEnabled, buildContext.BuildTags, buildContext.ToolTags, buildContext.ReleaseTags)
+	}
+	return key
+}
+
+// cachedDirFS is a tree whose directory listings come from a WalkCache when
+// the directory has not changed since it was last read.
+type cachedDirFS struct {
+	fs.FS
+	cache *WalkCache
+}
+
+func (f cachedDirFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(f.FS, name)
+}
+
+func (f cachedDirFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(f.FS, name)
+}
+
+func (f cachedDirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	info, err := fs.Stat(f.FS, name)
+	if err != nil {
+		return nil, err
+	}
+	if entries, ok := f.cache.dir(name, info.ModTime()); ok {
+		fresh := make([]fs.DirEntry, len(entries))
+		for i, e := range entries {
+			fresh[i] = freshDirEntry{e, f.FS, path.Join(name, e.Name())}
+		}
+		return fresh, nil
+	}
+	entries, err := fs.ReadDir(f.FS, name)
+	if err == nil {
+		f.cache.keepDir(name, info.ModTime(), entries)
+	}
+	return entries, err
+}
+
+// freshDirEntry is an entry of a cached listing whose Info stats the file
+// again, as some filesystems' entries hold the metadata of when the
+// directory was read. Symlinks keep their entry's: fs.FS cannot stat a
+// link itself.
+type freshDirEntry struct {
+	fs.DirEntry
+	fsys fs.FS
+	name string
+}
+
+func (e freshDirEntry) Info() (fs.FileInfo, error) {
+	if e.Type()&fs.ModeSymlink != 0 {
+		return e.DirEntry.Info()
+	}
+	return fs.Stat(e.fsys, e.name)
+}
@@ -0,0 +1,406 @@
+// project-bundler/plugins.go
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// Plugins are executables named project-bundler-plugin-<name> on PATH. A
+// plugin is started once per run and speaks JSON over stdio: every request
+// is one JSON object on a line of its stdin, answered by one JSON object on
+// a line of its stdout, in order. What it writes to stderr is passed
+// through. The first request is always "describe"; the plugin exits when
+// its stdin is closed.
+const (
+	pluginPrefix   = "project-bundler-plugin-"
+	pluginProtocol = 1
+)
+
+// Plugin kinds, as listed by a plugin's describe response.
+const (
+	pluginSource      = "source"      // Provides the files of a -src plugin:<name>:<ref>.
+	pluginTransformer = "transformer" // Rewrites file contents for -transform.
+	pluginDetector    = "detector"    // Adds findings to the secret scan for -detect.
+	pluginSink        = "sink"        // Receives the finished bundle for -sink.
+)
+
+// pluginRequest is a request to a plugin. Method is "describe", "source",
+// "transform", "detect" or "sink"; the other fields are the method's.
+type pluginRequest struct {
+	Protocol int      `json:"protocol"`
+	Method   string   `json:"method"`
+	Ref      string   `json:"ref,omitempty"`     // source: what to fetch.
+	Path     string   `json:"path,omitempty"`    // transform and detect: slash-separated, relative to the source.
+	Lang     string   `json:"lang,omitempty"`    // transform and detect.
+	Content  *string  `json:"content,omitempty"` // transform and detect: the file, as UTF-8.
+	Outputs  []string `json:"outputs,omitempty"` // sink: absolute paths of the files written.
+	Files    int      `json:"files,omitempty"`   // sink: the number of files bundled.
+}
+
+// pluginFile is a file of a source response.
+type pluginFile struct {
+	Path    string `json:"path"` // Slash-separated, relative to the source.
+	Content string `json:"content"`
+}
+
+// pluginFinding is a finding of a detect response, with byte offsets into
+// the content.
+type pluginFinding struct {
+	Rule  string `json:"rule"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// pluginResponse is a plugin's answer. A non-empty Error fails the request.
+type pluginResponse struct {
+	Error string `json:"error,omitempty"`
+	// describe:
+	Protocol    int      `json:"protocol,omitempty"`
+	Kinds       []string `json:"kinds,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Match       []string `json:"match,omitempty"` // Path globs a transformer or detector handles; none is all.
+	// source:
+	Files []pluginFile `json:"files,omitempty"`
+	// transform: nil Content keeps the file as it is.
+	Content *string `json:"content,omitempty"`
+	Note    string  `json:"note,omitempty"` // Shown above a transformed file.
+	// detect:
+	Findings []pluginFinding `json:"findings,omitempty"`
+	// sink:
+	Message string `json:"message,omitempty"`
+}
+
+// plugin is a running plugin process.
+type plugin struct {
+	name, path string
+	describe   pluginResponse
+	match      ruleSet
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// findPlugins returns the plugins on PATH by name. As for commands, the
+// first directory on PATH with a plugin of a name wins.
+func findPlugins() map[string]string {
+	found := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, ok := strings.CutPrefix(e.Name(), pluginPrefix)
+			if runtime.GOOS == "windows" {
+				var exe bool
+				name, exe = strings.CutSuffix(name, ".exe")
+				ok = ok && exe
+			}
+			if !ok || name == "" || e.IsDir() {
+				continue
+			}
+			if _, seen := found[name]; seen {
+				continue
+			}
+			if info, err := e.Info(); err != nil || runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0 {
+				continue
+			}
+			found[name] = filepath.Join(dir, e.Name())
+		}
+	}
+	return found
+}
+
+// startPlugin starts the named plugin and checks that it speaks the protocol
+// and is of the given kind. The caller closes it.
+func startPlugin(name, kind string) (*plugin, error) {
+	exe, ok := findPlugins()[name]
+	if !ok {
+		return nil, fmt.Errorf("no plugin '%s': %s%s is not on PATH", name, pluginPrefix, name)
+	}
+	p := &plugin{name: name, path: exe, match: make(ruleSet)}
+	p.cmd = exec.Command(exe)
+	p.cmd.Stderr = os.Stderr
+	stdin, err := p.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	p.stdin, p.stdout = stdin, bufio.NewReader(stdout)
+	if err := p.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("plugin '%s': %w", name, err)
+	}
+	if p.describe, err = p.call(pluginRequest{Method: "describe"}); err != nil {
+		p.Close()
+		return nil, err
+	}
+	switch {
+	case p.describe.Protocol != pluginProtocol:
+		err = fmt.Errorf("plugin '%s' speaks protocol %d, not %d", name, p.describe.Protocol, pluginProtocol)
+	case kind != "" && !slices.Contains(p.describe.Kinds, kind):
+		err = fmt.Errorf("plugin '%s' is not a %s; it is a %s", name, kind, strings.Join(p.describe.Kinds, ", "))
+	}
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	p.match.Add(p.describe.Match, "plugin "+name)
+	return p, nil
+}
+
+// startPlugins starts the plugins of a comma-separated flag value, exiting
+// if one cannot be used.
+func startPlugins(flagName, names, kind string) []*plugin {
+	var started []*plugin
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		p, err := startPlugin(name, kind)
+		if err != nil {
+			fatalf("Invalid %s: %v", flagName, err)
+		}
+		started = append(started, p)
+	}
+	return started
+}
+
+// call sends a request and reads the response.
+func (p *plugin) call(req pluginRequest) (pluginResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	req.Protocol = pluginProtocol
+	line, err := json.Marshal(req)
+	if err != nil {
+		return pluginResponse{}, err
+	}
+	if _, err := p.stdin.Write(append(line, '\n')); err != nil {
+		return pluginResponse{}, fmt.Errorf("plugin '%s' stopped: %w", p.name, err)
+	}
+	line, err = p.stdout.ReadBytes('\n')
+	if err != nil {
+		return pluginResponse{}, fmt.Errorf("plugin '%s' gave no %s response: %w", p.name, req.Method, err)
+	}
+	var resp pluginResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return pluginResponse{}, fmt.Errorf("plugin '%s' gave an invalid %s response: %w", p.name, req.Method, err)
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("plugin '%s': %s", p.name, resp.Error)
+	}
+	return resp, nil
+}
+
+// handles reports whether a transformer or detector wants a file.
+func (p *plugin) handles(rel string) bool {
+	if len(p.match) == 0 {
+		return true
+	}
+	_, ok := p.match.MatchingGlob(rel)
+	return ok
+}
+
+// Close closes the plugin's stdin and waits for it to exit.
+func (p *plugin) Close() error {
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
+
+// closePlugins closes every plugin of a run.
+func closePlugins(opts bundleOptions) {
+	for _, p := range slices.Concat(opts.transformers, opts.detectors, opts.sinks) {
+		p.Close()
+	}
+}
+
+// parsePluginSource recognizes a -src served by a source plugin, written as
+// plugin:<name>:<ref>.
+func parsePluginSource(src string) (name, ref string, ok bool) {
+	rest, ok := strings.CutPrefix(src, "plugin:")
+	if !ok {
+		return "", "", false
+	}
+	name, ref, _ = strings.Cut(rest, ":")
+	return name, ref, true
+}
+
+// fetchPluginSource writes the files a source plugin provides for ref into
+// a new temporary directory, which the returned function removes.
+func fetchPluginSource(name, ref string) (string, int, func(), error) {
+	p, err := startPlugin(name, pluginSource)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	defer p.Close()
+	resp, err := p.call(pluginRequest{Method: "source", Ref: ref})
+	if err != nil {
+		return "", 0, nil, err
+	}
+	dir, err := os.MkdirTemp("", "project-bundler-plugin-")
+	if err != nil {
+		return "", 0, nil, err
+	}
+	remove := func() { os.RemoveAll(dir) }
+	for _, f := range resp.Files {
+		rel := filepath.FromSlash(f.Path)
+		if !filepath.IsLocal(rel) {
+			remove()
+			return "", 0, nil, fmt.Errorf("plugin '%s' gave a path outside the source: %s", name, f.Path)
+		}
+		target := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			remove()
+			return "", 0, nil, err
+		}
+		if err := os.WriteFile(target, []byte(f.Content), 0o644); err != nil {
+			remove()
+			return "", 0, nil, err
+		}
+	}
+	return dir, len(resp.Files), remove, nil
+}
+
+// transformFiles passes the files each -transform plugin handles through it,
+// in the order of the flag. The results are added to contents, and their
+// notes to notes, both keyed by fileEntry.Path. A file a plugin fails on is
+// bundled as it was.
+func transformFiles(opts bundleOptions, files []fileEntry, contents map[string][]byte, notes map[string]string) {
+	for i := range files {
+		f := &files[i]
+		if f.Placeholder || f.Minified || f.Binary || f.DuplicateOf != "" {
+			continue
+		}
+		rel := filepath.ToSlash(f.RelPath)
+		for _, p := range opts.transformers {
+			if !p.handles(rel) {
+				continue
+			}
+			content, ok := contents[f.Path]
+			if !ok {
+				raw, err := os.ReadFile(f.Path)
+				if err != nil {
+					break // Reported when the file itself is bundled.
+				}
+				content, _ = bundler.Transcode(raw, f.Charset, f.EOL)
+			}
+			text := string(content)
+			resp, err := p.call(pluginRequest{Method: "transform", Path: rel, Lang: f.Lang, Content: &text})
+			if err != nil {
+				log.Printf("Could not transform %s: %v", f.Path, err)
+				continue
+			}
+			if resp.Content == nil {
+				continue
+			}
+			contents[f.Path] = []byte(*resp.Content)
+			f.Size, f.Charset, f.EOL = int64(len(*resp.Content)), "", ""
+			if resp.Note != "" {
+				notes[f.Path] = strings.TrimRight(resp.Note, "\n") + "\n"
+			}
+		}
+	}
+}
+
+// detectWithPlugins asks each -detect plugin that handles the file for its
+// findings, which join the secret scan's.
+func detectWithPlugins(opts bundleOptions, f fileEntry, content []byte) []bundler.SecretFinding {
+	var findings []bundler.SecretFinding
+	rel := filepath.ToSlash(f.RelPath)
+	for _, p := range opts.detectors {
+		if !p.handles(rel) {
+			continue
+		}
+		text := string(content)
+		resp, err := p.call(pluginRequest{Method: "detect", Path: rel, Lang: f.Lang, Content: &text})
+		if err != nil {
+			log.Printf("Could not scan %s: %v", f.Path, err)
+			continue
+		}
+		for _, d := range resp.Findings {
+			if d.Start < 0 || d.End > len(content) || d.Start >= d.End {
+				log.Printf("Ignoring a finding of plugin '%s' outside %s", p.name, f.Path)
+				continue
+			}
+			findings = append(findings, bundler.SecretFinding{
+				Rule:  d.Rule,
+				Line:  1 + strings.Count(text[:d.Start], "\n"),
+				Start: d.Start,
+				End:   d.End,
+			})
+		}
+	}
+	return findings
+}
+
+// sendToSinks hands the written bundle to each -sink plugin.
+func sendToSinks(opts bundleOptions, result bundleResult) error {
+	var outputs []string
+	for _, out := range result.outputs {
+		if abs, err := filepath.Abs(out); err == nil {
+			out = abs
+		}
+		outputs = append(outputs, out)
+	}
+	for _, p := range opts.sinks {
+		resp, err := p.call(pluginRequest{Method: "sink", Outputs: outputs, Files: result.filesBundled})
+		if err != nil {
+			return err
+		}
+		if resp.Message != "" {
+			fmt.Printf("%s: %s\n", p.name, resp.Message)
+		}
+	}
+	return nil
+}
+
+// runPlugins implements the `plugins` subcommand, which lists the plugins on
+// PATH with their kinds.
+func runPlugins(args []string) {
+	fs := flag.NewFlagSet("plugins", flag.ExitOnError)
+	fs.Parse(args)
+
+	found := findPlugins()
+	if len(found) == 0 {
+		fmt.Printf("No plugins found; install executables named %s<name> on PATH.\n", pluginPrefix)
+		return
+	}
+	var names []string
+	for name := range found {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	failed := false
+	for _, name := range names {
+		p, err := startPlugin(name, "")
+		if err != nil {
+			fmt.Printf("%-20s %v\n", name, err)
+			failed = true
+			continue
+		}
+		p.Close()
+		fmt.Printf("%-20s %-24s %s\n", name, strings.Join(p.describe.Kinds, ","), p.describe.Description)
+		fmt.Printf("%-20s %s\n", "", p.path)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,58 @@
+// project-bundler/pricing.go
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// inputPricePerMTok is the list price in USD per million input tokens, keyed
+// by model name prefix (the longest match wins). Prices change often; update
+// this table with each release, and use -price for negotiated rates or models
+// that are not listed. Self-hosted families (Llama, Mistral) have no price.
+var inputPricePerMTok = map[string]float64{
+	"gpt-5":             1.25,
+	"gpt-5-mini":        0.25,
+	"gpt-5-nano":        0.05,
+	"gpt-4.1":           2.00,
+	"gpt-4.1-mini":      0.40,
+	"gpt-4.1-nano":      0.10,
+	"gpt-4o":            2.50,
+	"gpt-4o-mini":       0.15,
+	"gpt-4-turbo":       10.00,
+	"gpt-4":             30.00,
+	"gpt-3.5-turbo":     0.50,
+	"o1":                15.00,
+	"o3":                2.00,
+	"o3-mini":           1.10,
+	"o4-mini":           1.10,
+	"claude-opus-4":     15.00,
+	"claude-sonnet-4":   3.00,
+	"claude-3-7-sonnet": 3.00,
+	"claude-3-5-sonnet": 3.00,
+	"claude-3-5-haiku":  0.80,
+	"claude-3-opus":     15.00,
+	"claude-3-haiku":    0.25,
+	"gemini-2.5-pro":    1.25,
+	"gemini-2.5-flash":  0.30,
+	"gemini-2.0-flash":  0.10,
+	"gemini-1.5-pro":    1.25,
+	"gemini-1.5-flash":  0.075,
+}
+
+// modelInputPrice returns the USD price per million input tokens for model.
+// override, when positive, wins over the built-in table.
+func modelInputPrice(model string, override float64) (float64, bool) {
+	if override > 0 {
+		return override, true
+	}
+	return longestPrefixMatch(inputPricePerMTok, strings.ToLower(strings.TrimSpace(model)))
+}
+
+// formatCost renders a dollar amount with enough precision for small bundles.
+func formatCost(usd float64) string {
+	if usd < 0.01 {
+		return fmt.Sprintf("$%.4f", usd)
+	}
+	return fmt.Sprintf("$%.2f", usd)
+}
@@ -0,0 +1,68 @@
+// project-bundler/printconfig.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// printConfig writes the configuration a run bundles with, once the presets,
+// the local config and the flags are merged, in the local config's YAML
+// shape. Each rule is commented with the layer it came from, and the rules
+// the local config removed are listed with the layer they were dropped from.
+func printConfig(w io.Writer, opts bundleOptions) {
+	fmt.Fprintf(w, "# Effective configuration for '%s'.\n", opts.SrcDir)
+	fmt.Fprintf(w, "type: %s\n", opts.ProjectType)
+	lists := []struct {
+		key   string
+		rules ruleSet
+	}{{"ignore-dirs", opts.IgnoreDirs}, {"ignore-exts", opts.IgnoreExts}, {"ignore-paths", opts.IgnorePaths}}
+	for _, list := range lists {
+		writeRuleList(w, "", list.key, list.rules, "")
+	}
+	suffixes := slices.Clone(opts.IgnoreSuffixes)
+	slices.Sort(suffixes)
+	fmt.Fprintf(w, "ignore-suffixes: [%s]\n", strings.Join(slices.Compact(suffixes), ", "))
+	if len(opts.removed["ignore-dirs"])+len(opts.removed["ignore-exts"])+len(opts.removed["ignore-paths"]) > 0 {
+		fmt.Fprintln(w, "removed:")
+		for _, list := range lists {
+			if removed := opts.removed[list.key]; len(removed) > 0 {
+				writeRuleList(w, "  ", list.key, removed, "was ")
+			}
+		}
+	}
+	if len(opts.budgets) > 0 {
+		fmt.Fprintln(w, "budgets:")
+		for _, b := range opts.budgets {
+			fmt.Fprintf(w, "  %q: %d\n", b.pattern, b.tokens)
+		}
+	}
+	if len(opts.classRules) > 0 {
+		fmt.Fprintln(w, "classification:")
+		for _, r := range opts.classRules {
+			for glob := range r.rule {
+				fmt.Fprintf(w, "  %q: %s\n", glob, r.level)
+			}
+		}
+	}
+}
+
+// writeRuleList writes rules as a YAML list under key, each with a comment
+// naming its layer.
+func writeRuleList(w io.Writer, indent, key string, rules ruleSet, note string) {
+	sorted := rules.Sorted()
+	if len(sorted) == 0 {
+		fmt.Fprintf(w, "%s%s: []\n", indent, key)
+		return
+	}
+	width := 0
+	for _, rule := range sorted {
+		width = max(width, len(fmt.Sprintf("%q", rule)))
+	}
+	fmt.Fprintf(w, "%s%s:\n", indent, key)
+	for _, rule := range sorted {
+		fmt.Fprintf(w, "%s  - %-*q  # %s%s\n", indent, width, rule, note, rules[rule])
+	}
+}
@@ -0,0 +1,269 @@
+// project-bundler/profile.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// profileHeat is how hot each source file of a -profile is at runtime, by
+// the file's path as the profile records it: an import path for coverage
+// profiles, the build machine's path for pprof profiles.
+type profileHeat map[string]int64
+
+// readProfile reads a Go coverage profile (go test -coverprofile), where a
+// file's heat is the statements its blocks ran, weighted by how often in
+// count and atomic mode; or a gzipped pprof profile (go test -cpuprofile,
+// runtime/pprof), where it is the samples' last value, such as CPU time,
+// attributed to the file of the innermost frame.
+func readProfile(file string) (profileHeat, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte("mode: ")) {
+		return readCoverProfile(data)
+	}
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, err
+		}
+	}
+	heat, err := readPprof(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither a coverage nor a pprof profile: %w", file, err)
+	}
+	return heat, nil
+}
+
+// readCoverProfile reads the lines of a coverage profile, which after the
+// mode line are "file:start.col,end.col statements count".
+func readCoverProfile(data []byte) (profileHeat, error) {
+	heat := make(profileHeat)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Scan() // The mode line.
+	for n := 2; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		colon := strings.LastIndexByte(line, ':')
+		fields := strings.Fields(line[colon+1:])
+		if colon < 0 || len(fields) != 3 {
+			return nil, fmt.Errorf("invalid coverage profile line %d", n)
+		}
+		stmts, err1 := strconv.ParseInt(fields[1], 10, 64)
+		count, err2 := strconv.ParseInt(fields[2], 10, 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid coverage profile line %d", n)
+		}
+		heat[line[:colon]] += stmts * count
+	}
+	return heat, scanner.Err()
+}
+
+// readPprof reads the parts of a pprof profile.proto message it needs: the
+// samples with their stacks, the locations and functions, and the strings.
+func readPprof(data []byte) (profileHeat, error) {
+	type sample struct {
+		leaf  uint64
+		value int64
+	}
+	var samples []sample
+	locFunc := make(map[uint64]uint64)  // Location ID to the innermost function's ID.
+	funcFile := make(map[uint64]uint64) // Function ID to its file name's string index.
+	var strs []string
+	err := protoFields(data, func(field int, v uint64, b []byte) error {
+		switch field {
+		case 2: // Sample.
+			var s sample
+			first := true
+			err := protoFields(b, func(field int, v uint64, b []byte) error {
+				switch field {
+				case 1: // location_id, leaf first.
+					ids, err := protoVarints(v, b)
+					if len(ids) > 0 && first {
+						s.leaf, first = ids[0], false
+					}
+					return err
+				case 2: // value; the last is the profile's main sample type.
+					values, err := protoVarints(v, b)
+					if len(values) > 0 {
+						s.value = int64(values[len(values)-1])
+					}
+					return err
+				}
+				return nil
+			})
+			samples = append(samples, s)
+			return err
+		case 4: // Location.
+			var id, fn uint64
+			err := protoFields(b, func(field int, v uint64, b []byte) error {
+				switch field {
+				case 1:
+					id = v
+				case 4: // Line; inlined calls come first.
+					if fn != 0 {
+						return nil
+					}
+					return protoFields(b, func(field int, v uint64, b []byte) error {
+						if field == 1 {
+							fn = v
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			locFunc[id] = fn
+			return err
+		case 5: // Function.
+			var id, name uint64
+			err := protoFields(b, func(field int, v uint64, b []byte) error {
+				switch field {
+				case 1:
+					id = v
+				case 4: // filename.
+					name = v
+				}
+				return nil
+			})
+			funcFile[id] = name
+			return err
+		case 6: // string_table.
+			strs = append(strs, string(b))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(strs) == 0 || strs[0] != "" {
+		return nil, errors.New("no string table")
+	}
+	heat := make(profileHeat)
+	for _, s := range samples {
+		if name := funcFile[locFunc[s.leaf]]; name > 0 && name < uint64(len(strs)) {
+			heat[strs[name]] += s.value
+		}
+	}
+	return heat, nil
+}
+
+// protoFields calls fn for each field of a protocol buffers message: with
+// the value of a varint or fixed-size field, or the bytes of a
+// length-delimited one.
+func protoFields(data []byte, fn func(field int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("invalid field key")
+		}
+		data = data[n:]
+		var v uint64
+		var b []byte
+		switch key & 7 {
+		case 0:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return errors.New("invalid varint")
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return io.ErrUnexpectedEOF
+			}
+			v, data = binary.LittleEndian.Uint64(data), data[8:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || size > uint64(len(data)-n) {
+				return errors.New("invalid length")
+			}
+			b, data = data[n:n+int(size)], data[n+int(size):]
+		case 5:
+			if len(data) < 4 {
+				return io.ErrUnexpectedEOF
+			}
+			v, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d", key&7)
+		}
+		if err := fn(int(key>>3), v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protoVarints returns the values of a repeated integer field, which is
+// either a single varint v or, packed, the varints in b.
+func protoVarints(v uint64, b []byte) ([]uint64, error) {
+	if b == nil {
+		return []uint64{v}, nil
+	}
+	var values []uint64
+	for len(b) > 0 {
+		x, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errors.New("invalid packed varint")
+		}
+		values, b = append(values, x), b[n:]
+	}
+	return values, nil
+}
+
+// forFiles maps the heat to the bundled files, keyed by fileEntry.Path. A
+// profile path belongs to the file whose relative path is its longest
+// matching suffix, so profiles recorded on other machines, or by import
+// path, still apply.
+func (h profileHeat) forFiles(files []fileEntry) map[string]int64 {
+	byRel := make(map[string]string, len(files))
+	for _, f := range files {
+		byRel[filepath.ToSlash(f.RelPath)] = f.Path
+	}
+	heat := make(map[string]int64)
+	for name, value := range h {
+		name = filepath.ToSlash(name)
+		for {
+			if p, ok := byRel[name]; ok {
+				heat[p] += value
+				break
+			}
+			_, rest, found := strings.Cut(name, "/")
+			if !found {
+				break
+			}
+			name = rest
+		}
+	}
+	return heat
+}
+
+// sortByHeat puts the files in -order coverage: the hottest first, and the
+// files the profile does not cover after them in their walk order.
+func sortByHeat(files []fileEntry, heat map[string]int64) {
+	slices.SortStableFunc(files, func(a, b fileEntry) int {
+		ha, hb := heat[a.Path], heat[b.Path]
+		switch {
+		case ha > hb:
+			return -1
+		case ha < hb:
+			return 1
+		}
+		return 0
+	})
+}
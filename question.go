@@ -0,0 +1,95 @@
+// project-bundler/question.go
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeQuestion emits the -question the bundle is the context for. It is
+// written both ahead of the files and after them, as models weigh the start
+// and the end of a long context the most.
+func writeQuestion(w io.Writer, question string, end bool) error {
+	if question == "" {
+		return nil
+	}
+	var b strings.Builder
+	if end {
+		fmt.Fprintf(&b, "Question (repeated from the start of the bundle): %s\n\n", question)
+	} else {
+		fmt.Fprintf(&b, "Question: %s\n\nThe files below are the context for this question, which is repeated at the end of the bundle.\n\n", question)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// readQuestions reads a -questions file: a question per line, skipping
+// blank lines and lines starting with #.
+func readQuestions(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var questions []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			questions = append(questions, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(questions) == 0 {
+		return nil, errors.New("it holds no questions")
+	}
+	return questions, nil
+}
+
+// questionPath is the name of the prompt file of the n-th -questions entry,
+// e.g. bundle.question3.md.
+func questionPath(outputFile string, n int) string {
+	return sidecarPath(outputFile, fmt.Sprintf(".question%d%s", n, filepath.Ext(outputFile)))
+}
+
+// writeQuestionPrompts writes a prompt file for each question of a batch,
+// naming the bundle they share as context, so that one upload of the
+// bundle serves every question. context is the bundle or, when it was
+// split, its index; a whole bundle is named with its SHA-256 to tell it
+// from other versions. Prompt files left from an earlier, longer batch are
+// removed.
+func writeQuestionPrompts(outputFile, context string, files int, questions []string) ([]string, error) {
+	described := fmt.Sprintf("the bundle `%s` (%d files", filepath.Base(context), files)
+	if context == outputFile {
+		data, err := os.ReadFile(outputFile)
+		if err != nil {
+			return nil, err
+		}
+		described += fmt.Sprintf(", SHA-256 %x)", sha256.Sum256(data))
+	} else {
+		described += " in the parts it lists)"
+	}
+	var written []string
+	for i, q := range questions {
+		name := questionPath(outputFile, i+1)
+		prompt := fmt.Sprintf("Question %d of %d about the same context: %s. Attach or paste the bundle ahead of this prompt, or reuse it where it is already loaded; every question of the batch shares it.\n\nQuestion: %s\n",
+			i+1, len(questions), described, q)
+		if err := os.WriteFile(name, []byte(prompt), 0o644); err != nil {
+			return written, err
+		}
+		written = append(written, name)
+	}
+	for n := len(questions) + 1; ; n++ {
+		if err := os.Remove(questionPath(outputFile, n)); err != nil {
+			break
+		}
+	}
+	return written, nil
+}
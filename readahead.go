@@ -0,0 +1,90 @@
+// project-bundler/readahead.go
+package main
+
+import (
+	"os"
+	"runtime"
+)
+
+// readResult is the content of one file read ahead of the writer.
+type readResult struct {
+	content []byte
+	err     error
+}
+
+// readAhead reads the files the bundle loop will need on a pool of workers,
+// a bounded window ahead of it, and hands the results back in file order so
+// the output does not depend on which worker finishes first.
+type readAhead struct {
+	files   []fileEntry
+	results []chan readResult // One per file; nil for files that are not read.
+	slots   chan struct{}     // Bounds how far the workers get ahead.
+	stop    chan struct{}
+}
+
+// startReadAhead starts reading every file for which want returns true on
+// jobs workers (one per CPU when jobs is 0). With a single job nothing is
+// started and read falls back to reading the file when it is asked for.
+func startReadAhead(files []fileEntry, want func(f fileEntry) bool, jobs int) *readAhead {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	r := &readAhead{files: files}
+	if jobs == 1 {
+		return r
+	}
+	r.results = make([]chan readResult, len(files))
+	r.slots = make(chan struct{}, 4*jobs)
+	r.stop = make(chan struct{})
+	work := make(chan int)
+	for i, f := range files {
+		if want(f) {
+			r.results[i] = make(chan readResult, 1)
+		}
+	}
+	go func() {
+		defer close(work)
+		for i := range files {
+			if r.results[i] == nil {
+				continue
+			}
+			select {
+			case r.slots <- struct{}{}:
+			case <-r.stop:
+				return
+			}
+			select {
+			case work <- i:
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+	for range jobs {
+		go func() {
+			for i := range work {
+				content, err := os.ReadFile(files[i].Path)
+				r.results[i] <- readResult{content, err}
+			}
+		}()
+	}
+	return r
+}
+
+// read returns the content of files[i], waiting for its worker if needed.
+func (r *readAhead) read(i int) ([]byte, error) {
+	if r.results == nil || r.results[i] == nil {
+		return os.ReadFile(r.files[i].Path)
+	}
+	res := <-r.results[i]
+	<-r.slots
+	return res.content, res.err
+}
+
+// close stops the workers from starting on further files; it must be
+// called once the loop is done with the files, even if it stopped early.
+func (r *readAhead) close() {
+	if r.stop != nil {
+		close(r.stop)
+	}
+}
@@ -0,0 +1,113 @@
+// project-bundler/remote.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// scpLikeRE matches git's scp-like remote syntax, e.g. git@github.com:org/repo.
+var scpLikeRE = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[^/]`)
+
+// remoteSource is a repository to clone for -src, written as
+// URL[@ref][:/subdir], e.g. https://github.com/org/repo@v1.2.3:/pkg/foo.
+type remoteSource struct {
+	url    string
+	ref    string // Branch, tag or commit; "" is the remote's default branch.
+	subdir string // Slash-separated directory within the repository to bundle.
+}
+
+// parseRemoteSource recognizes a remote -src. ref, from -ref, takes
+// precedence over an @ref in src.
+func parseRemoteSource(src, ref string) (remoteSource, bool) {
+	scheme := strings.Index(src, "://")
+	if scheme < 0 && !scpLikeRE.MatchString(src) {
+		return remoteSource{}, false
+	}
+	var r remoteSource
+	if i := strings.LastIndex(src, ":/"); i >= 0 && i != scheme {
+		src, r.subdir = src[:i], strings.Trim(src[i+1:], "/")
+	}
+	// An @ after the last slash and colon separates the ref; one before them
+	// is the user of the URL.
+	if i := strings.LastIndex(src, "@"); i > strings.LastIndex(src, "/") && i > strings.LastIndex(src, ":") {
+		src, r.ref = src[:i], src[i+1:]
+	}
+	r.url = src
+	if ref != "" {
+		r.ref = ref
+	}
+	return r, true
+}
+
+// name returns the repository's name, e.g. "repo" for
+// https://github.com/org/repo.git.
+func (r remoteSource) name() string {
+	name := strings.TrimSuffix(path.Base(strings.TrimRight(r.url, "/")), ".git")
+	if i := strings.LastIndexAny(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" || name == "." || name == "/" {
+		return "repo"
+	}
+	return name
+}
+
+// cloneRemote fetches the tree of r at its ref into a temporary directory,
+// without history, and returns the directory to bundle (the clone or its
+// subdirectory) and the commit. The caller must call cleanup once bundling
+// is done.
+func cloneRemote(r remoteSource) (dir, commit string, cleanup func(), err error) {
+	if strings.Contains("/"+r.subdir+"/", "/../") {
+		return "", "", nil, fmt.Errorf("subdirectory '%s' leaves the repository", r.subdir)
+	}
+	tmp, err := os.MkdirTemp("", "project-bundler-remote-*")
+	if err != nil {
+		return "", "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(tmp) }
+	// Clone under the repository's name, which -output's {name} uses.
+	clone := filepath.Join(tmp, r.name())
+	if err := os.Mkdir(clone, 0o755); err != nil {
+		cleanup()
+		return "", "", nil, err
+	}
+
+	ref := r.ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", r.url},
+		{"fetch", "--quiet", "--depth", "1", "origin", ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", clone}, args...)...)
+		// Fail rather than wait for a password nobody types.
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			cleanup()
+			if msg := strings.TrimSpace(string(out)); msg != "" {
+				return "", "", nil, fmt.Errorf("git %s: %s", args[0], msg)
+			}
+			return "", "", nil, fmt.Errorf("git %s: %w", args[0], err)
+		}
+	}
+	if commit, err = gitOutput(clone, "rev-parse", "HEAD"); err != nil {
+		cleanup()
+		return "", "", nil, err
+	}
+
+	dir = filepath.Join(clone, filepath.FromSlash(r.subdir))
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		cleanup()
+		return "", "", nil, fmt.Errorf("'%s' is not a directory of the repository", r.subdir)
+	}
+	return dir, commit, cleanup, nil
+}
@@ -0,0 +1,192 @@
+// project-bundler/remoteconfig.go
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// remoteConfigTTL is how long a fetched shared config is used before it is
+// fetched again. Pinned configs never expire.
+const remoteConfigTTL = time.Hour
+
+// remoteConfigMaxSize caps the size of a shared config.
+const remoteConfigMaxSize = 1 << 20
+
+// extendRemote resolves the URLs among the entries of config's extends: each
+// names a shared config, such as an organization's defaults, optionally
+// pinned to its SHA-256 with a "#sha256=HEX" suffix. The shared configs
+// become config's bases, in the order named, and fill in whatever config
+// does not set itself: presets to extend, scalars, map entries, remove:
+// lists and onboard sections. Their ignore lists stay separate so each rule
+// keeps the URL it came from. seen holds the URLs being resolved, so a
+// shared config extending another cannot loop.
+func extendRemote(config localConfig, seen []string) (localConfig, error) {
+	presets, refs := splitExtends(config.Extends)
+	if len(refs) == 0 {
+		return config, nil
+	}
+	config.Extends = strings.Join(presets, ",")
+	for _, ref := range refs {
+		rawURL, _, _ := strings.Cut(ref, "#")
+		for _, s := range seen {
+			if s == rawURL {
+				return config, fmt.Errorf("%s: 'extends' loops back to %s", config.file, rawURL)
+			}
+		}
+		data, err := fetchRemoteConfig(ref)
+		if err != nil {
+			return config, fmt.Errorf("%s: %w", config.file, err)
+		}
+		base, err := parseLocalConfig(bytes.NewReader(data), rawURL)
+		if err == nil {
+			base, err = extendRemote(base, append(seen, rawURL))
+		}
+		if err != nil {
+			return config, err
+		}
+		if config.Extends == "" {
+			config.Extends = base.Extends
+		}
+		config.Override = config.Override || base.Override
+		for _, s := range []struct{ local, base *string }{{&config.Style, &base.Style}, {&config.Output, &base.Output}, {&config.OutputDir, &base.OutputDir}} {
+			if *s.local == "" {
+				*s.local = *s.base
+			}
+		}
+		for _, m := range []struct{ local, base *map[string]string }{{&config.LangMap, &base.LangMap}, {&config.Budgets, &base.Budgets}, {&config.Classes, &base.Classes}} {
+			merged := maps.Clone(*m.base)
+			if merged == nil {
+				merged = make(map[string]string)
+			}
+			maps.Copy(merged, *m.local)
+			if len(merged) > 0 {
+				*m.local = merged
+			}
+		}
+		config.RemoveDirs = append(config.RemoveDirs, base.RemoveDirs...)
+		config.RemoveExts = append(config.RemoveExts, base.RemoveExts...)
+		config.RemovePaths = append(config.RemovePaths, base.RemovePaths...)
+		if len(config.OnboardSections) == 0 {
+			config.OnboardSections = base.OnboardSections
+		}
+		config.bases = append(config.bases, base.layers()...)
+	}
+	return config, nil
+}
+
+// splitExtends splits the entries of extends into preset names and the
+// URLs of shared configs.
+func splitExtends(extends string) (presets, refs []string) {
+	for _, entry := range strings.Split(extends, ",") {
+		switch entry = strings.TrimSpace(entry); {
+		case entry == "":
+		case strings.HasPrefix(entry, "https://"), strings.HasPrefix(entry, "http://"):
+			refs = append(refs, entry)
+		default:
+			presets = append(presets, entry)
+		}
+	}
+	return presets, refs
+}
+
+// fetchRemoteConfig returns the content of a shared config. Pinned configs
+// are read from the cache when it has the pinned content, and fetched
+// otherwise; unpinned ones are fetched once remoteConfigTTL has passed, and
+// when that fails the stale copy is used with a warning. Plain http is only
+// accepted with a pin, which the content must match either way.
+func fetchRemoteConfig(ref string) ([]byte, error) {
+	rawURL, fragment, _ := strings.Cut(ref, "#")
+	pin, pinned := strings.CutPrefix(fragment, "sha256=")
+	if fragment != "" && !pinned {
+		return nil, fmt.Errorf("invalid pin '#%s' in %s: use #sha256=HEX", fragment, rawURL)
+	}
+	pin = strings.ToLower(pin)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL in 'extends': %w", err)
+	}
+	if u.Scheme == "http" && !pinned {
+		return nil, fmt.Errorf("%s: plain http configs must be pinned with #sha256=HEX", rawURL)
+	}
+	matches := func(data []byte) bool {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:]) == pin
+	}
+
+	cacheFile := remoteConfigCachePath(rawURL)
+	cached, cacheErr := os.ReadFile(cacheFile)
+	if cacheErr == nil {
+		if pinned && matches(cached) {
+			return cached, nil
+		}
+		if info, err := os.Stat(cacheFile); !pinned && err == nil && time.Since(info.ModTime()) < remoteConfigTTL {
+			return cached, nil
+		}
+	}
+
+	data, err := getRemoteConfig(rawURL)
+	if err != nil {
+		if cacheErr == nil && !pinned {
+			log.Printf("Warning: could not fetch %s, using the copy cached %s: %v", rawURL, cacheFileAge(cacheFile), err)
+			return cached, nil
+		}
+		return nil, fmt.Errorf("could not fetch %s: %w", rawURL, err)
+	}
+	if pinned && !matches(data) {
+		sum := sha256.Sum256(data)
+		return nil, fmt.Errorf("%s has SHA-256 %s, not the pinned %s", rawURL, hex.EncodeToString(sum[:]), pin)
+	}
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0o755); err == nil {
+		os.WriteFile(cacheFile, data, 0o644)
+	}
+	return data, nil
+}
+
+// getRemoteConfig downloads a shared config.
+func getRemoteConfig(rawURL string) ([]byte, error) {
+	resp, err := httpClient.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, remoteConfigMaxSize+1))
+	if err == nil && len(data) > remoteConfigMaxSize {
+		err = fmt.Errorf("larger than %s", formatSize(remoteConfigMaxSize))
+	}
+	return data, err
+}
+
+// remoteConfigCachePath returns where the shared config at a URL is cached:
+// in the user's cache directory, named by the URL's hash.
+func remoteConfigCachePath(rawURL string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(dir, "project-bundler", "config", hex.EncodeToString(sum[:8])+".yaml")
+}
+
+// cacheFileAge describes how long ago a cache file was written.
+func cacheFileAge(file string) string {
+	info, err := os.Stat(file)
+	if err != nil {
+		return "earlier"
+	}
+	return time.Since(info.ModTime()).Round(time.Minute).String() + " ago"
+}
@@ -0,0 +1,91 @@
+// project-bundler/result.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// resultVersion is the version of the RESULT line. Keys may be added to a
+// version, at the end; removing, renaming or reordering them makes a new one.
+const resultVersion = 1
+
+// resultPath is what the RESULT line reports as the output when the run
+// fails, and in place of the temporary file behind -output - ("-") and
+// -clipboard ("clipboard").
+var resultPath = "bundle.md"
+
+// writeResultLine ends a run of the bundle command, or of a subcommand that
+// writes a bundle (onboard, incident, compact), with a line on stderr for
+// the scripts that wrap it, in every mode and however the run ends:
+//
+//	RESULT v=1 status=ok path=bundle.md files=135 skipped=12 bytes=1034211 tokens=234346
+//
+// status is ok, partial (the watchdog stopped the run early) or failed, and
+// the latter two add why as reason. tokens is 0 when no tokenizer was set.
+// Values with spaces, quotes, backslashes, equals signs or control
+// characters are quoted as JSON strings.
+func writeResultLine(path string, result bundleResult, err error) {
+	status, reason := "ok", ""
+	switch {
+	case err != nil:
+		status, reason = "failed", err.Error()
+	case result.truncated != "":
+		status, reason = "partial", result.truncated
+	}
+	line := fmt.Sprintf("RESULT v=%d status=%s path=%s files=%d skipped=%d bytes=%d tokens=%d",
+		resultVersion, status, resultValue(path), result.filesBundled, result.filesSkipped, result.bytesWritten, result.tokens)
+	if reason != "" {
+		line += " reason=" + resultValue(reason)
+	}
+	fmt.Fprintln(os.Stderr, line)
+}
+
+func resultValue(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \"\\=") && strings.IndexFunc(s, unicode.IsControl) < 0 {
+		return s
+	}
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// countSkipped counts the files of a skipped report.
+func countSkipped(skipped map[string][]string) int {
+	n := 0
+	for _, paths := range skipped {
+		n += len(paths)
+	}
+	return n
+}
+
+// exitCleanups remove what the bundle command made on its way, such as the
+// clones of remote -src roots, the checkout of -at and the temporary bundle
+// of -output - and -clipboard. They run once the bundle is written or, as
+// os.Exit skips deferred calls, in fatalf.
+var exitCleanups []func()
+
+func runExitCleanups() {
+	for _, c := range exitCleanups {
+		c()
+	}
+	exitCleanups = nil
+}
+
+// fatalf logs why the bundle command (or a subcommand that writes a
+// bundle) cannot go on and exits, like
+// log.Fatalf, after the cleanups and the RESULT line.
+func fatalf(format string, args ...any) {
+	runExitCleanups()
+	msg := fmt.Sprintf(format, args...)
+	log.Print(msg)
+	writeResultLine(resultPath, bundleResult{}, errors.New(msg))
+	os.Exit(1)
+}
@@ -0,0 +1,139 @@
+// project-bundler/revision.go
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitOutput runs a git command in dir and returns its trimmed stdout.
+func gitOutput(dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// resolveRevision turns a commit, tag, branch or date into a commit hash.
+// Dates resolve to the last commit on HEAD made before that point in time.
+func resolveRevision(srcDir, rev string) (string, error) {
+	if commit, err := gitOutput(srcDir, "rev-parse", "--verify", "--quiet", rev+"^{commit}"); err == nil {
+		return commit, nil
+	}
+	commit, err := gitOutput(srcDir, "rev-list", "-1", "--before="+rev, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if commit == "" {
+		return "", fmt.Errorf("'%s' is neither a known revision nor a date with commits before it", rev)
+	}
+	return commit, nil
+}
+
+// checkoutRevision extracts the tree of srcDir as of rev into a temporary
+// directory using `git archive`, leaving the working directory untouched.
+// It also returns the resolved commit. The caller must call cleanup once
+// bundling is done.
+func checkoutRevision(srcDir, rev string) (dir, commit string, cleanup func(), err error) {
+	commit, err = resolveRevision(srcDir, rev)
+	if err != nil {
+		return "", "", nil, err
+	}
+	// Archive only the part of the repository that -src points at.
+	prefix, err := gitOutput(srcDir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	dir, err = os.MkdirTemp("", "project-bundler-at-*")
+	if err != nil {
+		return "", "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+
+	cmd := exec.Command("git", "-C", srcDir, "archive", "--format=tar", commit+":"+prefix)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cleanup()
+		return "", "", nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cleanup()
+		return "", "", nil, err
+	}
+	extractErr := extractTar(stdout, dir)
+	io.Copy(io.Discard, stdout) // Let git finish if extraction stopped early.
+	if err := cmd.Wait(); err != nil {
+		cleanup()
+		return "", "", nil, fmt.Errorf("git archive: %s", strings.TrimSpace(stderr.String()))
+	}
+	if extractErr != nil {
+		cleanup()
+		return "", "", nil, extractErr
+	}
+	return dir, commit, cleanup, nil
+}
+
+// extractTar writes regular files, directories and symlinks from r into dir.
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(hdr.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("archive entry '%s' escapes the snapshot directory", hdr.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			_, copyErr := io.Copy(f, tr)
+			if err := f.Close(); err != nil && copyErr == nil {
+				copyErr = err
+			}
+			if copyErr != nil {
+				return copyErr
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// project-bundler/roots.go
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sourceRoot is one -src directory of a run that bundles several.
+type sourceRoot struct {
+	dir    string
+	prefix string        // Slash-separated directory its files appear under.
+	preset bundleOptions // Its project type's ignore rules and languages.
+}
+
+// resolveSourceRoots pairs the -src directories with their -src-prefix,
+// defaulting to each directory's base name, and resolves the project type
+// of every root after the first, whose options the caller already has. A
+// single root has no prefix, so its paths stay as they were.
+func resolveSourceRoots(dirs, prefixes []string, projectType, ignoreDirsStr, ignoreExtsStr string, noDefaultIgnores bool) ([]sourceRoot, error) {
+	if len(prefixes) > len(dirs) {
+		return nil, fmt.Errorf("%d -src-prefix values for %d -src roots", len(prefixes), len(dirs))
+	}
+	if len(dirs) == 1 && len(prefixes) == 0 {
+		return []sourceRoot{{dir: dirs[0]}}, nil
+	}
+	roots := make([]sourceRoot, len(dirs))
+	seen := make(map[string]string)
+	for i, dir := range dirs {
+		prefix := ""
+		if i < len(prefixes) {
+			prefix = prefixes[i]
+		} else if abs, err := filepath.Abs(dir); err == nil {
+			prefix = filepath.Base(abs)
+		}
+		prefix = strings.Trim(path.Clean("/"+filepath.ToSlash(prefix)), "/")
+		if other, dup := seen[prefix]; dup {
+			return nil, fmt.Errorf("'%s' and '%s' would both be bundled under '%s/'; give each its own -src-prefix", other, dir, prefix)
+		}
+		seen[prefix] = dir
+		roots[i] = sourceRoot{dir: dir, prefix: prefix}
+		if i == 0 {
+			continue
+		}
+		preset, err := resolveOptions(dir, projectType, ignoreDirsStr, ignoreExtsStr, noDefaultIgnores)
+		if err != nil {
+			return nil, err
+		}
+		roots[i].preset = preset
+	}
+	return roots, nil
+}
+
+// collectRootFiles walks a further -src root with the project's options,
+// but its own project type's rules, and places its files under the root's
+// prefix.
+func collectRootFiles(opts bundleOptions, root sourceRoot) ([]fileEntry, map[string][]string, error) {
+	p := root.preset
+	opts.SrcDir = root.dir
+	opts.ProjectType = p.ProjectType
+	opts.IgnoreDirs, opts.IgnoreExts, opts.IgnoreSuffixes, opts.IgnorePaths = p.IgnoreDirs, p.IgnoreExts, p.IgnoreSuffixes, p.IgnorePaths
+	opts.LangMap, opts.GitExcludes = p.LangMap, p.GitExcludes
+	opts.Only = nil // Allowlists are written for the first root's paths.
+	files, skipped, err := collectFiles(opts)
+	prefixFiles(files, root.prefix)
+	return files, skipped, err
+}
+
+// prefixFiles moves files under a directory of the bundle.
+func prefixFiles(files []fileEntry, prefix string) {
+	if prefix == "" {
+		return
+	}
+	for i := range files {
+		files[i].RelPath = filepath.Join(filepath.FromSlash(prefix), files[i].RelPath)
+	}
+}
@@ -0,0 +1,151 @@
+// project-bundler/runreport.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runMetadata describes a bundle: -metadata puts it at the top of the
+// Markdown, and -report-json starts with it.
+type runMetadata struct {
+	Version   string    `json:"version"`
+	Generated time.Time `json:"generated"`
+	Source    string    `json:"source"`
+	Preset    string    `json:"preset"`
+	Commit    string    `json:"commit,omitempty"`
+	Files     int       `json:"files"`
+	Bytes     int64     `json:"bytes"` // Of the bundled files, before any transformation.
+	Tokens    int       `json:"tokens,omitempty"`
+	Tokenizer string    `json:"tokenizer,omitempty"`
+}
+
+// runReport is what -report-json writes: everything about a run that CI
+// would otherwise have to parse from the console.
+type runReport struct {
+	runMetadata
+	Outputs    []string       `json:"outputs"`
+	Truncated  string         `json:"truncated,omitempty"` // Why the watchdog stopped the run early.
+	DurationMS int64          `json:"duration_ms"`
+	Included   []reportFile   `json:"included"`
+	Skipped    []reportSkip   `json:"skipped"`
+	ByReason   map[string]int `json:"skipped_by_reason"`
+}
+
+type reportFile struct {
+	Path       string `json:"path"`
+	Language   string `json:"language"`
+	Size       int64  `json:"size"`
+	Tokens     int    `json:"tokens,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`   // Over -max-file-size, bundled with -truncate.
+	OverBudget bool   `json:"over_budget,omitempty"` // Outlined or truncated to fit a budget.
+	Class      string `json:"classification,omitempty"`
+}
+
+type reportSkip struct {
+	Path        string `json:"path"`
+	Reason      string `json:"reason"` // A reason code, as in -report-skipped.
+	Description string `json:"description"`
+}
+
+// collectMetadata gathers the metadata of a finished run.
+func collectMetadata(opts bundleOptions, result bundleResult) runMetadata {
+	m := runMetadata{
+		Version:   version,
+		Generated: result.generated,
+		Source:    opts.srcLabel,
+		Preset:    opts.ProjectType,
+		Files:     result.filesBundled,
+		Tokens:    result.tokens,
+	}
+	if m.Source == "" {
+		m.Source = opts.SrcDir
+	}
+	if commit, err := gitOutput(opts.SrcDir, "rev-parse", "HEAD"); err == nil {
+		m.Commit = commit
+	}
+	for _, f := range result.bundled {
+		m.Bytes += f.Size
+	}
+	if opts.tokenizer != nil {
+		m.Tokenizer = opts.tokenizer.name()
+	}
+	return m
+}
+
+// header renders the metadata as the YAML front matter -metadata puts at the
+// top of the bundle.
+func (m runMetadata) header() string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "bundler: project-bundler %s\n", m.Version)
+	fmt.Fprintf(&b, "generated: %s\n", m.Generated.Format(time.RFC3339))
+	fmt.Fprintf(&b, "source: %q\n", m.Source)
+	fmt.Fprintf(&b, "preset: %s\n", m.Preset)
+	if m.Commit != "" {
+		fmt.Fprintf(&b, "commit: %s\n", m.Commit)
+	}
+	fmt.Fprintf(&b, "files: %d\n", m.Files)
+	fmt.Fprintf(&b, "bytes: %d\n", m.Bytes)
+	if m.Tokenizer != "" {
+		fmt.Fprintf(&b, "tokens: %d  # %s tokenizer\n", m.Tokens, m.Tokenizer)
+	}
+	b.WriteString("---\n\n")
+	return b.String()
+}
+
+// writeRunReport writes the -report-json file for a finished run.
+func writeRunReport(path string, opts bundleOptions, result bundleResult) error {
+	report := runReport{
+		runMetadata: collectMetadata(opts, result),
+		Outputs:     result.outputs,
+		Truncated:   result.truncated,
+		DurationMS:  time.Since(result.generated).Milliseconds(),
+		Included:    []reportFile{},
+		Skipped:     []reportSkip{},
+		ByReason:    result.skipped,
+	}
+	tokens := make(map[string]int, len(result.fileTokens))
+	for _, t := range result.fileTokens {
+		tokens[t.Path] = t.Tokens
+	}
+	truncated := make(stringSet)
+	for _, p := range result.shortened {
+		truncated[p] = struct{}{}
+	}
+	overBudget := make(stringSet)
+	for _, p := range result.overBudget {
+		overBudget[p] = struct{}{}
+	}
+	for _, f := range result.bundled {
+		file := reportFile{
+			Path:       filepath.ToSlash(f.RelPath),
+			Language:   f.Lang,
+			Size:       f.Size,
+			Tokens:     tokens[f.RelPath],
+			Truncated:  truncated.Contains(f.Path),
+			OverBudget: overBudget.Contains(f.Path),
+		}
+		if level, ok := result.classes[f.Path]; ok {
+			file.Class = level.String()
+		}
+		report.Included = append(report.Included, file)
+	}
+	for reason, paths := range result.skippedPaths {
+		for _, p := range paths {
+			report.Skipped = append(report.Skipped, reportSkip{Path: filepath.ToSlash(p), Reason: reason, Description: reasonDescription(reason)})
+		}
+	}
+	sort.Slice(report.Skipped, func(i, j int) bool { return report.Skipped[i].Path < report.Skipped[j].Path })
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,130 @@
+// project-bundler/schema.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// schemaFileNames are dumps of the live schema maintained by common tools
+// (Rails, dbmate, pg_dump conventions). When present they win over migrations.
+var schemaFileNames = stringSet{"schema.sql": {}, "structure.sql": {}}
+
+// migrationDirNames are directory names that hold ordered SQL migrations.
+var migrationDirNames = stringSet{"migrations": {}, "migrate": {}, "migration": {}, "db_migrations": {}}
+
+var (
+	// downMarker starts the rollback half of single-file migrations
+	// (goose, dbmate, sql-migrate).
+	downMarker = regexp.MustCompile(`(?mi)^--\s*(?:\+goose\s+down|migrate:down|\+migrate\s+down)\b`)
+	// ddlStatement matches the statements that shape the schema.
+	ddlStatement = regexp.MustCompile(`(?i)^\s*(?:create|alter|drop|comment\s+on|rename)\b`)
+)
+
+// writeSchemaSection emits a consolidated "current schema" block: the live
+// schema file if the project has one, otherwise the DDL of all up-migrations
+// in filename order. Nothing is written when neither is found.
+func writeSchemaSection(w io.Writer, files []fileEntry) error {
+	var schemaFiles []fileEntry
+	migrations := make(map[string][]fileEntry)
+	for _, f := range files {
+		if f.Placeholder || !strings.EqualFold(filepath.Ext(f.RelPath), ".sql") {
+			continue
+		}
+		rel := filepath.ToSlash(f.RelPath)
+		switch {
+		case schemaFileNames.Contains(path.Base(rel)):
+			schemaFiles = append(schemaFiles, f)
+		case migrationDirNames.Contains(path.Base(path.Dir(rel))) && !isDownMigration(rel):
+			migrations[path.Dir(rel)] = append(migrations[path.Dir(rel)], f)
+		}
+	}
+
+	if len(schemaFiles) > 0 {
+		f := schemaFiles[0]
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			return nil // Reported when the file itself is bundled.
+		}
+		return writeSchemaBlock(w, fmt.Sprintf("from /%s", filepath.ToSlash(f.RelPath)), content)
+	}
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	// Use the directory with the most migrations; projects rarely have two.
+	var dir string
+	for d, ms := range migrations {
+		if dir == "" || len(ms) > len(migrations[dir]) || (len(ms) == len(migrations[dir]) && d < dir) {
+			dir = d
+		}
+	}
+	ms := migrations[dir]
+	sort.Slice(ms, func(i, j int) bool { return ms[i].RelPath < ms[j].RelPath })
+
+	var b strings.Builder
+	for _, f := range ms {
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			continue
+		}
+		ddl := migrationDDL(string(content))
+		if ddl == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "-- %s\n%s\n", path.Base(filepath.ToSlash(f.RelPath)), ddl)
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	return writeSchemaBlock(w, fmt.Sprintf("consolidated from %d migrations in /%s", len(ms), dir), []byte(b.String()))
+}
+
+func writeSchemaBlock(w io.Writer, source string, content []byte) error {
+	fence := strings.Repeat("`", bundler.FenceLength(content, '`'))
+	_, err := fmt.Fprintf(w, "Current database schema (%s):\n%ssql\n%s\n%s\n\n", source, fence, strings.TrimRight(string(content), "\n"), fence)
+	return err
+}
+
+// isDownMigration reports whether a file only holds a rollback, following
+// the "<version>_name.down.sql" convention of golang-migrate and others.
+func isDownMigration(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".down.sql")
+}
+
+// migrationDDL returns the schema-changing statements of a migration's up
+// half, dropping data manipulation and the rollback section.
+func migrationDDL(sql string) string {
+	if loc := downMarker.FindStringIndex(sql); loc != nil {
+		sql = sql[:loc[0]]
+	}
+	var out []string
+	for _, stmt := range strings.Split(sql, ";") {
+		stmt = strings.TrimSpace(stripSQLComments(stmt))
+		if ddlStatement.MatchString(stmt) {
+			out = append(out, stmt+";")
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// stripSQLComments removes whole-line "--" comments so that a statement's
+// leading keyword can be recognized.
+func stripSQLComments(stmt string) string {
+	lines := strings.Split(stmt, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,145 @@
+// project-bundler/scrub.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Placeholders of the names -template-scrub auto finds.
+const (
+	scrubModulePath = "__MODULE_PATH__"
+	scrubAppName    = "__APP_NAME__"
+)
+
+// parseTemplateScrub parses a -template-scrub value: comma-separated
+// NAME=PLACEHOLDER pairs, where "auto" stands for the project's module path
+// and app name. Longer names are replaced first, so a module path is not
+// cut apart by the app name it ends in.
+func parseTemplateScrub(s, srcDir string) (*strings.Replacer, error) {
+	names := make(map[string]string)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "auto" {
+			for name, placeholder := range projectNames(srcDir) {
+				if _, ok := names[name]; !ok {
+					names[name] = placeholder
+				}
+			}
+			continue
+		}
+		name, placeholder, ok := strings.Cut(item, "=")
+		if !ok || name == "" || placeholder == "" || name == placeholder {
+			return nil, fmt.Errorf("invalid mapping '%s': use NAME=PLACEHOLDER, e.g. acme-shop=__APP_NAME__, or auto", item)
+		}
+		names[name] = placeholder
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("auto found no module path or app name in %s; give the names as NAME=PLACEHOLDER", srcDir)
+	}
+	order := make([]string, 0, len(names))
+	for name := range names {
+		order = append(order, name)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if len(order[i]) != len(order[j]) {
+			return len(order[i]) > len(order[j])
+		}
+		return order[i] < order[j]
+	})
+	var pairs []string
+	for _, name := range order {
+		pairs = append(pairs, name, names[name])
+	}
+	return strings.NewReplacer(pairs...), nil
+}
+
+// projectNames finds the names that identify a project: the module path of
+// its go.mod and, as app name, the last element of that path, the name in
+// its package.json or Cargo.toml, or else the name of its directory.
+func projectNames(srcDir string) map[string]string {
+	names := make(map[string]string)
+	app := ""
+	if f, err := os.Open(filepath.Join(srcDir, "go.mod")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if module, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+				module = strings.Trim(strings.TrimSpace(module), `"`)
+				names[module] = scrubModulePath
+				app = path.Base(module)
+				break
+			}
+		}
+		f.Close()
+	}
+	if app == "" {
+		if data, err := os.ReadFile(filepath.Join(srcDir, "package.json")); err == nil {
+			var pkg struct{ Name string }
+			if json.Unmarshal(data, &pkg) == nil {
+				app = pkg.Name
+			}
+		}
+	}
+	if app == "" {
+		if data, err := os.ReadFile(filepath.Join(srcDir, "Cargo.toml")); err == nil {
+			inPackage := false
+			for _, line := range strings.Split(string(data), "\n") {
+				line = strings.TrimSpace(line)
+				if strings.HasPrefix(line, "[") {
+					inPackage = line == "[package]"
+				} else if key, value, ok := strings.Cut(line, "="); ok && inPackage && strings.TrimSpace(key) == "name" {
+					app = strings.Trim(strings.TrimSpace(value), `"'`)
+					break
+				}
+			}
+		}
+	}
+	if app == "" {
+		if abs, err := filepath.Abs(srcDir); err == nil && filepath.Dir(abs) != abs {
+			app = filepath.Base(abs)
+		}
+	}
+	if app != "" {
+		names[app] = scrubAppName
+	}
+	return names
+}
+
+// scrubWriter replaces project-specific names in the Markdown on its way to
+// w. It works a line at a time, since names do not span lines, and holds
+// back the text after the last newline until the next write or Flush. The
+// block boundaries of -split-tokens and -source-map fall after a newline,
+// so nothing is held back there.
+type scrubWriter struct {
+	w        io.Writer
+	replacer *strings.Replacer
+	held     []byte
+}
+
+func (s *scrubWriter) Write(p []byte) (int, error) {
+	s.held = append(s.held, p...)
+	end := bytes.LastIndexByte(s.held, '\n') + 1
+	if end == 0 {
+		return len(p), nil
+	}
+	if _, err := s.replacer.WriteString(s.w, string(s.held[:end])); err != nil {
+		return 0, err
+	}
+	s.held = append(s.held[:0], s.held[end:]...)
+	return len(p), nil
+}
+
+// Flush writes the text held back after the last newline.
+func (s *scrubWriter) Flush() error {
+	_, err := s.replacer.WriteString(s.w, string(s.held))
+	s.held = s.held[:0]
+	return err
+}
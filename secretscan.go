@@ -0,0 +1,60 @@
+// project-bundler/secretscan.go
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// secretHit is a likely credential found in a bundled file by
+// -redact-secrets or -fail-on-secrets.
+type secretHit struct {
+	relPath string
+	bundler.SecretFinding
+}
+
+// scanForSecrets checks content when a secret scan was requested. With
+// -redact-secrets it returns the content with the findings replaced;
+// otherwise content is returned as is.
+func scanForSecrets(opts bundleOptions, f fileEntry, content []byte, hits *[]secretHit) []byte {
+	if !opts.redactSecrets && !opts.failOnSecrets {
+		return content
+	}
+	findings := bundler.ScanSecrets(f.RelPath, content)
+	if len(opts.detectors) > 0 {
+		findings = mergeFindings(findings, detectWithPlugins(opts, f, content))
+	}
+	for _, finding := range findings {
+		*hits = append(*hits, secretHit{f.RelPath, finding})
+	}
+	if opts.redactSecrets && len(findings) > 0 {
+		return bundler.RedactSecrets(content, findings)
+	}
+	return content
+}
+
+// mergeFindings adds the findings of -detect plugins to the built-in ones,
+// in content order, dropping any that overlap one before it.
+func mergeFindings(findings, more []bundler.SecretFinding) []bundler.SecretFinding {
+	all := append(findings, more...)
+	slices.SortStableFunc(all, func(a, b bundler.SecretFinding) int { return a.Start - b.Start })
+	var merged []bundler.SecretFinding
+	for _, f := range all {
+		if len(merged) > 0 && f.Start < merged[len(merged)-1].End {
+			continue
+		}
+		merged = append(merged, f)
+	}
+	return merged
+}
+
+// printSecretReport lists the findings of the secret scan with their
+// locations. The secrets themselves are never printed.
+func printSecretReport(key string, hits []secretHit) {
+	printMsg(key, len(hits))
+	for _, h := range hits {
+		fmt.Printf("  - %s:%d: %s\n", h.relPath, h.Line, h.Rule)
+	}
+}
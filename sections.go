@@ -0,0 +1,166 @@
+// project-bundler/sections.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Annotation sections are appended to a finished bundle between marker lines.
+// The markers are HTML comments, so renderers hide them, and a section is
+// only recognized when both markers stand on lines of their own.
+const (
+	sectionStartMarker = "<!-- project-bundler:section %q -->"
+	sectionEndMarker   = "<!-- /project-bundler:section -->"
+)
+
+// bundleSection is a named annotation section found in a bundle.
+type bundleSection struct {
+	name       string
+	start, end int // Line indexes of the start and end markers.
+}
+
+// runAnnotate implements the `annotate` subcommand, which lets external tools
+// attach named sections (coverage reports, review notes, ...) to an existing
+// bundle:
+//
+//	project-bundler annotate add -section Coverage -file cov.md bundle.md
+//	project-bundler annotate list bundle.md
+//	project-bundler annotate remove -section Coverage bundle.md
+func runAnnotate(args []string) {
+	if len(args) == 0 {
+		log.Fatalf("Usage: project-bundler annotate add|list|remove [flags] <bundle>")
+	}
+	fs := flag.NewFlagSet("annotate "+args[0], flag.ExitOnError)
+	section := fs.String("section", "", "Name of the annotation section.")
+	file := fs.String("file", "-", "File with the section's Markdown content; - reads standard input. Only used by add.")
+	fs.Parse(args[1:])
+	if fs.NArg() != 1 {
+		log.Fatalf("Expected exactly one bundle file, got %d", fs.NArg())
+	}
+	bundlePath := fs.Arg(0)
+
+	data, err := os.ReadFile(bundlePath)
+	if err != nil {
+		log.Fatalf("Could not read bundle: %v", err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	sections := findSections(lines)
+
+	switch args[0] {
+	case "list":
+		for _, s := range sections {
+			fmt.Printf("%s (%d lines)\n", s.name, s.end-s.start-1)
+		}
+		return
+	case "add", "remove":
+		if *section == "" {
+			log.Fatalf("-section is required")
+		}
+	default:
+		log.Fatalf("Unknown annotate command '%s'. Use add, list, or remove.", args[0])
+	}
+
+	// Drop an existing section of the same name, so add replaces it in place
+	// of duplicating it.
+	at := -1
+	for _, s := range sections {
+		if s.name == *section {
+			lines = append(lines[:s.start], lines[s.end+1:]...)
+			at = s.start
+			break
+		}
+	}
+	if args[0] == "remove" {
+		if at < 0 {
+			log.Fatalf("No section named %q in '%s'", *section, bundlePath)
+		}
+		if err := replaceFile(bundlePath, strings.Join(lines, "")); err != nil {
+			log.Fatalf("Could not write bundle: %v", err)
+		}
+		fmt.Printf("Removed section %q from '%s'\n", *section, bundlePath)
+		return
+	}
+
+	var content []byte
+	if *file == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		log.Fatalf("Could not read section content: %v", err)
+	}
+	block := formatSection(*section, string(content))
+	if at < 0 {
+		if n := len(lines); n > 0 && lines[n-1] != "" && !strings.HasSuffix(lines[n-1], "\n") {
+			lines[n-1] += "\n"
+		}
+		lines = append(lines, block)
+	} else {
+		lines = append(lines[:at], append([]string{block}, lines[at:]...)...)
+	}
+	if err := replaceFile(bundlePath, strings.Join(lines, "")); err != nil {
+		log.Fatalf("Could not write bundle: %v", err)
+	}
+	fmt.Printf("Added section %q to '%s'\n", *section, bundlePath)
+}
+
+// formatSection renders a section with its markers and a heading.
+func formatSection(name, content string) string {
+	content = strings.TrimRight(content, "\n")
+	return fmt.Sprintf(sectionStartMarker+"\n## %s\n\n%s\n"+sectionEndMarker+"\n", name, name, content)
+}
+
+// findSections locates the annotation sections in a bundle split into lines.
+// A start marker without a matching end marker is ignored.
+func findSections(lines []string) []bundleSection {
+	var sections []bundleSection
+	var open *bundleSection
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r\n")
+		var name string
+		if _, err := fmt.Sscanf(line, sectionStartMarker, &name); err == nil && fmt.Sprintf(sectionStartMarker, name) == line {
+			open = &bundleSection{name: name, start: i}
+			continue
+		}
+		if line == sectionEndMarker && open != nil {
+			open.end = i
+			sections = append(sections, *open)
+			open = nil
+		}
+	}
+	return sections
+}
+
+// replaceFile writes data to a temporary file next to path and renames it
+// over path, so a failed write never leaves a half-written bundle behind.
+// The file keeps its permissions.
+func replaceFile(path, data string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".project-bundler-annotate-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.WriteString(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,508 @@
+// project-bundler/serve.go
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// bundleRequest holds the options of a POST /bundle request: the JSON body,
+// or the "options" field of a multipart upload.
+type bundleRequest struct {
+	Src     string   `json:"src"`  // Directory on the server, under one of -allow-src.
+	Type    string   `json:"type"` // Project type; "" auto-detects it.
+	Style   string   `json:"style"`
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+// bundleFormats are the representations POST /bundle can answer with, by
+// media type, the first the default.
+var bundleFormats = []string{"text/markdown", "application/json", "application/zip"}
+
+// bundleJSON is the application/json representation of a bundle.
+type bundleJSON struct {
+	Files   []jsonFile          `json:"files"`
+	Skipped map[string][]string `json:"skipped"`          // Relative paths by reason code.
+	Tokens  int                 `json:"tokens,omitempty"` // Estimate of the Markdown bundle, with a budget.
+}
+
+// servedFiles collects the files of a bundle for the JSON and zip answers.
+type servedFiles struct {
+	bundler.NopObserver
+	files []bundler.RenderedFile
+}
+
+func (s *servedFiles) OnFileIncluded(r bundler.RenderedFile) {
+	s.files = append(s.files, r)
+}
+
+// presetInfo describes a preset for GET /presets.
+type presetInfo struct {
+	Name        string   `json:"name"`
+	IgnoreDirs  []string `json:"ignoreDirs"`
+	IgnoreExts  []string `json:"ignoreExts"`
+	IgnorePaths []string `json:"ignorePaths,omitempty"`
+	Languages   []string `json:"languages"`
+}
+
+// bundleServer serves bundling over HTTP.
+type bundleServer struct {
+	allowSrc  []string      // Absolute directories POST /bundle may read; none disables src.
+	maxUpload int64         // Largest request body, and largest expanded archive.
+	timeout   time.Duration // Per bundle.
+	slots     chan struct{} // One token per bundle that may run at once.
+	tenants   []*tenant     // Clients by token, from -auth; nil lets every request through.
+	audit     *auditLog     // Nil when -audit-log is off.
+}
+
+// runServe implements the `serve` subcommand, which runs bundling as an
+// HTTP service for CI bots and chat assistants:
+//
+//	POST /bundle   bundle a directory on the server or an uploaded archive
+//	GET  /presets  list the presets and their rules
+//
+// With -auth, every request needs the bearer token of a tenant, which
+// limits the repositories it may bundle, whether it may upload archives and
+// how many bundles it may request per hour.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "Address to listen on.")
+	allowSrc := fs.String("allow-src", "", "Comma-separated directories whose subdirectories requests may bundle by path. Empty accepts only uploaded archives.")
+	concurrency := fs.Int("max-concurrent", 4, "Bundles built at once; further requests get 503 Service Unavailable.")
+	maxUploadStr := fs.String("max-upload", "100MB", "Largest request body, and largest total size an uploaded archive may expand to.")
+	timeout := fs.Duration("timeout", 2*time.Minute, "Time limit of each bundle.")
+	authPath := fs.String("auth", "", "JSON file of tenants: their tokens, the directories each may bundle, whether it may upload archives and its hourly quota. Empty serves everyone.")
+	auditPath := fs.String("audit-log", "-", "File to append a JSON line to for every request; - is stderr, empty disables it.")
+	fs.Parse(args)
+
+	maxUpload, err := parseByteSize(*maxUploadStr)
+	if err != nil || maxUpload <= 0 {
+		log.Fatalf("Invalid -max-upload '%s'", *maxUploadStr)
+	}
+	if *concurrency < 1 {
+		log.Fatalf("-max-concurrent must be at least 1")
+	}
+	s := &bundleServer{maxUpload: maxUpload, timeout: *timeout, slots: make(chan struct{}, *concurrency)}
+	for _, dir := range strings.Split(*allowSrc, ",") {
+		if dir = strings.TrimSpace(dir); dir == "" {
+			continue
+		}
+		abs, err := filepath.Abs(dir)
+		if err == nil {
+			abs, err = filepath.EvalSymlinks(abs)
+		}
+		if err != nil {
+			log.Fatalf("Invalid -allow-src '%s': %v", dir, err)
+		}
+		s.allowSrc = append(s.allowSrc, abs)
+	}
+	if *authPath != "" {
+		if s.tenants, err = loadTenants(*authPath, s.allowSrc); err != nil {
+			log.Fatalf("Invalid -auth: %v", err)
+		}
+	}
+	switch *auditPath {
+	case "":
+	case "-":
+		s.audit = newAuditLog(os.Stderr)
+	default:
+		f, err := os.OpenFile(*auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			log.Fatalf("Could not open -audit-log: %v", err)
+		}
+		defer f.Close()
+		s.audit = newAuditLog(f)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /bundle", s.handle(s.bundle, true))
+	mux.HandleFunc("GET /presets", s.handle(s.presets, false))
+	if s.tenants != nil {
+		fmt.Printf("Serving bundles on http://%s (POST /bundle, GET /presets) to %d tenants\n", *addr, len(s.tenants))
+	} else {
+		fmt.Printf("Serving bundles on http://%s (POST /bundle, GET /presets)\n", *addr)
+	}
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+// presets answers GET /presets.
+func (s *bundleServer) presets(w http.ResponseWriter, r *http.Request, entry *auditEntry) {
+	var list []presetInfo
+	for _, name := range bundler.ProjectTypes() {
+		config := bundler.Presets[name]
+		info := presetInfo{Name: name, IgnoreDirs: config.IgnoreDirs, IgnoreExts: config.IgnoreExts, IgnorePaths: config.IgnorePaths}
+		for _, lang := range config.LangMap {
+			if !slices.Contains(info.Languages, lang) {
+				info.Languages = append(info.Languages, lang)
+			}
+		}
+		slices.Sort(info.Languages)
+		list = append(list, info)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// bundle answers POST /bundle with the bundle in the format the Accept
+// header prefers: Markdown, JSON with each file's content, or a zip of the
+// files as read. The query parameters include and exclude add globs to the
+// options', and budget refuses bundles over that many tokens. The request is JSON
+// options naming a src directory, or a multipart form with the project as an
+// "archive" file (zip, tar or tar.gz) and the options as an "options" field.
+// An uploaded archive is expanded into a temporary directory that is removed
+// when the request ends. The response carries the number of files bundled
+// and skipped in X-Bundle-Files and X-Bundle-Skipped. A tenant may only
+// bundle its own src directories, and upload archives if it is allowed to.
+func (s *bundleServer) bundle(w http.ResponseWriter, r *http.Request, entry *auditEntry) {
+	w.Header().Set("Vary", "Accept")
+	format, ok := negotiateFormat(r.Header.Get("Accept"))
+	if !ok {
+		http.Error(w, "the bundle is available as "+strings.Join(bundleFormats, ", "), http.StatusNotAcceptable)
+		return
+	}
+	query := r.URL.Query()
+	var budget int
+	var tok tokenizer
+	if v := query.Get("budget"); v != "" {
+		var err error
+		if budget, err = strconv.Atoi(v); err != nil || budget <= 0 {
+			http.Error(w, fmt.Sprintf("invalid budget '%s'; give a number of tokens", v), http.StatusBadRequest)
+			return
+		}
+		model := query.Get("model")
+		if model == "" {
+			model = "gpt-4o"
+		}
+		if tok, err = tokenizerForModel(model); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	default:
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "too many bundles in progress", http.StatusServiceUnavailable)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+
+	var req bundleRequest
+	var srcDir string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if entry.tenant != nil && !entry.tenant.Uploads {
+			http.Error(w, errUploadsDenied.Error(), http.StatusForbidden)
+			return
+		}
+		dir, err := s.expandUpload(r, &req)
+		if r.MultipartForm != nil && len(r.MultipartForm.File["archive"]) > 0 {
+			entry.Archive = r.MultipartForm.File["archive"][0].Filename
+		}
+		if dir != "" {
+			defer os.RemoveAll(dir)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		srcDir = dir
+		// Archives of a project usually hold its directory.
+		if entries, err := os.ReadDir(dir); err == nil && len(entries) == 1 && entries[0].IsDir() {
+			srcDir = filepath.Join(dir, entries[0].Name())
+		}
+	} else {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid options: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		dir, err := s.allowedSrc(req.Src)
+		if err == nil && entry.tenant != nil && !underAny(dir, entry.tenant.Src) {
+			err = fmt.Errorf("src '%s' is not one of the directories tenant '%s' may bundle", req.Src, entry.tenant.Name)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		srcDir, entry.Src = dir, dir
+	}
+
+	req.Include = append(req.Include, queryList(query, "include")...)
+	req.Exclude = append(req.Exclude, queryList(query, "exclude")...)
+	opts, err := s.requestOptions(srcDir, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var out bytes.Buffer
+	var served servedFiles
+	b := bundler.New(opts)
+	b.Observer = &served
+	report, err := b.Bundle(srcDir, &out)
+	var partial *bundler.PartialBundleError
+	switch {
+	case errors.As(err, &partial):
+		// Files that could not be read are counted in X-Bundle-Skipped.
+	case errors.Is(err, bundler.ErrDeadlineExceeded):
+		http.Error(w, fmt.Sprintf("bundling took longer than %s", s.timeout), http.StatusGatewayTimeout)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	entry.Files = len(report.Files)
+	skipped := 0
+	for _, paths := range report.Skipped {
+		skipped += len(paths)
+	}
+	tokens := 0
+	if tok != nil {
+		tokens = tok.count(out.Bytes())
+		if tokens > budget {
+			http.Error(w, fmt.Sprintf("bundle is an estimated %d tokens, over the budget of %d; narrow it with include or exclude", tokens, budget), http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("X-Bundle-Tokens", strconv.Itoa(tokens))
+	}
+	w.Header().Set("X-Bundle-Files", strconv.Itoa(len(report.Files)))
+	w.Header().Set("X-Bundle-Skipped", strconv.Itoa(skipped))
+
+	switch format {
+	case "application/json":
+		answer := bundleJSON{Files: []jsonFile{}, Skipped: make(map[string][]string), Tokens: tokens}
+		for _, f := range served.files {
+			answer.Files = append(answer.Files, newJSONFile(f.File, f.Content, f.Annotation))
+		}
+		for reason, paths := range report.Skipped {
+			for _, p := range paths {
+				if rel, err := filepath.Rel(srcDir, p); err == nil {
+					p = filepath.ToSlash(rel)
+				}
+				answer.Skipped[reason] = append(answer.Skipped[reason], p)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(answer)
+	case "application/zip":
+		var archive bytes.Buffer
+		zw := zip.NewWriter(&archive)
+		for _, f := range served.files {
+			if f.Raw == nil {
+				continue // Stubs have no content to archive.
+			}
+			if err := addZipFile(zw, f.RelPath, f.Raw); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := zw.Close(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="bundle.zip"`)
+		w.Write(archive.Bytes())
+	default:
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Write(out.Bytes())
+	}
+}
+
+// negotiateFormat picks the bundleFormats type an Accept header prefers:
+// the one with the highest quality, by the most specific range matching it,
+// and the earlier of bundleFormats on a tie. An empty header accepts the
+// default. It returns false when the header accepts none of them.
+func negotiateFormat(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return bundleFormats[0], true
+	}
+	best, bestQ := "", 0.0
+	for _, format := range bundleFormats {
+		major, _, _ := strings.Cut(format, "/")
+		q, specificity := 0.0, -1
+		for _, mediaRange := range strings.Split(accept, ",") {
+			params := strings.Split(mediaRange, ";")
+			var rank int
+			switch strings.ToLower(strings.TrimSpace(params[0])) {
+			case format:
+				rank = 2
+			case major + "/*":
+				rank = 1
+			case "*/*":
+				rank = 0
+			default:
+				continue
+			}
+			if rank < specificity {
+				continue
+			}
+			rangeQ := 1.0
+			for _, p := range params[1:] {
+				if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+					if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+						rangeQ = parsed
+					}
+				}
+			}
+			q, specificity = rangeQ, rank
+		}
+		if q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best, best != ""
+}
+
+// queryList returns the comma-separated values of a repeatable query
+// parameter.
+func queryList(query url.Values, key string) []string {
+	var list []string
+	for _, v := range query[key] {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// requestOptions resolves the rules for srcDir as the CLI does, with the
+// request's project type, style and include and exclude globs. Whoever sends
+// the request may have written the project's local config, so shared
+// configs by URL in its extends are refused rather than fetched: the server
+// would otherwise make requests of the client's choosing and store their
+// answers in its cache. The config's other keys only add rules, or set
+// output settings the server does not use.
+func (s *bundleServer) requestOptions(srcDir string, req bundleRequest) (bundler.Options, error) {
+	projectType := req.Type
+	if projectType == "" {
+		projectType = "auto"
+	}
+	local, _, err := readLocalConfig(srcDir)
+	if err != nil {
+		return bundler.Options{}, err
+	}
+	if _, refs := splitExtends(local.Extends); len(refs) > 0 {
+		return bundler.Options{}, fmt.Errorf("%s: 'extends' names %s; the server does not fetch shared configs for requests, so copy its rules into the file or extend presets only", local.file, refs[0])
+	}
+	opts, err := resolveConfigOptions(local, srcDir, projectType, "", "", false)
+	if err != nil {
+		return bundler.Options{}, err
+	}
+	if req.Style != "" {
+		style, ok := bundler.Styles[req.Style]
+		if !ok {
+			return bundler.Options{}, fmt.Errorf("unknown style '%s'; use %s", req.Style, strings.Join(bundler.StyleNames(), ", "))
+		}
+		opts.Style = style
+	}
+	if len(req.Include) > 0 {
+		opts.Only = make(ruleSet)
+		opts.Only.Add(req.Include, "request include")
+	}
+	opts.IgnorePaths.Add(req.Exclude, "request exclude")
+	opts.Deadline = time.Now().Add(s.timeout)
+	return opts.Options, nil
+}
+
+// allowedSrc resolves a src directory of a request, which must be one of
+// the -allow-src directories or below one.
+func (s *bundleServer) allowedSrc(src string) (string, error) {
+	if len(s.allowSrc) == 0 {
+		return "", errors.New("bundling by path is disabled; upload an archive or start the server with -allow-src")
+	}
+	abs, err := filepath.Abs(src)
+	if err == nil {
+		abs, err = filepath.EvalSymlinks(abs)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid src '%s'", src)
+	}
+	if !underAny(abs, s.allowSrc) {
+		return "", fmt.Errorf("src '%s' is not under a directory allowed by -allow-src", src)
+	}
+	return abs, nil
+}
+
+// underAny reports whether the absolute path dir is one of roots or below
+// one.
+func underAny(dir string, roots []string) bool {
+	for _, root := range roots {
+		if rel, err := filepath.Rel(root, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandUpload reads a multipart request: its "options" field into req and
+// its "archive" file into a new temporary directory, which it returns for
+// the caller to remove, also on error.
+func (s *bundleServer) expandUpload(r *http.Request, req *bundleRequest) (string, error) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return "", fmt.Errorf("invalid upload: %v", err)
+	}
+	defer r.MultipartForm.RemoveAll()
+	if options := r.FormValue("options"); options != "" {
+		if err := json.Unmarshal([]byte(options), req); err != nil {
+			return "", fmt.Errorf("invalid options: %v", err)
+		}
+	}
+	file, header, err := r.FormFile("archive")
+	if err != nil {
+		return "", errors.New("missing archive file")
+	}
+	defer file.Close()
+	format := archiveFormat(header.Filename)
+	if format == "" {
+		return "", fmt.Errorf("unsupported archive '%s'; upload a .zip, .tar or .tar.gz file", header.Filename)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	entries, err := readArchive(data, format, s.maxUpload)
+	if err != nil {
+		return "", fmt.Errorf("invalid archive: %v", err)
+	}
+
+	dir, err := os.MkdirTemp("", "project-bundler-serve-*")
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		name := path.Clean("/" + e.name)[1:] // Keeps ".." from leaving dir.
+		if name == "" {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return dir, err
+		}
+		if err := os.WriteFile(target, e.content, 0o644); err != nil {
+			return dir, err
+		}
+		os.Chtimes(target, e.modTime, e.modTime)
+	}
+	return dir, nil
+}
@@ -0,0 +1,236 @@
+// project-bundler/serve_test.go
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestServeRefusesRemoteExtends checks that a request cannot make the
+// server fetch a shared config named in the project's own local config.
+func TestServeRefusesRemoteExtends(t *testing.T) {
+	shared := []byte("ignore-exts: [.md]\n")
+	var fetched atomic.Int32
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched.Add(1)
+		w.Write(shared)
+	}))
+	defer remote.Close()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	// Pinned, as plain http would be refused anyway.
+	sum := sha256.Sum256(shared)
+	config := "extends: " + remote.URL + "/org.yaml#sha256=" + hex.EncodeToString(sum[:]) + "\n"
+
+	allowed := t.TempDir()
+	project := filepath.Join(allowed, "project")
+	os.MkdirAll(project, 0o755)
+	os.WriteFile(filepath.Join(project, localConfigFile), []byte(config), 0o644)
+	os.WriteFile(filepath.Join(project, "main.go"), []byte("package main\n"), 0o644)
+
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, content := range map[string]string{localConfigFile: config, "main.go": "package main\n"} {
+		w, _ := zw.Create("project/" + name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	var upload bytes.Buffer
+	mw := multipart.NewWriter(&upload)
+	part, _ := mw.CreateFormFile("archive", "project.zip")
+	part.Write(archive.Bytes())
+	mw.Close()
+
+	s := &bundleServer{allowSrc: []string{allowed}, maxUpload: 1 << 20, timeout: time.Minute, slots: make(chan struct{}, 1)}
+	if resolved, err := filepath.EvalSymlinks(allowed); err == nil {
+		s.allowSrc = []string{resolved}
+	}
+	for name, r := range map[string]*http.Request{
+		"src":    httptest.NewRequest("POST", "/bundle", strings.NewReader(`{"src":"`+project+`"}`)),
+		"upload": httptest.NewRequest("POST", "/bundle", &upload),
+	} {
+		if name == "upload" {
+			r.Header.Set("Content-Type", mw.FormDataContentType())
+		}
+		w := httptest.NewRecorder()
+		s.handle(s.bundle, true)(w, r)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "extends") {
+			t.Errorf("%s: got %d %q, want 400 about extends", name, w.Code, w.Body.String())
+		}
+	}
+	if n := fetched.Load(); n != 0 {
+		t.Errorf("the server fetched the shared config %d times", n)
+	}
+}
+
+// TestServeTenants checks the tokens, repositories, uploads and quotas of
+// -auth tenants, and that the audit log records each request.
+func TestServeTenants(t *testing.T) {
+	allowed := t.TempDir()
+	if resolved, err := filepath.EvalSymlinks(allowed); err == nil {
+		allowed = resolved
+	}
+	for _, repo := range []string{"api", "web"} {
+		os.MkdirAll(filepath.Join(allowed, repo), 0o755)
+		os.WriteFile(filepath.Join(allowed, repo, "main.go"), []byte("package main\n"), 0o644)
+	}
+	sum := sha256.Sum256([]byte("web-token-0123456789"))
+	authPath := filepath.Join(t.TempDir(), "auth.json")
+	os.WriteFile(authPath, []byte(`{"tenants": [
+		{"name": "api-ci", "token": "api-token-0123456789", "src": ["`+filepath.Join(allowed, "api")+`"], "quotaPerHour": 3},
+		{"name": "web-ci", "tokenSha256": "`+hex.EncodeToString(sum[:])+`", "src": ["`+filepath.Join(allowed, "web")+`"], "uploads": true}
+	]}`), 0o644)
+	tenants, err := loadTenants(authPath, []string{allowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var audit bytes.Buffer
+	s := &bundleServer{allowSrc: []string{allowed}, maxUpload: 1 << 20, timeout: time.Minute, slots: make(chan struct{}, 1), tenants: tenants, audit: newAuditLog(&audit)}
+	handler := s.handle(s.bundle, true)
+
+	tests := []struct {
+		name, token, src string
+		upload           bool
+		want             int
+	}{
+		{"no token", "", "api", false, http.StatusUnauthorized},
+		{"unknown token", "nobody-0123456789", "api", false, http.StatusUnauthorized},
+		{"own repo", "api-token-0123456789", "api", false, http.StatusOK},
+		{"other repo", "api-token-0123456789", "web", false, http.StatusForbidden},
+		{"upload denied", "api-token-0123456789", "", true, http.StatusForbidden},
+		{"over quota", "api-token-0123456789", "api", false, http.StatusTooManyRequests},
+		{"hashed token", "web-token-0123456789", "web", false, http.StatusOK},
+		{"upload allowed", "web-token-0123456789", "", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		var r *http.Request
+		if tt.upload {
+			var archive bytes.Buffer
+			zw := zip.NewWriter(&archive)
+			w, _ := zw.Create("main.go")
+			w.Write([]byte("package main\n"))
+			zw.Close()
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			part, _ := mw.CreateFormFile("archive", "web.zip")
+			part.Write(archive.Bytes())
+			mw.Close()
+			r = httptest.NewRequest("POST", "/bundle", &body)
+			r.Header.Set("Content-Type", mw.FormDataContentType())
+		} else {
+			r = httptest.NewRequest("POST", "/bundle", strings.NewReader(`{"src":"`+filepath.Join(allowed, tt.src)+`"}`))
+		}
+		if tt.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: got %d %q, want %d", tt.name, w.Code, w.Body.String(), tt.want)
+		}
+	}
+
+	var entries []auditEntry
+	for _, line := range strings.Split(strings.TrimSpace(audit.String()), "\n") {
+		var e auditEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("audit line %q: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != len(tests) {
+		t.Fatalf("audit log has %d entries, want %d", len(entries), len(tests))
+	}
+	if e := entries[2]; e.Tenant != "api-ci" || e.Src != filepath.Join(allowed, "api") || e.Status != http.StatusOK || e.Files != 1 {
+		t.Errorf("audit entry of a bundle is %+v", e)
+	}
+	if e := entries[7]; e.Tenant != "web-ci" || e.Archive != "web.zip" || e.Status != http.StatusOK {
+		t.Errorf("audit entry of an upload is %+v", e)
+	}
+	if e := entries[3]; e.Status != http.StatusForbidden || !strings.Contains(e.Error, "tenant 'api-ci'") {
+		t.Errorf("audit entry of a refusal is %+v", e)
+	}
+}
+
+// TestServeFormats checks the representations chosen by the Accept header
+// and the include, exclude and budget query parameters.
+func TestServeFormats(t *testing.T) {
+	allowed := t.TempDir()
+	if resolved, err := filepath.EvalSymlinks(allowed); err == nil {
+		allowed = resolved
+	}
+	project := filepath.Join(allowed, "project")
+	os.MkdirAll(filepath.Join(project, "cmd"), 0o755)
+	os.WriteFile(filepath.Join(project, "go.mod"), []byte("module x\n"), 0o644)
+	os.WriteFile(filepath.Join(project, "main.go"), []byte("package main\n"), 0o644)
+	os.WriteFile(filepath.Join(project, "cmd", "tool.go"), []byte("package main\n\nfunc tool() {}\n"), 0o644)
+	s := &bundleServer{allowSrc: []string{allowed}, maxUpload: 1 << 20, timeout: time.Minute, slots: make(chan struct{}, 1)}
+	request := func(query, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/bundle"+query, strings.NewReader(`{"src":"`+project+`"}`))
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		s.handle(s.bundle, true)(w, r)
+		return w
+	}
+
+	for accept, want := range map[string]string{
+		"":                                     "text/markdown; charset=utf-8",
+		"*/*":                                  "text/markdown; charset=utf-8",
+		"application/json":                     "application/json",
+		"application/*":                        "application/json",
+		"text/markdown;q=0.5, application/zip": "application/zip",
+		"text/markdown;q=0, */*":               "application/json",
+	} {
+		if w := request("", accept); w.Code != http.StatusOK || w.Header().Get("Content-Type") != want {
+			t.Errorf("Accept %q: got %d %s, want %s", accept, w.Code, w.Header().Get("Content-Type"), want)
+		}
+	}
+	if w := request("", "text/html"); w.Code != http.StatusNotAcceptable {
+		t.Errorf("Accept text/html: got %d, want 406", w.Code)
+	}
+
+	w := request("?exclude=cmd/**", "application/json")
+	var answer bundleJSON
+	if err := json.Unmarshal(w.Body.Bytes(), &answer); err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range answer.Files {
+		paths = append(paths, f.Path)
+	}
+	if strings.Join(paths, ",") != "go.mod,main.go" || answer.Files[1].Content != "package main\n" {
+		t.Errorf("JSON files %+v, want go.mod and main.go", answer.Files)
+	}
+	if skipped := answer.Skipped["IGNORED_PATH"]; len(skipped) != 1 || skipped[0] != "cmd" {
+		t.Errorf("JSON skipped %v, want cmd by relative path", answer.Skipped)
+	}
+
+	w = request("?include=cmd/**", "application/zip")
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "cmd/tool.go" {
+		t.Errorf("zip holds %v, want cmd/tool.go", zr.File)
+	}
+
+	if w := request("?budget=5", ""); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("budget 5: got %d, want 422", w.Code)
+	}
+	if w := request("?budget=5000", ""); w.Code != http.StatusOK || w.Header().Get("X-Bundle-Tokens") == "" {
+		t.Errorf("budget 5000: got %d with X-Bundle-Tokens %q", w.Code, w.Header().Get("X-Bundle-Tokens"))
+	}
+}
@@ -0,0 +1,244 @@
+// project-bundler/serveauth.go
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minTokenLength is the shortest plain token an -auth file may hold.
+const minTokenLength = 16
+
+// quotaWindow is the period a tenant's quota counts bundles over.
+const quotaWindow = time.Hour
+
+// authFile is the -auth file of `serve`:
+//
+//	{"tenants": [{"name": "payments-ci", "tokenSha256": "9f86d0...",
+//	              "src": ["/srv/checkouts/payments"], "uploads": false,
+//	              "quotaPerHour": 120}]}
+type authFile struct {
+	Tenants []*tenant `json:"tenants"`
+}
+
+// tenant is a client of the service with its own token, repositories and
+// quota.
+type tenant struct {
+	Name        string   `json:"name"`
+	Token       string   `json:"token,omitempty"`       // Plain token; prefer TokenSHA256.
+	TokenSHA256 string   `json:"tokenSha256,omitempty"` // Hex SHA-256 of the token.
+	Src         []string `json:"src"`                   // Directories it may bundle by path, at or below.
+	Uploads     bool     `json:"uploads"`               // Whether it may upload archives.
+	Quota       int      `json:"quotaPerHour"`          // Bundles per hour; 0 is unlimited.
+
+	digest [sha256.Size]byte
+
+	mu          sync.Mutex
+	windowStart time.Time
+	used        int
+}
+
+// loadTenants reads an -auth file. Each tenant's src directories are
+// resolved and must lie within allowSrc, which stays the limit of the whole
+// service.
+func loadTenants(path string, allowSrc []string) ([]*tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file authFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(file.Tenants) == 0 {
+		return nil, fmt.Errorf("%s: no tenants", path)
+	}
+	names := make(stringSet)
+	digests := make(map[[sha256.Size]byte]string)
+	for _, t := range file.Tenants {
+		switch {
+		case t.Name == "":
+			return nil, fmt.Errorf("%s: a tenant has no name", path)
+		case names.Contains(t.Name):
+			return nil, fmt.Errorf("%s: tenant '%s' is listed twice", path, t.Name)
+		case (t.Token == "") == (t.TokenSHA256 == ""):
+			return nil, fmt.Errorf("%s: tenant '%s' needs exactly one of token and tokenSha256", path, t.Name)
+		case t.Token != "" && len(t.Token) < minTokenLength:
+			return nil, fmt.Errorf("%s: the token of tenant '%s' is shorter than %d characters", path, t.Name, minTokenLength)
+		case t.Quota < 0:
+			return nil, fmt.Errorf("%s: tenant '%s' has a negative quotaPerHour", path, t.Name)
+		}
+		names[t.Name] = struct{}{}
+		if t.Token != "" {
+			t.digest = sha256.Sum256([]byte(t.Token))
+		} else if sum, err := hex.DecodeString(t.TokenSHA256); err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("%s: the tokenSha256 of tenant '%s' is not a hex SHA-256", path, t.Name)
+		} else {
+			copy(t.digest[:], sum)
+		}
+		if other, ok := digests[t.digest]; ok {
+			return nil, fmt.Errorf("%s: tenants '%s' and '%s' have the same token", path, other, t.Name)
+		}
+		digests[t.digest] = t.Name
+		for i, dir := range t.Src {
+			abs, err := filepath.Abs(dir)
+			if err == nil {
+				abs, err = filepath.EvalSymlinks(abs)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid src '%s' of tenant '%s': %v", path, dir, t.Name, err)
+			}
+			if !underAny(abs, allowSrc) {
+				return nil, fmt.Errorf("%s: src '%s' of tenant '%s' is not under a directory allowed by -allow-src", path, dir, t.Name)
+			}
+			t.Src[i] = abs
+		}
+	}
+	return file.Tenants, nil
+}
+
+// authenticate returns the tenant whose token the request carries as
+// "Authorization: Bearer <token>", or nil. Every tenant's digest is
+// compared, in constant time, so the time taken tells nothing of the token.
+func (s *bundleServer) authenticate(r *http.Request) *tenant {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil
+	}
+	digest := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	var found *tenant
+	for _, t := range s.tenants {
+		if subtle.ConstantTimeCompare(digest[:], t.digest[:]) == 1 {
+			found = t
+		}
+	}
+	return found
+}
+
+// take counts a bundle against the tenant's quota. When the quota of the
+// current window is used up, it returns false and how long until the next.
+func (t *tenant) take(now time.Time) (bool, time.Duration) {
+	if t.Quota == 0 {
+		return true, 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.windowStart) >= quotaWindow {
+		t.windowStart, t.used = now, 0
+	}
+	if t.used >= t.Quota {
+		return false, t.windowStart.Add(quotaWindow).Sub(now)
+	}
+	t.used++
+	return true, 0
+}
+
+// auditEntry is one line of the audit log, written when a request ends.
+type auditEntry struct {
+	Time       string  `json:"time"`
+	Tenant     string  `json:"tenant,omitempty"`
+	Remote     string  `json:"remote"`
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Src        string  `json:"src,omitempty"`     // Directory bundled by path.
+	Archive    string  `json:"archive,omitempty"` // Name of the uploaded archive.
+	Status     int     `json:"status"`
+	Files      int     `json:"files,omitempty"`
+	Bytes      int64   `json:"bytes"` // Response body bytes.
+	DurationMS float64 `json:"durationMs"`
+	Error      string  `json:"error,omitempty"` // Start of the body of a refused or failed request.
+
+	tenant *tenant
+}
+
+// auditLog writes audit entries as JSON lines.
+type auditLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newAuditLog(w io.Writer) *auditLog {
+	return &auditLog{enc: json.NewEncoder(w)}
+}
+
+func (l *auditLog) write(e *auditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enc.Encode(e)
+}
+
+// auditWriter records what a handler answers for the audit log.
+type auditWriter struct {
+	http.ResponseWriter
+	entry *auditEntry
+}
+
+// maxAuditError is how much of an error body the audit log keeps.
+const maxAuditError = 200
+
+func (w *auditWriter) WriteHeader(status int) {
+	if w.entry.Status == 0 {
+		w.entry.Status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditWriter) Write(p []byte) (int, error) {
+	if w.entry.Status == 0 {
+		w.entry.Status = http.StatusOK
+	}
+	if w.entry.Status >= 400 && len(w.entry.Error) < maxAuditError {
+		w.entry.Error += strings.TrimSpace(string(p[:min(len(p), maxAuditError-len(w.entry.Error))]))
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.entry.Bytes += int64(n)
+	return n, err
+}
+
+// handle wraps a handler with authentication, the quota when metered, and
+// the audit log. Without tenants every request is let through as before.
+func (s *bundleServer) handle(h func(http.ResponseWriter, *http.Request, *auditEntry), metered bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &auditEntry{Time: start.UTC().Format(time.RFC3339), Remote: r.RemoteAddr, Method: r.Method, Path: r.URL.Path}
+		aw := &auditWriter{ResponseWriter: w, entry: entry}
+		if s.audit != nil {
+			defer func() {
+				entry.DurationMS = float64(time.Since(start).Microseconds()) / 1000
+				s.audit.write(entry)
+			}()
+		}
+		if s.tenants != nil {
+			entry.tenant = s.authenticate(r)
+			if entry.tenant == nil {
+				aw.Header().Set("WWW-Authenticate", `Bearer realm="project-bundler"`)
+				http.Error(aw, "missing or unknown bearer token", http.StatusUnauthorized)
+				return
+			}
+			entry.Tenant = entry.tenant.Name
+			if metered {
+				if ok, wait := entry.tenant.take(start); !ok {
+					aw.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+					http.Error(aw, fmt.Sprintf("quota of %d bundles per hour used up", entry.tenant.Quota), http.StatusTooManyRequests)
+					return
+				}
+			}
+		}
+		h(aw, r, entry)
+	}
+}
+
+// errUploadsDenied refuses an archive from a tenant without uploads.
+var errUploadsDenied = errors.New("this token may not upload archives")
@@ -0,0 +1,294 @@
+// project-bundler/sheets.go
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	sheetSampleRows = 5  // Data rows shown below the header of each sheet.
+	sheetMaxColumns = 30 // Columns shown per row; wider sheets are cut off.
+	sheetMaxCell    = 80 // Characters shown per cell.
+)
+
+// spreadsheetConverter replaces XLSX workbooks and CSV/TSV tables with a
+// summary of each sheet: its name, dimensions, header row and a few sample
+// rows. Tables short enough to show whole are bundled as they are.
+var spreadsheetConverter = fileConverter{
+	handles: func(name string) bool {
+		switch strings.ToLower(path.Ext(name)) {
+		case ".xlsx", ".csv", ".tsv":
+			return true
+		}
+		return false
+	},
+	convert: func(name string) ([]byte, string, error) {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, "", err
+		}
+		var sheets []sheetSummary
+		format := strings.ToUpper(strings.TrimPrefix(path.Ext(name), "."))
+		if format == "XLSX" {
+			if sheets, err = summarizeXLSX(data); err != nil {
+				return nil, "", fmt.Errorf("could not read workbook: %w", err)
+			}
+		} else {
+			sheet, err := summarizeCSV(data, format == "TSV")
+			if err != nil || sheet.rows <= sheetSampleRows+1 {
+				return nil, "", nil // Unparsable or short enough: bundle it whole.
+			}
+			sheets = []sheetSummary{sheet}
+		}
+		var b strings.Builder
+		for i, s := range sheets {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			s.write(&b)
+		}
+		return []byte(b.String()), fmt.Sprintf("Summary of %s: sheets with their size, header and first %d data rows.\n", format, sheetSampleRows), nil
+	},
+}
+
+// sheetSummary is what a summary shows of one sheet.
+type sheetSummary struct {
+	name    string     // Empty for CSV and TSV files.
+	rows    int        // Non-empty rows, including the header.
+	columns int        // Widest row.
+	sample  [][]string // The header and up to sheetSampleRows rows after it.
+}
+
+// addRow counts a row and keeps it if it is part of the sample.
+func (s *sheetSummary) addRow(cells []string) {
+	for len(cells) > 0 && cells[len(cells)-1] == "" {
+		cells = cells[:len(cells)-1]
+	}
+	if len(cells) == 0 {
+		return
+	}
+	s.rows++
+	s.columns = max(s.columns, len(cells))
+	if len(s.sample) <= sheetSampleRows {
+		s.sample = append(s.sample, cells)
+	}
+}
+
+// write renders the summary, with the sample rows as CSV.
+func (s sheetSummary) write(b *strings.Builder) {
+	if s.name != "" {
+		fmt.Fprintf(b, "Sheet %q: ", s.name)
+	}
+	data := max(s.rows-1, 0)
+	fmt.Fprintf(b, "%d data rows, %d columns\n", data, s.columns)
+	w := csv.NewWriter(b)
+	for _, row := range s.sample {
+		if len(row) > sheetMaxColumns {
+			row = append(row[:sheetMaxColumns:sheetMaxColumns], "…")
+		}
+		shown := make([]string, len(row))
+		for i, cell := range row {
+			if r := []rune(cell); len(r) > sheetMaxCell {
+				cell = string(r[:sheetMaxCell]) + "…"
+			}
+			shown[i] = cell
+		}
+		w.Write(shown)
+	}
+	w.Flush()
+	if data > len(s.sample)-1 && len(s.sample) > 0 {
+		fmt.Fprintf(b, "(%d more rows)\n", data-(len(s.sample)-1))
+	}
+}
+
+// summarizeCSV reads a comma- or tab-separated table.
+func summarizeCSV(data []byte, tabs bool) (sheetSummary, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	if tabs {
+		r.Comma = '\t'
+	}
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	var s sheetSummary
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return s, err
+		}
+		s.addRow(record)
+	}
+}
+
+// summarizeXLSX reads the sheets of an Office Open XML workbook in order.
+func summarizeXLSX(data []byte) ([]sheetSummary, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	parts := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		parts[f.Name] = f
+	}
+	decode := func(name string, v any) error {
+		f, ok := parts[name]
+		if !ok {
+			return fmt.Errorf("missing %s", name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		return xml.NewDecoder(rc).Decode(v)
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decode("xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := make(map[string]string)
+	for _, r := range rels.Relationships {
+		target := strings.TrimPrefix(r.Target, "/")
+		if !strings.HasPrefix(target, "xl/") {
+			target = path.Join("xl", target)
+		}
+		targets[r.ID] = target
+	}
+	var shared []string
+	if _, ok := parts["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []struct {
+				Text string   `xml:"t"`
+				Runs []string `xml:"r>t"`
+			} `xml:"si"`
+		}
+		if err := decode("xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+		for _, si := range sst.Items {
+			shared = append(shared, si.Text+strings.Join(si.Runs, ""))
+		}
+	}
+
+	var sheets []sheetSummary
+	for _, sheet := range workbook.Sheets {
+		f, ok := parts[targets[sheet.RID]]
+		if !ok {
+			return nil, fmt.Errorf("missing worksheet %q", sheet.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		s, err := readWorksheet(rc, shared)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("worksheet %q: %w", sheet.Name, err)
+		}
+		s.name = sheet.Name
+		sheets = append(sheets, s)
+	}
+	return sheets, nil
+}
+
+// readWorksheet streams the rows of a worksheet part, resolving shared and
+// inline strings. Cells are placed by their column reference, so sparse rows
+// keep their columns.
+func readWorksheet(r io.Reader, shared []string) (sheetSummary, error) {
+	var s sheetSummary
+	dec := xml.NewDecoder(r)
+	var row []string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return s, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row = row[:0]
+			case "c":
+				var cell struct {
+					Ref    string `xml:"r,attr"`
+					Type   string `xml:"t,attr"`
+					Value  string `xml:"v"`
+					Inline string `xml:"is>t"`
+				}
+				if err := dec.DecodeElement(&cell, &t); err != nil {
+					return s, err
+				}
+				value := cell.Value
+				switch cell.Type {
+				case "s":
+					if i, err := strconv.Atoi(value); err == nil && i >= 0 && i < len(shared) {
+						value = shared[i]
+					}
+				case "inlineStr":
+					value = cell.Inline
+				case "b":
+					value = map[string]string{"0": "FALSE", "1": "TRUE"}[value]
+				}
+				col := columnIndex(cell.Ref)
+				if col < 0 {
+					col = len(row)
+				}
+				for len(row) <= col {
+					row = append(row, "")
+				}
+				row[col] = value
+			}
+		case xml.EndElement:
+			if t.Name.Local == "row" {
+				s.addRow(append([]string(nil), row...))
+			}
+		}
+	}
+}
+
+// columnIndex returns the zero-based column of a cell reference like "AB12",
+// or -1 when it has none.
+func columnIndex(ref string) int {
+	col := 0
+	n := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		col = col*26 + int(c-'A'+1)
+		n++
+	}
+	if n == 0 || col > 16384 {
+		return -1
+	}
+	return col - 1
+}
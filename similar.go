@@ -0,0 +1,239 @@
+// project-bundler/similar.go
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+const (
+	// minHashSize is the number of hash functions of a fingerprint. The
+	// estimated similarity is off by about 1/sqrt(minHashSize).
+	minHashSize = 128
+	// shingleWords is the number of consecutive words in a content shingle.
+	shingleWords = 5
+)
+
+// minHash is a MinHash signature: for each of minHashSize hash functions,
+// the smallest hash of a set's elements. The share of positions where two
+// signatures agree estimates the Jaccard similarity of their sets, and the
+// signature of a union is the element-wise minimum, so a bundle's
+// fingerprint is merged from those of its files.
+type minHash []uint32
+
+func newMinHash() minHash {
+	m := make(minHash, minHashSize)
+	for i := range m {
+		m[i] = ^uint32(0)
+	}
+	return m
+}
+
+// fileMinHash fingerprints a file by its path and the shingles of
+// shingleWords consecutive words of its content, so that reformatting and
+// small edits change only a few of its elements.
+func fileMinHash(relPath string, content []byte) minHash {
+	m := newMinHash()
+	h := fnv.New64a()
+	h.Write([]byte("path\x00" + relPath))
+	m.add(h.Sum64())
+	words := bytes.Fields(content)
+	shingles := len(words) - shingleWords + 1
+	if shingles < 1 && len(words) > 0 {
+		shingles = 1 // A file shorter than a shingle is one.
+	}
+	for i := 0; i < shingles; i++ {
+		h.Reset()
+		h.Write([]byte("text"))
+		for _, w := range words[i:min(i+shingleWords, len(words))] {
+			h.Write([]byte{0})
+			h.Write(w)
+		}
+		m.add(h.Sum64())
+	}
+	return m
+}
+
+// add adds an element, by its 64-bit hash, to the set. The hash functions
+// are the hash mixed with a different seed each.
+func (m minHash) add(h uint64) {
+	for i := range m {
+		if v := uint32(mix64(h^minHashSeeds[i]) >> 32); v < m[i] {
+			m[i] = v
+		}
+	}
+}
+
+// mix64 is the finalizer of SplitMix64.
+func mix64(x uint64) uint64 {
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+var minHashSeeds = func() [minHashSize]uint64 {
+	var seeds [minHashSize]uint64
+	for i := range seeds {
+		seeds[i] = mix64(uint64(i+1) * 0x9e3779b97f4a7c15)
+	}
+	return seeds
+}()
+
+func (m minHash) merge(o minHash) {
+	for i := range m {
+		m[i] = min(m[i], o[i])
+	}
+}
+
+// similarity estimates the Jaccard similarity of the two sets, from 0 to 1.
+func (m minHash) similarity(o minHash) float64 {
+	same := 0
+	for i := range m {
+		if m[i] == o[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(m))
+}
+
+func (m minHash) String() string {
+	b := make([]byte, 4*len(m))
+	for i, v := range m {
+		binary.BigEndian.PutUint32(b[4*i:], v)
+	}
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+func parseMinHash(s string) (minHash, error) {
+	b, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil || len(b) != 4*minHashSize {
+		return nil, errors.New("invalid fingerprint")
+	}
+	m := make(minHash, minHashSize)
+	for i := range m {
+		m[i] = binary.BigEndian.Uint32(b[4*i:])
+	}
+	return m, nil
+}
+
+// addFingerprint merges a file's fingerprint into the bundle's, when the
+// manifest is fingerprinted, and returns it encoded for the render cache.
+func (m *bundleManifest) addFingerprint(relPath string, content []byte) string {
+	if m.fingerprint == nil {
+		return ""
+	}
+	sig := fileMinHash(relPath, content)
+	m.fingerprint.merge(sig)
+	return sig.String()
+}
+
+// reuseFingerprint merges the fingerprint of a file whose block came from
+// the render cache.
+func (m *bundleManifest) reuseFingerprint(encoded string) {
+	if m.fingerprint == nil {
+		return
+	}
+	if sig, err := parseMinHash(encoded); err == nil {
+		m.fingerprint.merge(sig)
+	}
+}
+
+// bundleFingerprint is what `similar` compares of a bundle.
+type bundleFingerprint struct {
+	sig        minHash
+	files      map[string]string // Relative path -> SHA-256 of the content.
+	fromBundle bool              // Computed from the bundle's blocks, as its manifest has no fingerprint.
+}
+
+// runSimilar implements the `similar` subcommand: it estimates how much two
+// bundles share, by the fingerprints in their manifests, and counts the
+// files they have in common. This finds copied projects and near-duplicate
+// bundles in an archive.
+func runSimilar(args []string) {
+	fs := flag.NewFlagSet("similar", flag.ExitOnError)
+	styleName := fs.String("style", "github", "Output style the bundles were written with, for bundles without a fingerprint in their manifest. Options: "+strings.Join(bundler.StyleNames(), ", "))
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		log.Fatalf("Usage: project-bundler similar [-style name] <bundle> <bundle>")
+	}
+	style, ok := bundler.Styles[*styleName]
+	if !ok {
+		log.Fatalf("Invalid style '%s'. Available styles are: %s", *styleName, strings.Join(bundler.StyleNames(), ", "))
+	}
+	var prints [2]bundleFingerprint
+	for i, bundle := range fs.Args() {
+		fp, err := loadFingerprint(bundle, style)
+		if err != nil {
+			log.Fatalf("Could not fingerprint %s: %v", bundle, err)
+		}
+		prints[i] = fp
+	}
+	a, b := fs.Arg(0), fs.Arg(1)
+	shared, identical := 0, 0
+	for path, sum := range prints[0].files {
+		if other, ok := prints[1].files[path]; ok {
+			shared++
+			if other == sum {
+				identical++
+			}
+		}
+	}
+	fmt.Printf("Similarity of %s and %s: %.0f%% (estimated over their paths and content).\n", a, b, 100*prints[0].sig.similarity(prints[1].sig))
+	fmt.Printf("Files: %d in %s, %d in %s; %d paths in both, %d of them identical.\n", len(prints[0].files), a, len(prints[1].files), b, shared, identical)
+	for i, bundle := range fs.Args() {
+		if prints[i].fromBundle {
+			fmt.Printf("%s has no fingerprint in its manifest; it was computed from the bundled content, which may differ from the files where they were transformed.\n", bundle)
+		}
+	}
+}
+
+// loadFingerprint reads the fingerprint and file hashes of a bundle's
+// manifest or, without a fingerprint there, computes them from the blocks
+// of the bundle or of its parts.
+func loadFingerprint(bundle string, style bundler.Style) (bundleFingerprint, error) {
+	m, err := loadManifest(sidecarPath(bundle, ".manifest.json"))
+	if err != nil {
+		return bundleFingerprint{}, err
+	}
+	if m != nil && m.Fingerprint != "" {
+		sig, err := parseMinHash(m.Fingerprint)
+		if err != nil {
+			return bundleFingerprint{}, err
+		}
+		return bundleFingerprint{sig: sig, files: m.Files}, nil
+	}
+	files := bundleFiles(bundle)
+	if len(files) == 0 {
+		return bundleFingerprint{}, fmt.Errorf("neither %s nor its parts exist", bundle)
+	}
+	fp := bundleFingerprint{sig: newMinHash(), files: make(map[string]string), fromBundle: true}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return bundleFingerprint{}, err
+		}
+		blocks, err := style.Parse(f)
+		f.Close()
+		if err != nil {
+			return bundleFingerprint{}, err
+		}
+		for _, b := range blocks {
+			fp.sig.merge(fileMinHash(b.Path, b.Content))
+			sum := sha256.Sum256(b.Content)
+			fp.files[b.Path] = hex.EncodeToString(sum[:])
+		}
+	}
+	return fp, nil
+}
@@ -0,0 +1,88 @@
+// project-bundler/sourcemap.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// sourceMapEntry maps the lines of one file block in the bundle back to the
+// file. When Exact is false the content was condensed (elided, reduced to its
+// API, ...) and only the block as a whole corresponds to the file.
+type sourceMapEntry struct {
+	Path        string `json:"path"`
+	BundleStart int64  `json:"bundleStart"` // First content line in the bundle.
+	BundleEnd   int64  `json:"bundleEnd"`
+	Line        int    `json:"line"` // File line shown at BundleStart.
+	Exact       bool   `json:"exact"`
+}
+
+// sourceMap is the -source-map sidecar written next to the bundle.
+type sourceMap struct {
+	Bundle string           `json:"bundle"`
+	Files  []sourceMapEntry `json:"files"` // In bundle order.
+}
+
+// add records a block that starts on bundle line blockLine and shows content,
+// rendered from the file's original bytes raw.
+func (m *sourceMap) add(blockLine int64, style bundler.Style, annotation, relPath string, raw, content []byte) {
+	lines := lineCount(content)
+	if lines == 0 {
+		return
+	}
+	start := blockLine + int64(style.ContentLine(style.BlockAnnotation(annotation, content))) - 1
+	m.Files = append(m.Files, sourceMapEntry{
+		Path:        filepath.ToSlash(relPath),
+		BundleStart: start,
+		BundleEnd:   start + int64(lines) - 1,
+		Line:        1,
+		Exact:       lines == lineCount(raw),
+	})
+}
+
+// shift moves every entry down by n bundle lines, for text inserted above
+// the first block.
+func (m *sourceMap) shift(n int64) {
+	for i := range m.Files {
+		m.Files[i].BundleStart += n
+		m.Files[i].BundleEnd += n
+	}
+}
+
+// lookup translates a bundle line into a file location.
+func (m *sourceMap) lookup(line int64) (string, error) {
+	i := sort.Search(len(m.Files), func(i int) bool { return m.Files[i].BundleEnd >= line })
+	if i == len(m.Files) || m.Files[i].BundleStart > line {
+		return "", fmt.Errorf("line %d of %s is not file content", line, m.Bundle)
+	}
+	e := m.Files[i]
+	if !e.Exact {
+		return fmt.Sprintf("%s (condensed in the bundle at lines %d-%d)", e.Path, e.BundleStart, e.BundleEnd), nil
+	}
+	return fmt.Sprintf("%s:%d", e.Path, e.Line+int(line-e.BundleStart)), nil
+}
+
+func (m *sourceMap) save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func loadSourceMap(path string) (*sourceMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m sourceMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid source map %s: %w", path, err)
+	}
+	return &m, nil
+}
@@ -0,0 +1,187 @@
+// project-bundler/split.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// splitLimit is the per-part budget of -split-tokens or -split-bytes.
+type splitLimit struct {
+	tokens  int   // Maximum estimated tokens per part; 0 when splitting by bytes.
+	bytes   int64 // Maximum bytes per part; 0 when splitting by tokens.
+	overlap int   // Lines of the previous part's last file to repeat (-split-overlap); 0 repeats nothing.
+}
+
+// splitContext is what -split-overlap needs to open each part after the
+// first with context from the ones before.
+type splitContext struct {
+	tree  string        // The -tree section, repeated as is; "" without -tree.
+	style bundler.Style // Reads the previous part's last block back.
+	tok   tokenizer     // Counts the repeated text; nil when tokens are not counted.
+	price float64       // USD per million input tokens, for each part's cost in the index; 0 without -model pricing.
+}
+
+// blockStart records where a file's block begins in the Markdown bundle.
+type blockStart struct {
+	offset  int64
+	tokens  int // Tokens before the block, with -split-tokens.
+	relPath string
+}
+
+// bundlePart is one output file of a split bundle.
+type bundlePart struct {
+	path   string
+	files  []string
+	bytes  int64
+	tokens int
+}
+
+// partPath names part n of a split bundle: bundle.md becomes bundle.part1.md.
+func partPath(outputFile string, n int) string {
+	return sidecarPath(outputFile, fmt.Sprintf(".part%d%s", n, filepath.Ext(outputFile)))
+}
+
+// splitBundle cuts the finished bundle at file block boundaries into parts
+// that each stay within limit, so no file's block is ever divided. What
+// precedes the first block (sections, headers) stays in the first part and
+// what follows the last (appendices, notices) in the last. A single block
+// over the limit gets a part of its own. The parts and an index replace
+// the bundle; it returns the index's path and the parts. The tokens of
+// each part come from the counts recorded while the bundle was written, out
+// of total, when ctx.tok is set. With limit.overlap, parts after the first
+// start with the context of overlapContext, which counts against their
+// limit.
+func splitBundle(outputFile string, starts []blockStart, limit splitLimit, total int, ctx splitContext) (string, []*bundlePart, error) {
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		return "", nil, err
+	}
+	// Segment i spans from its start to the next one's; the last block also
+	// carries the bundle's tail.
+	cuts := []int64{0}
+	for i := 1; i < len(starts); i++ {
+		cuts = append(cuts, starts[i].offset)
+	}
+	cuts = append(cuts, int64(len(data)))
+	tokens := func(i int) int { // Up to the start of segment i.
+		switch {
+		case i == 0:
+			return 0
+		case i < len(starts):
+			return starts[i].tokens
+		}
+		return total
+	}
+	over := func(p *bundlePart, b int64, t int) bool {
+		if limit.tokens > 0 {
+			return p.tokens+t > limit.tokens
+		}
+		return p.bytes+b > limit.bytes
+	}
+
+	var parts []*bundlePart
+	var contents [][]byte
+	for i := 0; i+1 < len(cuts); i++ {
+		segment := data[cuts[i]:cuts[i+1]]
+		b, t := int64(len(segment)), tokens(i+1)-tokens(i)
+		if len(parts) == 0 || (len(parts[len(parts)-1].files) > 0 && over(parts[len(parts)-1], b, t)) {
+			p := &bundlePart{path: partPath(outputFile, len(parts)+1)}
+			var preamble []byte
+			if len(parts) > 0 && limit.overlap > 0 {
+				preamble = overlapContext(ctx, limit.overlap, len(parts), data[cuts[i-1]:cuts[i]])
+				p.bytes = int64(len(preamble))
+				if ctx.tok != nil {
+					p.tokens = ctx.tok.count(preamble)
+				}
+			}
+			parts = append(parts, p)
+			contents = append(contents, preamble)
+		}
+		p := parts[len(parts)-1]
+		p.bytes += b
+		p.tokens += t
+		if i < len(starts) {
+			p.files = append(p.files, starts[i].relPath)
+		}
+		contents[len(contents)-1] = append(contents[len(contents)-1], segment...)
+		if len(p.files) == 1 && over(&bundlePart{}, p.bytes, p.tokens) {
+			log.Printf("Warning: %s alone exceeds the split limit; it gets a part of its own.", p.files[0])
+		}
+	}
+
+	for i, p := range parts {
+		if err := os.WriteFile(p.path, contents[i], 0o644); err != nil {
+			return "", nil, err
+		}
+	}
+	indexPath := sidecarPath(outputFile, ".index"+filepath.Ext(outputFile))
+	if err := os.WriteFile(indexPath, []byte(splitIndex(parts, limit, ctx)), 0o644); err != nil {
+		return "", nil, err
+	}
+	return indexPath, parts, os.Remove(outputFile)
+}
+
+// overlapContext renders the start of part n+1 (counting from 1): the tree
+// section and the last lines of the previous part's final block, which is
+// given. The lines are quoted in a plain fence rather than as a file block,
+// so tools reading the parts back do not take them for the file.
+func overlapContext(ctx splitContext, lines, n int, previous []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(ctx.tree)
+	blocks, err := ctx.style.Parse(bytes.NewReader(previous))
+	if err != nil || len(blocks) == 0 {
+		return b.Bytes() // Custom templates cannot be read back.
+	}
+	last := blocks[0]
+	content := strings.TrimSuffix(string(last.Content), "\n")
+	tail := strings.Split(content, "\n")
+	if len(tail) > lines {
+		tail = tail[len(tail)-lines:]
+	}
+	quoted := strings.Join(tail, "\n") + "\n"
+	fence := strings.Repeat("`", bundler.FenceLength([]byte(quoted), '`'))
+	fmt.Fprintf(&b, "Continued from part %d, which ends with %s; its last %d lines, for context:\n\n%stext\n%s%s\n\n", n, "/"+last.Path, len(tail), fence, quoted, fence)
+	return b.Bytes()
+}
+
+// splitIndex renders the index listing which files landed in which part,
+// with each part's tokens and cost when ctx counts and prices them.
+func splitIndex(parts []*bundlePart, limit splitLimit, ctx splitContext) string {
+	var b strings.Builder
+	budget := formatSize(limit.bytes)
+	if limit.tokens > 0 {
+		budget = fmt.Sprintf("%d tokens", limit.tokens)
+	}
+	fmt.Fprintf(&b, "Bundle split into parts of at most %s each: %d in total.\n\n", budget, len(parts))
+	if limit.overlap > 0 && len(parts) > 1 {
+		repeated := fmt.Sprintf("the last %d lines of the previous part's final file", limit.overlap)
+		if ctx.tree != "" {
+			repeated = "the project tree and " + repeated
+		}
+		fmt.Fprintf(&b, "Each part after the first starts with %s, for context.\n\n", repeated)
+	}
+	for i, p := range parts {
+		size := formatSize(p.bytes)
+		if limit.tokens > 0 {
+			size = fmt.Sprintf("%d tokens", p.tokens)
+		} else if ctx.tok != nil {
+			size += fmt.Sprintf(", %d tokens", p.tokens)
+		}
+		if ctx.tok != nil && ctx.price > 0 {
+			size += ", about " + formatCost(float64(p.tokens)/1e6*ctx.price)
+		}
+		fmt.Fprintf(&b, "## Part %d: %s (%d files, %s)\n\n", i+1, filepath.Base(p.path), len(p.files), size)
+		for _, f := range p.files {
+			fmt.Fprintf(&b, "- %s\n", filepath.ToSlash(f))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,556 @@
+// project-bundler/sqlite.go
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sqliteFilesTable is the schema of the sqlite artifact's files table, one
+// row per bundled file. The meta table holds "generated", "version",
+// "languages" (JSON, as in the JSON artifact) and, for truncated runs,
+// "truncated".
+const sqliteFilesTable = `CREATE TABLE files (
+	id INTEGER PRIMARY KEY,
+	path TEXT NOT NULL,
+	language TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	mode INTEGER NOT NULL,
+	modified TEXT,
+	placeholder INTEGER NOT NULL,
+	annotation TEXT,
+	imports TEXT,
+	exports TEXT,
+	content TEXT NOT NULL
+)`
+
+// sqliteArtifact stores the bundle in a single SQLite database, so tools can
+// look files up by path or language instead of scanning a Markdown file.
+type sqliteArtifact struct {
+	db          *sqliteDB
+	name        string
+	files, meta *sqliteTable
+
+	languages []languageShare // Written to meta when any file has a known language.
+}
+
+func newSQLiteArtifact(path string) (*sqliteArtifact, error) {
+	db, err := createSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &sqliteArtifact{db: db, name: path}
+	a.files = db.createTable("files", sqliteFilesTable)
+	a.files.createIndex("files_path", "CREATE INDEX files_path ON files (path)", 1)
+	a.meta = db.createTable("meta", "CREATE TABLE meta (key TEXT NOT NULL, value TEXT)")
+	return a, nil
+}
+
+func (a *sqliteArtifact) add(f fileEntry, raw, rendered []byte, annotation string) error {
+	var modified, imports, exports any
+	if !f.ModTime.IsZero() {
+		modified = f.ModTime.UTC().Format(time.RFC3339)
+	}
+	if im, ex, ok := summarizeSymbols(f.Lang, rendered); ok {
+		imports, exports = strings.Join(im, "\n"), strings.Join(ex, "\n")
+	}
+	var note any
+	if annotation != "" {
+		note = strings.TrimSuffix(annotation, "\n")
+	}
+	return a.db.insert(a.files, nil, filepath.ToSlash(f.RelPath), f.Lang, f.Size, int64(f.Mode.Perm()),
+		modified, sqliteBool(f.Placeholder), note, imports, exports, string(rendered))
+}
+
+func (a *sqliteArtifact) finish(truncated string) error {
+	meta := [][2]string{{"generated", time.Now().UTC().Format(time.RFC3339)}, {"version", version}}
+	if len(a.languages) > 0 {
+		data, err := json.Marshal(a.languages)
+		if err != nil {
+			return err
+		}
+		meta = append(meta, [2]string{"languages", string(data)})
+	}
+	if truncated != "" {
+		meta = append(meta, [2]string{"truncated", truncated})
+	}
+	for _, kv := range meta {
+		if err := a.db.insert(a.meta, kv[0], kv[1]); err != nil {
+			a.db.file.Close()
+			return err
+		}
+	}
+	return a.db.close()
+}
+
+func (a *sqliteArtifact) path() string { return a.name }
+
+func sqliteBool(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// sqlitePageSize is the page size of the databases sqliteDB writes.
+const sqlitePageSize = 4096
+
+// B-tree page types.
+const (
+	sqliteInteriorIndex = 0x02
+	sqliteInteriorTable = 0x05
+	sqliteLeafIndex     = 0x0a
+	sqliteLeafTable     = 0x0d
+)
+
+// sqliteDB writes a SQLite 3 database file as described in
+// https://www.sqlite.org/fileformat.html, so -format sqlite needs no driver or
+// cgo. It only writes: rows are appended to table leaves as they arrive, and
+// the interior pages, indexes and the schema on page 1 are laid out by close.
+type sqliteDB struct {
+	file   *os.File
+	pages  uint32 // Pages allocated so far, including page 1.
+	tables []*sqliteTable
+}
+
+// sqliteTable is a rowid table being filled.
+type sqliteTable struct {
+	name, sql string
+	rowid     int64
+	leaf      sqlitePage    // The leaf being filled.
+	leaves    []sqliteChild // Flushed leaves.
+	indexes   []*sqliteIndex
+}
+
+// sqliteIndex is an index on one text column, built when the database is
+// closed.
+type sqliteIndex struct {
+	name, sql string
+	column    int
+	entries   []sqliteIndexEntry
+}
+
+type sqliteIndexEntry struct {
+	key   string
+	rowid int64
+}
+
+// sqliteChild is a written page and the largest rowid under it.
+type sqliteChild struct {
+	page   uint32
+	maxKey int64
+}
+
+// sqlitePage collects the cells of one b-tree page.
+type sqlitePage struct {
+	kind   byte
+	offset int // Where the page header starts: 100 on page 1, after the database header.
+	cells  [][]byte
+	used   int // Bytes taken by the cells and their pointers.
+}
+
+func (p *sqlitePage) headerSize() int {
+	if p.kind == sqliteInteriorIndex || p.kind == sqliteInteriorTable {
+		return 12
+	}
+	return 8
+}
+
+func (p *sqlitePage) fits(cell []byte) bool {
+	return p.offset+p.headerSize()+p.used+len(cell)+2 <= sqlitePageSize
+}
+
+func (p *sqlitePage) add(cell []byte) {
+	p.cells = append(p.cells, cell)
+	p.used += len(cell) + 2
+}
+
+// pop removes the last cell and returns it.
+func (p *sqlitePage) pop() []byte {
+	cell := p.cells[len(p.cells)-1]
+	p.cells = p.cells[:len(p.cells)-1]
+	p.used -= len(cell) + 2
+	return cell
+}
+
+// encode lays the page out with its header after p.offset and the cells
+// packed at the end. right is the right-most child of interior pages.
+func (p *sqlitePage) encode(right uint32) []byte {
+	buf := make([]byte, sqlitePageSize)
+	h := buf[p.offset:]
+	h[0] = p.kind
+	binary.BigEndian.PutUint16(h[3:], uint16(len(p.cells)))
+	if p.headerSize() == 12 {
+		binary.BigEndian.PutUint32(h[8:], right)
+	}
+	end := sqlitePageSize
+	for i, cell := range p.cells {
+		end -= len(cell)
+		copy(buf[end:], cell)
+		binary.BigEndian.PutUint16(h[p.headerSize()+2*i:], uint16(end))
+	}
+	binary.BigEndian.PutUint16(h[5:], uint16(end%65536))
+	return buf
+}
+
+// createSQLite starts a database at path. Page 1 is reserved for the schema.
+func createSQLite(path string) (*sqliteDB, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteDB{file: f, pages: 1}, nil
+}
+
+// createTable adds a rowid table; sql is its CREATE TABLE statement, which
+// must match the values later passed to insert.
+func (db *sqliteDB) createTable(name, sql string) *sqliteTable {
+	t := &sqliteTable{name: name, sql: sql, leaf: sqlitePage{kind: sqliteLeafTable}}
+	db.tables = append(db.tables, t)
+	return t
+}
+
+// createIndex adds an index on a text column of t.
+func (t *sqliteTable) createIndex(name, sql string, column int) {
+	t.indexes = append(t.indexes, &sqliteIndex{name: name, sql: sql, column: column})
+}
+
+// insert appends a row to t with the next rowid. An INTEGER PRIMARY KEY
+// column is an alias of the rowid and must be passed as nil. Values are nil,
+// int64, string or []byte.
+func (db *sqliteDB) insert(t *sqliteTable, values ...any) error {
+	t.rowid++
+	for _, ix := range t.indexes {
+		key, _ := values[ix.column].(string)
+		ix.entries = append(ix.entries, sqliteIndexEntry{key, t.rowid})
+	}
+	record := sqliteRecord(values)
+	prefix := append(sqliteVarint(uint64(len(record))), sqliteVarint(uint64(t.rowid))...)
+	cell, err := db.payloadCell(prefix, record, sqlitePageSize-35)
+	if err != nil {
+		return err
+	}
+	if !t.leaf.fits(cell) {
+		if err := db.flushLeaf(t, t.rowid-1); err != nil {
+			return err
+		}
+	}
+	t.leaf.add(cell)
+	return nil
+}
+
+// flushLeaf writes the leaf being filled, whose largest rowid is maxKey.
+func (db *sqliteDB) flushLeaf(t *sqliteTable, maxKey int64) error {
+	page, err := db.writePage(&t.leaf, 0)
+	if err != nil {
+		return err
+	}
+	t.leaves = append(t.leaves, sqliteChild{page, maxKey})
+	t.leaf = sqlitePage{kind: sqliteLeafTable}
+	return nil
+}
+
+// allocate reserves the next page.
+func (db *sqliteDB) allocate() uint32 {
+	db.pages++
+	return db.pages
+}
+
+func (db *sqliteDB) writePage(p *sqlitePage, right uint32) (uint32, error) {
+	page := db.allocate()
+	_, err := db.file.WriteAt(p.encode(right), int64(page-1)*sqlitePageSize)
+	return page, err
+}
+
+// payloadCell builds a cell of prefix followed by payload, moving what does
+// not fit in the page to a chain of overflow pages. maxLocal is the largest
+// payload kept entirely on the page, which differs between table leaves and
+// index pages.
+func (db *sqliteDB) payloadCell(prefix, payload []byte, maxLocal int) ([]byte, error) {
+	cell := append([]byte(nil), prefix...)
+	if len(payload) <= maxLocal {
+		return append(cell, payload...), nil
+	}
+	const usable = sqlitePageSize
+	minLocal := (usable-12)*32/255 - 23
+	local := minLocal + (len(payload)-minLocal)%(usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	cell = append(cell, payload[:local]...)
+	rest := payload[local:]
+	cell = binary.BigEndian.AppendUint32(cell, db.pages+1)
+	for len(rest) > 0 {
+		page := db.allocate()
+		buf := make([]byte, sqlitePageSize)
+		n := copy(buf[4:], rest)
+		rest = rest[n:]
+		if len(rest) > 0 {
+			binary.BigEndian.PutUint32(buf, page+1)
+		}
+		if _, err := db.file.WriteAt(buf, int64(page-1)*sqlitePageSize); err != nil {
+			return nil, err
+		}
+	}
+	return cell, nil
+}
+
+// tableRoot writes the interior pages above a table's leaves and returns its
+// root page.
+func (db *sqliteDB) tableRoot(t *sqliteTable) (uint32, error) {
+	if len(t.leaf.cells) > 0 || len(t.leaves) == 0 {
+		if err := db.flushLeaf(t, t.rowid); err != nil {
+			return 0, err
+		}
+	}
+	level := t.leaves
+	for len(level) > 1 {
+		var next []sqliteChild
+		for start := 0; start < len(level); {
+			p := sqlitePage{kind: sqliteInteriorTable}
+			i := start
+			for i < len(level)-1 {
+				cell := binary.BigEndian.AppendUint32(nil, level[i].page)
+				cell = append(cell, sqliteVarint(uint64(level[i].maxKey))...)
+				if !p.fits(cell) {
+					break
+				}
+				p.add(cell)
+				i++
+			}
+			if i == len(level)-2 && len(p.cells) > 1 {
+				// The last child alone would make an interior page without cells.
+				p.pop()
+				i--
+			}
+			page, err := db.writePage(&p, level[i].page)
+			if err != nil {
+				return 0, err
+			}
+			next = append(next, sqliteChild{page, level[i].maxKey})
+			start = i + 1
+		}
+		level = next
+	}
+	return level[0].page, nil
+}
+
+// indexRoot sorts an index's entries and writes its b-tree. Unlike tables,
+// index interior pages hold entries of their own, which separate the pages
+// below them.
+func (db *sqliteDB) indexRoot(ix *sqliteIndex) (uint32, error) {
+	sort.Slice(ix.entries, func(i, j int) bool {
+		a, b := ix.entries[i], ix.entries[j]
+		return a.key < b.key || a.key == b.key && a.rowid < b.rowid
+	})
+	maxLocal := (sqlitePageSize-12)*64/255 - 23
+	cells := make([][]byte, len(ix.entries)) // Leaf cells; interior cells prefix a child page.
+	for i, e := range ix.entries {
+		record := sqliteRecord([]any{e.key, e.rowid})
+		cell, err := db.payloadCell(sqliteVarint(uint64(len(record))), record, maxLocal)
+		if err != nil {
+			return 0, err
+		}
+		cells[i] = cell
+	}
+
+	// Pack the leaves. The entry after each full leaf moves up as separator.
+	var children []uint32
+	var separators [][]byte
+	p := sqlitePage{kind: sqliteLeafIndex}
+	for i := 0; i < len(cells); i++ {
+		if p.fits(cells[i]) {
+			p.add(cells[i])
+			continue
+		}
+		sep := cells[i]
+		if i == len(cells)-1 {
+			// No entry would be left for the next leaf: separate with this
+			// leaf's last entry instead.
+			sep = p.pop()
+			i--
+		}
+		page, err := db.writePage(&p, 0)
+		if err != nil {
+			return 0, err
+		}
+		children, separators = append(children, page), append(separators, sep)
+		p = sqlitePage{kind: sqliteLeafIndex}
+	}
+	page, err := db.writePage(&p, 0)
+	if err != nil {
+		return 0, err
+	}
+	children = append(children, page)
+
+	for len(children) > 1 {
+		var nextChildren []uint32
+		var nextSeparators [][]byte
+		for start := 0; start < len(children); {
+			p := sqlitePage{kind: sqliteInteriorIndex}
+			i := start
+			for i < len(separators) {
+				cell := append(binary.BigEndian.AppendUint32(nil, children[i]), separators[i]...)
+				if !p.fits(cell) {
+					break
+				}
+				p.add(cell)
+				i++
+			}
+			if i == len(separators)-1 && len(p.cells) > 1 {
+				// The last child alone would make an interior page without cells.
+				p.pop()
+				i--
+			}
+			page, err := db.writePage(&p, children[i])
+			if err != nil {
+				return 0, err
+			}
+			nextChildren = append(nextChildren, page)
+			if i < len(separators) {
+				nextSeparators = append(nextSeparators, separators[i])
+			}
+			start = i + 1
+		}
+		children, separators = nextChildren, nextSeparators
+	}
+	return children[0], nil
+}
+
+// close writes the remaining pages, the schema and the database header.
+func (db *sqliteDB) close() error {
+	defer db.file.Close()
+	schema := sqlitePage{kind: sqliteLeafTable, offset: 100}
+	var rowid uint64
+	addSchema := func(kind, name, table string, root uint32, sql string) error {
+		rowid++
+		record := sqliteRecord([]any{kind, name, table, int64(root), sql})
+		cell := append(sqliteVarint(uint64(len(record))), sqliteVarint(rowid)...)
+		cell = append(cell, record...)
+		if len(record) > sqlitePageSize-35 || !schema.fits(cell) {
+			return fmt.Errorf("schema does not fit on the first page")
+		}
+		schema.add(cell)
+		return nil
+	}
+	for _, t := range db.tables {
+		root, err := db.tableRoot(t)
+		if err != nil {
+			return err
+		}
+		if err := addSchema("table", t.name, t.name, root, t.sql); err != nil {
+			return err
+		}
+		for _, ix := range t.indexes {
+			root, err := db.indexRoot(ix)
+			if err != nil {
+				return err
+			}
+			if err := addSchema("index", ix.name, t.name, root, ix.sql); err != nil {
+				return err
+			}
+		}
+	}
+
+	page := schema.encode(0)
+	h := page[:100]
+	copy(h, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(h[16:], sqlitePageSize)
+	h[18], h[19] = 1, 1                          // Legacy rollback journal.
+	h[21], h[22], h[23] = 64, 32, 32             // Payload fractions, fixed by the format.
+	binary.BigEndian.PutUint32(h[24:], 1)        // File change counter.
+	binary.BigEndian.PutUint32(h[28:], db.pages) // Database size in pages.
+	binary.BigEndian.PutUint32(h[40:], 1)        // Schema cookie.
+	binary.BigEndian.PutUint32(h[44:], 4)        // Schema format.
+	binary.BigEndian.PutUint32(h[56:], 1)        // UTF-8.
+	binary.BigEndian.PutUint32(h[92:], 1)        // Version-valid-for, matching the change counter.
+	binary.BigEndian.PutUint32(h[96:], 3045000)  // SQLite version the format follows.
+	if _, err := db.file.WriteAt(page, 0); err != nil {
+		return err
+	}
+	return db.file.Close()
+}
+
+// sqliteRecord encodes values in the record format: a header of serial types
+// followed by the values.
+func sqliteRecord(values []any) []byte {
+	var types, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			types = append(types, 0)
+		case int64:
+			serial, data := sqliteInt(v)
+			types = append(types, sqliteVarint(serial)...)
+			body = append(body, data...)
+		case string:
+			types = append(types, sqliteVarint(uint64(13+2*len(v)))...)
+			body = append(body, v...)
+		case []byte:
+			types = append(types, sqliteVarint(uint64(12+2*len(v)))...)
+			body = append(body, v...)
+		default:
+			panic(fmt.Sprintf("sqliteRecord: unsupported value %T", v))
+		}
+	}
+	size := len(types) + 1
+	for len(sqliteVarint(uint64(size)))+len(types) != size {
+		size = len(sqliteVarint(uint64(size))) + len(types)
+	}
+	record := append(sqliteVarint(uint64(size)), types...)
+	return append(record, body...)
+}
+
+// sqliteInt returns the smallest serial type for v and its big-endian bytes.
+func sqliteInt(v int64) (uint64, []byte) {
+	switch {
+	case v == 0:
+		return 8, nil
+	case v == 1:
+		return 9, nil
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(v))
+	for _, t := range []struct {
+		serial uint64
+		n      int
+	}{{1, 1}, {2, 2}, {3, 3}, {4, 4}, {5, 6}} {
+		if bits := 8 * t.n; v >= -1<<(bits-1) && v < 1<<(bits-1) {
+			return t.serial, buf[8-t.n:]
+		}
+	}
+	return 6, buf[:]
+}
+
+// sqliteVarint encodes v as a SQLite varint: big-endian groups of seven
+// bits, with the ninth byte, if any, carrying a full eight.
+func sqliteVarint(v uint64) []byte {
+	if v>>56 != 0 {
+		b := make([]byte, 9)
+		b[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			b[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return b
+	}
+	var groups []byte
+	for {
+		groups = append([]byte{byte(v & 0x7f)}, groups...)
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	for i := range groups[:len(groups)-1] {
+		groups[i] |= 0x80
+	}
+	return groups
+}
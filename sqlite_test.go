@@ -0,0 +1,313 @@
+// project-bundler/sqlite_test.go
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestSQLiteRoundTrip writes a database large enough for interior pages and
+// overflow chains, then reads it back by following the file format: the
+// header, the schema on page 1 and the table and index b-trees.
+func TestSQLiteRoundTrip(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "bundle.sqlite")
+	db, err := createSQLite(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := db.createTable("files", "CREATE TABLE files (id INTEGER PRIMARY KEY, path TEXT NOT NULL, size INTEGER, content TEXT)")
+	files.createIndex("files_path", "CREATE INDEX files_path ON files (path)", 1)
+	meta := db.createTable("meta", "CREATE TABLE meta (key TEXT NOT NULL, value TEXT)")
+	var want [][]any
+	for i := 0; i < 3000; i++ {
+		// Paths out of order so the index has to sort; some contents span
+		// several overflow pages.
+		path := fmt.Sprintf("dir%d/file%04d.go", i%7, (i*7919)%3000)
+		content := strings.Repeat(fmt.Sprintf("line %d\n", i), 1+i%5)
+		if i%250 == 0 {
+			content = strings.Repeat("x", 3*sqlitePageSize+i)
+		}
+		size := []int64{0, 1, 127, 1 << 20, -5, 1 << 40}[i%6]
+		row := []any{nil, path, size, content}
+		if i%11 == 0 {
+			row[2] = nil
+		}
+		if err := db.insert(files, row...); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, row)
+	}
+	if err := db.insert(meta, "version", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &sqliteReader{t: t, data: data}
+	if got := string(data[:16]); got != "SQLite format 3\x00" {
+		t.Fatalf("header string %q", got)
+	}
+	if got := binary.BigEndian.Uint16(data[16:]); got != sqlitePageSize {
+		t.Errorf("page size %d, want %d", got, sqlitePageSize)
+	}
+	if data[21] != 64 || data[22] != 32 || data[23] != 32 {
+		t.Errorf("payload fractions %d %d %d, want 64 32 32", data[21], data[22], data[23])
+	}
+	if pages := binary.BigEndian.Uint32(data[28:]); int(pages)*sqlitePageSize != len(data) {
+		t.Errorf("header counts %d pages, the file has %d bytes", pages, len(data))
+	}
+	if binary.BigEndian.Uint32(data[24:]) != binary.BigEndian.Uint32(data[92:]) {
+		t.Error("version-valid-for does not match the change counter")
+	}
+	if got := binary.BigEndian.Uint32(data[56:]); got != 1 {
+		t.Errorf("text encoding %d, want 1 (UTF-8)", got)
+	}
+
+	schema := r.table(1)
+	if len(schema) != 3 {
+		t.Fatalf("schema has %d rows, want 3", len(schema))
+	}
+	roots := make(map[string]uint32)
+	for i, w := range [][3]string{{"table", "files", "files"}, {"index", "files_path", "files"}, {"table", "meta", "meta"}} {
+		row := schema[i].values
+		if row[0] != w[0] || row[1] != w[1] || row[2] != w[2] {
+			t.Errorf("schema row %d is %v, want %v", i+1, row[:3], w)
+		}
+		roots[w[1]] = uint32(row[3].(int64))
+	}
+
+	rows := r.table(roots["files"])
+	if len(rows) != len(want) {
+		t.Fatalf("files has %d rows, want %d", len(rows), len(want))
+	}
+	for i, row := range rows {
+		if row.rowid != int64(i+1) {
+			t.Fatalf("row %d has rowid %d", i+1, row.rowid)
+		}
+		for c := 1; c < len(want[i]); c++ {
+			if row.values[c] != want[i][c] {
+				t.Fatalf("row %d column %d is %.40v, want %.40v", i+1, c, row.values[c], want[i][c])
+			}
+		}
+	}
+
+	entries := r.index(roots["files_path"])
+	if len(entries) != len(want) {
+		t.Fatalf("files_path has %d entries, want %d", len(entries), len(want))
+	}
+	if !sort.SliceIsSorted(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		return a[0].(string) < b[0].(string) || a[0] == b[0] && a[1].(int64) < b[1].(int64)
+	}) {
+		t.Error("files_path entries are not in order")
+	}
+	for _, e := range entries {
+		if rowid := e[1].(int64); want[rowid-1][1] != e[0] {
+			t.Errorf("files_path maps %v to row %d, which has path %v", e[0], rowid, want[rowid-1][1])
+		}
+	}
+
+	if m := r.table(roots["meta"]); len(m) != 1 || m[0].values[0] != "version" || m[0].values[1] != "test" {
+		t.Errorf("meta is %v", m)
+	}
+}
+
+// sqliteReader decodes the pages of a database written by sqliteDB.
+type sqliteReader struct {
+	t    *testing.T
+	data []byte
+}
+
+type sqliteRow struct {
+	rowid  int64
+	values []any
+}
+
+func (r *sqliteReader) page(n uint32) (page []byte, header int) {
+	if n < 1 || int(n)*sqlitePageSize > len(r.data) {
+		r.t.Fatalf("page %d out of range", n)
+	}
+	page = r.data[int(n-1)*sqlitePageSize : int(n)*sqlitePageSize]
+	if n == 1 {
+		header = 100
+	}
+	return page, header
+}
+
+// cells returns the cell offsets of a page and, for interior pages, the
+// right-most child.
+func (r *sqliteReader) cells(page []byte, header int) (kind byte, offsets []int, right uint32) {
+	kind = page[header]
+	n := int(binary.BigEndian.Uint16(page[header+3:]))
+	size := 8
+	if kind == sqliteInteriorIndex || kind == sqliteInteriorTable {
+		size = 12
+		right = binary.BigEndian.Uint32(page[header+8:])
+	}
+	for i := 0; i < n; i++ {
+		offsets = append(offsets, int(binary.BigEndian.Uint16(page[header+size+2*i:])))
+	}
+	return kind, offsets, right
+}
+
+// table returns the rows of the table b-tree rooted at root, checking that
+// rowids ascend and stay within the keys of the interior cells.
+func (r *sqliteReader) table(root uint32) []sqliteRow {
+	var rows []sqliteRow
+	var walk func(n uint32, max int64)
+	walk = func(n uint32, max int64) {
+		page, header := r.page(n)
+		kind, offsets, right := r.cells(page, header)
+		switch kind {
+		case sqliteLeafTable:
+			for _, off := range offsets {
+				size, k := readVarint(page[off:])
+				rowid, k2 := readVarint(page[off+k:])
+				payload := r.payload(page[off+k+k2:], int(size), sqlitePageSize-35)
+				if len(rows) > 0 && int64(rowid) <= rows[len(rows)-1].rowid || int64(rowid) > max {
+					r.t.Fatalf("page %d: rowid %d out of order", n, rowid)
+				}
+				rows = append(rows, sqliteRow{int64(rowid), decodeRecord(r.t, payload)})
+			}
+		case sqliteInteriorTable:
+			if len(offsets) == 0 {
+				r.t.Fatalf("interior page %d has no cells", n)
+			}
+			for _, off := range offsets {
+				key, _ := readVarint(page[off+4:])
+				walk(binary.BigEndian.Uint32(page[off:]), int64(key))
+			}
+			walk(right, max)
+		default:
+			r.t.Fatalf("page %d has type %#x in a table", n, kind)
+		}
+	}
+	walk(root, 1<<62)
+	return rows
+}
+
+// index returns the entries of the index b-tree rooted at root in tree
+// order, interior entries between the subtrees they separate.
+func (r *sqliteReader) index(root uint32) [][]any {
+	var entries [][]any
+	maxLocal := (sqlitePageSize-12)*64/255 - 23
+	entry := func(cell []byte) []any {
+		size, k := readVarint(cell)
+		return decodeRecord(r.t, r.payload(cell[k:], int(size), maxLocal))
+	}
+	var walk func(n uint32)
+	walk = func(n uint32) {
+		page, header := r.page(n)
+		kind, offsets, right := r.cells(page, header)
+		switch kind {
+		case sqliteLeafIndex:
+			for _, off := range offsets {
+				entries = append(entries, entry(page[off:]))
+			}
+		case sqliteInteriorIndex:
+			if len(offsets) == 0 {
+				r.t.Fatalf("interior page %d has no cells", n)
+			}
+			for _, off := range offsets {
+				walk(binary.BigEndian.Uint32(page[off:]))
+				entries = append(entries, entry(page[off+4:]))
+			}
+			walk(right)
+		default:
+			r.t.Fatalf("page %d has type %#x in an index", n, kind)
+		}
+	}
+	walk(root)
+	return entries
+}
+
+// payload reads size bytes of payload starting at cell, following the
+// overflow chain when it is larger than maxLocal.
+func (r *sqliteReader) payload(cell []byte, size, maxLocal int) []byte {
+	if size <= maxLocal {
+		return cell[:size]
+	}
+	minLocal := (sqlitePageSize-12)*32/255 - 23
+	local := minLocal + (size-minLocal)%(sqlitePageSize-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	out := append([]byte(nil), cell[:local]...)
+	next := binary.BigEndian.Uint32(cell[local:])
+	for len(out) < size {
+		if next == 0 {
+			r.t.Fatalf("overflow chain ends %d bytes short", size-len(out))
+		}
+		page, _ := r.page(next)
+		n := min(size-len(out), sqlitePageSize-4)
+		out = append(out, page[4:4+n]...)
+		next = binary.BigEndian.Uint32(page)
+	}
+	if next != 0 {
+		r.t.Fatalf("overflow chain continues past the payload")
+	}
+	return out
+}
+
+func readVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v<<8 | uint64(b[8]), 9
+}
+
+// decodeRecord decodes a record into nil, int64, string and []byte values.
+func decodeRecord(t *testing.T, record []byte) []any {
+	size, k := readVarint(record)
+	var types []uint64
+	for k < int(size) {
+		typ, n := readVarint(record[k:])
+		types, k = append(types, typ), k+n
+	}
+	body := record[size:]
+	var values []any
+	for _, typ := range types {
+		switch {
+		case typ == 0:
+			values = append(values, nil)
+		case typ == 8, typ == 9:
+			values = append(values, int64(typ-8))
+		case typ >= 1 && typ <= 6:
+			n := []int{0, 1, 2, 3, 4, 6, 8}[typ]
+			var v int64
+			for _, c := range body[:n] {
+				v = v<<8 | int64(c)
+			}
+			if shift := 64 - 8*n; shift > 0 {
+				v = v << shift >> shift // Sign-extend.
+			}
+			values, body = append(values, v), body[n:]
+		case typ >= 12 && typ%2 == 0:
+			n := int(typ-12) / 2
+			values, body = append(values, append([]byte(nil), body[:n]...)), body[n:]
+		case typ >= 13:
+			n := int(typ-13) / 2
+			values, body = append(values, string(body[:n])), body[n:]
+		default:
+			t.Fatalf("unexpected serial type %d", typ)
+		}
+	}
+	if len(body) != 0 {
+		t.Fatalf("record has %d bytes past its values", len(body))
+	}
+	return values
+}
@@ -0,0 +1,211 @@
+// project-bundler/stats.go
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// statsRecord is one line of the opt-in local usage stats file. Nothing in it
+// ever leaves the machine; it only exists so users can tune their workflows.
+type statsRecord struct {
+	Time         time.Time       `json:"time"`
+	Source       string          `json:"source"`
+	ProjectType  string          `json:"project_type"`
+	FilesBundled int             `json:"files_bundled"`
+	FilesSkipped int             `json:"files_skipped"`
+	Skipped      map[string]int  `json:"skipped,omitempty"` // By reason code.
+	Bytes        int64           `json:"bytes"`
+	DurationMS   int64           `json:"duration_ms"`
+	Flags        []string        `json:"flags"`
+	Languages    []languageShare `json:"languages,omitempty"`
+}
+
+// recordStats appends a record for the current run to the stats file.
+func recordStats(path string, opts bundleOptions, result bundleResult, elapsed time.Duration) error {
+	source, err := filepath.Abs(opts.SrcDir)
+	if err != nil {
+		source = opts.SrcDir
+	}
+
+	// Only flag names are recorded, never their values.
+	var flags []string
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "stats-file" {
+			flags = append(flags, f.Name)
+		}
+	})
+
+	line, err := json.Marshal(statsRecord{
+		Time:         time.Now().UTC(),
+		Source:       source,
+		ProjectType:  opts.ProjectType,
+		FilesBundled: result.filesBundled,
+		FilesSkipped: result.filesSkipped,
+		Skipped:      result.skipped,
+		Bytes:        result.bytesWritten,
+		DurationMS:   elapsed.Milliseconds(),
+		Flags:        flags,
+		Languages:    result.languages,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// loadStats reads every record from the stats file, skipping malformed lines.
+func loadStats(path string) ([]statsRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []statsRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var r statsRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// runStats implements the `stats` subcommand, summarizing recorded runs.
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	statsFile := fs.String("stats-file", os.Getenv("PROJECT_BUNDLER_STATS_FILE"), "Stats file to read (defaults to $PROJECT_BUNDLER_STATS_FILE).")
+	recent := fs.Int("recent", 10, "Number of most recent runs to list.")
+	htmlFile := fs.String("html", "", "Instead of summarizing runs, write a treemap of the bytes and tokens per directory of -src's bundle to this HTML file.")
+	srcDir := fs.String("src", ".", "Source project directory for -html.")
+	projectType := fs.String("type", "auto", "Project type for -html. Options: "+strings.Join(availableProjectTypes(), ", "))
+	model := fs.String("model", "gpt-4o", "Target model or tokenizer used to count tokens for -html.")
+	fs.Parse(args)
+
+	if *htmlFile != "" {
+		writeTreemap(*htmlFile, *srcDir, *projectType, *model)
+		return
+	}
+
+	if *statsFile == "" {
+		log.Fatalf("No stats file configured. Record runs with -stats-file or set PROJECT_BUNDLER_STATS_FILE.")
+	}
+	records, err := loadStats(*statsFile)
+	if err != nil {
+		log.Fatalf("Could not read stats file: %v", err)
+	}
+	if len(records) == 0 {
+		fmt.Println("No runs recorded yet.")
+		return
+	}
+
+	var totalBytes, totalMS int64
+	minBytes, maxBytes := records[0].Bytes, records[0].Bytes
+	flagCounts := make(map[string]int)
+	for _, r := range records {
+		totalBytes += r.Bytes
+		totalMS += r.DurationMS
+		minBytes = min(minBytes, r.Bytes)
+		maxBytes = max(maxBytes, r.Bytes)
+		for _, f := range r.Flags {
+			flagCounts[f]++
+		}
+	}
+	n := int64(len(records))
+
+	fmt.Printf("Runs recorded: %d (%s to %s)\n", n, records[0].Time.Local().Format("2006-01-02"), records[n-1].Time.Local().Format("2006-01-02"))
+	fmt.Printf("Bundle size:   avg %s, min %s, max %s\n", formatSize(totalBytes/n), formatSize(minBytes), formatSize(maxBytes))
+	fmt.Printf("Duration:      avg %s\n", time.Duration(totalMS/n)*time.Millisecond)
+
+	if len(flagCounts) > 0 {
+		names := make([]string, 0, len(flagCounts))
+		for name := range flagCounts {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if flagCounts[names[i]] != flagCounts[names[j]] {
+				return flagCounts[names[i]] > flagCounts[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		fmt.Println("\nMost-used flags:")
+		for _, name := range names {
+			fmt.Printf("  -%-20s %d runs\n", name, flagCounts[name])
+		}
+	}
+
+	// Language shares come from the most recent run that recorded them, as
+	// older stats files predate them.
+	for i := len(records) - 1; i >= 0; i-- {
+		if len(records[i].Languages) > 0 {
+			fmt.Printf("\nLanguages (run of %s, %s):\n", records[i].Time.Local().Format("2006-01-02 15:04"), records[i].Source)
+			for _, l := range records[i].Languages {
+				fmt.Printf("  %-20s %5.1f%%  %10s\n", l.Language, l.Percent, formatSize(l.Bytes))
+			}
+			break
+		}
+	}
+
+	// The recent runs list shows the trend, with the change in size from the previous run.
+	start := max(0, len(records)-*recent)
+	fmt.Println("\nRecent runs:")
+	for i := start; i < len(records); i++ {
+		r := records[i]
+		trend := ""
+		if i > 0 {
+			trend = fmt.Sprintf("(%+.1f%%)", percent(r.Bytes-records[i-1].Bytes, max(records[i-1].Bytes, 1)))
+		}
+		fmt.Printf("  %s  %-8s %5d files  %10s %-10s %6dms  %s\n",
+			r.Time.Local().Format("2006-01-02 15:04"), r.ProjectType, r.FilesBundled, formatSize(r.Bytes), trend, r.DurationMS, r.Source)
+	}
+}
+
+// writeTreemap implements `stats -html`, measuring the bundle -src would
+// produce with the project type's default filters.
+func writeTreemap(htmlFile, srcDir, projectType, model string) {
+	tok, err := tokenizerForModel(model)
+	if err != nil {
+		log.Fatalf("Invalid -model: %v", err)
+	}
+	opts, err := resolveOptions(srcDir, projectType, "", "", false)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	root, err := buildSizeTree(opts, tok)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	file, err := os.Create(htmlFile)
+	if err != nil {
+		log.Fatalf("Could not create '%s': %v", htmlFile, err)
+	}
+	if err := writeTreemapHTML(file, root, tok.name()); err != nil {
+		file.Close()
+		log.Fatalf("Could not write '%s': %v", htmlFile, err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatalf("Could not write '%s': %v", htmlFile, err)
+	}
+	fmt.Printf("Wrote treemap of %s (%d tokens) to '%s'\n", formatSize(root.Bytes), root.Tokens, htmlFile)
+}
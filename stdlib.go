@@ -0,0 +1,116 @@
+// project-bundler/stdlib.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// notableStdlib are imports reviewers want to know about: they bypass type
+// safety, pull in cgo or reach into the operating system directly.
+var notableStdlib = map[string]string{
+	"unsafe":  "bypasses type safety",
+	"reflect": "runtime reflection",
+	"C":       "cgo",
+	"syscall": "raw system calls",
+	"os/exec": "runs external programs",
+	"plugin":  "loads code at run time",
+}
+
+// isGoStdlib reports whether a Go import path belongs to the standard
+// library: its first element has no dot, unlike every module path.
+func isGoStdlib(imp string) bool {
+	first, _, _ := strings.Cut(imp, "/")
+	return !strings.Contains(first, ".")
+}
+
+// stdlibUsage aggregates the standard library imports of one package.
+type stdlibUsage struct {
+	pkg     string
+	imports map[string]int      // Import path -> number of files importing it.
+	notable map[string][]string // Notable import -> files importing it.
+}
+
+// findStdlibUsage collects the standard library imports of the bundled Go
+// files, grouped by directory.
+func findStdlibUsage(opts bundleOptions, files []fileEntry) []stdlibUsage {
+	module := readModuleLine(filepath.Join(opts.SrcDir, "go.mod"), "module ")
+	byPackage := make(map[string]*stdlibUsage)
+	for _, f := range files {
+		if f.Lang != "go" || f.Placeholder {
+			continue
+		}
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			continue // Reported when the file itself is bundled.
+		}
+		imports, _, ok := summarizeGoSymbols(content)
+		if !ok {
+			continue
+		}
+		rel := filepath.ToSlash(f.RelPath)
+		pkg := path.Dir(rel)
+		u := byPackage[pkg]
+		if u == nil {
+			u = &stdlibUsage{pkg: pkg, imports: make(map[string]int), notable: make(map[string][]string)}
+			byPackage[pkg] = u
+		}
+		for _, imp := range imports {
+			// A module without a dot (e.g. "myapp") looks like the standard library.
+			if !isGoStdlib(imp) || module != "" && (imp == module || strings.HasPrefix(imp, module+"/")) {
+				continue
+			}
+			u.imports[imp]++
+			if _, ok := notableStdlib[imp]; ok {
+				u.notable[imp] = append(u.notable[imp], rel)
+			}
+		}
+	}
+
+	var usage []stdlibUsage
+	for _, u := range byPackage {
+		if len(u.imports) > 0 {
+			usage = append(usage, *u)
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].pkg < usage[j].pkg })
+	return usage
+}
+
+// writeStdlibAppendix appends the per-package standard library usage and
+// calls out the notable imports with the files that use them.
+func writeStdlibAppendix(w io.Writer, usage []stdlibUsage) error {
+	if len(usage) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Appendix: standard library usage (%d packages):\n\n| Package | Imports (files) |\n|---|---|\n", len(usage))
+	var notable []string
+	for _, u := range usage {
+		var imps []string
+		for imp := range u.imports {
+			imps = append(imps, imp)
+		}
+		sort.Strings(imps)
+		cells := make([]string, len(imps))
+		for i, imp := range imps {
+			cells[i] = fmt.Sprintf("`%s` (%d)", imp, u.imports[imp])
+			if files, ok := u.notable[imp]; ok {
+				notable = append(notable, fmt.Sprintf("- `%s` (%s): %s", imp, notableStdlib[imp], strings.Join(files, ", ")))
+			}
+		}
+		fmt.Fprintf(&b, "| `%s` | %s |\n", u.pkg, strings.Join(cells, ", "))
+	}
+	if len(notable) > 0 {
+		sort.Strings(notable)
+		b.WriteString("\nNotable imports:\n\n" + strings.Join(notable, "\n") + "\n")
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,352 @@
+// project-bundler/strip.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// contentTransform rewrites a file's content for the bundle.
+type contentTransform func(content []byte) []byte
+
+// commentSyntax describes how a language writes comments and string
+// literals, so that comment markers inside strings are left alone.
+type commentSyntax struct {
+	line        []string    // Line comment markers.
+	block       [][2]string // Block comment delimiters.
+	nested      bool        // Block comments nest, as in Rust, Swift and Kotlin.
+	quotes      []quote     // String delimiters; longer ones first.
+	markerAfter string      // If set, a line comment must follow one of these bytes or start the line.
+	keep        func(comment string) bool
+}
+
+type quote struct {
+	open, close string
+	escapes     bool // A backslash escapes the next byte.
+}
+
+var (
+	cQuotes      = []quote{{`"`, `"`, true}, {`'`, `'`, true}}
+	cLikeComment = commentSyntax{line: []string{"//"}, block: [][2]string{{"/*", "*/"}}, quotes: cQuotes}
+	goComment    = commentSyntax{
+		line: []string{"//"}, block: [][2]string{{"/*", "*/"}},
+		quotes: []quote{{`"`, `"`, true}, {`'`, `'`, true}, {"`", "`", false}},
+		keep:   isGoDirective,
+	}
+	kotlinComment = commentSyntax{
+		line: []string{"//"}, block: [][2]string{{"/*", "*/"}}, nested: true,
+		quotes: []quote{{`"""`, `"""`, false}, {`"`, `"`, true}, {`'`, `'`, true}},
+	}
+	swiftComment = commentSyntax{
+		line: []string{"//"}, block: [][2]string{{"/*", "*/"}}, nested: true,
+		quotes: []quote{{`"""`, `"""`, true}, {`"`, `"`, true}},
+	}
+	// Rust's single quote also starts lifetimes, so only double quotes
+	// delimit strings.
+	rustComment = commentSyntax{
+		line: []string{"//"}, block: [][2]string{{"/*", "*/"}}, nested: true,
+		quotes: []quote{{`"`, `"`, true}},
+	}
+	pythonComment = commentSyntax{
+		line:   []string{"#"},
+		quotes: []quote{{`"""`, `"""`, true}, {`'''`, `'''`, true}, {`"`, `"`, true}, {`'`, `'`, true}},
+		keep:   isPythonPragma,
+	}
+	shellComment = commentSyntax{
+		line: []string{"#"}, quotes: cQuotes, markerAfter: " \t;",
+		keep: func(c string) bool { return strings.HasPrefix(c, "#!") },
+	}
+	cssComment = commentSyntax{block: [][2]string{{"/*", "*/"}}, quotes: cQuotes}
+)
+
+// commentStrippers are the -strip-comments transforms by language. A
+// language without an entry is bundled with its comments.
+var commentStrippers = map[string]contentTransform{
+	"go": func(content []byte) []byte {
+		// The comment before import "C" is cgo's C source, not a comment.
+		if bytes.Contains(content, []byte(`import "C"`)) {
+			return content
+		}
+		return goComment.strip(content)
+	},
+	"java":       cLikeComment.strip,
+	"csharp":     cLikeComment.strip,
+	"dart":       cLikeComment.strip,
+	"groovy":     cLikeComment.strip,
+	"objectivec": cLikeComment.strip,
+	"kotlin":     kotlinComment.strip,
+	"swift":      swiftComment.strip,
+	"rust":       rustComment.strip,
+	// A "//" in a regular expression literal follows a backslash or another
+	// slash, never a space or punctuation that ends a statement.
+	"javascript": jsComment.strip,
+	"typescript": jsComment.strip,
+	"python":     pythonComment.strip,
+	"shell":      shellComment.strip,
+	"yaml":       stripYAMLComments,
+	"css":        cssComment.strip,
+	"scss":       cLikeComment.strip,
+	"less":       cLikeComment.strip,
+}
+
+var jsComment = commentSyntax{
+	line: []string{"//"}, block: [][2]string{{"/*", "*/"}},
+	quotes:      []quote{{`"`, `"`, true}, {`'`, `'`, true}, {"`", "`", true}},
+	markerAfter: " \t;,{}()[]",
+	keep:        func(c string) bool { return strings.HasPrefix(c, "/// <reference") },
+}
+
+// isGoDirective keeps the comments the Go toolchain reads.
+func isGoDirective(c string) bool {
+	for _, prefix := range []string{"//go:", "// +build", "//line ", "//export ", "//nolint"} {
+		if strings.HasPrefix(c, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// pythonPragmaRE matches the comments Python and its tools read: the
+// encoding declaration, type comments and linter pragmas.
+var pythonPragmaRE = regexp.MustCompile(`^#!|coding[:=]|^#\s*(?:type:|noqa|pragma|pylint:|mypy:)`)
+
+func isPythonPragma(c string) bool { return pythonPragmaRE.MatchString(c) }
+
+// strip removes the comments from content. Comments that keep accepts stay.
+// Lines left empty by the removal are dropped; other blank lines stay.
+func (s commentSyntax) strip(content []byte) []byte {
+	var out bytes.Buffer
+	lineStart := 0    // Offset in out of the current line.
+	stripped := false // A comment was removed from the current line.
+	// trimLine removes the whitespace a comment left at the end of the
+	// current line, and the line itself if nothing else is on it. It
+	// reports whether the line is still there.
+	trimLine := func() bool {
+		if !stripped {
+			return true
+		}
+		stripped = false
+		line := out.Bytes()[lineStart:]
+		trimmed := bytes.TrimRight(line, " \t\r")
+		if len(bytes.TrimSpace(trimmed)) == 0 {
+			out.Truncate(lineStart)
+			return false
+		}
+		cr := bytes.HasSuffix(line, []byte("\r"))
+		out.Truncate(lineStart + len(trimmed))
+		if cr {
+			out.WriteByte('\r')
+		}
+		return true
+	}
+	endLine := func() {
+		if trimLine() {
+			out.WriteByte('\n')
+		}
+		lineStart = out.Len()
+	}
+
+	src := string(content)
+	for i := 0; i < len(src); {
+		if src[i] == '\n' {
+			endLine()
+			i++
+			continue
+		}
+		if q, ok := s.quoteAt(src, i); ok {
+			end := closeQuote(src, i+len(q.open), q)
+			literal := src[i:end]
+			out.WriteString(literal)
+			if nl := strings.LastIndexByte(literal, '\n'); nl >= 0 {
+				// A multi-line string: the current line is its last one.
+				lineStart = out.Len() - (len(literal) - nl - 1)
+				stripped = false
+			}
+			i = end
+			continue
+		}
+		if s.lineCommentAt(src, i, out.Bytes()[lineStart:]) {
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			comment := strings.TrimSuffix(src[i:i+end], "\r")
+			if s.keep != nil && s.keep(comment) {
+				out.WriteString(comment)
+			} else {
+				stripped = true
+			}
+			i += len(comment)
+			continue
+		}
+		if open, close, ok := s.blockCommentAt(src, i); ok {
+			end := s.closeBlock(src, i+len(open), open, close)
+			comment := src[i:end]
+			switch {
+			case s.keep != nil && s.keep(comment):
+				out.WriteString(comment)
+			case strings.Contains(comment, "\n"):
+				// Code after the comment goes on a line of its own.
+				stripped = true
+				endLine()
+				stripped = true
+			default:
+				// Keep the tokens on either side apart, by one space.
+				stripped = true
+				if o := out.Bytes(); len(o) > lineStart && o[len(o)-1] != ' ' && o[len(o)-1] != '\t' {
+					out.WriteByte(' ')
+				} else {
+					for end < len(src) && (src[end] == ' ' || src[end] == '\t') {
+						end++
+					}
+				}
+			}
+			i = end
+			continue
+		}
+		out.WriteByte(src[i])
+		i++
+	}
+	trimLine()
+	return out.Bytes()
+}
+
+func (s commentSyntax) quoteAt(src string, i int) (quote, bool) {
+	for _, q := range s.quotes {
+		if strings.HasPrefix(src[i:], q.open) {
+			return q, true
+		}
+	}
+	return quote{}, false
+}
+
+// closeQuote returns the offset just past the end of the string whose
+// content starts at i. An unterminated string runs to the end of its line,
+// so one stray quote does not hide the rest of the file.
+func closeQuote(src string, i int, q quote) int {
+	for i < len(src) {
+		switch {
+		case q.escapes && src[i] == '\\':
+			i += 2
+		case strings.HasPrefix(src[i:], q.close):
+			return i + len(q.close)
+		case src[i] == '\n' && len(q.close) == 1 && q.close != "`":
+			return i
+		default:
+			i++
+		}
+	}
+	return len(src)
+}
+
+func (s commentSyntax) lineCommentAt(src string, i int, line []byte) bool {
+	for _, marker := range s.line {
+		if !strings.HasPrefix(src[i:], marker) {
+			continue
+		}
+		if s.markerAfter != "" && len(line) > 0 && !strings.ContainsRune(s.markerAfter, rune(line[len(line)-1])) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func (s commentSyntax) blockCommentAt(src string, i int) (open, close string, ok bool) {
+	for _, b := range s.block {
+		if strings.HasPrefix(src[i:], b[0]) {
+			return b[0], b[1], true
+		}
+	}
+	return "", "", false
+}
+
+// closeBlock returns the offset just past the end of the block comment
+// whose content starts at i.
+func (s commentSyntax) closeBlock(src string, i int, open, close string) int {
+	depth := 1
+	for i < len(src) {
+		switch {
+		case strings.HasPrefix(src[i:], close):
+			i += len(close)
+			if depth--; depth == 0 {
+				return i
+			}
+		case s.nested && strings.HasPrefix(src[i:], open):
+			i += len(open)
+			depth++
+		default:
+			i++
+		}
+	}
+	return len(src)
+}
+
+// yamlBlockScalarRE matches a line that starts a block scalar, whose
+// following, more indented lines are text in which "#" is not a comment.
+var yamlBlockScalarRE = regexp.MustCompile(`[:-]\s*[|>][-+0-9]*\s*$`)
+
+// stripYAMLComments removes YAML comments: a "#" at the start of a line or
+// after whitespace, outside quotes and block scalars.
+func stripYAMLComments(content []byte) []byte {
+	syntax := commentSyntax{line: []string{"#"}, quotes: []quote{{`"`, `"`, true}, {`'`, `'`, false}}, markerAfter: " \t"}
+	lines := strings.SplitAfter(string(content), "\n")
+	var out strings.Builder
+	scalarIndent := -1 // Indentation of the line that opened a block scalar.
+	for _, line := range lines {
+		body := strings.TrimRight(line, "\r\n")
+		indent := len(body) - len(strings.TrimLeft(body, " "))
+		if scalarIndent >= 0 {
+			if strings.TrimSpace(body) == "" || indent > scalarIndent {
+				out.WriteString(line)
+				continue
+			}
+			scalarIndent = -1
+		}
+		kept := line
+		if strings.Contains(line, "#") {
+			kept = string(syntax.strip([]byte(line)))
+			if kept == "" {
+				continue
+			}
+		}
+		if yamlBlockScalarRE.MatchString(strings.TrimRight(kept, "\r\n")) {
+			scalarIndent = indent
+		}
+		out.WriteString(kept)
+	}
+	return []byte(out.String())
+}
+
+// compactWhitespace implements -compact: trailing whitespace goes and runs
+// of blank lines collapse into one. Indentation stays, as it is significant
+// in Python and YAML. Valid JSON is minified.
+func compactWhitespace(lang string, content []byte) []byte {
+	if lang == "json" {
+		var b bytes.Buffer
+		if json.Compact(&b, content) == nil {
+			return b.Bytes()
+		}
+	}
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	var out []string
+	blank := true // Drops leading blank lines.
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		out = append(out, line)
+	}
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return []byte(strings.Join(out, "\n") + "\n")
+}
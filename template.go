@@ -0,0 +1,34 @@
+// project-bundler/template.go
+package main
+
+import (
+	"io"
+	"path/filepath"
+)
+
+// bundleDocument is what the "header" and "footer" templates of -template
+// are executed with.
+type bundleDocument struct {
+	Source      string // The source directory, as given to -src.
+	Name        string // Its base name.
+	ProjectType string
+	Files       int    // Files to bundle for the header, files bundled for the footer.
+	Truncated   string // Why the bundle was cut short, in the footer; "" if it was not.
+}
+
+// writeDocumentTemplate executes the named template of -template, if the
+// template file defines it.
+func writeDocumentTemplate(w io.Writer, opts bundleOptions, name string, files int, truncated string) error {
+	t := opts.Style.Template
+	if t == nil || t.Lookup(name) == nil {
+		return nil
+	}
+	abs, _ := filepath.Abs(opts.SrcDir)
+	return t.ExecuteTemplate(w, name, bundleDocument{
+		Source:      opts.SrcDir,
+		Name:        filepath.Base(abs),
+		ProjectType: opts.ProjectType,
+		Files:       files,
+		Truncated:   truncated,
+	})
+}
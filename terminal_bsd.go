@@ -0,0 +1,9 @@
+//go:build darwin || freebsd || netbsd || dragonfly
+
+// project-bundler/terminal_bsd.go
+package main
+
+import "syscall"
+
+// ioctlGetTermios is the ioctl that reads a terminal's attributes.
+const ioctlGetTermios = syscall.TIOCGETA
@@ -0,0 +1,7 @@
+// project-bundler/terminal_linux.go
+package main
+
+import "syscall"
+
+// ioctlGetTermios is the ioctl that reads a terminal's attributes.
+const ioctlGetTermios = syscall.TCGETS
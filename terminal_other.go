@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !dragonfly && !windows
+
+// project-bundler/terminal_other.go
+package main
+
+import "os"
+
+// isTerminal reports whether f is a character device such as a console.
+// Without a portable isatty(3) here, that includes /dev/null.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd || netbsd || dragonfly
+
+// project-bundler/terminal_termios.go
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// isTerminal reports whether f is a terminal: whether it has terminal
+// attributes, as isatty(3) checks. Other character devices such as
+// /dev/null are not terminals.
+func isTerminal(f *os.File) bool {
+	var t syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), ioctlGetTermios, uintptr(unsafe.Pointer(&t)))
+	return errno == 0
+}
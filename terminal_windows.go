@@ -0,0 +1,14 @@
+// project-bundler/terminal_windows.go
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// isTerminal reports whether f is a console. NUL and other character
+// devices have no console mode.
+func isTerminal(f *os.File) bool {
+	var mode uint32
+	return syscall.GetConsoleMode(syscall.Handle(f.Fd()), &mode) == nil
+}
@@ -0,0 +1,126 @@
+// project-bundler/testrules.go
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// ruleCase is one expectation of a rules test file: a path, whether it is
+// bundled, and optionally the reason code it is skipped for.
+type ruleCase struct {
+	path    string
+	include bool
+	reason  string
+	line    int
+}
+
+// runTestRules implements the `test-rules` subcommand, which checks the
+// effective configuration of a source directory (preset, .bundler.yaml and
+// the common ignore list) against the expectations in a rules test file, so
+// a team can regression-test its config like code. It exits with status 1
+// when an expectation fails.
+//
+//	project-bundler test-rules -src . rules_test.yaml
+func runTestRules(args []string) {
+	fs := flag.NewFlagSet("test-rules", flag.ExitOnError)
+	srcDir := fs.String("src", ".", "Source directory whose configuration is tested.")
+	projectType := fs.String("type", "auto", "Project type, as for bundling. Options: auto, "+strings.Join(availableProjectTypes(), ", "))
+	verbose := fs.Bool("v", false, "Also list the expectations that pass.")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatalf("Usage: project-bundler test-rules [-src dir] [-type t] [-v] <rules_test.yaml>")
+	}
+	cases, err := readRuleCases(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Could not read '%s': %v", fs.Arg(0), err)
+	}
+	opts, err := resolveOptions(*srcDir, *projectType, "", "", false)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	failed := 0
+	for _, c := range cases {
+		reason, rule := opts.CheckPath(c.path)
+		got := "included"
+		if reason != "" {
+			got = fmt.Sprintf("skipped as %s (%s)", reason, rule)
+		}
+		want := "include"
+		if !c.include {
+			want = "exclude"
+			if c.reason != "" {
+				want += " " + c.reason
+			}
+		}
+		ok := c.include == (reason == "") && (c.reason == "" || c.reason == reason)
+		switch {
+		case !ok:
+			failed++
+			fmt.Printf("FAIL %s:%d: %s: want %s, got %s\n", fs.Arg(0), c.line, c.path, want, got)
+		case *verbose:
+			fmt.Printf("ok   %s: %s\n", c.path, got)
+		}
+	}
+	fmt.Printf("%d of %d expectations passed (type: %s).\n", len(cases)-failed, len(cases), opts.ProjectType)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// readRuleCases reads a rules test file: a YAML map from paths relative to
+// the source directory to "include" or "exclude", optionally followed by the
+// reason code the path must be skipped for:
+//
+//	cmd/server/main.go: include
+//	vendor/github.com/x/y.go: exclude
+//	web/dist/app.js: exclude IGNORED_DIR
+//	.env: exclude SECRET  # Never, whatever the preset says.
+func readRuleCases(file string) ([]ruleCase, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var cases []ruleCase
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 && (i == 0 || text[i-1] == ' ') {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" || text == "---" {
+			continue
+		}
+		key, value, ok := strings.Cut(text, ":")
+		key, value = unquoteYAML(strings.TrimSpace(key)), unquoteYAML(strings.TrimSpace(value))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: want 'path: include' or 'path: exclude [REASON]'", n)
+		}
+		c := ruleCase{path: key, line: n}
+		fields := strings.Fields(value)
+		switch {
+		case len(fields) == 1 && fields[0] == "include":
+			c.include = true
+		case len(fields) >= 1 && len(fields) <= 2 && fields[0] == "exclude":
+			if len(fields) == 2 {
+				c.reason = fields[1]
+				if _, known := bundler.ReasonText[c.reason]; !known && cliReasonText[c.reason] == "" {
+					return nil, fmt.Errorf("line %d: unknown reason code '%s'", n, c.reason)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("line %d: want include or exclude for '%s', not '%s'", n, key, value)
+		}
+		cases = append(cases, c)
+	}
+	return cases, scanner.Err()
+}
@@ -0,0 +1,108 @@
+// project-bundler/tokenreport.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// tokenReportRows is how many of the largest files the token report lists
+// before summing up the rest.
+const tokenReportRows = 25
+
+// fileTokens is the estimated token count of one bundled file's block.
+type fileTokens struct {
+	Path   string
+	Tokens int
+}
+
+// largestFiles returns counts sorted by descending tokens, then path.
+func largestFiles(counts []fileTokens) []fileTokens {
+	sorted := append([]fileTokens(nil), counts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Tokens != sorted[j].Tokens {
+			return sorted[i].Tokens > sorted[j].Tokens
+		}
+		return sorted[i].Path < sorted[j].Path
+	})
+	return sorted
+}
+
+// printTokenReport prints the per-file token table of -token-report.
+func printTokenReport(counts []fileTokens, total int, tokName string) {
+	fmt.Printf("\nTokens per file (%s tokenizer):\n", tokName)
+	rest, restFiles := 0, 0
+	for i, c := range largestFiles(counts) {
+		if i >= tokenReportRows {
+			rest += c.Tokens
+			restFiles++
+			continue
+		}
+		fmt.Printf("  %8d  %5.1f%%  %s\n", c.Tokens, percentOf(c.Tokens, total), c.Path)
+	}
+	if restFiles > 0 {
+		fmt.Printf("  %8d  %5.1f%%  (%d more files)\n", rest, percentOf(rest, total), restFiles)
+	}
+	fmt.Printf("  %8d  total, including headers and sections\n", total)
+}
+
+// tokenHeader renders the summary -token-header puts at the top of the bundle.
+func tokenHeader(counts []fileTokens, total int, tokName string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Token estimate: %d tokens in %d files (%s tokenizer).\n\n| Tokens | File |\n|---:|---|\n", total, len(counts), tokName)
+	rest, restFiles := 0, 0
+	for i, c := range largestFiles(counts) {
+		if i >= tokenReportRows {
+			rest += c.Tokens
+			restFiles++
+			continue
+		}
+		fmt.Fprintf(&b, "| %d | %s |\n", c.Tokens, filepath.ToSlash(c.Path))
+	}
+	if restFiles > 0 {
+		fmt.Fprintf(&b, "| %d | (%d more files) |\n", rest, restFiles)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+func percentOf(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+// prependToFile writes header in front of a file's content by copying it
+// into a sibling temporary file that replaces the original.
+func prependToFile(name, header string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.WriteString(tmp, header); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if info, err := src.Stat(); err == nil {
+		os.Chmod(tmp.Name(), info.Mode().Perm())
+	}
+	return os.Rename(tmp.Name(), name)
+}
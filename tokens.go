@@ -0,0 +1,400 @@
+// project-bundler/tokens.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenizer counts tokens the way a model family does. The backends are
+// calibrated estimates rather than exact BPE implementations: they split
+// text with the family's pre-tokenizer rules and charge each piece by the
+// family's typical merge ratios. That is close enough for budgeting and
+// avoids shipping megabytes of vocabulary files.
+type tokenizer interface {
+	name() string
+	count(text []byte) int
+}
+
+// nextPiece returns the length of the first piece of text as the
+// cl100k/o200k pre-tokenizer splits it, approximated by the expression
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+
+//
+// (contractions, words with one leading non-letter, 1-3 digit groups,
+// punctuation runs and whitespace), whose alternatives it tries in order.
+// Without lookahead trailing whitespace is grouped slightly differently
+// than by tiktoken; that does not change the counts noticeably. Matching by
+// hand rather than with regexp keeps counting free of allocations.
+func nextPiece(text []byte) int {
+	r, w := utf8.DecodeRune(text)
+	if r == '\'' {
+		if n := contraction(text[w:]); n > 0 {
+			return w + n
+		}
+	}
+	// A word, with one leading character that is not a newline or a digit.
+	if unicode.IsLetter(r) {
+		return w + letterRun(text[w:])
+	}
+	if r != '\r' && r != '\n' && !unicode.IsNumber(r) {
+		if n := letterRun(text[w:]); n > 0 {
+			return w + n
+		}
+	}
+	if unicode.IsNumber(r) {
+		n := w
+		for i := 1; i < 3 && n < len(text); i++ {
+			d, dw := utf8.DecodeRune(text[n:])
+			if !unicode.IsNumber(d) {
+				break
+			}
+			n += dw
+		}
+		return n
+	}
+	// Punctuation, after an optional space, and the newlines that follow.
+	n := 0
+	if r == ' ' && len(text) > 1 {
+		if next, _ := utf8.DecodeRune(text[1:]); isPunct(next) {
+			n = 1
+		}
+	}
+	if next, _ := utf8.DecodeRune(text[n:]); n < len(text) && isPunct(next) {
+		for n < len(text) {
+			p, pw := utf8.DecodeRune(text[n:])
+			if !isPunct(p) {
+				break
+			}
+			n += pw
+		}
+		for n < len(text) && (text[n] == '\r' || text[n] == '\n') {
+			n++
+		}
+		return n
+	}
+	// Whitespace: up to the last newline of the run, or the whole run.
+	end, lastNewline := 0, 0
+	for end < len(text) && isSpace(text[end]) {
+		if text[end] == '\r' || text[end] == '\n' {
+			lastNewline = end + 1
+		}
+		end++
+	}
+	if lastNewline > 0 {
+		return lastNewline
+	}
+	if end > 0 {
+		return end
+	}
+	return w
+}
+
+// contraction returns the length of a contraction suffix ("s", "re", "ll",
+// ...) at the start of text, matched case-insensitively, or 0.
+func contraction(text []byte) int {
+	r, w := utf8.DecodeRune(text)
+	switch r {
+	case 's', 'S', 'ſ', 't', 'T', 'm', 'M', 'd', 'D':
+		return w
+	}
+	if len(text) < 2 {
+		return 0
+	}
+	switch a, b := text[0]|0x20, text[1]|0x20; {
+	case a == 'r' && b == 'e', a == 'v' && b == 'e', a == 'l' && b == 'l':
+		return 2
+	}
+	return 0
+}
+
+// letterRun returns the length of the run of letters text starts with.
+func letterRun(text []byte) int {
+	n := 0
+	for n < len(text) {
+		r, w := utf8.DecodeRune(text[n:])
+		if !unicode.IsLetter(r) {
+			break
+		}
+		n += w
+	}
+	return n
+}
+
+// isSpace reports whether b is whitespace as regexp's \s has it.
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\f' || b == '\r'
+}
+
+// isPunct reports whether r is neither whitespace, a letter nor a digit.
+func isPunct(r rune) bool {
+	return (r >= utf8.RuneSelf || !isSpace(byte(r))) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// bpeEstimator charges tiktoken-style pieces by kind.
+type bpeEstimator struct {
+	id string
+	// lettersPerToken is how many ASCII letters one token covers on average
+	// once a word is longer than a single vocabulary entry.
+	lettersPerToken int
+	// runesPerToken is the same for non-ASCII scripts (CJK, Cyrillic, ...).
+	runesPerToken float64
+}
+
+func (e bpeEstimator) name() string { return e.id }
+
+func (e bpeEstimator) count(text []byte) int {
+	n := 0
+	for len(text) > 0 {
+		end := nextPiece(text)
+		n += e.pieceTokens(text[:end])
+		text = text[end:]
+	}
+	return n
+}
+
+func (e bpeEstimator) pieceTokens(piece []byte) int {
+	trimmed := bytes.TrimLeft(piece, " \t")
+	switch {
+	case len(trimmed) == 0 || isSpaceOnly(trimmed):
+		// Whitespace runs, including indentation, merge into few tokens.
+		return 1 + len(piece)/16
+	case !utf8.Valid(trimmed) || hasNonASCII(trimmed):
+		runes := utf8.RuneCount(trimmed)
+		return max(1, int(float64(runes)/e.runesPerToken+0.5))
+	case isLetter(trimmed[0]) || isLetter(trimmed[len(trimmed)-1]):
+		return ceilDiv(len(trimmed), e.lettersPerToken)
+	case trimmed[0] >= '0' && trimmed[0] <= '9':
+		return 1 // Digit groups are at most three long and always one token.
+	}
+	// Punctuation runs: common pairs like "()", "{}", ":=" are single tokens.
+	return ceilDiv(len(trimmed), 2)
+}
+
+// sentencePieceEstimator models Llama 2/Mistral style SentencePiece
+// vocabularies: a leading space joins the next word, words split into more
+// pieces than with tiktoken, and each space of indentation is a token.
+type sentencePieceEstimator struct{}
+
+func (sentencePieceEstimator) name() string { return "sentencepiece" }
+
+func (sentencePieceEstimator) count(text []byte) int {
+	n := 0
+	for len(text) > 0 {
+		end := nextPiece(text)
+		piece := text[:end]
+		text = text[end:]
+		trimmed := bytes.TrimLeft(piece, " \t")
+		switch {
+		case len(trimmed) == 0 || isSpaceOnly(trimmed):
+			n += len(bytes.Trim(piece, "\r\n")) + bytes.Count(piece, []byte("\n"))
+		case hasNonASCII(trimmed):
+			n += utf8.RuneCount(trimmed)
+		case isLetter(trimmed[0]) || isLetter(trimmed[len(trimmed)-1]):
+			n += ceilDiv(len(trimmed), 3)
+		default:
+			n += len(trimmed) // Digits and punctuation are mostly single characters.
+		}
+	}
+	return n
+}
+
+// charRatioEstimator divides the character count by a fixed ratio, which is
+// the published guidance for vocabularies that are not public.
+type charRatioEstimator struct {
+	id            string
+	charsPerToken float64
+}
+
+func (e charRatioEstimator) name() string { return e.id }
+
+func (e charRatioEstimator) count(text []byte) int {
+	return e.tokens(utf8.RuneCount(text))
+}
+
+// tokens converts a character count to tokens. The rounding is only exact
+// for a whole text, so counts of parts are added up as characters.
+func (e charRatioEstimator) tokens(chars int) int {
+	return int(float64(chars)/e.charsPerToken + 0.5)
+}
+
+// tokenizers holds the available backends by name.
+var tokenizers = map[string]tokenizer{
+	"cl100k":        bpeEstimator{id: "cl100k", lettersPerToken: 5, runesPerToken: 1},
+	"o200k":         bpeEstimator{id: "o200k", lettersPerToken: 6, runesPerToken: 1.4},
+	"sentencepiece": sentencePieceEstimator{},
+	"claude":        charRatioEstimator{id: "claude", charsPerToken: 3.5},
+}
+
+// modelTokenizers maps model name prefixes to their tokenizer. The longest
+// matching prefix wins, so "gpt-4o" is not mistaken for "gpt-4".
+var modelTokenizers = map[string]string{
+	"gpt-4o":         "o200k",
+	"gpt-4.1":        "o200k",
+	"gpt-5":          "o200k",
+	"o1":             "o200k",
+	"o3":             "o200k",
+	"o4":             "o200k",
+	"gpt-4":          "cl100k",
+	"gpt-3.5":        "cl100k",
+	"llama-3":        "cl100k", // Llama 3 extends the cl100k vocabulary.
+	"llama-2":        "sentencepiece",
+	"mistral":        "sentencepiece",
+	"mixtral":        "sentencepiece",
+	"gemini":         "sentencepiece",
+	"claude":         "claude",
+	"text-embedding": "cl100k",
+}
+
+// tokenizerForModel resolves -model, which may name a model or a tokenizer.
+func tokenizerForModel(model string) (tokenizer, error) {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if t, ok := tokenizers[model]; ok {
+		return t, nil
+	}
+	name, ok := longestPrefixMatch(modelTokenizers, model)
+	if !ok {
+		return nil, fmt.Errorf("unknown model '%s'. Use a model name (e.g. gpt-4o, claude-sonnet-4, llama-3) or a tokenizer: %s", model, strings.Join(availableTokenizers(), ", "))
+	}
+	return tokenizers[name], nil
+}
+
+// longestPrefixMatch looks up the entry whose key is the longest prefix of s.
+func longestPrefixMatch[T any](m map[string]T, s string) (T, bool) {
+	best, found := "", false
+	for prefix := range m {
+		if strings.HasPrefix(s, prefix) && (!found || len(prefix) > len(best)) {
+			best, found = prefix, true
+		}
+	}
+	return m[best], found
+}
+
+// availableTokenizers returns the tokenizer backend names.
+func availableTokenizers() []string {
+	var names []string
+	for name := range tokenizers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tokenCountingWriter counts the tokens of everything written through it
+// as it passes, so the total is known once the bundle is written without
+// reading it again. Text is counted in segments that end with a newline
+// followed by something other than whitespace, where no piece of any
+// backend's pre-tokenizer can continue, so the total does not depend on how
+// the writes divide the text. Only the text after the last such newline is
+// held back: the content is never buffered as a whole, and nothing is
+// allocated once that buffer has grown to the longest line.
+type tokenCountingWriter struct {
+	tok     tokenizer
+	pending []byte // Text after the last segment counted.
+	n       int    // Tokens of the segments counted; characters for a charRatioEstimator.
+}
+
+// measure returns what n adds up for text.
+func (w *tokenCountingWriter) measure(text []byte) int {
+	if _, ok := w.tok.(charRatioEstimator); ok {
+		return utf8.RuneCount(text)
+	}
+	return w.tok.count(text)
+}
+
+// tokens converts a sum of measures to tokens.
+func (w *tokenCountingWriter) tokens(n int) int {
+	if e, ok := w.tok.(charRatioEstimator); ok {
+		return e.tokens(n)
+	}
+	return n
+}
+
+func (w *tokenCountingWriter) Write(p []byte) (int, error) {
+	size := len(p)
+	if len(w.pending) > 0 {
+		// Complete the held-back segment with the start of p.
+		first := segmentEnd(w.pending, p)
+		if first < 0 {
+			w.pending = append(w.pending, p...)
+			return size, nil
+		}
+		w.pending = append(w.pending, p[:first]...)
+		w.n += w.measure(w.pending)
+		w.pending, p = w.pending[:0], p[first:]
+	}
+	if last := lastSegmentEnd(p); last > 0 {
+		w.n += w.measure(p[:last])
+		p = p[last:]
+	}
+	w.pending = append(w.pending, p...)
+	return size, nil
+}
+
+// segmentEnd returns the first offset in p where a segment ends, p
+// following held; -1 when there is none.
+func segmentEnd(held, p []byte) int {
+	if len(p) > 0 && held[len(held)-1] == '\n' && !isSpace(p[0]) {
+		return 0
+	}
+	for from := 0; ; {
+		i := bytes.IndexByte(p[from:], '\n')
+		if i < 0 {
+			return -1
+		}
+		end := from + i + 1
+		if end < len(p) && !isSpace(p[end]) {
+			return end
+		}
+		from = end
+	}
+}
+
+// lastSegmentEnd returns the last offset in p where a segment ends, or 0.
+func lastSegmentEnd(p []byte) int {
+	for end := len(p); end > 0; {
+		i := bytes.LastIndexByte(p[:end], '\n')
+		if i < 0 {
+			return 0
+		}
+		if i+1 < len(p) && !isSpace(p[i+1]) {
+			return i + 1
+		}
+		end = i
+	}
+	return 0
+}
+
+// sofar returns the tokens written so far, counting the held-back text as
+// if nothing followed it.
+func (w *tokenCountingWriter) sofar() int {
+	return w.tokens(w.n + w.measure(w.pending))
+}
+
+// total returns the token count, including the held-back text.
+func (w *tokenCountingWriter) total() int {
+	if len(w.pending) > 0 {
+		w.n += w.measure(w.pending)
+		w.pending = w.pending[:0]
+	}
+	return w.tokens(w.n)
+}
+
+func isLetter(b byte) bool { return b|0x20 >= 'a' && b|0x20 <= 'z' || b == '_' }
+
+func isSpaceOnly(b []byte) bool { return len(bytes.TrimSpace(b)) == 0 }
+
+func hasNonASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return true
+		}
+	}
+	return false
+}
+
+func ceilDiv(a, b int) int { return (a + b - 1) / b }
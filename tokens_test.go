@@ -0,0 +1,78 @@
+// project-bundler/tokens_test.go
+package main
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestTokenCountingWriter checks that counting while writing, however the
+// writes divide the text, gives the count of the text as a whole.
+func TestTokenCountingWriter(t *testing.T) {
+	texts := map[string]string{
+		"empty":       "",
+		"one line":    "package main",
+		"code":        "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n",
+		"markdown":    "# Title\n\nSome *text*, with punctuation: don't stop.\n\n```go\nx := 1\n```\n\n- a\n- b\n",
+		"indented":    "a:\n  b:\n    c: 1\n\n\n  d: 2\n",
+		"crlf":        "line one\r\nline two\r\n\r\nline three\r\n",
+		"blank lines": "\n\n\na\n\n\n\nb\n\n",
+		"spaces":      "x   \n   \ny \t\n\t\tz",
+		"unicode":     "// Grüße, 世界 — ok\nconst s = \"émoji 🎉\"\n日本語のテキスト\n",
+		"long line":   strings.Repeat("word ", 2000) + "\nend\n",
+	}
+	// The repo's own sources, concatenated, stand in for a bundle.
+	sources, _ := filepath.Glob("*.go")
+	var bundle strings.Builder
+	for _, name := range sources[:min(len(sources), 20)] {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle.WriteString("File: /" + name + "\n```go\n")
+		bundle.Write(data)
+		bundle.WriteString("```\n\n")
+	}
+	texts["bundle"] = bundle.String()
+
+	rng := rand.New(rand.NewSource(1))
+	for tokName, tok := range tokenizers {
+		for name, text := range texts {
+			want := tok.count([]byte(text))
+			splits := map[string][]int{"whole": {len(text)}, "bytes": nil, "lines": nil, "random": nil}
+			for i := 0; i < len(text); i++ {
+				splits["bytes"] = append(splits["bytes"], 1)
+			}
+			for rest := text; rest != ""; {
+				n := strings.IndexByte(rest, '\n') + 1
+				if n == 0 {
+					n = len(rest)
+				}
+				splits["lines"] = append(splits["lines"], n)
+				rest = rest[n:]
+			}
+			for rest := len(text); rest > 0; {
+				n := min(rest, 1+rng.Intn(64))
+				splits["random"] = append(splits["random"], n)
+				rest -= n
+			}
+			for splitName, sizes := range splits {
+				w := &tokenCountingWriter{tok: tok}
+				at := 0
+				for _, n := range sizes {
+					w.Write([]byte(text[at : at+n]))
+					at += n
+				}
+				if sofar := w.sofar(); sofar != want {
+					t.Errorf("%s, %s, %s writes: sofar() = %d, want %d", tokName, name, splitName, sofar, want)
+				}
+				if got := w.total(); got != want {
+					t.Errorf("%s, %s, %s writes: total() = %d, want %d", tokName, name, splitName, got, want)
+				}
+			}
+		}
+	}
+}
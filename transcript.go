@@ -0,0 +1,204 @@
+// project-bundler/transcript.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// transcript is an earlier conversation that -attach-transcript carries
+// into a follow-up bundle, cut to its budget.
+type transcript struct {
+	source   string   // The file it was read from.
+	unit     string   // "messages", or "paragraphs" for a Markdown transcript.
+	parts    []string // The kept messages or paragraphs, oldest first.
+	omitted  int      // The earliest ones left out to fit the budget.
+	budget   int      // In tokens.
+	cutFirst bool     // The earliest kept one lost its beginning to fit the budget.
+}
+
+// transcriptMessage is a message of a JSON transcript, in the shape of the
+// OpenAI and Anthropic chat APIs: content is a string or a list of parts,
+// of which the text ones are kept.
+type transcriptMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// readTranscript reads a conversation and keeps its most recent messages
+// that fit in budget tokens. A .json file holds a list of messages or an
+// object with a "messages" list (and an optional "system" prompt); any
+// other file is read as Markdown and split into paragraphs. Line endings
+// are normalized and runs of blank lines collapsed.
+func readTranscript(file string, tok tokenizer, budget int) (*transcript, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	t := &transcript{source: filepath.Base(file), budget: budget}
+	var parts []string
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		t.unit = "messages"
+		if parts, err = transcriptMessages(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	} else {
+		t.unit = "paragraphs"
+		parts = transcriptParagraphs(normalizeTranscript(string(data)))
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%s holds no conversation", file)
+	}
+	used := 0
+	first := len(parts)
+	for first > 0 {
+		n := tok.count([]byte(parts[first-1]))
+		if used+n > budget {
+			break
+		}
+		used += n
+		first--
+	}
+	if first == len(parts) {
+		// Not even the latest fits: keep as many of its last lines as do.
+		lines := strings.SplitAfter(parts[len(parts)-1], "\n")
+		kept := len(lines)
+		for kept > 0 && tok.count([]byte(strings.Join(lines[len(lines)-kept:], ""))) > budget {
+			kept--
+		}
+		parts[len(parts)-1] = strings.Join(lines[len(lines)-kept:], "")
+		first, t.cutFirst = len(parts)-1, true
+	}
+	t.parts, t.omitted = parts[first:], first
+	return t, nil
+}
+
+func transcriptMessages(data []byte) ([]string, error) {
+	var messages []transcriptMessage
+	if err := json.Unmarshal(data, &messages); err != nil {
+		var chat struct {
+			System   json.RawMessage     `json:"system"`
+			Messages []transcriptMessage `json:"messages"`
+		}
+		if err := json.Unmarshal(data, &chat); err != nil || chat.Messages == nil {
+			return nil, fmt.Errorf("not a list of messages or an object with a \"messages\" list")
+		}
+		messages = chat.Messages
+		if len(chat.System) > 0 {
+			messages = append([]transcriptMessage{{"system", chat.System}}, messages...)
+		}
+	}
+	var parts []string
+	for _, m := range messages {
+		text := normalizeTranscript(messageText(m.Content))
+		if text == "" {
+			continue
+		}
+		role := m.Role
+		if role == "" {
+			role = "unknown"
+		}
+		parts = append(parts, fmt.Sprintf("**%s%s:**\n\n%s", strings.ToUpper(role[:1]), role[1:], text))
+	}
+	return parts, nil
+}
+
+// messageText returns the text of a message's content: the string itself,
+// or the "text" of its parts joined by blank lines.
+func messageText(content json.RawMessage) string {
+	var s string
+	if json.Unmarshal(content, &s) == nil {
+		return s
+	}
+	var parts []struct {
+		Text string `json:"text"`
+	}
+	json.Unmarshal(content, &parts)
+	var texts []string
+	for _, p := range parts {
+		if p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// normalizeTranscript converts line endings to LF, strips trailing spaces
+// and collapses runs of blank lines into one.
+func normalizeTranscript(s string) string {
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+	var b strings.Builder
+	blank := false
+	for _, line := range strings.Split(strings.TrimSpace(s), "\n") {
+		line = strings.TrimRight(line, " \t")
+		if line == "" && blank {
+			continue
+		}
+		blank = line == ""
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// transcriptParagraphs splits Markdown at blank lines outside of code
+// fences, so that a paragraph left out never takes half a fence with it.
+func transcriptParagraphs(s string) []string {
+	var parts []string
+	var cur []string
+	fence := ""
+	for _, line := range strings.Split(s, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if run := len(trimmed) - len(strings.TrimLeft(trimmed, "`~")); run >= 3 && (trimmed[0] == '`' || trimmed[0] == '~') {
+			marker := trimmed[:run]
+			switch {
+			case fence == "":
+				fence = marker
+			case strings.HasPrefix(marker, fence) && strings.TrimSpace(trimmed[run:]) == "":
+				fence = ""
+			}
+		}
+		if line == "" && fence == "" {
+			if len(cur) > 0 {
+				parts = append(parts, strings.Join(cur, "\n"))
+				cur = nil
+			}
+			continue
+		}
+		cur = append(cur, line)
+	}
+	if len(cur) > 0 {
+		parts = append(parts, strings.Join(cur, "\n"))
+	}
+	return parts
+}
+
+// writeTranscriptAppendix appends the conversation in a Markdown fence
+// longer than any inside it, so its own fences and a cut-off beginning
+// cannot spill into the rest of the bundle.
+func writeTranscriptAppendix(w io.Writer, t *transcript) error {
+	if t == nil {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Appendix: earlier conversation from `%s` (%d %s", t.source, len(t.parts), t.unit)
+	switch {
+	case t.cutFirst && t.omitted > 0:
+		fmt.Fprintf(&b, "; the %d earliest are left out and the earliest kept is cut to fit the -transcript-budget of %d tokens", t.omitted, t.budget)
+	case t.cutFirst:
+		fmt.Fprintf(&b, "; the beginning is cut to fit the -transcript-budget of %d tokens", t.budget)
+	case t.omitted > 0:
+		fmt.Fprintf(&b, "; the %d earliest are left out to fit the -transcript-budget of %d tokens", t.omitted, t.budget)
+	}
+	body := strings.Join(t.parts, "\n\n")
+	fence := strings.Repeat("`", bundler.FenceLength([]byte(body), '`'))
+	fmt.Fprintf(&b, "):\n\n%smarkdown\n%s\n%s\n\n", fence, body, fence)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
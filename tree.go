@@ -0,0 +1,72 @@
+// project-bundler/tree.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// treeNode is a directory or file in the -tree overview.
+type treeNode struct {
+	name     string
+	children map[string]*treeNode // nil for files.
+}
+
+// writeTreeSection emits an ASCII directory tree, like tree(1) prints, of
+// the files about to be bundled, with paths as the file headers show them.
+func writeTreeSection(w io.Writer, style bundler.Style, files []fileEntry) error {
+	if len(files) == 0 {
+		return nil
+	}
+	root := &treeNode{children: make(map[string]*treeNode)}
+	for _, f := range files {
+		parts := strings.Split(strings.TrimPrefix(bundler.EscapeHeaderPath(style.DisplayPath(f.RelPath)), "/"), "/")
+		n := root
+		for i, part := range parts {
+			child, ok := n.children[part]
+			if !ok {
+				child = &treeNode{name: part}
+				if i < len(parts)-1 {
+					child.children = make(map[string]*treeNode)
+				}
+				n.children[part] = child
+			}
+			n = child
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Project tree (%d files):\n\n```text\n/\n", len(files))
+	writeTreeLevel(&b, root, "")
+	b.WriteString("```\n\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeTreeLevel writes the children of n in lexical order, each line
+// prefixed with the branches of the levels above.
+func writeTreeLevel(b *strings.Builder, n *treeNode, indent string) {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		child := n.children[name]
+		branch, next := "├── ", "│   "
+		if i == len(names)-1 {
+			branch, next = "└── ", "    "
+		}
+		if child.children != nil {
+			name += "/"
+		}
+		b.WriteString(indent + branch + name + "\n")
+		if child.children != nil {
+			writeTreeLevel(b, child, indent+next)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// project-bundler/treemap.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sizeNode is a directory or file in the bundle composition tree written
+// by `stats -html`.
+type sizeNode struct {
+	Name     string      `json:"name"`
+	Lang     string      `json:"lang,omitempty"` // Files only.
+	Bytes    int64       `json:"bytes"`
+	Tokens   int         `json:"tokens"`
+	Children []*sizeNode `json:"children,omitempty"`
+}
+
+// child returns the named child directory, creating it when missing.
+func (n *sizeNode) child(name string) *sizeNode {
+	for _, c := range n.Children {
+		if c.Name == name && c.Lang == "" {
+			return c
+		}
+	}
+	c := &sizeNode{Name: name}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// buildSizeTree measures every file as the default bundle would render it,
+// in bytes and tokens, and sums the sizes up the directory tree.
+func buildSizeTree(opts bundleOptions, tok tokenizer) (*sizeNode, error) {
+	files, _, err := collectFiles(opts)
+	if err != nil {
+		return nil, fmt.Errorf("error during directory walk: %w", err)
+	}
+	root := &sizeNode{Name: filepath.Base(absPath(opts.SrcDir))}
+	for _, f := range files {
+		content, err := os.ReadFile(f.Path)
+		if f.DuplicateOf != "" {
+			content, err = opts.Style.DuplicateStub(f.DuplicateOf), nil
+		}
+		if err != nil {
+			continue // The bundle would skip it too.
+		}
+		counter := &countingWriter{w: io.Discard}
+		tokens := &tokenCountingWriter{tok: tok}
+		if err := opts.Style.WriteFile(io.MultiWriter(counter, tokens), f.RelPath, f.Lang, "", content); err != nil {
+			return nil, err
+		}
+		leaf := &sizeNode{Name: filepath.Base(f.RelPath), Lang: f.Lang, Bytes: counter.n, Tokens: tokens.total()}
+
+		node := root
+		node.Bytes += leaf.Bytes
+		node.Tokens += leaf.Tokens
+		if dir := filepath.Dir(f.RelPath); dir != "." {
+			for _, name := range strings.Split(filepath.ToSlash(dir), "/") {
+				node = node.child(name)
+				node.Bytes += leaf.Bytes
+				node.Tokens += leaf.Tokens
+			}
+		}
+		node.Children = append(node.Children, leaf)
+	}
+	sortSizeTree(root)
+	return root, nil
+}
+
+// sortSizeTree orders every level largest first, as the treemap lays it out.
+func sortSizeTree(n *sizeNode) {
+	sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Bytes > n.Children[j].Bytes })
+	for _, c := range n.Children {
+		sortSizeTree(c)
+	}
+}
+
+// absPath returns the absolute form of path, or path itself when it cannot
+// be resolved.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// writeTreemapHTML writes a self-contained page drawing the tree as a
+// zoomable treemap. The data is embedded as JSON, which encoding/json
+// escapes for use inside <script>.
+func writeTreemapHTML(w io.Writer, root *sizeNode, tokenizerName string) error {
+	data, err := json.Marshal(root)
+	if err != nil {
+		return err
+	}
+	page := strings.NewReplacer(
+		"{{TITLE}}", html.EscapeString(root.Name),
+		"{{TOKENIZER}}", html.EscapeString(tokenizerName),
+		"{{DATA}}", string(data),
+	).Replace(treemapPage)
+	_, err = io.WriteString(w, page)
+	return err
+}
+
+// treemapPage lays the tree out with the squarified treemap algorithm
+// (Bruls, Huizing and van Wijk). Clicking a directory zooms into it; the
+// breadcrumb zooms back out.
+const treemapPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{TITLE}} bundle composition</title>
+<style>
+body { font: 13px system-ui, sans-serif; margin: 0; display: flex; flex-direction: column; height: 100vh; }
+header { padding: 8px 12px; display: flex; gap: 16px; align-items: center; border-bottom: 1px solid #ccc; }
+#crumbs a { cursor: pointer; color: #0366d6; }
+#map { position: relative; flex: 1; margin: 8px; }
+.cell { position: absolute; box-sizing: border-box; border: 1px solid #fff; overflow: hidden; white-space: nowrap; text-overflow: ellipsis; padding: 2px 4px; }
+.dir { background: #f0f0f0; border-color: #999; cursor: zoom-in; font-weight: 600; }
+</style>
+</head>
+<body>
+<header>
+<span id="crumbs"></span>
+<label><input type="radio" name="measure" value="bytes" checked> bytes</label>
+<label><input type="radio" name="measure" value="tokens"> tokens ({{TOKENIZER}})</label>
+<span id="total"></span>
+</header>
+<div id="map"></div>
+<script>
+const root = {{DATA}};
+let measure = "bytes", path = [root];
+const map = document.getElementById("map");
+
+function fmt(n) {
+  if (measure === "tokens") return n.toLocaleString() + " tokens";
+  const units = ["B", "KB", "MB", "GB"];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) { n /= 1024; i++; }
+  return (i ? n.toFixed(1) : n) + " " + units[i];
+}
+
+function color(lang) {
+  let h = 0;
+  for (const c of lang) h = (h * 31 + c.charCodeAt(0)) % 360;
+  return "hsl(" + h + ", 55%, 70%)";
+}
+
+function worst(row, side) {
+  const sum = row.reduce((s, r) => s + r.area, 0);
+  const big = Math.max(...row.map(r => r.area)), small = Math.min(...row.map(r => r.area));
+  return Math.max(side * side * big / (sum * sum), sum * sum / (side * side * small));
+}
+
+function squarify(nodes, x, y, w, h) {
+  const total = nodes.reduce((s, n) => s + n[measure], 0);
+  if (!total || w <= 0 || h <= 0) return [];
+  let items = nodes.filter(n => n[measure] > 0).map(n => ({ node: n, area: n[measure] * w * h / total }));
+  const out = [];
+  while (items.length) {
+    const side = Math.min(w, h);
+    let row = [items[0]], i = 1;
+    while (i < items.length && worst(row.concat(items[i]), side) <= worst(row, side)) row.push(items[i++]);
+    items = items.slice(i);
+    const thick = row.reduce((s, r) => s + r.area, 0) / side;
+    let offset = 0;
+    for (const r of row) {
+      const len = r.area / thick;
+      out.push(w >= h ? { node: r.node, x: x, y: y + offset, w: thick, h: len }
+                      : { node: r.node, x: x + offset, y: y, w: len, h: thick });
+      offset += len;
+    }
+    if (w >= h) { x += thick; w -= thick; } else { y += thick; h -= thick; }
+  }
+  return out;
+}
+
+function draw(node, x, y, w, h, chain) {
+  for (const r of squarify(node.children || [], x, y, w, h)) {
+    const n = r.node, name = chain.slice(1).concat(n).map(c => c.name).join("/") + (n.children ? "/" : "");
+    const cell = document.createElement("div");
+    cell.className = "cell" + (n.children ? " dir" : "");
+    Object.assign(cell.style, { left: r.x + "px", top: r.y + "px", width: r.w + "px", height: r.h + "px" });
+    if (!n.children) cell.style.background = color(n.lang);
+    cell.title = name + "\n" + fmt(n[measure]) + " (" + (100 * n[measure] / root[measure]).toFixed(1) + "% of the bundle)";
+    if (r.w > 40 && r.h > 14) cell.textContent = n.name;
+    map.appendChild(cell);
+    if (n.children) {
+      cell.onclick = e => { e.stopPropagation(); path = chain.concat(n); render(); };
+      if (r.w > 30 && r.h > 36) draw(n, r.x + 3, r.y + 18, r.w - 6, r.h - 21, chain.concat(n));
+    }
+  }
+}
+
+function render() {
+  map.textContent = "";
+  const current = path[path.length - 1];
+  const crumbs = document.getElementById("crumbs");
+  crumbs.textContent = "";
+  path.forEach((n, i) => {
+    const a = document.createElement("a");
+    a.textContent = n.name;
+    a.onclick = () => { path = path.slice(0, i + 1); render(); };
+    crumbs.append(i ? " / " : "", a);
+  });
+  document.getElementById("total").textContent = fmt(current[measure]) + " of " + fmt(root[measure]);
+  draw(current, 0, 0, map.clientWidth, map.clientHeight, path);
+}
+
+document.querySelectorAll("input[name=measure]").forEach(input => input.onchange = () => { measure = input.value; render(); });
+window.onresize = render;
+render();
+</script>
+</body>
+</html>
+`
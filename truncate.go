@@ -0,0 +1,127 @@
+// project-bundler/truncate.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// overSizeLimit splits off the files larger than -max-file-size. Without
+// -truncate they are skipped; with it they stay in the bundle and are
+// returned so their content can be cut down.
+func overSizeLimit(opts bundleOptions, files []fileEntry, skipped map[string][]string) ([]fileEntry, stringSet) {
+	if opts.maxFileSize <= 0 {
+		return files, nil
+	}
+	truncated := make(stringSet)
+	kept := files[:0]
+	for _, f := range files {
+		switch {
+		case f.Size <= opts.maxFileSize || f.Placeholder || f.Minified || f.Binary || f.DuplicateOf != "":
+		case opts.truncateLines > 0:
+			truncated[f.Path] = struct{}{}
+		default:
+			skipped[reasonTooLarge] = append(skipped[reasonTooLarge], f.Path)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept, truncated
+}
+
+// truncateContent keeps the first and last n lines of content with a marker
+// line in between. Content still over limit bytes after that, such as a
+// minified file on one line, is cut to its first limit bytes. It returns the
+// annotation describing the cut.
+func truncateContent(content []byte, n int, limit int64) ([]byte, string) {
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	total := len(lines)
+	note := fmt.Sprintf("Truncated: over -max-file-size; the first and last %d of %d lines are shown.\n", n, total)
+	if total > 2*n+1 {
+		omitted := total - 2*n
+		var b bytes.Buffer
+		for _, line := range lines[:n] {
+			b.Write(line)
+		}
+		fmt.Fprintf(&b, "... truncated (%d lines omitted) ...\n", omitted)
+		for _, line := range lines[total-n:] {
+			b.Write(line)
+		}
+		content = b.Bytes()
+	}
+	if int64(len(content)) > limit {
+		cut := int(limit)
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		omitted := len(content) - cut
+		content = append(content[:cut:cut], fmt.Sprintf("\n... truncated (%d bytes omitted) ...\n", omitted)...)
+		note = fmt.Sprintf("Truncated: over -max-file-size; only the first %s are shown.\n", formatSize(limit))
+	}
+	return content, note
+}
+
+// limitLineLength cuts the lines of content longer than max characters,
+// such as minified code or an embedded base64 blob, to their first max
+// characters with a marker for the bytes left out, or with wrap breaks them
+// onto lines of at most max characters. It returns the annotation
+// describing the change, or "" when no line is that long.
+func limitLineLength(content []byte, max int, wrap bool) ([]byte, string) {
+	var b bytes.Buffer
+	long := 0
+	for rest := content; len(rest) > 0; {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		rest = rest[len(line):]
+		body := bytes.TrimRight(line, "\r\n")
+		if utf8.RuneCount(body) <= max {
+			b.Write(line)
+			continue
+		}
+		long++
+		eol := line[len(body):]
+		for {
+			cut := runeOffset(body, max)
+			if !wrap {
+				b.Write(body[:cut])
+				fmt.Fprintf(&b, " ... truncated (%d bytes omitted) ...", len(body)-cut)
+				b.Write(eol)
+				break
+			}
+			b.Write(body[:cut])
+			if body = body[cut:]; len(body) == 0 {
+				b.Write(eol)
+				break
+			}
+			if bytes.Equal(eol, []byte("\r\n")) {
+				b.WriteString("\r\n")
+			} else {
+				b.WriteString("\n")
+			}
+		}
+	}
+	if long == 0 {
+		return content, ""
+	}
+	if wrap {
+		return b.Bytes(), fmt.Sprintf("Wrapped: %d lines over -max-line-length were broken onto lines of %d characters.\n", long, max)
+	}
+	return b.Bytes(), fmt.Sprintf("Truncated: %d lines over -max-line-length are cut to their first %d characters.\n", long, max)
+}
+
+// runeOffset returns the byte offset of the n-th character of b, or len(b)
+// when it has fewer.
+func runeOffset(b []byte, n int) int {
+	i := 0
+	for ; n > 0 && i < len(b); n-- {
+		_, size := utf8.DecodeRune(b[i:])
+		i += size
+	}
+	return i
+}
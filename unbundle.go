@@ -0,0 +1,390 @@
+// project-bundler/unbundle.go
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// duplicateStubRE matches the content bundler.Style.DuplicateStub writes.
+var duplicateStubRE = regexp.MustCompile(`^\(same file as (.+); its content is bundled there\)$`)
+
+// stubRE matches the line written in place of a file whose content was not
+// bundled: a cloud placeholder, a minified file (-minified stub), or a
+// binary file listed by -binary-mode or too large for it to embed.
+var stubRE = regexp.MustCompile(`^\((cloud placeholder|minified [^,\n]*|binary), [^\n]*\)$`)
+
+// binaryEmbedRE matches the line that starts a binary file embedded with
+// -binary-mode base64; the base64 of its bytes follows.
+var binaryEmbedRE = regexp.MustCompile(`^\(binary, [^\n]*, base64\)\r?\n`)
+
+// stubKind returns what stands in for the file in a block without its
+// content, such as "cloud placeholder", or "".
+func stubKind(content []byte) string {
+	if m := stubRE.FindSubmatch(bytes.TrimSpace(content)); m != nil && !binaryEmbedRE.Match(content) {
+		return string(m[1])
+	}
+	return ""
+}
+
+// decodeBinaryBlock returns the bytes of a binary file embedded with
+// -binary-mode base64. ok is false for any other block.
+func decodeBinaryBlock(content []byte) (data []byte, ok bool, err error) {
+	header := binaryEmbedRE.Find(content)
+	if header == nil {
+		return nil, false, nil
+	}
+	encoded := strings.Join(strings.Fields(string(content[len(header):])), "")
+	data, err = base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid base64 of an embedded binary file: %v", err)
+	}
+	return data, true, nil
+}
+
+// elidedRE matches the line -elide-boilerplate puts in place of entries.
+var elidedRE = regexp.MustCompile(`(?m)^\s*… \(\d+ similar entries elided\)$`)
+
+// condensedAnnotations start the annotations of blocks whose content is not
+// the file's: writing it back would replace the file with a summary, or
+// with a copy that lost its secrets, comments or layout.
+var condensedAnnotations = []string{
+	"Exported API only",
+	"Synthetic sample",
+	"Plain text extracted from",
+	"Summary of",
+	"Truncated:",
+	"Redacted:",
+	"Altered:",
+}
+
+// redactedRE matches what -redact-secrets and redact markers put in place of
+// content, for bundles whose annotations were lost on the way back. Only the
+// built-in rules' markers and whole marker lines are matched, so code that
+// builds or mentions such markers is not taken for redacted content.
+var redactedRE = func() *regexp.Regexp {
+	var rules []string
+	for _, name := range bundler.SecretRuleNames() {
+		rules = append(rules, regexp.QuoteMeta(name))
+	}
+	return regexp.MustCompile(`(?m)\[REDACTED (?:` + strings.Join(rules, "|") + `)\]|^[ \t]*(?:\S+[ \t]*)?bundler:redacted \(\d+ lines?\)(?:[ \t]*\S+)?[ \t]*$`)
+}()
+
+// runUnbundle implements the `unbundle` subcommand, which writes the files of
+// a bundle (for example one a model returned with its edits) back to disk.
+//
+//	project-bundler unbundle -dir ./restored bundle.md
+func runUnbundle(args []string) {
+	fs := flag.NewFlagSet("unbundle", flag.ExitOnError)
+	dir := fs.String("dir", ".", "Directory to write the files under.")
+	styleName := fs.String("style", "github", "Output style the bundle was written with. Options: "+strings.Join(bundler.StyleNames(), ", "))
+	finalNewline := fs.String("final-newline", "pad", "-final-newline value the bundle was written with: pad or mark.")
+	dryRun := fs.Bool("dry-run", false, "Only list what would be created, changed or left alone.")
+	force := fs.Bool("force", false, "Overwrite existing files whose content differs. Without it they are left alone and reported.")
+	lenient := fs.Bool("lenient", false, "Recover what can be read from a malformed bundle, such as one edited by hand, and warn about each problem with its line, instead of stopping at the first.")
+	exact := fs.Bool("exact", false, "Write contents exactly as in the bundle. By default a missing final newline, which models often drop, is added.")
+	manifestPath := fs.String("manifest", "", "Manifest of the bundle (written by -track-changes or -chunk-ids). Defaults to the bundle's name with .manifest.json, if it exists.")
+	asUTF8 := fs.Bool("utf8", false, "Write every file as UTF-8 with LF line endings, as in the bundle, instead of converting files back to the charset, byte order mark and line endings the manifest recorded.")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatalf("Usage: project-bundler unbundle [-dir dir] [-style name] [-dry-run] [-force] [-lenient] <bundle>")
+	}
+	if *manifestPath == "" {
+		*manifestPath = sidecarPath(fs.Arg(0), ".manifest.json")
+	}
+	manifest, err := loadManifest(*manifestPath)
+	if err != nil {
+		log.Fatalf("Could not read manifest: %v", err)
+	}
+	if manifest == nil {
+		manifest = newBundleManifest()
+	}
+	if *asUTF8 {
+		manifest.Encodings = nil
+	}
+	style, ok := bundler.Styles[*styleName]
+	if !ok {
+		log.Fatalf("Invalid style '%s'. Available styles are: %s", *styleName, strings.Join(bundler.StyleNames(), ", "))
+	}
+	if style.MarkNoNewline, err = parseFinalNewline(*finalNewline); err != nil {
+		log.Fatalf("%v", err)
+	}
+	if _, err := os.Stat(fs.Arg(0)); err != nil {
+		log.Fatalf("Could not read bundle: %v", err)
+	}
+	// Replaying also covers journals: each file is written at its latest
+	// content, and files a record removed are deleted.
+	state, err := replayJournal(fs.Arg(0), style, &bundler.ParseOptions{Lenient: *lenient})
+	if err != nil {
+		log.Fatalf("Could not parse bundle: %v", err)
+	}
+	blocks := state.live()
+	if len(blocks) == 0 {
+		log.Fatalf("No file blocks found in '%s'; pass -style if it was not written in the github style.", fs.Arg(0))
+	}
+
+	u := &unbundler{dir: *dir, style: style, manifest: manifest, exact: *exact, dryRun: *dryRun, force: *force}
+	for _, b := range blocks {
+		if err := u.write(b); err != nil {
+			log.Fatalf("Unbundle stopped: %v", err)
+		}
+	}
+	for _, p := range state.removed() {
+		if err := u.remove(p); err != nil {
+			log.Fatalf("Unbundle stopped: %v", err)
+		}
+	}
+	if err := u.makeEmptyDirs(); err != nil {
+		log.Fatalf("Unbundle stopped: %v", err)
+	}
+	verb := "Wrote"
+	if *dryRun {
+		verb = "Would write"
+	}
+	dirs := ""
+	if u.createdDirs > 0 {
+		dirs = fmt.Sprintf(" and %d empty directories", u.createdDirs)
+	}
+	deleted := ""
+	if u.deleted > 0 {
+		deleted = fmt.Sprintf("%d deleted, ", u.deleted)
+	}
+	fmt.Printf("%s %d new and %d changed files%s; %s%d unchanged, %d skipped.\n", verb, u.created, u.changed, dirs, deleted, u.unchanged, u.refused)
+	if u.refused > 0 {
+		os.Exit(1)
+	}
+}
+
+// unbundler writes the blocks of a bundle back to files under dir.
+type unbundler struct {
+	dir                  string
+	style                bundler.Style
+	manifest             *bundleManifest
+	exact, dryRun, force bool
+
+	contents map[string][]byte // By path, for duplicate stubs.
+	binaries stringSet         // Paths of the decoded binary files.
+
+	created, changed, unchanged, refused int
+	createdDirs, deleted                 int
+}
+
+// skip leaves a block's file alone and says why.
+func (u *unbundler) skip(b bundler.Block, format string, args ...any) {
+	log.Printf("Skipping %s (line %d): %s", b.Path, b.Line, fmt.Sprintf(format, args...))
+	u.refused++
+}
+
+// write writes the file of one block, or skips it when the block does not
+// hold the file's content. Only a failure to write is an error.
+func (u *unbundler) write(b bundler.Block) error {
+	if u.contents == nil {
+		u.contents, u.binaries = make(map[string][]byte), make(stringSet)
+	}
+	rel := filepath.FromSlash(b.Path)
+	if !filepath.IsLocal(rel) {
+		u.skip(b, "path leaves the target directory")
+		return nil
+	}
+	if b.Unclosed && b.Lang == "" && len(b.Content) == 0 {
+		u.skip(b, "the block has no content and no closing line; the bundle looks cut off")
+		return nil
+	}
+	content := b.Content
+	encodedAs := b.Path // Whose recorded encoding to convert content to.
+	binary := false
+	if m := duplicateStubRE.FindSubmatch(bytes.TrimSpace(content)); m != nil {
+		firstPath := strings.TrimPrefix(string(m[1]), "/")
+		first, ok := u.contents[firstPath]
+		if !ok {
+			u.skip(b, "duplicate of %s, which is not in the bundle", m[1])
+			return nil
+		}
+		content, encodedAs, binary = first, "", u.binaries.Contains(firstPath)
+	} else if data, ok, err := decodeBinaryBlock(content); ok {
+		if err != nil {
+			u.skip(b, "%v", err)
+			return nil
+		}
+		content, binary = data, true
+		u.binaries[b.Path] = struct{}{}
+	} else if kind := stubKind(content); kind != "" {
+		u.skip(b, "%s without content", kind)
+		return nil
+	}
+	note := condensedNote(b.Annotation)
+	if note == "" && elidedRE.Match(content) {
+		note = "entries removed by -elide-boilerplate"
+	}
+	if note == "" && redactedRE.Match(content) {
+		note = "content redacted"
+	}
+	if note != "" {
+		u.skip(b, "bundled in condensed or altered form (%s)", note)
+		return nil
+	}
+	// A missing final newline is only added where the file did not
+	// originally lack it. Bundles that mark such files need no guess.
+	// Decoded binary files are already their exact bytes.
+	if !binary {
+		if !u.exact && !u.style.MarkNoNewline && len(content) > 0 && content[len(content)-1] != '\n' && !u.manifest.original(b.Path, u.manifest.encode(encodedAs, content)) {
+			content = append(content[:len(content):len(content)], '\n')
+		}
+		content = u.manifest.encode(encodedAs, content)
+	}
+	u.contents[b.Path] = content
+
+	target := filepath.Join(u.dir, rel)
+	existing, err := os.ReadFile(target)
+	switch {
+	case err == nil && bytes.Equal(existing, content):
+		u.unchanged++
+		return nil
+	case err == nil && !u.force:
+		fmt.Printf("  ! %s differs from the bundle; use -force to overwrite\n", target)
+		u.refused++
+		return nil
+	case err == nil:
+		fmt.Printf("  ~ %s\n", target)
+		u.changed++
+	default:
+		fmt.Printf("  + %s\n", target)
+		u.created++
+	}
+	if u.dryRun {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("could not create directory for %s: %v", target, err)
+	}
+	if err := os.WriteFile(target, content, 0o644); err != nil {
+		return fmt.Errorf("could not write %s: %v", target, err)
+	}
+	return u.restoreMetadata(b.Path, target)
+}
+
+// remove deletes the file at path under dir, which a journal record removed
+// from the project. Without -force it is left alone and reported.
+func (u *unbundler) remove(path string) error {
+	rel := filepath.FromSlash(path)
+	if !filepath.IsLocal(rel) {
+		log.Printf("Skipping the removal of %s: path leaves the target directory", path)
+		u.refused++
+		return nil
+	}
+	target := filepath.Join(u.dir, rel)
+	if info, err := os.Lstat(target); err != nil || info.IsDir() {
+		return nil
+	}
+	if !u.force {
+		fmt.Printf("  ! %s was removed from the project; use -force to delete it\n", target)
+		u.refused++
+		return nil
+	}
+	fmt.Printf("  - %s\n", target)
+	u.deleted++
+	if u.dryRun {
+		return nil
+	}
+	if err := os.Remove(target); err != nil {
+		return fmt.Errorf("could not remove %s: %v", target, err)
+	}
+	return nil
+}
+
+// makeEmptyDirs recreates under dir the empty directories the manifest
+// recorded (-empty-dirs), which no block carries.
+func (u *unbundler) makeEmptyDirs() error {
+	for _, d := range u.manifest.EmptyDirs {
+		rel := filepath.FromSlash(d)
+		if !filepath.IsLocal(rel) {
+			log.Printf("Skipping directory %s: path leaves the target directory", d)
+			u.refused++
+			continue
+		}
+		target := filepath.Join(u.dir, rel)
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		fmt.Printf("  + %s%c\n", target, filepath.Separator)
+		u.createdDirs++
+		if u.dryRun {
+			continue
+		}
+		if err := os.MkdirAll(target, 0o755); err != nil {
+			return fmt.Errorf("could not create directory %s: %v", target, err)
+		}
+	}
+	return nil
+}
+
+// restoreMetadata gives the file written to target the permission bits and
+// modification time the manifest recorded for path, if any.
+func (u *unbundler) restoreMetadata(path, target string) error {
+	if recorded, ok := u.manifest.Modes[path]; ok {
+		mode, err := strconv.ParseUint(recorded, 8, 32)
+		if err != nil {
+			log.Printf("Ignoring the mode '%s' the manifest records for %s", recorded, path)
+		} else if err := os.Chmod(target, os.FileMode(mode).Perm()); err != nil {
+			return fmt.Errorf("could not set the mode of %s: %v", target, err)
+		}
+	}
+	if mtime, ok := u.manifest.Mtimes[path]; ok {
+		if err := os.Chtimes(target, mtime, mtime); err != nil {
+			return fmt.Errorf("could not set the modification time of %s: %v", target, err)
+		}
+	}
+	return nil
+}
+
+// parseFinalNewline reads a -final-newline value into
+// bundler.Style.MarkNoNewline.
+func parseFinalNewline(value string) (bool, error) {
+	switch value {
+	case "pad":
+		return false, nil
+	case "mark":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid -final-newline value '%s'; use pad or mark", value)
+}
+
+// parseBundle reads the blocks of the bundle named name from r with the
+// checks of ParseChecked. A strict parse fails with the location of the
+// first problem; a lenient one logs every problem it recovered from.
+func parseBundle(name string, r io.Reader, style bundler.Style, opts bundler.ParseOptions) ([]bundler.Block, error) {
+	blocks, warnings, err := style.ParseChecked(r, opts)
+	var perr *bundler.ParseError
+	if errors.As(err, &perr) {
+		return nil, fmt.Errorf("%s:%d: %s; pass -lenient to read it anyway", name, perr.Line, perr.Msg)
+	}
+	for _, w := range warnings {
+		log.Printf("%s:%d: %s", name, w.Line, w.Msg)
+	}
+	return blocks, err
+}
+
+// condensedNote returns the annotation line that marks a block as condensed,
+// or "".
+func condensedNote(annotation string) string {
+	for _, line := range strings.Split(annotation, "\n") {
+		for _, prefix := range condensedAnnotations {
+			if strings.HasPrefix(line, prefix) {
+				return strings.TrimSuffix(line, ".")
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,223 @@
+// project-bundler/unbundle_test.go
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// unbundleRoundTrip bundles src as a project of the given type, with the
+// options setup adjusts, and unbundles the bundle into a new directory,
+// which it returns with the unbundler.
+func unbundleRoundTrip(t *testing.T, src, projectType string, setup func(*bundleOptions)) (string, *unbundler) {
+	t.Helper()
+	opts, err := resolveOptions(src, projectType, "", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Style = bundler.Styles["github"]
+	opts.trackChanges = true
+	if setup != nil {
+		setup(&opts)
+	}
+	output := filepath.Join(t.TempDir(), "bundle.md")
+	if _, err := writeBundle(opts, output, false); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, _, err := opts.Style.ParseChecked(bytes.NewReader(data), bundler.ParseOptions{})
+	if err != nil {
+		t.Fatalf("parsing the bundle: %v\n%s", err, data)
+	}
+	manifest, err := loadManifest(sidecarPath(output, ".manifest.json"))
+	if err != nil || manifest == nil {
+		t.Fatalf("reading the manifest: %v", err)
+	}
+	dir := t.TempDir()
+	u := &unbundler{dir: dir, style: opts.Style, manifest: manifest}
+	for _, b := range blocks {
+		if err := u.write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, u
+}
+
+// writeTree creates files under a new directory.
+func writeTree(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// pngHeader is the start of a PNG file, enough to be sniffed as one.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x02\x00\x00\x00\x90wS\xde")
+
+func TestUnbundleBinary(t *testing.T) {
+	logo := append(pngHeader, bytes.Repeat([]byte{0, 0xff, '\n', '\r'}, 40)...)
+	src := writeTree(t, map[string][]byte{"main.go": []byte("package main\n"), "logo.png": logo, "copy.png": logo})
+
+	dir, u := unbundleRoundTrip(t, src, "generic", func(opts *bundleOptions) { opts.Binary, opts.binaryMode, opts.binaryMaxSize = "stub", "base64", 1<<20 })
+	for _, name := range []string{"logo.png", "copy.png"} {
+		if got, err := os.ReadFile(filepath.Join(dir, name)); err != nil || !bytes.Equal(got, logo) {
+			t.Errorf("base64: %s = %q, %v; want the original bytes", name, got, err)
+		}
+	}
+	if u.refused != 0 {
+		t.Errorf("base64: %d files skipped", u.refused)
+	}
+
+	for _, mode := range []struct {
+		name    string
+		maxSize int64
+	}{{"list", 1 << 20}, {"base64", 16}} {
+		dir, u := unbundleRoundTrip(t, src, "generic", func(opts *bundleOptions) {
+			opts.Binary, opts.binaryMode, opts.binaryMaxSize = "stub", mode.name, mode.maxSize
+		})
+		for _, name := range []string{"logo.png", "copy.png"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+				t.Errorf("%s up to %d bytes: the stub of %s was written", mode.name, mode.maxSize, name)
+			}
+		}
+		if got, err := os.ReadFile(filepath.Join(dir, "main.go")); err != nil || string(got) != "package main\n" {
+			t.Errorf("%s: main.go = %q, %v", mode.name, got, err)
+		}
+		if u.refused == 0 {
+			t.Errorf("%s: no file skipped", mode.name)
+		}
+	}
+}
+
+func TestUnbundleMinifiedStub(t *testing.T) {
+	bundle := "/******/ (() => { var __webpack_require__ = {}; })();\n"
+	src := writeTree(t, map[string][]byte{"app.js": []byte("export const a = 1;\n"), "static/bundle.js": []byte(bundle)})
+	dir, u := unbundleRoundTrip(t, src, "node", func(opts *bundleOptions) { opts.Minified = "stub" })
+	if _, err := os.Stat(filepath.Join(dir, "static", "bundle.js")); !os.IsNotExist(err) {
+		t.Error("the stub of static/bundle.js was written")
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "app.js")); err != nil || string(got) != "export const a = 1;\n" {
+		t.Errorf("app.js = %q, %v", got, err)
+	}
+	if u.refused != 1 {
+		t.Errorf("%d files skipped, want 1", u.refused)
+	}
+}
+
+func TestUnbundleMetadata(t *testing.T) {
+	src := writeTree(t, map[string][]byte{"run.sh": []byte("#!/bin/sh\necho hi\n"), "README": []byte("hi\n")})
+	if err := os.Chmod(filepath.Join(src, "run.sh"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "README"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	dir, _ := unbundleRoundTrip(t, src, "generic", func(opts *bundleOptions) { opts.manifestMtimes = true })
+	for name, want := range map[string]os.FileMode{"run.sh": 0o755, "README": 0o644} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm() != want {
+			t.Errorf("%s has mode %v, want %v", name, info.Mode().Perm(), want)
+		}
+		if name == "README" && !info.ModTime().Equal(mtime) {
+			t.Errorf("README modified at %v, want %v", info.ModTime(), mtime)
+		}
+	}
+}
+
+func TestUnbundleEmptyDirs(t *testing.T) {
+	src := writeTree(t, map[string][]byte{"main.go": []byte("package main\n")})
+	for _, d := range []string{"empty", "logs/archive"} {
+		if err := os.MkdirAll(filepath.Join(src, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir, u := unbundleRoundTrip(t, src, "generic", func(opts *bundleOptions) { opts.emptyDirs = true })
+	u.manifest.EmptyDirs = append(u.manifest.EmptyDirs, "../outside")
+	if err := u.makeEmptyDirs(); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{"empty", "logs/archive"} {
+		if info, err := os.Stat(filepath.Join(dir, d)); err != nil || !info.IsDir() {
+			t.Errorf("%s was not recreated: %v", d, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..", "outside")); !os.IsNotExist(err) {
+		t.Error("a directory outside the target was created")
+	}
+	if u.createdDirs != 2 || u.refused != 1 {
+		t.Errorf("%d directories created and %d skipped, want 2 and 1", u.createdDirs, u.refused)
+	}
+}
+
+func TestUnbundleJournal(t *testing.T) {
+	style := bundler.Styles["github"]
+	dir := t.TempDir()
+	journal, snapshot := filepath.Join(dir, "journal.md"), filepath.Join(dir, "snapshot.md")
+	for _, files := range [][]string{{"a.go", "package a\n", "b.go", "package b\n"}, {"a.go", "package a // v2\n"}} {
+		var b bytes.Buffer
+		for i := 0; i < len(files); i += 2 {
+			style.WriteFile(&b, files[i], "go", "", []byte(files[i+1]))
+		}
+		if err := os.WriteFile(snapshot, b.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, err := appendJournal(journal, snapshot, style); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems, err := lintBundleFile(journal, data, style, make(map[string]lintProblem)); err != nil || len(problems) > 0 {
+		t.Errorf("lint: %v, %v", problems, err)
+	}
+
+	state, err := replayJournal(journal, style, &bundler.ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed := state.removed(); len(removed) != 1 || removed[0] != "b.go" {
+		t.Fatalf("removed = %q, want [b.go]", removed)
+	}
+	target := writeTree(t, map[string][]byte{"b.go": []byte("package b\n")})
+	for _, force := range []bool{false, true} {
+		u := &unbundler{dir: target, style: style, manifest: newBundleManifest(), force: force}
+		for _, b := range state.live() {
+			if err := u.write(b); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := u.remove("b.go"); err != nil {
+			t.Fatal(err)
+		}
+		_, err := os.Stat(filepath.Join(target, "b.go"))
+		if force != os.IsNotExist(err) {
+			t.Errorf("force %v: b.go exists: %v", force, err == nil)
+		}
+	}
+	if got, err := os.ReadFile(filepath.Join(target, "a.go")); err != nil || string(got) != "package a // v2\n" {
+		t.Errorf("a.go = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,271 @@
+// project-bundler/update.go
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// version is stamped at release time via -ldflags "-X main.version=vX.Y.Z".
+var version = "dev"
+
+const releasesAPI = "https://api.github.com/repos/kbhuyan/project-bundler/releases/latest"
+
+// githubRelease is the subset of the GitHub releases API response we use.
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+var httpClient = &http.Client{Timeout: 60 * time.Second}
+
+// updateCheckInterval is how often other commands ask for the latest
+// release, so drift is noticed without a request on every run.
+const updateCheckInterval = 24 * time.Hour
+
+func fetchLatestRelease(client *http.Client) (*githubRelease, error) {
+	resp, err := client.Get(releasesAPI)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub releases API returned %s", resp.Status)
+	}
+	var rel githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return nil, err
+	}
+	return &rel, nil
+}
+
+// assetURL returns the download URL of the named release asset.
+func (r *githubRelease) assetURL(name string) (string, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL, true
+		}
+	}
+	return "", false
+}
+
+// binaryAssetName matches the names produced by the release workflow.
+func binaryAssetName() string {
+	name := fmt.Sprintf("project-bundler-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// isNewerVersion reports whether candidate is a later semantic version than
+// current. Development builds are never considered up to date.
+func isNewerVersion(candidate, current string) bool {
+	if current == "dev" {
+		return true
+	}
+	c, cur := parseVersion(candidate), parseVersion(current)
+	for i := range c {
+		if c[i] != cur[i] {
+			return c[i] > cur[i]
+		}
+	}
+	return false
+}
+
+// parseVersion extracts major, minor and patch from tags like "v1.2.3" or
+// "v1.2.3-rc1". Missing or malformed parts are treated as zero.
+func parseVersion(v string) [3]int {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	for i, s := range strings.SplitN(v, ".", 3) {
+		parts[i], _ = strconv.Atoi(s)
+	}
+	return parts
+}
+
+// runVersion implements the `version` subcommand.
+func runVersion(args []string) {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	check := fs.Bool("check", false, "Check GitHub for a newer release.")
+	fs.Parse(args)
+
+	fmt.Printf("project-bundler %s (%s/%s)\n", version, runtime.GOOS, runtime.GOARCH)
+	if !*check {
+		return
+	}
+
+	rel, err := fetchLatestRelease(httpClient)
+	if err != nil {
+		log.Fatalf("Could not check for updates: %v", err)
+	}
+	if isNewerVersion(rel.TagName, version) {
+		fmt.Printf("A newer release is available: %s. Run 'project-bundler self-update' to install it.\n", rel.TagName)
+		os.Exit(1)
+	}
+	fmt.Println("You are running the latest release.")
+}
+
+// warnIfOutdated logs a notice when a newer release than the running one is
+// out. The latest tag is cached in the user's cache directory and refreshed
+// at most once per updateCheckInterval, with a short timeout, so a command
+// only waits on the network once a day and not at all offline. It is off
+// unless PROJECT_BUNDLER_UPDATE_CHECK is set, so that no command contacts
+// GitHub unasked; development builds never check.
+func warnIfOutdated() {
+	if version == "dev" || os.Getenv("PROJECT_BUNDLER_UPDATE_CHECK") == "" {
+		return
+	}
+	file := updateCheckPath()
+	data, err := os.ReadFile(file)
+	if info, statErr := os.Stat(file); err != nil || statErr != nil || time.Since(info.ModTime()) >= updateCheckInterval {
+		// Record the attempt first, so a failing check is not retried on
+		// every run either.
+		os.MkdirAll(filepath.Dir(file), 0o755)
+		os.WriteFile(file, data, 0o644)
+		if rel, err := fetchLatestRelease(&http.Client{Timeout: 2 * time.Second}); err == nil {
+			data = []byte(rel.TagName + "\n")
+			os.WriteFile(file, data, 0o644)
+		}
+	}
+	if latest := strings.TrimSpace(string(data)); latest != "" && isNewerVersion(latest, version) {
+		log.Printf("project-bundler %s is available; this is %s. Run 'project-bundler self-update', or unset PROJECT_BUNDLER_UPDATE_CHECK to stop this notice.", latest, version)
+	}
+}
+
+// updateCheckPath returns where warnIfOutdated caches the latest tag.
+func updateCheckPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "project-bundler", "latest-release")
+}
+
+// runSelfUpdate implements the `self-update` subcommand. It downloads the
+// release binary for this platform, verifies it against the published
+// SHA-256 checksum, and atomically replaces the running executable. The
+// checksum comes from the same release as the binary, so it proves the
+// download is intact, not who published it.
+func runSelfUpdate(args []string) {
+	fs := flag.NewFlagSet("self-update", flag.ExitOnError)
+	force := fs.Bool("force", false, "Reinstall even if already on the latest release.")
+	fs.BoolVar(&plainOutput, "plain", plainOutput, "Disable emoji in the output.")
+	fs.Parse(args)
+
+	rel, err := fetchLatestRelease(httpClient)
+	if err != nil {
+		log.Fatalf("Could not check for updates: %v", err)
+	}
+	if !*force && !isNewerVersion(rel.TagName, version) {
+		fmt.Printf("Already up to date (%s).\n", version)
+		return
+	}
+
+	asset := binaryAssetName()
+	binURL, ok := rel.assetURL(asset)
+	if !ok {
+		log.Fatalf("Release %s has no binary for %s/%s", rel.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+	sumURL, ok := rel.assetURL(asset + ".sha256")
+	if !ok {
+		log.Fatalf("Release %s publishes no checksum for %s; refusing to install an unverified binary", rel.TagName, asset)
+	}
+
+	fmt.Printf("Downloading %s %s...\n", asset, rel.TagName)
+	binary, err := download(binURL)
+	if err != nil {
+		log.Fatalf("Download failed: %v", err)
+	}
+	sumFile, err := download(sumURL)
+	if err != nil {
+		log.Fatalf("Checksum download failed: %v", err)
+	}
+
+	// The checksum file uses sha256sum output format: "<hex>  <name>".
+	fields := strings.Fields(string(sumFile))
+	if len(fields) == 0 {
+		log.Fatalf("Checksum file for %s is empty", asset)
+	}
+	sum := sha256.Sum256(binary)
+	if !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+		log.Fatalf("Checksum mismatch for %s; the download may be corrupted or tampered with", asset)
+	}
+
+	if err := replaceExecutable(binary); err != nil {
+		log.Fatalf("Could not replace the binary: %v", err)
+	}
+	fmt.Print(plainText(fmt.Sprintf("✅ Updated project-bundler %s -> %s\n", version, rel.TagName)))
+}
+
+func download(url string) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// replaceExecutable writes the new binary next to the current one and renames
+// it into place. The old binary is moved aside first because Windows does not
+// allow overwriting a running executable.
+func replaceExecutable(binary []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".project-bundler-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, bytes.NewReader(binary)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		os.Rename(old, exe) // Best effort rollback.
+		return err
+	}
+	os.Remove(old) // Fails harmlessly on Windows while the old binary is running.
+	return nil
+}
@@ -0,0 +1,96 @@
+// project-bundler/vendor.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// availableDepSources lists the accepted -deps-source values.
+func availableDepSources() []string {
+	return []string{"auto", "vendor", "proxy"}
+}
+
+// readVendorModules returns the module versions listed in vendor/modules.txt
+// ("# path version" lines), or nil when the project does not vendor.
+func readVendorModules(srcDir string) map[string]string {
+	f, err := os.Open(filepath.Join(srcDir, "vendor", "modules.txt"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	modules := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Replaced modules read "# path version => replacement [version]".
+		if len(fields) >= 3 && fields[0] == "#" {
+			modules[fields[1]] = fields[2]
+		}
+	}
+	return modules
+}
+
+// readGoSum returns the go.sum hashes of module contents keyed by
+// "path version"; the go.mod-only hashes are left out.
+func readGoSum(srcDir string) map[string]string {
+	f, err := os.Open(filepath.Join(srcDir, "go.sum"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && !strings.HasSuffix(fields[1], "/go.mod") {
+			sums[fields[0]+" "+fields[1]] = fields[2]
+		}
+	}
+	return sums
+}
+
+// vendorMismatches reports vendored modules whose version go.sum does not
+// know, which means vendor/ was not refreshed after go.mod changed.
+func vendorMismatches(vendored, sums map[string]string) []string {
+	var problems []string
+	for path, version := range vendored {
+		if _, ok := sums[path+" "+version]; !ok && sums != nil {
+			problems = append(problems, fmt.Sprintf("vendor/ has %s %s, which go.sum does not list; run 'go mod vendor'", path, version))
+		}
+	}
+	return problems
+}
+
+// resolveDep picks where a -with-dep module's source comes from. With
+// "auto", a module vendored at the requested version (or any version when
+// none is requested) is taken from vendor/, everything else from the module
+// proxy; `go mod download` verifies those against go.sum itself.
+func resolveDep(srcDir, spec, source string, vendored map[string]string) (moduleDep, []string, error) {
+	path, version, _ := strings.Cut(spec, "@")
+	vendoredVersion, isVendored := vendored[path]
+	if source == "vendor" && !isVendored {
+		return moduleDep{}, nil, fmt.Errorf("%s is not in vendor/modules.txt", path)
+	}
+	useVendor := isVendored && (source == "vendor" || source == "auto" && (version == "" || version == vendoredVersion))
+	if useVendor {
+		var warnings []string
+		if version != "" && version != vendoredVersion {
+			warnings = append(warnings, fmt.Sprintf("%s is vendored at %s, not %s; bundling the vendored source", path, vendoredVersion, version))
+		}
+		return moduleDep{Path: path, Version: vendoredVersion, Dir: filepath.Join(srcDir, "vendor", filepath.FromSlash(path))}, warnings, nil
+	}
+
+	dep, err := resolveModuleDep(srcDir, spec)
+	if err != nil {
+		return dep, nil, err
+	}
+	var warnings []string
+	if isVendored {
+		warnings = append(warnings, fmt.Sprintf("%s is also vendored (at %s); bundling only the downloaded %s", path, vendoredVersion, dep.Version))
+	}
+	return dep, warnings, nil
+}
@@ -0,0 +1,88 @@
+// project-bundler/warm.go
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+)
+
+// runWarm implements the `warm` subcommand, which asks running daemons to
+// render and count the tokens of every bundled file ahead of the first
+// request, e.g. right after a deployment starts them. Each daemon keeps
+// what it already rendered, so running warm again after an interruption
+// resumes where it stopped.
+//
+//	project-bundler warm -socket /tmp/api.sock -socket /tmp/web.sock
+func runWarm(args []string) {
+	fs := flag.NewFlagSet("warm", flag.ExitOnError)
+	var sockets stringsFlag
+	fs.Var(&sockets, "socket", "Unix socket of a daemon to warm. May be repeated. Defaults to the daemon's default socket.")
+	quiet := fs.Bool("quiet", false, "Do not report progress, only the result per daemon.")
+	fs.Parse(args)
+	if len(sockets) == 0 {
+		sockets = stringsFlag{defaultDaemonSocket()}
+	}
+
+	failed := false
+	for _, socket := range sockets {
+		if err := warmDaemon(socket, *quiet); err != nil {
+			log.Printf("Could not warm '%s': %v", socket, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// warmDaemon sends a warm request to the daemon on socket and reports its
+// progress notifications until the result arrives.
+func warmDaemon(socket string, quiet bool) error {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(rpcRequest{JSONRPC: "2.0", ID: json.RawMessage("1"), Method: "warm"}); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(conn)
+	for {
+		var m struct {
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result *struct {
+				Warmed    int    `json:"warmed"`
+				Cached    int    `json:"cached"`
+				Failed    int    `json:"failed"`
+				Tokens    int    `json:"tokens"`
+				Tokenizer string `json:"tokenizer"`
+			} `json:"result"`
+			Error *rpcError `json:"error"`
+		}
+		if err := dec.Decode(&m); err != nil {
+			return fmt.Errorf("reading from daemon: %w", err)
+		}
+		switch {
+		case m.Error != nil:
+			return fmt.Errorf("%s", m.Error.Message)
+		case m.Method == "warmProgress":
+			var p struct{ Done, Total int }
+			if !quiet && json.Unmarshal(m.Params, &p) == nil {
+				fmt.Printf("%s: %d/%d files\n", socket, p.Done, p.Total)
+			}
+		case m.Result != nil:
+			r := m.Result
+			fmt.Printf("%s: warmed %d files (%d already cached), ~%d tokens (%s)\n", socket, r.Warmed, r.Cached, r.Tokens, r.Tokenizer)
+			if r.Failed > 0 {
+				return fmt.Errorf("%d files could not be read", r.Failed)
+			}
+			return nil
+		}
+	}
+}
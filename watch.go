@@ -0,0 +1,80 @@
+// project-bundler/watch.go
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/kbhuyan/project-bundler/pkg/bundler"
+)
+
+// watchAndRebundle implements -watch: it rescans the tree every interval
+// and writes the bundle again once a change has settled, that is when a
+// scan finds changes and the next one finds no further changes. Like the
+// daemon it polls the filtered file list, so only changes to files the
+// bundle would contain count. It runs until the process is stopped.
+func watchAndRebundle(opts bundleOptions, outputFile string, reportSkipped bool, interval time.Duration) {
+	opts.Cache = bundler.NewWalkCache() // Rescans and rebundles reuse what the previous walk read.
+	scan := func() map[string]fileStamp {
+		files, _, err := collectFiles(opts)
+		if err != nil {
+			log.Printf("Rescan failed: %v", err)
+			return nil
+		}
+		stamps := make(map[string]fileStamp, len(files))
+		for _, f := range files {
+			// Writing the bundle inside the tree is not a change.
+			if isOwnOutput(opts, outputFile, f.Path) {
+				continue
+			}
+			stamps[filepath.ToSlash(f.RelPath)] = fileStamp{size: f.Size, modTime: f.ModTime}
+		}
+		return stamps
+	}
+
+	printMsg("watching", opts.SrcDir, interval)
+	last := scan()
+	pending := 0 // Files changed since the bundle was last written.
+	for range time.Tick(interval) {
+		stamps := scan()
+		if stamps == nil {
+			continue
+		}
+		if changed := countChanged(last, stamps); changed > 0 {
+			pending += changed
+			last = stamps
+			continue
+		}
+		if pending == 0 {
+			continue
+		}
+		printMsg("watch-rebundling", pending)
+		result, err := writeBundle(opts, outputFile, reportSkipped)
+		if err != nil {
+			log.Printf("%v", err)
+			result = bundleResult{}
+		} else if len(result.outputs) > 0 {
+			resultPath = result.outputs[0]
+		}
+		writeResultLine(resultPath, result, err)
+		pending = 0
+		last = scan()
+	}
+}
+
+// countChanged counts the files added, removed or modified between two scans.
+func countChanged(prev, cur map[string]fileStamp) int {
+	n := 0
+	for rel, stamp := range cur {
+		if old, ok := prev[rel]; !ok || old != stamp {
+			n++
+		}
+	}
+	for rel := range prev {
+		if _, ok := cur[rel]; !ok {
+			n++
+		}
+	}
+	return n
+}
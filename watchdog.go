@@ -0,0 +1,69 @@
+// project-bundler/watchdog.go
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// watchdog enforces the optional wall-clock and output size limits so that
+// unattended runs never hang or fill the disk. Zero values disable a limit.
+type watchdog struct {
+	maxRuntime time.Duration
+	maxOutput  int64
+	deadline   time.Time
+}
+
+// start arms the runtime limit relative to now.
+func (w *watchdog) start() {
+	if w.maxRuntime > 0 {
+		w.deadline = time.Now().Add(w.maxRuntime)
+	}
+}
+
+func (w *watchdog) expired() bool {
+	return !w.deadline.IsZero() && time.Now().After(w.deadline)
+}
+
+// check returns a non-empty reason if writing `next` more bytes on top of
+// `written` would break a limit. Files are only ever written whole, so the
+// bundle stays structurally valid when the watchdog stops it.
+func (w *watchdog) check(written, next int64) string {
+	if w.expired() {
+		return fmt.Sprintf("maximum runtime of %s exceeded", w.maxRuntime)
+	}
+	if w.maxOutput > 0 && written+next > w.maxOutput {
+		return fmt.Sprintf("maximum output size of %s reached", formatSize(w.maxOutput))
+	}
+	return ""
+}
+
+// parseByteSize parses sizes such as "512", "200KB", "10MB" or "1.5GB"
+// (binary units, case-insensitive). An empty string means no limit.
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+	multipliers := []struct {
+		suffix string
+		factor float64
+	}{
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	factor := 1.0
+	for _, m := range multipliers {
+		if strings.HasSuffix(s, m.suffix) {
+			s, factor = strings.TrimSpace(strings.TrimSuffix(s, m.suffix)), m.factor
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * factor), nil
+}
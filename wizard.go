@@ -0,0 +1,295 @@
+// project-bundler/wizard.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// localConfigFile holds a project's own settings next to its sources. The
+// first-run wizard writes it; every later run reads it (or localConfigAltFile).
+const localConfigFile = ".bundler.yaml"
+
+// localConfigAltFile is the other common spelling, read when localConfigFile
+// does not exist.
+const localConfigAltFile = ".bundler.yml"
+
+// wizardSuggestions is how many candidate exclusions the wizard asks about.
+const wizardSuggestions = 10
+
+// localConfig is the content of localConfigFile. Command-line flags win over
+// its settings, which win over the preset's.
+type localConfig struct {
+	file string // Which of the two file names was read.
+
+	Extends     string // Presets to start from when -type is not given; URLs of shared configs are resolved into bases.
+	Override    bool   // Drop the preset's ignore lists instead of extending them.
+	IgnoreDirs  []string
+	IgnoreExts  []string
+	IgnorePaths []string          // Globs relative to the source directory, like -ignore-paths.
+	LangMap     map[string]string // Extension (".tmpl") or file name ("Jenkinsfile") -> language.
+	Style       string            // Default for -style.
+	Output      string            // Default for -output; may use {module}, {name} and {type}.
+	OutputDir   string            // Default for -output-dir.
+	Budgets     map[string]string // Path glob -> token budget, e.g. "docs/**": "10k".
+	Classes     map[string]string // Path glob -> classification, e.g. "docs/**": "public".
+
+	// The remove: lists of ignore-dirs, ignore-exts and ignore-paths: rules
+	// dropped from the presets' and the common ignore lists.
+	RemoveDirs, RemoveExts, RemovePaths []string
+
+	OnboardSections []string // Sections of `onboard` packets, in order.
+
+	bases []localConfig // The remote configs named in extends, whose ignore lists apply after this one's.
+}
+
+// layers returns the config followed by its bases, most specific first.
+func (c localConfig) layers() []localConfig {
+	return append([]localConfig{c}, c.bases...)
+}
+
+// loadLocalConfig reads localConfigFile (or localConfigAltFile) from srcDir.
+// It understands the subset of YAML the settings need: top-level scalars,
+// "- item" or inline [a, b] lists, optionally split into "add:" and
+// "remove:" lists below their key, and "key: value" maps indented below their
+// key or written inline as {k: v}. found is false when the project has no
+// such file. URLs in extends are fetched and merged in; see extendRemote.
+func loadLocalConfig(srcDir string) (config localConfig, found bool, err error) {
+	if config, found, err = readLocalConfig(srcDir); err != nil || !found {
+		return config, found, err
+	}
+	config, err = extendRemote(config, nil)
+	return config, true, err
+}
+
+// readLocalConfig reads the local config like loadLocalConfig, but leaves
+// the URLs in extends unresolved.
+func readLocalConfig(srcDir string) (config localConfig, found bool, err error) {
+	file := localConfigFile
+	f, err := os.Open(filepath.Join(srcDir, localConfigFile))
+	if os.IsNotExist(err) {
+		file = localConfigAltFile
+		f, err = os.Open(filepath.Join(srcDir, localConfigAltFile))
+	}
+	if os.IsNotExist(err) {
+		return localConfig{file: localConfigFile}, false, nil
+	} else if err != nil {
+		return localConfig{file: file}, false, err
+	}
+	defer f.Close()
+	config, err = parseLocalConfig(f, file)
+	return config, true, err
+}
+
+// parseLocalConfig parses the settings of loadLocalConfig from r; file names
+// it in errors and as the source of its rules.
+func parseLocalConfig(r io.Reader, file string) (config localConfig, err error) {
+	config.file = file
+	lists := map[string]*[]string{"ignore-dirs": &config.IgnoreDirs, "ignore-exts": &config.IgnoreExts, "ignore-paths": &config.IgnorePaths, "onboard-sections": &config.OnboardSections}
+	removals := map[string]*[]string{"ignore-dirs": &config.RemoveDirs, "ignore-exts": &config.RemoveExts, "ignore-paths": &config.RemovePaths}
+	scalars := map[string]*string{"extends": &config.Extends, "style": &config.Style, "output": &config.Output, "output-dir": &config.OutputDir}
+	maps := map[string]*map[string]string{"lang-map": &config.LangMap, "budgets": &config.Budgets, "classification": &config.Classes}
+	var override string
+	scalars["override"] = &override
+
+	var currentKey string
+	var currentList *[]string
+	var currentMap *map[string]string
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 && (i == 0 || text[i-1] == ' ') {
+			text = text[:i]
+		}
+		trimmed := strings.TrimSpace(text)
+		switch {
+		case trimmed == "":
+		case strings.HasPrefix(trimmed, "- "):
+			if currentList == nil {
+				return config, fmt.Errorf("%s:%d: list item outside a list key", config.file, line)
+			}
+			*currentList = append(*currentList, unquoteYAML(strings.TrimSpace(trimmed[2:])))
+		case (text[0] == ' ' || text[0] == '\t') && currentList != nil:
+			// A list written as "add:" and "remove:" keys below its own.
+			key, value, _ := strings.Cut(trimmed, ":")
+			switch key {
+			case "add":
+				currentList = lists[currentKey]
+			case "remove":
+				if currentList = removals[currentKey]; currentList == nil {
+					return config, fmt.Errorf("%s:%d: '%s' has no entries to remove", config.file, line, currentKey)
+				}
+			default:
+				return config, fmt.Errorf("%s:%d: '%s' takes 'add:' and 'remove:' lists, not '%s'", config.file, line, currentKey, key)
+			}
+			if !appendInlineList(currentList, strings.TrimSpace(value)) {
+				return config, fmt.Errorf("%s:%d: '%s' takes a list", config.file, line, key)
+			}
+		case text[0] == ' ' || text[0] == '\t':
+			key, value, ok := strings.Cut(trimmed, ":")
+			if !ok || currentMap == nil {
+				return config, fmt.Errorf("%s:%d: unexpected indented line", config.file, line)
+			}
+			(*currentMap)[unquoteYAML(strings.TrimSpace(key))] = unquoteYAML(strings.TrimSpace(value))
+		default:
+			key, value, ok := strings.Cut(trimmed, ":")
+			if !ok {
+				return config, fmt.Errorf("%s:%d: expected 'key:'", config.file, line)
+			}
+			value = strings.TrimSpace(value)
+			currentKey, currentList, currentMap = key, nil, nil
+			if list, ok := lists[key]; ok {
+				currentList = list
+				if !appendInlineList(list, value) {
+					return config, fmt.Errorf("%s:%d: '%s' takes a list", config.file, line, key)
+				}
+			} else if m, ok := maps[key]; ok {
+				currentMap = m
+				if *m == nil {
+					*m = make(map[string]string)
+				}
+				if strings.HasPrefix(value, "{") && strings.HasSuffix(value, "}") {
+					for _, pair := range strings.Split(value[1:len(value)-1], ",") {
+						k, v, ok := strings.Cut(pair, ":")
+						if !ok {
+							continue
+						}
+						(*m)[unquoteYAML(strings.TrimSpace(k))] = unquoteYAML(strings.TrimSpace(v))
+					}
+				} else if value != "" {
+					return config, fmt.Errorf("%s:%d: '%s' takes a map", config.file, line, key)
+				}
+			} else if scalar, ok := scalars[key]; ok {
+				*scalar = unquoteYAML(value)
+			} else {
+				return config, fmt.Errorf("%s:%d: unknown key '%s'", config.file, line, key)
+			}
+		}
+	}
+	for _, key := range []string{"ignore-dirs", "ignore-exts", "ignore-paths"} {
+		for _, rule := range *removals[key] {
+			if slices.Contains(*lists[key], rule) {
+				return config, fmt.Errorf("%s: '%s' both adds and removes '%s'", config.file, key, rule)
+			}
+		}
+	}
+	switch override {
+	case "", "false":
+	case "true":
+		config.Override = true
+	default:
+		return config, fmt.Errorf("%s: 'override' must be true or false", config.file)
+	}
+	return config, scanner.Err()
+}
+
+// appendInlineList appends the items of an inline [a, b] list to list. An
+// empty value, whose items follow on their own lines, adds nothing; any other
+// value is not a list.
+func appendInlineList(list *[]string, value string) bool {
+	if value == "" {
+		return true
+	}
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return false
+	}
+	for _, item := range strings.Split(value[1:len(value)-1], ",") {
+		if item = unquoteYAML(strings.TrimSpace(item)); item != "" {
+			*list = append(*list, item)
+		}
+	}
+	return true
+}
+
+// unquoteYAML strips the quotes of a quoted YAML scalar.
+func unquoteYAML(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// saveLocalConfig writes config to localConfigFile in srcDir.
+func saveLocalConfig(srcDir string, config localConfig) error {
+	return os.WriteFile(filepath.Join(srcDir, localConfigFile), []byte(formatLocalConfig(config)), 0o644)
+}
+
+// formatLocalConfig renders the settings the wizard and init choose: extends
+// and the ignore lists.
+func formatLocalConfig(config localConfig) string {
+	var b strings.Builder
+	b.WriteString("# project-bundler settings for this project; see `project-bundler -h`.\n")
+	if config.Extends != "" {
+		fmt.Fprintf(&b, "extends: %s\n", config.Extends)
+	}
+	for _, list := range []struct {
+		key   string
+		items []string
+	}{{"ignore-dirs", config.IgnoreDirs}, {"ignore-exts", config.IgnoreExts}} {
+		if len(list.items) == 0 {
+			fmt.Fprintf(&b, "%s: []\n", list.key)
+			continue
+		}
+		fmt.Fprintf(&b, "%s:\n", list.key)
+		for _, item := range list.items {
+			fmt.Fprintf(&b, "  - %q\n", item)
+		}
+	}
+	return b.String()
+}
+
+// runFirstRunWizard asks about the largest directories and extensions of a
+// project that has no localConfigFile yet, applies the chosen exclusions to
+// opts and saves them, so the wizard does not ask again. Nothing is saved
+// unless an exclusion is chosen. A first bundle is otherwise easily
+// dominated by fixtures, data or vendored code.
+func runFirstRunWizard(opts *bundleOptions) error {
+	files, _, err := collectFiles(*opts)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	suggestions := buildSuggestions(files, total, 0)
+	if len(suggestions) > wizardSuggestions {
+		suggestions = suggestions[:wizardSuggestions]
+	}
+
+	var config localConfig
+	if len(suggestions) > 0 {
+		fmt.Printf("\nFirst run in '%s': the bundle would hold %d files, %s. The largest parts are:\n", opts.SrcDir, len(files), formatSize(total))
+		reader := bufio.NewReader(os.Stdin)
+		for _, s := range suggestions {
+			fmt.Printf("  Exclude %s (%.0f%%, %s, %d files)? [y/N] ", strings.TrimPrefix(s.describe(), "excluding "), percent(s.bytes, total), formatSize(s.bytes), s.files)
+			answer, _ := reader.ReadString('\n')
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			if answer != "y" && answer != "yes" {
+				continue
+			}
+			switch s.kind {
+			case adviceDir:
+				config.IgnoreDirs = append(config.IgnoreDirs, s.name)
+			case adviceExt:
+				config.IgnoreExts = append(config.IgnoreExts, s.name)
+			}
+		}
+	}
+	if len(config.IgnoreDirs)+len(config.IgnoreExts) == 0 {
+		return nil
+	}
+	opts.IgnoreDirs.Add(config.IgnoreDirs, localConfigFile)
+	opts.IgnoreExts.Add(config.IgnoreExts, localConfigFile)
+
+	if err := saveLocalConfig(opts.SrcDir, config); err != nil {
+		return err
+	}
+	fmt.Printf("Saved your choices to '%s'; edit it to change them.\n\n", filepath.Join(opts.SrcDir, localConfigFile))
+	return nil
+}